@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/db/migrations"
+	"github.com/bwise1/waze_kibris/internal/firebaseapp"
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/nominatim"
+	api "github.com/bwise1/waze_kibris/internal/http/rest"
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util"
+	smtp "github.com/bwise1/waze_kibris/util/email"
+	"github.com/bwise1/waze_kibris/util/presence"
+)
+
+// Fixed points in Nicosia used for the provider test calls: one reverse geocode and a
+// route of a few hundred metres.
+var (
+	doctorOrigin      = [2]float64{35.1856, 33.3823} // lat, lng
+	doctorDestination = [2]float64{35.1876, 33.3846}
+)
+
+const doctorCheckTimeout = 10 * time.Second
+
+// errSkipped marks an integration that isn't configured.
+type errSkipped string
+
+func (e errSkipped) Error() string { return string(e) }
+
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// doctor exercises the database and every configured integration with a cheap call
+// and prints a readiness report. It fails if any configured check fails.
+func doctor(ctx context.Context, a *api.API) error {
+	cfg := a.Config
+	checks := []doctorCheck{
+		{"database", func(ctx context.Context) (string, error) {
+			if err := a.DB.Ping(ctx); err != nil {
+				return "", err
+			}
+			var postgis string
+			if err := a.DB.QueryRow(ctx, `SELECT PostGIS_Version()`).Scan(&postgis); err != nil {
+				return "", fmt.Errorf("PostGIS unavailable: %w", err)
+			}
+			return "PostGIS " + postgis, nil
+		}},
+		{"migrations", func(ctx context.Context) (string, error) {
+			objects, err := migrations.Objects()
+			if err != nil {
+				return "", err
+			}
+			missing, err := a.MissingMigrationObjectsRepo(ctx, objects)
+			if err != nil {
+				return "", err
+			}
+			if len(missing) > 0 {
+				var files []string
+				seen := map[string]bool{}
+				for _, o := range missing {
+					if !seen[o.Source] {
+						seen[o.Source] = true
+						files = append(files, o.Source)
+					}
+				}
+				return "", fmt.Errorf("%d of %d objects missing (from %s); run migrate up", len(missing), len(objects), strings.Join(files, ", "))
+			}
+			return fmt.Sprintf("%d objects present", len(objects)), nil
+		}},
+		{"valhalla", func(ctx context.Context) (string, error) {
+			if cfg.ValhallaURL == "" {
+				return "", errSkipped("VALHALLA_URL not set")
+			}
+			client := valhalla.NewValhallaClient(cfg.ValhallaURL)
+			status, err := client.GetStatus(ctx)
+			if err != nil {
+				return "", err
+			}
+			route, err := client.GetRoute(ctx, valhalla.RouteRequest{
+				Locations: []valhalla.Location{
+					{Lat: doctorOrigin[0], Lon: doctorOrigin[1]},
+					{Lat: doctorDestination[0], Lon: doctorDestination[1]},
+				},
+				Costing: "auto",
+			})
+			if err != nil {
+				return "", fmt.Errorf("test route: %w", err)
+			}
+			detail := fmt.Sprintf("version %s, test route %.0f s", status.Version, route.Trip.Summary.TotalTimeSeconds)
+			if built := status.TilesetBuiltAt(); built != nil {
+				detail += ", tiles built " + built.Format(time.DateOnly)
+			}
+			return detail, nil
+		}},
+		{"stadia", func(ctx context.Context) (string, error) {
+			if cfg.StadiaMapsAPIKey == "" {
+				return "", errSkipped("STADIA_MAPS_API_KEY not set")
+			}
+			results, err := stadiamaps.NewClient(cfg.StadiaMapsAPIKey).ReverseGeocode(ctx, doctorOrigin[0], doctorOrigin[1],
+				&stadiamaps.GeocodeQuery{Size: util.IntPtr(1)})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("reverse geocode returned %d results", len(results.Features)), nil
+		}},
+		{"google maps", func(ctx context.Context) (string, error) {
+			if cfg.GoogleMapsAPIKey == "" {
+				return "", errSkipped("GOOGLE_MAPS_API_KEY not set")
+			}
+			results, err := googlemaps.NewGoogleMapsClient(cfg.GoogleMapsAPIKey).ReverseGeocode(ctx, doctorOrigin[0], doctorOrigin[1], "")
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("reverse geocode returned %d results", len(results.Results)), nil
+		}},
+		{"nominatim", func(ctx context.Context) (string, error) {
+			if cfg.NominatimURL == "" {
+				return "", errSkipped("NOMINATIM_URL not set")
+			}
+			place, err := nominatim.NewClient(cfg.NominatimURL).Reverse(ctx, doctorOrigin[0], doctorOrigin[1], "")
+			if err != nil {
+				return "", err
+			}
+			if place == nil {
+				return "reverse geocode returned no address", nil
+			}
+			return "reverse geocode returned " + place.DisplayName, nil
+		}},
+		{"mapbox", func(ctx context.Context) (string, error) {
+			if cfg.MapboxAPIKey == "" {
+				return "", errSkipped("MAPBOX_API_KEY not set")
+			}
+			coords := []string{
+				fmt.Sprintf("%f,%f", doctorOrigin[1], doctorOrigin[0]),
+				fmt.Sprintf("%f,%f", doctorDestination[1], doctorDestination[0]),
+			}
+			resp, err := mapbox.NewMapboxClient(cfg.MapboxAPIKey).Directions(ctx, coords, "driving", false, false, "")
+			if err != nil {
+				return "", err
+			}
+			if len(resp.Routes) == 0 {
+				return "", fmt.Errorf("test route returned no routes (code %s)", resp.Code)
+			}
+			return fmt.Sprintf("test route %.0f s", resp.Routes[0].Duration), nil
+		}},
+		{"smtp", func(ctx context.Context) (string, error) {
+			if cfg.SMTPHost == "" {
+				return "", errSkipped("SMTP_HOST not set")
+			}
+			mailer := smtp.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom)
+			if err := mailer.Verify(); err != nil {
+				return "", err
+			}
+			return "logged in as " + cfg.SMTPUser, nil
+		}},
+		{"firebase", func(ctx context.Context) (string, error) {
+			auth, messaging, err := firebaseapp.InitAuthAndMessaging(ctx, cfg.FirebaseCredentialsPath)
+			if err != nil {
+				return "", err
+			}
+			if auth == nil {
+				return "", errSkipped("no Firebase credentials")
+			}
+			if messaging == nil {
+				return "auth only, push sending unavailable", nil
+			}
+			return "auth and messaging initialized", nil
+		}},
+		{"redis", func(ctx context.Context) (string, error) {
+			if cfg.RedisURL == "" {
+				return "", errSkipped("REDIS_URL not set, presence is per instance")
+			}
+			store, err := presence.NewRedisStore(ctx, cfg.RedisURL)
+			if err != nil {
+				return "", err
+			}
+			store.Close()
+			return "ping ok", nil
+		}},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tTIME\tDETAIL")
+	failed := 0
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, doctorCheckTimeout)
+		start := time.Now()
+		detail, err := c.run(checkCtx)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		cancel()
+
+		status := "ok"
+		if skipped, ok := err.(errSkipped); ok {
+			status, detail = "skipped", string(skipped)
+		} else if err != nil {
+			status, detail = "FAIL", err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.name, status, elapsed, detail)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	fmt.Println("ready")
+	return nil
+}
@@ -0,0 +1,184 @@
+// Command admin runs one-off operational tasks against the database configured in .env,
+// so ops doesn't have to write ad-hoc SQL.
+//
+//	go run ./cmd/admin <command> [flags]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bwise1/waze_kibris/config"
+	deps "github.com/bwise1/waze_kibris/internal/debs"
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	api "github.com/bwise1/waze_kibris/internal/http/rest"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  create-admin         -email <email> [-username <name>]  create an admin user, or promote an existing one
+  revoke-tokens        -user <user id>                    revoke all refresh tokens and device secrets for a user
+  regeocode-locations  [-all] [-delay 200ms]              fill saved location addresses via reverse geocoding
+  backfill-geohashes                                      set reports.geohash where missing
+  recompute-counters                                      rebuild report vote/comment and group member counts
+  import-lit           -file <ways.geojson>               load OSM street lighting for safe routing
+  doctor                                                  check the database, migrations and configured integrations
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	cfg := config.New()
+	deps := deps.New(cfg)
+	defer deps.DB.Close()
+
+	a := &api.API{
+		Config:           cfg,
+		Deps:             deps,
+		DB:               deps.DB.Pool(),
+		GoogleMapsClient: googlemaps.NewGoogleMapsClient(cfg.GoogleMapsAPIKey),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch cmd {
+	case "create-admin":
+		err = createAdmin(ctx, a, args)
+	case "revoke-tokens":
+		err = revokeTokens(ctx, a, args)
+	case "regeocode-locations":
+		err = regeocodeLocations(ctx, a, args)
+	case "backfill-geohashes":
+		err = backfillGeohashes(ctx, a)
+	case "recompute-counters":
+		err = recomputeCounters(ctx, a)
+	case "import-lit":
+		err = importLit(ctx, a, args)
+	case "doctor":
+		err = doctor(ctx, a)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd, err)
+		stop()
+		deps.DB.Close()
+		os.Exit(1)
+	}
+}
+
+func createAdmin(ctx context.Context, a *api.API, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	email := fs.String("email", "", "email of the admin user")
+	username := fs.String("username", "", "username to set if the user has none")
+	fs.Parse(args)
+
+	e := strings.ToLower(strings.TrimSpace(*email))
+	if e == "" {
+		return fmt.Errorf("-email is required")
+	}
+	var name *string
+	if *username != "" {
+		name = username
+	}
+
+	user, err := a.CreateAdminUserRepo(ctx, e, name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "user %s (%s) now has role %s\n", user.ID, user.Email, user.Role)
+	return nil
+}
+
+func revokeTokens(ctx context.Context, a *api.API, args []string) error {
+	fs := flag.NewFlagSet("revoke-tokens", flag.ExitOnError)
+	userID := fs.String("user", "", "id of the user whose tokens are revoked")
+	fs.Parse(args)
+
+	if _, err := uuid.Parse(*userID); err != nil {
+		return fmt.Errorf("-user must be a user id: %w", err)
+	}
+	refresh, secrets, err := a.RevokeUserTokensRepo(ctx, *userID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "revoked %d refresh tokens and %d device secrets for user %s; access tokens stay valid until they expire (%s)\n",
+		refresh, secrets, *userID, a.Config.JwtExpires)
+	return nil
+}
+
+func regeocodeLocations(ctx context.Context, a *api.API, args []string) error {
+	fs := flag.NewFlagSet("regeocode-locations", flag.ExitOnError)
+	all := fs.Bool("all", false, "re-geocode every saved location, not only those without an address")
+	delay := fs.Duration("delay", 200*time.Millisecond, "pause between geocoding requests")
+	fs.Parse(args)
+
+	if a.Config.GoogleMapsAPIKey == "" {
+		return fmt.Errorf("GOOGLE_MAPS_API_KEY is not set")
+	}
+	updated, failed, err := a.RegeocodeSavedLocationsRepo(ctx, *all, *delay)
+	fmt.Fprintf(os.Stdout, "re-geocoded %d saved locations, %d failed\n", updated, failed)
+	return err
+}
+
+func backfillGeohashes(ctx context.Context, a *api.API) error {
+	n, err := a.BackfillReportGeohashesRepo(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "set geohash on %d reports\n", n)
+	return nil
+}
+
+func recomputeCounters(ctx context.Context, a *api.API) error {
+	reports, groups, err := a.RecomputeCountersRepo(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "fixed counters on %d reports and %d groups\n", reports, groups)
+	return nil
+}
+
+// importLit loads a GeoJSON export of OSM ways with a lit tag, e.g. from
+//
+//	osmium tags-filter cyprus.osm.pbf w/lit -o lit.osm.pbf
+//	osmium export lit.osm.pbf --geometry-types=linestring --add-unique-id=type_id -o lit.geojson
+func importLit(ctx context.Context, a *api.API, args []string) error {
+	fs := flag.NewFlagSet("import-lit", flag.ExitOnError)
+	file := fs.String("file", "", "GeoJSON FeatureCollection of OSM ways")
+	fs.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stored, skipped, err := api.ReadLitWays(f, func(ways []model.LitWay) (int64, error) {
+		return a.UpsertLitWaysRepo(ctx, ways)
+	})
+	fmt.Fprintf(os.Stdout, "imported %d lit ways, skipped %d features without a way id, lit value or line geometry\n", stored, skipped)
+	return err
+}
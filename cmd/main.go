@@ -1,32 +1,61 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"log"
+	"log/slog"
 	"os"
-	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/bwise1/waze_kibris/config"
 	"github.com/bwise1/waze_kibris/internal/db"
+	"github.com/bwise1/waze_kibris/internal/db/migrations"
 	deps "github.com/bwise1/waze_kibris/internal/debs"
 	"github.com/bwise1/waze_kibris/internal/firebaseapp"
 	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
 	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/nominatim"
 	api "github.com/bwise1/waze_kibris/internal/http/rest"
 	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
 
 	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/http/vision"
+	"github.com/bwise1/waze_kibris/internal/lifecycle"
+	"github.com/bwise1/waze_kibris/internal/notifications"
+	"github.com/bwise1/waze_kibris/internal/sms"
+	"github.com/bwise1/waze_kibris/internal/telemetry"
 	smtp "github.com/bwise1/waze_kibris/util/email"
+	"github.com/bwise1/waze_kibris/util/logging"
 )
 
 const (
 	allowConnectionsAfterShutdown = 1 * time.Second
+	shutdownTimeout               = 30 * time.Second
 )
 
 func main() {
 	cfg := config.New()
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("invalid log settings: %v", err)
+	}
+	slog.SetDefault(logger)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	shutdownTracing, err := telemetry.Setup(context.Background(), telemetry.Options{
+		Endpoint:    cfg.OTelExporterEndpoint,
+		ServiceName: cmp.Or(cfg.OTelServiceName, "waze-kibris-api"),
+		SampleRatio: cfg.OTelTracesSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
 	deps := deps.New(cfg)
 
 	mailer := smtp.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPFrom)
@@ -35,29 +64,54 @@ func main() {
 	if err != nil {
 		log.Panicln("failed to connect to database", "error", err)
 	}
+	if cfg.AutoMigrate {
+		applied, err := migrations.Up(context.Background(), database.Pool())
+		if err != nil {
+			log.Panicln("failed to migrate database", "error", err)
+		}
+		slog.Info("Database migrated", "applied", len(applied))
+	}
 	valhallaClient := valhalla.NewValhallaClient(cfg.ValhallaURL)
-	log.Printf("Valhalla client initialized with BaseURL: %s", cfg.ValhallaURL)
+	slog.Info("Valhalla client initialized", "base_url", cfg.ValhallaURL)
 
 	stadiaClient := stadiamaps.NewClient(cfg.StadiaMapsAPIKey)
-	log.Printf("stadia client initialized")
+	slog.Info("Stadia client initialized")
 
 	googleMapsClient := googlemaps.NewGoogleMapsClient(cfg.GoogleMapsAPIKey)
 	mapboxClient := mapbox.NewMapboxClient(cfg.MapboxAPIKey)
-	log.Printf("Mapbox client initialized")
+	slog.Info("Mapbox client initialized")
+	nominatimClient := nominatim.NewClient(cfg.NominatimURL)
+	if cfg.NominatimURL != "" {
+		slog.Info("Nominatim client initialized", "base_url", cfg.NominatimURL)
+	}
+	valhallaClient.Strict = cfg.ProviderStrictParsing
+	stadiaClient.Strict = cfg.ProviderStrictParsing
+	googleMapsClient.Strict = cfg.ProviderStrictParsing
+	mapboxClient.Strict = cfg.ProviderStrictParsing
+	nominatimClient.Strict = cfg.ProviderStrictParsing
+	visionClient := vision.NewVisionClient(cfg.VisionAPIKey)
 
 	fbAuth, fbMessaging, err := firebaseapp.InitAuthAndMessaging(context.Background(), cfg.FirebaseCredentialsPath)
 	if err != nil {
 		log.Panicln("failed to init Firebase", err)
 	}
 	if fbAuth != nil {
-		log.Println("Firebase Auth client initialized (ID token verification enabled)")
+		slog.Info("Firebase Auth client initialized (ID token verification enabled)")
 		if fbMessaging != nil {
-			log.Println("Firebase Cloud Messaging client initialized (push send enabled)")
+			slog.Info("Firebase Cloud Messaging client initialized (push send enabled)")
 		} else {
-			log.Println("Firebase Messaging unavailable (FCM send disabled)")
+			slog.Warn("Firebase Messaging unavailable (FCM send disabled)")
 		}
 	} else {
-		log.Println("Firebase not configured (set FIREBASE_CREDENTIALS_PATH or GOOGLE_APPLICATION_CREDENTIALS)")
+		slog.Warn("Firebase not configured (set FIREBASE_CREDENTIALS_PATH or GOOGLE_APPLICATION_CREDENTIALS)")
+	}
+
+	var smsSender sms.Sender
+	if cfg.TwilioAccountSID != "" && cfg.TwilioAuthToken != "" && cfg.TwilioFrom != "" {
+		smsSender = sms.NewTwilio(cfg.TwilioAccountSID, cfg.TwilioAuthToken, cfg.TwilioFrom)
+		slog.Info("Twilio SMS client initialized (phone login enabled)")
+	} else {
+		slog.Warn("Twilio not configured (phone login disabled)")
 	}
 
 	a := &api.API{
@@ -69,28 +123,56 @@ func main() {
 		StadiaClient:       stadiaClient,
 		GoogleMapsClient:   googleMapsClient,
 		MapboxClient:       mapboxClient,
+		NominatimClient:    nominatimClient,
+		VisionClient:       visionClient,
 		FirebaseAuth:       fbAuth,
 		FirebaseMessaging:  fbMessaging,
+		Notifier:           notifications.New(fbMessaging),
+		SMS:                smsSender,
+		Logger:             logger,
 	}
 	a.Init()
-	go deps.WebSocket.Run()
-	go func() {
-		log.Printf("Server running on port %v ...", cfg.Port)
-		log.Fatal(a.Serve())
-	}()
-
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	<-stopChan
-
-	log.Println("Request to shutdown server. Doing nothing for ", allowConnectionsAfterShutdown)
-	waitTimer := time.NewTimer(allowConnectionsAfterShutdown)
-	<-waitTimer.C
-
-	log.Println("Shutting down server...")
-
-	database.Close()
-	log.Fatal("Database connections closed.")
+	if n, err := a.FailInterruptedReportExportsRepo(context.Background()); err != nil {
+		slog.Error("Failed to clean up interrupted report exports", "err", err)
+	} else if n > 0 {
+		slog.Info("Marked interrupted report exports as failed", "count", n)
+	}
+	a.Server = a.NewServer()
+
+	lc := lifecycle.New(allowConnectionsAfterShutdown, shutdownTimeout)
+	go deps.WebSocket.Run() // stays up until exit: the WebSocket drain unregisters through it
+	lc.Go("presence", deps.WebSocket.RunPresence)
+	lc.Go("outbox dispatcher", a.RunOutboxDispatcher)
+	lc.Go("db health monitor", a.RunDBHealthMonitor)
+	lc.Go("valhalla tile monitor", a.RunValhallaTileMonitor)
+	lc.Go("report auto-resolver", a.RunReportAutoResolver)
+	lc.Go("report lifecycle", a.RunReportLifecycle)
+	lc.Go("calendar sync", a.RunCalendarSync)
+	lc.Go("planned drive monitor", a.RunPlannedDriveMonitor)
+	lc.Go("upload cleaner", a.RunUploadCleaner)
+	lc.Go("account purger", a.RunAccountPurger)
+	lc.Go("nonce sweeper", a.RunNonceSweeper)
+	lc.Go("auth token sweeper", a.RunAuthTokenSweeper)
+	lc.Go("external usage recorder", a.RunExternalUsageRecorder)
+
+	lc.OnDrain("http server", a.Shutdown)
+	lc.OnDrain("websockets", deps.WebSocket.Shutdown)
+	lc.OnClose("database", func(context.Context) error {
+		database.Close()
+		deps.DB.Close()
+		return nil
+	})
+	if deps.Presence != nil {
+		lc.OnClose("redis", func(context.Context) error {
+			return deps.Presence.Close()
+		})
+	}
+	lc.OnClose("tracing", shutdownTracing)
 
-	log.Fatal(a.Shutdown())
+	slog.Info("Server running", "port", cfg.Port)
+	if err := lc.Run(a.Serve, os.Interrupt, syscall.SIGTERM); err != nil {
+		slog.Error("Shutdown incomplete", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("Server stopped")
 }
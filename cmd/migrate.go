@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bwise1/waze_kibris/config"
+	"github.com/bwise1/waze_kibris/internal/db"
+	"github.com/bwise1/waze_kibris/internal/db/migrations"
+)
+
+const migrateUsage = `usage: waze_kibris migrate <command>
+
+commands:
+  up      apply every pending migration
+  down    roll back the most recently applied migration
+  status  list the migrations and when each was applied
+`
+
+var errMigrateUsage = errors.New("expected up, down or status")
+
+// runMigrate runs the migrate subcommand against the database in DSN.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errMigrateUsage
+	}
+
+	database, err := db.New(cfg.Dsn)
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer database.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, database.Pool())
+		for _, v := range applied {
+			fmt.Println("applied", v)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		version, err := migrations.Down(ctx, database.Pool())
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			fmt.Println("no migrations applied")
+		} else {
+			fmt.Println("rolled back", version)
+		}
+		return nil
+	case "status":
+		statuses, err := migrations.List(ctx, database.Pool())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED AT")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%s\t%s\n", s.Version, applied)
+		}
+		return w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errMigrateUsage
+	}
+}
@@ -30,19 +30,109 @@ type Config struct {
 	StadiaMapsAPIKey    string `env:"STADIA_MAPS_API_KEY"`
 	GoogleMapsAPIKey    string `env:"GOOGLE_MAPS_API_KEY"`
 	MapboxAPIKey        string `env:"MAPBOX_API_KEY"`
+	// Cloud Vision key used for SafeSearch moderation of report images. Empty disables moderation.
+	VisionAPIKey string `env:"GOOGLE_VISION_API_KEY"`
 	// Path to Firebase service account JSON (server-side only). If empty, GOOGLE_APPLICATION_CREDENTIALS is used.
 	FirebaseCredentialsPath string `env:"FIREBASE_CREDENTIALS_PATH"`
+	// When true, report submissions and location heartbeats must carry a valid device signature.
+	// When false, signatures are verified only if the app sends them.
+	RequireSignedRequests bool `env:"REQUIRE_SIGNED_REQUESTS"`
+	// Optional endpoint that receives every outbox event as a signed JSON POST.
+	OutboxWebhookURL    string `env:"OUTBOX_WEBHOOK_URL"`
+	OutboxWebhookSecret string `env:"OUTBOX_WEBHOOK_SECRET"`
+	// Externally reachable base URL of this API (e.g. https://api.example.com), used for
+	// the browser fallback of emailed verification links. Empty leaves that link out.
+	PublicBaseURL string `env:"PUBLIC_BASE_URL"`
+	// Base URL of a Nominatim server (e.g. http://localhost:8080), a keyless geocoder
+	// used alongside Stadia and Google. Empty leaves it out.
+	NominatimURL string `env:"NOMINATIM_URL"`
+	// Pins /places geocoding to one provider ("stadia", "google" or "nominatim"). Empty
+	// means the healthiest configured provider is picked automatically.
+	GeocodingProvider string `env:"GEOCODING_PROVIDER"`
+	// Comma-separated order /route tries routing providers in ("mapbox", "valhalla",
+	// "google"); the next one is used when a provider fails. Empty means
+	// "mapbox,valhalla,google". Safe and motorcycle routes always need Valhalla.
+	RoutingProviders string `env:"ROUTING_PROVIDERS"`
+	// Comma-separated order road snapping of reports and navigation fixes tries providers
+	// in ("valhalla", "mapbox"). Empty means "valhalla,mapbox": the paid Mapbox Map
+	// Matching API is only used when Valhalla fails.
+	SnappingProviders string `env:"SNAPPING_PROVIDERS"`
+	// Percentage (0-100) of /route requests re-run on the other routing provider to
+	// measure divergence. 0 disables route auditing.
+	RouteAuditSamplePercent float64 `env:"ROUTE_AUDIT_SAMPLE_PERCENT"`
+	// Valhalla tiles older than this many days are reported stale and a rebuild is
+	// requested through the outbox webhook. 0 uses the default (30 days).
+	ValhallaTilesMaxAgeDays int `env:"VALHALLA_TILES_MAX_AGE_DAYS"`
+	// Mounts the admin-only /admin/debug endpoints (EXPLAIN ANALYZE on the geo queries).
+	// Leave off unless diagnosing a production issue.
+	DebugEndpoints bool `env:"DEBUG_ENDPOINTS"`
+	// Minutes speeds must stay back to normal around an active TRAFFIC or ACCIDENT report
+	// before it is resolved automatically. 0 uses the default (10); negative turns it off.
+	ReportAutoResolveGraceMinutes int `env:"REPORT_AUTO_RESOLVE_GRACE_MINUTES"`
+	// Share (0-1) of a location's usual speed at this hour that counts as back to normal.
+	// 0 uses the default (0.8).
+	ReportAutoResolveSpeedRatio float64 `env:"REPORT_AUTO_RESOLVE_SPEED_RATIO"`
+	// Hours a report can be kept up by "still there?" confirmations, counted from its
+	// creation. 0 uses the default (24).
+	ReportMaxLifetimeHours int `env:"REPORT_MAX_LIFETIME_HOURS"`
+	// redis://[:password@]host:port[/db], or rediss:// for TLS, shared by all API instances
+	// for WebSocket presence. Empty keeps presence in memory, which is only right for a
+	// single instance.
+	RedisURL string `env:"REDIS_URL"`
+	// Makes the Mapbox, Google, Stadia and Valhalla clients reject responses missing fields
+	// the app depends on, so upstream schema changes fail loudly. Meant for staging.
+	ProviderStrictParsing bool `env:"PROVIDER_STRICT_PARSING"`
+	// Milliseconds each upstream source gets in fan-out endpoints (e.g. /places/unified-search)
+	// before the response is sent without it and flagged degraded. 0 uses the default (1500).
+	AggregateSourceBudgetMs int `env:"AGGREGATE_SOURCE_BUDGET_MS"`
+	// /auth/refresh only rotates the refresh token once it has fewer than this many hours
+	// left; until then it returns a new access token and the same refresh token, sparing
+	// auth_tokens a write per background refresh. 0 rotates on every refresh.
+	RefreshRotateWithinHours int `env:"REFRESH_ROTATE_WITHIN_HOURS"`
+	// Per-type overrides for folding new reports into an existing nearby one, as
+	// "TYPE=radius_m/window_min" pairs, e.g. "POLICE=200/20,ACCIDENT=0/0" (0 turns it off).
+	// Types not listed use the built-in rule (100 m, 30 minutes; off for PHOTOSHARING).
+	ReportDedupRules string `env:"REPORT_DEDUP_RULES"`
+	// Per-group overrides of the request rate limits, as "group:scope=per_minute/burst"
+	// pairs where scope is user or ip, e.g. "places:user=120/30,directions:ip=0/0"
+	// (0 turns that bucket off). Groups: places, directions, report_create, traffic_samples,
+	// image_upload, phone_code.
+	RateLimits string `env:"RATE_LIMITS"`
+	// Load balancers and proxies in front of the API, as comma separated IPs or CIDRs
+	// (e.g. "10.0.0.0/8"). Requests arriving through them are keyed on the client address
+	// they add to X-Forwarded-For; empty ignores the header and uses the connection's peer.
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+	// Applies pending database migrations at startup, before serving. Without it run
+	// "migrate up" before deploying.
+	AutoMigrate bool `env:"AUTO_MIGRATE"`
+	// Minimum level written to the log: debug, info, warn or error. Empty means info.
+	LogLevel string `env:"LOG_LEVEL"`
+	// "json" (one object per line, for log shipping) or "text". Empty means json.
+	LogFormat string `env:"LOG_FORMAT"`
+	// OTLP/HTTP collector base URL (e.g. http://otel-collector:4318) that receives
+	// request, provider-call and query spans. Empty turns tracing off.
+	OTelExporterEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// service.name on exported spans. Empty means waze-kibris-api.
+	OTelServiceName string `env:"OTEL_SERVICE_NAME"`
+	// Share (0-1) of new traces recorded; requests arriving with a trace context follow the
+	// caller's sampling decision. 0 records every trace.
+	OTelTracesSampleRatio float64 `env:"OTEL_TRACES_SAMPLE_RATIO"`
+	// Twilio credentials for sending phone login codes. Phone login is off without them.
+	TwilioAccountSID string `env:"TWILIO_ACCOUNT_SID"`
+	TwilioAuthToken  string `env:"TWILIO_AUTH_TOKEN"`
+	// Number or messaging service SID (MG...) the codes are sent from.
+	TwilioFrom string `env:"TWILIO_FROM"`
 }
 
 func New() *Config {
 	if loadErr := godotenv.Load(".env"); loadErr != nil {
-		log.Println("[Env]: unable to load .env file %v", loadErr)
+		log.Printf("[Env]: unable to load .env file %v", loadErr)
 	}
 
 	var cfg Config
 
 	if parseErr := env.Parse(&cfg); parseErr != nil {
-		log.Println("[Env]: failed to parse environment variables: %v", parseErr)
+		log.Printf("[Env]: failed to parse environment variables: %v", parseErr)
 	}
 
 	return &cfg
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/bwise1/waze_kibris/internal/telemetry"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -30,6 +31,7 @@ func New(dsn string) (*DB, error) {
 	config.MaxConnLifetime = 2 * time.Hour
 	config.MaxConnIdleTime = 5 * time.Minute
 	config.ConnConfig.RuntimeParams["prefer_simple_protocol"] = "true"
+	config.ConnConfig.Tracer = telemetry.QueryTracer{}
 
 	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
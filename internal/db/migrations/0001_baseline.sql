@@ -0,0 +1,198 @@
+-- Base schema every later migration builds on: users and their auth tables, reports with
+-- their comments and votes, saved locations, and community groups with memberships and
+-- invitations. Replaces the old hand-run db.sql, with the columns the code has relied on
+-- since (users.username/is_verified, email_verifications.type, saved_locations.address,
+-- comments.content, community_groups.short_code) folded in. On a database created from
+-- db.sql it only adds what is missing.
+-- Safe to run repeatedly.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) NOT NULL UNIQUE,
+    firstname VARCHAR(50),
+    lastname VARCHAR(50),
+    auth_provider VARCHAR(20) NOT NULL, -- 'email', 'google' or 'system'
+    auth_provider_id VARCHAR(255),
+    preferred_language VARCHAR(10) DEFAULT 'en',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS username VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username) WHERE username IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_update_updated_at ON users;
+CREATE TRIGGER trigger_update_updated_at
+BEFORE UPDATE ON users
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS email_verifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id),
+    email VARCHAR(255) NOT NULL,
+    verification_code VARCHAR(4),
+    verification_token UUID DEFAULT gen_random_uuid(),
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- What the code is for: 'register' or 'login'.
+ALTER TABLE email_verifications ADD COLUMN IF NOT EXISTS type VARCHAR(32);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_lookup
+    ON email_verifications (email, type, verification_code);
+
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id),
+    token_type VARCHAR(20) NOT NULL, -- 'access', 'refresh'
+    token_value TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    is_revoked BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_user ON auth_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_expiry ON auth_tokens (expires_at);
+
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID REFERENCES users(id) NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('TRAFFIC', 'POLICE', 'ACCIDENT', 'HAZARD', 'ROAD_CLOSED')),
+    subtype TEXT CHECK (subtype IN ('LIGHT', 'HEAVY', 'STAND_STILL', 'VISIBLE', 'HIDDEN', 'OTHER_SIDE', 'MINOR', 'MAJOR')),
+    position GEOMETRY(Point, 4326) NOT NULL,
+    description TEXT,
+    severity INTEGER CHECK (severity BETWEEN 1 AND 5),
+    verified_count INTEGER DEFAULT 0,
+    active BOOLEAN DEFAULT TRUE,
+    resolved BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    image_url TEXT,
+    report_source TEXT CHECK (report_source IN ('USER', 'AUTOMATIC')),
+    report_status TEXT CHECK (report_status IN ('PENDING', 'VERIFIED', 'RESOLVED')),
+    comments_count INTEGER DEFAULT 0,
+    upvotes_count INTEGER DEFAULT 0,
+    downvotes_count INTEGER DEFAULT 0,
+    CONSTRAINT valid_report_position CHECK (ST_IsValid(position))
+);
+
+CREATE INDEX IF NOT EXISTS reports_position_idx ON reports USING GIST (position);
+CREATE INDEX IF NOT EXISTS reports_active_idx ON reports (active) WHERE active = TRUE;
+CREATE INDEX IF NOT EXISTS reports_expires_at_idx ON reports (expires_at);
+CREATE INDEX IF NOT EXISTS reports_user_id_idx ON reports (user_id);
+CREATE INDEX IF NOT EXISTS reports_resolved_idx ON reports (resolved);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id BIGINT REFERENCES reports(id) NOT NULL,
+    user_id UUID REFERENCES users(id) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS comments_report_id_idx ON comments (report_id);
+CREATE INDEX IF NOT EXISTS comments_user_id_idx ON comments (user_id);
+
+CREATE TABLE IF NOT EXISTS votes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id BIGINT REFERENCES reports(id) NOT NULL,
+    user_id UUID REFERENCES users(id) NOT NULL,
+    vote_type TEXT NOT NULL CHECK (vote_type IN ('UPVOTE', 'DOWNVOTE')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS votes_report_id_idx ON votes (report_id);
+CREATE INDEX IF NOT EXISTS votes_user_id_idx ON votes (user_id);
+
+-- The (user_id, name) unique constraint is added by add_unique_constraint_saved_locations.
+CREATE TABLE IF NOT EXISTS saved_locations (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID REFERENCES users(id),
+    name VARCHAR(50) NOT NULL, -- e.g. 'Home', 'Office'
+    location GEOMETRY(Point, 4326) NOT NULL,
+    place_id VARCHAR(255), -- Google or other provider place ID
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE saved_locations ADD COLUMN IF NOT EXISTS address TEXT;
+ALTER TABLE saved_locations ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS community_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    description TEXT,
+    group_type TEXT NOT NULL CHECK (group_type IN ('destination', 'event', 'route', 'general')) DEFAULT 'general',
+    destination_place_id TEXT,
+    destination_name TEXT,
+    destination_location GEOMETRY(Point, 4326),
+    visibility TEXT NOT NULL CHECK (visibility IN ('public', 'private')) DEFAULT 'public',
+    creator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    icon_url TEXT,
+    member_count INT DEFAULT 0,
+    last_message_at TIMESTAMPTZ,
+    is_deleted BOOLEAN DEFAULT FALSE,
+    deleted_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Invite code shared to join private groups; the constraint name is matched in group_helper.go.
+ALTER TABLE community_groups ADD COLUMN IF NOT EXISTS short_code TEXT;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'community_groups_short_code_key') THEN
+    ALTER TABLE community_groups ADD CONSTRAINT community_groups_short_code_key UNIQUE (short_code);
+  END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_community_groups_visibility ON community_groups (visibility) WHERE is_deleted = FALSE;
+CREATE INDEX IF NOT EXISTS idx_community_groups_type ON community_groups (group_type) WHERE is_deleted = FALSE;
+CREATE INDEX IF NOT EXISTS idx_community_groups_last_message_at
+    ON community_groups (last_message_at DESC NULLS LAST) WHERE is_deleted = FALSE;
+
+-- last_read_at is introduced by add_last_read_at_to_group_memberships.
+CREATE TABLE IF NOT EXISTS group_memberships (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES community_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('member', 'moderator', 'admin')) DEFAULT 'member',
+    notifications_enabled BOOLEAN DEFAULT TRUE,
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_memberships_user_id ON group_memberships (user_id);
+
+CREATE TABLE IF NOT EXISTS group_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES community_groups(id) ON DELETE CASCADE,
+    invited_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')) DEFAULT 'pending',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- At most one pending invitation per user and group.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_invitations_pending
+    ON group_invitations (group_id, invited_user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_group_invitations_invited_user_id_status
+    ON group_invitations (invited_user_id, status);
@@ -6,3 +6,5 @@ CREATE INDEX IF NOT EXISTS idx_community_groups_destination_location
   USING GIST (destination_location)
   WHERE is_deleted = FALSE;
 
+-- +migrate Down
+DROP INDEX IF EXISTS idx_community_groups_destination_location;
@@ -40,3 +40,5 @@ ALTER TABLE group_memberships
   ALTER COLUMN last_read_at SET DEFAULT NOW(),
   ALTER COLUMN last_read_at SET NOT NULL;
 
+-- +migrate Down
+-- Nothing to undo: last_read_at is kept rather than renamed back.
@@ -15,3 +15,6 @@ ALTER TABLE reports ADD CONSTRAINT reports_type_check CHECK (
     'PHOTOSHARING'
   )
 );
+
+-- +migrate Down
+-- Nothing to undo: PHOTOSHARING reports would break the narrower CHECK, so it stays.
@@ -10,10 +10,18 @@ WHERE id NOT IN (
     GROUP BY user_id, name
 );
 
--- Step 2: Add the unique constraint
-ALTER TABLE saved_locations
-ADD CONSTRAINT saved_locations_user_name_unique UNIQUE(user_id, name);
+-- Step 2: Add the unique constraint (unless an earlier run already did)
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'saved_locations_user_name_unique') THEN
+    ALTER TABLE saved_locations
+    ADD CONSTRAINT saved_locations_user_name_unique UNIQUE(user_id, name);
+  END IF;
+END $$;
 
 -- Step 3: Create an index on (user_id, name) for faster lookups
 -- Note: The UNIQUE constraint already creates an index, so this step is optional
 -- CREATE INDEX IF NOT EXISTS idx_saved_locations_user_name ON saved_locations(user_id, name);
+
+-- +migrate Down
+ALTER TABLE saved_locations DROP CONSTRAINT IF EXISTS saved_locations_user_name_unique;
@@ -17,3 +17,6 @@ SET profile_icon = (
   ]
 )[1 + floor(random() * 9)::int]
 WHERE profile_icon IS NULL;
+
+-- +migrate Down
+-- Nothing to undo: the assigned icons are kept.
@@ -1,6 +1,5 @@
 -- Migration: Create messages table for group chat (and later DMs).
 -- Prerequisites: community_groups and users tables must exist.
--- Run with: psql -d your_database -f internal/db/migrations/create_messages.sql
 
 CREATE TABLE IF NOT EXISTS messages (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -39,3 +38,6 @@ CREATE INDEX IF NOT EXISTS idx_messages_parent_id
     ON messages (parent_message_id);
 CREATE INDEX IF NOT EXISTS idx_messages_type
     ON messages (message_type);
+
+-- +migrate Down
+DROP TABLE IF EXISTS messages;
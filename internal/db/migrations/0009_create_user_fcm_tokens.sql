@@ -11,3 +11,6 @@ CREATE TABLE IF NOT EXISTS user_fcm_tokens (
 );
 
 CREATE INDEX IF NOT EXISTS idx_user_fcm_tokens_user_id ON user_fcm_tokens(user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_fcm_tokens;
@@ -0,0 +1,27 @@
+-- Per-device signing secrets issued at login, and the nonces already seen for signed requests.
+-- The app signs report submissions and location heartbeats with HMAC-SHA256 using its device secret.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS device_secrets (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_id TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT device_secrets_user_device_unique UNIQUE (user_id, device_id)
+);
+
+CREATE TABLE IF NOT EXISTS request_nonces (
+    nonce TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_nonces_expires_at ON request_nonces(expires_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS request_nonces;
+DROP TABLE IF EXISTS device_secrets;
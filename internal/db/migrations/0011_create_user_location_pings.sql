@@ -0,0 +1,16 @@
+-- Location heartbeats sent by the app while driving (POST /user/location).
+CREATE TABLE IF NOT EXISTS user_location_pings (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position GEOMETRY(Point, 4326) NOT NULL,
+    speed DOUBLE PRECISION,    -- m/s as reported by the device
+    heading DOUBLE PRECISION,  -- degrees clockwise from north
+    accuracy DOUBLE PRECISION, -- meters
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_location_pings_user_recorded ON user_location_pings(user_id, recorded_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_location_pings;
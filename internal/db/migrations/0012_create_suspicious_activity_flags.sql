@@ -0,0 +1,35 @@
+-- Review queue for accounts whose location streams or reports look fabricated
+-- (teleporting, impossible heading changes, emulator devices).
+-- Also records device fingerprints on the device registry (device_secrets).
+-- Safe to run repeatedly.
+
+ALTER TABLE device_secrets ADD COLUMN IF NOT EXISTS device_model TEXT;
+ALTER TABLE device_secrets ADD COLUMN IF NOT EXISTS platform TEXT;
+ALTER TABLE device_secrets ADD COLUMN IF NOT EXISTS is_emulator BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS suspicious_activity_flags (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('teleport', 'impossible_heading', 'emulator')),
+    source TEXT NOT NULL CHECK (source IN ('location', 'report')),
+    details JSONB,
+    occurrences INT NOT NULL DEFAULT 1,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'confirmed', 'dismissed')) DEFAULT 'pending',
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One open flag per user and reason; repeat offences bump occurrences instead of adding rows.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_suspicious_activity_flags_pending
+  ON suspicious_activity_flags (user_id, reason)
+  WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_suspicious_activity_flags_status ON suspicious_activity_flags (status, last_seen_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS suspicious_activity_flags;
+ALTER TABLE device_secrets DROP COLUMN IF EXISTS device_model;
+ALTER TABLE device_secrets DROP COLUMN IF EXISTS platform;
+ALTER TABLE device_secrets DROP COLUMN IF EXISTS is_emulator;
@@ -0,0 +1,9 @@
+-- Global user role used to gate admin endpoints (/admin/*).
+-- Promote an operator with: UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- +migrate Down
+ALTER TABLE users DROP COLUMN IF EXISTS role;
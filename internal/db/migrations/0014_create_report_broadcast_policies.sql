@@ -0,0 +1,30 @@
+-- Broadcast radius policy for report_update WebSocket events.
+-- The row with the matching report_type (or '*' as fallback) and the highest
+-- min_severity not above the report's severity wins. Editable via /admin/broadcast-policies.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_broadcast_policies (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    report_type TEXT NOT NULL, -- TRAFFIC, POLICE, ACCIDENT, HAZARD, ROAD_CLOSED, PHOTOSHARING or '*'
+    min_severity INT NOT NULL DEFAULT 1 CHECK (min_severity BETWEEN 1 AND 5),
+    radius_m INT NOT NULL CHECK (radius_m > 0),
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT report_broadcast_policies_type_severity_unique UNIQUE (report_type, min_severity)
+);
+
+INSERT INTO report_broadcast_policies (report_type, min_severity, radius_m) VALUES
+    ('*', 1, 5000),
+    ('ACCIDENT', 1, 5000),
+    ('ACCIDENT', 4, 10000),
+    ('ROAD_CLOSED', 1, 10000),
+    ('TRAFFIC', 1, 5000),
+    ('POLICE', 1, 3000),
+    ('HAZARD', 1, 1000),
+    ('HAZARD', 4, 3000),
+    ('PHOTOSHARING', 1, 2000)
+ON CONFLICT (report_type, min_severity) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_broadcast_policies;
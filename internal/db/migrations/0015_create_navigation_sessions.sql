@@ -0,0 +1,27 @@
+-- Active navigation sessions with a precomputed, buffered route corridor.
+-- corridor is ST_Buffer(route_geom::geography, corridor_width_m)::geometry, computed once at
+-- session start and again on reroute (route_version is bumped), so matching reports along
+-- the route can use a plain ST_Intersects against the GIST index instead of re-buffering.
+-- Position and ETA updates measure against route_geom directly and don't use it.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS navigation_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL CHECK (status IN ('active', 'finished', 'cancelled')) DEFAULT 'active',
+    route_geom GEOMETRY(LineString, 4326) NOT NULL,
+    corridor GEOMETRY(Geometry, 4326) NOT NULL,
+    corridor_width_m INT NOT NULL,
+    route_version INT NOT NULL DEFAULT 1,
+    destination GEOMETRY(Point, 4326),
+    destination_name TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_navigation_sessions_user_status ON navigation_sessions (user_id, status);
+CREATE INDEX IF NOT EXISTS idx_navigation_sessions_corridor ON navigation_sessions USING GIST (corridor) WHERE status = 'active';
+
+-- +migrate Down
+DROP TABLE IF EXISTS navigation_sessions;
@@ -0,0 +1,26 @@
+-- Transactional outbox for report and group events.
+-- Rows are written in the same transaction as the domain change and published to the
+-- WebSocket, push and webhook sinks by the outbox dispatcher (at-least-once). dedup_key
+-- makes producers idempotent; consumers can dedupe on the event id.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    aggregate_id TEXT NOT NULL,
+    dedup_key TEXT NOT NULL UNIQUE,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'published', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending
+    ON event_outbox (next_attempt_at, id)
+    WHERE status = 'pending';
+
+-- +migrate Down
+DROP TABLE IF EXISTS event_outbox;
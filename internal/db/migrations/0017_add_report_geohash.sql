@@ -0,0 +1,10 @@
+-- Geohash of each report's position, used for coarse bucketing of nearby reports.
+-- New reports get it on insert; existing rows are filled by `admin backfill-geohashes`.
+-- Safe to run repeatedly.
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS geohash TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_reports_geohash ON reports (geohash text_pattern_ops);
+
+-- +migrate Down
+ALTER TABLE reports DROP COLUMN IF EXISTS geohash;
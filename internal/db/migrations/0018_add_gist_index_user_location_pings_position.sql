@@ -0,0 +1,13 @@
+-- Speeds up the historical speed lookups along a route used for arrival windows.
+-- Safe to run repeatedly.
+
+CREATE INDEX IF NOT EXISTS idx_user_location_pings_position
+  ON user_location_pings
+  USING GIST (position);
+
+CREATE INDEX IF NOT EXISTS idx_user_location_pings_recorded_at
+  ON user_location_pings (recorded_at);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_user_location_pings_position;
+DROP INDEX IF EXISTS idx_user_location_pings_recorded_at;
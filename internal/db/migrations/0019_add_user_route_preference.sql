@@ -0,0 +1,9 @@
+-- Default routing preference used by POST /route when the request doesn't set one.
+-- 'safe' favours main roads and lit streets over dark shortcuts.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS route_preference TEXT NOT NULL DEFAULT 'fastest';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_route_preference_check;
+ALTER TABLE users ADD CONSTRAINT users_route_preference_check CHECK (route_preference IN ('fastest', 'safe'));
+
+-- +migrate Down
+ALTER TABLE users DROP COLUMN IF EXISTS route_preference;
@@ -0,0 +1,24 @@
+-- Migration: Allow two-wheeler hazard subtypes (GRAVEL, OIL_SLICK) in reports.subtype.
+-- Used with type HAZARD; motorcycle routes avoid them.
+
+-- Drop the existing CHECK constraint on reports.subtype (PostgreSQL default name)
+ALTER TABLE reports DROP CONSTRAINT IF EXISTS reports_subtype_check;
+
+-- Re-add the constraint including the two-wheeler subtypes
+ALTER TABLE reports ADD CONSTRAINT reports_subtype_check CHECK (
+  subtype IN (
+    'LIGHT',
+    'HEAVY',
+    'STAND_STILL',
+    'VISIBLE',
+    'HIDDEN',
+    'OTHER_SIDE',
+    'MINOR',
+    'MAJOR',
+    'GRAVEL',
+    'OIL_SLICK'
+  )
+);
+
+-- +migrate Down
+-- Nothing to undo: two-wheeler hazards would break the narrower CHECK, so it stays.
@@ -0,0 +1,25 @@
+-- Moderation log for images attached to reports. Every upload gets a row with the
+-- SafeSearch verdict so held images can be reviewed and rejections appealed.
+-- Held and rejected images are stored outside the public "reports" folder and only
+-- reach reports.image_url once approved.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_image_moderations (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    report_id BIGINT REFERENCES reports(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    image_url TEXT,
+    provider TEXT NOT NULL,
+    decision TEXT NOT NULL CHECK (decision IN ('approved', 'held', 'rejected')),
+    reason TEXT,
+    scores JSONB,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_image_moderations_decision ON report_image_moderations (decision, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_report_image_moderations_user ON report_image_moderations (user_id, created_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_image_moderations;
@@ -0,0 +1,26 @@
+-- Async exports of anonymized historical reports for local authorities.
+-- Each row is one admin-requested job; the finished file is stored in Cloudinary and
+-- linked from file_url.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_exports (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    format TEXT NOT NULL CHECK (format IN ('csv', 'geojson')),
+    filters JSONB NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'running', 'done', 'failed')) DEFAULT 'pending',
+    file_url TEXT,
+    row_count INT,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_exports_created_at ON report_exports (created_at DESC);
+
+-- Exports filter on type and creation time.
+CREATE INDEX IF NOT EXISTS idx_reports_type_created_at ON reports (type, created_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_exports;
+DROP INDEX IF EXISTS idx_reports_type_created_at;
@@ -0,0 +1,22 @@
+-- Per-membership push settings for group messages:
+--   all      - push for every message (default)
+--   mentions - push only when the member's @username is in the message
+--   muted    - no pushes; messages still arrive over the WebSocket and count as unread
+-- Safe to run repeatedly.
+
+ALTER TABLE group_memberships
+  ADD COLUMN IF NOT EXISTS notification_level TEXT NOT NULL DEFAULT 'all';
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'group_memberships_notification_level_check'
+  ) THEN
+    ALTER TABLE group_memberships
+      ADD CONSTRAINT group_memberships_notification_level_check
+      CHECK (notification_level IN ('all', 'mentions', 'muted'));
+  END IF;
+END $$;
+
+-- +migrate Down
+ALTER TABLE group_memberships DROP COLUMN IF EXISTS notification_level;
@@ -0,0 +1,40 @@
+-- Group admins can pin messages and post announcements.
+--   pinned_at / pinned_by - set while a message is pinned to the top of its group
+--   'announcement'        - message type pushed to every member regardless of their
+--                           notification level; rate limited per group in the API
+-- Safe to run repeatedly.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS pinned_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_group_pinned
+  ON messages (group_id, pinned_at DESC)
+  WHERE pinned_at IS NOT NULL;
+
+-- Announcement rate limiting counts recent announcements per group.
+CREATE INDEX IF NOT EXISTS idx_messages_group_announcements
+  ON messages (group_id, created_at DESC)
+  WHERE message_type = 'announcement';
+
+-- Re-add the message_type CHECK (PostgreSQL default name) including 'announcement'
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check CHECK (
+  message_type IN (
+    'text',
+    'location_update',
+    'eta_update',
+    'report_share',
+    'poll',
+    'system',
+    'image',
+    'location_pin',
+    'report_pin',
+    'announcement'
+  )
+);
+
+-- +migrate Down
+-- The message_type CHECK keeps 'announcement' so existing announcements stay valid.
+DROP INDEX IF EXISTS idx_messages_group_announcements;
+ALTER TABLE messages DROP COLUMN IF EXISTS pinned_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS pinned_by;
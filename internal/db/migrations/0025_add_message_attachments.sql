@@ -0,0 +1,22 @@
+-- Structured attachments on group messages (see model.MessageAttachment):
+--   {"kind": "location", "location": {...}} - a saved location or dropped pin
+--   {"kind": "eta", "eta": {...}}           - live ETA for the sender's navigation session
+--   {"kind": "route", "route": {...}}       - the sender's navigation route
+-- Payloads are validated by the API; live fields are expanded on read, not stored.
+-- Safe to run repeatedly.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachment JSONB;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'messages_attachment_kind_check'
+  ) THEN
+    ALTER TABLE messages
+      ADD CONSTRAINT messages_attachment_kind_check
+      CHECK (attachment IS NULL OR attachment->>'kind' IN ('location', 'eta', 'route'));
+  END IF;
+END $$;
+
+-- +migrate Down
+ALTER TABLE messages DROP COLUMN IF EXISTS attachment;
@@ -0,0 +1,34 @@
+-- Sampled production route requests re-run on the other routing provider (Valhalla vs
+-- Mapbox), with the divergence between the two answers. Used to catch routing-quality
+-- regressions on the self-hosted Valhalla tiles. See ROUTE_AUDIT_SAMPLE_PERCENT.
+--   duration_diff_pct / distance_diff_pct - (audit - primary) / primary * 100
+--   geometry_divergence_m                 - symmetric Hausdorff distance between the routes
+--   flagged                               - divergence above the alert thresholds
+--   error                                 - set when the audit provider failed; metrics are NULL
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS route_audits (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    primary_provider TEXT NOT NULL,
+    audit_provider TEXT NOT NULL,
+    profile TEXT NOT NULL,
+    preference TEXT NOT NULL,
+    origin GEOMETRY(Point, 4326) NOT NULL,
+    destination GEOMETRY(Point, 4326) NOT NULL,
+    primary_duration_s DOUBLE PRECISION NOT NULL,
+    primary_distance_m DOUBLE PRECISION NOT NULL,
+    audit_duration_s DOUBLE PRECISION,
+    audit_distance_m DOUBLE PRECISION,
+    duration_diff_pct DOUBLE PRECISION,
+    distance_diff_pct DOUBLE PRECISION,
+    geometry_divergence_m DOUBLE PRECISION,
+    flagged BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_route_audits_created_at ON route_audits (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_route_audits_flagged ON route_audits (created_at DESC) WHERE flagged;
+
+-- +migrate Down
+DROP TABLE IF EXISTS route_audits;
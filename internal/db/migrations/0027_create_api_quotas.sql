@@ -0,0 +1,32 @@
+-- Per-user API quota tiers and daily usage counters for expensive endpoints.
+--   users.quota_tier  - free (default), power or partner; changed via PUT /admin/users/{id}/quota
+--   api_quota_usage   - one row per subject, quota class and UTC day. subject is
+--                       "user:<uuid>" or "ip:<address>" for anonymous /route calls.
+-- Tier limits live in the API (rest/quota.go). Safe to run repeatedly.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_tier TEXT NOT NULL DEFAULT 'free';
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'users_quota_tier_check'
+  ) THEN
+    ALTER TABLE users
+      ADD CONSTRAINT users_quota_tier_check
+      CHECK (quota_tier IN ('free', 'power', 'partner'));
+  END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS api_quota_usage (
+    subject TEXT NOT NULL,
+    quota_class TEXT NOT NULL,
+    day DATE NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (subject, quota_class, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_quota_usage_day ON api_quota_usage (day);
+
+-- +migrate Down
+DROP TABLE IF EXISTS api_quota_usage;
+ALTER TABLE users DROP COLUMN IF EXISTS quota_tier;
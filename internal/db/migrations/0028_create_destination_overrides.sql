@@ -0,0 +1,25 @@
+-- Curated arrival points for destinations whose geocoded point is a poor place to route
+-- to (e.g. a hospital's emergency entrance instead of its centroid). A route whose
+-- destination matches place_id, or lies within radius_m of location, ends at
+-- arrival_point instead. Editable via /admin/destination-overrides.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS destination_overrides (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    place_id TEXT,                             -- Stadia gid or Google place id, optional
+    location GEOMETRY(Point, 4326) NOT NULL,   -- the destination as geocoded
+    radius_m INT NOT NULL DEFAULT 150 CHECK (radius_m BETWEEN 10 AND 2000),
+    arrival_point GEOMETRY(Point, 4326) NOT NULL,
+    arrival_kind TEXT NOT NULL DEFAULT 'entrance' CHECK (arrival_kind IN ('entrance', 'parking', 'drop_off')),
+    notes TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_destination_overrides_place_id ON destination_overrides (place_id) WHERE place_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_destination_overrides_location ON destination_overrides USING GIST (location);
+
+-- +migrate Down
+DROP TABLE IF EXISTS destination_overrides;
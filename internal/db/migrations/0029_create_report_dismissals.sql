@@ -0,0 +1,24 @@
+-- Per-user report dismissals and snoozes, synced across the user's devices.
+--   reports.material_version - bumped by UpdateReportRepo when the type, subtype, severity,
+--                              position (> 50 m), active or resolved state changes
+--   report_dismissals        - a dismissal (snoozed_until NULL) or snooze only applies while
+--                              report_version still matches reports.material_version, so it
+--                              lapses by itself once the report changes materially
+-- Safe to run repeatedly.
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS material_version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS report_dismissals (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    report_version INT NOT NULL,
+    snoozed_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, report_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_dismissals_report ON report_dismissals (report_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_dismissals;
+ALTER TABLE reports DROP COLUMN IF EXISTS material_version;
@@ -0,0 +1,17 @@
+-- Auto-resolution of TRAFFIC/ACCIDENT reports once location pings show speeds back to normal.
+--   reopen_detected_at - first check that saw normal speeds; cleared if traffic slows again.
+--                        The report is resolved once speeds stay normal for the grace period.
+--   auto_resolved_at   - when the resolver resolved the report (NULL for user resolutions)
+-- Safe to run repeatedly.
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS reopen_detected_at TIMESTAMPTZ;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS auto_resolved_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_reports_auto_resolve_candidates
+    ON reports (type, expires_at)
+    WHERE active = true AND resolved IS NOT TRUE;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_reports_auto_resolve_candidates;
+ALTER TABLE reports DROP COLUMN IF EXISTS reopen_detected_at;
+ALTER TABLE reports DROP COLUMN IF EXISTS auto_resolved_at;
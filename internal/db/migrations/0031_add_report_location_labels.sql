@@ -0,0 +1,9 @@
+-- Human-readable location labels for reports ("Dereboyu Cd., Lefkoşa"), reverse geocoded
+-- once per supported language right after the report is created:
+--   {"en": "Dereboyu St., Nicosia", "tr": "Dereboyu Cd., Lefkoşa"}
+-- NULL until the lookup finishes (or if it failed). Safe to run repeatedly.
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS location_labels JSONB;
+
+-- +migrate Down
+ALTER TABLE reports DROP COLUMN IF EXISTS location_labels;
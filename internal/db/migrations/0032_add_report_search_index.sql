@@ -0,0 +1,8 @@
+-- Index for GET /reports/search, which pages through reports by (created_at, id)
+-- whatever the type filter.
+-- Safe to run repeatedly.
+
+CREATE INDEX IF NOT EXISTS idx_reports_created_at_id ON reports (created_at, id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_reports_created_at_id;
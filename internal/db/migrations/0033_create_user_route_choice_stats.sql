@@ -0,0 +1,18 @@
+-- Per-user route preferences learned from which /route alternative the user navigates.
+-- Each column is an exponential moving average over the user's choices:
+--   highway_avoidance      -1 (picks the most motorway) .. 1 (picks the least)
+--   simplicity_preference  -1 (picks the most maneuvers) .. 1 (picks the fewest)
+--   detour_tolerance       extra travel time accepted over the fastest route, as a share
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS user_route_choice_stats (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    choices INT NOT NULL DEFAULT 0,
+    highway_avoidance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    simplicity_preference DOUBLE PRECISION NOT NULL DEFAULT 0,
+    detour_tolerance DOUBLE PRECISION NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS user_route_choice_stats;
@@ -0,0 +1,40 @@
+-- Calendar feeds (ICS URLs) users connect under /user/integrations/calendar, and the
+-- planned drive suggestions the sync worker derives from events with a location.
+-- planned_drives.depart_at is arrive_by minus the routed duration and a buffer; the
+-- origin is the integration's origin, else the user's latest location ping.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS calendar_integrations (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    feed_url TEXT NOT NULL,
+    origin GEOMETRY(Point, 4326),
+    last_synced_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendar_integrations_last_synced ON calendar_integrations (last_synced_at NULLS FIRST);
+
+CREATE TABLE IF NOT EXISTS planned_drives (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    title TEXT,
+    destination_name TEXT NOT NULL,
+    destination GEOMETRY(Point, 4326) NOT NULL,
+    arrive_by TIMESTAMPTZ NOT NULL,
+    depart_at TIMESTAMPTZ NOT NULL,
+    duration_s DOUBLE PRECISION NOT NULL,
+    status TEXT NOT NULL DEFAULT 'suggested' CHECK (status IN ('suggested', 'dismissed')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, source, external_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_planned_drives_user_arrive ON planned_drives (user_id, arrive_by);
+
+-- +migrate Down
+DROP TABLE IF EXISTS planned_drives;
+DROP TABLE IF EXISTS calendar_integrations;
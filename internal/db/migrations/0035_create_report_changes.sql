@@ -0,0 +1,50 @@
+-- History of report severity, status and resolution changes for the incident timeline.
+--   report_changes - one row per changed field, written by a trigger so every path that
+--                    updates reports (owner edits, auto-resolution) is covered.
+--                    field is severity, report_status or resolved; automatic is true when
+--                    the auto-resolver made the change.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_changes (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    field TEXT NOT NULL CHECK (field IN ('severity', 'report_status', 'resolved')),
+    old_value TEXT,
+    new_value TEXT,
+    automatic BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_changes_report ON report_changes (report_id, created_at);
+
+CREATE OR REPLACE FUNCTION record_report_changes()
+RETURNS TRIGGER AS $$
+DECLARE
+  auto BOOLEAN := NEW.auto_resolved_at IS DISTINCT FROM OLD.auto_resolved_at;
+BEGIN
+  IF NEW.severity IS DISTINCT FROM OLD.severity THEN
+    INSERT INTO report_changes (report_id, field, old_value, new_value, automatic)
+    VALUES (NEW.id, 'severity', OLD.severity::text, NEW.severity::text, auto);
+  END IF;
+  IF NEW.report_status IS DISTINCT FROM OLD.report_status THEN
+    INSERT INTO report_changes (report_id, field, old_value, new_value, automatic)
+    VALUES (NEW.id, 'report_status', OLD.report_status, NEW.report_status, auto);
+  END IF;
+  IF NEW.resolved IS DISTINCT FROM OLD.resolved THEN
+    INSERT INTO report_changes (report_id, field, old_value, new_value, automatic)
+    VALUES (NEW.id, 'resolved', OLD.resolved::text, NEW.resolved::text, auto);
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trigger_record_report_changes ON reports;
+CREATE TRIGGER trigger_record_report_changes
+AFTER UPDATE ON reports
+FOR EACH ROW
+EXECUTE FUNCTION record_report_changes();
+
+-- +migrate Down
+DROP TRIGGER IF EXISTS trigger_record_report_changes ON reports;
+DROP FUNCTION IF EXISTS record_report_changes();
+DROP TABLE IF EXISTS report_changes;
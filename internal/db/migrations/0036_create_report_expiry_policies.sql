@@ -0,0 +1,22 @@
+-- Default lifetime of new reports by type and subtype.
+-- The row matching both report_type and subtype wins, then the type with subtype '*',
+-- then the ('*', '*') fallback. Editable via /admin/expiry-policies.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_expiry_policies (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    report_type TEXT NOT NULL, -- TRAFFIC, POLICE, ACCIDENT, HAZARD, ROAD_CLOSED, PHOTOSHARING or '*'
+    subtype TEXT NOT NULL DEFAULT '*', -- report subtype or '*' for any
+    ttl_minutes INT NOT NULL CHECK (ttl_minutes > 0),
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT report_expiry_policies_type_subtype_unique UNIQUE (report_type, subtype)
+);
+
+INSERT INTO report_expiry_policies (report_type, subtype, ttl_minutes) VALUES
+    ('*', '*', 360)
+ON CONFLICT (report_type, subtype) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_expiry_policies;
@@ -0,0 +1,11 @@
+-- System "anonymous" user that takes over community-valuable reports (and votes) when
+-- their author deletes their account; see PurgeUserRepo. It has no credentials and
+-- can't sign in. The id must match anonymousUserID in rest/user_repo.go.
+-- Safe to run repeatedly.
+
+INSERT INTO users (id, email, auth_provider, is_verified)
+VALUES ('00000000-0000-0000-0000-000000000001', 'anonymous@users.invalid', 'system', false)
+ON CONFLICT (id) DO NOTHING;
+
+-- +migrate Down
+-- Nothing to undo: the anonymous user owns the reports and votes of deleted accounts.
@@ -0,0 +1,28 @@
+-- Errors in the base map reported by users (missing road, wrong one-way, bad speed
+-- limit), kept apart from reports, which are live hazards. Admins triage them via
+-- /admin/map-issues and export accepted ones as OpenStreetMap notes.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS map_issues (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    category TEXT NOT NULL CHECK (category IN (
+        'missing_road', 'wrong_one_way', 'speed_limit', 'turn_restriction', 'road_name', 'road_closed', 'other'
+    )),
+    location GEOMETRY(Point, 4326) NOT NULL,
+    description TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'accepted', 'rejected', 'fixed', 'exported')),
+    admin_note TEXT,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    exported_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_map_issues_status_created_at ON map_issues (status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_map_issues_user_id ON map_issues (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_map_issues_location ON map_issues USING GIST (location);
+
+-- +migrate Down
+DROP TABLE IF EXISTS map_issues;
@@ -0,0 +1,29 @@
+-- Names of places that go by different names per language or community (Nicosia /
+-- Lefkoşa / Λευκωσία). Geocoding responses swap any of the names for the one in the
+-- caller's language and list all of them. Editable via /admin/place-aliases.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS place_name_aliases (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    key TEXT NOT NULL UNIQUE,                -- admin-facing slug, e.g. 'nicosia'
+    names JSONB NOT NULL,                    -- language code -> name, e.g. {"en": "Nicosia", "tr": "Lefkoşa"}
+    gids TEXT[] NOT NULL DEFAULT '{}',       -- provider ids (Stadia gid, google:place:...) that are this place
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO place_name_aliases (key, names) VALUES
+    ('nicosia', '{"en": "Nicosia", "tr": "Lefkoşa", "el": "Λευκωσία"}'),
+    ('kyrenia', '{"en": "Kyrenia", "tr": "Girne", "el": "Κερύνεια"}'),
+    ('famagusta', '{"en": "Famagusta", "tr": "Gazimağusa", "el": "Αμμόχωστος"}'),
+    ('morphou', '{"en": "Morphou", "tr": "Güzelyurt", "el": "Μόρφου"}'),
+    ('trikomo', '{"en": "Trikomo", "tr": "İskele", "el": "Τρίκωμο"}'),
+    ('lefka', '{"en": "Lefka", "tr": "Lefke", "el": "Λεύκα"}'),
+    ('limassol', '{"en": "Limassol", "tr": "Limasol", "el": "Λεμεσός"}'),
+    ('larnaca', '{"en": "Larnaca", "tr": "Larnaka", "el": "Λάρνακα"}'),
+    ('paphos', '{"en": "Paphos", "tr": "Baf", "el": "Πάφος"}')
+ON CONFLICT (key) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS place_name_aliases;
@@ -0,0 +1,22 @@
+-- Local points of interest (fuel stations, pharmacies, restaurants, ...) used for "stops
+-- along route" lookups without per-place provider calls. Loaded via /admin/pois, keyed by
+-- the source they were imported from.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS pois (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    source TEXT NOT NULL,                    -- e.g. 'osm', 'google'
+    source_id TEXT NOT NULL,                 -- id within the source, e.g. 'node/123'
+    name TEXT NOT NULL,
+    category TEXT NOT NULL CHECK (category IN ('fuel', 'ev_charging', 'pharmacy', 'restaurant', 'cafe', 'atm', 'parking')),
+    location GEOMETRY(Point, 4326) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (source, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pois_location_geog ON pois USING GIST ((location::geography));
+CREATE INDEX IF NOT EXISTS idx_pois_category ON pois (category);
+
+-- +migrate Down
+DROP TABLE IF EXISTS pois;
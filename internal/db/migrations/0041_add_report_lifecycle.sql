@@ -0,0 +1,36 @@
+-- Report lifecycle: "still there?" confirmations and automatic expiry.
+--   report_confirmations - one answer per user and report; answering again replaces it.
+--   confirmations_count  - "still there" answers; each pushes expires_at out (bounded by
+--                          the report's max lifetime).
+--   not_there_count      - "not there" answers; together with downvotes they expire the
+--                          report early once they clearly outweigh the confirmations.
+--   last_confirmed_at    - most recent "still there" answer.
+--   expired_at           - when the lifecycle worker deactivated the report, and why.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_confirmations (
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    still_there BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (report_id, user_id)
+);
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS confirmations_count INT NOT NULL DEFAULT 0;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS not_there_count INT NOT NULL DEFAULT 0;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS last_confirmed_at TIMESTAMPTZ;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS expired_at TIMESTAMPTZ;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS expiry_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_reports_active_expires_at
+    ON reports (expires_at)
+    WHERE active = true;
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_confirmations;
+DROP INDEX IF EXISTS idx_reports_active_expires_at;
+ALTER TABLE reports DROP COLUMN IF EXISTS confirmations_count;
+ALTER TABLE reports DROP COLUMN IF EXISTS not_there_count;
+ALTER TABLE reports DROP COLUMN IF EXISTS last_confirmed_at;
+ALTER TABLE reports DROP COLUMN IF EXISTS expired_at;
+ALTER TABLE reports DROP COLUMN IF EXISTS expiry_reason;
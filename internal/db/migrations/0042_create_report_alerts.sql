@@ -0,0 +1,27 @@
+-- Push alerts for new high-severity reports near a user.
+--   user_notification_preferences - per-user alert settings; users without a row get the
+--                                   defaults (alerts on, 2 km, severity 4+, every type).
+--                                   report_types empty means every type.
+--   report_alert_deliveries       - who was alerted about which report, so outbox retries
+--                                   never alert the same user twice.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS user_notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    report_alerts BOOLEAN NOT NULL DEFAULT true,
+    alert_radius_m INT NOT NULL DEFAULT 2000 CHECK (alert_radius_m BETWEEN 200 AND 20000),
+    min_severity INT NOT NULL DEFAULT 4 CHECK (min_severity BETWEEN 3 AND 5),
+    report_types TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS report_alert_deliveries (
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (report_id, user_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_alert_deliveries;
+DROP TABLE IF EXISTS user_notification_preferences;
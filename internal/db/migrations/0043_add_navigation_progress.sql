@@ -0,0 +1,30 @@
+-- Server-side progress tracking for navigation sessions.
+--   profile, preference - how the route was planned, so automatic reroutes ask the same
+--                         provider for the same kind of route.
+--   last_position       - latest fix from POST /navigation/sessions/{id}/position (snapped
+--                         to the road when it looked off route).
+--   progress_m          - distance along route_geom of the last on-route fix.
+--   off_route_count     - consecutive fixes beyond the off-route threshold; reset on the
+--                         next on-route fix or reroute.
+--   reroute_count       - manual and automatic reroutes; last_rerouted_at rate-limits the
+--                         automatic ones.
+-- Safe to run repeatedly.
+
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS profile TEXT NOT NULL DEFAULT 'driving';
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS preference TEXT NOT NULL DEFAULT 'fastest';
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS last_position GEOMETRY(Point, 4326);
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS last_position_at TIMESTAMPTZ;
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS progress_m DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS off_route_count INT NOT NULL DEFAULT 0;
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS reroute_count INT NOT NULL DEFAULT 0;
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS last_rerouted_at TIMESTAMPTZ;
+
+-- +migrate Down
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS profile;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS preference;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS last_position;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS last_position_at;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS progress_m;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS off_route_count;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS reroute_count;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS last_rerouted_at;
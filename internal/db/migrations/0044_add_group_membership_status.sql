@@ -0,0 +1,40 @@
+-- Join requests for private groups:
+--   active  - a member (default; every existing membership)
+--   pending - asked to join a private group and waiting for an admin to approve
+-- community_groups.member_count counts active memberships and is kept up to date by the
+-- API; the backfill below fixes counts written before that.
+-- Safe to run repeatedly.
+
+ALTER TABLE group_memberships
+  ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'group_memberships_status_check'
+  ) THEN
+    ALTER TABLE group_memberships
+      ADD CONSTRAINT group_memberships_status_check
+      CHECK (status IN ('active', 'pending'));
+  END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_group_memberships_pending
+  ON group_memberships (group_id, joined_at)
+  WHERE status = 'pending';
+
+UPDATE community_groups cg
+SET member_count = (
+  SELECT COUNT(*) FROM group_memberships gm
+  WHERE gm.group_id = cg.id AND gm.status = 'active'
+)
+WHERE cg.member_count IS DISTINCT FROM (
+  SELECT COUNT(*) FROM group_memberships gm
+  WHERE gm.group_id = cg.id AND gm.status = 'active'
+);
+
+-- +migrate Down
+-- Pending join requests would otherwise turn into memberships.
+DELETE FROM group_memberships WHERE status = 'pending';
+DROP INDEX IF EXISTS idx_group_memberships_pending;
+ALTER TABLE group_memberships DROP COLUMN IF EXISTS status;
@@ -0,0 +1,37 @@
+-- Reporter reputation.
+--   users.reputation_score - running total of the user's reputation_events; reports from
+--                            users at or above the trusted threshold skip PENDING
+--   reputation_events      - one row per award or penalty; dedup_key (reason, report and,
+--                            for votes and confirmations, the acting user) makes each
+--                            award count once however often the trigger repeats
+-- Safe to run repeatedly.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reputation_score INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reputation_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id BIGINT REFERENCES reports(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    points INT NOT NULL,
+    dedup_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint WHERE conname = 'reputation_events_reason_check'
+  ) THEN
+    ALTER TABLE reputation_events
+      ADD CONSTRAINT reputation_events_reason_check
+      CHECK (reason IN ('report_confirmed', 'report_upvoted', 'report_resolved', 'report_disputed'));
+  END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_reputation_events_user
+    ON reputation_events (user_id, created_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS reputation_events;
+ALTER TABLE users DROP COLUMN IF EXISTS reputation_score;
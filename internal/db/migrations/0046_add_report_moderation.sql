@@ -0,0 +1,38 @@
+-- Moderator review of reports (/admin/reports).
+--   reports.report_status      - PENDING -> VERIFIED (approved), REJECTED, or MERGED into a
+--                                duplicate; RESOLVED is unchanged
+--   reports.merged_into        - the report a MERGED duplicate was folded into
+--   report_moderation_actions  - audit trail: who moved which report from which status to
+--                                which, and why
+-- Safe to run repeatedly.
+
+ALTER TABLE reports DROP CONSTRAINT IF EXISTS reports_report_status_check;
+ALTER TABLE reports ADD CONSTRAINT reports_report_status_check
+  CHECK (report_status IN ('PENDING', 'VERIFIED', 'RESOLVED', 'REJECTED', 'MERGED'));
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS merged_into BIGINT REFERENCES reports(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS report_moderation_actions (
+    id BIGSERIAL PRIMARY KEY,
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL CHECK (action IN ('approve', 'reject', 'merge')),
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    merged_into BIGINT REFERENCES reports(id) ON DELETE SET NULL,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_moderation_actions_report
+    ON report_moderation_actions (report_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_reports_pending
+    ON reports (created_at)
+    WHERE report_status = 'PENDING' AND active = true;
+
+-- +migrate Down
+-- The report_status CHECK keeps REJECTED and MERGED so moderated reports stay valid.
+DROP TABLE IF EXISTS report_moderation_actions;
+DROP INDEX IF EXISTS idx_reports_pending;
+ALTER TABLE reports DROP COLUMN IF EXISTS merged_into;
@@ -0,0 +1,27 @@
+-- Crowd-sourced traffic: speeds from map-matched GPS samples (POST /traffic/samples),
+-- aggregated per road segment and direction. Only aggregates are kept, never the traces.
+--   segment_key  - road name, geohash (precision 7, ~150 m) of the segment midpoint and
+--                  8-way direction bucket, e.g. "Ataturk Cd|swm2qk4|2"
+--   geom         - the matched line the segment was first observed on
+--   speed_ms     - moving average of recent observations; restarts after a quiet spell
+--   free_flow_ms - slowly decaying maximum, the speed the segment is driven at when clear
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS traffic_segments (
+    segment_key TEXT PRIMARY KEY,
+    road_name TEXT NOT NULL DEFAULT '',
+    bearing_bucket SMALLINT NOT NULL CHECK (bearing_bucket BETWEEN 0 AND 7),
+    geom GEOMETRY(LineString, 4326) NOT NULL,
+    speed_ms DOUBLE PRECISION NOT NULL,
+    free_flow_ms DOUBLE PRECISION NOT NULL,
+    sample_count INT NOT NULL DEFAULT 0,
+    recent_samples INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_traffic_segments_geom ON traffic_segments USING GIST (geom);
+CREATE INDEX IF NOT EXISTS idx_traffic_segments_updated_at ON traffic_segments (updated_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS traffic_segments;
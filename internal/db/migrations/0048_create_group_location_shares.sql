@@ -0,0 +1,27 @@
+-- Live location sharing in community groups: members who opt in during a drive have
+-- their last known position and ETA kept here, so members opening the group map see
+-- current state without waiting for the next WebSocket update.
+--   session_id - the navigation session the ETA comes from, if any
+--   expires_at - sharing stops on its own after this, even if the app never says so
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS group_location_shares (
+    group_id UUID NOT NULL REFERENCES community_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID REFERENCES navigation_sessions(id) ON DELETE SET NULL,
+    position GEOMETRY(Point, 4326),
+    heading DOUBLE PRECISION,  -- degrees clockwise from north
+    speed DOUBLE PRECISION,    -- m/s as reported by the device
+    eta TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    position_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_location_shares_expires ON group_location_shares(expires_at);
+CREATE INDEX IF NOT EXISTS idx_group_location_shares_user ON group_location_shares(user_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS group_location_shares;
@@ -0,0 +1,32 @@
+-- Trip history: one row per navigation session that was actually driven, written in the
+-- same statement that finishes the session (POST /navigation/sessions/{id}/finish).
+--   navigation_sessions.driven_m - distance between consecutive position fixes, so a
+--                                  trip's length survives reroutes, unlike progress_m
+--   route_geom                   - the last route the session followed
+--   status                       - finished (arrived) or cancelled
+-- Safe to run repeatedly.
+
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS driven_m DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS trips (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID UNIQUE REFERENCES navigation_sessions(id) ON DELETE SET NULL,
+    status TEXT NOT NULL CHECK (status IN ('finished', 'cancelled')),
+    distance_m DOUBLE PRECISION NOT NULL,
+    duration_s INT NOT NULL,
+    avg_speed_ms DOUBLE PRECISION NOT NULL,
+    route_geom GEOMETRY(LineString, 4326) NOT NULL,
+    destination GEOMETRY(Point, 4326),
+    destination_name TEXT,
+    profile TEXT NOT NULL DEFAULT 'driving',
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_trips_user_started ON trips(user_id, started_at DESC);
+
+-- +migrate Down
+DROP TABLE IF EXISTS trips;
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS driven_m;
@@ -0,0 +1,25 @@
+-- Permanent road features drivers are warned about, unlike reports which expire:
+-- speed cameras, speed bumps and railway crossings, curated by admins.
+--   bearing         - direction of travel the feature applies to, in degrees clockwise
+--                     from north; NULL applies to both directions
+--   speed_limit_kmh - enforced limit, for speed cameras
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS map_features (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    type TEXT NOT NULL CHECK (type IN ('speed_camera', 'speed_bump', 'railway_crossing')),
+    position GEOMETRY(Point, 4326) NOT NULL,
+    bearing DOUBLE PRECISION CHECK (bearing >= 0 AND bearing < 360),
+    speed_limit_kmh INT CHECK (speed_limit_kmh > 0),
+    name TEXT,
+    notes TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_map_features_position ON map_features USING GIST (position) WHERE active;
+
+-- +migrate Down
+DROP TABLE IF EXISTS map_features;
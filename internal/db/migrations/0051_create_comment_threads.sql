@@ -0,0 +1,22 @@
+-- Threaded, editable report comments.
+--   parent_comment_id - the comment this one replies to, on the same report
+--   edited_at         - last time the author changed the content
+--   deleted_at/by     - soft deletion by the author or a moderator; the row stays so
+--                       replies keep their place in the thread
+--   reports.comments_count only counts comments that aren't deleted
+-- Safe to run repeatedly.
+
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_comment_id UUID REFERENCES comments(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_parent ON comments (parent_comment_id) WHERE parent_comment_id IS NOT NULL;
+
+-- +migrate Down
+-- Replies become top-level comments; deleted comments are removed rather than shown again.
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_comment_id;
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+ALTER TABLE comments DROP COLUMN IF EXISTS edited_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_by;
@@ -0,0 +1,33 @@
+-- One vote per user per report; a later vote switches it and DELETE retracts it.
+--   Duplicate votes from before the constraint are collapsed into each user's latest
+--   one and the report counters rebuilt from what is left.
+--   The anonymous user (see 0037_create_anonymous_user.sql) is exempt: it inherits the votes
+--   of deleted accounts, several of which may have voted on the same report.
+-- Safe to run repeatedly.
+
+DELETE FROM votes v
+USING votes newer
+WHERE newer.report_id = v.report_id
+  AND newer.user_id = v.user_id
+  AND v.user_id <> '00000000-0000-0000-0000-000000000001'
+  AND (newer.created_at, newer.id) > (v.created_at, v.id);
+
+UPDATE reports r SET
+    upvotes_count = counts.upvotes,
+    downvotes_count = counts.downvotes
+FROM (
+    SELECT r2.id,
+        (SELECT COUNT(*) FROM votes v WHERE v.report_id = r2.id AND v.vote_type = 'UPVOTE')::int AS upvotes,
+        (SELECT COUNT(*) FROM votes v WHERE v.report_id = r2.id AND v.vote_type = 'DOWNVOTE')::int AS downvotes
+    FROM reports r2
+) counts
+WHERE r.id = counts.id
+  AND (r.upvotes_count IS DISTINCT FROM counts.upvotes
+    OR r.downvotes_count IS DISTINCT FROM counts.downvotes);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_report_user
+    ON votes (report_id, user_id)
+    WHERE user_id <> '00000000-0000-0000-0000-000000000001';
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_votes_report_user;
@@ -0,0 +1,32 @@
+-- Report images uploaded through POST /uploads or with a multipart report, tracked so
+-- they can be deleted from Cloudinary once nothing shows them any more.
+--   report_id       - set when a report is created with the image; NULL until then
+--   public_id       - Cloudinary public ID, used to delete the image
+--   decision/...    - the upload's moderation verdict, logged to report_image_moderations
+--                     against the report once the image is attached to one
+--   deleted_at      - set by the cleaner after the image was removed from Cloudinary
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS report_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    report_id BIGINT REFERENCES reports(id) ON DELETE SET NULL,
+    public_id TEXT NOT NULL,
+    url TEXT NOT NULL UNIQUE,
+    content_type TEXT NOT NULL,
+    size_bytes INT NOT NULL,
+    width INT,
+    height INT,
+    provider TEXT NOT NULL,
+    decision TEXT NOT NULL CHECK (decision IN ('approved', 'held')),
+    reason TEXT,
+    scores JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_uploads_report ON report_uploads (report_id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_report_uploads_unattached ON report_uploads (created_at) WHERE report_id IS NULL AND deleted_at IS NULL;
+
+-- +migrate Down
+DROP TABLE IF EXISTS report_uploads;
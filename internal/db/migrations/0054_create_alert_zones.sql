@@ -0,0 +1,39 @@
+-- Geofences users watch for new reports ("watch my commute").
+--   user_alert_zones      - shape is what the user saved: a polygon they drew, or a route.
+--                           area is what reports are matched against: the polygon, or the
+--                           route buffered by corridor_width_m once when saved, so matching
+--                           a report is a single ST_Intersects. report_types empty means
+--                           every type.
+--   alert_zone_deliveries - who was alerted about which report through a zone, so outbox
+--                           retries and overlapping zones never alert a user twice.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS user_alert_zones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('polygon', 'corridor')),
+    shape GEOMETRY(Geometry, 4326) NOT NULL,
+    area GEOMETRY(Geometry, 4326) NOT NULL,
+    corridor_width_m INT CHECK (corridor_width_m BETWEEN 50 AND 1000),
+    report_types TEXT[] NOT NULL DEFAULT '{}',
+    min_severity INT NOT NULL DEFAULT 1 CHECK (min_severity BETWEEN 1 AND 5),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_alert_zones_area ON user_alert_zones USING GIST (area) WHERE active;
+CREATE INDEX IF NOT EXISTS idx_user_alert_zones_user ON user_alert_zones (user_id);
+
+CREATE TABLE IF NOT EXISTS alert_zone_deliveries (
+    report_id BIGINT NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    zone_id UUID REFERENCES user_alert_zones(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (report_id, user_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS alert_zone_deliveries;
+DROP TABLE IF EXISTS user_alert_zones;
@@ -0,0 +1,14 @@
+-- Outgoing requests to map and ML providers, per upstream host and UTC day, for
+-- GET /admin/analytics/external-usage. Each API instance counts requests in memory and
+-- adds them here about once a minute. Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS external_api_usage (
+    day DATE NOT NULL,
+    host TEXT NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, host)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS external_api_usage;
@@ -0,0 +1,15 @@
+-- Two-phase account deletion (DELETE /user/account).
+--   users.deactivated_at - when the user asked for their account to be deleted. Logging
+--                          in again within the grace period (30 days) clears it; after
+--                          that the account purger anonymizes their reports and comments
+--                          and deletes the account.
+-- Safe to run repeatedly.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_deactivated_at
+    ON users (deactivated_at)
+    WHERE deactivated_at IS NOT NULL;
+
+-- +migrate Down
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_at;
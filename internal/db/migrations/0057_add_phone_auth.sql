@@ -0,0 +1,32 @@
+-- Phone number login (POST /auth/phone/request, /auth/phone/verify).
+--   users.phone          - E.164 number (+35799123456) the user logs in with. Accounts
+--                          created this way have no email, so email becomes optional.
+--   phone_verifications  - one-time codes sent by SMS. attempts counts wrong guesses
+--                          so a code stops working after a few; the newest unexpired
+--                          code for a number is the only one accepted.
+-- Safe to run repeatedly.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone TEXT;
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone
+    ON users (phone)
+    WHERE phone IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    id BIGSERIAL PRIMARY KEY,
+    phone TEXT NOT NULL,
+    code VARCHAR(6) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_phone
+    ON phone_verifications (phone, created_at DESC);
+
+-- +migrate Down
+-- Fails while accounts without an email exist; delete or give them one first.
+DROP TABLE IF EXISTS phone_verifications;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
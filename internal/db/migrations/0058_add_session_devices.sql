@@ -0,0 +1,27 @@
+-- Sessions (GET/DELETE /user/sessions): each refresh token is a signed-in device.
+--   device_id, device_model, platform - from the X-Device-* headers at login
+--   ip_address, user_agent            - where the login came from
+--   last_used_at                      - last refresh with the token
+-- A rotated refresh token inherits these and the original created_at, so a session
+-- keeps its login time across rotations.
+-- Safe to run repeatedly.
+
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS device_id TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS device_model TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS platform TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS ip_address TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_active_sessions
+    ON auth_tokens (user_id, created_at DESC)
+    WHERE token_type = 'refresh' AND is_revoked = FALSE;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_auth_tokens_active_sessions;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS platform;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS device_model;
+ALTER TABLE auth_tokens DROP COLUMN IF EXISTS device_id;
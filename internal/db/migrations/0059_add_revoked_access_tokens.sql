@@ -0,0 +1,18 @@
+-- Access token denylist (POST /auth/logout). Access tokens are checked by signature
+-- alone, so a signed-out token is recorded here by its jti claim until it would have
+-- expired anyway; RequireLogin rejects any token listed. Expired rows are dropped on
+-- the next logout.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at
+    ON revoked_access_tokens (expires_at);
+
+-- +migrate Down
+DROP TABLE IF EXISTS revoked_access_tokens;
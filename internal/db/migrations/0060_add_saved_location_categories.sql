@@ -0,0 +1,52 @@
+-- Saved location categories and ordering for the favorites list. A user has at most one
+-- home and one work; everything else is a favorite, or custom with its own icon or
+-- emoji. sort_order is the user's explicit order (1 first); existing rows are numbered
+-- by creation time and places named Home or Work take those categories.
+-- Safe to run repeatedly.
+
+ALTER TABLE saved_locations ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT 'favorite';
+ALTER TABLE saved_locations ADD COLUMN IF NOT EXISTS icon TEXT;
+ALTER TABLE saved_locations ADD COLUMN IF NOT EXISTS sort_order INT NOT NULL DEFAULT 0;
+
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'saved_locations_category_check') THEN
+    ALTER TABLE saved_locations ADD CONSTRAINT saved_locations_category_check
+      CHECK (category IN ('home', 'work', 'favorite', 'custom'));
+  END IF;
+END $$;
+
+UPDATE saved_locations s
+SET sort_order = o.pos
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at, id) AS pos
+    FROM saved_locations
+) o
+WHERE s.id = o.id AND s.sort_order = 0;
+
+UPDATE saved_locations s
+SET category = LOWER(s.name)
+WHERE s.id IN (
+    SELECT DISTINCT ON (user_id, LOWER(name)) id
+    FROM saved_locations
+    WHERE LOWER(name) IN ('home', 'work')
+    ORDER BY user_id, LOWER(name), id
+)
+AND NOT EXISTS (
+    SELECT 1 FROM saved_locations o
+    WHERE o.user_id = s.user_id AND o.category = LOWER(s.name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_locations_user_home_work
+    ON saved_locations (user_id, category) WHERE category IN ('home', 'work');
+
+CREATE INDEX IF NOT EXISTS idx_saved_locations_user_sort_order
+    ON saved_locations (user_id, sort_order);
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_saved_locations_user_sort_order;
+DROP INDEX IF EXISTS idx_saved_locations_user_home_work;
+ALTER TABLE saved_locations DROP CONSTRAINT IF EXISTS saved_locations_category_check;
+ALTER TABLE saved_locations DROP COLUMN IF EXISTS sort_order;
+ALTER TABLE saved_locations DROP COLUMN IF EXISTS icon;
+ALTER TABLE saved_locations DROP COLUMN IF EXISTS category;
@@ -0,0 +1,27 @@
+-- Recent destinations: where the user started navigating to, newest visit first. A
+-- destination within 100 m of an earlier one counts as another visit to it rather than
+-- a new row, so GET /user/recent-destinations needs no dedupe of its own.
+--   users.recent_destinations_enabled - off stops collection; turning it off also clears
+--                                       the history
+-- Safe to run repeatedly.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS recent_destinations_enabled BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE TABLE IF NOT EXISTS recent_destinations (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT,
+    location GEOMETRY(Point, 4326) NOT NULL,
+    visit_count INT NOT NULL DEFAULT 1,
+    first_visited_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_visited_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recent_destinations_user_last_visited
+    ON recent_destinations (user_id, last_visited_at DESC);
+CREATE INDEX IF NOT EXISTS idx_recent_destinations_location
+    ON recent_destinations USING GIST (location);
+
+-- +migrate Down
+DROP TABLE IF EXISTS recent_destinations;
+ALTER TABLE users DROP COLUMN IF EXISTS recent_destinations_enabled;
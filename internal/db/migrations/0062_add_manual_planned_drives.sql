@@ -0,0 +1,34 @@
+-- Planned drives users schedule themselves under /planned-drives, next to the ones the
+-- calendar sync suggests.
+--   planned_drives.source       - 'manual' for scheduled drives; they have no external_id
+--   planned_drives.origin       - where the drive starts; NULL means the user's latest
+--                                 location ping
+--   planned_drives.status       - 'cancelled' for manual drives the user cancelled
+--   planned_drives.computed_at  - when the planned drive monitor last re-estimated the
+--                                 drive time and depart_at
+--   planned_drives.notified_at  - when the user was told to leave; set once
+-- Safe to run repeatedly.
+
+ALTER TABLE planned_drives ALTER COLUMN external_id DROP NOT NULL;
+ALTER TABLE planned_drives ADD COLUMN IF NOT EXISTS origin GEOMETRY(Point, 4326);
+ALTER TABLE planned_drives ADD COLUMN IF NOT EXISTS computed_at TIMESTAMPTZ;
+ALTER TABLE planned_drives ADD COLUMN IF NOT EXISTS notified_at TIMESTAMPTZ;
+
+ALTER TABLE planned_drives DROP CONSTRAINT IF EXISTS planned_drives_status_check;
+ALTER TABLE planned_drives ADD CONSTRAINT planned_drives_status_check
+  CHECK (status IN ('suggested', 'dismissed', 'cancelled'));
+
+CREATE INDEX IF NOT EXISTS idx_planned_drives_pending_departure
+    ON planned_drives (depart_at) WHERE status = 'suggested' AND notified_at IS NULL;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_planned_drives_pending_departure;
+DELETE FROM planned_drives WHERE source = 'manual';
+UPDATE planned_drives SET status = 'dismissed' WHERE status = 'cancelled';
+ALTER TABLE planned_drives DROP CONSTRAINT IF EXISTS planned_drives_status_check;
+ALTER TABLE planned_drives ADD CONSTRAINT planned_drives_status_check
+  CHECK (status IN ('suggested', 'dismissed'));
+ALTER TABLE planned_drives DROP COLUMN IF EXISTS notified_at;
+ALTER TABLE planned_drives DROP COLUMN IF EXISTS computed_at;
+ALTER TABLE planned_drives DROP COLUMN IF EXISTS origin;
+ALTER TABLE planned_drives ALTER COLUMN external_id SET NOT NULL;
@@ -0,0 +1,46 @@
+-- Carpooling in destination groups: members offer or ask for a ride to the group's
+-- destination within a departure window. A request matches an offer in the same group
+-- when the windows overlap, the offer has enough seats and the request's pickup is
+-- within a corridor around the driver's route.
+--   group_rides.route                     - the driver's route (offers only)
+--   group_ride_matches.pickup_distance_m  - from the driver's route to the pickup
+-- A match is stored once, so each side is told about it once.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS group_rides (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES community_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('offer', 'request')),
+    origin GEOMETRY(Point, 4326) NOT NULL,
+    origin_name TEXT,
+    destination GEOMETRY(Point, 4326) NOT NULL,
+    route GEOMETRY(LineString, 4326),
+    depart_from TIMESTAMPTZ NOT NULL,
+    depart_until TIMESTAMPTZ NOT NULL,
+    seats INT NOT NULL CHECK (seats BETWEEN 1 AND 8),
+    note TEXT,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (depart_until >= depart_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_rides_group_open
+    ON group_rides (group_id, kind, depart_from) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_group_rides_route ON group_rides USING GIST (route);
+CREATE INDEX IF NOT EXISTS idx_group_rides_user ON group_rides (user_id);
+
+CREATE TABLE IF NOT EXISTS group_ride_matches (
+    offer_id UUID NOT NULL REFERENCES group_rides(id) ON DELETE CASCADE,
+    request_id UUID NOT NULL REFERENCES group_rides(id) ON DELETE CASCADE,
+    pickup_distance_m DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (offer_id, request_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_ride_matches_request ON group_ride_matches (request_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS group_ride_matches;
+DROP TABLE IF EXISTS group_rides;
@@ -0,0 +1,23 @@
+-- ROAD_CLOSED reports can cover a stretch of road instead of a single point, and Valhalla
+-- routes steer around active closures.
+--   reports.closure_segment    - the closed stretch, snapped to the road
+--   reports.closure_direction  - 'BOTH', or 'ONE_WAY' when only traffic travelling in the
+--                                order the segment is drawn in is blocked
+-- Safe to run repeatedly.
+
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS closure_segment GEOMETRY(LineString, 4326);
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS closure_direction TEXT;
+
+ALTER TABLE reports DROP CONSTRAINT IF EXISTS reports_closure_direction_check;
+ALTER TABLE reports ADD CONSTRAINT reports_closure_direction_check
+  CHECK (closure_direction IN ('BOTH', 'ONE_WAY'));
+
+CREATE INDEX IF NOT EXISTS idx_reports_active_closures
+    ON reports USING GIST (COALESCE(closure_segment, position))
+    WHERE type = 'ROAD_CLOSED' AND active = true;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_reports_active_closures;
+ALTER TABLE reports DROP CONSTRAINT IF EXISTS reports_closure_direction_check;
+ALTER TABLE reports DROP COLUMN IF EXISTS closure_direction;
+ALTER TABLE reports DROP COLUMN IF EXISTS closure_segment;
@@ -0,0 +1,10 @@
+-- Automatic reroutes are sent to the app as a diff against the route it already has
+-- (see NavigationProgress.RouteDiff), so the session keeps the steps it planned.
+--   navigation_sessions.route_steps - DrivingStep list of a server-planned route; NULL
+--                                     when the app supplied the route
+-- Safe to run repeatedly.
+
+ALTER TABLE navigation_sessions ADD COLUMN IF NOT EXISTS route_steps JSONB;
+
+-- +migrate Down
+ALTER TABLE navigation_sessions DROP COLUMN IF EXISTS route_steps;
@@ -0,0 +1,27 @@
+-- Unified search (/search) matches saved locations, recent destinations and public
+-- community groups by name, tolerating typos and partially typed words.
+--   community_groups.search_vector - name, destination and description as 'simple'
+--                                    lexemes: names mix Turkish, Greek and English, so
+--                                    nothing is stemmed
+-- Saved locations and recent destinations are only searched within one user's rows, which
+-- the user_id indexes already narrow down; public groups are searched as a whole, so the
+-- trigram and full-text matches on them are indexed.
+-- Safe to run repeatedly.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE community_groups ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (to_tsvector('simple',
+        COALESCE(name, '') || ' ' || COALESCE(destination_name, '') || ' ' || COALESCE(description, ''))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_community_groups_search_vector
+    ON community_groups USING GIN (search_vector)
+    WHERE visibility = 'public' AND is_deleted = FALSE;
+CREATE INDEX IF NOT EXISTS idx_community_groups_name_trgm
+    ON community_groups USING GIN (name gin_trgm_ops)
+    WHERE visibility = 'public' AND is_deleted = FALSE;
+
+-- +migrate Down
+DROP INDEX IF EXISTS idx_community_groups_name_trgm;
+DROP INDEX IF EXISTS idx_community_groups_search_vector;
+ALTER TABLE community_groups DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,19 @@
+-- Street lighting along OSM ways, imported with `admin import-lit` from a GeoJSON export
+-- of ways that carry the lit tag. The "safe" routing preference uses it to rank vehicle
+-- routes by how much of them is lit, as Valhalla's vehicle costing has no lit option.
+--   lit_ways.lit - 'yes' or 'no'; OSM values such as 24/7 or automatic are stored as 'yes'
+-- Ways without a lit tag aren't imported: a route over them counts as unknown, not dark.
+-- Safe to run repeatedly.
+
+CREATE TABLE IF NOT EXISTS lit_ways (
+    osm_way_id BIGINT PRIMARY KEY,
+    lit TEXT NOT NULL CHECK (lit IN ('yes', 'no')),
+    geom geometry(LineString, 4326) NOT NULL,
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Route samples are matched to ways in meters, so the index is on the geography.
+CREATE INDEX IF NOT EXISTS idx_lit_ways_geog ON lit_ways USING GIST ((geom::geography));
+
+-- +migrate Down
+DROP TABLE IF EXISTS lit_ways;
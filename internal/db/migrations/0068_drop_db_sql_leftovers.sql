@@ -0,0 +1,73 @@
+-- Drops what the old hand-run db.sql created and 0001_baseline deliberately left out, so
+-- databases created from db.sql match those created by the migrations:
+--   token_blacklist         - superseded by revoked_access_tokens (0059)
+--   message_reactions       - never read or written by the API
+--   moderation_actions      - never read or written; report moderation has its own log
+--                             (report_moderation_actions, 0046)
+--   cleanup_expired_tokens  - and its hourly pg_cron job; expired auth_tokens and
+--                             revoked_access_tokens are now swept by the API
+--                             (RunAuthTokenSweeper), which needs no extension
+-- Safe to run repeatedly, with or without pg_cron installed.
+
+DO $$
+BEGIN
+    IF to_regclass('cron.job') IS NOT NULL THEN
+        PERFORM cron.unschedule(jobid) FROM cron.job WHERE command = 'SELECT cleanup_expired_tokens()';
+    END IF;
+END
+$$;
+
+DROP FUNCTION IF EXISTS cleanup_expired_tokens();
+DROP TABLE IF EXISTS token_blacklist;
+DROP TABLE IF EXISTS message_reactions;
+DROP TABLE IF EXISTS moderation_actions;
+
+-- +migrate Down
+CREATE TABLE IF NOT EXISTS token_blacklist (
+    token_hash TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_blacklist_expiry ON token_blacklist (expires_at);
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reaction_emoji TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (message_id, user_id, reaction_emoji)
+);
+CREATE INDEX IF NOT EXISTS idx_message_reactions_message_id ON message_reactions (message_id);
+CREATE INDEX IF NOT EXISTS idx_message_reactions_user_id ON message_reactions (user_id);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    group_id UUID NOT NULL REFERENCES community_groups(id) ON DELETE CASCADE,
+    moderator_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    target_message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    action_type TEXT NOT NULL CHECK (action_type IN ('delete_message', 'kick_user', 'ban_user', 'unban_user', 'promote_moderator', 'demote_moderator')),
+    reason TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_group_id ON moderation_actions (group_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_moderator_id ON moderation_actions (moderator_user_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target_user_id ON moderation_actions (target_user_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_actions_target_message_id ON moderation_actions (target_message_id);
+
+CREATE OR REPLACE FUNCTION cleanup_expired_tokens()
+RETURNS void AS $$
+BEGIN
+    DELETE FROM auth_tokens WHERE expires_at < NOW();
+    DELETE FROM token_blacklist WHERE expires_at < NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+    IF to_regclass('cron.job') IS NOT NULL THEN
+        PERFORM cron.schedule('0 * * * *', 'SELECT cleanup_expired_tokens()');
+    END IF;
+END
+$$;
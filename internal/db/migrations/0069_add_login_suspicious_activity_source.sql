@@ -0,0 +1,13 @@
+-- Emulator devices are flagged when they log in (issueDeviceSecret), not from a location
+-- stream, so suspicious_activity_flags.source gains 'login'.
+-- Safe to run repeatedly.
+
+ALTER TABLE suspicious_activity_flags DROP CONSTRAINT IF EXISTS suspicious_activity_flags_source_check;
+ALTER TABLE suspicious_activity_flags ADD CONSTRAINT suspicious_activity_flags_source_check
+    CHECK (source IN ('location', 'report', 'login'));
+
+-- +migrate Down
+UPDATE suspicious_activity_flags SET source = 'location' WHERE source = 'login';
+ALTER TABLE suspicious_activity_flags DROP CONSTRAINT IF EXISTS suspicious_activity_flags_source_check;
+ALTER TABLE suspicious_activity_flags ADD CONSTRAINT suspicious_activity_flags_source_check
+    CHECK (source IN ('location', 'report'));
@@ -0,0 +1,114 @@
+// Package migrations embeds the SQL migrations and applies them in version order.
+//
+// Each file is named NNNN_description.sql; the name without .sql is its version. The
+// statements up to a "-- +migrate Down" line migrate up and are idempotent, so databases
+// set up by hand before migrations were tracked can be brought under Up safely. The
+// statements after it undo the migration; a file without one can't be rolled back.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed *.sql
+var Files embed.FS
+
+// Object is a table, index or column a migration creates.
+type Object struct {
+	Kind   string // "table", "index" or "column"
+	Table  string // table the column belongs to; empty otherwise
+	Name   string
+	Source string // migration file name
+}
+
+var (
+	createTableRe = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS\s+(\w+)`)
+	createIndexRe = regexp.MustCompile(`(?i)CREATE (?:UNIQUE )?INDEX IF NOT EXISTS\s+(\w+)`)
+	alterTableRe  = regexp.MustCompile(`(?i)ALTER TABLE(?: IF EXISTS)?\s+(\w+)`)
+	addColumnRe   = regexp.MustCompile(`(?i)ADD COLUMN(?: IF NOT EXISTS)?\s+(\w+)`)
+	sqlCommentRe  = regexp.MustCompile(`--[^\n]*`)
+
+	fileNameRe = regexp.MustCompile(`^(\d{4})_\w+\.sql$`)
+	downRe     = regexp.MustCompile(`(?m)^-- \+migrate Down[ \t]*$`)
+)
+
+// Migration is one embedded migration file.
+type Migration struct {
+	Version string // file name without .sql, e.g. 0001_baseline
+	Up      string
+	Down    string
+	HasDown bool // false when the migration can't be rolled back
+}
+
+// Load parses the embedded migrations, in version order.
+func Load() ([]Migration, error) {
+	names, err := fs.Glob(Files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	migrations := make([]Migration, 0, len(names))
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		m := fileNameRe.FindStringSubmatch(name)
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 0001_description.sql", name)
+		}
+		if other, ok := seen[m[1]]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share number %s", other, name, m[1])
+		}
+		seen[m[1]] = name
+
+		raw, err := Files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, parse(strings.TrimSuffix(name, ".sql"), string(raw)))
+	}
+	return migrations, nil
+}
+
+// parse splits a migration file at its "-- +migrate Down" line.
+func parse(version, sql string) Migration {
+	m := Migration{Version: version, Up: sql}
+	if loc := downRe.FindStringIndex(sql); loc != nil {
+		m.Up, m.Down, m.HasDown = sql[:loc[0]], sql[loc[1]:], true
+	}
+	return m
+}
+
+// Objects lists what each migration creates, in version order. Statements that don't
+// create a named table, index or column (backfills, constraints) aren't covered.
+func Objects() ([]Object, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, mig := range all {
+		name := mig.Version + ".sql"
+		for _, stmt := range strings.Split(sqlCommentRe.ReplaceAllString(mig.Up, ""), ";") {
+			for _, m := range createTableRe.FindAllStringSubmatch(stmt, -1) {
+				objects = append(objects, Object{Kind: "table", Name: strings.ToLower(m[1]), Source: name})
+			}
+			for _, m := range createIndexRe.FindAllStringSubmatch(stmt, -1) {
+				objects = append(objects, Object{Kind: "index", Name: strings.ToLower(m[1]), Source: name})
+			}
+			alter := alterTableRe.FindStringSubmatch(stmt)
+			if alter == nil {
+				continue
+			}
+			for _, m := range addColumnRe.FindAllStringSubmatch(stmt, -1) {
+				objects = append(objects, Object{Kind: "column", Table: strings.ToLower(alter[1]), Name: strings.ToLower(m[1]), Source: name})
+			}
+		}
+	}
+	return objects, nil
+}
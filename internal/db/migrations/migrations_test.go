@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	all, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || all[0].Version != "0001_baseline" {
+		t.Fatalf("first migration should be the baseline, got %+v", all[0].Version)
+	}
+	for i, m := range all {
+		if i > 0 && m.Version <= all[i-1].Version {
+			t.Errorf("%s sorts before %s", m.Version, all[i-1].Version)
+		}
+		if strings.Contains(m.Up, "+migrate") {
+			t.Errorf("%s: Up still holds a marker", m.Version)
+		}
+	}
+	if all[0].HasDown {
+		t.Error("the baseline should not be reversible")
+	}
+}
+
+func TestParse(t *testing.T) {
+	m := parse("0002_x", "CREATE TABLE IF NOT EXISTS x (id INT);\n\n-- +migrate Down\nDROP TABLE IF EXISTS x;\n")
+	if !m.HasDown || strings.TrimSpace(m.Down) != "DROP TABLE IF EXISTS x;" {
+		t.Errorf("Down = %q, HasDown = %v", m.Down, m.HasDown)
+	}
+	if strings.Contains(m.Up, "DROP") {
+		t.Errorf("Up = %q", m.Up)
+	}
+
+	m = parse("0003_y", "UPDATE y SET z = 1; -- +migrate Down is only a marker on its own line\n")
+	if m.HasDown {
+		t.Errorf("inline marker was taken as a Down section: %q", m.Down)
+	}
+}
+
+func TestObjectsSkipDown(t *testing.T) {
+	objects, err := Objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, o := range objects {
+		if o.Kind == "table" && o.Name == "users" {
+			found = o.Source == "0001_baseline.sql"
+		}
+	}
+	if !found {
+		t.Error("users table should come from the baseline")
+	}
+}
@@ -0,0 +1,179 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey is the advisory lock held while migrating, so instances starting together
+// don't apply the same migration twice.
+const lockKey int64 = 0x77617a656b6962 // "wazekib"
+
+// Status is a migration and when it was applied; AppliedAt is nil while it is pending.
+type Status struct {
+	Version   string
+	AppliedAt *time.Time
+}
+
+// Up applies every pending migration in version order, each in its own transaction,
+// and returns the versions it applied. It stops at the first one that fails.
+func Up(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	err = withLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("applying %s: %w", m.Version, err)
+			}
+			applied = append(applied, m.Version)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migration and returns its version, or ""
+// when nothing is applied.
+func Down(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+	all, err := Load()
+	if err != nil {
+		return "", err
+	}
+	var version string
+	err = withLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		err := conn.QueryRow(ctx, `SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1`).Scan(&version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var m *Migration
+		for i := range all {
+			if all[i].Version == version {
+				m = &all[i]
+			}
+		}
+		if m == nil {
+			return fmt.Errorf("%s was applied but isn't in this build", version)
+		}
+		if !m.HasDown {
+			return fmt.Errorf("%s can't be rolled back", version)
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if strings.TrimSpace(sqlCommentRe.ReplaceAllString(m.Down, "")) != "" {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back %s: %w", version, err)
+		}
+		return nil
+	})
+	return version, err
+}
+
+// List returns every embedded migration with when it was applied.
+func List(ctx context.Context, pool *pgxpool.Pool) ([]Status, error) {
+	all, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	var tracked bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&tracked); err != nil {
+		return nil, err
+	}
+	done := map[string]time.Time{}
+	if tracked {
+		if done, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]Status, len(all))
+	for i, m := range all {
+		statuses[i].Version = m.Version
+		if at, ok := done[m.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// withLock runs fn on one connection holding the migration lock, creating the
+// schema_migrations table first if needed.
+func withLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	_, err = conn.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version TEXT PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[string]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[string]time.Time{}
+	for rows.Next() {
+		var version string
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		done[version] = at
+	}
+	return done, rows.Err()
+}
@@ -1,10 +1,12 @@
 package deps
 
 import (
-	"log"
+	"context"
+	"log/slog"
 
 	"github.com/bwise1/waze_kibris/config"
 	"github.com/bwise1/waze_kibris/internal/db"
+	"github.com/bwise1/waze_kibris/util/presence"
 	"github.com/bwise1/waze_kibris/util/storage"
 	"github.com/bwise1/waze_kibris/util/websockets"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,21 +16,35 @@ type Dependencies struct {
 	DB         *db.DB
 	Cloudinary *storage.Cloudinary
 	WebSocket  *websockets.WebSocketManager
+	// Presence is nil when REDIS_URL is unset or Redis was unreachable at startup.
+	Presence *presence.RedisStore
 }
 
 func New(cfg *config.Config) *Dependencies {
 	database, err := db.New(cfg.Dsn)
 	if err != nil {
-		log.Panicln("failed to connect to database", "error", err)
+		slog.Error("failed to connect to database", "err", err)
+		panic(err)
 	}
 
 	cloudinary := storage.NewCloudinary(cfg)
 	websocket := websockets.NewWebSocketManager()
+	var presenceStore *presence.RedisStore
+	if cfg.RedisURL != "" {
+		store, err := presence.NewRedisStore(context.Background(), cfg.RedisURL)
+		if err != nil {
+			slog.Warn("redis unavailable, presence limited to this instance", "err", err)
+		} else {
+			websocket.SetPresenceStore(store)
+			presenceStore = store
+		}
+	}
 
 	deps := Dependencies{
 		DB:         database,
 		Cloudinary: cloudinary,
 		WebSocket:  websocket,
+		Presence:   presenceStore,
 	}
 	return &deps
 }
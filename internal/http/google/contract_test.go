@@ -0,0 +1,46 @@
+package googlemaps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixture  string
+		status   int
+		strict   bool
+		required []string
+		allowed  []string
+		wantErr  string
+	}{
+		{name: "geocode ok", fixture: "geocode_ok.json", status: 200, strict: true, required: geocodeRequiredFields},
+		{name: "geocode zero results", fixture: "geocode_zero_results.json", status: 200, required: geocodeRequiredFields, wantErr: "ZERO_RESULTS"},
+		{name: "autocomplete zero results", fixture: "geocode_zero_results.json", status: 200, strict: true, allowed: []string{"ZERO_RESULTS"}},
+		{name: "directions zero results", fixture: "directions_zero_results.json", status: 200, strict: true, required: directionsRequiredFields, wantErr: "ZERO_RESULTS"},
+		{name: "request denied", fixture: "request_denied.json", status: 200, wantErr: "REQUEST_DENIED (The provided API key is invalid. )"},
+		{name: "http error", fixture: "request_denied.json", status: 500, wantErr: "status code 500"},
+		{name: "missing geometry lenient", fixture: "geocode_missing_geometry.json", status: 200, required: geocodeRequiredFields},
+		{name: "missing geometry strict", fixture: "geocode_missing_geometry.json", status: 200, strict: true, required: geocodeRequiredFields, wantErr: "missing results[0].geometry"},
+	}
+	for _, tt := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v GeocodeResponse
+		err = decodeResponse(tt.status, body, &v, tt.strict, tt.required, tt.allowed...)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+}
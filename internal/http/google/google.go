@@ -5,28 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
-	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/util"
 )
 
 // GoogleMapsClient handles communication with Google Maps APIs
 type GoogleMapsClient struct {
 	APIKey string // IMPORTANT: Handle your API Key securely! Do not hardcode.
 	Client *http.Client
+	// Strict makes responses missing fields we rely on fail instead of decoding to zero values.
+	Strict bool
 }
 
 // NewGoogleMapsClient creates a new client instance
 // apiKey should be loaded securely (e.g., from environment variable)
 func NewGoogleMapsClient(apiKey string) *GoogleMapsClient {
 	if apiKey == "" {
-		log.Println("Warning: Google Maps API Key is empty.")
+		slog.Warn("Google Maps API key is empty")
 	}
 	return &GoogleMapsClient{
 		APIKey: apiKey,
-		Client: &http.Client{Timeout: 30 * time.Second},
+		Client: resilient.NewClient(nil, resilient.Policy{}),
 	}
 }
 
@@ -185,35 +190,21 @@ func (gc *GoogleMapsClient) GetPlaceDetails(ctx context.Context, placeID string,
 
 	resp, err := gc.Client.Do(req)
 	if err != nil {
-		log.Printf("Error making Place Details request: %v\n", err)
+		slog.ErrorContext(ctx, "Google place details request failed", "err", err)
 		return nil, fmt.Errorf("failed to execute Place Details request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading Place Details response body: %v\n", err)
+		slog.ErrorContext(ctx, "Reading Google place details response failed", "err", err)
 		return nil, fmt.Errorf("failed to read Place Details response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Place Details request failed with status %d: %s\n", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("google maps error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var detailsResponse PlaceDetailsResponse
-	err = json.Unmarshal(bodyBytes, &detailsResponse)
-	if err != nil {
-		log.Printf("Error decoding Place Details response: %v\nBody: %s\n", err, string(bodyBytes))
-		return nil, fmt.Errorf("failed to decode Place Details response: %w", err)
-	}
-
-	// Check the status field in the response JSON
-	if detailsResponse.Status != "OK" {
-		log.Printf("Google Maps API returned status: %s\n", detailsResponse.Status)
-		return nil, fmt.Errorf("google maps API error: %s", detailsResponse.Status)
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &detailsResponse, gc.Strict, placeDetailsRequiredFields); err != nil {
+		return nil, err
 	}
-
 	return &detailsResponse.Result, nil
 }
 
@@ -358,21 +349,10 @@ func (gc *GoogleMapsClient) PlaceAutocomplete(ctx context.Context, input string,
 		return nil, fmt.Errorf("failed to read Place Autocomplete response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google maps error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var autoResp AutocompleteResponse
-	err = json.Unmarshal(bodyBytes, &autoResp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode Place Autocomplete response: %w", err)
-	}
-
-	// Check for API-level errors, like "ZERO_RESULTS" or "REQUEST_DENIED".
-	if autoResp.Status != "OK" && autoResp.Status != "ZERO_RESULTS" {
-		return nil, fmt.Errorf("google maps API error: %s", autoResp.Status)
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &autoResp, gc.Strict, nil, "ZERO_RESULTS"); err != nil {
+		return nil, err
 	}
-
 	return &autoResp, nil
 }
 
@@ -419,17 +399,9 @@ func (gc *GoogleMapsClient) PlaceSearch(ctx context.Context, query string, locat
 		return nil, fmt.Errorf("failed to read Place Search response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google maps error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var searchResp PlaceSearchResponse
-	err = json.Unmarshal(bodyBytes, &searchResp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode Place Search response: %w", err)
-	}
-	if searchResp.Status != "OK" {
-		return nil, fmt.Errorf("google maps API error: %s", searchResp.Status)
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &searchResp, gc.Strict, placeSearchRequiredFields); err != nil {
+		return nil, err
 	}
 	return &searchResp, nil
 }
@@ -475,8 +447,9 @@ type TextValue struct {
 	Value int    `json:"value"`
 }
 
-// Directions fetches directions between two points.
-func (gc *GoogleMapsClient) Directions(ctx context.Context, origin, destination string, waypoints []string, mode string, alternatives bool) (*DirectionsResponse, error) {
+// Directions fetches directions between two points. language (e.g. "tr") is optional;
+// Google picks one when it is empty.
+func (gc *GoogleMapsClient) Directions(ctx context.Context, origin, destination string, waypoints []string, mode string, alternatives bool, language string) (*DirectionsResponse, error) {
 	if gc.APIKey == "" {
 		return nil, fmt.Errorf("google maps API key is not set")
 	}
@@ -498,6 +471,9 @@ func (gc *GoogleMapsClient) Directions(ctx context.Context, origin, destination
 	if alternatives {
 		params.Set("alternatives", "true")
 	}
+	if language != "" {
+		params.Set("language", language)
+	}
 
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
@@ -516,17 +492,9 @@ func (gc *GoogleMapsClient) Directions(ctx context.Context, origin, destination
 		return nil, fmt.Errorf("failed to read Directions response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google maps error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var dirResp DirectionsResponse
-	err = json.Unmarshal(bodyBytes, &dirResp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode Directions response: %w", err)
-	}
-	if dirResp.Status != "OK" {
-		return nil, fmt.Errorf("google maps API error: %s", dirResp.Status)
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &dirResp, gc.Strict, directionsRequiredFields); err != nil {
+		return nil, err
 	}
 	return &dirResp, nil
 }
@@ -544,8 +512,9 @@ type GeocodeResult struct {
 	Types            []string `json:"types"`
 }
 
-// ReverseGeocode converts coordinates to an address.
-func (gc *GoogleMapsClient) ReverseGeocode(ctx context.Context, lat, lng float64) (*GeocodeResponse, error) {
+// ReverseGeocode converts coordinates to an address. language (e.g. "tr") is optional;
+// empty lets Google pick.
+func (gc *GoogleMapsClient) ReverseGeocode(ctx context.Context, lat, lng float64, language string) (*GeocodeResponse, error) {
 	if gc.APIKey == "" {
 		return nil, fmt.Errorf("google maps API key is not set")
 	}
@@ -554,6 +523,9 @@ func (gc *GoogleMapsClient) ReverseGeocode(ctx context.Context, lat, lng float64
 	params := url.Values{}
 	params.Set("latlng", fmt.Sprintf("%f,%f", lat, lng))
 	params.Set("key", gc.APIKey)
+	if language != "" {
+		params.Set("language", language)
+	}
 
 	fullURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
@@ -572,19 +544,76 @@ func (gc *GoogleMapsClient) ReverseGeocode(ctx context.Context, lat, lng float64
 		return nil, fmt.Errorf("failed to read Reverse Geocode response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google maps error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
 	var geoResp GeocodeResponse
-	err = json.Unmarshal(bodyBytes, &geoResp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode Reverse Geocode response: %w", err)
-	}
-	if geoResp.Status != "OK" {
-		return nil, fmt.Errorf("google maps API error: %s", geoResp.Status)
+	if err := decodeResponse(resp.StatusCode, bodyBytes, &geoResp, gc.Strict, geocodeRequiredFields); err != nil {
+		return nil, err
 	}
 	return &geoResp, nil
 }
 
-// ...existing code...
+// Fields the app reads from each response; strict mode rejects responses without them.
+var (
+	placeDetailsRequiredFields = []string{"result.geometry.location", "result.place_id"}
+	placeSearchRequiredFields  = []string{"results[].geometry.location", "results[].place_id"}
+	directionsRequiredFields   = []string{"routes[].legs[].duration.value", "routes[].legs[].distance.value", "routes[].overview_polyline.points"}
+	geocodeRequiredFields      = []string{"results[].formatted_address", "results[].geometry.location"}
+)
+
+// Error is a Google Maps web service rejection: a non-200 reply, or a 200 whose status
+// isn't OK.
+type Error struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Body       string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.StatusCode != http.StatusOK:
+		return fmt.Sprintf("google maps error: status code %d, body: %s", e.StatusCode, e.Body)
+	case e.Message != "":
+		return fmt.Sprintf("google maps API error: %s (%s)", e.Status, e.Message)
+	}
+	return fmt.Sprintf("google maps API error: %s", e.Status)
+}
+
+// NoResults reports whether the request was fine but nothing matched it.
+func (e *Error) NoResults() bool {
+	return e.Status == "ZERO_RESULTS" || e.Status == "NOT_FOUND"
+}
+
+// RateLimited reports whether Google turned the request away for exceeding a rate limit
+// or quota.
+func (e *Error) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Status == "OVER_QUERY_LIMIT" || e.Status == "OVER_DAILY_LIMIT"
+}
+
+// decodeResponse decodes a Google Maps web service reply into v. Google signals most
+// failures (ZERO_RESULTS, REQUEST_DENIED, OVER_QUERY_LIMIT) with HTTP 200 and a status
+// field, so anything but OK, or one of allowed, is an error naming the status.
+func decodeResponse(statusCode int, body []byte, v interface{}, strict bool, required []string, allowed ...string) error {
+	if statusCode != http.StatusOK {
+		return &Error{StatusCode: statusCode, Body: string(body)}
+	}
+
+	var envelope struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode Google Maps response: %w", err)
+	}
+	if envelope.Status != "OK" && !slices.Contains(allowed, envelope.Status) {
+		return &Error{StatusCode: statusCode, Status: envelope.Status, Message: envelope.ErrorMessage}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode Google Maps response: %w", err)
+	}
+	if strict && envelope.Status == "OK" {
+		if err := util.RequireJSONFields(body, required...); err != nil {
+			return fmt.Errorf("google maps: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package mapbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDirectionsResponse(t *testing.T) {
+	tests := []struct {
+		fixture string
+		status  int
+		strict  bool
+		wantErr string
+	}{
+		{fixture: "directions_ok.json", status: http.StatusOK, strict: true},
+		{fixture: "directions_no_route.json", status: http.StatusOK, wantErr: "NoRoute (No route found)"},
+		{fixture: "directions_invalid_input.json", status: http.StatusUnprocessableEntity, wantErr: "status code 422"},
+		{fixture: "directions_missing_geometry.json", status: http.StatusOK},
+		{fixture: "directions_missing_geometry.json", status: http.StatusOK, strict: true, wantErr: "missing routes[0].geometry"},
+	}
+	for _, tt := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := parseDirectionsResponse(tt.status, body, tt.strict)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s (strict=%v): got error %v, want %q", tt.fixture, tt.strict, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (strict=%v): unexpected error: %v", tt.fixture, tt.strict, err)
+			continue
+		}
+		if len(resp.Routes) == 0 {
+			t.Errorf("%s: no routes decoded", tt.fixture)
+		}
+	}
+}
+
+func TestParseMapMatchingResponse(t *testing.T) {
+	tests := []struct {
+		fixture string
+		status  int
+		wantErr error
+	}{
+		{fixture: "matching_ok.json", status: http.StatusOK},
+		{fixture: "matching_no_match.json", status: http.StatusOK, wantErr: ErrNoMatch},
+	}
+	for _, tt := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := parseMapMatchingResponse(context.Background(), tt.status, body)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tt.fixture, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr == nil && len(resp.Tracepoints) != 2 {
+			t.Errorf("%s: got %d tracepoints, want 2", tt.fixture, len(resp.Tracepoints))
+		}
+	}
+	if _, err := parseMapMatchingResponse(context.Background(), http.StatusUnauthorized, []byte(`{"message":"Not Authorized"}`)); err == nil || errors.Is(err, ErrNoMatch) {
+		t.Errorf("401: got error %v", err)
+	}
+}
@@ -3,29 +3,34 @@ package mapbox
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/util"
 )
 
 // MapboxClient handles communication with Mapbox APIs
 type MapboxClient struct {
 	APIKey string // IMPORTANT: Handle your API Key securely! Load from environment variable.
 	Client *http.Client
+	// Strict makes responses missing fields we rely on fail instead of decoding to zero values.
+	Strict bool
 }
 
 // NewMapboxClient creates a new Mapbox client instance
 func NewMapboxClient(apiKey string) *MapboxClient {
 	if apiKey == "" {
-		log.Println("Warning: Mapbox API Key is empty.")
+		slog.Warn("Mapbox API key is empty")
 	}
 	return &MapboxClient{
 		APIKey: apiKey,
-		Client: &http.Client{Timeout: 30 * time.Second},
+		Client: resilient.NewClient(nil, resilient.Policy{}),
 	}
 }
 
@@ -35,6 +40,21 @@ func NewMapboxClient(apiKey string) *MapboxClient {
 type DirectionsResponse struct {
 	Routes []Route `json:"routes"`
 	Code   string  `json:"code"` // "Ok", "NoRoute", "NoSegment", "ProfileNotFound", etc.
+	// Message explains a non-Ok code, e.g. "No route found".
+	Message string `json:"message,omitempty"`
+	// Arrival is added by our API when the route ends at a better arrival point than the
+	// requested destination; Mapbox never sends it.
+	Arrival *ArrivalPoint `json:"arrival,omitempty"`
+	// Provider is added by our API: the routing provider that computed the routes, which
+	// may be a fallback when the preferred one is down.
+	Provider string `json:"provider,omitempty"`
+	// ChoiceToken is set by our API when alternatives were ranked for a signed-in user. The
+	// app sends it back with the chosen route's index when navigation starts.
+	ChoiceToken string `json:"choice_token,omitempty"`
+	// ParkAndWalk is set by our API when the trip ends on foot: the app follows Routes to
+	// WalkingLeg.StartsAt, then switches to walking guidance for WalkingLeg.Route.
+	ParkAndWalk bool        `json:"park_and_walk,omitempty"`
+	WalkingLeg  *WalkingLeg `json:"walking_leg,omitempty"`
 }
 
 // Route contains a single route with geometry and legs
@@ -45,6 +65,88 @@ type Route struct {
 	Weight     float64    `json:"weight"`
 	Duration   float64    `json:"duration"` // in seconds
 	Distance   float64    `json:"distance"` // in meters
+	// ArrivalWindow is added by our API from historical speeds; Mapbox never sends it.
+	ArrivalWindow *ArrivalWindow `json:"arrival_window,omitempty"`
+	// Recommendation is added by our API when alternatives are ranked for the user.
+	Recommendation *RouteRecommendation `json:"recommendation,omitempty"`
+	// Reports is added by our API: active reports close to this route, in driving order.
+	Reports []RouteReport `json:"reports,omitempty"`
+	// AdjustedDuration is added by our API: Duration plus the delay expected from live
+	// TRAFFIC and ACCIDENT reports on the route, itemised in ReportDelays.
+	AdjustedDuration float64       `json:"adjusted_duration,omitempty"`
+	ReportDelays     []RouteReport `json:"report_delays,omitempty"`
+	// MapFeatures is added by our API: speed cameras, speed bumps and railway crossings on
+	// this route in the direction it is driven, in driving order.
+	MapFeatures []RouteMapFeature `json:"map_features,omitempty"`
+	// Lighting is added by our API on "safe" vehicle routes, which are ranked by it.
+	Lighting *RouteLighting `json:"lighting,omitempty"`
+}
+
+// RouteReport is an active report near a route, with where it is along the route.
+type RouteReport struct {
+	ID               int64   `json:"id"`
+	Type             string  `json:"type"`
+	Subtype          *string `json:"subtype,omitempty"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	Severity         int     `json:"severity"`
+	DistanceAlongM   float64 `json:"distance_along_m"`
+	DistanceToRouteM float64 `json:"distance_to_route_m"`
+	// DelayS is set in Route.ReportDelays: the seconds this report is expected to add.
+	DelayS float64 `json:"delay_s,omitempty"`
+}
+
+// RouteMapFeature is a fixed hazard on a route, with where it is along the route.
+type RouteMapFeature struct {
+	ID             int64   `json:"id"`
+	Type           string  `json:"type"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	SpeedLimitKmh  *int    `json:"speed_limit_kmh,omitempty"`
+	DistanceAlongM float64 `json:"distance_along_m"`
+	// Bearing is only used to match the feature to the direction of travel.
+	Bearing *float64 `json:"-"`
+}
+
+// RouteLighting is the share of a route's length on ways OSM tags as lit or unlit. The
+// rest has no lit tag in the imported data.
+type RouteLighting struct {
+	LitShare   float64 `json:"lit_share"`
+	UnlitShare float64 `json:"unlit_share"`
+}
+
+// RouteRecommendation explains where a route was ranked among the alternatives.
+type RouteRecommendation struct {
+	Rank   int    `json:"rank"` // 1 is the recommended route
+	Reason string `json:"reason"`
+}
+
+// ArrivalPoint is where the route actually ends and how far the requested destination
+// is from there on foot.
+type ArrivalPoint struct {
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	Source           string  `json:"source"`         // "override" or "google_place"
+	Kind             string  `json:"kind,omitempty"` // entrance, parking or drop_off (overrides only)
+	Name             string  `json:"name,omitempty"`
+	WalkingDistanceM float64 `json:"walking_distance_m"`
+	WalkingDurationS float64 `json:"walking_duration_s"`
+}
+
+// WalkingLeg is the on-foot final approach of a park-and-walk trip.
+type WalkingLeg struct {
+	Reason   string    `json:"reason"`    // "parking" or "pedestrian_zone"
+	StartsAt []float64 `json:"starts_at"` // [longitude, latitude] where the driving route ends
+	Route    Route     `json:"route"`
+}
+
+// ArrivalWindow is the range the trip duration is likely to fall in, e.g. "18–25 min".
+type ArrivalWindow struct {
+	OptimisticSeconds  float64 `json:"optimistic_seconds"`
+	PessimisticSeconds float64 `json:"pessimistic_seconds"`
+	Formatted          string  `json:"formatted"`
+	Basis              string  `json:"basis"`   // "historical" or "default" when there isn't enough data
+	Samples            int     `json:"samples"` // Historical speed samples the window is based on
 }
 
 // LineString contains the route geometry with road-snapped coordinates
@@ -88,6 +190,18 @@ type Step struct {
 	Pronunciation       string              `json:"pronunciation,omitempty"`
 	RotaryName          string              `json:"rotary_name,omitempty"`
 	RotaryPronunciation string              `json:"rotary_pronunciation,omitempty"`
+	// RoadControls is added by our API when requested: traffic signals and stop signs
+	// passed while following this step, in travel order. Mapbox never sends it.
+	RoadControls []RoadControl `json:"road_controls,omitempty"`
+}
+
+// RoadControl is a traffic signal or stop sign along a step, so clients can say "turn
+// left at the second traffic light".
+type RoadControl struct {
+	Type           string    `json:"type"`            // "TrafficSignal" or "StopSign"
+	DistanceMeters float64   `json:"distance_meters"` // Distance from the start of the step
+	Coordinates    []float64 `json:"coordinates"`     // [lon, lat]
+	AtManeuverEnd  bool      `json:"at_maneuver_end"` // True when it sits at the next step's intersection
 }
 
 // Intersection contains information about road intersections
@@ -236,36 +350,18 @@ func (mc *MapboxClient) Directions(ctx context.Context, coordinates []string, pr
 
 	resp, err := mc.Client.Do(req)
 	if err != nil {
-		log.Printf("Error making Mapbox Directions request: %v\n", err)
+		slog.ErrorContext(ctx, "Mapbox directions request failed", "err", err)
 		return nil, fmt.Errorf("failed to execute Mapbox Directions request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading Mapbox Directions response body: %v\n", err)
+		slog.ErrorContext(ctx, "Reading Mapbox directions response failed", "err", err)
 		return nil, fmt.Errorf("failed to read Mapbox Directions response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Mapbox Directions request failed with status %d: %s\n", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("mapbox directions error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var dirResp DirectionsResponse
-	err = json.Unmarshal(bodyBytes, &dirResp)
-	if err != nil {
-		log.Printf("Error decoding Mapbox Directions response: %v\nBody: %s\n", err, string(bodyBytes))
-		return nil, fmt.Errorf("failed to decode Mapbox Directions response: %w", err)
-	}
-
-	// Check the code field in the response
-	if dirResp.Code != "Ok" {
-		log.Printf("Mapbox Directions API returned code: %s\n", dirResp.Code)
-		return nil, fmt.Errorf("mapbox directions API error: %s", dirResp.Code)
-	}
-
-	return &dirResp, nil
+	return parseDirectionsResponse(resp.StatusCode, bodyBytes, mc.Strict)
 }
 
 // DirectionsWithNavigation fetches directions with enhanced navigation features
@@ -347,33 +443,86 @@ func (mc *MapboxClient) DirectionsWithNavigation(ctx context.Context, coordinate
 
 	resp, err := mc.Client.Do(req)
 	if err != nil {
-		log.Printf("Error making Mapbox Directions request: %v\n", err)
+		slog.ErrorContext(ctx, "Mapbox directions request failed", "err", err)
 		return nil, fmt.Errorf("failed to execute Mapbox Directions request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading Mapbox Directions response body: %v\n", err)
+		slog.ErrorContext(ctx, "Reading Mapbox directions response failed", "err", err)
 		return nil, fmt.Errorf("failed to read Mapbox Directions response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Mapbox Directions request failed with status %d: %s\n", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("mapbox directions error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
+	return parseDirectionsResponse(resp.StatusCode, bodyBytes, mc.Strict)
+}
+
+// directionsRequiredFields are what the app reads from every route; strict mode rejects
+// responses without them.
+var directionsRequiredFields = []string{
+	"routes[].geometry.coordinates",
+	"routes[].legs",
+	"routes[].duration",
+	"routes[].distance",
+}
+
+// Error is a Directions API rejection: a non-200 reply, or a 200 whose code isn't Ok or
+// that has no routes.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Body       string
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.StatusCode != http.StatusOK:
+		return fmt.Sprintf("mapbox directions error: status code %d, body: %s", e.StatusCode, e.Body)
+	case e.Code == "Ok":
+		return "mapbox directions API error: no routes"
+	case e.Message != "":
+		return fmt.Sprintf("mapbox directions API error: %s (%s)", e.Code, e.Message)
+	}
+	return fmt.Sprintf("mapbox directions API error: %s", e.Code)
+}
+
+// NoRoute reports whether the request was fine but no route joins the coordinates.
+func (e *Error) NoRoute() bool {
+	return e.StatusCode == http.StatusOK && (e.Code == "NoRoute" || e.Code == "NoSegment" || e.Code == "Ok")
+}
+
+// RateLimited reports whether Mapbox turned the request away for exceeding the rate limit.
+func (e *Error) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// parseDirectionsResponse decodes a Directions API reply. Mapbox reports NoRoute and
+// NoSegment with HTTP 200 and an empty routes list, so the code is checked too.
+func parseDirectionsResponse(statusCode int, body []byte, strict bool) (*DirectionsResponse, error) {
+	if statusCode != http.StatusOK {
+		slog.Error("Mapbox directions request rejected", "status", statusCode, "body", string(body))
+		return nil, &Error{StatusCode: statusCode, Body: string(body)}
 	}
 
 	var dirResp DirectionsResponse
-	err = json.Unmarshal(bodyBytes, &dirResp)
-	if err != nil {
-		log.Printf("Error decoding Mapbox Directions response: %v\nBody: %s\n", err, string(bodyBytes))
+	if err := json.Unmarshal(body, &dirResp); err != nil {
+		slog.Error("Decoding Mapbox directions response failed", "err", err, "body", string(body))
 		return nil, fmt.Errorf("failed to decode Mapbox Directions response: %w", err)
 	}
 
 	// Check the code field in the response
 	if dirResp.Code != "Ok" {
-		log.Printf("Mapbox Directions API returned code: %s\n", dirResp.Code)
-		return nil, fmt.Errorf("mapbox directions API error: %s", dirResp.Code)
+		slog.Warn("Mapbox directions returned an error code", "code", dirResp.Code)
+		return nil, &Error{StatusCode: statusCode, Code: dirResp.Code, Message: dirResp.Message}
+	}
+	if len(dirResp.Routes) == 0 {
+		return nil, &Error{StatusCode: statusCode, Code: dirResp.Code}
+	}
+	if strict {
+		if err := util.RequireJSONFields(body, directionsRequiredFields...); err != nil {
+			return nil, fmt.Errorf("mapbox directions: %w", err)
+		}
 	}
 
 	return &dirResp, nil
@@ -469,46 +618,79 @@ func (mc *MapboxClient) MapMatching(ctx context.Context, coordinates []string, a
 
 	resp, err := mc.Client.Do(req)
 	if err != nil {
-		log.Printf("Error making Mapbox Map Matching request: %v\n", err)
+		slog.ErrorContext(ctx, "Mapbox map matching request failed", "err", err)
 		return nil, fmt.Errorf("failed to execute Mapbox Map Matching request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading Mapbox Map Matching response body: %v\n", err)
+		slog.ErrorContext(ctx, "Reading Mapbox map matching response failed", "err", err)
 		return nil, fmt.Errorf("failed to read Mapbox Map Matching response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Mapbox Map Matching request failed with status %d: %s\n", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("mapbox map matching error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
+	return parseMapMatchingResponse(ctx, resp.StatusCode, bodyBytes)
+}
+
+// ErrNoMatch means Map Matching found no road the coordinates could lie on.
+var ErrNoMatch = errors.New("no suitable road network match found for the given coordinates")
+
+// MatchingError is a Map Matching API rejection other than NoMatch: a non-200 reply, or a
+// 200 whose code isn't Ok.
+type MatchingError struct {
+	StatusCode int
+	Code       string
+	Body       string
+}
+
+func (e *MatchingError) Error() string {
+	if e.StatusCode != http.StatusOK {
+		return fmt.Sprintf("mapbox map matching error: status code %d, body: %s", e.StatusCode, e.Body)
+	}
+	switch e.Code {
+	case "TooManyCoordinates":
+		return "too many coordinates provided (max 100)"
+	case "InvalidInput":
+		return "invalid input coordinates"
+	case "ProfileNotFound":
+		return "driving profile not found"
+	}
+	return fmt.Sprintf("mapbox map matching API error: %s", e.Code)
+}
+
+// InvalidInput reports whether Mapbox rejected the coordinates or parameters sent.
+func (e *MatchingError) InvalidInput() bool {
+	return e.StatusCode == http.StatusUnprocessableEntity || e.Code == "InvalidInput" || e.Code == "TooManyCoordinates"
+}
+
+// RateLimited reports whether Mapbox turned the request away for exceeding the rate limit.
+func (e *MatchingError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// parseMapMatchingResponse decodes a Map Matching API reply. Like Directions, it reports
+// failures such as NoMatch with HTTP 200 and a code.
+func parseMapMatchingResponse(ctx context.Context, statusCode int, bodyBytes []byte) (*MapMatchingResponse, error) {
+	if statusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "Mapbox map matching request rejected", "status", statusCode, "body", string(bodyBytes))
+		return nil, &MatchingError{StatusCode: statusCode, Body: string(bodyBytes)}
 	}
 
 	var matchResp MapMatchingResponse
-	err = json.Unmarshal(bodyBytes, &matchResp)
+	err := json.Unmarshal(bodyBytes, &matchResp)
 	if err != nil {
-		log.Printf("Error decoding Mapbox Map Matching response: %v\nBody: %s\n", err, string(bodyBytes))
+		slog.ErrorContext(ctx, "Decoding Mapbox map matching response failed", "err", err, "body", string(bodyBytes))
 		return nil, fmt.Errorf("failed to decode Mapbox Map Matching response: %w", err)
 	}
 
 	// Check the code field in the response
 	if matchResp.Code != "Ok" {
-		log.Printf("Mapbox Map Matching API returned code: %s\n", matchResp.Code)
-
-		// Handle specific error codes
-		switch matchResp.Code {
-		case "NoMatch":
-			return nil, fmt.Errorf("no suitable road network match found for the given coordinates")
-		case "TooManyCoordinates":
-			return nil, fmt.Errorf("too many coordinates provided (max 100)")
-		case "InvalidInput":
-			return nil, fmt.Errorf("invalid input coordinates")
-		case "ProfileNotFound":
-			return nil, fmt.Errorf("driving profile not found")
-		default:
-			return nil, fmt.Errorf("mapbox map matching API error: %s", matchResp.Code)
+		slog.WarnContext(ctx, "Mapbox map matching returned an error code", "code", matchResp.Code)
+
+		if matchResp.Code == "NoMatch" {
+			return nil, ErrNoMatch
 		}
+		return nil, &MatchingError{StatusCode: statusCode, Code: matchResp.Code}
 	}
 
 	return &matchResp, nil
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"net/url"
@@ -72,30 +72,30 @@ func (mc *MapboxClient) SnapLocationToRoad(ctx context.Context, req LocationSnap
 
 	// Strategy 1: Try route snapping first if route geometry provided
 	if req.RouteGeometry != nil && len(req.RouteGeometry.Coordinates) > 0 {
-		log.Println("🛣️ Attempting route snapping...")
+		slog.DebugContext(ctx, "Attempting route snapping")
 		response, err = mc.snapToRoute(ctx, req)
 		if err == nil && response.Confidence > 0.6 {
 			response.SnapType = "route"
-			log.Printf("✅ Route snapping successful with confidence: %.2f", response.Confidence)
+			slog.DebugContext(ctx, "Route snapping succeeded", "confidence", response.Confidence)
 			return response, nil
 		}
-		log.Printf("⚠️ Route snapping failed or low confidence: %.2f", response.Confidence)
+		slog.DebugContext(ctx, "Route snapping failed or low confidence", "confidence", response.Confidence)
 	}
 
 	// Strategy 2: Fall back to road snapping using Map Matching API
-	log.Println("🛣️ Attempting road snapping...")
+	slog.DebugContext(ctx, "Attempting road snapping")
 	response, err = mc.snapToRoadNetwork(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("road snapping failed: %w", err)
 	}
 
 	response.SnapType = "road"
-	log.Printf("✅ Road snapping successful with confidence: %.2f", response.Confidence)
+	slog.DebugContext(ctx, "Road snapping succeeded", "confidence", response.Confidence)
 
 	// Strategy 3: Handle opposite side placement for reports
 	if req.OppositeSide {
 		response = mc.adjustForOppositeSide(response)
-		log.Println("🔄 Applied opposite side adjustment for report placement")
+		slog.DebugContext(ctx, "Applied opposite side adjustment for report placement")
 	}
 
 	return response, nil
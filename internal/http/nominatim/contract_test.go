@@ -0,0 +1,137 @@
+package nominatim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// replay serves a recorded response for each path and records the requests it got.
+func replay(t *testing.T, status int, fixtures map[string]string) (*Client, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		fixture, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(readFixture(t, fixture))
+	}))
+	t.Cleanup(srv.Close)
+	client := NewClient(srv.URL + "/")
+	client.Strict = true
+	return client, &requests
+}
+
+func TestSearch(t *testing.T) {
+	client, requests := replay(t, http.StatusOK, map[string]string{"/search": "search_ok.json"})
+	places, err := client.Search(context.Background(), "girne liman", SearchParams{
+		Limit: 5, Language: "tr", Viewbox: []float64{33.2, 35.2, 33.4, 35.4}, CountryCodes: []string{"cy"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 2 {
+		t.Fatalf("got %d places, want 2", len(places))
+	}
+	p := places[0]
+	if p.Name != "Girne Antik Liman" || p.Address["road"] != "Kordon Boyu Sokak" || p.OSMRef() != "N2400167831" {
+		t.Errorf("place = %+v", p)
+	}
+	if lat, lon, err := p.Coordinates(); err != nil || lat != 35.3417614 || lon != 33.3196042 {
+		t.Errorf("coordinates = %v, %v, %v", lat, lon, err)
+	}
+	if got := places[1].OSMRef(); got != "R5702863" {
+		t.Errorf("relation ref = %q", got)
+	}
+
+	r := (*requests)[0]
+	q := r.URL.Query()
+	if q.Get("q") != "girne liman" || q.Get("format") != "jsonv2" || q.Get("addressdetails") != "1" ||
+		q.Get("limit") != "5" || q.Get("viewbox") != "33.2,35.2,33.4,35.4" || q.Get("countrycodes") != "cy" {
+		t.Errorf("query = %v", q)
+	}
+	if r.Header.Get("User-Agent") != defaultUserAgent || r.Header.Get("Accept-Language") != "tr" {
+		t.Errorf("headers = %v", r.Header)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	client, requests := replay(t, http.StatusOK, map[string]string{"/reverse": "reverse_ok.json"})
+	place, err := client.Reverse(context.Background(), 35.1748, 33.3611, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place == nil || place.Address["city"] != "Lefkoşa" || place.OSMRef() != "W168842577" {
+		t.Errorf("place = %+v", place)
+	}
+	if q := (*requests)[0].URL.Query(); q.Get("lat") != "35.1748" || q.Get("lon") != "33.3611" {
+		t.Errorf("query = %v", q)
+	}
+
+	client, _ = replay(t, http.StatusOK, map[string]string{"/reverse": "reverse_unable.json"})
+	place, err = client.Reverse(context.Background(), 35.5, 33.0, "")
+	if err != nil || place != nil {
+		t.Errorf("nothing there: got %+v, %v", place, err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	client, requests := replay(t, http.StatusOK, map[string]string{"/lookup": "lookup_ok.json"})
+	places, err := client.Lookup(context.Background(), []string{"N2400167831", "W1"}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 1 || places[0].OSMRef() != "N2400167831" {
+		t.Errorf("places = %+v", places)
+	}
+	if got := (*requests)[0].URL.Query().Get("osm_ids"); got != "N2400167831,W1" {
+		t.Errorf("osm_ids = %q", got)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		fixture string
+		status  int
+		strict  bool
+		wantErr string
+	}{
+		{fixture: "search_ok.json", status: 200, strict: true},
+		{fixture: "reverse_ok.json", status: 200, strict: true},
+		{fixture: "search_missing_lat.json", status: 200},
+		{fixture: "search_missing_lat.json", status: 200, strict: true, wantErr: "result 0: response schema changed: missing lat"},
+		{fixture: "bad_request.json", status: 400, wantErr: "status 400: Parameter 'lat' expected to be a number."},
+		{fixture: "reverse_unable.json", status: 200, wantErr: "unable to geocode"},
+	}
+	for _, tt := range tests {
+		var v any
+		err := decodeResponse(tt.status, readFixture(t, tt.fixture), &v, tt.strict, placeRequiredFields)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s (strict=%v): got error %v, want %q", tt.fixture, tt.strict, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (strict=%v): unexpected error: %v", tt.fixture, tt.strict, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package nominatim is a client for the Nominatim OpenStreetMap geocoder. It needs no
+// API key, so a self-hosted instance lets the stack geocode without Stadia or Google.
+package nominatim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/util"
+)
+
+// defaultUserAgent identifies the API to Nominatim, whose usage policy requires one.
+const defaultUserAgent = "waze-kibris-api"
+
+// Client talks to a Nominatim server, e.g. a self-hosted http://localhost:8080.
+type Client struct {
+	BaseURL    string
+	UserAgent  string
+	HTTPClient *http.Client
+	// Strict makes responses missing fields we rely on fail instead of decoding to zero values.
+	Strict bool
+}
+
+// NewClient creates a client for the Nominatim server at baseURL. An empty baseURL gives
+// a client that isn't configured.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		UserAgent: defaultUserAgent,
+		HTTPClient: resilient.NewClient(&http.Transport{
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 5 * time.Second,
+		}, resilient.Policy{}),
+	}
+}
+
+// Place is a jsonv2 result with address details.
+type Place struct {
+	PlaceID     int64   `json:"place_id"`
+	OSMType     string  `json:"osm_type"` // "node", "way" or "relation"
+	OSMID       int64   `json:"osm_id"`
+	Lat         string  `json:"lat"` // decimal degrees as a string
+	Lon         string  `json:"lon"`
+	Category    string  `json:"category"` // OSM key, e.g. "amenity"
+	Type        string  `json:"type"`     // OSM value, e.g. "cafe"
+	AddressType string  `json:"addresstype"`
+	Name        string  `json:"name"`
+	DisplayName string  `json:"display_name"`
+	Importance  float64 `json:"importance"`
+	// Address holds the address parts by kind: "road", "house_number", "village",
+	// "town", "city", "state", "country", ...
+	Address map[string]string `json:"address"`
+}
+
+// Coordinates parses the place's position.
+func (p Place) Coordinates() (lat, lon float64, err error) {
+	lat, err = strconv.ParseFloat(p.Lat, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nominatim: bad lat %q", p.Lat)
+	}
+	lon, err = strconv.ParseFloat(p.Lon, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("nominatim: bad lon %q", p.Lon)
+	}
+	return lat, lon, nil
+}
+
+// OSMRef is the place's OSM object in the form /lookup takes, e.g. "N240109189".
+func (p Place) OSMRef() string {
+	if p.OSMType == "" {
+		return ""
+	}
+	return strings.ToUpper(p.OSMType[:1]) + strconv.FormatInt(p.OSMID, 10)
+}
+
+// SearchParams narrows a forward search.
+type SearchParams struct {
+	Limit    int    // 1-40; 0 leaves Nominatim's default of 10
+	Language string // preferred language of names, e.g. "tr"
+	// Viewbox is [minLon, minLat, maxLon, maxLat]. Results inside it rank higher, but
+	// others are still returned.
+	Viewbox []float64
+	// CountryCodes limits results to these ISO 3166-1 alpha-2 countries.
+	CountryCodes []string
+}
+
+// placeRequiredFields are what the API reads from every place; strict mode rejects
+// responses without them.
+var placeRequiredFields = []string{"osm_type", "osm_id", "lat", "lon", "display_name"}
+
+// Search geocodes free-form text, best match first.
+func (c *Client) Search(ctx context.Context, text string, params SearchParams) ([]Place, error) {
+	q := url.Values{}
+	q.Set("q", text)
+	if params.Limit > 0 {
+		q.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if len(params.Viewbox) == 4 {
+		parts := make([]string, 4)
+		for i, v := range params.Viewbox {
+			parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		q.Set("viewbox", strings.Join(parts, ","))
+	}
+	if len(params.CountryCodes) > 0 {
+		q.Set("countrycodes", strings.Join(params.CountryCodes, ","))
+	}
+	var places []Place
+	if err := c.get(ctx, "/search", q, params.Language, &places, placeRequiredFields...); err != nil {
+		return nil, fmt.Errorf("nominatim search: %w", err)
+	}
+	return places, nil
+}
+
+// Reverse returns the address at a point, or nil when there is nothing there (e.g. at
+// sea).
+func (c *Client) Reverse(ctx context.Context, lat, lon float64, language string) (*Place, error) {
+	q := url.Values{}
+	q.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	q.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	var place Place
+	err := c.get(ctx, "/reverse", q, language, &place, placeRequiredFields...)
+	if errors.Is(err, errUnableToGeocode) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("nominatim reverse: %w", err)
+	}
+	return &place, nil
+}
+
+// Lookup returns the places for OSM refs like "N240109189" (see Place.OSMRef). Unknown
+// refs are left out.
+func (c *Client) Lookup(ctx context.Context, refs []string, language string) ([]Place, error) {
+	q := url.Values{}
+	q.Set("osm_ids", strings.Join(refs, ","))
+	var places []Place
+	if err := c.get(ctx, "/lookup", q, language, &places, placeRequiredFields...); err != nil {
+		return nil, fmt.Errorf("nominatim lookup: %w", err)
+	}
+	return places, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, q url.Values, language string, v any, required ...string) error {
+	q.Set("format", "jsonv2")
+	q.Set("addressdetails", "1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	if language != "" {
+		req.Header.Set("Accept-Language", language)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	return decodeResponse(resp.StatusCode, body, v, c.Strict, required)
+}
+
+// errUnableToGeocode is Nominatim's answer when a reverse lookup finds nothing.
+var errUnableToGeocode = errors.New("unable to geocode")
+
+// errorBody is how Nominatim reports a failed request: a bare message for "nothing
+// found", or a code and message for bad requests.
+type errorBody struct {
+	Error json.RawMessage `json:"error"`
+}
+
+// Error is a failed Nominatim request: its error message, or the body of a non-2xx reply.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+// NotFound reports whether the requested place does not exist.
+func (e *Error) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// RateLimited reports whether Nominatim turned the request away for sending too many.
+func (e *Error) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+func decodeResponse(statusCode int, body []byte, v any, strict bool, required []string) error {
+	var eb errorBody
+	if json.Unmarshal(body, &eb) == nil && len(eb.Error) > 0 {
+		var message string
+		if json.Unmarshal(eb.Error, &message) == nil && strings.EqualFold(message, errUnableToGeocode.Error()) {
+			return errUnableToGeocode
+		}
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(eb.Error, &detail) == nil && detail.Message != "" {
+			message = detail.Message
+		}
+		return &Error{StatusCode: statusCode, Message: message}
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return &Error{StatusCode: statusCode, Message: string(body)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if strict && len(required) > 0 {
+		if err := requireFields(body, required); err != nil {
+			return fmt.Errorf("nominatim: %w", err)
+		}
+	}
+	return nil
+}
+
+// requireFields checks the required fields on a single place or on every place of a list.
+func requireFields(body []byte, required []string) error {
+	var items []json.RawMessage
+	if json.Unmarshal(body, &items) != nil {
+		return util.RequireJSONFields(body, required...)
+	}
+	for i, item := range items {
+		if err := util.RequireJSONFields(item, required...); err != nil {
+			return fmt.Errorf("result %d: %w", i, err)
+		}
+	}
+	return nil
+}
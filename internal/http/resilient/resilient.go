@@ -0,0 +1,281 @@
+// Package resilient wraps the HTTP clients of the external map providers with retries
+// and a circuit breaker per host, so one slow or failing upstream costs a handler a
+// bounded amount of time instead of stalling it.
+package resilient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while its host's breaker is
+// open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Policy configures retries, timeouts and the breaker. Zero fields take the defaults.
+type Policy struct {
+	// MaxAttempts counts the first try (default 3).
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for each one after up to
+	// MaxDelay; the actual wait is a random share of it (defaults 100ms and 1s).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AttemptTimeout caps one attempt (default 5s).
+	AttemptTimeout time.Duration
+	// Budget caps all attempts and the waits between them (default 12s). A deadline on
+	// the request's context that comes sooner wins.
+	Budget time.Duration
+	// FailureThreshold failures in a row open a host's breaker for Cooldown (defaults 5
+	// and 30s). After the cooldown one request is let through to probe the host.
+	FailureThreshold int
+	Cooldown         time.Duration
+	// RetryPOST retries POSTs too, for upstreams whose POSTs only read (Valhalla).
+	RetryPOST bool
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = time.Second
+	}
+	if p.AttemptTimeout <= 0 {
+		p.AttemptTimeout = 5 * time.Second
+	}
+	if p.Budget <= 0 {
+		p.Budget = 12 * time.Second
+	}
+	if p.FailureThreshold < 1 {
+		p.FailureThreshold = 5
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 30 * time.Second
+	}
+	return p
+}
+
+// NewClient returns an HTTP client that traces requests (see telemetry.Transport) and
+// retries and breaks circuits by policy. Each attempt is its own span.
+func NewClient(base http.RoundTripper, policy Policy) *http.Client {
+	return &http.Client{Transport: NewTransport(telemetry.Transport(base), policy)}
+}
+
+// Transport is an http.RoundTripper applying a Policy to the requests it sends.
+type Transport struct {
+	base     http.RoundTripper
+	policy   Policy
+	breakers *breakers
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewTransport wraps base (http.DefaultTransport if nil).
+func NewTransport(base http.RoundTripper, policy Policy) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:     base,
+		policy:   policy.withDefaults(),
+		breakers: &breakers{hosts: make(map[string]*breaker)},
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !t.breakers.allow(host, t.now()) {
+		return nil, fmt.Errorf("%s: %w", host, ErrCircuitOpen)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.policy.Budget)
+	attempts := 1
+	if t.retryable(req) {
+		attempts = t.policy.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		resp, cancelAttempt, err := t.try(ctx, req, attempt)
+		if req.Context().Err() != nil {
+			// The caller gave up; that says nothing about the host.
+			t.breakers.release(host)
+			if err == nil {
+				resp.Body.Close()
+			}
+			cancelAttempt()
+			cancel()
+			return nil, req.Context().Err()
+		}
+		failed := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		open := t.breakers.record(host, failed, t.policy, t.now())
+
+		wait := t.backoff(attempt, resp)
+		deadline, _ := ctx.Deadline()
+		if !failed || open || attempt >= attempts || t.now().Add(wait).After(deadline) {
+			if err != nil {
+				cancelAttempt()
+				cancel()
+				return nil, err
+			}
+			// The body is read after RoundTrip returns, so the attempt's and the budget's
+			// contexts live until it is closed.
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() { cancelAttempt(); cancel() }}
+			return resp, nil
+		}
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancelAttempt()
+		if err := t.sleep(ctx, wait); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+}
+
+// try sends one attempt with its own timeout. The returned cancel releases it.
+func (t *Transport) try(ctx context.Context, req *http.Request, attempt int) (*http.Response, context.CancelFunc, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, t.policy.AttemptTimeout)
+	r := req.Clone(attemptCtx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, cancel, fmt.Errorf("rewind request body: %w", err)
+		}
+		r.Body = body
+	}
+	resp, err := t.base.RoundTrip(r)
+	return resp, cancel, err
+}
+
+// retryable reports whether req can safely be sent again: reads, plus POSTs when the
+// policy allows, as long as the body can be replayed.
+func (t *Transport) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return t.policy.RetryPOST
+	}
+	return false
+}
+
+// backoff is the wait before the next attempt: a random share of the exponential
+// delay, or the upstream's Retry-After when that asks for longer.
+func (t *Transport) backoff(attempt int, resp *http.Response) time.Duration {
+	// Doubling stops at MaxDelay, so a large MaxAttempts can't shift the delay past the
+	// largest duration.
+	delay := t.policy.BaseDelay
+	for i := 1; i < attempt && delay < t.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay <= 0 || delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+	wait := time.Duration(rand.Int64N(int64(delay)) + 1)
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			wait = max(wait, time.Duration(s)*time.Second)
+		}
+	}
+	return wait
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+	// probing is set while the one request let through after the cooldown is out.
+	probing bool
+}
+
+type breakers struct {
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+// allow reports whether a request to host may go out. Once the cooldown is over a single
+// probe is let through; the breaker stays open for everything else until it answers.
+func (bs *breakers) allow(host string, now time.Time) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.hosts[host]
+	if !ok || b.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record counts an attempt's outcome and reports whether the host's breaker is open
+// after it. A failed probe opens it again straight away.
+func (bs *breakers) record(host string, failed bool, policy Policy, now time.Time) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.hosts[host]
+	if !ok {
+		b = &breaker{}
+		bs.hosts[host] = b
+	}
+	if !failed {
+		*b = breaker{}
+		return false
+	}
+	b.failures++
+	if b.failures >= policy.FailureThreshold || b.probing {
+		b.openUntil = now.Add(policy.Cooldown)
+		b.probing = false
+	}
+	return !b.openUntil.IsZero()
+}
+
+// release lets another probe through when the one out was abandoned by its caller.
+func (bs *breakers) release(host string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if b, ok := bs.hosts[host]; ok {
+		b.probing = false
+	}
+}
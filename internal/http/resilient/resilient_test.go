@@ -0,0 +1,178 @@
+package resilient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upstream answers each call with the status statuses returns for it, echoing the body.
+func upstream(t *testing.T, statuses func(call int) int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(statuses(n))
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testClient(policy Policy) (*http.Client, *Transport) {
+	tr := NewTransport(nil, policy)
+	tr.sleep = func(context.Context, time.Duration) error { return nil }
+	return &http.Client{Transport: tr}, tr
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	srv, calls := upstream(t, func(call int) int {
+		if call < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	client, _ := testClient(Policy{})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("status %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetriesPOSTOnlyWhenAllowed(t *testing.T) {
+	srv, calls := upstream(t, func(call int) int {
+		if call == 1 {
+			return http.StatusBadGateway
+		}
+		return http.StatusOK
+	})
+
+	client, _ := testClient(Policy{})
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"costing":"auto"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Errorf("POST retried: status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+
+	client, _ = testClient(Policy{RetryPOST: true})
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader(`{"costing":"auto"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"costing":"auto"}` {
+		t.Errorf("retried POST: status %d, body %q", resp.StatusCode, body)
+	}
+}
+
+func TestAttemptTimeoutRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	client, _ := testClient(Policy{AttemptTimeout: 50 * time.Millisecond})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" || calls.Load() != 2 {
+		t.Errorf("body %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestBudgetStopsRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	client, _ := testClient(Policy{Budget: time.Second})
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("waited past the budget: status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	srv, calls := upstream(t, func(int) int { return status })
+	client, tr := testClient(Policy{MaxAttempts: 1, FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	get := func() error {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	get()
+	get()
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after 2 failures: err = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("open breaker let a call through: %d calls", calls.Load())
+	}
+
+	// A failed probe opens the breaker again straight away.
+	now = now.Add(time.Minute)
+	if err := get(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed probe: err = %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	status = http.StatusOK
+	for i := 0; i < 3; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("after recovery: %v", err)
+		}
+	}
+}
+
+func TestBackoffStaysWithinMaxDelay(t *testing.T) {
+	tr := NewTransport(nil, Policy{MaxAttempts: 100, BaseDelay: time.Second, MaxDelay: time.Duration(1<<62 + 1)})
+	for _, attempt := range []int{1, 2, 40, 63, 64, 99} {
+		if wait := tr.backoff(attempt, nil); wait <= 0 || wait > tr.policy.MaxDelay {
+			t.Errorf("attempt %d: backoff %v outside (0, %v]", attempt, wait, tr.policy.MaxDelay)
+		}
+	}
+}
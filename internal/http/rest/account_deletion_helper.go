@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	// accountDeletionGrace is how long a deactivated account can still be reactivated by
+	// logging in before it is purged.
+	accountDeletionGrace = 30 * 24 * time.Hour
+	accountPurgeInterval = time.Hour
+	accountPurgeBatch    = 50
+)
+
+// ErrAccountDeactivated rejects requests from an account that is waiting to be deleted.
+var ErrAccountDeactivated = errors.New("account deactivated")
+
+// DeactivateAccountHelper starts deleting an account. It is signed out everywhere and
+// purged after accountDeletionGrace unless the user logs in again first.
+func (api *API) DeactivateAccountHelper(ctx context.Context, userID string) (model.AccountDeactivation, string, string, error) {
+	if userID == anonymousUserID.String() {
+		return model.AccountDeactivation{}, values.NotAllowed, "This account can't be deleted", errors.New("anonymous user")
+	}
+	at, err := api.DeactivateUserRepo(ctx, userID)
+	if err != nil {
+		return model.AccountDeactivation{}, errorStatus(err, values.Error), "Failed to delete account", err
+	}
+	return model.AccountDeactivation{
+		DeactivatedAt: at,
+		PurgeAfter:    at.Add(accountDeletionGrace),
+	}, values.Success, "Account deactivated; it will be deleted in 30 days unless you log in again", nil
+}
+
+// reactivateOnLogin cancels a pending deletion when its owner logs in again. A failure
+// is logged rather than failing the login; the account stays deactivated and RequireLogin
+// keeps turning its requests away.
+func (api *API) reactivateOnLogin(ctx context.Context, userID string) bool {
+	reactivated, err := api.ReactivateUserRepo(ctx, userID)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Reactivating account failed", "user_id", userID, "err", err)
+		return false
+	}
+	if reactivated {
+		api.logger().InfoContext(ctx, "Account reactivated", "user_id", userID)
+	}
+	return reactivated
+}
+
+// RunAccountPurger deletes accounts whose grace period has run out every hour until ctx
+// is cancelled.
+func (api *API) RunAccountPurger(ctx context.Context) {
+	ticker := time.NewTicker(accountPurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if api.dbAvailable() {
+				api.purgeDeactivatedAccounts(ctx)
+			}
+		}
+	}
+}
+
+func (api *API) purgeDeactivatedAccounts(ctx context.Context) {
+	before := time.Now().Add(-accountDeletionGrace)
+	ids, err := api.ListPurgeableUsersRepo(ctx, before, accountPurgeBatch)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Listing deactivated accounts failed", "err", err)
+		return
+	}
+	purged := 0
+	for _, id := range ids {
+		err := api.PurgeUserRepo(ctx, id, before)
+		if errors.Is(err, ErrNotFound) {
+			continue // reactivated since it was listed
+		}
+		if err != nil {
+			// The account stays deactivated, so the next run retries it.
+			api.logger().ErrorContext(ctx, "Purging account failed", "user_id", id, "err", err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		api.logger().InfoContext(ctx, "Purged deactivated accounts", "count", purged)
+	}
+}
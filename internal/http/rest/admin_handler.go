@@ -0,0 +1,981 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (api *API) AdminRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	// Report review, open to moderators as well as admins
+	// Query Params (GET /reports): ?status=PENDING&limit=50
+	// Request Body (approve, reject): { "reason": "..." }
+	// Request Body (merge): { "into_report_id": 123, "reason": "..." }
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		r.Use(api.RequirePermission(PermModerateReports))
+
+		r.Method(http.MethodGet, "/reports", Handler(api.ListReportsForModerationHandler))
+		r.Method(http.MethodPost, "/reports/{reportID}/approve", Handler(api.ApproveReportHandler))
+		r.Method(http.MethodPost, "/reports/{reportID}/reject", Handler(api.RejectReportHandler))
+		r.Method(http.MethodPost, "/reports/{reportID}/merge", Handler(api.MergeReportHandler))
+		r.Method(http.MethodGet, "/reports/{reportID}/actions", Handler(api.ListReportModerationActionsHandler))
+	})
+
+	// Accounts flagged for teleporting, impossible headings or emulators
+	// Query Params (GET): ?status=pending&limit=50
+	// Request Body (PUT): { "status": "confirmed" } or { "status": "dismissed" }
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		r.Use(api.RequirePermission(PermReviewSuspiciousActivity))
+
+		r.Method(http.MethodGet, "/suspicious-activity", Handler(api.ListSuspiciousActivityFlagsHandler))
+		r.Method(http.MethodPut, "/suspicious-activity/{id}", Handler(api.ResolveSuspiciousActivityFlagHandler))
+	})
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		r.Use(api.RequireRole(values.RoleAdmin))
+
+		// Report broadcast radius policy (by type and severity)
+		r.Method(http.MethodGet, "/broadcast-policies", Handler(api.ListBroadcastPoliciesHandler))
+		r.Method(http.MethodPut, "/broadcast-policies", Handler(api.UpsertBroadcastPolicyHandler))
+		r.Method(http.MethodDelete, "/broadcast-policies/{id}", Handler(api.DeleteBroadcastPolicyHandler))
+
+		// Default lifetime of new reports (by type and subtype)
+		// Request Body (PUT): { "report_type": "POLICE", "subtype": "HIDDEN", "ttl_minutes": 120 }
+		r.Method(http.MethodGet, "/expiry-policies", Handler(api.ListExpiryPoliciesHandler))
+		r.Method(http.MethodPut, "/expiry-policies", Handler(api.UpsertExpiryPolicyHandler))
+		r.Method(http.MethodDelete, "/expiry-policies/{id}", Handler(api.DeleteExpiryPolicyHandler))
+
+		// Names of places known by several names, per language (Nicosia / Lefkoşa / Λευκωσία)
+		// Request Body (PUT): { "key": "nicosia", "names": { "en": "Nicosia", "tr": "Lefkoşa", "el": "Λευκωσία" },
+		//   "gids": ["whosonfirst:locality:101752311"] }
+		r.Method(http.MethodGet, "/place-aliases", Handler(api.ListPlaceAliasesHandler))
+		r.Method(http.MethodPut, "/place-aliases", Handler(api.UpsertPlaceAliasHandler))
+		r.Method(http.MethodDelete, "/place-aliases/{id}", Handler(api.DeletePlaceAliasHandler))
+
+		// Local POIs behind /places/prefetch, upserted by (source, source_id)
+		// Request Body (PUT): { "pois": [{ "source": "osm", "source_id": "node/123", "name": "Petrol Ofisi",
+		//   "category": "fuel", "latitude": 35.19, "longitude": 33.36 }] }
+		r.Method(http.MethodPut, "/pois", Handler(api.UpsertPOIsHandler))
+		r.Method(http.MethodDelete, "/pois/{id}", Handler(api.DeletePOIHandler))
+
+		// Report image moderation queue and appeals
+		r.Method(http.MethodGet, "/image-moderations", Handler(api.ListImageModerationsHandler))
+		r.Method(http.MethodPut, "/image-moderations/{id}", Handler(api.ReviewImageModerationHandler))
+
+		// Anonymized historical report exports for local authorities
+		r.Method(http.MethodPost, "/report-exports", Handler(api.CreateReportExportHandler))
+		r.Method(http.MethodGet, "/report-exports", Handler(api.ListReportExportsHandler))
+		r.Method(http.MethodGet, "/report-exports/{id}", Handler(api.GetReportExportHandler))
+
+		// Dashboard aggregates over the latest days (UTC), today included
+		// Query Params: ?days=30 (max 365); hotspots also take &cell=0.01 (degrees) &limit=50
+		r.Method(http.MethodGet, "/analytics/reports", Handler(api.ReportsPerDayHandler))
+		r.Method(http.MethodGet, "/analytics/active-users", Handler(api.ActiveUsersHandler))
+		r.Method(http.MethodGet, "/analytics/hotspots", Handler(api.ReportHotspotsHandler))
+		r.Method(http.MethodGet, "/analytics/report-lifetimes", Handler(api.ReportLifetimesHandler))
+		r.Method(http.MethodGet, "/analytics/external-usage", Handler(api.ExternalAPIUsageHandler))
+
+		// Runtime state: database mode and geocoding provider health
+		r.Method(http.MethodGet, "/ops", Handler(api.OpsStatusHandler))
+
+		// Sampled routes re-run on the other provider, for routing-quality monitoring
+		// Query Params: ?hours=24
+		r.Method(http.MethodGet, "/route-audits", Handler(api.RouteAuditSummaryHandler))
+
+		// Valhalla tileset age, and a rebuild request for the tile pipeline (via the outbox webhook)
+		// Request Body (rebuild): { "force": bool, "reason": "..." }
+		r.Method(http.MethodGet, "/valhalla/tiles", Handler(api.GetValhallaTileStatusHandler))
+		r.Method(http.MethodPost, "/valhalla/tiles/rebuild", Handler(api.TriggerValhallaRebuildHandler))
+
+		// Per-user API quota tier (free, power, partner) and today's usage
+		// Request Body (PUT): { "tier": "power" }
+		r.Method(http.MethodGet, "/users/{userID}/quota", Handler(api.GetUserQuotaHandler))
+		r.Method(http.MethodPut, "/users/{userID}/quota", Handler(api.UpdateUserQuotaTierHandler))
+
+		// Curated arrival points (entrance, parking, drop-off) that routes to a destination end at
+		// Request Body (POST): { "name": "...", "place_id": "...", "latitude": 35.1, "longitude": 33.3,
+		//   "radius_m": 150, "arrival_lat": 35.1, "arrival_lng": 33.3, "kind": "entrance", "notes": "..." }
+		r.Method(http.MethodGet, "/destination-overrides", Handler(api.ListDestinationOverridesHandler))
+		r.Method(http.MethodPost, "/destination-overrides", Handler(api.CreateDestinationOverrideHandler))
+		r.Method(http.MethodDelete, "/destination-overrides/{id}", Handler(api.DeleteDestinationOverrideHandler))
+
+		// Permanent road features drivers are warned about: speed cameras, speed bumps, railway crossings
+		// Query Params (GET): ?type=speed_camera&page=1&pageSize=50
+		// Request Body (POST, PUT): { "type": "speed_camera", "latitude": 35.18, "longitude": 33.36,
+		//   "bearing": 90, "speed_limit_kmh": 50, "name": "...", "notes": "...", "active": true }
+		r.Method(http.MethodGet, "/map-features", Handler(api.ListMapFeaturesHandler))
+		r.Method(http.MethodPost, "/map-features", Handler(api.CreateMapFeatureHandler))
+		r.Method(http.MethodPut, "/map-features/{id}", Handler(api.UpdateMapFeatureHandler))
+		r.Method(http.MethodDelete, "/map-features/{id}", Handler(api.DeleteMapFeatureHandler))
+
+		// User-reported base map errors: triage, then export accepted ones as OSM notes
+		// Query Params (GET): ?status=open&limit=50
+		// Request Body (PUT): { "status": "accepted", "admin_note": "..." }
+		// Request Body (export): { "ids": [1, 2] } or {} for every accepted issue
+		r.Method(http.MethodGet, "/map-issues", Handler(api.ListMapIssuesHandler))
+		r.Method(http.MethodPut, "/map-issues/{id}", Handler(api.UpdateMapIssueStatusHandler))
+		r.Method(http.MethodPost, "/map-issues/osm-notes", Handler(api.ExportMapIssuesHandler))
+
+		// EXPLAIN ANALYZE on a report geo query, only with DEBUG_ENDPOINTS set
+		// Request Body: { "query": "nearby", "latitude": 35.18, "longitude": 33.36, "radius": 1000 }
+		//           or: { "query": "viewport", "min_lat": 35.1, "min_lng": 33.3, "max_lat": 35.2, "max_lng": 33.4 }
+		//           or: { "query": "clusters", "zoom": 10, ...bbox }, { "query": "route_hazards", ...bbox }
+		if api.Config.DebugEndpoints {
+			r.Method(http.MethodPost, "/debug/explain", Handler(api.ExplainGeoQueryHandler))
+		}
+	})
+
+	return mux
+}
+
+func (api *API) ListBroadcastPoliciesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	policies, status, message, err := api.ListBroadcastPoliciesHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       policies,
+	}
+}
+
+func (api *API) UpsertBroadcastPolicyHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpsertBroadcastPolicyRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	policy, status, message, err := api.UpsertBroadcastPolicyHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       policy,
+	}
+}
+
+func (api *API) DeleteBroadcastPolicyHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeleteBroadcastPolicyHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListExpiryPoliciesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	policies, status, message, err := api.ListExpiryPoliciesHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       policies,
+	}
+}
+
+func (api *API) UpsertExpiryPolicyHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpsertExpiryPolicyRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	policy, status, message, err := api.UpsertExpiryPolicyHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       policy,
+	}
+}
+
+func (api *API) DeleteExpiryPolicyHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeleteExpiryPolicyHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListPlaceAliasesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	aliases, status, message, err := api.ListPlaceAliasesHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       aliases,
+	}
+}
+
+func (api *API) UpsertPlaceAliasHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpsertPlaceAliasRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	alias, status, message, err := api.UpsertPlaceAliasHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       alias,
+	}
+}
+
+func (api *API) DeletePlaceAliasHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeletePlaceAliasHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) UpsertPOIsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req model.UpsertPOIsRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	result, status, message, err := api.UpsertPOIsHelper(r.Context(), req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       result,
+	}
+}
+
+func (api *API) DeletePOIHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeletePOIHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) OpsStatusHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	return &ServerResponse{
+		Message:    "Ops status fetched successfully",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+		Data: model.OpsStatus{
+			Database:           dbStateName(api.dbHealth.state.Load()),
+			GeocodingPinned:    api.geocodingPin(),
+			GeocodingProviders: api.geocoderHealthReport(),
+		},
+	}
+}
+
+func (api *API) ListImageModerationsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	q := r.URL.Query()
+	moderations, status, message, err := api.ListImageModerationsHelper(r.Context(), q.Get("decision"), q.Get("limit"))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       moderations,
+	}
+}
+
+func (api *API) ReviewImageModerationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	var req model.ReviewImageModerationRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	status, message, err := api.ReviewImageModerationHelper(r.Context(), id, req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListSuspiciousActivityFlagsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	q := r.URL.Query()
+	flags, status, message, err := api.ListSuspiciousActivityFlagsHelper(r.Context(), q.Get("status"), q.Get("limit"))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       flags,
+	}
+}
+
+func (api *API) ResolveSuspiciousActivityFlagHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reviewerID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	var req model.ResolveSuspiciousActivityRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	status, message, err := api.ResolveSuspiciousActivityFlagHelper(r.Context(), id, req, reviewerID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) CreateReportExportHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.CreateReportExportRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	export, status, message, err := api.CreateReportExportHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: http.StatusAccepted,
+		Data:       export,
+	}
+}
+
+func (api *API) ListReportExportsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	exports, status, message, err := api.ListReportExportsHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       exports,
+	}
+}
+
+// GetReportExportHandler reports a job's progress; file_url is the download link once status is done.
+func (api *API) GetReportExportHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	export, status, message, err := api.GetReportExportHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       export,
+	}
+}
+
+func (api *API) RouteAuditSummaryHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	summary, status, message, err := api.RouteAuditSummaryHelper(r.Context(), r.URL.Query().Get("hours"))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       summary,
+	}
+}
+
+func (api *API) GetValhallaTileStatusHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	tiles, status, message, err := api.GetValhallaTileStatusHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       tiles,
+	}
+}
+
+func (api *API) TriggerValhallaRebuildHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.TriggerValhallaRebuildRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	tiles, status, message, err := api.TriggerValhallaRebuildHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: http.StatusAccepted,
+		Data:       tiles,
+	}
+}
+
+func (api *API) GetUserQuotaHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		return respondWithError(err, "invalid user ID format", values.BadRequestBody, &tc)
+	}
+
+	quota, status, message, err := api.GetUserQuotaHelper(r.Context(), userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       quota,
+	}
+}
+
+func (api *API) UpdateUserQuotaTierHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		return respondWithError(err, "invalid user ID format", values.BadRequestBody, &tc)
+	}
+
+	var req model.UpdateQuotaTierRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	quota, status, message, err := api.UpdateUserQuotaTierHelper(r.Context(), userID, req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    "Quota tier updated",
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       quota,
+	}
+}
+
+func (api *API) ListDestinationOverridesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	overrides, status, message, err := api.ListDestinationOverridesHelper(r.Context())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       overrides,
+	}
+}
+
+func (api *API) CreateDestinationOverrideHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.CreateDestinationOverrideRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	override, status, message, err := api.CreateDestinationOverrideHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       override,
+	}
+}
+
+func (api *API) DeleteDestinationOverrideHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeleteDestinationOverrideHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListMapFeaturesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	pageSize, _ := strconv.Atoi(q.Get("pageSize"))
+
+	features, status, message, err := api.ListMapFeaturesHelper(r.Context(), q.Get("type"), page, pageSize)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       features,
+	}
+}
+
+func (api *API) CreateMapFeatureHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	adminID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.MapFeatureRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	feature, status, message, err := api.CreateMapFeatureHelper(r.Context(), req, adminID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       feature,
+	}
+}
+
+func (api *API) UpdateMapFeatureHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	var req model.MapFeatureRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	feature, status, message, err := api.UpdateMapFeatureHelper(r.Context(), id, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       feature,
+	}
+}
+
+func (api *API) DeleteMapFeatureHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeleteMapFeatureHelper(r.Context(), id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ExplainGeoQueryHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req model.ExplainGeoQueryRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	explain, status, message, err := api.ExplainGeoQueryHelper(r.Context(), req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       explain,
+	}
+}
+
+func (api *API) ListReportsForModerationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	q := r.URL.Query()
+	reports, status, message, err := api.ListReportsForModerationHelper(r.Context(), q.Get("status"), q.Get("limit"))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       reports,
+	}
+}
+
+// moderationParams reads the report ID from the path and the moderator from the context.
+func moderationParams(r *http.Request, tc *tracing.Context) (int64, uuid.UUID, *ServerResponse) {
+	moderatorID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return 0, uuid.Nil, respondWithError(err, "unable to get user ID from context", values.NotAuthorised, tc)
+	}
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return 0, uuid.Nil, respondWithError(err, "invalid report ID", values.BadRequestBody, tc)
+	}
+	return reportID, moderatorID, nil
+}
+
+func (api *API) ApproveReportHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	return api.moderateReport(r, api.ApproveReportHelper)
+}
+
+func (api *API) RejectReportHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	return api.moderateReport(r, api.RejectReportHelper)
+}
+
+func (api *API) moderateReport(r *http.Request, helper func(context.Context, int64, uuid.UUID, model.ModerateReportRequest) (model.ReportModerationAction, string, string, error)) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, moderatorID, resp := moderationParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	var req model.ModerateReportRequest
+	if r.ContentLength != 0 {
+		if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+			return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+		}
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	action, status, message, err := helper(r.Context(), reportID, moderatorID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       action,
+	}
+}
+
+func (api *API) MergeReportHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, moderatorID, resp := moderationParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	var req model.MergeReportRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	action, status, message, err := api.MergeReportHelper(r.Context(), reportID, moderatorID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       action,
+	}
+}
+
+func (api *API) ListReportModerationActionsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
+
+	actions, status, message, err := api.ListReportModerationActionsHelper(r.Context(), reportID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       actions,
+	}
+}
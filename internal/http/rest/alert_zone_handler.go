@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (api *API) ListAlertZones(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	zones, status, message, err := api.ListAlertZonesHelper(r.Context(), userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       zones,
+	}
+}
+
+func (api *API) CreateAlertZone(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.AlertZoneRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	zone, status, message, err := api.CreateAlertZoneHelper(r.Context(), userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       zone,
+	}
+}
+
+func (api *API) UpdateAlertZone(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	zoneID, err := uuid.Parse(chi.URLParam(r, "zoneID"))
+	if err != nil {
+		return respondWithError(err, "invalid alert zone ID", values.BadRequestBody, &tc)
+	}
+
+	var req model.AlertZoneRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	zone, status, message, err := api.UpdateAlertZoneHelper(r.Context(), userID, zoneID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       zone,
+	}
+}
+
+func (api *API) DeleteAlertZone(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	zoneID, err := uuid.Parse(chi.URLParam(r, "zoneID"))
+	if err != nil {
+		return respondWithError(err, "invalid alert zone ID", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.DeleteAlertZoneHelper(r.Context(), userID, zoneID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
@@ -0,0 +1,232 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/internal/notifications"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
+)
+
+// Alert zone kinds.
+const (
+	AlertZonePolygon  = "polygon"
+	AlertZoneCorridor = "corridor"
+)
+
+const (
+	maxAlertZonesPerUser          = 10
+	defaultAlertZoneCorridorWidth = 200
+	alertZonePushType             = "report.alert_zone"
+)
+
+// alertZoneInput is a validated AlertZoneRequest, ready to store. Shape is GeoJSON.
+type alertZoneInput struct {
+	Name           string
+	Kind           string
+	Shape          string
+	CorridorWidthM *int
+	ReportTypes    []string
+	MinSeverity    int
+	Active         bool
+}
+
+// alertZoneFromRequest checks the zone geometry and fills in defaults. Coordinates are
+// normalized like every other client position, and an open polygon ring is closed.
+func alertZoneFromRequest(req model.AlertZoneRequest) (alertZoneInput, error) {
+	if (req.Polygon == nil) == (req.Route == nil) {
+		return alertZoneInput{}, errors.New("set exactly one of polygon or route")
+	}
+	z := alertZoneInput{
+		Name:        req.Name,
+		ReportTypes: req.ReportTypes,
+		MinSeverity: req.MinSeverity,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if z.ReportTypes == nil {
+		z.ReportTypes = []string{}
+	}
+	if z.MinSeverity == 0 {
+		z.MinSeverity = 1
+	}
+
+	var geometry map[string]any
+	if req.Route != nil {
+		if req.CorridorWidthM == 0 {
+			req.CorridorWidthM = defaultAlertZoneCorridorWidth
+		}
+		coords, err := normalizeLngLats(req.Route)
+		if err != nil {
+			return alertZoneInput{}, err
+		}
+		z.Kind = AlertZoneCorridor
+		z.CorridorWidthM = &req.CorridorWidthM
+		geometry = map[string]any{"type": "LineString", "coordinates": coords}
+	} else {
+		if req.CorridorWidthM != 0 {
+			return alertZoneInput{}, errors.New("corridor_width_m only applies to routes")
+		}
+		ring, err := normalizeLngLats(req.Polygon)
+		if err != nil {
+			return alertZoneInput{}, err
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			ring = append(ring, first)
+		}
+		if len(ring) < 4 {
+			return alertZoneInput{}, errors.New("polygon needs at least three distinct points")
+		}
+		z.Kind = AlertZonePolygon
+		geometry = map[string]any{"type": "Polygon", "coordinates": [][][]float64{ring}}
+	}
+
+	b, err := json.Marshal(geometry)
+	if err != nil {
+		return alertZoneInput{}, err
+	}
+	z.Shape = string(b)
+	return z, nil
+}
+
+// normalizeLngLats normalizes [lng, lat] pairs with util.NormalizeCoordinate.
+func normalizeLngLats(coords [][]float64) ([][]float64, error) {
+	out := make([][]float64, len(coords))
+	for i, c := range coords {
+		if len(c) != 2 {
+			return nil, errors.New("coordinates must be [lng, lat] pairs")
+		}
+		lat, lng, err := util.NormalizeCoordinate(c[1], c[0])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = []float64{lng, lat}
+	}
+	return out, nil
+}
+
+func (api *API) ListAlertZonesHelper(ctx context.Context, userID uuid.UUID) ([]model.AlertZone, string, string, error) {
+	zones, err := api.ListAlertZonesRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch alert zones", err
+	}
+	if zones == nil {
+		zones = []model.AlertZone{}
+	}
+	return zones, values.Success, "Alert zones fetched successfully", nil
+}
+
+func (api *API) CreateAlertZoneHelper(ctx context.Context, userID uuid.UUID, req model.AlertZoneRequest) (model.AlertZone, string, string, error) {
+	z, err := alertZoneFromRequest(req)
+	if err != nil {
+		return model.AlertZone{}, values.BadRequestBody, err.Error(), err
+	}
+	zone, err := api.CreateAlertZoneRepo(ctx, userID, z, maxAlertZonesPerUser)
+	if errors.Is(err, ErrAlertZoneLimit) {
+		return model.AlertZone{}, values.Conflict, "You can watch at most " + strconv.Itoa(maxAlertZonesPerUser) + " alert zones", err
+	}
+	if err != nil {
+		return model.AlertZone{}, errorStatus(err, values.Error), "Failed to create alert zone", err
+	}
+	return zone, values.Created, "Alert zone created", nil
+}
+
+func (api *API) UpdateAlertZoneHelper(ctx context.Context, userID, zoneID uuid.UUID, req model.AlertZoneRequest) (model.AlertZone, string, string, error) {
+	z, err := alertZoneFromRequest(req)
+	if err != nil {
+		return model.AlertZone{}, values.BadRequestBody, err.Error(), err
+	}
+	zone, err := api.UpdateAlertZoneRepo(ctx, zoneID, userID, z)
+	if err != nil {
+		return model.AlertZone{}, errorStatus(err, values.Error), "Failed to update alert zone", err
+	}
+	return zone, values.Success, "Alert zone updated", nil
+}
+
+func (api *API) DeleteAlertZoneHelper(ctx context.Context, userID, zoneID uuid.UUID) (string, string, error) {
+	if err := api.DeleteAlertZoneRepo(ctx, zoneID, userID); err != nil {
+		return errorStatus(err, values.Error), "Failed to delete alert zone", err
+	}
+	return values.Success, "Alert zone deleted", nil
+}
+
+// notifyAlertZones tells the users watching a zone a new report landed in about it, over
+// their WebSocket connection and by push. It runs after pushReportAlerts so users already
+// alerted about the report nearby aren't alerted again. Like nearby alerts, recipients
+// are claimed before sending, so a failed send is not retried.
+func (api *API) notifyAlertZones(ctx context.Context, eventID string, p model.ReportCreatedEvent) error {
+	r := p.Report
+	matches, err := api.ClaimAlertZoneMatchesRepo(ctx, r, p.Severity)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+
+	label := reportAlertLabel(r)
+	reportID := strconv.FormatInt(r.ID, 10)
+	for _, m := range matches {
+		content, err := json.Marshal(map[string]any{
+			"zone_id":   m.ZoneID,
+			"zone_name": m.ZoneName,
+			"report": websockets.ReportUpdatePayload{
+				ID:        r.ID,
+				UserID:    r.UserID.String(),
+				Type:      r.Type,
+				Latitude:  r.Latitude,
+				Longitude: r.Longitude,
+				Active:    r.Active,
+				Resolved:  r.Resolved,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		raw, err := json.Marshal(websockets.Message{
+			Type:    websockets.MsgTypeAlertZoneReport,
+			UserID:  m.UserID.String(),
+			Content: string(content),
+			EventID: eventID,
+		})
+		if err != nil {
+			return err
+		}
+		api.Deps.WebSocket.SendToUser(m.UserID.String(), raw)
+
+		if !api.Notifier.Enabled() {
+			continue
+		}
+		tokens, err := api.GetFCMTokensForUsersRepo(ctx, []uuid.UUID{m.UserID})
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Alert zone: failed to load tokens", "report_id", r.ID, "recipient_id", m.UserID, "err", err)
+			continue
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		stale, failed, err := api.Notifier.Send(ctx, tokens, notifications.Notification{
+			Title: label + " on " + m.ZoneName,
+			Body:  "A new report is inside one of your alert zones.",
+			Data: map[string]string{
+				"type":      alertZonePushType,
+				"report_id": reportID,
+				"zone_id":   m.ZoneID.String(),
+				"latitude":  strconv.FormatFloat(r.Latitude, 'f', 6, 64),
+				"longitude": strconv.FormatFloat(r.Longitude, 'f', 6, 64),
+				"event_id":  eventID,
+			},
+			CollapseKey: "report-" + reportID,
+		})
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Alert zone push failed", "report_id", r.ID, "recipient_id", m.UserID, "err", err)
+		} else if failed > 0 {
+			api.logger().WarnContext(ctx, "Alert zone: sends failed", "report_id", r.ID, "failed", failed, "tokens", len(tokens))
+		}
+		api.forgetStaleTokens(ctx, stale)
+	}
+	return nil
+}
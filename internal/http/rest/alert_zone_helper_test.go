@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestAlertZoneFromRequest(t *testing.T) {
+	route := [][]float64{{33.36, 35.18}, {33.37, 35.19}}
+	triangle := [][]float64{{33.36, 35.18}, {33.37, 35.18}, {33.37, 35.19}}
+
+	z, err := alertZoneFromRequest(model.AlertZoneRequest{Name: "Commute", Route: route})
+	if err != nil {
+		t.Fatalf("route: %v", err)
+	}
+	if z.Kind != AlertZoneCorridor || z.CorridorWidthM == nil || *z.CorridorWidthM != defaultAlertZoneCorridorWidth {
+		t.Errorf("route zone = %+v, want a corridor of the default width", z)
+	}
+	if z.MinSeverity != 1 || !z.Active || z.ReportTypes == nil {
+		t.Errorf("route zone defaults = %+v", z)
+	}
+
+	z, err = alertZoneFromRequest(model.AlertZoneRequest{Name: "Home", Polygon: triangle})
+	if err != nil {
+		t.Fatalf("polygon: %v", err)
+	}
+	if z.Kind != AlertZonePolygon || z.CorridorWidthM != nil {
+		t.Errorf("polygon zone = %+v", z)
+	}
+	// The ring is closed for the client.
+	if !strings.Contains(z.Shape, `[[[33.36,35.18],[33.37,35.18],[33.37,35.19],[33.36,35.18]]]`) {
+		t.Errorf("polygon shape = %s, want a closed ring", z.Shape)
+	}
+
+	for name, req := range map[string]model.AlertZoneRequest{
+		"neither":            {Name: "x"},
+		"both":               {Name: "x", Route: route, Polygon: triangle},
+		"polygon with width": {Name: "x", Polygon: triangle, CorridorWidthM: 100},
+		"degenerate polygon": {Name: "x", Polygon: [][]float64{{33.36, 35.18}, {33.37, 35.18}, {33.36, 35.18}}},
+		"null island":        {Name: "x", Route: [][]float64{{0, 0}, {33.37, 35.19}}},
+	} {
+		if _, err := alertZoneFromRequest(req); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAlertZoneNotFound = fmt.Errorf("alert zone %w", ErrNotFound)
+	ErrAlertZoneLimit    = fmt.Errorf("alert zone limit reached: %w", ErrConflict)
+)
+
+const alertZoneColumns = `
+	id, name, kind, ST_AsGeoJSON(shape), corridor_width_m, report_types, min_severity,
+	active, created_at, updated_at
+`
+
+func scanAlertZone(row pgx.Row) (model.AlertZone, error) {
+	var z model.AlertZone
+	var shape string
+	err := row.Scan(&z.ID, &z.Name, &z.Kind, &shape, &z.CorridorWidthM, &z.ReportTypes, &z.MinSeverity,
+		&z.Active, &z.CreatedAt, &z.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.AlertZone{}, ErrAlertZoneNotFound
+	}
+	if err != nil {
+		return model.AlertZone{}, err
+	}
+	if z.Kind == AlertZoneCorridor {
+		var line struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		}
+		err = json.Unmarshal([]byte(shape), &line)
+		z.Route = line.Coordinates
+	} else {
+		var polygon struct {
+			Coordinates [][][]float64 `json:"coordinates"`
+		}
+		err = json.Unmarshal([]byte(shape), &polygon)
+		if len(polygon.Coordinates) > 0 {
+			z.Polygon = polygon.Coordinates[0]
+		}
+	}
+	if err != nil {
+		return model.AlertZone{}, fmt.Errorf("decoding alert zone shape: %w", err)
+	}
+	return z, nil
+}
+
+func (api *API) ListAlertZonesRepo(ctx context.Context, userID uuid.UUID) ([]model.AlertZone, error) {
+	query := `SELECT ` + alertZoneColumns + ` FROM user_alert_zones WHERE user_id = $1 ORDER BY created_at`
+	rows, err := api.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying alert zones: %w", err)
+	}
+	defer rows.Close()
+
+	var zones []model.AlertZone
+	for rows.Next() {
+		z, err := scanAlertZone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning alert zone: %w", err)
+		}
+		zones = append(zones, z)
+	}
+	return zones, rows.Err()
+}
+
+// CreateAlertZoneRepo saves a zone unless the user already has maxZones, in which case it
+// returns ErrAlertZoneLimit. shape is GeoJSON, a Polygon or, for corridors, a LineString.
+func (api *API) CreateAlertZoneRepo(ctx context.Context, userID uuid.UUID, z alertZoneInput, maxZones int) (model.AlertZone, error) {
+	query := `
+        WITH zone AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($4), 4326) AS shape
+        )
+        INSERT INTO user_alert_zones (
+            user_id, name, kind, shape, area, corridor_width_m, report_types, min_severity, active
+        )
+        SELECT $1, $2, $3, zone.shape,
+            CASE WHEN $3 = 'corridor' THEN ST_Buffer(zone.shape::geography, $5::int)::geometry
+                 ELSE zone.shape END,
+            $5, $6, $7, $8
+        FROM zone
+        WHERE (SELECT COUNT(*) FROM user_alert_zones WHERE user_id = $1) < $9
+        RETURNING ` + alertZoneColumns
+	zone, err := scanAlertZone(api.DB.QueryRow(ctx, query,
+		userID, z.Name, z.Kind, z.Shape, z.CorridorWidthM, z.ReportTypes, z.MinSeverity, z.Active, maxZones))
+	if errors.Is(err, ErrAlertZoneNotFound) {
+		return model.AlertZone{}, ErrAlertZoneLimit
+	}
+	return zone, dbError(err)
+}
+
+// UpdateAlertZoneRepo replaces a zone the user owns, recomputing its area.
+func (api *API) UpdateAlertZoneRepo(ctx context.Context, id, userID uuid.UUID, z alertZoneInput) (model.AlertZone, error) {
+	query := `
+        WITH zone AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($5), 4326) AS shape
+        )
+        UPDATE user_alert_zones
+        SET name = $3, kind = $4, shape = zone.shape,
+            area = CASE WHEN $4 = 'corridor' THEN ST_Buffer(zone.shape::geography, $6::int)::geometry
+                        ELSE zone.shape END,
+            corridor_width_m = $6, report_types = $7, min_severity = $8, active = $9,
+            updated_at = NOW()
+        FROM zone
+        WHERE id = $1 AND user_id = $2
+        RETURNING ` + alertZoneColumns
+	zone, err := scanAlertZone(api.DB.QueryRow(ctx, query,
+		id, userID, z.Name, z.Kind, z.Shape, z.CorridorWidthM, z.ReportTypes, z.MinSeverity, z.Active))
+	return zone, dbError(err)
+}
+
+func (api *API) DeleteAlertZoneRepo(ctx context.Context, id, userID uuid.UUID) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM user_alert_zones WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlertZoneNotFound
+	}
+	return nil
+}
+
+// ClaimAlertZoneMatchesRepo picks the users with an active zone containing a new report
+// whose type and severity the zone accepts, one zone per user (their oldest). Users
+// already pushed a nearby alert for the report are left out, as is the reporter.
+// Recipients are recorded in alert_zone_deliveries in the same statement, so a user
+// already claimed for this report is never returned again.
+func (api *API) ClaimAlertZoneMatchesRepo(ctx context.Context, r model.CreateReportResponse, severity int) ([]model.AlertZoneMatch, error) {
+	query := `
+        WITH report AS (
+            SELECT ST_SetSRID(ST_MakePoint($3, $2), 4326) AS geom
+        ),
+        matches AS (
+            SELECT DISTINCT ON (z.user_id) z.user_id, z.id, z.name
+            FROM user_alert_zones z
+            CROSS JOIN report
+            WHERE z.active
+              AND z.user_id <> $4
+              AND ST_Intersects(z.area, report.geom)
+              AND $6 >= z.min_severity
+              AND (cardinality(z.report_types) = 0 OR $5 = ANY(z.report_types))
+              AND NOT EXISTS (
+                  SELECT 1 FROM report_alert_deliveries d
+                  WHERE d.report_id = $1 AND d.user_id = z.user_id
+              )
+            ORDER BY z.user_id, z.created_at
+        ),
+        claimed AS (
+            INSERT INTO alert_zone_deliveries (report_id, user_id, zone_id)
+            SELECT $1, user_id, id FROM matches
+            ON CONFLICT (report_id, user_id) DO NOTHING
+            RETURNING user_id
+        )
+        SELECT m.user_id, m.id, m.name
+        FROM claimed c
+        JOIN matches m ON m.user_id = c.user_id
+    `
+	rows, err := api.DB.Query(ctx, query, r.ID, r.Latitude, r.Longitude, r.UserID, r.Type, severity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []model.AlertZoneMatch
+	for rows.Next() {
+		var m model.AlertZoneMatch
+		if err := rows.Scan(&m.UserID, &m.ZoneID, &m.ZoneName); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func (api *API) ReportsPerDayHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	stats, status, message, err := api.ReportsPerDayHelper(r.Context(), days)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       stats,
+	}
+}
+
+func (api *API) ActiveUsersHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	stats, status, message, err := api.ActiveUsersHelper(r.Context(), days)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       stats,
+	}
+}
+
+func (api *API) ReportHotspotsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+	days, _ := strconv.Atoi(q.Get("days"))
+	cell, _ := strconv.ParseFloat(q.Get("cell"), 64)
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	hotspots, status, message, err := api.ReportHotspotsHelper(r.Context(), days, cell, limit)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       hotspots,
+	}
+}
+
+func (api *API) ReportLifetimesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	lifetimes, status, message, err := api.ReportLifetimesHelper(r.Context(), days)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       lifetimes,
+	}
+}
+
+func (api *API) ExternalAPIUsageHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+	usage, status, message, err := api.ExternalAPIUsageHelper(r.Context(), days)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       usage,
+	}
+}
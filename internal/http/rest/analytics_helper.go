@@ -0,0 +1,166 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 365
+
+	defaultHotspotCellDeg = 0.01 // ~1.1 km north-south
+	minHotspotCellDeg     = 0.001
+	maxHotspotCellDeg     = 1.0
+	defaultHotspots       = 50
+	maxHotspots           = 500
+
+	externalUsageFlushInterval = time.Minute
+	externalUsageFlushTimeout  = 10 * time.Second
+)
+
+var errAnalyticsDays = errors.New("days must be between 1 and 365")
+
+// analyticsSince is the start of the UTC day days-1 days ago, so a window of 1 is today.
+// Zero means defaultAnalyticsDays.
+func analyticsSince(days int, now time.Time) (time.Time, error) {
+	if days == 0 {
+		days = defaultAnalyticsDays
+	}
+	if days < 1 || days > maxAnalyticsDays {
+		return time.Time{}, errAnalyticsDays
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	return today.AddDate(0, 0, -(days - 1)), nil
+}
+
+func (api *API) ReportsPerDayHelper(ctx context.Context, days int) ([]model.ReportsPerDay, string, string, error) {
+	since, err := analyticsSince(days, time.Now())
+	if err != nil {
+		return nil, values.BadRequestBody, err.Error(), err
+	}
+	stats, err := api.ReportsPerDayRepo(ctx, since)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch report counts", err
+	}
+	if stats == nil {
+		stats = []model.ReportsPerDay{}
+	}
+	return stats, values.Success, "Report counts fetched successfully", nil
+}
+
+func (api *API) ActiveUsersHelper(ctx context.Context, days int) (model.ActiveUsersStats, string, string, error) {
+	since, err := analyticsSince(days, time.Now())
+	if err != nil {
+		return model.ActiveUsersStats{}, values.BadRequestBody, err.Error(), err
+	}
+	total, daily, err := api.ActiveUsersRepo(ctx, since)
+	if err != nil {
+		return model.ActiveUsersStats{}, values.Error, "Failed to fetch active users", err
+	}
+	if daily == nil {
+		daily = []model.DailyActivity{}
+	}
+	return model.ActiveUsersStats{Since: since, ActiveUsers: total, Daily: daily}, values.Success, "Active users fetched successfully", nil
+}
+
+func (api *API) ReportHotspotsHelper(ctx context.Context, days int, cellDeg float64, limit int) ([]model.ReportHotspot, string, string, error) {
+	since, err := analyticsSince(days, time.Now())
+	if err != nil {
+		return nil, values.BadRequestBody, err.Error(), err
+	}
+	if cellDeg == 0 {
+		cellDeg = defaultHotspotCellDeg
+	}
+	if cellDeg < minHotspotCellDeg || cellDeg > maxHotspotCellDeg {
+		return nil, values.BadRequestBody, "cell must be between 0.001 and 1 degrees", errors.New("hotspot cell size out of range")
+	}
+	if limit < 1 {
+		limit = defaultHotspots
+	}
+	limit = min(limit, maxHotspots)
+
+	hotspots, err := api.ReportHotspotsRepo(ctx, since, cellDeg, limit)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch report hotspots", err
+	}
+	if hotspots == nil {
+		hotspots = []model.ReportHotspot{}
+	}
+	return hotspots, values.Success, "Report hotspots fetched successfully", nil
+}
+
+func (api *API) ReportLifetimesHelper(ctx context.Context, days int) ([]model.ReportLifetime, string, string, error) {
+	since, err := analyticsSince(days, time.Now())
+	if err != nil {
+		return nil, values.BadRequestBody, err.Error(), err
+	}
+	lifetimes, err := api.ReportLifetimesRepo(ctx, since)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch report lifetimes", err
+	}
+	if lifetimes == nil {
+		lifetimes = []model.ReportLifetime{}
+	}
+	return lifetimes, values.Success, "Report lifetimes fetched successfully", nil
+}
+
+func (api *API) ExternalAPIUsageHelper(ctx context.Context, days int) ([]model.ExternalAPIUsage, string, string, error) {
+	since, err := analyticsSince(days, time.Now())
+	if err != nil {
+		return nil, values.BadRequestBody, err.Error(), err
+	}
+	usage, err := api.ExternalAPIUsageRepo(ctx, since)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch external API usage", err
+	}
+	if usage == nil {
+		usage = []model.ExternalAPIUsage{}
+	}
+	return usage, values.Success, "External API usage fetched successfully", nil
+}
+
+// RunExternalUsageRecorder adds the provider requests counted by telemetry.Transport to
+// external_api_usage every minute until ctx is cancelled, then flushes once more.
+// Counts that fail to save are kept for the next flush.
+func (api *API) RunExternalUsageRecorder(ctx context.Context) {
+	pending := make(map[string]telemetry.HostUsage)
+	flush := func(ctx context.Context) {
+		for _, u := range telemetry.TakeUsage() {
+			p := pending[u.Host]
+			p.Host, p.Requests, p.Errors = u.Host, p.Requests+u.Requests, p.Errors+u.Errors
+			pending[u.Host] = p
+		}
+		if len(pending) == 0 || !api.dbAvailable() {
+			return
+		}
+		usage := make([]telemetry.HostUsage, 0, len(pending))
+		for _, u := range pending {
+			usage = append(usage, u)
+		}
+		ctx, cancel := context.WithTimeout(ctx, externalUsageFlushTimeout)
+		defer cancel()
+		if err := api.AddExternalAPIUsageRepo(ctx, time.Now().UTC(), usage); err != nil {
+			api.logger().WarnContext(ctx, "Recording external API usage failed", "hosts", len(usage), "err", err)
+			return
+		}
+		clear(pending)
+	}
+
+	ticker := time.NewTicker(externalUsageFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flush(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyticsSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 15, 30, 0, 0, time.FixedZone("EEST", 3*3600))
+
+	since, err := analyticsSince(1, now)
+	if err != nil || !since.Equal(time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("1 day: since = %v, %v; want the start of today (UTC)", since, err)
+	}
+	since, err = analyticsSince(0, now)
+	if err != nil || !since.Equal(time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("default: since = %v, %v; want 30 days including today", since, err)
+	}
+	for _, days := range []int{-1, maxAnalyticsDays + 1} {
+		if _, err := analyticsSince(days, now); err == nil {
+			t.Errorf("days=%d: want an error", days)
+		}
+	}
+}
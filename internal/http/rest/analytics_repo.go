@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+)
+
+func (api *API) ReportsPerDayRepo(ctx context.Context, since time.Time) ([]model.ReportsPerDay, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT to_char((created_at AT TIME ZONE 'UTC')::date, 'YYYY-MM-DD') AS day, type, COUNT(*)
+        FROM reports
+        WHERE created_at >= $1
+        GROUP BY day, type
+        ORDER BY day, type
+    `, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ReportsPerDay
+	for rows.Next() {
+		var r model.ReportsPerDay
+		if err := rows.Scan(&r.Day, &r.Type, &r.Reports); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// ActiveUsersRepo counts the users with a location ping or a new report since since:
+// per UTC day, and over the whole window.
+func (api *API) ActiveUsersRepo(ctx context.Context, since time.Time) (int, []model.DailyActivity, error) {
+	query := `
+        WITH activity AS (
+            SELECT user_id, (recorded_at AT TIME ZONE 'UTC')::date AS day
+            FROM user_location_pings
+            WHERE recorded_at >= $1
+            UNION
+            SELECT user_id, (created_at AT TIME ZONE 'UTC')::date AS day
+            FROM reports
+            WHERE created_at >= $1
+        )
+        SELECT to_char(day, 'YYYY-MM-DD'), COUNT(DISTINCT user_id),
+               (SELECT COUNT(DISTINCT user_id) FROM activity)
+        FROM activity
+        GROUP BY day
+        ORDER BY day
+    `
+	rows, err := api.DB.Query(ctx, query, since)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var total int
+	var daily []model.DailyActivity
+	for rows.Next() {
+		var d model.DailyActivity
+		if err := rows.Scan(&d.Day, &d.Users, &total); err != nil {
+			return 0, nil, err
+		}
+		daily = append(daily, d)
+	}
+	return total, daily, rows.Err()
+}
+
+// ReportHotspotsRepo buckets reports created since since into cellDeg grid cells and
+// returns the busiest limit cells.
+func (api *API) ReportHotspotsRepo(ctx context.Context, since time.Time, cellDeg float64, limit int) ([]model.ReportHotspot, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT (floor(ST_Y(position) / $2) + 0.5) * $2 AS lat,
+               (floor(ST_X(position) / $2) + 0.5) * $2 AS lng,
+               COUNT(*) AS reports
+        FROM reports
+        WHERE created_at >= $1
+        GROUP BY lat, lng
+        ORDER BY reports DESC, lat, lng
+        LIMIT $3
+    `, since, cellDeg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ReportHotspot
+	for rows.Next() {
+		h := model.ReportHotspot{CellDeg: cellDeg}
+		if err := rows.Scan(&h.Latitude, &h.Longitude, &h.Reports); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// ReportLifetimesRepo measures, per type, how long reports created since since stayed up.
+// Only reports that have ended count: expired by the lifecycle worker, auto-resolved,
+// resolved or taken down (ended at their last update), or past expires_at.
+func (api *API) ReportLifetimesRepo(ctx context.Context, since time.Time) ([]model.ReportLifetime, error) {
+	rows, err := api.DB.Query(ctx, `
+        WITH ended AS (
+            SELECT type,
+                   EXTRACT(EPOCH FROM COALESCE(
+                       expired_at,
+                       auto_resolved_at,
+                       CASE WHEN resolved OR NOT active THEN LEAST(updated_at, expires_at) END,
+                       expires_at
+                   ) - created_at) / 60 AS minutes
+            FROM reports
+            WHERE created_at >= $1
+              AND (NOT active OR resolved OR expires_at <= NOW())
+        )
+        SELECT type, COUNT(*), AVG(minutes),
+               percentile_cont(0.5) WITHIN GROUP (ORDER BY minutes)
+        FROM ended
+        WHERE minutes >= 0
+        GROUP BY type
+        ORDER BY type
+    `, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ReportLifetime
+	for rows.Next() {
+		var l model.ReportLifetime
+		if err := rows.Scan(&l.Type, &l.Reports, &l.AvgMinutes, &l.MedianMinutes); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func (api *API) ExternalAPIUsageRepo(ctx context.Context, since time.Time) ([]model.ExternalAPIUsage, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT to_char(day, 'YYYY-MM-DD'), host, requests, errors
+        FROM external_api_usage
+        WHERE day >= $1::date
+        ORDER BY day, host
+    `, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []model.ExternalAPIUsage
+	for rows.Next() {
+		var u model.ExternalAPIUsage
+		if err := rows.Scan(&u.Day, &u.Host, &u.Requests, &u.Errors); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// AddExternalAPIUsageRepo adds counted requests to day's totals.
+func (api *API) AddExternalAPIUsageRepo(ctx context.Context, day time.Time, usage []telemetry.HostUsage) error {
+	hosts := make([]string, len(usage))
+	requests := make([]int64, len(usage))
+	errs := make([]int64, len(usage))
+	for i, u := range usage {
+		hosts[i], requests[i], errs[i] = u.Host, u.Requests, u.Errors
+	}
+	_, err := api.DB.Exec(ctx, `
+        INSERT INTO external_api_usage (day, host, requests, errors)
+        SELECT $1::date, host, requests, errors
+        FROM unnest($2::text[], $3::bigint[], $4::bigint[]) AS u(host, requests, errors)
+        ON CONFLICT (day, host) DO UPDATE SET
+            requests = external_api_usage.requests + EXCLUDED.requests,
+            errors = external_api_usage.errors + EXCLUDED.errors
+    `, day, hosts, requests, errs)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -14,8 +15,13 @@ import (
 	deps "github.com/bwise1/waze_kibris/internal/debs"
 	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
 	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/nominatim"
 	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
 	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/http/vision"
+	"github.com/bwise1/waze_kibris/internal/notifications"
+	"github.com/bwise1/waze_kibris/internal/sms"
+	"github.com/bwise1/waze_kibris/internal/telemetry"
 	smtp "github.com/bwise1/waze_kibris/util/email"
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/go-chi/chi/v5"
@@ -23,16 +29,30 @@ import (
 )
 
 const (
-	defaultIdleTimeout    = time.Minute
-	defaultReadTimeout    = 5 * time.Second
-	defaultWriteTimeout   = 10 * time.Second
-	defaultShutdownPeriod = 30 * time.Second
+	defaultIdleTimeout  = time.Minute
+	defaultReadTimeout  = 5 * time.Second
+	defaultWriteTimeout = 10 * time.Second
 )
 
 type Handler func(w http.ResponseWriter, r *http.Request) *ServerResponse
 
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	resp := h(w, r)
+	resp.Version = ResponseEnvelopeVersion
+	if resp.Code == "" && resp.StatusCode >= http.StatusBadRequest {
+		// Built by hand rather than with respondWithError.
+		resp.Code = errorCode(nil, resp.Status)
+	}
+	// Successful responses can be sent as bare GeoJSON; errors keep the envelope.
+	if resp.Data != nil && resp.StatusCode < http.StatusBadRequest && geoJSONFormat(r) {
+		if fc, ok := geoJSONView(resp.Data); ok {
+			writeGeoJSONResponse(w, fc, resp.StatusCode)
+			return
+		}
+	}
+	if resp.Data != nil && drivingMode(r) {
+		resp.Data = drivingView(resp.Data)
+	}
 	respByte, err := json.Marshal(resp)
 	if err != nil {
 		writeErrorResponse(w, err, values.Error, "unable to marshal server response")
@@ -51,17 +71,51 @@ type API struct {
 	StadiaClient     *stadiamaps.Client
 	GoogleMapsClient *googlemaps.GoogleMapsClient
 	MapboxClient     *mapbox.MapboxClient
+	NominatimClient  *nominatim.Client
+	VisionClient     *vision.VisionClient
 	FirebaseAuth      *auth.Client
 	FirebaseMessaging *messaging.Client
+	// Notifier sends push notifications; disabled when FirebaseMessaging is nil.
+	Notifier *notifications.Client
+	// SMS sends phone login codes. Nil turns phone login off.
+	SMS sms.Sender
+	// Logger writes structured logs, tagged with request fields when given a request
+	// context (see util/logging). Nil uses slog.Default().
+	Logger *slog.Logger
+
+	broadcastPolicies broadcastPolicyCache
+	expiryPolicies    expiryPolicyCache
+	placeAliases      placeAliasCache
+	routePOIs         routePOICache
+	outbox            outboxDispatcher
+	dbHealth          dbHealth
+	reportReadCache   readCache
+	reportTiles       reportTileCache
+	reportDedup       reportDedupPolicy
+	rateLimits        rateLimiter
+	proxies           trustedProxies
+	geocoderHealth    providerHealth
+	routeAudits       routeAuditor
+	routingHealth     providerHealth
+	revokedTokens     tokenRevocationCache
 }
 
-func (api *API) Serve() error {
-	api.Server = &http.Server{
+// NewServer builds the HTTP server Serve runs. Build it before starting Serve so
+// Shutdown always has a server to stop.
+func (api *API) NewServer() *http.Server {
+	return &http.Server{
 		Addr:         fmt.Sprintf(":%d", api.Config.Port),
 		IdleTimeout:  defaultIdleTimeout,
 		ReadTimeout:  defaultReadTimeout,
 		WriteTimeout: defaultWriteTimeout,
-		Handler:      api.setUpServerHandler(),
+		Handler:      telemetry.Handler(api.setUpServerHandler(), "/ws"),
+	}
+}
+
+// Serve listens until Shutdown, when it returns http.ErrServerClosed.
+func (api *API) Serve() error {
+	if api.Server == nil {
+		api.Server = api.NewServer()
 	}
 	return api.Server.ListenAndServe()
 }
@@ -79,13 +133,29 @@ func (api *API) setUpServerHandler() http.Handler {
 			},
 		)
 
-		r.Mount("/auth", api.AuthRoutes())
-		r.Mount("/reports", api.ReportRoutes())
-		r.Mount("/saved-locations", api.SavedLocationRoutes())
-		r.Mount("/user", api.UserRoutes())
+		// Routing and geocoding proxies keep working while the database is degraded.
 		r.Mount("/route", api.RoutingRoutes())
-		r.Mount("/community", api.GroupRoutes())
 		r.Mount("/places", api.PlacesRoutes())
+
+		// Writes below get a retryable 503 while the database is down or read-only.
+		r.Group(func(r chi.Router) {
+			r.Use(api.RequireWritableDB)
+
+			r.Mount("/auth", api.AuthRoutes())
+			r.Mount("/reports", api.ReportRoutes())
+			r.Mount("/uploads", api.UploadRoutes())
+			r.Mount("/saved-locations", api.SavedLocationRoutes())
+			r.Mount("/user", api.UserRoutes())
+			r.Mount("/community", api.GroupRoutes())
+			r.Mount("/navigation", api.NavigationRoutes())
+			r.Mount("/planned-drives", api.PlannedDriveRoutes())
+			r.Mount("/map-issues", api.MapIssueRoutes())
+			r.Mount("/offline", api.OfflineRoutes())
+			r.Mount("/traffic", api.TrafficRoutes())
+			r.Mount("/map-features", api.MapFeatureRoutes())
+			r.Mount("/search", api.SearchRoutes())
+			r.Mount("/admin", api.AdminRoutes())
+		})
 		// mux.Mount("/location", api.LocationSnappingRoutes())
 	})
 	//websocket
@@ -94,15 +164,9 @@ func (api *API) setUpServerHandler() http.Handler {
 	return mux
 }
 
-func (a *API) Shutdown() error {
-	// err := a.Deps.DAL.DB.Close()
-	// if err != nil {
-	// 	return err
-	// }
-
-	err := a.Server.Shutdown(context.Background())
-	if err != nil {
-		return err
-	}
-	return nil
+// Shutdown closes the listeners and waits, until ctx is done, for in-flight requests to
+// finish. Upgraded WebSocket connections are not tracked by the server; drain them with
+// WebSocketManager.Shutdown.
+func (a *API) Shutdown(ctx context.Context) error {
+	return a.Server.Shutdown(ctx)
 }
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	arrivalSourceOverride    = "override"
+	arrivalSourceGooglePlace = "google_place"
+
+	defaultOverrideRadiusM = 150
+	defaultArrivalKind     = "entrance"
+	// A Google place point is only used if it is this close to the requested destination;
+	// further away the place id and the coordinates probably disagree about the destination.
+	googleArrivalMaxShiftM = 1000
+	// Below this the requested point is already good enough to route to.
+	arrivalMinShiftM   = 15
+	walkingSpeedMS     = 1.3
+	arrivalLookupLimit = 3 * time.Second
+)
+
+// resolveArrivalPoint picks where a route to the request's last location should end:
+// a curated destination override, else the Google place's own point when the destination
+// has a Google place id. It returns nil to route to the requested point unchanged.
+// Lookups failing never fail the route.
+func (api *API) resolveArrivalPoint(ctx context.Context, req RouteRequest) *mapbox.ArrivalPoint {
+	dest := req.Locations[len(req.Locations)-1]
+	ctx, cancel := context.WithTimeout(ctx, arrivalLookupLimit)
+	defer cancel()
+
+	if api.dbAvailable() {
+		o, err := api.FindDestinationOverrideRepo(ctx, req.DestinationPlaceID, dest.Lat, dest.Lng)
+		switch {
+		case err == nil:
+			return newArrivalPoint(dest, o.ArrivalLat, o.ArrivalLng, arrivalSourceOverride, o.Kind, o.Name)
+		case !errors.Is(err, pgx.ErrNoRows):
+			api.logger().WarnContext(ctx, "Destination override lookup failed", "err", err)
+		}
+	}
+
+	placeID, ok := strings.CutPrefix(req.DestinationPlaceID, googleGID(""))
+	if !ok || placeID == "" || !api.geocoderConfigured(GeocoderGoogle) {
+		return nil
+	}
+	place, err := api.GoogleMapsClient.GetPlaceDetails(ctx, placeID, []string{"geometry", "name"})
+	if err != nil {
+		api.logger().WarnContext(ctx, "Google place lookup for arrival point failed", "err", err)
+		return nil
+	}
+	loc := place.Geometry.Location
+	shift := util.HaversineMeters(dest.Lat, dest.Lng, loc.Lat, loc.Lng)
+	if shift < arrivalMinShiftM || shift > googleArrivalMaxShiftM {
+		return nil
+	}
+	return newArrivalPoint(dest, loc.Lat, loc.Lng, arrivalSourceGooglePlace, "", place.Name)
+}
+
+func newArrivalPoint(dest Location, lat, lng float64, source, kind, name string) *mapbox.ArrivalPoint {
+	walk := util.HaversineMeters(lat, lng, dest.Lat, dest.Lng)
+	return &mapbox.ArrivalPoint{
+		Latitude:         lat,
+		Longitude:        lng,
+		Source:           source,
+		Kind:             kind,
+		Name:             name,
+		WalkingDistanceM: walk,
+		WalkingDurationS: walk / walkingSpeedMS,
+	}
+}
+
+func (api *API) ListDestinationOverridesHelper(ctx context.Context) ([]model.DestinationOverride, string, string, error) {
+	overrides, err := api.ListDestinationOverridesRepo(ctx)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch destination overrides", err
+	}
+	if overrides == nil {
+		overrides = []model.DestinationOverride{}
+	}
+	return overrides, values.Success, "Destination overrides fetched successfully", nil
+}
+
+func (api *API) CreateDestinationOverrideHelper(ctx context.Context, req model.CreateDestinationOverrideRequest, adminID uuid.UUID) (model.DestinationOverride, string, string, error) {
+	if req.RadiusM == 0 {
+		req.RadiusM = defaultOverrideRadiusM
+	}
+	if req.Kind == "" {
+		req.Kind = defaultArrivalKind
+	}
+	req.PlaceID = trimmedOrNil(req.PlaceID)
+	if walk := util.HaversineMeters(req.Latitude, req.Longitude, req.ArrivalLat, req.ArrivalLng); walk > googleArrivalMaxShiftM {
+		return model.DestinationOverride{}, values.BadRequestBody, "Arrival point must be within 1 km of the destination", fmt.Errorf("arrival point %.0f m away", walk)
+	}
+
+	o, err := api.CreateDestinationOverrideRepo(ctx, req, adminID)
+	if err != nil {
+		return model.DestinationOverride{}, values.Error, "Failed to create destination override", err
+	}
+	return o, values.Created, "Destination override created", nil
+}
+
+func (api *API) DeleteDestinationOverrideHelper(ctx context.Context, id int64) (string, string, error) {
+	if err := api.DeleteDestinationOverrideRepo(ctx, id); err != nil {
+		if errors.Is(err, ErrDestinationOverrideNotFound) {
+			return values.NotFound, "Destination override not found", err
+		}
+		return values.Error, "Failed to delete destination override", err
+	}
+	return values.Success, "Destination override deleted", nil
+}
@@ -3,12 +3,15 @@ package rest
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"html/template"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/assets"
 	"github.com/bwise1/waze_kibris/util/tracing"
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/go-chi/chi/v5"
@@ -26,7 +29,10 @@ import (
 var googleOauthConfig *oauth2.Config
 
 func (api *API) Init() {
-	log.Println("Initializing google auth")
+	api.outbox = newOutboxDispatcher()
+	api.Deps.WebSocket.SetAuthorizer(wsAuthorizer{api: api})
+
+	api.logger().Info("Initializing google auth")
 	googleOauthConfig = &oauth2.Config{
 		RedirectURL:  "http://localhost:8080/auth/google/callback",
 		ClientID:     api.Config.GoogleClientID,
@@ -43,10 +49,17 @@ func (api *API) AuthRoutes() chi.Router {
 	mux.Method(http.MethodPost, "/login", Handler(api.Login))
 	mux.Method(http.MethodPost, "/verify", Handler(api.VerifyCode))
 	mux.Method(http.MethodPost, "/resend", Handler(api.ResendCode))
+	mux.Method(http.MethodPost, "/verify-link", Handler(api.VerifyLink))
+	mux.Get("/verify-link", api.VerifyLinkPage)
 	mux.Method(http.MethodPost, "/google/create", Handler(api.CreateAccountWithGoogle))
 	mux.Method(http.MethodPost, "/refresh", Handler(api.RefreshTokenHandler)) // Add this line
+	mux.With(api.RequireLogin).Method(http.MethodPost, "/logout", Handler(api.Logout))
+	mux.Method(http.MethodGet, "/introspect", Handler(api.IntrospectTokenHandler))
+	mux.Method(http.MethodHead, "/introspect", Handler(api.IntrospectTokenHandler))
 	mux.Method(http.MethodPost, "/google/login", Handler(api.MobileGoogleLogin))
 	mux.Method(http.MethodPost, "/firebase/login", Handler(api.MobileFirebaseLogin))
+	mux.With(api.RateLimit(RateLimitPhoneCode)).Method(http.MethodPost, "/phone/request", Handler(api.RequestPhoneCode))
+	mux.With(api.RateLimit(RateLimitPhoneCode)).Method(http.MethodPost, "/phone/verify", Handler(api.VerifyPhoneCode))
 	return mux
 }
 
@@ -75,10 +88,10 @@ func (api *API) CreateAccountWithGoogle(_ http.ResponseWriter, r *http.Request)
 		return respondWithError(err, "failed to decode user info", values.Error, &tc)
 	}
 
-	log.Println(userInfo)
+	api.logger().DebugContext(r.Context(), "Google user info", "user_info", userInfo)
 	// Check if user already exists
 	Euser, err := api.GetUserByEmail(r.Context(), userInfo.Email)
-	log.Println(Euser)
+	api.logger().DebugContext(r.Context(), "Existing user for Google account", "user_id", Euser.ID)
 	if err == nil {
 		return respondWithError(nil, "user already exists", values.Conflict, &tc)
 	}
@@ -183,6 +196,7 @@ func (api *API) MobileGoogleLogin(_ http.ResponseWriter, r *http.Request) *Serve
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
+	api.startSession(r, &user)
 
 	return &ServerResponse{
 		Message:    message,
@@ -211,6 +225,7 @@ func (api *API) MobileFirebaseLogin(_ http.ResponseWriter, r *http.Request) *Ser
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
+	api.startSession(r, &user)
 
 	return &ServerResponse{
 		Message:    message,
@@ -227,6 +242,9 @@ func (api *API) Register(_ http.ResponseWriter, r *http.Request) *ServerResponse
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	user, status, message, err := api.CreateNewUser(req)
 	if err != nil {
@@ -249,6 +267,9 @@ func (api *API) Login(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	user, status, message, err := api.LoginUser(req)
 	if err != nil {
@@ -270,11 +291,41 @@ func (api *API) VerifyCode(_ http.ResponseWriter, r *http.Request) *ServerRespon
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	user, status, message, err := api.VerifyCodeHelper(req)
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
+	api.startSession(r, &user)
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       user,
+	}
+}
+
+// VerifyLink is called by the app when it is opened from an emailed verification link.
+func (api *API) VerifyLink(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req model.VerifyLinkRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	user, status, message, err := api.VerifyLinkHelper(req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+	api.startSession(r, &user)
 
 	return &ServerResponse{
 		Message:    message,
@@ -284,6 +335,29 @@ func (api *API) VerifyCode(_ http.ResponseWriter, r *http.Request) *ServerRespon
 	}
 }
 
+var verifyLinkPage = template.Must(template.ParseFS(assets.EmbeddedFiles, "pages/verifyLink.tmpl"))
+
+// VerifyLinkPage is the browser fallback for verification links opened where the app
+// isn't installed or the scheme isn't handled. It renders HTML rather than the JSON envelope.
+func (api *API) VerifyLinkPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	data := map[string]interface{}{"OK": false}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	codeReq, err := api.VerifyLinkPageHelper(r.Context(), token)
+	if err != nil {
+		api.logger().InfoContext(r.Context(), "Verification link rejected", "err", err)
+		w.WriteHeader(http.StatusUnauthorized)
+	} else {
+		data["OK"] = true
+		data["Type"] = codeReq.Type
+		data["AppLink"] = verifyAppLink(token)
+	}
+	if err := verifyLinkPage.ExecuteTemplate(w, "page", data); err != nil {
+		api.logger().ErrorContext(r.Context(), "Failed to render verification page", "err", err)
+	}
+}
+
 func (api *API) RefreshTokenHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
@@ -293,10 +367,10 @@ func (api *API) RefreshTokenHandler(_ http.ResponseWriter, r *http.Request) *Ser
 	}
 
 	// Refresh the access token
-	log.Println("refreshing token")
-	accessToken, newRefreshToken, err := api.RefreshAccessToken(r.Context(), req.RefreshToken)
+	api.logger().DebugContext(r.Context(), "Refreshing token")
+	tokens, err := api.RefreshAccessToken(r.Context(), req.RefreshToken)
 	if err != nil {
-		log.Println("error refreshing token", err)
+		api.logger().InfoContext(r.Context(), "Token refresh failed", "err", err)
 		return respondWithError(err, "Failed to refresh tokens", values.NotAuthorised, nil)
 	}
 
@@ -305,12 +379,59 @@ func (api *API) RefreshTokenHandler(_ http.ResponseWriter, r *http.Request) *Ser
 		Message:    "Tokens refreshed successfully",
 		Status:     values.Success,
 		StatusCode: http.StatusOK,
-		Data: map[string]string{
-			"access_token":  accessToken,
-			"refresh_token": newRefreshToken,
-		},
+		Data:       tokens,
+	}
+}
+
+// IntrospectTokenHandler reports whether the bearer access token is still valid and when
+// it expires. It answers HEAD too, for clients that only need the status code.
+func (api *API) IntrospectTokenHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	authorization := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(authorization) != 2 || authorization[0] != "Bearer" {
+		return respondWithError(errors.New(values.NotAuthorised), "Missing bearer token", values.NotAuthorised, nil)
+	}
+
+	info, status, message, err := api.IntrospectAccessToken(r.Context(), authorization[1])
+	if err != nil {
+		return respondWithError(err, message, status, nil)
+	}
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       info,
+	}
+}
+
+// Logout denies the bearer access token until it expires and revokes the refresh token
+// in the body, if any: { "refresh_token": "..." }.
+func (api *API) Logout(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return respondWithError(err, "unable to decode request", values.BadRequestBody, &tc)
+	}
+
+	// RequireLogin already accepted the token; read its claims again for the jti.
+	claims, err := api.verifyToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), false)
+	if err != nil {
+		return respondWithError(err, "Invalid token", values.NotAuthorised, &tc)
+	}
+
+	status, message, err := api.LogoutHelper(r.Context(), claims, strings.TrimSpace(req.RefreshToken))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 	}
 }
+
 func (api *API) ResendCode(w http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
@@ -318,6 +439,9 @@ func (api *API) ResendCode(w http.ResponseWriter, r *http.Request) *ServerRespon
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	status, message, err := api.ResendVerificationCode(req)
 	if err != nil {
@@ -2,11 +2,12 @@ package rest
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"html/template"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -14,8 +15,6 @@ import (
 	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/golang-jwt/jwt"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"google.golang.org/api/idtoken"
 )
 
@@ -36,11 +35,14 @@ type TokenClaims struct {
 	UserID string `json:"sub"`
 	Type   string `json:"typ"`
 	Exp    int64  `json:"exp"`
+	// JTI identifies an access token so logout can deny it before it expires. Tokens
+	// issued before it was added have none.
+	JTI string `json:"jti"`
 }
 
 // Simplified token creation
 func (api *API) createToken(id string) (string, time.Time, error) {
-	log.Println("Creating token for user", id)
+	api.logger().Debug("Creating token", "user_id", id)
 	exp_time, err := time.ParseDuration(api.Config.JwtExpires)
 	if err != nil {
 		return "", time.Time{}, err
@@ -52,6 +54,7 @@ func (api *API) createToken(id string) (string, time.Time, error) {
 		"exp": expiresAt.Unix(),
 		"iat": time.Now().Unix(),
 		"typ": "access",
+		"jti": util.GenerateUUID().String(),
 	})
 
 	tokenString, err := token.SignedString([]byte(api.Config.JwtSecret))
@@ -77,10 +80,9 @@ func (api *API) createRefreshToken(id string) (string, time.Time, error) {
 
 	tokenString, err := token.SignedString([]byte(api.Config.RefreshSecret))
 	if err != nil {
-		log.Println("error signing refresh token", err)
+		api.logger().Error("Failed to sign refresh token", "err", err)
 		return "", time.Time{}, err
 	}
-	log.Println("refresh token", tokenString)
 	return tokenString, expiresAt, nil
 }
 
@@ -126,8 +128,7 @@ func (api *API) CreateNewUser(req model.RegisterRequest) (model.VerifyCodeRespon
 		}
 
 		// If it's not a unique violation on username, bail out immediately.
-		var pgErr *pgconn.PgError
-		if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		if !errors.Is(err, ErrConflict) {
 			return model.VerifyCodeResponse{}, values.Error, "Error creating new user", err
 		}
 
@@ -146,16 +147,14 @@ func (api *API) CreateNewUser(req model.RegisterRequest) (model.VerifyCodeRespon
 		return model.VerifyCodeResponse{}, values.Error, "Failed to store verification code", err
 	}
 
-	log.Println("Verification code:", code)
+	api.logger().Debug("Verification code issued", "email", req.Email, "code", code)
 	go func() {
 		// Send verification email
-		emailData := map[string]interface{}{
-			"Code": code,
-		}
+		emailData := api.verificationEmailData(user.Email, code, tokenType, expiresAt)
 
 		err = api.Mailer.Send(user.Email, emailData, "verifyEmail.tmpl")
 		if err != nil {
-			log.Println(values.Error, "Failed to send verification email", err)
+			api.logger().Error("Failed to send verification email", "err", err)
 		}
 	}()
 
@@ -180,13 +179,16 @@ func (api *API) LoginUser(req model.LoginRequest) (model.VerifyCodeResponse, str
 
 	user, err := api.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		return model.VerifyCodeResponse{}, values.NotFound, "User not found", err
+		if errors.Is(err, ErrNotFound) {
+			return model.VerifyCodeResponse{}, values.NotFound, "User not found", err
+		}
+		return model.VerifyCodeResponse{}, values.Error, "Failed to look up user", err
 	}
 
 	// Generate verification code
 	code := util.GenerateVerificationCode()
 	// Store verification code
-	log.Println("Verification code:", code)
+	api.logger().Debug("Verification code issued", "email", req.Email, "code", code)
 	expiresAt := time.Now().Add(1 * time.Hour) // Code expires in 1 hour
 	tokenType := "login"
 	err = api.StoreVerificationCode(ctx, user.ID.String(), user.Email, code, tokenType, expiresAt)
@@ -195,12 +197,10 @@ func (api *API) LoginUser(req model.LoginRequest) (model.VerifyCodeResponse, str
 	}
 	go func() {
 		// Send verification email
-		emailData := map[string]interface{}{
-			"Code": code,
-		}
+		emailData := api.verificationEmailData(user.Email, code, tokenType, expiresAt)
 		err = api.Mailer.Send(user.Email, emailData, "verifyEmail.tmpl")
 		if err != nil {
-			log.Println(values.Error, "Failed to send verification email", err)
+			api.logger().Error("Failed to send verification email", "err", err)
 		}
 	}()
 
@@ -232,7 +232,7 @@ func (api *API) VerifyCodeHelper(req model.VerifyCodeRequest) (model.LoginRespon
 	// Check if the code is valid
 	userID, err := api.VerifyCodeRepo(ctx, req.Code, req.Type, req.Email)
 	if err != nil {
-		log.Println("Error verifying code", err)
+		api.logger().Info("Verification code rejected", "err", err)
 		return model.LoginResponse{}, values.NotAuthorised, "Invalid or expired verification code", err
 	}
 
@@ -246,6 +246,8 @@ func (api *API) VerifyCodeHelper(req model.VerifyCodeRequest) (model.LoginRespon
 		// Handle login verification logic if needed
 	}
 
+	reactivated := api.reactivateOnLogin(ctx, userID)
+
 	// Retrieve the updated user
 	user, err := api.GetUserByID(ctx, userID)
 	if err != nil {
@@ -276,12 +278,14 @@ func (api *API) VerifyCodeHelper(req model.VerifyCodeRequest) (model.LoginRespon
 			LastName:          user.LastName,
 			Username:          user.Username,
 			Email:             user.Email,
+			Phone:             user.Phone,
 			ProfileIcon:       user.ProfileIcon,
 			IsVerified:        user.IsVerified,
 			PreferredLanguage: user.PreferredLanguage,
 		},
 		Token:        token,
 		RefreshToken: refreshToken, // refreshToken,
+		Reactivated:  reactivated,
 	}
 	return loggedInUser, values.Success, "Verification successful", nil
 }
@@ -305,7 +309,7 @@ func (api *API) ResendVerificationCode(req model.ResendCodeRequest) (string, str
 	// Generate verification code
 	code := util.GenerateVerificationCode()
 	// Store verification code
-	log.Println("Verification code:", code)
+	api.logger().Debug("Verification code issued", "email", req.Email, "code", code)
 	expiresAt := time.Now().Add(1 * time.Hour) // Code expires in 1 hour
 	tokenType := "register"
 	err = api.StoreVerificationCode(ctx, user.ID.String(), user.Email, code, tokenType, expiresAt)
@@ -314,28 +318,156 @@ func (api *API) ResendVerificationCode(req model.ResendCodeRequest) (string, str
 	}
 	go func() {
 		// Send verification email
-		emailData := map[string]interface{}{
-			"Name": user.FirstName,
-			"Code": code,
-		}
+		emailData := api.verificationEmailData(user.Email, code, tokenType, expiresAt)
+		emailData["Name"] = user.FirstName
 		err = api.Mailer.Send(user.Email, emailData, "verifyEmail.tmpl")
 		if err != nil {
-			log.Println(values.Error, "Failed to send verification email", err)
+			api.logger().Error("Failed to send verification email", "err", err)
 		}
 	}()
 
 	return values.Success, "Verification code sent", nil
 }
 
-// func (api *API) LogUserOut(userID int) (bool, error) {
-// 	err := api.invalidateRefreshToken(context.TODO(), userID)
-// 	if err != nil {
-// 		return false, err
-// 	}
-// 	return true, nil
-// }
+// verifyLinkBase is the app deep link that email verification links open.
+const verifyLinkBase = "wazekibris://verify"
+
+// createVerifyLinkToken signs the code into a token so a link can stand in for typing
+// it. The token carries the code itself, so it stays valid exactly as long as the code.
+func (api *API) createVerifyLinkToken(email, code, codeType string, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  email,
+		"code": code,
+		"vt":   codeType,
+		"exp":  expiresAt.Unix(),
+		"iat":  time.Now().Unix(),
+		"typ":  "email_link",
+	})
+	return token.SignedString([]byte(api.Config.JwtSecret))
+}
+
+// parseVerifyLinkToken checks a link token and returns the code request it stands for.
+func (api *API) parseVerifyLinkToken(tokenString string) (model.VerifyCodeRequest, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(api.Config.JwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return model.VerifyCodeRequest{}, fmt.Errorf("invalid verification link: %v", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return model.VerifyCodeRequest{}, fmt.Errorf("invalid verification link claims")
+	}
+	if typ, _ := claims["typ"].(string); typ != "email_link" {
+		return model.VerifyCodeRequest{}, fmt.Errorf("invalid token type")
+	}
+	email, _ := claims["sub"].(string)
+	code, _ := claims["code"].(string)
+	codeType, _ := claims["vt"].(string)
+	if email == "" || code == "" || codeType == "" {
+		return model.VerifyCodeRequest{}, fmt.Errorf("incomplete verification link")
+	}
+	return model.VerifyCodeRequest{Email: email, Code: code, Type: codeType}, nil
+}
+
+// verificationEmailData is the template data for verifyEmail.tmpl: the code plus, when
+// a token can be signed, the app deep link and the browser fallback link.
+func (api *API) verificationEmailData(email, code, codeType string, expiresAt time.Time) map[string]interface{} {
+	data := map[string]interface{}{
+		"Code": code,
+	}
+	token, err := api.createVerifyLinkToken(email, code, codeType, expiresAt)
+	if err != nil {
+		api.logger().Warn("Failed to sign verification link, sending code only", "err", err)
+		return data
+	}
+	data["AppLink"] = verifyAppLink(token)
+	if base := strings.TrimRight(api.Config.PublicBaseURL, "/"); base != "" {
+		data["WebLink"] = base + "/auth/verify-link?token=" + url.QueryEscape(token)
+	}
+	return data
+}
+
+// verifyAppLink is typed as a trusted URL so html/template keeps the custom scheme in hrefs.
+func verifyAppLink(token string) template.URL {
+	return template.URL(verifyLinkBase + "?token=" + url.QueryEscape(token))
+}
+
+// VerifyLinkHelper logs the user in from an emailed link opened in the app. It goes
+// through the same checks as typing the code.
+func (api *API) VerifyLinkHelper(req model.VerifyLinkRequest) (model.LoginResponse, string, string, error) {
+	codeReq, err := api.parseVerifyLinkToken(req.Token)
+	if err != nil {
+		return model.LoginResponse{}, values.NotAuthorised, "Invalid or expired verification link", err
+	}
+	return api.VerifyCodeHelper(codeReq)
+}
 
-// }
+// VerifyLinkPageHelper validates a link opened in a browser. Registration links verify
+// the email straight away; login links only confirm the link is valid, since the session
+// has to be created in the app. The code is left usable for the app either way.
+func (api *API) VerifyLinkPageHelper(ctx context.Context, token string) (model.VerifyCodeRequest, error) {
+	codeReq, err := api.parseVerifyLinkToken(token)
+	if err != nil {
+		return model.VerifyCodeRequest{}, err
+	}
+	userID, err := api.VerifyCodeRepo(ctx, codeReq.Code, codeReq.Type, codeReq.Email)
+	if err != nil {
+		return model.VerifyCodeRequest{}, err
+	}
+	if codeReq.Type == "register" {
+		if err := api.UpdateEmailVerifiedStatus(ctx, userID); err != nil {
+			return model.VerifyCodeRequest{}, err
+		}
+	}
+	return codeReq, nil
+}
+
+// LogoutHelper signs the caller out: the access token is denied until it expires and,
+// when given, the refresh token is revoked so the session can't be renewed.
+func (api *API) LogoutHelper(ctx context.Context, claims *TokenClaims, refreshToken string) (string, string, error) {
+	if claims.JTI != "" {
+		err := api.RevokeAccessTokenRepo(ctx, claims.JTI, claims.UserID, time.Unix(claims.Exp, 0))
+		if err != nil {
+			return values.Error, "Failed to log out", err
+		}
+		api.revokedTokens.put(claims.JTI, true)
+	}
+	if refreshToken != "" {
+		if err := api.RevokeUserRefreshTokenRepo(ctx, claims.UserID, refreshToken); err != nil {
+			return values.Error, "Failed to log out", err
+		}
+	}
+	return values.Success, "Logged out", nil
+}
+
+// accessTokenRevoked reports whether the token was signed out. Lookups are cached for
+// tokenRevocationCacheTTL. Like resolveLoginUser it trusts the token while the database
+// can't be asked.
+func (api *API) accessTokenRevoked(ctx context.Context, claims *TokenClaims) bool {
+	if claims.JTI == "" {
+		return false
+	}
+	if revoked, ok := api.revokedTokens.get(claims.JTI); ok {
+		return revoked
+	}
+	if !api.dbAvailable() {
+		return false
+	}
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	revoked, err := api.IsAccessTokenRevokedRepo(dbCtx, claims.JTI)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Access token denylist lookup failed", "err", err)
+		return false
+	}
+	api.revokedTokens.put(claims.JTI, revoked)
+	return revoked
+}
 
 func (api *API) verifyGoogleIDToken(idToken string) (*model.NewUserInfo, error) {
 	tokenValidator, err := idtoken.NewValidator(context.Background())
@@ -377,6 +509,7 @@ func (api *API) generateAndStoreTokens(user model.User) (model.LoginResponse, st
 	if err != nil {
 		return model.LoginResponse{}, values.Error, "Failed to store refresh token", err
 	}
+	reactivated := api.reactivateOnLogin(ctx, user.ID.String())
 
 	user, err = api.GetUserByID(ctx, user.ID.String())
 	if err != nil {
@@ -391,12 +524,14 @@ func (api *API) generateAndStoreTokens(user model.User) (model.LoginResponse, st
 			LastName:          user.LastName,
 			Username:          user.Username,
 			Email:             user.Email,
+			Phone:             user.Phone,
 			ProfileIcon:       user.ProfileIcon,
 			IsVerified:        user.IsVerified,
 			PreferredLanguage: user.PreferredLanguage,
 		},
 		Token:        token,
 		RefreshToken: refreshToken,
+		Reactivated:  reactivated,
 	}
 
 	return response, values.Success, "Login successful", nil
@@ -416,14 +551,12 @@ func (api *API) GoogleLogin(idToken string) (model.LoginResponse, string, string
 
 	// Step 2: Check if the Google account is already linked to any user
 	authRecord, err := api.GetUserAuthProviderByProviderID(ctx, "google", googleUserID)
-	log.Println("auth get user error", err)
 
-	// Fix: Check for pgx.ErrNoRows instead of sql.ErrNoRows
 	if err == nil {
 		// Google account is linked to a user; fetch the user
 		user, err := api.GetUserByID(ctx, authRecord.UserID.String())
 		if err != nil {
-			return model.LoginResponse{}, values.Error, "Failed to retrieve user", err
+			return model.LoginResponse{}, errorStatus(err, values.Error), "Failed to retrieve user", err
 		}
 		if user.Email != email {
 			return model.LoginResponse{}, values.Conflict, "Google account is linked to a different email", nil
@@ -431,13 +564,12 @@ func (api *API) GoogleLogin(idToken string) (model.LoginResponse, string, string
 
 		// Generate tokens for the existing user
 		return api.generateAndStoreTokens(user)
-	} else if errors.Is(err, pgx.ErrNoRows) || err.Error() == "no rows in result set" {
-		log.Println("Google account not linked; checking if user exists by email")
+	} else if errors.Is(err, ErrNotFound) {
+		api.logger().Debug("Google account not linked; checking if user exists by email")
 		// Google account not linked; check if user exists by email
 		user, err := api.GetUserByEmail(ctx, email)
 		if err != nil {
-			// Check if the error is specifically "no rows found"
-			if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) || err.Error() == "no rows in result set" {
+			if errors.Is(err, ErrNotFound) {
 				// No user exists; register a new user
 				googleIcon := defaultProfileIcons[rand.Intn(len(defaultProfileIcons))]
 				newUser := model.User{
@@ -478,7 +610,7 @@ func (api *API) GoogleLogin(idToken string) (model.LoginResponse, string, string
 			}
 			_, err = api.InsertUserAuthProvider(ctx, authRecord)
 			if err != nil {
-				if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" { // unique_violation
+				if errors.Is(err, ErrConflict) {
 					return model.LoginResponse{}, values.Conflict, "Google account is already linked to another user", err
 				}
 				return model.LoginResponse{}, values.Error, "Failed to link Google account", err
@@ -541,20 +673,20 @@ func (api *API) FirebaseLogin(idToken string) (model.LoginResponse, string, stri
 	if err == nil {
 		user, err := api.GetUserByID(ctx, authRecord.UserID.String())
 		if err != nil {
-			return model.LoginResponse{}, values.Error, "Failed to retrieve user", err
+			return model.LoginResponse{}, errorStatus(err, values.Error), "Failed to retrieve user", err
 		}
 		if user.Email != email {
 			return model.LoginResponse{}, values.Conflict, "Firebase account is linked to a different email", nil
 		}
 		return api.generateAndStoreTokens(user)
 	}
-	if !errors.Is(err, pgx.ErrNoRows) && err.Error() != "no rows in result set" {
+	if !errors.Is(err, ErrNotFound) {
 		return model.LoginResponse{}, values.Error, "Database error checking Firebase linkage", err
 	}
 
 	user, err := api.GetUserByEmail(ctx, email)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows) || err.Error() == "no rows in result set" {
+		if errors.Is(err, ErrNotFound) {
 			fbIcon := defaultProfileIcons[rand.Intn(len(defaultProfileIcons))]
 			newUser := model.User{
 				ID:           util.GenerateUUID(),
@@ -592,7 +724,7 @@ func (api *API) FirebaseLogin(idToken string) (model.LoginResponse, string, stri
 		AuthProviderID: uid,
 	}
 	if _, err = api.InsertUserAuthProvider(ctx, link); err != nil {
-		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
+		if errors.Is(err, ErrConflict) {
 			return model.LoginResponse{}, values.Conflict, "Firebase account is already linked to another user", err
 		}
 		return model.LoginResponse{}, values.Error, "Failed to link Firebase account", err
@@ -601,51 +733,161 @@ func (api *API) FirebaseLogin(idToken string) (model.LoginResponse, string, stri
 	return api.generateAndStoreTokens(user)
 }
 
-func (api *API) RefreshAccessToken(ctx context.Context, refreshToken string) (string, string, error) {
+// RefreshAccessToken issues a new access token for a valid refresh token. The refresh
+// token itself is only rotated once it is close to expiry (see refreshNeedsRotation), so
+// frequent background refreshes from mobile clients don't each write to auth_tokens.
+func (api *API) RefreshAccessToken(ctx context.Context, refreshToken string) (model.RefreshTokenResponse, error) {
 	// Validate the refresh token
 	claims, err := api.verifyToken(refreshToken, true)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid or expired refresh token")
+		return model.RefreshTokenResponse{}, fmt.Errorf("invalid or expired refresh token")
 	}
 
 	// Ensure the token type is "refresh"
 	if claims.Type != "refresh" {
-		return "", "", fmt.Errorf("invalid token type")
+		return model.RefreshTokenResponse{}, fmt.Errorf("invalid token type")
 	}
 
 	// Check if the refresh token is revoked or expired in the database
 	userID := claims.UserID
-	err = api.ValidateRefreshToken(ctx, refreshToken)
+	err = api.ValidateRefreshToken(ctx, refreshToken, userID)
 	if err != nil {
-		return "", "", fmt.Errorf("refresh token validation failed: %w", err)
+		return model.RefreshTokenResponse{}, fmt.Errorf("refresh token validation failed: %w", err)
 	}
 
 	// Generate a new access token
-	accessToken, _, err := api.createToken(userID)
+	accessToken, accessExpiresAt, err := api.createToken(userID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+		return model.RefreshTokenResponse{}, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	resp := model.RefreshTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessExpiresAt,
+		RefreshToken:         refreshToken,
+	}
+	if !api.refreshNeedsRotation(time.Unix(claims.Exp, 0)) {
+		return resp, nil
 	}
 
-	// Optionally, generate a new refresh token
 	newRefreshToken, expiresAt, err := api.createRefreshToken(userID)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate new refresh token: %w", err)
+		return model.RefreshTokenResponse{}, fmt.Errorf("failed to generate new refresh token: %w", err)
 	}
 
-	// Store the new refresh token and revoke the old one
-	err = api.StoreRefreshToken(ctx, userID, newRefreshToken, expiresAt)
+	// Store the new refresh token in the same session and revoke the old one
+	err = api.RotateRefreshTokenRepo(ctx, refreshToken, newRefreshToken, expiresAt)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to store new refresh token: %w", err)
+		return model.RefreshTokenResponse{}, err
 	}
 
-	err = api.RevokeRefreshToken(ctx, refreshToken)
+	resp.RefreshToken = newRefreshToken
+	resp.Rotated = true
+	return resp, nil
+}
+
+// refreshNeedsRotation reports whether a refresh token expiring at expiresAt should be
+// replaced. With REFRESH_ROTATE_WITHIN_HOURS unset every refresh rotates.
+func (api *API) refreshNeedsRotation(expiresAt time.Time) bool {
+	within := time.Duration(api.Config.RefreshRotateWithinHours) * time.Hour
+	return within <= 0 || time.Until(expiresAt) < within
+}
+
+// IntrospectAccessToken checks an access token's signature, type, expiry and whether it
+// was signed out, so clients can cheaply ask whether they need to refresh. The denylist
+// lookup is usually answered from the revocation cache.
+func (api *API) IntrospectAccessToken(ctx context.Context, token string) (model.TokenIntrospection, string, string, error) {
+	claims, err := api.verifyToken(token, false)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to revoke old refresh token: %w", err)
+		return model.TokenIntrospection{}, errorStatus(err, values.NotAuthorised), "Invalid or expired token", err
+	}
+	if api.accessTokenRevoked(ctx, claims) {
+		return model.TokenIntrospection{}, values.NotAuthorised, "Token has been revoked", ErrTokenRevoked
 	}
+	expiresAt := time.Unix(claims.Exp, 0)
+	return model.TokenIntrospection{
+		Active:    true,
+		UserID:    claims.UserID,
+		ExpiresAt: expiresAt,
+		ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+	}, values.Success, "Token is valid", nil
+}
 
-	return accessToken, newRefreshToken, nil
+// startSession records the device and client a login came from on its session, then
+// issues the device's signing secret. Like issueDeviceSecret, failures don't fail the login.
+func (api *API) startSession(r *http.Request, resp *model.LoginResponse) {
+	device := deviceInfoFromRequest(r)
+	if resp.RefreshToken != "" {
+		if err := api.TagSessionRepo(r.Context(), resp.RefreshToken, device, api.clientIP(r), r.UserAgent()); err != nil {
+			api.logger().ErrorContext(r.Context(), "Failed to record session device", "err", err)
+		}
+	}
+	api.issueDeviceSecret(r.Context(), device, resp)
+}
+
+// issueDeviceSecret generates and stores a fresh signing secret for the device and
+// attaches it to the login response. Login still succeeds if this fails; the app
+// simply falls back to unsigned requests.
+func (api *API) issueDeviceSecret(ctx context.Context, device model.DeviceInfo, resp *model.LoginResponse) {
+	if device.DeviceID == "" || resp.User == nil {
+		return
+	}
+	secret, err := util.GenerateDeviceSecret()
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Failed to generate device secret", "err", err)
+		return
+	}
+	if err := api.UpsertDeviceSecret(ctx, resp.User.ID.String(), device, secret); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to store device secret", "err", err)
+		return
+	}
+	resp.DeviceSecret = secret
+
+	if device.IsEmulator {
+		api.flagSuspiciousActivity(ctx, resp.User.ID.String(), util.SuspicionEmulator, "login", map[string]interface{}{
+			"device_id":    device.DeviceID,
+			"device_model": device.Model,
+		})
+	}
+}
+
+// deviceInfoFromRequest reads the X-Device-* headers sent by the app.
+func deviceInfoFromRequest(r *http.Request) model.DeviceInfo {
+	deviceModel := strings.TrimSpace(r.Header.Get(values.HeaderDeviceModel))
+	return model.DeviceInfo{
+		DeviceID:   strings.TrimSpace(r.Header.Get(values.HeaderDeviceID)),
+		Model:      deviceModel,
+		Platform:   strings.ToLower(strings.TrimSpace(r.Header.Get(values.HeaderDevicePlatform))),
+		IsEmulator: util.IsEmulatorFingerprint(deviceModel),
+	}
 }
 
 func (api *API) generateLink() {
 
 }
+
+// authTokenSweepInterval is how often expired refresh tokens and access token denials
+// are deleted.
+const authTokenSweepInterval = time.Hour
+
+// RunAuthTokenSweeper deletes expired auth_tokens and revoked_access_tokens rows every
+// hour until ctx is cancelled. Neither is accepted once expired, so nothing is lost.
+func (api *API) RunAuthTokenSweeper(ctx context.Context) {
+	ticker := time.NewTicker(authTokenSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !api.dbAvailable() {
+				continue
+			}
+			n, err := api.PurgeExpiredAuthTokensRepo(ctx)
+			if err != nil {
+				api.logger().ErrorContext(ctx, "Purging expired auth tokens failed", "err", err)
+			} else if n > 0 {
+				api.logger().InfoContext(ctx, "Purged expired auth tokens", "count", n)
+			}
+		}
+	}
+}
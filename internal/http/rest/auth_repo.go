@@ -3,7 +3,6 @@ package rest
 import (
 	"context"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
@@ -22,7 +21,7 @@ func (api *API) EmailExists(ctx context.Context, email string) (bool, error) {
 	// err := api.Deps.DB.QueryRow(ctx, stmt, email).Scan(&exists)
 	err := api.DB.QueryRow(ctx, stmt, email).Scan(&exists)
 	if err != nil {
-		log.Println("error checking email", err)
+		api.logger().ErrorContext(ctx, "Failed to check email", "err", err)
 		return false, err
 	}
 	return exists, nil
@@ -40,8 +39,8 @@ func (api *API) CreateNewUserRepo(ctx context.Context, req model.User) error {
     `
 	_, err := api.Deps.DB.Pool().Exec(ctx, stmt, req.ID, req.Email, req.AuthProvider, req.Username, req.ProfileIcon)
 	if err != nil {
-		log.Println("error creating new user", err)
-		return err
+		api.logger().ErrorContext(ctx, "Failed to create user", "err", err)
+		return dbError(err)
 	}
 	return nil
 }
@@ -83,7 +82,7 @@ func (api *API) CreateGoogleUserRepo(ctx context.Context, req model.User) (model
 		&user.ProfileIcon,
 	)
 	if err != nil {
-		log.Println("error creating new Google user", err)
+		api.logger().ErrorContext(ctx, "Failed to create Google user", "err", err)
 		return model.User{}, err
 	}
 
@@ -100,32 +99,37 @@ func (api *API) GetUserByEmail(ctx context.Context, email string) (model.User, e
 		&user.Email,
 	)
 	if err != nil {
-		log.Println("error getting user by email", err)
-		return model.User{}, err
+		api.logger().ErrorContext(ctx, "Failed to get user by email", "err", err)
+		return model.User{}, dbError(err)
 	}
 	return user, nil
 }
 
 func (api *API) GetUserByID(ctx context.Context, userID string) (model.User, error) {
 	var user model.User
-	stmt := `SELECT id, email, firstname, lastname, username, auth_provider, is_verified, preferred_language, created_at, updated_at, profile_icon FROM users WHERE id = $1`
+	stmt := `SELECT id, COALESCE(email, ''), phone, firstname, lastname, username, auth_provider, is_verified, preferred_language, role, route_preference, created_at, updated_at, profile_icon, deactivated_at, recent_destinations_enabled FROM users WHERE id = $1`
 
 	err := api.Deps.DB.Pool().QueryRow(ctx, stmt, userID).Scan(
 		&user.ID,
 		&user.Email,
+		&user.Phone,
 		&user.FirstName,
 		&user.LastName,
 		&user.Username,
 		&user.AuthProvider,
 		&user.IsVerified,
 		&user.PreferredLanguage,
+		&user.Role,
+		&user.RoutePreference,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.ProfileIcon,
+		&user.DeactivatedAt,
+		&user.RecentDestinationsEnabled,
 	)
 	if err != nil {
-		log.Println("error getting user by ID", err)
-		return model.User{}, err
+		api.logger().ErrorContext(ctx, "Failed to get user by ID", "err", err)
+		return model.User{}, dbError(err)
 	}
 	return user, nil
 }
@@ -137,7 +141,7 @@ func (api *API) StoreVerificationCode(ctx context.Context, userID string, email
     `
 	_, err := api.DB.Exec(ctx, stmt, userID, email, code, tokenType, expiresAt)
 	if err != nil {
-		log.Println("error storing verification code", err)
+		api.logger().ErrorContext(ctx, "Failed to store verification code", "err", err)
 	}
 	return err
 }
@@ -155,19 +159,20 @@ func (api *API) StoreRefreshToken(ctx context.Context, userID, token string, exp
 	return nil
 }
 
-func (api *API) ValidateRefreshToken(ctx context.Context, token string) error {
+// ValidateRefreshToken checks that the refresh token belongs to userID and hasn't been
+// revoked (signed out, or its session ended from another device), and records the use.
+func (api *API) ValidateRefreshToken(ctx context.Context, token, userID string) error {
 	query := `
-        SELECT 1 FROM auth_tokens
-        WHERE token_value = $1 AND token_type = 'refresh' AND is_revoked = FALSE AND expires_at > NOW()
+        UPDATE auth_tokens SET last_used_at = NOW()
+        WHERE token_value = $1 AND user_id = $2 AND token_type = 'refresh' AND is_revoked = FALSE AND expires_at > NOW()
     `
-	var exists int
-	err := api.DB.QueryRow(ctx, query, token).Scan(&exists)
+	tag, err := api.DB.Exec(ctx, query, token, userID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return fmt.Errorf("refresh token is invalid or expired")
-		}
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("refresh token is invalid or revoked: %w", ErrInvalidToken)
+	}
 	return nil
 }
 
@@ -184,13 +189,68 @@ func (api *API) RevokeRefreshToken(ctx context.Context, token string) error {
 	return nil
 }
 
+// RevokeUserRefreshTokenRepo revokes one of userID's refresh tokens. A token that isn't
+// theirs, or is already revoked, is left alone.
+func (api *API) RevokeUserRefreshTokenRepo(ctx context.Context, userID, token string) error {
+	_, err := api.DB.Exec(ctx, `
+        UPDATE auth_tokens SET is_revoked = TRUE
+        WHERE token_value = $1 AND user_id = $2 AND token_type = 'refresh'
+    `, token, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessTokenRepo denies an access token until expiresAt, clearing out entries
+// that have expired on the way.
+func (api *API) RevokeAccessTokenRepo(ctx context.Context, jti, userID string, expiresAt time.Time) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < NOW()`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+            INSERT INTO revoked_access_tokens (jti, user_id, expires_at)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (jti) DO NOTHING
+        `, jti, userID, expiresAt)
+		return err
+	})
+}
+
+// PurgeExpiredAuthTokensRepo deletes expired refresh tokens and access token denials
+// and returns how many went.
+func (api *API) PurgeExpiredAuthTokensRepo(ctx context.Context) (int64, error) {
+	var purged int64
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM auth_tokens WHERE expires_at < NOW()`)
+		if err != nil {
+			return fmt.Errorf("purging expired auth tokens: %w", err)
+		}
+		purged = tag.RowsAffected()
+		tag, err = tx.Exec(ctx, `DELETE FROM revoked_access_tokens WHERE expires_at < NOW()`)
+		if err != nil {
+			return fmt.Errorf("purging expired access token denials: %w", err)
+		}
+		purged += tag.RowsAffected()
+		return nil
+	})
+	return purged, err
+}
+
+func (api *API) IsAccessTokenRevokedRepo(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := api.DB.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	return revoked, err
+}
+
 func (api *API) VerifyCodeRepo(ctx context.Context, code string, tokenType string, email string) (string, error) {
 	var userID string
 	stmt := `SELECT user_id FROM email_verifications WHERE verification_code = $1 AND type = $2 AND email= $3 AND expires_at > NOW()`
 
 	err := api.Deps.DB.Pool().QueryRow(ctx, stmt, code, tokenType, email).Scan(&userID)
 	if err != nil {
-		log.Println("error verifying code", err)
+		api.logger().ErrorContext(ctx, "Failed to verify code", "err", err)
 		return "", err
 	}
 	return userID, nil
@@ -201,7 +261,7 @@ func (api *API) UpdateEmailVerifiedStatus(ctx context.Context, userID string) er
 
 	_, err := api.Deps.DB.Pool().Exec(ctx, stmt, userID)
 	if err != nil {
-		log.Println("error updating email verification status", err)
+		api.logger().ErrorContext(ctx, "Failed to update email verification status", "err", err)
 		return err
 	}
 	return nil
@@ -223,8 +283,8 @@ func (api *API) InsertUserAuthProvider(ctx context.Context, uauthRecord model.Us
 		&authRecord.AuthProviderID,
 	)
 	if err != nil {
-		log.Println("error inserting into user_auth_providers", err)
-		return model.UserAuthProvider{}, err
+		api.logger().ErrorContext(ctx, "Failed to insert user auth provider", "err", err)
+		return model.UserAuthProvider{}, dbError(err)
 	}
 
 	return authRecord, nil
@@ -244,7 +304,7 @@ func (api *API) GetUserAuthProviderByProviderID(ctx context.Context, authProvide
 		&authRecord.AuthProviderID,
 	)
 	if err != nil {
-		return model.UserAuthProvider{}, err
+		return model.UserAuthProvider{}, dbError(err)
 	}
 	return authRecord, nil
 }
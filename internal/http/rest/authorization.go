@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// Permission names an action that only some site roles (users.role) may take. Group
+// roles (group_memberships.role) are separate; PermModerateGroups lets a site role act
+// as a group admin anywhere.
+type Permission string
+
+const (
+	// Approve, reject and merge reports in the review queue.
+	PermModerateReports Permission = "moderate_reports"
+	// Delete other people's comments.
+	PermModerateComments Permission = "moderate_comments"
+	// Manage members, join requests and pinned messages of any group.
+	PermModerateGroups Permission = "moderate_groups"
+	// Look up who reported what (report search by reporter).
+	PermViewReporters Permission = "view_reporters"
+	// Confirm or dismiss accounts flagged for fabricated locations.
+	PermReviewSuspiciousActivity Permission = "review_suspicious_activity"
+)
+
+// rolePermissions is the permission matrix. Roles not listed, including user, have none.
+// The rest of /admin is for admins alone (RequireRole).
+var rolePermissions = map[string][]Permission{
+	values.RoleModerator: {PermModerateReports, PermModerateComments, PermModerateGroups, PermReviewSuspiciousActivity},
+	values.RoleAdmin:     {PermModerateReports, PermModerateComments, PermModerateGroups, PermViewReporters, PermReviewSuspiciousActivity},
+}
+
+var (
+	ErrInsufficientRole  = errors.New("role not allowed")
+	ErrMissingPermission = errors.New("permission not granted")
+)
+
+func roleHas(role string, p Permission) bool {
+	return slices.Contains(rolePermissions[role], p)
+}
+
+// callerCan reports whether the role RequireLogin loaded for the request grants p.
+func callerCan(ctx context.Context, p Permission) bool {
+	return roleHas(util.GetUserRoleFromContext(ctx), p)
+}
+
+// RequireRole only lets users with one of roles through. Must run after RequireLogin.
+func (api *API) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(roles, util.GetUserRoleFromContext(r.Context())) {
+				writeErrorResponse(w, ErrInsufficientRole, values.NotAllowed, "insufficient-role")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission only lets users whose role grants p through. Must run after
+// RequireLogin.
+func (api *API) RequirePermission(p Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !callerCan(r.Context(), p) {
+				writeErrorResponse(w, ErrMissingPermission, values.NotAllowed, "missing-permission")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func TestRoleHas(t *testing.T) {
+	cases := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{values.RoleUser, PermModerateReports, false},
+		{values.RoleModerator, PermModerateReports, true},
+		{values.RoleModerator, PermViewReporters, false},
+		{values.RoleAdmin, PermViewReporters, true},
+		{values.RoleUser, PermReviewSuspiciousActivity, false},
+		{values.RoleModerator, PermReviewSuspiciousActivity, true},
+		{"", PermModerateComments, false},
+	}
+	for _, c := range cases {
+		if got := roleHas(c.role, c.perm); got != c.want {
+			t.Errorf("roleHas(%q, %s) = %v, want %v", c.role, c.perm, got, c.want)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	api := &API{}
+	handler := api.RequireRole(values.RoleModerator, values.RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for role, want := range map[string]int{
+		values.RoleUser:      http.StatusForbidden,
+		values.RoleModerator: http.StatusNoContent,
+		values.RoleAdmin:     http.StatusNoContent,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), "user_role", role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("role %s: status %d, want %d", role, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultBroadcastRadiusM is used when no policy matches or the table can't be read.
+	defaultBroadcastRadiusM = 5000
+	broadcastPolicyCacheTTL = time.Minute
+	// wildcardReportType is the fallback policy row applied to any report type.
+	wildcardReportType = "*"
+)
+
+// broadcastPolicyCache keeps the (small) policy table in memory so report
+// creation doesn't query it every time. Admin edits invalidate it.
+type broadcastPolicyCache struct {
+	mu       sync.RWMutex
+	policies []model.BroadcastPolicy
+	loadedAt time.Time
+}
+
+func (c *broadcastPolicyCache) get() ([]model.BroadcastPolicy, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadedAt.IsZero() || time.Since(c.loadedAt) > broadcastPolicyCacheTTL {
+		return nil, false
+	}
+	return c.policies, true
+}
+
+func (c *broadcastPolicyCache) set(policies []model.BroadcastPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies = policies
+	c.loadedAt = time.Now()
+}
+
+func (c *broadcastPolicyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
+
+// BroadcastRadiusFor returns the report_update broadcast radius in meters for a report.
+func (api *API) BroadcastRadiusFor(ctx context.Context, reportType string, severity int) float64 {
+	policies, ok := api.broadcastPolicies.get()
+	if !ok {
+		var err error
+		policies, err = api.ListBroadcastPoliciesRepo(ctx)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Failed to load broadcast policies, using default radius", "err", err)
+			return defaultBroadcastRadiusM
+		}
+		api.broadcastPolicies.set(policies)
+	}
+	return resolveBroadcastRadius(policies, reportType, severity)
+}
+
+// resolveBroadcastRadius picks the type-specific policy with the highest min_severity
+// not above severity, falling back to the wildcard policy, then the default.
+func resolveBroadcastRadius(policies []model.BroadcastPolicy, reportType string, severity int) float64 {
+	reportType = strings.ToUpper(reportType)
+	var typed, wildcard *model.BroadcastPolicy
+	for i := range policies {
+		p := &policies[i]
+		if p.MinSeverity > severity {
+			continue
+		}
+		switch p.ReportType {
+		case reportType:
+			if typed == nil || p.MinSeverity > typed.MinSeverity {
+				typed = p
+			}
+		case wildcardReportType:
+			if wildcard == nil || p.MinSeverity > wildcard.MinSeverity {
+				wildcard = p
+			}
+		}
+	}
+	if typed != nil {
+		return float64(typed.RadiusM)
+	}
+	if wildcard != nil {
+		return float64(wildcard.RadiusM)
+	}
+	return defaultBroadcastRadiusM
+}
+
+func (api *API) ListBroadcastPoliciesHelper(ctx context.Context) ([]model.BroadcastPolicy, string, string, error) {
+	policies, err := api.ListBroadcastPoliciesRepo(ctx)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch broadcast policies", err
+	}
+	if policies == nil {
+		policies = []model.BroadcastPolicy{}
+	}
+	return policies, values.Success, "Broadcast policies fetched successfully", nil
+}
+
+func (api *API) UpsertBroadcastPolicyHelper(ctx context.Context, req model.UpsertBroadcastPolicyRequest, adminID uuid.UUID) (model.BroadcastPolicy, string, string, error) {
+	req.ReportType = strings.ToUpper(strings.TrimSpace(req.ReportType))
+	policy, err := api.UpsertBroadcastPolicyRepo(ctx, req, adminID)
+	if err != nil {
+		return model.BroadcastPolicy{}, values.Error, "Failed to save broadcast policy", err
+	}
+	api.broadcastPolicies.invalidate()
+	return policy, values.Success, "Broadcast policy saved", nil
+}
+
+func (api *API) DeleteBroadcastPolicyHelper(ctx context.Context, id int64) (string, string, error) {
+	err := api.DeleteBroadcastPolicyRepo(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrBroadcastPolicyNotFound) {
+			return values.NotFound, "Broadcast policy not found", err
+		}
+		return values.Error, "Failed to delete broadcast policy", err
+	}
+	api.broadcastPolicies.invalidate()
+	return values.Success, "Broadcast policy deleted", nil
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrBroadcastPolicyNotFound = fmt.Errorf("broadcast policy %w", ErrNotFound)
+
+func (api *API) ListBroadcastPoliciesRepo(ctx context.Context) ([]model.BroadcastPolicy, error) {
+	query := `
+        SELECT id, report_type, min_severity, radius_m, updated_by, updated_at
+        FROM report_broadcast_policies
+        ORDER BY report_type, min_severity
+    `
+	rows, err := api.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []model.BroadcastPolicy
+	for rows.Next() {
+		var p model.BroadcastPolicy
+		if err := rows.Scan(&p.ID, &p.ReportType, &p.MinSeverity, &p.RadiusM, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (api *API) UpsertBroadcastPolicyRepo(ctx context.Context, req model.UpsertBroadcastPolicyRequest, updatedBy uuid.UUID) (model.BroadcastPolicy, error) {
+	query := `
+        INSERT INTO report_broadcast_policies (report_type, min_severity, radius_m, updated_by, updated_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        ON CONFLICT (report_type, min_severity) DO UPDATE SET
+            radius_m = EXCLUDED.radius_m,
+            updated_by = EXCLUDED.updated_by,
+            updated_at = NOW()
+        RETURNING id, report_type, min_severity, radius_m, updated_by, updated_at
+    `
+	var p model.BroadcastPolicy
+	err := api.DB.QueryRow(ctx, query, req.ReportType, req.MinSeverity, req.RadiusM, updatedBy).Scan(
+		&p.ID, &p.ReportType, &p.MinSeverity, &p.RadiusM, &p.UpdatedBy, &p.UpdatedAt,
+	)
+	return p, err
+}
+
+func (api *API) DeleteBroadcastPolicyRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM report_broadcast_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrBroadcastPolicyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/calendar"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	calendarSyncInterval = 5 * time.Minute
+	// Feeds are re-fetched this long after their last sync (Postgres interval).
+	calendarResyncAfter = "30 minutes"
+	calendarSyncBatch   = 20
+	calendarSyncTimeout = 2 * time.Minute
+	// Only events starting within calendarLookahead become drives, at most
+	// calendarMaxEvents per feed, to bound geocoding and routing calls.
+	calendarLookahead    = 48 * time.Hour
+	calendarMaxEvents    = 20
+	calendarFeedMaxBytes = 2 << 20
+	// Without a route, drive time is estimated from straight-line distance.
+	calendarDetourFactor  = 1.3
+	calendarFallbackSpeed = 8.3 // m/s, ~30 km/h
+)
+
+// calendarTimeZone reads event times that carry no zone; users are in Cyprus.
+var calendarTimeZone = func() *time.Location {
+	if loc, err := time.LoadLocation("Europe/Nicosia"); err == nil {
+		return loc
+	}
+	return time.UTC
+}()
+
+// calendarHTTPClient fetches user-supplied feed URLs, so it refuses to connect to
+// loopback, private and link-local addresses.
+var calendarHTTPClient = &http.Client{
+	Timeout: 20 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: publicAddressOnly}).DialContext,
+	},
+}
+
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("calendar feed address %s is not public", host)
+	}
+	return nil
+}
+
+// normalizeFeedURL accepts http(s) and webcal(s) feed URLs; webcal is fetched over https.
+func normalizeFeedURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("missing host")
+	}
+	return u.String(), nil
+}
+
+func (api *API) ConnectCalendarHelper(ctx context.Context, userID uuid.UUID, req model.ConnectCalendarRequest) (model.CalendarIntegration, string, string, error) {
+	feedURL, err := normalizeFeedURL(req.FeedURL)
+	if err != nil {
+		return model.CalendarIntegration{}, values.BadRequestBody, "feed_url must be an http(s) or webcal ICS URL", err
+	}
+	if req.OriginLatitude != nil {
+		lat, lng, err := util.NormalizeCoordinate(*req.OriginLatitude, *req.OriginLongitude)
+		if err != nil {
+			return model.CalendarIntegration{}, values.BadRequestBody, "invalid origin latitude or longitude", err
+		}
+		req.OriginLatitude, req.OriginLongitude = &lat, &lng
+	}
+
+	integration, err := api.UpsertCalendarIntegrationRepo(ctx, userID, feedURL, req.OriginLatitude, req.OriginLongitude)
+	if err != nil {
+		return model.CalendarIntegration{}, values.Error, "Failed to connect calendar", err
+	}
+	// First suggestions shouldn't wait for the worker's next pass.
+	go func() {
+		syncCtx, cancel := context.WithTimeout(context.Background(), calendarSyncTimeout)
+		defer cancel()
+		api.syncCalendar(syncCtx, integration)
+	}()
+	return integration, values.Success, "Calendar connected", nil
+}
+
+func (api *API) GetCalendarIntegrationHelper(ctx context.Context, userID uuid.UUID) (model.CalendarIntegration, string, string, error) {
+	integration, err := api.GetCalendarIntegrationRepo(ctx, userID)
+	if errors.Is(err, ErrCalendarIntegrationNotFound) {
+		return integration, values.NotFound, "No calendar connected", err
+	}
+	if err != nil {
+		return integration, values.Error, "Failed to fetch calendar integration", err
+	}
+	return integration, values.Success, "Calendar integration fetched", nil
+}
+
+func (api *API) DisconnectCalendarHelper(ctx context.Context, userID uuid.UUID) (string, string, error) {
+	err := api.DeleteCalendarIntegrationRepo(ctx, userID)
+	if errors.Is(err, ErrCalendarIntegrationNotFound) {
+		return values.NotFound, "No calendar connected", err
+	}
+	if err != nil {
+		return values.Error, "Failed to disconnect calendar", err
+	}
+	return values.Success, "Calendar disconnected", nil
+}
+
+func (api *API) ListPlannedDrivesHelper(ctx context.Context, userID uuid.UUID) ([]model.PlannedDrive, string, string, error) {
+	drives, err := api.ListPlannedDrivesRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch planned drives", err
+	}
+	if drives == nil {
+		drives = []model.PlannedDrive{}
+	}
+	return drives, values.Success, "Planned drives fetched", nil
+}
+
+func (api *API) DismissPlannedDriveHelper(ctx context.Context, userID uuid.UUID, id int64) (string, string, error) {
+	err := api.DismissPlannedDriveRepo(ctx, userID, id)
+	if errors.Is(err, ErrPlannedDriveNotFound) {
+		return values.NotFound, "Planned drive not found", err
+	}
+	if err != nil {
+		return values.Error, "Failed to dismiss planned drive", err
+	}
+	return values.Success, "Planned drive dismissed", nil
+}
+
+// RunCalendarSync refreshes due calendar feeds until ctx is cancelled.
+func (api *API) RunCalendarSync(ctx context.Context) {
+	ticker := time.NewTicker(calendarSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !api.dbAvailable() {
+				continue
+			}
+			integrations, err := api.DueCalendarIntegrationsRepo(ctx, calendarResyncAfter, calendarSyncBatch)
+			if err != nil {
+				api.logger().ErrorContext(ctx, "Calendar sync: failed to load due feeds", "err", err)
+				continue
+			}
+			for _, integration := range integrations {
+				syncCtx, cancel := context.WithTimeout(ctx, calendarSyncTimeout)
+				api.syncCalendar(syncCtx, integration)
+				cancel()
+			}
+		}
+	}
+}
+
+// syncCalendar turns the feed's upcoming located events into planned drives and records
+// the outcome on the integration.
+func (api *API) syncCalendar(ctx context.Context, integration model.CalendarIntegration) {
+	drives, err := api.calendarDrives(ctx, integration)
+	if err == nil {
+		err = api.ReplaceCalendarDrivesRepo(ctx, integration.UserID, drives)
+	}
+
+	var syncErr *string
+	if err != nil {
+		api.logger().WarnContext(ctx, "Calendar sync failed", "calendar_user_id", integration.UserID, "err", err)
+		msg := err.Error()
+		syncErr = &msg
+	}
+	if err := api.MarkCalendarSyncedRepo(ctx, integration.UserID, syncErr); err != nil {
+		api.logger().ErrorContext(ctx, "Calendar sync: failed to record sync", "calendar_user_id", integration.UserID, "err", err)
+	}
+}
+
+func (api *API) calendarDrives(ctx context.Context, integration model.CalendarIntegration) ([]model.PlannedDriveUpsert, error) {
+	events, err := fetchCalendarEvents(ctx, integration.FeedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var originLat, originLng float64
+	if integration.OriginLat != nil && integration.OriginLng != nil {
+		originLat, originLng = *integration.OriginLat, *integration.OriginLng
+	} else {
+		var ok bool
+		originLat, originLng, ok, err = api.LatestUserLocationRepo(ctx, integration.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("no origin: set origin_latitude/origin_longitude or share your location once")
+		}
+	}
+
+	now := time.Now()
+	var drives []model.PlannedDriveUpsert
+	for _, e := range events {
+		if len(drives) >= calendarMaxEvents {
+			break
+		}
+		if e.AllDay || e.Cancelled || !e.Start.After(now) || e.Start.After(now.Add(calendarLookahead)) {
+			continue
+		}
+		if e.Location == "" && e.Lat == nil {
+			continue
+		}
+
+		lat, lng, ok := api.calendarEventPosition(ctx, e, originLat, originLng)
+		if !ok {
+			continue
+		}
+		duration := api.estimateDriveSeconds(ctx, originLat, originLng, lat, lng)
+		drive := model.PlannedDriveUpsert{
+			ExternalID:      e.UID,
+			DestinationName: e.Location,
+			Latitude:        lat,
+			Longitude:       lng,
+			ArriveBy:        e.Start,
+			DepartAt:        departureTime(e.Start, duration),
+			DurationS:       duration,
+		}
+		if e.Summary != "" {
+			title := e.Summary
+			drive.Title = &title
+		}
+		if drive.DestinationName == "" {
+			drive.DestinationName = fmt.Sprintf("%.5f, %.5f", lat, lng)
+		}
+		drives = append(drives, drive)
+	}
+	return drives, nil
+}
+
+func fetchCalendarEvents(ctx context.Context, feedURL string) ([]calendar.Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	resp, err := calendarHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching calendar feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching calendar feed: status %d", resp.StatusCode)
+	}
+	events, err := calendar.Parse(io.LimitReader(resp.Body, calendarFeedMaxBytes), calendarTimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("reading calendar feed: %w", err)
+	}
+	return events, nil
+}
+
+// calendarEventPosition uses the event's GEO property, else geocodes its location text
+// near the origin.
+func (api *API) calendarEventPosition(ctx context.Context, e calendar.Event, originLat, originLng float64) (float64, float64, bool) {
+	if e.Lat != nil && e.Lng != nil {
+		lat, lng, err := util.NormalizeCoordinate(*e.Lat, *e.Lng)
+		return lat, lng, err == nil
+	}
+	results, err := api.searchPlaces(ctx, "", e.Location, &stadiamaps.GeocodeQuery{
+		Size:          util.IntPtr(1),
+		FocusPointLat: &originLat,
+		FocusPointLon: &originLng,
+	})
+	if err != nil {
+		api.logger().WarnContext(ctx, "Calendar sync: failed to geocode event location", "location", e.Location, "err", err)
+		return 0, 0, false
+	}
+	if len(results) == 0 || len(results[0].Coordinates) < 2 {
+		return 0, 0, false
+	}
+	return results[0].Coordinates[1], results[0].Coordinates[0], true
+}
+
+// estimateDriveSeconds routes the trip on Valhalla, adding the delay from live reports on
+// the route, and falls back to a straight-line estimate.
+func (api *API) estimateDriveSeconds(ctx context.Context, fromLat, fromLng, toLat, toLng float64) float64 {
+	if api.ValhallaClient != nil {
+		route, err := api.ValhallaClient.GetRoute(ctx, valhalla.RouteRequest{
+			Locations: []valhalla.Location{{Lat: fromLat, Lon: fromLng}, {Lat: toLat, Lon: toLng}},
+			Costing:   "auto",
+		})
+		if err == nil && route.Trip.Summary.TotalTimeSeconds > 0 {
+			api.addMobileTripReportDelays(ctx, &route.Trip)
+			if route.Trip.Summary.AdjustedTimeSeconds > 0 {
+				return route.Trip.Summary.AdjustedTimeSeconds
+			}
+			return route.Trip.Summary.TotalTimeSeconds
+		}
+		if err != nil {
+			api.logger().WarnContext(ctx, "Calendar sync: routing failed, estimating drive time", "err", err)
+		}
+	}
+	return util.HaversineMeters(fromLat, fromLng, toLat, toLng) * calendarDetourFactor / calendarFallbackSpeed
+}
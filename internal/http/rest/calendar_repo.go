@@ -0,0 +1,182 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrCalendarIntegrationNotFound = fmt.Errorf("calendar integration %w", ErrNotFound)
+	ErrPlannedDriveNotFound        = fmt.Errorf("planned drive %w", ErrNotFound)
+)
+
+const calendarIntegrationColumns = `
+    user_id, feed_url, ST_Y(origin), ST_X(origin), last_synced_at, last_error, created_at, updated_at`
+
+func scanCalendarIntegration(row pgx.Row) (model.CalendarIntegration, error) {
+	var c model.CalendarIntegration
+	err := row.Scan(&c.UserID, &c.FeedURL, &c.OriginLat, &c.OriginLng, &c.LastSyncedAt, &c.LastError, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// UpsertCalendarIntegrationRepo connects or replaces the user's feed. The sync state is
+// cleared so the worker picks the feed up on its next pass.
+func (api *API) UpsertCalendarIntegrationRepo(ctx context.Context, userID uuid.UUID, feedURL string, originLat, originLng *float64) (model.CalendarIntegration, error) {
+	return scanCalendarIntegration(api.DB.QueryRow(ctx, `
+        INSERT INTO calendar_integrations (user_id, feed_url, origin)
+        VALUES ($1, $2, CASE WHEN $3::float8 IS NULL THEN NULL ELSE ST_SetSRID(ST_MakePoint($4, $3), 4326) END)
+        ON CONFLICT (user_id) DO UPDATE SET
+            feed_url = EXCLUDED.feed_url,
+            origin = EXCLUDED.origin,
+            last_synced_at = NULL,
+            last_error = NULL,
+            updated_at = NOW()
+        RETURNING`+calendarIntegrationColumns,
+		userID, feedURL, originLat, originLng))
+}
+
+func (api *API) GetCalendarIntegrationRepo(ctx context.Context, userID uuid.UUID) (model.CalendarIntegration, error) {
+	c, err := scanCalendarIntegration(api.DB.QueryRow(ctx,
+		`SELECT`+calendarIntegrationColumns+` FROM calendar_integrations WHERE user_id = $1`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return c, ErrCalendarIntegrationNotFound
+	}
+	return c, err
+}
+
+// DeleteCalendarIntegrationRepo disconnects the feed and drops the drives it suggested.
+func (api *API) DeleteCalendarIntegrationRepo(ctx context.Context, userID uuid.UUID) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `DELETE FROM calendar_integrations WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrCalendarIntegrationNotFound
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM planned_drives WHERE user_id = $1 AND source = $2`,
+			userID, values.PlannedDriveSourceCalendar)
+		return err
+	})
+}
+
+// DueCalendarIntegrationsRepo returns feeds never synced or last synced before resyncAfter
+// (a Postgres interval) ago, least recently synced first.
+func (api *API) DueCalendarIntegrationsRepo(ctx context.Context, resyncAfter string, limit int) ([]model.CalendarIntegration, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT`+calendarIntegrationColumns+`
+        FROM calendar_integrations
+        WHERE last_synced_at IS NULL OR last_synced_at < NOW() - $1::interval
+        ORDER BY last_synced_at NULLS FIRST
+        LIMIT $2
+    `, resyncAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var integrations []model.CalendarIntegration
+	for rows.Next() {
+		c, err := scanCalendarIntegration(rows)
+		if err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, c)
+	}
+	return integrations, rows.Err()
+}
+
+// MarkCalendarSyncedRepo records a sync attempt; syncErr is nil on success.
+func (api *API) MarkCalendarSyncedRepo(ctx context.Context, userID uuid.UUID, syncErr *string) error {
+	_, err := api.DB.Exec(ctx, `
+        UPDATE calendar_integrations SET last_synced_at = NOW(), last_error = $2
+        WHERE user_id = $1
+    `, userID, syncErr)
+	return err
+}
+
+// LatestUserLocationRepo returns the user's most recent location ping, if any.
+func (api *API) LatestUserLocationRepo(ctx context.Context, userID uuid.UUID) (lat, lng float64, ok bool, err error) {
+	err = api.DB.QueryRow(ctx, `
+        SELECT ST_Y(position), ST_X(position) FROM user_location_pings
+        WHERE user_id = $1
+        ORDER BY recorded_at DESC
+        LIMIT 1
+    `, userID).Scan(&lat, &lng)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	return lat, lng, err == nil, err
+}
+
+// ReplaceCalendarDrivesRepo upserts the drives for the feed's upcoming events and removes
+// suggestions for upcoming events no longer in the feed. Dismissed drives stay dismissed;
+// an event that moved gets a new leave reminder.
+func (api *API) ReplaceCalendarDrivesRepo(ctx context.Context, userID uuid.UUID, drives []model.PlannedDriveUpsert) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		externalIDs := make([]string, len(drives))
+		for i, d := range drives {
+			externalIDs[i] = d.ExternalID
+			_, err := tx.Exec(ctx, `
+                INSERT INTO planned_drives
+                    (user_id, source, external_id, title, destination_name, destination, arrive_by, depart_at, duration_s)
+                VALUES ($1, $2, $3, $4, $5, ST_SetSRID(ST_MakePoint($7, $6), 4326), $8, $9, $10)
+                ON CONFLICT (user_id, source, external_id) DO UPDATE SET
+                    title = EXCLUDED.title,
+                    destination_name = EXCLUDED.destination_name,
+                    destination = EXCLUDED.destination,
+                    arrive_by = EXCLUDED.arrive_by,
+                    depart_at = EXCLUDED.depart_at,
+                    duration_s = EXCLUDED.duration_s,
+                    notified_at = CASE WHEN planned_drives.arrive_by = EXCLUDED.arrive_by
+                        THEN planned_drives.notified_at END,
+                    updated_at = NOW()
+            `, userID, values.PlannedDriveSourceCalendar, d.ExternalID, d.Title, d.DestinationName,
+				d.Latitude, d.Longitude, d.ArriveBy, d.DepartAt, d.DurationS)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, `
+            DELETE FROM planned_drives
+            WHERE user_id = $1 AND source = $2 AND status = $3
+              AND arrive_by > NOW() AND NOT (external_id = ANY($4))
+        `, userID, values.PlannedDriveSourceCalendar, values.PlannedDriveSuggested, externalIDs)
+		return err
+	})
+}
+
+// ListPlannedDrivesRepo returns the user's suggested and scheduled drives that haven't
+// happened yet, soonest first.
+func (api *API) ListPlannedDrivesRepo(ctx context.Context, userID uuid.UUID) ([]model.PlannedDrive, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT`+plannedDriveColumns+`
+        FROM planned_drives
+        WHERE user_id = $1 AND status = $2 AND arrive_by > NOW()
+        ORDER BY depart_at
+    `, userID, values.PlannedDriveSuggested)
+	if err != nil {
+		return nil, err
+	}
+	return scanPlannedDrives(rows)
+}
+
+func (api *API) DismissPlannedDriveRepo(ctx context.Context, userID uuid.UUID, id int64) error {
+	result, err := api.DB.Exec(ctx, `
+        UPDATE planned_drives SET status = $3, updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+    `, id, userID, values.PlannedDriveDismissed)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPlannedDriveNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// trustedProxies is Config.TrustedProxies, parsed on first use.
+type trustedProxies struct {
+	once     sync.Once
+	prefixes []netip.Prefix
+}
+
+func (api *API) trustedProxyPrefixes() []netip.Prefix {
+	api.proxies.once.Do(func() {
+		for _, entry := range strings.Split(api.Config.TrustedProxies, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				addr, addrErr := netip.ParseAddr(entry)
+				if addrErr != nil {
+					api.logger().Warn("Ignoring invalid TRUSTED_PROXIES entry", "entry", entry, "err", err)
+					continue
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			api.proxies.prefixes = append(api.proxies.prefixes, prefix.Masked())
+		}
+	})
+	return api.proxies.prefixes
+}
+
+func isTrustedProxy(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP is the address per-IP rate limits, quotas and sessions are keyed on. The
+// connection's peer is used unless it is a trusted proxy (Config.TrustedProxies); then
+// X-Forwarded-For is read right to left and the first hop that isn't a trusted proxy is
+// the client. Hops further left were written by the client and are never believed.
+func (api *API) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	prefixes := api.trustedProxyPrefixes()
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(prefixes, peer) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrustedProxy(prefixes, hop) {
+			return hop.Unmap().String()
+		}
+		peer = hop
+	}
+	return peer.Unmap().String()
+}
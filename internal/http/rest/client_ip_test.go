@@ -0,0 +1,37 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/config"
+)
+
+func TestClientIP(t *testing.T) {
+	api := &API{Config: &config.Config{TrustedProxies: "10.0.0.0/8, 192.0.2.7, bogus"}}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", "203.0.113.5:4321", nil, "203.0.113.5"},
+		{"untrusted peer's header is ignored", "203.0.113.5:4321", []string{"198.51.100.1"}, "203.0.113.5"},
+		{"through the load balancer", "10.1.2.3:80", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed hops left of the client", "10.1.2.3:80", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chained proxies", "10.1.2.3:80", []string{"198.51.100.1, 192.0.2.7", "10.9.9.9"}, "198.51.100.1"},
+		{"no header", "10.1.2.3:80", nil, "10.1.2.3"},
+		{"garbage hop", "10.1.2.3:80", []string{"not-an-ip"}, "10.1.2.3"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remoteAddr
+		for _, v := range tc.forwarded {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := api.clientIP(r); got != tc.want {
+			t.Errorf("%s: clientIP = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+var errCommentNotAuthor = errors.New("not the comment's author")
+
+func (api *API) CreateCommentHelper(ctx context.Context, reportID int64, userID uuid.UUID, req model.CreateCommentRequest) (model.Comment, string, string, error) {
+	comment, err := api.CreateCommentRepo(ctx, model.Comment{
+		ReportID:        reportID,
+		ParentCommentID: req.ParentCommentID,
+		UserID:          userID,
+		Comment:         req.Content,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrReportNotFound):
+			return model.Comment{}, values.NotFound, "Report not found", err
+		case errors.Is(err, ErrCommentNotFound):
+			return model.Comment{}, values.NotFound, "Comment being replied to not found", err
+		}
+		return model.Comment{}, values.Error, "Failed to add comment", err
+	}
+	return comment, values.Success, "Comment added successfully", nil
+}
+
+const (
+	defaultCommentsPageSize = 20
+	maxCommentsPageSize     = 100
+)
+
+// GetCommentsHelper returns a page of the report's comment threads; each top-level
+// comment comes with all its replies.
+func (api *API) GetCommentsHelper(ctx context.Context, reportID int64, page pageParams) (model.Page[model.Comment], string, string, error) {
+	comments, err := api.ListCommentsRepo(ctx, reportID)
+	if err != nil {
+		return model.Page[model.Comment]{}, values.Error, "Failed to get comments", err
+	}
+	return paginate(buildCommentThreads(comments), page), values.Success, "Comments retrieved successfully", nil
+}
+
+// UpdateCommentHelper edits a comment. Only its author may.
+func (api *API) UpdateCommentHelper(ctx context.Context, reportID int64, commentID, userID uuid.UUID, req model.UpdateCommentRequest) (model.Comment, string, string, error) {
+	status, message, err := api.authorizeComment(ctx, reportID, commentID, userID, false)
+	if err != nil {
+		return model.Comment{}, status, message, err
+	}
+	comment, err := api.UpdateCommentRepo(ctx, reportID, commentID, userID, req.Content)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			return model.Comment{}, values.NotFound, "Comment not found", err
+		}
+		return model.Comment{}, values.Error, "Failed to update comment", err
+	}
+	return comment, values.Success, "Comment updated", nil
+}
+
+// DeleteCommentHelper soft-deletes a comment for its author or a moderator.
+func (api *API) DeleteCommentHelper(ctx context.Context, reportID int64, commentID, userID uuid.UUID, role string) (string, string, error) {
+	moderator := roleHas(role, PermModerateComments)
+	if status, message, err := api.authorizeComment(ctx, reportID, commentID, userID, moderator); err != nil {
+		return status, message, err
+	}
+	if err := api.DeleteCommentRepo(ctx, reportID, commentID, userID); err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			return values.NotFound, "Comment not found", err
+		}
+		return values.Error, "Failed to delete comment", err
+	}
+	return values.Success, "Comment deleted", nil
+}
+
+// authorizeComment checks that a live comment exists and that the user wrote it, unless
+// moderator is set.
+func (api *API) authorizeComment(ctx context.Context, reportID int64, commentID, userID uuid.UUID, moderator bool) (string, string, error) {
+	comment, err := api.GetCommentRepo(ctx, reportID, commentID)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			return values.NotFound, "Comment not found", err
+		}
+		return values.Error, "Failed to load comment", err
+	}
+	if comment.Deleted {
+		return values.NotFound, "Comment not found", ErrCommentNotFound
+	}
+	if comment.UserID != userID && !moderator {
+		return values.NotAllowed, "Only the comment's author can change it", errCommentNotAuthor
+	}
+	return values.Success, "", nil
+}
+
+// buildCommentThreads nests replies under their parents, keeping the oldest-first order
+// of comments. Deleted comments are only kept as placeholders for replies that survive;
+// replies whose parent is missing are shown at the top level.
+func buildCommentThreads(comments []model.Comment) []model.Comment {
+	ids := make(map[uuid.UUID]bool, len(comments))
+	for _, c := range comments {
+		ids[c.ID] = true
+	}
+	var roots []model.Comment
+	children := make(map[uuid.UUID][]model.Comment)
+	for _, c := range comments {
+		if c.ParentCommentID != nil && ids[*c.ParentCommentID] && *c.ParentCommentID != c.ID {
+			children[*c.ParentCommentID] = append(children[*c.ParentCommentID], c)
+			continue
+		}
+		roots = append(roots, c)
+	}
+
+	var attach func([]model.Comment) []model.Comment
+	attach = func(level []model.Comment) []model.Comment {
+		out := make([]model.Comment, 0, len(level))
+		for _, c := range level {
+			c.Replies = attach(children[c.ID])
+			if c.Deleted && len(c.Replies) == 0 {
+				continue
+			}
+			out = append(out, c)
+		}
+		return out
+	}
+	return attach(roots)
+}
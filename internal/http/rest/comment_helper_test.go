@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestBuildCommentThreads(t *testing.T) {
+	id := func(n byte) uuid.UUID { return uuid.UUID{15: n} }
+	parent := func(n byte) *uuid.UUID { p := id(n); return &p }
+
+	threads := buildCommentThreads([]model.Comment{
+		{ID: id(1), Comment: "first"},
+		{ID: id(2), Deleted: true},
+		{ID: id(3), ParentCommentID: parent(1), Comment: "reply"},
+		{ID: id(4), ParentCommentID: parent(3), Comment: "nested"},
+		{ID: id(5), Deleted: true},
+		{ID: id(6), ParentCommentID: parent(5), Comment: "reply to deleted"},
+		{ID: id(7), ParentCommentID: parent(9), Comment: "orphan"},
+	})
+
+	if len(threads) != 3 {
+		t.Fatalf("got %d threads, want 3: %+v", len(threads), threads)
+	}
+	if threads[0].ID != id(1) || len(threads[0].Replies) != 1 || len(threads[0].Replies[0].Replies) != 1 {
+		t.Errorf("first thread = %+v, want 1 > 3 > 4", threads[0])
+	}
+	if threads[1].ID != id(5) || !threads[1].Deleted || len(threads[1].Replies) != 1 {
+		t.Errorf("second thread = %+v, want deleted placeholder 5 with its reply", threads[1])
+	}
+	if threads[2].ID != id(7) {
+		t.Errorf("third thread = %+v, want orphaned reply 7 at the top level", threads[2])
+	}
+}
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrCommentNotFound = fmt.Errorf("comment %w", ErrNotFound)
+
+// commentColumns reads a comment aliased c joined to its author u. Deleted comments come
+// back with their content blanked.
+const commentColumns = `
+	c.id, c.report_id, c.parent_comment_id, c.user_id, u.username,
+	CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '' END,
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL
+`
+
+func scanComment(row pgx.Row) (model.Comment, error) {
+	var c model.Comment
+	err := row.Scan(
+		&c.ID, &c.ReportID, &c.ParentCommentID, &c.UserID, &c.Username,
+		&c.Comment, &c.CreatedAt, &c.EditedAt, &c.Deleted,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Comment{}, ErrCommentNotFound
+	}
+	return c, err
+}
+
+// CreateCommentRepo adds a comment, or a reply when ParentCommentID is set, and bumps the
+// report's comments_count in the same transaction. The parent must be a live comment on
+// the same report. It returns ErrReportNotFound or ErrCommentNotFound otherwise.
+func (api *API) CreateCommentRepo(ctx context.Context, comment model.Comment) (model.Comment, error) {
+	var created model.Comment
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `UPDATE reports SET comments_count = comments_count + 1 WHERE id = $1`, comment.ReportID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrReportNotFound
+		}
+		if comment.ParentCommentID != nil {
+			var exists bool
+			err := tx.QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM comments
+					WHERE id = $1 AND report_id = $2 AND deleted_at IS NULL
+				)
+			`, *comment.ParentCommentID, comment.ReportID).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrCommentNotFound
+			}
+		}
+		query := `
+			WITH c AS (
+				INSERT INTO comments (report_id, parent_comment_id, user_id, content, created_at)
+				VALUES ($1, $2, $3, $4, NOW())
+				RETURNING *
+			)
+			SELECT ` + commentColumns + ` FROM c LEFT JOIN users u ON u.id = c.user_id
+		`
+		created, err = scanComment(tx.QueryRow(ctx, query, comment.ReportID, comment.ParentCommentID, comment.UserID, comment.Comment))
+		return err
+	})
+	return created, err
+}
+
+// GetCommentRepo returns a comment on the report, deleted or not.
+func (api *API) GetCommentRepo(ctx context.Context, reportID int64, commentID uuid.UUID) (model.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1 AND c.report_id = $2
+	`
+	return scanComment(api.DB.QueryRow(ctx, query, commentID, reportID))
+}
+
+// ListCommentsRepo returns every comment on the report, deleted ones included so their
+// replies can be threaded, oldest first.
+func (api *API) ListCommentsRepo(ctx context.Context, reportID int64) ([]model.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.report_id = $1
+		ORDER BY c.created_at ASC, c.id
+	`
+	rows, err := api.DB.Query(ctx, query, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("querying comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []model.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// UpdateCommentRepo replaces the content of the author's live comment.
+func (api *API) UpdateCommentRepo(ctx context.Context, reportID int64, commentID, userID uuid.UUID, content string) (model.Comment, error) {
+	query := `
+		WITH c AS (
+			UPDATE comments SET content = $4, edited_at = NOW()
+			WHERE id = $1 AND report_id = $2 AND user_id = $3 AND deleted_at IS NULL
+			RETURNING *
+		)
+		SELECT ` + commentColumns + ` FROM c LEFT JOIN users u ON u.id = c.user_id
+	`
+	return scanComment(api.DB.QueryRow(ctx, query, commentID, reportID, userID, content))
+}
+
+// DeleteCommentRepo soft-deletes a live comment and drops it from the report's
+// comments_count in the same transaction. Its replies are left in place.
+func (api *API) DeleteCommentRepo(ctx context.Context, reportID int64, commentID, deletedBy uuid.UUID) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE comments SET deleted_at = NOW(), deleted_by = $3
+			WHERE id = $1 AND report_id = $2 AND deleted_at IS NULL
+		`, commentID, reportID, deletedBy)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrCommentNotFound
+		}
+		_, err = tx.Exec(ctx, `UPDATE reports SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = $1`, reportID)
+		return err
+	})
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// Database availability as last seen by the health monitor.
+const (
+	dbUp int32 = iota
+	dbReadOnly
+	dbDown
+)
+
+const (
+	dbHealthInterval = 5 * time.Second
+	dbHealthTimeout  = 2 * time.Second
+	// dbRetryAfter is the Retry-After hint sent with writes rejected while degraded.
+	dbRetryAfter = 30 * time.Second
+)
+
+// dbHealth tracks whether the database is reachable and writable so requests can
+// degrade instead of waiting on pool timeouts. The zero value means "up".
+type dbHealth struct {
+	state atomic.Int32
+}
+
+func (h *dbHealth) set(state int32) {
+	if old := h.state.Swap(state); old != state {
+		slog.Warn("Database state changed", "from", dbStateName(old), "to", dbStateName(state))
+	}
+}
+
+func dbStateName(state int32) string {
+	switch state {
+	case dbReadOnly:
+		return "read-only"
+	case dbDown:
+		return "down"
+	}
+	return "up"
+}
+
+// dbAvailable reports whether reads can be expected to succeed.
+func (api *API) dbAvailable() bool {
+	return api.dbHealth.state.Load() != dbDown
+}
+
+// dbWritable reports whether writes can be expected to succeed.
+func (api *API) dbWritable() bool {
+	return api.dbHealth.state.Load() == dbUp
+}
+
+// RunDBHealthMonitor polls the database until ctx is cancelled, tracking whether it is
+// up, read-only (e.g. a replica after failover) or unreachable.
+func (api *API) RunDBHealthMonitor(ctx context.Context) {
+	ticker := time.NewTicker(dbHealthInterval)
+	defer ticker.Stop()
+	for {
+		api.checkDBHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (api *API) checkDBHealth(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, dbHealthTimeout)
+	defer cancel()
+
+	var readOnly bool
+	err := api.DB.QueryRow(ctx, `SELECT pg_is_in_recovery() OR current_setting('transaction_read_only') = 'on'`).Scan(&readOnly)
+	switch {
+	case err != nil:
+		api.dbHealth.set(dbDown)
+	case readOnly:
+		api.dbHealth.set(dbReadOnly)
+	default:
+		api.dbHealth.set(dbUp)
+	}
+}
+
+// errDBUnavailable is returned by helpers that skip the database while it is down.
+var errDBUnavailable = errors.New("database unavailable")
+
+// RequireWritableDB rejects non-read requests with a retryable 503 while the database is
+// down or read-only, so clients can queue and retry instead of seeing a generic 500.
+func (api *API) RequireWritableDB(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !api.dbWritable() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(dbRetryAfter.Seconds())))
+			writeErrorResponse(w, errDBUnavailable, values.Unavailable, "database-unavailable")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
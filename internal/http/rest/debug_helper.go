@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	GeoQueryNearby       = "nearby"
+	GeoQueryViewport     = "viewport"
+	GeoQueryClusters     = "clusters"
+	GeoQueryRouteHazards = "route_hazards"
+
+	// explainStatementTimeout caps EXPLAIN ANALYZE, which really runs the query.
+	explainStatementTimeout = "15s"
+)
+
+// ExplainGeoQueryHelper runs EXPLAIN ANALYZE on the same SQL the app uses for the chosen
+// geo query, with the request's parameters filled in the way the handlers default them.
+func (api *API) ExplainGeoQueryHelper(ctx context.Context, req model.ExplainGeoQueryRequest) (model.GeoQueryExplain, string, string, error) {
+	if req.Query != GeoQueryNearby && (req.MinLat >= req.MaxLat || req.MinLng >= req.MaxLng) {
+		return model.GeoQueryExplain{}, values.BadRequestBody, "min_lat/min_lng must be below max_lat/max_lng", fmt.Errorf("empty bounding box")
+	}
+
+	var query string
+	var args []interface{}
+	switch req.Query {
+	case GeoQueryNearby:
+		params := model.NearbyReportsParams{
+			Latitude:  req.Latitude,
+			Longitude: req.Longitude,
+			Radius:    req.Radius,
+			Types:     req.Types,
+			Status:    req.Status,
+			ViewerID:  req.ViewerID,
+			Page:      req.Page,
+			PageSize:  req.PageSize,
+		}
+		if params.Radius == 0 {
+			params.Radius = 1000
+		}
+		if params.Page == 0 {
+			params.Page = 1
+		}
+		if params.PageSize == 0 {
+			params.PageSize = 10
+		}
+		query, args = nearbyReportsQuery(params)
+	case GeoQueryViewport:
+		limit := req.Limit
+		if limit == 0 {
+			limit = reportTileMaxReports
+		}
+		query = activeReportsInBBoxQuery
+		args = []interface{}{req.MinLng, req.MinLat, req.MaxLng, req.MaxLat, limit}
+	case GeoQueryClusters:
+		if req.Zoom < 0 || req.Zoom > reportClusterMaxZoom {
+			return model.GeoQueryExplain{}, values.BadRequestBody, fmt.Sprintf("zoom must be between 0 and %d", reportClusterMaxZoom), fmt.Errorf("zoom %d out of range", req.Zoom)
+		}
+		query, args = reportClustersQuery(model.ReportClusterParams{
+			BBox:      model.BoundingBox{MinLat: req.MinLat, MinLng: req.MinLng, MaxLat: req.MaxLat, MaxLng: req.MaxLng},
+			Zoom:      req.Zoom,
+			Precision: reportClusterPrecision(req.Zoom),
+			Types:     req.Types,
+			ViewerID:  req.ViewerID,
+			Limit:     reportClusterLimit + 1,
+		})
+	case GeoQueryRouteHazards:
+		subtypes, minSeverity, limit := req.Subtypes, req.MinSeverity, req.Limit
+		if subtypes == nil {
+			subtypes = twoWheelerHazardSubtypes
+		}
+		if minSeverity == 0 {
+			minSeverity = twoWheelerHazardMinSeverity
+		}
+		if limit == 0 {
+			limit = maxHazardExclusions
+		}
+		query = routeHazardsQuery
+		args = []interface{}{req.MinLat, req.MinLng, req.MaxLat, req.MaxLng, subtypes, minSeverity, limit}
+	default:
+		return model.GeoQueryExplain{}, values.BadRequestBody, "query must be nearby, viewport, clusters or route_hazards", fmt.Errorf("unknown query %q", req.Query)
+	}
+
+	start := time.Now()
+	plan, err := api.ExplainAnalyzeRepo(ctx, query, args)
+	if err != nil {
+		return model.GeoQueryExplain{}, values.Error, "Failed to explain query", err
+	}
+	result := model.GeoQueryExplain{
+		Query:   req.Query,
+		SQL:     query,
+		Args:    args,
+		TotalMs: float64(time.Since(start).Microseconds()) / 1000,
+		Plan:    plan,
+	}
+
+	var timings []struct {
+		PlanningTime  float64 `json:"Planning Time"`
+		ExecutionTime float64 `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(plan, &timings); err == nil && len(timings) > 0 {
+		result.PlanningMs = timings[0].PlanningTime
+		result.ExecutionMs = timings[0].ExecutionTime
+	}
+	return result, values.Success, "Query explained", nil
+}
+
+// ExplainAnalyzeRepo runs EXPLAIN ANALYZE on query inside a read-only transaction that is
+// always rolled back, so only SELECTs can be explained and nothing they touch is kept.
+func (api *API) ExplainAnalyzeRepo(ctx context.Context, query string, args []interface{}) (json.RawMessage, error) {
+	tx, err := api.DB.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = '"+explainStatementTimeout+"'"); err != nil {
+		return nil, err
+	}
+	var plan []byte
+	if err := tx.QueryRow(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
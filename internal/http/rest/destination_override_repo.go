@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrDestinationOverrideNotFound = fmt.Errorf("destination override %w", ErrNotFound)
+
+const destinationOverrideColumns = `
+	id, name, place_id, ST_Y(location), ST_X(location), radius_m,
+	ST_Y(arrival_point), ST_X(arrival_point), arrival_kind, notes, created_by, created_at, updated_at
+`
+
+func scanDestinationOverride(row pgx.Row) (model.DestinationOverride, error) {
+	var o model.DestinationOverride
+	err := row.Scan(&o.ID, &o.Name, &o.PlaceID, &o.Latitude, &o.Longitude, &o.RadiusM,
+		&o.ArrivalLat, &o.ArrivalLng, &o.Kind, &o.Notes, &o.CreatedBy, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+func (api *API) ListDestinationOverridesRepo(ctx context.Context) ([]model.DestinationOverride, error) {
+	rows, err := api.DB.Query(ctx, `SELECT `+destinationOverrideColumns+` FROM destination_overrides ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []model.DestinationOverride
+	for rows.Next() {
+		o, err := scanDestinationOverride(rows)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+func (api *API) CreateDestinationOverrideRepo(ctx context.Context, req model.CreateDestinationOverrideRequest, createdBy uuid.UUID) (model.DestinationOverride, error) {
+	query := `
+        INSERT INTO destination_overrides (name, place_id, location, radius_m, arrival_point, arrival_kind, notes, created_by)
+        VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326), $5, ST_SetSRID(ST_MakePoint($6, $7), 4326), $8, $9, $10)
+        RETURNING ` + destinationOverrideColumns
+	return scanDestinationOverride(api.DB.QueryRow(ctx, query,
+		req.Name, req.PlaceID, req.Longitude, req.Latitude, req.RadiusM,
+		req.ArrivalLng, req.ArrivalLat, req.Kind, req.Notes, createdBy))
+}
+
+func (api *API) DeleteDestinationOverrideRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM destination_overrides WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDestinationOverrideNotFound
+	}
+	return nil
+}
+
+// FindDestinationOverrideRepo returns the override for a destination: an exact place_id
+// match first, otherwise the nearest override whose radius covers the point. It returns
+// pgx.ErrNoRows when none applies.
+func (api *API) FindDestinationOverrideRepo(ctx context.Context, placeID string, lat, lng float64) (model.DestinationOverride, error) {
+	query := `
+        SELECT ` + destinationOverrideColumns + `
+        FROM destination_overrides
+        WHERE ($1 <> '' AND place_id = $1)
+           OR ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography, radius_m)
+        ORDER BY ($1 <> '' AND place_id = $1) DESC,
+                 ST_Distance(location::geography, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography)
+        LIMIT 1
+    `
+	return scanDestinationOverride(api.DB.QueryRow(ctx, query, placeID, lng, lat))
+}
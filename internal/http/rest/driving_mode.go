@@ -0,0 +1,151 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const (
+	// Requests opt into driving mode with this header, or ?response_mode=driving.
+	responseModeHeader  = "X-Response-Mode"
+	responseModeDriving = "driving"
+
+	// drivingMaxReports caps report lists; more than a few can't be taken in while driving.
+	drivingMaxReports = 3
+)
+
+// reportTypeLabels and reportSubtypeLabels give the short spoken/displayed name of a report.
+var reportTypeLabels = map[string]string{
+	"TRAFFIC":      "Traffic",
+	"POLICE":       "Police",
+	"ACCIDENT":     "Accident",
+	"HAZARD":       "Hazard",
+	"ROAD_CLOSED":  "Road closed",
+	"PHOTOSHARING": "Photo",
+}
+
+var reportSubtypeLabels = map[string]string{
+	"TRAFFIC/LIGHT":       "Light traffic",
+	"TRAFFIC/HEAVY":       "Heavy traffic",
+	"TRAFFIC/STAND_STILL": "Standstill traffic",
+	"POLICE/VISIBLE":      "Police",
+	"POLICE/HIDDEN":       "Hidden police",
+	"POLICE/OTHER_SIDE":   "Police, other side",
+	"ACCIDENT/MINOR":      "Minor accident",
+	"ACCIDENT/MAJOR":      "Major accident",
+	"HAZARD/GRAVEL":       "Gravel on road",
+	"HAZARD/OIL_SLICK":    "Oil on road",
+}
+
+// drivingMode reports whether the caller asked for driving-mode payloads.
+func drivingMode(r *http.Request) bool {
+	mode := r.Header.Get(responseModeHeader)
+	if mode == "" {
+		mode = r.URL.Query().Get("response_mode")
+	}
+	return strings.EqualFold(mode, responseModeDriving)
+}
+
+// drivingView trims a handler's response data for driving mode. Data it has no driving
+// shape for is returned unchanged.
+func drivingView(data interface{}) interface{} {
+	switch d := data.(type) {
+	case model.Report:
+		return drivingReport(d, nil)
+	case []model.Report:
+		out := model.DrivingReports{Reports: []model.DrivingReport{}}
+		for i, report := range d {
+			if i == drivingMaxReports {
+				out.More = len(d) - i
+				break
+			}
+			out.Reports = append(out.Reports, drivingReport(report, nil))
+		}
+		return out
+	case model.ReportSearchResult:
+		return drivingView(d.Reports)
+	case model.Page[model.Report]:
+		return drivingView(d.Data)
+	case []model.RouteAlert:
+		out := model.DrivingReports{Reports: []model.DrivingReport{}}
+		for i, alert := range d {
+			if i == drivingMaxReports {
+				out.More = len(d) - i
+				break
+			}
+			distance := alert.DistanceFromStartM
+			out.Reports = append(out.Reports, drivingReport(alert.Report, &distance))
+		}
+		return out
+	case *mapbox.DirectionsResponse:
+		if d == nil || len(d.Routes) == 0 {
+			return data
+		}
+		return drivingRoute(d)
+	}
+	return data
+}
+
+func drivingReport(r model.Report, distanceM *float64) model.DrivingReport {
+	return model.DrivingReport{
+		ID:        r.ID,
+		Label:     reportLabel(r),
+		Latitude:  r.Latitude,
+		Longitude: r.Longitude,
+		Severity:  r.Severity,
+		DistanceM: distanceM,
+	}
+}
+
+// reportLabel is a two or three word name for the report, e.g. "Hidden police".
+func reportLabel(r model.Report) string {
+	if r.Subtype != nil {
+		if label, ok := reportSubtypeLabels[r.Type+"/"+*r.Subtype]; ok {
+			return label
+		}
+	}
+	if label, ok := reportTypeLabels[r.Type]; ok {
+		return label
+	}
+	return strings.ReplaceAll(strings.ToLower(r.Type), "_", " ")
+}
+
+// drivingRoute keeps the primary route's geometry and one short line per step; step
+// geometry, intersections, annotations and the alternatives themselves are dropped.
+func drivingRoute(resp *mapbox.DirectionsResponse) model.DrivingRoute {
+	route := resp.Routes[0]
+	out := model.DrivingRoute{
+		DurationS:    route.Duration,
+		DistanceM:    route.Distance,
+		Geometry:     route.Geometry.Coordinates,
+		Steps:        []model.DrivingStep{},
+		Alternatives: len(resp.Routes) - 1,
+	}
+	if route.ArrivalWindow != nil {
+		out.ETA = route.ArrivalWindow.Formatted
+	}
+	for _, leg := range route.Legs {
+		if out.Summary == "" {
+			out.Summary = leg.Summary
+		}
+		for _, step := range leg.Steps {
+			s := model.DrivingStep{
+				Instruction: step.Maneuver.Instruction,
+				Type:        step.Maneuver.Type,
+				Modifier:    step.Maneuver.Modifier,
+				DistanceM:   step.Distance,
+			}
+			if len(step.BannerInstructions) > 0 && step.BannerInstructions[0].Primary.Text != "" {
+				s.Instruction = step.BannerInstructions[0].Primary.Text
+			}
+			if len(step.VoiceInstructions) > 0 {
+				s.Voice = step.VoiceInstructions[0].Announcement
+			}
+			out.Steps = append(out.Steps, s)
+		}
+	}
+	return out
+}
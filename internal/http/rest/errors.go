@@ -0,0 +1,189 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Domain errors shared by repos, helpers and handlers. Repos wrap database errors in
+// them with dbError, and the specific sentinels (ErrReportNotFound, ...) wrap them too,
+// so callers test with errors.Is rather than matching error strings.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+
+	ErrTokenExpired = errors.New("token expired")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenRevoked = fmt.Errorf("token revoked: %w", ErrInvalidToken)
+)
+
+// Postgres error codes dbError classifies.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+)
+
+// dbError wraps "no rows" in ErrNotFound, unique violations in ErrConflict and other
+// constraint violations in ErrValidation. The original error stays in the chain, so
+// errors.Is(err, pgx.ErrNoRows) and errors.As(err, &pgErr) keep working.
+func dbError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case pgForeignKeyViolation, pgCheckViolation, pgNotNullViolation:
+			return fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+	}
+	return err
+}
+
+// errorStatus maps a domain error to its response status, or fallback for anything else.
+func errorStatus(err error, fallback string) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return values.NotFound
+	case errors.Is(err, ErrConflict):
+		return values.Conflict
+	case errors.Is(err, ErrValidation):
+		return values.BadRequestBody
+	case errors.Is(err, ErrTokenExpired):
+		return values.TokenExpired
+	case errors.Is(err, ErrInvalidToken):
+		return values.NotAuthorised
+	case errors.Is(err, ErrNoRoute):
+		return values.Unprocessable
+	case errors.Is(err, ErrRoutingFailed):
+		return values.Unavailable
+	}
+	return fallback
+}
+
+// errorCodes gives the code sent with each domain error, most specific first: the first
+// entry the error matches wins.
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrReportNotFound, values.CodeReportNotFound},
+	{ErrReportTransition, values.CodeReportTransition},
+	{ErrCommentNotFound, values.CodeCommentNotFound},
+	{ErrVoteNotFound, values.CodeVoteNotFound},
+	{ErrSessionNotFound, values.CodeSessionNotFound},
+	{ErrNavigationSessionNotFound, values.CodeNavigationSessionNotFound},
+	{ErrNavigationSessionInactive, values.CodeNavigationSessionInactive},
+	{ErrPlannedDriveNotFound, values.CodePlannedDriveNotFound},
+	{ErrAlertZoneNotFound, values.CodeAlertZoneNotFound},
+	{ErrAlertZoneLimit, values.CodeAlertZoneLimit},
+	{ErrGroupMemberNotFound, values.CodeGroupMemberNotFound},
+	{ErrAlreadyGroupMember, values.CodeAlreadyGroupMember},
+	{ErrLastGroupAdmin, values.CodeLastGroupAdmin},
+	{ErrGroupRideNotFound, values.CodeGroupRideNotFound},
+	{ErrPinLimitReached, values.CodePinLimitReached},
+	{ErrInvitationNotFound, values.CodeInvitationNotFound},
+	{ErrUploadNotFound, values.CodeUploadNotFound},
+	{ErrSavedLocationNotFound, values.CodeSavedLocationNotFound},
+	{ErrRecentDestinationNotFound, values.CodeRecentDestinationNotFound},
+	{ErrNoRoute, values.CodeRouteNoPath},
+	{ErrRoutingFailed, values.CodeProviderError},
+	{ErrPhoneCodeInvalid, values.CodePhoneCodeInvalid},
+	{ErrPhoneCodeLocked, values.CodePhoneCodeLocked},
+	{ErrAccountDeactivated, values.CodeAccountDeactivated},
+	{ErrInsufficientRole, values.CodeInsufficientRole},
+	{ErrMissingPermission, values.CodeMissingPermission},
+	{ErrSignatureRequired, values.CodeSignatureRequired},
+	{ErrInvalidSignature, values.CodeInvalidSignature},
+	{ErrSignatureExpired, values.CodeSignatureExpired},
+	{ErrUnknownDevice, values.CodeUnknownDevice},
+	{ErrNonceReused, values.CodeReplayedRequest},
+	{ErrQuotaExceeded, values.CodeQuotaExceeded},
+	{errDBUnavailable, values.CodeDatabaseUnavailable},
+	{ErrTokenRevoked, values.CodeTokenRevoked},
+	{context.DeadlineExceeded, values.CodeTimeout},
+	{ErrNotFound, values.CodeNotFound},
+	{ErrConflict, values.CodeConflict},
+	{ErrValidation, values.CodeValidationFailed},
+	{ErrTokenExpired, values.CodeTokenExpired},
+	{ErrInvalidToken, values.CodeInvalidToken},
+}
+
+// statusCodes is the code for errors without one of their own.
+var statusCodes = map[string]string{
+	values.Error:           values.CodeInternal,
+	values.Failed:          values.CodeRequestFailed,
+	values.BadRequestBody:  values.CodeBadRequest,
+	values.Unprocessable:   values.CodeUnprocessable,
+	values.NotAllowed:      values.CodeForbidden,
+	values.ActiveLogin:     values.CodeActiveLogin,
+	values.Conflict:        values.CodeConflict,
+	values.NotFound:        values.CodeNotFound,
+	values.NotAuthorised:   values.CodeUnauthorized,
+	values.TokenExpired:    values.CodeTokenExpired,
+	values.Unavailable:     values.CodeUnavailable,
+	values.TooManyRequests: values.CodeRateLimited,
+}
+
+// errorCode picks the code for an error response. An error's own code is only used when
+// the response status agrees with it, so a handler that answers 500 for a failed lookup
+// doesn't send NOT_FOUND.
+func errorCode(err error, status string) string {
+	if err != nil {
+		err = dbError(err)
+		if errorStatus(err, status) == status {
+			var verr *valhalla.Error
+			if errors.As(err, &verr) {
+				if verr.NoPath() {
+					return values.CodeRouteNoPath
+				}
+				return values.CodeProviderError
+			}
+			for _, c := range errorCodes {
+				if errors.Is(err, c.err) {
+					return c.code
+				}
+			}
+		}
+	}
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return values.CodeInternal
+}
+
+// detailedError carries the "details" of an error response alongside the error.
+type detailedError struct {
+	error
+	details map[string]any
+}
+
+func (e detailedError) Unwrap() error { return e.error }
+
+// withDetails attaches details for the error response err ends up in, such as how long to
+// wait before retrying.
+func withDetails(err error, details map[string]any) error {
+	return detailedError{error: err, details: details}
+}
+
+func errorDetails(err error) map[string]any {
+	var d detailedError
+	if errors.As(err, &d) {
+		return d.details
+	}
+	return nil
+}
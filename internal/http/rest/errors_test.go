@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestErrorCode(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status string
+		want   string
+	}{
+		{"specific sentinel", fmt.Errorf("loading: %w", ErrReportNotFound), values.NotFound, values.CodeReportNotFound},
+		{"raw no rows", pgx.ErrNoRows, values.NotFound, values.CodeNotFound},
+		{"status disagrees", ErrReportNotFound, values.Error, values.CodeInternal},
+		{"no natural status", ErrPhoneCodeLocked, values.NotAuthorised, values.CodePhoneCodeLocked},
+		{"revoked before invalid", ErrTokenRevoked, values.NotAuthorised, values.CodeTokenRevoked},
+		{"valhalla no path", &valhalla.Error{HTTPStatus: 400, Code: 442}, values.Unprocessable, values.CodeRouteNoPath},
+		{"valhalla other", &valhalla.Error{HTTPStatus: 500, Code: 199}, values.Error, values.CodeProviderError},
+		{"no route", fmt.Errorf("%w: NoRoute", ErrNoRoute), values.Unprocessable, values.CodeRouteNoPath},
+		{"nil error", nil, values.TooManyRequests, values.CodeRateLimited},
+		{"unknown error", errors.New("boom"), values.Failed, values.CodeRequestFailed},
+		{"unknown status", nil, "teapot", values.CodeInternal},
+	}
+	for _, c := range cases {
+		if got := errorCode(c.err, c.status); got != c.want {
+			t.Errorf("%s: errorCode = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", withDetails(ErrQuotaExceeded, map[string]any{"limit": 100}))
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatal("withDetails hid the wrapped error")
+	}
+	if got := errorDetails(err)["limit"]; got != 100 {
+		t.Errorf("details limit = %v, want 100", got)
+	}
+	if errorDetails(ErrQuotaExceeded) != nil {
+		t.Error("plain error has details")
+	}
+}
@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const (
+	// etaSegmentLengthM is the target slice length when sampling historical speeds along a route.
+	etaSegmentLengthM = 500
+	etaMaxSegments    = 20
+	etaPingRadiusM    = 30
+	etaLookback       = "28 days"
+	// etaMinSamples is how many pings a slice needs before its spread is trusted.
+	etaMinSamples = 5
+	// Spread applied to slices (or whole routes) without enough history.
+	etaDefaultOptimistic  = 0.9
+	etaDefaultPessimistic = 1.25
+	// etaMinCoverage is the share of route length that must have history for the
+	// window to be reported as historical.
+	etaMinCoverage = 0.5
+)
+
+// addArrivalWindows sets an arrival window on every route. It never fails the request;
+// if the history lookup errors, or the database is down, the default spread is used.
+func (api *API) addArrivalWindows(ctx context.Context, resp *mapbox.DirectionsResponse) {
+	if resp == nil {
+		return
+	}
+	for i := range resp.Routes {
+		route := &resp.Routes[i]
+		var stats []model.SegmentSpeedStats
+		if len(route.Geometry.Coordinates) >= 2 && api.dbAvailable() {
+			var err error
+			stats, err = api.RouteSegmentSpeedsRepo(ctx, route.Geometry.Coordinates, etaSegmentCount(route.Distance), etaPingRadiusM, etaLookback)
+			if err != nil {
+				api.logger().WarnContext(ctx, "Arrival window: failed to load segment speeds", "err", err)
+			}
+		}
+		route.ArrivalWindow = arrivalWindow(route.Duration, stats)
+	}
+}
+
+func etaSegmentCount(distanceM float64) int {
+	n := int(math.Ceil(distanceM / etaSegmentLengthM))
+	if n < 1 {
+		return 1
+	}
+	if n > etaMaxSegments {
+		return etaMaxSegments
+	}
+	return n
+}
+
+// arrivalWindow scales the point ETA by how much faster (85th percentile speed) or
+// slower (15th percentile) than the median traffic has historically moved on each
+// slice, weighted by slice length.
+func arrivalWindow(durationS float64, stats []model.SegmentSpeedStats) *mapbox.ArrivalWindow {
+	var totalLen, coveredLen, optimistic, pessimistic float64
+	samples := 0
+	for _, s := range stats {
+		if s.LengthM <= 0 {
+			continue
+		}
+		totalLen += s.LengthM
+		if s.Samples < etaMinSamples || s.SlowMS <= 0 || s.TypMS <= 0 || s.FastMS <= 0 {
+			optimistic += s.LengthM * etaDefaultOptimistic
+			pessimistic += s.LengthM * etaDefaultPessimistic
+			continue
+		}
+		coveredLen += s.LengthM
+		samples += s.Samples
+		optimistic += s.LengthM * (s.TypMS / s.FastMS)
+		pessimistic += s.LengthM * (s.TypMS / s.SlowMS)
+	}
+
+	optFactor, pesFactor := etaDefaultOptimistic, etaDefaultPessimistic
+	basis := "default"
+	if totalLen > 0 && coveredLen/totalLen >= etaMinCoverage {
+		optFactor = math.Min(optimistic/totalLen, 1)
+		pesFactor = math.Max(pessimistic/totalLen, 1)
+		basis = "historical"
+	} else {
+		samples = 0
+	}
+
+	w := &mapbox.ArrivalWindow{
+		OptimisticSeconds:  math.Round(durationS * optFactor),
+		PessimisticSeconds: math.Round(durationS * pesFactor),
+		Basis:              basis,
+		Samples:            samples,
+	}
+	w.Formatted = formatArrivalWindow(w.OptimisticSeconds, w.PessimisticSeconds)
+	return w
+}
+
+// formatArrivalWindow renders "18–25 min", or "1h 5m–1h 20m" for longer trips.
+func formatArrivalWindow(optimisticS, pessimisticS float64) string {
+	lo := time.Duration(optimisticS) * time.Second
+	hi := time.Duration(pessimisticS) * time.Second
+	if hi < time.Hour {
+		loMin := int(math.Max(1, math.Floor(lo.Minutes())))
+		hiMin := int(math.Max(float64(loMin), math.Ceil(hi.Minutes())))
+		if loMin == hiMin {
+			return fmt.Sprintf("%d min", loMin)
+		}
+		return fmt.Sprintf("%d–%d min", loMin, hiMin)
+	}
+	hm := func(d time.Duration) string {
+		d = d.Round(time.Minute)
+		if d < time.Hour {
+			return fmt.Sprintf("%dm", int(d.Minutes()))
+		}
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return hm(lo) + "–" + hm(hi)
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// RouteSegmentSpeedsRepo splits a route into equal slices and returns speed percentiles of
+// the location pings recorded within radiusM of each slice, over the lookback window and
+// within an hour of the current time of day. Slices with no pings come back with Samples = 0.
+func (api *API) RouteSegmentSpeedsRepo(ctx context.Context, coords [][]float64, segments int, radiusM float64, lookback string) ([]model.SegmentSpeedStats, error) {
+	geojson, err := lineStringGeoJSON(coords)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH route AS (
+			SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) AS geom
+		),
+		segs AS (
+			SELECT i, ST_LineSubstring(route.geom, i::float8 / $2, (i + 1)::float8 / $2) AS geom
+			FROM route, generate_series(0, $2 - 1) AS i
+		),
+		pings AS (
+			SELECT s.i, GREATEST(p.speed, 1.0) AS speed
+			FROM segs s
+			JOIN user_location_pings p
+			  ON ST_DWithin(p.position::geography, s.geom::geography, $3)
+			WHERE p.speed IS NOT NULL
+			  AND p.recorded_at > NOW() - $4::interval
+			  AND LEAST(
+					ABS(EXTRACT(HOUR FROM p.recorded_at) - EXTRACT(HOUR FROM NOW())),
+					24 - ABS(EXTRACT(HOUR FROM p.recorded_at) - EXTRACT(HOUR FROM NOW()))
+				  ) <= 1
+		)
+		SELECT
+			ST_Length(s.geom::geography),
+			COUNT(p.speed)::int,
+			COALESCE(percentile_cont(0.15) WITHIN GROUP (ORDER BY p.speed), 0),
+			COALESCE(percentile_cont(0.50) WITHIN GROUP (ORDER BY p.speed), 0),
+			COALESCE(percentile_cont(0.85) WITHIN GROUP (ORDER BY p.speed), 0)
+		FROM segs s
+		LEFT JOIN pings p ON p.i = s.i
+		GROUP BY s.i, s.geom
+		ORDER BY s.i
+	`
+	rows, err := api.DB.Query(ctx, query, geojson, segments, radiusM, lookback)
+	if err != nil {
+		return nil, fmt.Errorf("querying route segment speeds: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []model.SegmentSpeedStats
+	for rows.Next() {
+		var s model.SegmentSpeedStats
+		if err := rows.Scan(&s.LengthM, &s.Samples, &s.SlowMS, &s.TypMS, &s.FastMS); err != nil {
+			return nil, fmt.Errorf("scanning route segment speeds: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
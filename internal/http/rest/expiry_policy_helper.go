@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultReportTTL is used when no policy matches or the table can't be read.
+	defaultReportTTL     = 6 * time.Hour
+	expiryPolicyCacheTTL = time.Minute
+)
+
+// expiryPolicyCache keeps the expiry policy table in memory for report creation, like
+// broadcastPolicyCache. Admin edits invalidate it.
+type expiryPolicyCache struct {
+	mu       sync.RWMutex
+	policies []model.ExpiryPolicy
+	loadedAt time.Time
+}
+
+func (c *expiryPolicyCache) get() ([]model.ExpiryPolicy, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadedAt.IsZero() || time.Since(c.loadedAt) > expiryPolicyCacheTTL {
+		return nil, false
+	}
+	return c.policies, true
+}
+
+func (c *expiryPolicyCache) set(policies []model.ExpiryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies = policies
+	c.loadedAt = time.Now()
+}
+
+func (c *expiryPolicyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
+
+// ReportTTLFor returns how long a new report of this type and subtype stays live.
+func (api *API) ReportTTLFor(ctx context.Context, reportType string, subtype *string) time.Duration {
+	policies, ok := api.expiryPolicies.get()
+	if !ok {
+		var err error
+		policies, err = api.ListExpiryPoliciesRepo(ctx)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Failed to load expiry policies, using default TTL", "err", err)
+			return defaultReportTTL
+		}
+		api.expiryPolicies.set(policies)
+	}
+	sub := ""
+	if subtype != nil {
+		sub = *subtype
+	}
+	return resolveReportTTL(policies, reportType, sub)
+}
+
+// resolveReportTTL picks the policy for the exact type and subtype, then the type's
+// any-subtype policy, then the wildcard policy, then the default.
+func resolveReportTTL(policies []model.ExpiryPolicy, reportType, subtype string) time.Duration {
+	reportType, subtype = strings.ToUpper(reportType), strings.ToUpper(subtype)
+	best, bestRank := -1, 0
+	for i, p := range policies {
+		rank := 0
+		switch {
+		case p.ReportType == reportType && subtype != "" && p.Subtype == subtype:
+			rank = 3
+		case p.ReportType == reportType && p.Subtype == wildcardReportType:
+			rank = 2
+		case p.ReportType == wildcardReportType && p.Subtype == wildcardReportType:
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = i, rank
+		}
+	}
+	if best < 0 {
+		return defaultReportTTL
+	}
+	return time.Duration(policies[best].TTLMinutes) * time.Minute
+}
+
+func (api *API) ListExpiryPoliciesHelper(ctx context.Context) ([]model.ExpiryPolicy, string, string, error) {
+	policies, err := api.ListExpiryPoliciesRepo(ctx)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch expiry policies", err
+	}
+	if policies == nil {
+		policies = []model.ExpiryPolicy{}
+	}
+	return policies, values.Success, "Expiry policies fetched successfully", nil
+}
+
+func (api *API) UpsertExpiryPolicyHelper(ctx context.Context, req model.UpsertExpiryPolicyRequest, adminID uuid.UUID) (model.ExpiryPolicy, string, string, error) {
+	req.ReportType = strings.ToUpper(strings.TrimSpace(req.ReportType))
+	req.Subtype = strings.ToUpper(strings.TrimSpace(req.Subtype))
+	if req.Subtype == "" {
+		req.Subtype = wildcardReportType
+	}
+	policy, err := api.UpsertExpiryPolicyRepo(ctx, req, adminID)
+	if err != nil {
+		return model.ExpiryPolicy{}, values.Error, "Failed to save expiry policy", err
+	}
+	api.expiryPolicies.invalidate()
+	return policy, values.Success, "Expiry policy saved", nil
+}
+
+func (api *API) DeleteExpiryPolicyHelper(ctx context.Context, id int64) (string, string, error) {
+	err := api.DeleteExpiryPolicyRepo(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrExpiryPolicyNotFound) {
+			return values.NotFound, "Expiry policy not found", err
+		}
+		return values.Error, "Failed to delete expiry policy", err
+	}
+	api.expiryPolicies.invalidate()
+	return values.Success, "Expiry policy deleted", nil
+}
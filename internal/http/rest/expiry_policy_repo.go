@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrExpiryPolicyNotFound = fmt.Errorf("expiry policy %w", ErrNotFound)
+
+func (api *API) ListExpiryPoliciesRepo(ctx context.Context) ([]model.ExpiryPolicy, error) {
+	query := `
+        SELECT id, report_type, subtype, ttl_minutes, updated_by, updated_at
+        FROM report_expiry_policies
+        ORDER BY report_type, subtype
+    `
+	rows, err := api.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []model.ExpiryPolicy
+	for rows.Next() {
+		var p model.ExpiryPolicy
+		if err := rows.Scan(&p.ID, &p.ReportType, &p.Subtype, &p.TTLMinutes, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (api *API) UpsertExpiryPolicyRepo(ctx context.Context, req model.UpsertExpiryPolicyRequest, updatedBy uuid.UUID) (model.ExpiryPolicy, error) {
+	query := `
+        INSERT INTO report_expiry_policies (report_type, subtype, ttl_minutes, updated_by, updated_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        ON CONFLICT (report_type, subtype) DO UPDATE SET
+            ttl_minutes = EXCLUDED.ttl_minutes,
+            updated_by = EXCLUDED.updated_by,
+            updated_at = NOW()
+        RETURNING id, report_type, subtype, ttl_minutes, updated_by, updated_at
+    `
+	var p model.ExpiryPolicy
+	err := api.DB.QueryRow(ctx, query, req.ReportType, req.Subtype, req.TTLMinutes, updatedBy).Scan(
+		&p.ID, &p.ReportType, &p.Subtype, &p.TTLMinutes, &p.UpdatedBy, &p.UpdatedAt,
+	)
+	return p, err
+}
+
+func (api *API) DeleteExpiryPolicyRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM report_expiry_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrExpiryPolicyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const defaultAggregateSourceBudget = 1500 * time.Millisecond
+
+// aggregateSourceBudget is the soft deadline each source gets in a fan-out request.
+func (api *API) aggregateSourceBudget() time.Duration {
+	if api.Config.AggregateSourceBudgetMs > 0 {
+		return time.Duration(api.Config.AggregateSourceBudgetMs) * time.Millisecond
+	}
+	return defaultAggregateSourceBudget
+}
+
+// fanOut runs every call concurrently and waits at most budget for them. Sources that
+// fail or are still running at the deadline are left out of results and reported in the
+// statuses; degraded is true when any source is missing. The slow calls' contexts are
+// cancelled so they stop as soon as they notice.
+func fanOut[T any](ctx context.Context, budget time.Duration, calls map[string]func(context.Context) (T, error)) (results map[string]T, statuses []model.SourceStatus, degraded bool) {
+	type outcome struct {
+		source  string
+		result  T
+		err     error
+		latency time.Duration
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan outcome, len(calls))
+	for source, call := range calls {
+		go func(source string, call func(context.Context) (T, error)) {
+			result, err := call(ctx)
+			done <- outcome{source: source, result: result, err: err, latency: time.Since(start)}
+		}(source, call)
+	}
+
+	timer := time.NewTimer(budget)
+	defer timer.Stop()
+
+	results = make(map[string]T, len(calls))
+	pending := make(map[string]bool, len(calls))
+	for source := range calls {
+		pending[source] = true
+	}
+wait:
+	for len(pending) > 0 {
+		select {
+		case o := <-done:
+			delete(pending, o.source)
+			status := model.SourceStatus{Source: o.source, Status: model.SourceStatusOK, LatencyMs: millis(o.latency)}
+			if o.err != nil {
+				slog.WarnContext(ctx, "Fan-out source failed", "source", o.source, "err", o.err)
+				status.Status, status.Error = model.SourceStatusError, o.err.Error()
+				degraded = true
+			} else {
+				results[o.source] = o.result
+			}
+			statuses = append(statuses, status)
+		case <-timer.C:
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	for source := range pending {
+		slog.WarnContext(ctx, "Fan-out source missed its budget", "source", source, "budget", budget)
+		statuses = append(statuses, model.SourceStatus{Source: source, Status: model.SourceStatusTimeout, LatencyMs: millis(time.Since(start))})
+		degraded = true
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Source < statuses[j].Source })
+	return results, statuses, degraded
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
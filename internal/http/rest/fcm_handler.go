@@ -12,8 +12,8 @@ import (
 )
 
 type registerFCMRequest struct {
-	Token    string `json:"token"`
-	Platform string `json:"platform"`
+	Token    string `json:"token" validate:"required"`
+	Platform string `json:"platform" validate:"required,oneof=android ios web"`
 }
 
 type unregisterFCMRequest struct {
@@ -36,11 +36,8 @@ func (api *API) RegisterFCMToken(_ http.ResponseWriter, r *http.Request) *Server
 
 	req.Token = strings.TrimSpace(req.Token)
 	req.Platform = strings.ToLower(strings.TrimSpace(req.Platform))
-	if req.Token == "" || req.Platform == "" {
-		return respondWithError(nil, "token and platform are required", values.BadRequestBody, &tc)
-	}
-	if req.Platform != "android" && req.Platform != "ios" && req.Platform != "web" {
-		return respondWithError(nil, "platform must be android, ios, or web", values.BadRequestBody, &tc)
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
 	}
 
 	if err := api.UpsertFCMToken(r.Context(), userID.String(), req.Token, req.Platform); err != nil {
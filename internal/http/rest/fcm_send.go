@@ -2,35 +2,31 @@ package rest
 
 import (
 	"context"
-	"log"
 
-	"firebase.google.com/go/v4/messaging"
+	"github.com/bwise1/waze_kibris/internal/notifications"
 )
 
 // SendFCMToUser sends a data+notification message to all registered devices for a user.
-// No-op if Firebase Messaging is not configured or user has no tokens.
+// No-op if push is not configured or user has no tokens. Tokens FCM no longer knows are removed.
 func (api *API) SendFCMToUser(ctx context.Context, userID, title, body string, data map[string]string) error {
-	if api.FirebaseMessaging == nil {
+	if !api.Notifier.Enabled() {
 		return nil
 	}
 	tokens, err := api.GetFCMTokensForUser(ctx, userID)
 	if err != nil || len(tokens) == 0 {
 		return err
 	}
-	msg := &messaging.MulticastMessage{
-		Tokens: tokens,
-		Notification: &messaging.Notification{
-			Title: title,
-			Body:  body,
-		},
-		Data: data,
-	}
-	br, err := api.FirebaseMessaging.SendEachForMulticast(ctx, msg)
+	stale, failed, err := api.Notifier.Send(ctx, tokens, notifications.Notification{
+		Title: title,
+		Body:  body,
+		Data:  data,
+	})
 	if err != nil {
 		return err
 	}
-	if br.FailureCount > 0 {
-		log.Printf("FCM: sent %d failed %d for user %s", br.SuccessCount, br.FailureCount, userID)
+	if failed > 0 {
+		api.logger().WarnContext(ctx, "FCM sends failed", "recipient_id", userID, "failed", failed, "tokens", len(tokens))
 	}
+	api.forgetStaleTokens(ctx, stale)
 	return nil
 }
@@ -0,0 +1,396 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/nominatim"
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// Geocoder is one geocoding provider behind /places. Every method answers with
+// model.Place, so handlers never see a provider's own response shape.
+type Geocoder interface {
+	Name() string
+	// Configured reports whether the provider has the client and credentials it needs.
+	Configured() bool
+	Search(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error)
+	Reverse(ctx context.Context, lat, lon float64, params *stadiamaps.GeocodeQuery) ([]model.Place, error)
+	Autocomplete(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error)
+	// Details looks up a gid this provider returned.
+	Details(ctx context.Context, gid string) (*model.Place, error)
+}
+
+// geocoder returns the adapter for a provider name, or nil for unknown names.
+func (api *API) geocoder(name string) Geocoder {
+	switch name {
+	case GeocoderStadia:
+		return stadiaGeocoder{client: api.StadiaClient}
+	case GeocoderGoogle:
+		return googleGeocoder{client: api.GoogleMapsClient}
+	case GeocoderNominatim:
+		return nominatimGeocoder{client: api.NominatimClient}
+	}
+	return nil
+}
+
+const googleGIDPrefix = "google:place:"
+
+// googleGID builds a Pelias-style gid for a Google place so clients can tell the
+// source apart from Stadia gids.
+func googleGID(placeID string) string {
+	return googleGIDPrefix + placeID
+}
+
+const nominatimGIDPrefix = "nominatim:"
+
+// gidGeocoder returns the provider a gid came from.
+func gidGeocoder(gid string) string {
+	switch {
+	case strings.HasPrefix(gid, googleGIDPrefix):
+		return GeocoderGoogle
+	case strings.HasPrefix(gid, nominatimGIDPrefix):
+		return GeocoderNominatim
+	}
+	return GeocoderStadia
+}
+
+type stadiaGeocoder struct {
+	client *stadiamaps.Client
+}
+
+func (stadiaGeocoder) Name() string { return GeocoderStadia }
+
+func (s stadiaGeocoder) Configured() bool { return s.client != nil && s.client.APIKey != "" }
+
+func (s stadiaGeocoder) Search(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	results, err := s.client.Search(ctx, text, params)
+	if err != nil {
+		return nil, err
+	}
+	return stadiaPlaces(results.Features), nil
+}
+
+func (s stadiaGeocoder) Reverse(ctx context.Context, lat, lon float64, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	results, err := s.client.ReverseGeocode(ctx, lat, lon, params)
+	if err != nil {
+		return nil, err
+	}
+	return stadiaPlaces(results.Features), nil
+}
+
+func (s stadiaGeocoder) Autocomplete(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	suggestions, err := s.client.Autocomplete(ctx, text, params)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Place, 0, len(suggestions))
+	for _, sg := range suggestions {
+		out = append(out, model.Place{GID: sg.GID, Name: sg.Name, Address: sg.CoarseLocation, Layer: sg.Layer, Source: GeocoderStadia})
+	}
+	return out, nil
+}
+
+func (s stadiaGeocoder) Details(ctx context.Context, gid string) (*model.Place, error) {
+	d, err := s.client.PlaceDetail(ctx, gid)
+	if err != nil {
+		return nil, err
+	}
+	p := &model.Place{
+		GID:         gid,
+		Name:        d.Name,
+		Address:     d.Address,
+		Coordinates: []float64{d.Longitude, d.Latitude},
+		Source:      GeocoderStadia,
+		Phone:       d.Phone,
+		Website:     d.Website,
+	}
+	if d.Hours != "" {
+		p.Hours = []string{d.Hours}
+	}
+	return p, nil
+}
+
+// stadiaPlaces converts Pelias features. Town-level properties are often missing, so
+// Locality takes the most specific one there is.
+func stadiaPlaces(features []stadiamaps.GeoJSONFeature) []model.Place {
+	out := make([]model.Place, 0, len(features))
+	for _, f := range features {
+		prop := func(keys ...string) string {
+			for _, k := range keys {
+				if s, _ := f.Properties[k].(string); strings.TrimSpace(s) != "" {
+					return strings.TrimSpace(s)
+				}
+			}
+			return ""
+		}
+		p := model.Place{
+			GID:      prop("gid"),
+			Name:     prop("name"),
+			Address:  prop("label"),
+			Layer:    prop("layer"),
+			Source:   GeocoderStadia,
+			Street:   prop("street"),
+			Locality: prop("locality", "localadmin", "county"),
+			Region:   prop("region"),
+		}
+		if f.Geometry != nil {
+			p.Coordinates = f.Geometry.Coordinates
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+type googleGeocoder struct {
+	client *googlemaps.GoogleMapsClient
+}
+
+func (googleGeocoder) Name() string { return GeocoderGoogle }
+
+func (g googleGeocoder) Configured() bool { return g.client != nil && g.client.APIKey != "" }
+
+func (g googleGeocoder) Search(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	var focus *googlemaps.LatLng
+	if params.FocusPointLat != nil && params.FocusPointLon != nil {
+		focus = &googlemaps.LatLng{Lat: *params.FocusPointLat, Lng: *params.FocusPointLon}
+	}
+	results, err := g.client.PlaceSearch(ctx, text, focus, 0)
+	if googleNoResults(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []model.Place
+	for _, r := range results.Results {
+		if params.Size != nil && len(out) >= *params.Size {
+			break
+		}
+		out = append(out, googlePlace(r))
+	}
+	return out, nil
+}
+
+func (g googleGeocoder) Reverse(ctx context.Context, lat, lon float64, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	var language string
+	if params.Lang != nil {
+		language = *params.Lang
+	}
+	results, err := g.client.ReverseGeocode(ctx, lat, lon, language)
+	if googleNoResults(err) {
+		return []model.Place{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := []model.Place{}
+	for _, r := range results.Results {
+		if params.Size != nil && len(out) >= *params.Size {
+			break
+		}
+		loc := r.Geometry.Location
+		out = append(out, model.Place{
+			GID:         googleGID(r.PlaceID),
+			Name:        r.FormattedAddress,
+			Address:     r.FormattedAddress,
+			Coordinates: []float64{loc.Lng, loc.Lat},
+			Layer:       googleLayer(r.Types),
+			Source:      GeocoderGoogle,
+		})
+	}
+	return out, nil
+}
+
+func (g googleGeocoder) Autocomplete(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	results, err := g.client.PlaceAutocomplete(ctx, text, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []model.Place
+	for _, p := range results.Predictions {
+		if params.Size != nil && len(out) >= *params.Size {
+			break
+		}
+		out = append(out, model.Place{
+			GID:     googleGID(p.PlaceID),
+			Name:    p.StructuredFormatting.MainText,
+			Address: p.StructuredFormatting.SecondaryText,
+			Layer:   googleLayer(p.Types),
+			Source:  GeocoderGoogle,
+		})
+	}
+	return out, nil
+}
+
+// googleDetailFields are the Place Details fields model.Place has room for.
+var googleDetailFields = []string{
+	"place_id", "name", "formatted_address", "geometry", "types",
+	"formatted_phone_number", "website", "opening_hours", "rating",
+}
+
+func (g googleGeocoder) Details(ctx context.Context, gid string) (*model.Place, error) {
+	placeID, ok := strings.CutPrefix(gid, googleGIDPrefix)
+	if !ok {
+		return nil, fmt.Errorf("not a Google gid: %s", gid)
+	}
+	r, err := g.client.GetPlaceDetails(ctx, placeID, googleDetailFields)
+	if err != nil {
+		return nil, err
+	}
+	p := googlePlace(*r)
+	p.Phone = r.FormattedPhone
+	p.Website = r.Website
+	if r.OpeningHours != nil {
+		p.Hours = r.OpeningHours.WeekdayText
+	}
+	if r.Rating > 0 {
+		rating := r.Rating
+		p.Rating = &rating
+	}
+	return &p, nil
+}
+
+func googlePlace(r googlemaps.PlaceDetailsResult) model.Place {
+	loc := r.Geometry.Location
+	return model.Place{
+		GID:         googleGID(r.PlaceID),
+		Name:        r.Name,
+		Address:     r.FormattedAddress,
+		Coordinates: []float64{loc.Lng, loc.Lat},
+		Layer:       googleLayer(r.Types),
+		Source:      GeocoderGoogle,
+	}
+}
+
+// nominatimFocusDeg is how far around a focus point (~25 km) Nominatim prefers results.
+const nominatimFocusDeg = 0.25
+
+type nominatimGeocoder struct {
+	client *nominatim.Client
+}
+
+func (nominatimGeocoder) Name() string { return GeocoderNominatim }
+
+func (n nominatimGeocoder) Configured() bool { return n.client != nil && n.client.BaseURL != "" }
+
+func (n nominatimGeocoder) Search(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	places, err := n.client.Search(ctx, text, nominatimSearchParams(params))
+	if err != nil {
+		return nil, err
+	}
+	return nominatimPlaces(places), nil
+}
+
+func (n nominatimGeocoder) Reverse(ctx context.Context, lat, lon float64, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	place, err := n.client.Reverse(ctx, lat, lon, nominatimSearchParams(params).Language)
+	if err != nil {
+		return nil, err
+	}
+	if place == nil {
+		return []model.Place{}, nil
+	}
+	return nominatimPlaces([]nominatim.Place{*place}), nil
+}
+
+// Autocomplete runs a search: Nominatim has no autocomplete endpoint, and a self-hosted
+// server has no usage policy against searching as the user types.
+func (n nominatimGeocoder) Autocomplete(ctx context.Context, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	return n.Search(ctx, text, params)
+}
+
+func (n nominatimGeocoder) Details(ctx context.Context, gid string) (*model.Place, error) {
+	ref, ok := strings.CutPrefix(gid, nominatimGIDPrefix)
+	if !ok {
+		return nil, fmt.Errorf("not a Nominatim gid: %s", gid)
+	}
+	places, err := n.client.Lookup(ctx, []string{ref}, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, fmt.Errorf("nominatim has no place %s", ref)
+	}
+	p := nominatimPlaces(places)[0]
+	return &p, nil
+}
+
+func nominatimSearchParams(params *stadiamaps.GeocodeQuery) nominatim.SearchParams {
+	var sp nominatim.SearchParams
+	if params == nil {
+		return sp
+	}
+	if params.Size != nil {
+		sp.Limit = *params.Size
+	}
+	if params.Lang != nil {
+		sp.Language = *params.Lang
+	}
+	if params.FocusPointLat != nil && params.FocusPointLon != nil {
+		lat, lon := *params.FocusPointLat, *params.FocusPointLon
+		sp.Viewbox = []float64{lon - nominatimFocusDeg, lat - nominatimFocusDeg, lon + nominatimFocusDeg, lat + nominatimFocusDeg}
+	}
+	return sp
+}
+
+// nominatimPlaces converts Nominatim results. Plain addresses have no name of their own,
+// so they're named street first, as addresses are written on the island; anything
+// else unnamed takes the first part of its display name.
+func nominatimPlaces(places []nominatim.Place) []model.Place {
+	out := make([]model.Place, 0, len(places))
+	for _, np := range places {
+		addr := func(keys ...string) string {
+			for _, k := range keys {
+				if s := strings.TrimSpace(np.Address[k]); s != "" {
+					return s
+				}
+			}
+			return ""
+		}
+		name := np.Name
+		if name == "" && addr("house_number") != "" && addr("road") != "" {
+			name = addr("road") + " " + addr("house_number")
+		}
+		if name == "" {
+			first, _, _ := strings.Cut(np.DisplayName, ",")
+			name = strings.TrimSpace(first)
+		}
+		p := model.Place{
+			GID:      nominatimGIDPrefix + np.OSMRef(),
+			Name:     name,
+			Address:  np.DisplayName,
+			Layer:    nominatimLayer(np.AddressType),
+			Source:   GeocoderNominatim,
+			Street:   addr("road", "pedestrian"),
+			Locality: addr("city", "town", "village", "hamlet", "county"),
+			Region:   addr("state", "county"),
+		}
+		if lat, lon, err := np.Coordinates(); err == nil {
+			p.Coordinates = []float64{lon, lat}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// nominatimLayer maps a Nominatim address type onto the closest Pelias layer.
+func nominatimLayer(addressType string) string {
+	switch addressType {
+	case "house", "building", "house_number":
+		return "address"
+	case "road":
+		return "street"
+	case "city", "town", "village", "hamlet", "suburb", "quarter", "neighbourhood":
+		return "locality"
+	case "county":
+		return "county"
+	case "state":
+		return "region"
+	case "country":
+		return "country"
+	}
+	return "venue"
+}
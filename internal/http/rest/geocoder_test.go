@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/nominatim"
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestStadiaPlaces(t *testing.T) {
+	places := stadiaPlaces([]stadiamaps.GeoJSONFeature{{
+		Geometry: &stadiamaps.GeoJSONPoint{Type: "Point", Coordinates: []float64{33.36, 35.18}},
+		Properties: map[string]interface{}{
+			"gid": "openstreetmap:venue:node/1", "name": "Büyük Han", "label": "Büyük Han, Nicosia",
+			"layer": "venue", "street": "Asmaaltı Sk", "localadmin": "Nicosia", "locality": " ",
+		},
+	}, {
+		Properties: map[string]interface{}{"gid": "whosonfirst:region:1", "name": "Kyrenia"},
+	}})
+
+	if len(places) != 2 {
+		t.Fatalf("got %d places, want 2", len(places))
+	}
+	want := model.Place{
+		GID: "openstreetmap:venue:node/1", Name: "Büyük Han", Address: "Büyük Han, Nicosia", Layer: "venue",
+		Source: GeocoderStadia, Street: "Asmaaltı Sk", Locality: "Nicosia",
+	}
+	got := places[0]
+	got.Coordinates = nil
+	if got.GID != want.GID || got.Name != want.Name || got.Address != want.Address || got.Layer != want.Layer ||
+		got.Source != want.Source || got.Street != want.Street || got.Locality != want.Locality {
+		t.Errorf("place = %+v, want %+v", got, want)
+	}
+	if c := places[0].Coordinates; len(c) != 2 || c[0] != 33.36 {
+		t.Errorf("coordinates = %v, want [33.36 35.18]", c)
+	}
+	if places[1].Coordinates != nil {
+		t.Errorf("place without geometry has coordinates %v", places[1].Coordinates)
+	}
+}
+
+func TestNominatimPlaces(t *testing.T) {
+	places := nominatimPlaces([]nominatim.Place{{
+		OSMType: "way", OSMID: 168842577, Lat: "35.1747851", Lon: "33.3611472", AddressType: "road",
+		Name:        "Arasta Sokak",
+		DisplayName: "Arasta Sokak, Arabahmet, Lefkoşa, Lefkoşa Kazası, Kuzey Kıbrıs",
+		Address:     map[string]string{"road": "Arasta Sokak", "city": "Lefkoşa", "county": "Lefkoşa Kazası"},
+	}, {
+		OSMType: "node", OSMID: 7, Lat: "bad", AddressType: "house",
+		DisplayName: "12, Kordon Boyu Sokak, Girne",
+		Address:     map[string]string{"house_number": "12", "road": "Kordon Boyu Sokak", "town": "Girne"},
+	}})
+
+	got := places[0]
+	if got.GID != "nominatim:W168842577" || got.Name != "Arasta Sokak" || got.Layer != "street" ||
+		got.Source != GeocoderNominatim || got.Street != "Arasta Sokak" || got.Locality != "Lefkoşa" ||
+		got.Region != "Lefkoşa Kazası" {
+		t.Errorf("place = %+v", got)
+	}
+	if c := got.Coordinates; len(c) != 2 || c[0] != 33.3611472 || c[1] != 35.1747851 {
+		t.Errorf("coordinates = %v", c)
+	}
+	if got := places[1]; got.Name != "Kordon Boyu Sokak 12" || got.Layer != "address" || got.Locality != "Girne" || got.Coordinates != nil {
+		t.Errorf("unnamed place = %+v", got)
+	}
+	if label := reportLocationLabel(places[1]); label != "Kordon Boyu Sokak, Girne" {
+		t.Errorf("label = %q", label)
+	}
+}
+
+func TestGIDGeocoder(t *testing.T) {
+	if got := gidGeocoder(googleGID("abc")); got != GeocoderGoogle {
+		t.Errorf("google gid -> %q", got)
+	}
+	if got := gidGeocoder("openstreetmap:venue:node/1"); got != GeocoderStadia {
+		t.Errorf("pelias gid -> %q", got)
+	}
+	if got := gidGeocoder("nominatim:N1"); got != GeocoderNominatim {
+		t.Errorf("nominatim gid -> %q", got)
+	}
+}
+
+func TestReportLocationLabel(t *testing.T) {
+	for _, tc := range []struct {
+		place model.Place
+		want  string
+	}{
+		{model.Place{Source: GeocoderStadia, Name: "12 Main St", Street: "Main St", Locality: "Kyrenia"}, "Main St, Kyrenia"},
+		{model.Place{Source: GeocoderStadia, Name: "Kyrenia", Locality: "Kyrenia"}, "Kyrenia"},
+		{model.Place{Source: GeocoderStadia, Region: "Girne"}, "Girne"},
+		{model.Place{Source: GeocoderGoogle, Address: "Main St, Kyrenia, Cyprus"}, "Main St, Kyrenia"},
+	} {
+		if got := reportLocationLabel(tc.place); got != tc.want {
+			t.Errorf("reportLocationLabel(%+v) = %q, want %q", tc.place, got, tc.want)
+		}
+	}
+}
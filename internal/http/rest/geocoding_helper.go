@@ -0,0 +1,268 @@
+package rest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+// Geocoding providers behind the /places search, reverse and autocomplete endpoints.
+const (
+	GeocoderStadia = "stadia"
+	GeocoderGoogle = "google"
+	// GeocoderNominatim is a self-hosted OpenStreetMap geocoder: no key and no per-call
+	// cost, so it comes last as the fallback.
+	GeocoderNominatim = "nominatim"
+)
+
+// geocoderPriority is the tie-break order when providers score the same (e.g. at startup).
+var geocoderPriority = []string{GeocoderStadia, GeocoderGoogle, GeocoderNominatim}
+
+var errNoGeocoder = errors.New("no geocoding provider configured")
+
+// geocoderConfigured reports whether a provider has the credentials it needs.
+func (api *API) geocoderConfigured(provider string) bool {
+	g := api.geocoder(provider)
+	return g != nil && g.Configured()
+}
+
+// geocodingPin returns the provider pinned in config, or "" for automatic selection.
+// Unknown values are treated as unset.
+func (api *API) geocodingPin() string {
+	pin := strings.ToLower(strings.TrimSpace(api.Config.GeocodingProvider))
+	for _, p := range geocoderPriority {
+		if pin == p {
+			return p
+		}
+	}
+	return ""
+}
+
+// geocoderParam reads the optional provider query parameter of the /places endpoints. A
+// non-empty message means the request is invalid.
+func (api *API) geocoderParam(r *http.Request) (string, string) {
+	provider := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("provider")))
+	switch {
+	case provider == "":
+		return "", ""
+	case !slices.Contains(geocoderPriority, provider):
+		return "", fmt.Sprintf("provider must be one of %s", strings.Join(geocoderPriority, ", "))
+	case !api.geocoderConfigured(provider):
+		return "", fmt.Sprintf("provider %s is not configured", provider)
+	}
+	return provider, ""
+}
+
+// geocoderHealthReport returns every provider's health, best first.
+func (api *API) geocoderHealthReport() []model.ProviderHealth {
+	report := make([]model.ProviderHealth, len(geocoderPriority))
+	for i, p := range geocoderPriority {
+		report[i] = api.geocoderHealth.snapshot(p)
+		report[i].Configured = api.geocoderConfigured(p)
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Configured != report[j].Configured {
+			return report[i].Configured
+		}
+		return report[i].Score > report[j].Score
+	})
+	return report
+}
+
+// geocoderOrder is the order providers are tried in: only the one the request asked
+// for or the one pinned in config, otherwise every configured provider, healthiest first
+// with the ones currently down moved to the end.
+func (api *API) geocoderOrder(provider string) []string {
+	if provider != "" {
+		return []string{provider}
+	}
+	if pin := api.geocodingPin(); pin != "" {
+		return []string{pin}
+	}
+	var up, down []string
+	now := time.Now()
+	for _, ph := range api.geocoderHealthReport() {
+		switch {
+		case !ph.Configured:
+		case api.geocoderHealth.down(ph.Provider, now):
+			down = append(down, ph.Provider)
+		default:
+			up = append(up, ph.Provider)
+		}
+	}
+	return append(up, down...)
+}
+
+// geocode runs op against each provider in order until one succeeds, recording the
+// outcome of every attempt. provider restricts it to that one; "" picks automatically.
+func geocode[T any](ctx context.Context, api *API, op, provider string, call func(context.Context, Geocoder) (T, error)) (T, error) {
+	var zero T
+	err := errNoGeocoder
+	for _, name := range api.geocoderOrder(provider) {
+		g := api.geocoder(name)
+		if g == nil || !g.Configured() {
+			continue
+		}
+		start := time.Now()
+		var result T
+		result, err = call(ctx, g)
+		if ctx.Err() != nil {
+			// The client went away; that says nothing about the provider.
+			return zero, ctx.Err()
+		}
+		api.geocoderHealth.record(name, time.Since(start), err)
+		if err == nil {
+			return result, nil
+		}
+		api.logger().WarnContext(ctx, "Geocoding failed", "op", op, "provider", name, "err", err)
+	}
+	return zero, err
+}
+
+// googleNoResults reports whether a Google error only means nothing matched.
+func googleNoResults(err error) bool {
+	var gerr *googlemaps.Error
+	return errors.As(err, &gerr) && gerr.NoResults()
+}
+
+// providerNotFound reports whether a geocoding provider said the place asked for does
+// not exist.
+func providerNotFound(err error) bool {
+	var nf interface{ NotFound() bool }
+	return googleNoResults(err) || (errors.As(err, &nf) && nf.NotFound())
+}
+
+// providerRateLimited reports whether a map provider turned the request away for
+// exceeding its rate limit or quota. Each client's error type knows its provider's signal.
+func providerRateLimited(err error) bool {
+	var rl interface{ RateLimited() bool }
+	return errors.As(err, &rl) && rl.RateLimited()
+}
+
+func (api *API) searchPlaces(ctx context.Context, provider, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	return geocode(ctx, api, "search", provider, func(ctx context.Context, g Geocoder) ([]model.Place, error) {
+		return g.Search(ctx, text, params)
+	})
+}
+
+func (api *API) reverseGeocode(ctx context.Context, provider string, lat, lon float64, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	return geocode(ctx, api, "reverse", provider, func(ctx context.Context, g Geocoder) ([]model.Place, error) {
+		return g.Reverse(ctx, lat, lon, params)
+	})
+}
+
+func (api *API) autocompletePlaces(ctx context.Context, provider, text string, params *stadiamaps.GeocodeQuery) ([]model.Place, error) {
+	return geocode(ctx, api, "autocomplete", provider, func(ctx context.Context, g Geocoder) ([]model.Place, error) {
+		return g.Autocomplete(ctx, text, params)
+	})
+}
+
+// placeDetails looks the gid up with the provider it came from.
+func (api *API) placeDetails(ctx context.Context, gid string) (*model.Place, error) {
+	return geocode(ctx, api, "details", gidGeocoder(gid), func(ctx context.Context, g Geocoder) (*model.Place, error) {
+		return g.Details(ctx, gid)
+	})
+}
+
+// googleLayer maps Google place types onto the closest Pelias layer.
+func googleLayer(types []string) string {
+	for _, t := range types {
+		switch t {
+		case "street_address", "premise", "subpremise":
+			return "address"
+		case "route":
+			return "street"
+		case "locality", "sublocality", "neighborhood":
+			return "locality"
+		case "establishment", "point_of_interest":
+			return "venue"
+		}
+	}
+	return "venue"
+}
+
+// Sources queried by /places/unified-search.
+const (
+	SearchSourceSaved = "saved"
+)
+
+// unifiedSearchResponse is what /places/unified-search returns: whatever the sources
+// produced within their budget, saved locations first.
+type unifiedSearchResponse struct {
+	Results  []model.Place        `json:"results"`
+	Degraded bool                 `json:"degraded"`
+	Sources  []model.SourceStatus `json:"sources"`
+}
+
+// unifiedSearch queries the user's saved locations and every configured geocoder at once,
+// or only the given provider when set.
+// A source that errors or misses its budget is skipped rather than failing the search;
+// only when none answered is an error returned.
+func (api *API) unifiedSearch(ctx context.Context, userID uuid.UUID, provider, text string, params *stadiamaps.GeocodeQuery) (unifiedSearchResponse, error) {
+	size := 10
+	if params.Size != nil {
+		size = *params.Size
+	}
+	calls := map[string]func(context.Context) ([]model.Place, error){
+		SearchSourceSaved: func(ctx context.Context) ([]model.Place, error) {
+			saved, err := api.SearchSavedLocationsRepo(ctx, userID, text, params.FocusPointLat, params.FocusPointLon, size)
+			if err != nil {
+				return nil, err
+			}
+			out := make([]model.Place, 0, len(saved))
+			for _, s := range saved {
+				gid := fmt.Sprintf("saved:location:%d", s.ID)
+				if s.PlaceID != nil && *s.PlaceID != "" {
+					gid = *s.PlaceID
+				}
+				out = append(out, model.Place{GID: gid, Name: s.Name, Address: s.Address, Coordinates: []float64{s.Longitude, s.Latitude}, Source: SearchSourceSaved})
+			}
+			return out, nil
+		},
+	}
+	// Each geocoder is called directly: searchPlaces would fall back to the next
+	// provider, which here is already running alongside.
+	pinned := cmp.Or(provider, api.geocodingPin())
+	for _, name := range geocoderPriority {
+		g := api.geocoder(name)
+		if !g.Configured() || (pinned != "" && pinned != name) {
+			continue
+		}
+		calls[name] = func(ctx context.Context) ([]model.Place, error) {
+			start := time.Now()
+			results, err := g.Search(ctx, text, params)
+			if ctx.Err() == nil {
+				api.geocoderHealth.record(name, time.Since(start), err)
+			}
+			return results, err
+		}
+	}
+
+	results, statuses, degraded := fanOut(ctx, api.aggregateSourceBudget(), calls)
+	if len(results) == 0 {
+		return unifiedSearchResponse{}, errors.New("no search source answered in time")
+	}
+	resp := unifiedSearchResponse{Results: []model.Place{}, Degraded: degraded, Sources: statuses}
+	seen := make(map[string]bool)
+	for _, source := range append([]string{SearchSourceSaved}, geocoderPriority...) {
+		for _, r := range results[source] {
+			if r.GID != "" && seen[r.GID] {
+				continue
+			}
+			seen[r.GID] = true
+			resp.Results = append(resp.Results, r)
+		}
+	}
+	return resp, nil
+}
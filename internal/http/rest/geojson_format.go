@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// formatGeoJSON is the ?format= value that turns a response into a bare FeatureCollection.
+const formatGeoJSON = "geojson"
+
+// geoJSONFormat reports whether the caller asked for GeoJSON instead of the envelope.
+func geoJSONFormat(r *http.Request) bool {
+	return r.URL.Query().Get("format") == formatGeoJSON
+}
+
+// geoJSONView turns a handler's response data into a FeatureCollection: routes become
+// LineStrings, reports and places Points. ok is false for data it has no GeoJSON shape
+// for, which is then sent in the envelope as usual.
+func geoJSONView(data interface{}) (fc model.FeatureCollection, ok bool) {
+	fc = model.FeatureCollection{Type: "FeatureCollection", Features: []model.Feature{}}
+	switch d := data.(type) {
+	case []model.Report:
+		for _, r := range d {
+			fc.Features = append(fc.Features, pointFeature(r.ID, []float64{r.Longitude, r.Latitude}, r))
+		}
+	case model.ReportSearchResult:
+		return geoJSONView(d.Reports)
+	case model.Page[model.Report]:
+		return geoJSONView(d.Data)
+	case []model.Place:
+		for _, p := range d {
+			fc.Features = append(fc.Features, pointFeature(p.GID, p.Coordinates, p))
+		}
+	case model.Place:
+		return geoJSONView([]model.Place{d})
+	case unifiedSearchResponse:
+		return geoJSONView(d.Results)
+	case *mapbox.DirectionsResponse:
+		if d == nil {
+			return fc, false
+		}
+		for i, route := range d.Routes {
+			fc.Features = append(fc.Features, routeFeature(route, "route", i, d.Provider))
+		}
+		if d.WalkingLeg != nil {
+			fc.Features = append(fc.Features, routeFeature(d.WalkingLeg.Route, "walking", 0, d.Provider))
+		}
+	default:
+		return fc, false
+	}
+	return fc, true
+}
+
+// pointFeature makes a Point feature, or one with a null geometry when there are no
+// coordinates.
+func pointFeature(id interface{}, coordinates []float64, properties interface{}) model.Feature {
+	f := model.Feature{Type: "Feature", ID: id, Properties: properties}
+	if len(coordinates) >= 2 {
+		f.Geometry = &model.Geometry{Type: "Point", Coordinates: coordinates[:2]}
+	}
+	return f
+}
+
+func routeFeature(route mapbox.Route, kind string, index int, provider string) model.Feature {
+	properties := model.RouteFeatureProperties{
+		Kind:              kind,
+		RouteIndex:        index,
+		DurationS:         route.Duration,
+		DistanceM:         route.Distance,
+		AdjustedDurationS: route.AdjustedDuration,
+		Provider:          provider,
+	}
+	for _, leg := range route.Legs {
+		if leg.Summary != "" {
+			properties.Summary = leg.Summary
+			break
+		}
+	}
+	if route.ArrivalWindow != nil {
+		properties.ETA = route.ArrivalWindow.Formatted
+	}
+	return model.Feature{
+		Type:       "Feature",
+		Geometry:   &model.Geometry{Type: "LineString", Coordinates: route.Geometry.Coordinates},
+		Properties: properties,
+	}
+}
+
+// writeGeoJSONResponse sends a FeatureCollection as application/geo+json.
+func writeGeoJSONResponse(w http.ResponseWriter, fc model.FeatureCollection, statusCode int) {
+	content, err := json.Marshal(fc)
+	if err != nil {
+		writeErrorResponse(w, err, values.Error, "unable to marshal GeoJSON response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(content); err != nil {
+		slog.Warn("Unable to write GeoJSON response", "err", err)
+	}
+}
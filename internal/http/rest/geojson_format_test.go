@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestGeoJSONView(t *testing.T) {
+	fc, ok := geoJSONView([]model.Report{{ID: 7, Type: "POLICE", Latitude: 35.18, Longitude: 33.36}})
+	if !ok || fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+		t.Fatalf("reports: ok=%v fc=%+v", ok, fc)
+	}
+	f := fc.Features[0]
+	if f.ID != int64(7) || f.Geometry.Type != "Point" {
+		t.Errorf("report feature = %+v", f)
+	}
+	if c := f.Geometry.Coordinates.([]float64); c[0] != 33.36 || c[1] != 35.18 {
+		t.Errorf("report coordinates = %v, want [lng, lat]", c)
+	}
+
+	// Autocomplete suggestions may have no coordinates.
+	fc, _ = geoJSONView(unifiedSearchResponse{Results: []model.Place{{GID: "a", Coordinates: []float64{33.3, 35.1}}, {GID: "b"}}})
+	if len(fc.Features) != 2 || fc.Features[0].Geometry == nil || fc.Features[1].Geometry != nil {
+		t.Errorf("places = %+v, want a Point and a null geometry", fc.Features)
+	}
+
+	line := [][]float64{{33.30, 35.10}, {33.31, 35.11}}
+	fc, ok = geoJSONView(&mapbox.DirectionsResponse{
+		Provider: "valhalla",
+		Routes: []mapbox.Route{
+			{Geometry: mapbox.LineString{Coordinates: line}, Duration: 120, Legs: []mapbox.Leg{{Summary: "Atatürk Cd."}}},
+			{Geometry: mapbox.LineString{Coordinates: line}, Duration: 150},
+		},
+	})
+	if !ok || len(fc.Features) != 2 {
+		t.Fatalf("routes: ok=%v fc=%+v", ok, fc)
+	}
+	props := fc.Features[1].Properties.(model.RouteFeatureProperties)
+	if fc.Features[0].Geometry.Type != "LineString" || props.RouteIndex != 1 || props.Provider != "valhalla" {
+		t.Errorf("route feature = %+v", fc.Features[1])
+	}
+	if p := fc.Features[0].Properties.(model.RouteFeatureProperties); p.Summary != "Atatürk Cd." || p.Kind != "route" {
+		t.Errorf("primary route properties = %+v", p)
+	}
+
+	if _, ok := geoJSONView(model.NavigationSession{}); ok {
+		t.Error("data without a GeoJSON shape was converted")
+	}
+}
@@ -2,9 +2,12 @@ package rest
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
@@ -12,6 +15,14 @@ import (
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxPinnedGroupMessages = 5
+	// Announcements bypass members' notification levels, so they are rate limited per group.
+	announcementLimit  = 3
+	announcementWindow = 24 * time.Hour
 )
 
 func (api *API) GroupRoutes() chi.Router {
@@ -22,8 +33,9 @@ func (api *API) GroupRoutes() chi.Router {
 
 		r.Method(http.MethodPost, "/", Handler(api.CreateCommunityGroupHandler))
 		//(e.g., public groups, groups nearby, user's groups)
-		// Query Params: ?query=..., ?nearby=lat,lon,radius, ?member=me, ?public=true/false, ?page=1, ?pageSize=20
-		// Response: List of groups matching criteria
+		// Query Params: ?filter_type=near_me|my_routes|popular, ?lat=&lng=&radius= (near_me),
+		// ?page=1, ?page_size=20 (max 100) or ?cursor=<next_cursor>
+		// Response: a page of groups matching criteria
 		r.Method(http.MethodGet, "/", Handler(api.SearchForListOfGroupsHandler))
 		// Get details of a specific group
 		// Response: Full group details (incl. member count, maybe recent messages preview)
@@ -34,25 +46,28 @@ func (api *API) GroupRoutes() chi.Router {
 		// Delete a group - Requires Admin/Owner role
 		// Response: Success/Failure message
 		r.Method(http.MethodDelete, "/{groupID}", Handler(api.placeHolderHandler))
-		// Join a public group / Request to join a private group
-		// Response: Membership details or Pending status
-		r.Method(http.MethodPost, "/{short_code}/join", Handler(api.JoinGroupByShortCodeHandler)) // Or POST to /{groupID}/members using authenticated user ID
-		// Leave a group
+		// Join a public group / Request to join a private group (by group ID or short code)
+		// Response: Membership details; status "pending" until an admin approves a private join
+		r.Method(http.MethodPost, "/{groupID}/join", Handler(api.JoinGroupHandler))
+		// Leave a group, or withdraw a pending join request
 		// Response: Success/Failure message
-		r.Method(http.MethodDelete, "/{groupID}/leave", Handler(api.LeaveGroupHandler)) // Or DELETE /{groupID}/members/me
-		// List members of a group
-		// Query Params: ?page=1, ?pageSize=50
-		// Response: List of members (User ID, Username, Role)
-		r.Method(http.MethodGet, "/{groupID}/members", Handler(api.placeHolderHandler))
+		r.Method(http.MethodDelete, "/{groupID}/leave", Handler(api.LeaveGroupHandler))
+		// List members of a group - Requires Member role; ?status=pending lists join requests (Admin only)
+		// Query Params: ?page=1, ?pageSize=50, ?status=active|pending
+		// Response: Page of members (User ID, Username, Role) with the total
+		r.Method(http.MethodGet, "/{groupID}/members", Handler(api.ListGroupMembersHandler))
 		// Manage group members (Admin actions)
 		// Update a member's role (e.g., promote to admin) - Requires Admin role
-		// Request Body: { "role": "admin/member" }
+		// Request Body: { "role": "admin/moderator/member" }
+		// Response: Updated membership details
+		r.Method(http.MethodPut, "/{groupID}/members/{userID}", Handler(api.UpdateGroupMemberHandler))
+		// Approve a pending request to join a private group - Requires Admin role
 		// Response: Updated membership details
-		r.Method(http.MethodPut, "/{groupID}/members/{userID}", Handler(api.placeHolderHandler))
-		// Remove (kick) a member from a group - Requires Admin role
+		r.Method(http.MethodPost, "/{groupID}/members/{userID}/approve", Handler(api.ApproveGroupMemberHandler))
+		// Remove (kick) a member from a group, or reject a join request - Requires Admin role
 		// Response: Success/Failure message
-		r.Method(http.MethodDelete, "/{groupID}/members/{userID}", Handler(api.placeHolderHandler))
-		// Invite a user to the group - Requires Admin/Member (configurable)
+		r.Method(http.MethodDelete, "/{groupID}/members/{userID}", Handler(api.RemoveGroupMemberHandler))
+		// Invite a user to the group - Requires Member role; emails the invitee a deep link with the group short code
 		// Request Body: { "invited_user_id": "uuid" } or { "invited_user_email": "email" }
 		// Response: Invitation details or Success/Failure
 		r.Method(http.MethodPost, "/{groupID}/invitations", Handler(api.CreateInvitationHandler))
@@ -61,7 +76,7 @@ func (api *API) GroupRoutes() chi.Router {
 		r.Method(http.MethodGet, "/{groupID}/invitations", Handler(api.ListInvitationsByGroupHandler))
 		// User actions on invitations (could be top-level or user-scoped)
 		// Accept an invitation
-		// Response: Success/Failure (results in membership creation)
+		// Response: Success/Failure (results in an active membership, even for private groups)
 		r.Method(http.MethodPost, "/invitations/{invitationID}/accept", Handler(api.AcceptInvitationHandler))
 		// Decline an invitation
 		// Response: Success/Failure
@@ -70,13 +85,14 @@ func (api *API) GroupRoutes() chi.Router {
 		// Response: List of invitations for the logged-in user
 		r.Method(http.MethodGet, "/users/me/invitations", Handler(api.ListMyInvitationsHandler))
 		// Send a message to the group - Requires Member role
-		// Request Body: { "content": "...", "message_type": "text/location/report_link", "attachment_url": "..." }
+		// message_type "announcement" requires Admin role, is rate limited and is pushed to every member
+		// Request Body: { "content": "...", "message_type": "text/location/report_link", "attachment": { "kind": "location/eta/route", ... } }
 		// Response: The created message details
 		r.Method(http.MethodPost, "/{groupID}/messages", Handler(api.SendGroupMessageHandler))
 
 		// Get messages from the group - Requires Member role
-		// Query Params: ?before=<messageID/timestamp>, ?after=<messageID/timestamp>, ?limit=50
-		// Response: List of messages (paginated)
+		// Query Params: ?page_size=50 (max 100), ?cursor=<next_cursor> for older messages
+		// Response: a page of messages, newest first
 		r.Method(http.MethodGet, "/{groupID}/messages", Handler(api.GetGroupMessagesHandler))
 
 		// (Optional) Update a message - Requires Author role (within time limit?)
@@ -88,11 +104,40 @@ func (api *API) GroupRoutes() chi.Router {
 		// Response: Success/Failure message
 		r.Method(http.MethodDelete, "/{groupID}/messages/{messageID}", Handler(api.placeHolderHandler))
 
+		// Pin or unpin a message at the top of the group - Requires Admin role
+		r.Method(http.MethodPost, "/{groupID}/messages/{messageID}/pin", Handler(api.PinGroupMessageHandler))
+		r.Method(http.MethodDelete, "/{groupID}/messages/{messageID}/pin", Handler(api.UnpinGroupMessageHandler))
+
 		// (Optional) Mark messages as read - Requires Member role
 		// Request Body: { "last_read_message_id": "..." } or { "last_read_timestamp": "..." }
 		// Response: Success/Failure message
 		r.Method(http.MethodPost, "/{groupID}/read", Handler(api.MarkGroupReadHandler))
 
+		// Per-group push settings for the current member: all, mentions or muted
+		r.Method(http.MethodGet, "/{groupID}/notifications", Handler(api.GetGroupNotificationsHandler))
+		r.Method(http.MethodPut, "/{groupID}/notifications", Handler(api.UpdateGroupNotificationsHandler))
+
+		// Which members are online (connected over WebSocket on any instance) and navigating - Requires Member role
+		r.Method(http.MethodGet, "/{groupID}/presence", Handler(api.GetGroupPresenceHandler))
+		// Opt in or out of sharing live location and ETA with the group during a drive
+		// Request Body: { "enabled": true, "session_id": "...", "duration_minutes": 60 }
+		r.Method(http.MethodPut, "/{groupID}/location-sharing", Handler(api.UpdateLocationSharingHandler))
+		// Position update while sharing; relayed to the group as group_location_update
+		// Request Body: { "latitude": 35.18, "longitude": 33.36, "heading": 90, "speed": 12.5, "eta_seconds": 600 }
+		r.With(api.VerifySignedRequest).Method(http.MethodPost, "/{groupID}/location-sharing/position", Handler(api.ShareLocationHandler))
+		// Last known positions and ETAs of members sharing right now - Requires Member role
+		r.Method(http.MethodGet, "/{groupID}/locations", Handler(api.ListGroupLocationsHandler))
+		// Carpooling to a destination group's destination - Requires Member role
+		// Request Body: { "kind": "offer/request", "origin_latitude": 35.18, "origin_longitude": 33.36,
+		//   "depart_from": "...", "depart_until": "...", "seats": 2, "note": "..." }
+		// Matches (overlapping windows, pickup near the driver's route) are sent to both riders as ride_match
+		r.Method(http.MethodPost, "/{groupID}/rides", Handler(api.CreateGroupRideHandler))
+		// Open rides in the group; ?kind=offer|request
+		r.Method(http.MethodGet, "/{groupID}/rides", Handler(api.ListGroupRidesHandler))
+		// Matches for one of the caller's rides, closest pickup first
+		r.Method(http.MethodGet, "/{groupID}/rides/{rideID}/matches", Handler(api.ListGroupRideMatchesHandler))
+		r.Method(http.MethodDelete, "/{groupID}/rides/{rideID}", Handler(api.CancelGroupRideHandler))
+
 	})
 
 	return mux
@@ -114,19 +159,18 @@ func (api *API) GetGroupMessagesHandler(w http.ResponseWriter, r *http.Request)
 		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
 	}
 
-	messages, err := api.GetGroupMessages(r.Context(), groupID, 50)
+	page := parsePageParams(r.URL.Query(), defaultGroupMessagesPageSize, maxGroupMessagesPageSize)
+	messages, status, message, err := api.GetGroupMessagesHelper(r.Context(), groupID, page)
 	if err != nil {
-		return respondWithError(err, "failed to get group messages", values.Failed, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
-	if messages == nil {
-		messages = []model.GroupMessage{}
-	}
-	log.Printf("GetGroupMessages: groupID=%s returned %d messages", groupID.String(), len(messages))
+	api.expandMessageAttachments(r.Context(), messages.Data)
+	api.logger().DebugContext(r.Context(), "Loaded group messages", "group_id", groupID, "count", len(messages.Data))
 
 	return &ServerResponse{
-		Message:    "Messages retrieved",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 		Data:       messages,
 	}
 }
@@ -144,15 +188,129 @@ func (api *API) LeaveGroupHandler(w http.ResponseWriter, r *http.Request) *Serve
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	err = api.LeaveCommunityGroup(r.Context(), groupID, userID)
+	status, message, err := api.LeaveGroupHelper(r.Context(), groupID, userID)
 	if err != nil {
-		return respondWithError(err, "failed to leave group", values.Failed, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Successfully left the group",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListGroupMembersHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	pageSize, _ := strconv.Atoi(q.Get("pageSize"))
+
+	list, status, message, err := api.ListGroupMembersHelper(r.Context(), groupID, userID, q.Get("status"), page, pageSize)
+	if err != nil || status != values.Success {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       list,
+	}
+}
+
+// groupMemberParams parses the group and member IDs from the URL and the caller from the
+// context; resp is non-nil if any of them is missing or malformed.
+func groupMemberParams(r *http.Request, tc *tracing.Context) (groupID, memberID, callerID uuid.UUID, resp *ServerResponse) {
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return groupID, memberID, callerID, respondWithError(err, "invalid group ID format", values.BadRequestBody, tc)
+	}
+	memberID, err = uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		return groupID, memberID, callerID, respondWithError(err, "invalid user ID format", values.BadRequestBody, tc)
+	}
+	callerID, err = util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return groupID, memberID, callerID, respondWithError(err, "unable to get user ID from context", values.NotAuthorised, tc)
+	}
+	return groupID, memberID, callerID, nil
+}
+
+func (api *API) UpdateGroupMemberHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, memberID, callerID, resp := groupMemberParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	var req model.UpdateGroupMemberRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	membership, status, message, err := api.UpdateGroupMemberRoleHelper(r.Context(), groupID, callerID, memberID, req)
+	if err != nil || status != values.Success {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       membership,
+	}
+}
+
+func (api *API) ApproveGroupMemberHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, memberID, callerID, resp := groupMemberParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	membership, status, message, err := api.ApproveGroupMemberHelper(r.Context(), groupID, callerID, memberID)
+	if err != nil || status != values.Success {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       membership,
+	}
+}
+
+func (api *API) RemoveGroupMemberHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, memberID, callerID, resp := groupMemberParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	status, message, err := api.RemoveGroupMemberHelper(r.Context(), groupID, callerID, memberID)
+	if err != nil || status != values.Success {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 	}
 }
 
@@ -188,6 +346,124 @@ func (api *API) MarkGroupReadHandler(_ http.ResponseWriter, r *http.Request) *Se
 	}
 }
 
+func (api *API) GetGroupNotificationsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	level, err := api.GetGroupNotificationLevel(r.Context(), groupID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return respondWithError(err, "you must be a member to view notification settings", values.NotAllowed, &tc)
+	}
+	if err != nil {
+		return respondWithError(err, "failed to fetch notification settings", values.Failed, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    "Notification settings fetched",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+		Data:       model.GroupNotificationSettings{GroupID: groupID, NotificationLevel: level},
+	}
+}
+
+func (api *API) UpdateGroupNotificationsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpdateGroupNotificationsRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	ok, err := api.IsUserMemberOfGroup(r.Context(), groupID, userID)
+	if err != nil {
+		return respondWithError(err, "failed to check membership", values.Failed, &tc)
+	}
+	if !ok {
+		return respondWithError(nil, "you must be a member to change notification settings", values.NotAllowed, &tc)
+	}
+
+	if err := api.UpdateGroupNotificationLevel(r.Context(), groupID, userID, req.NotificationLevel); err != nil {
+		return respondWithError(err, "failed to update notification settings", values.Failed, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    "Notification settings updated",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+		Data:       model.GroupNotificationSettings{GroupID: groupID, NotificationLevel: req.NotificationLevel},
+	}
+}
+
+func (api *API) PinGroupMessageHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	return api.setGroupMessagePin(r, true)
+}
+
+func (api *API) UnpinGroupMessageHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	return api.setGroupMessagePin(r, false)
+}
+
+func (api *API) setGroupMessagePin(r *http.Request, pinned bool) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+	messageID, err := uuid.Parse(chi.URLParam(r, "messageID"))
+	if err != nil {
+		return respondWithError(err, "invalid message ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	if status, message, err := api.requireGroupAdmin(r.Context(), groupID, userID); status != "" {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	err = api.SetGroupMessagePinned(r.Context(), groupID, messageID, userID, pinned, maxPinnedGroupMessages)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return respondWithError(err, "message not found", values.NotFound, &tc)
+	}
+	if errors.Is(err, ErrPinLimitReached) {
+		return respondWithError(err, fmt.Sprintf("groups can have at most %d pinned messages", maxPinnedGroupMessages), values.Conflict, &tc)
+	}
+	if err != nil {
+		return respondWithError(err, "failed to update pinned message", values.Failed, &tc)
+	}
+
+	message := "Message unpinned"
+	if pinned {
+		message = "Message pinned"
+	}
+	return &ServerResponse{
+		Message:    message,
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+	}
+}
+
 func (api *API) SendGroupMessageHandler(w http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 	groupIDStr := chi.URLParam(r, "groupID")
@@ -205,29 +481,51 @@ func (api *API) SendGroupMessageHandler(w http.ResponseWriter, r *http.Request)
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return respondWithError(err, "Invalid request payload", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	req.GroupID = groupID
 	req.UserID = userID
+
+	attachment, status, msg, err := api.prepareMessageAttachment(r.Context(), userID, req.Attachment)
+	if err != nil {
+		return respondWithError(err, msg, status, &tc)
+	}
+	req.Attachment = attachment
+	if strings.TrimSpace(req.Content) == "" && req.Attachment == nil {
+		return respondWithError(nil, "message needs content or an attachment", values.BadRequestBody, &tc)
+	}
 	if req.MessageType == "" {
-		req.MessageType = "text"
+		req.MessageType = attachmentMessageType(req.Attachment)
+	}
+
+	if req.MessageType == values.GroupMessageAnnouncement {
+		role, err := api.GetGroupMemberRole(r.Context(), groupID, userID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return respondWithError(err, "failed to check membership", values.Failed, &tc)
+		}
+		if role != values.GroupRoleAdmin {
+			return respondWithError(nil, "only group admins can post announcements", values.NotAllowed, &tc)
+		}
+		count, err := api.CountRecentAnnouncements(r.Context(), groupID, time.Now().Add(-announcementWindow))
+		if err != nil {
+			return respondWithError(err, "failed to check announcement limit", values.Failed, &tc)
+		}
+		if count >= announcementLimit {
+			return respondWithError(nil, fmt.Sprintf("groups can post at most %d announcements per day", announcementLimit), values.TooManyRequests, &tc)
+		}
 	}
 
 	savedMsg, err := api.InsertGroupMessage(r.Context(), req)
 	if err != nil {
 		return respondWithError(err, "Failed to send message", values.Failed, &tc)
 	}
-	log.Printf("Group message saved: id=%s groupID=%s", savedMsg.ID, groupID)
+	api.logger().DebugContext(r.Context(), "Group message saved", "message_id", savedMsg.ID, "group_id", groupID)
+	api.expandMessageAttachments(r.Context(), []model.GroupMessage{savedMsg})
 
-	// Broadcast the message via WebSockets (wrapper so client gets type + content)
-	msgJSON, _ := json.Marshal(savedMsg)
-	wrapper := map[string]interface{}{
-		"type":     "group_chat",
-		"content":  string(msgJSON),
-		"user_id":  savedMsg.UserID.String(),
-		"group_id": groupID.String(),
-	}
-	wrappedPayload, _ := json.Marshal(wrapper)
-	api.Deps.WebSocket.BroadcastToGroup(groupID.String(), wrappedPayload)
+	// The WebSocket broadcast and member push go through the outbox.
+	api.NotifyOutbox()
 
 	return &ServerResponse{
 		Message:    "Message sent successfully",
@@ -243,6 +541,9 @@ func (api *API) CreateCommunityGroupHandler(_ http.ResponseWriter, r *http.Reque
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return respondWithError(err, "Invalid request payload", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 	userId, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
@@ -299,7 +600,8 @@ func (api *API) SearchForListOfGroupsHandler(_ http.ResponseWriter, r *http.Requ
 		radius = 50000 // 50km
 	}
 
-	groups, status, message, err := api.SearchCommunityGroupsHelper(r.Context(), userIDPtr, lat, lng, radius, filterType)
+	page := parsePageParams(q, defaultGroupSearchPageSize, maxGroupSearchPageSize)
+	groups, status, message, err := api.SearchCommunityGroupsHelper(r.Context(), userIDPtr, lat, lng, radius, filterType, page)
 	if err != nil {
 		return respondWithError(err, "unable to get groups", values.Failed, &tc)
 	}
@@ -311,34 +613,25 @@ func (api *API) SearchForListOfGroupsHandler(_ http.ResponseWriter, r *http.Requ
 	}
 }
 
-func (api *API) JoinGroupByShortCodeHandler(w http.ResponseWriter, r *http.Request) *ServerResponse {
+// JoinGroupHandler joins a group by ID or short code (the {groupID} segment takes either).
+func (api *API) JoinGroupHandler(w http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
-	shortCode := chi.URLParam(r, "short_code")
+	groupRef := chi.URLParam(r, "groupID")
 	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	// Find the group by short_code
-	group, err := api.GetCommunityGroupByShortCode(r.Context(), shortCode)
+	membership, status, message, err := api.JoinGroupHelper(r.Context(), groupRef, userID)
 	if err != nil {
-		return respondWithError(err, "Group not found", values.Failed, &tc)
-	}
-
-	// Insert membership (handle duplicate gracefully)
-	_, err = api.Deps.DB.Pool().Exec(r.Context(), `
-        INSERT INTO group_memberships (group_id, user_id, role, joined_at, updated_at)
-        VALUES ($1, $2, 'member', NOW(), NOW())
-        ON CONFLICT (group_id, user_id) DO NOTHING
-    `, group.ID, userID)
-	if err != nil {
-		return respondWithError(err, "Failed to join group", values.Failed, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Joined group successfully",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       membership,
 	}
 }
 
@@ -355,6 +648,22 @@ func (api *API) GetGroupByIDHandler(w http.ResponseWriter, r *http.Request) *Ser
 		return respondWithError(err, "Group not found", values.Failed, &tc)
 	}
 
+	// Pinned messages are group content, so only members see them.
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		isMember, err := api.IsUserMemberOfGroup(r.Context(), groupID, userID)
+		if err != nil {
+			return respondWithError(err, "failed to check membership", values.Failed, &tc)
+		}
+		if isMember {
+			group.IsMember = true
+			group.PinnedMessages, err = api.GetPinnedGroupMessages(r.Context(), groupID)
+			if err != nil {
+				return respondWithError(err, "failed to fetch pinned messages", values.Failed, &tc)
+			}
+			api.expandMessageAttachments(r.Context(), group.PinnedMessages)
+		}
+	}
+
 	return &ServerResponse{
 		Message:    "Group details retrieved",
 		Status:     values.Success,
@@ -382,44 +691,20 @@ func (api *API) CreateInvitationHandler(_ http.ResponseWriter, r *http.Request)
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	ok, err := api.IsUserMemberOfGroup(r.Context(), groupID, callerID)
-	if err != nil {
-		return respondWithError(err, "failed to check membership", values.Failed, &tc)
-	}
-	if !ok {
-		return respondWithError(nil, "you must be a member to invite others", values.NotAuthorised, &tc)
-	}
-
 	var req CreateInvitationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return respondWithError(err, "invalid request payload", values.BadRequestBody, &tc)
 	}
 
-	var invitedUserID uuid.UUID
-	if req.InvitedUserID != nil && *req.InvitedUserID != "" {
-		invitedUserID, err = uuid.Parse(*req.InvitedUserID)
-		if err != nil {
-			return respondWithError(err, "invalid invited_user_id", values.BadRequestBody, &tc)
-		}
-	} else if req.InvitedUserEmail != nil && *req.InvitedUserEmail != "" {
-		user, err := api.GetUserByEmail(r.Context(), *req.InvitedUserEmail)
-		if err != nil {
-			return respondWithError(err, "user not found for email", values.Failed, &tc)
-		}
-		invitedUserID = user.ID
-	} else {
-		return respondWithError(nil, "provide invited_user_id or invited_user_email", values.BadRequestBody, &tc)
-	}
-
-	inv, err := api.CreateInvitation(r.Context(), groupID, invitedUserID, callerID)
-	if err != nil {
-		return respondWithError(err, err.Error(), values.Failed, &tc)
+	inv, status, message, err := api.CreateInvitationHelper(r.Context(), groupID, callerID, req)
+	if err != nil || status != values.Created {
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Invitation created",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 		Data:       inv,
 	}
 }
@@ -497,15 +782,15 @@ func (api *API) AcceptInvitationHandler(_ http.ResponseWriter, r *http.Request)
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	err = api.AcceptInvitation(r.Context(), invitationID, userID)
+	status, message, err := api.AcceptInvitationHelper(r.Context(), invitationID, userID)
 	if err != nil {
-		return respondWithError(err, err.Error(), values.Failed, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Invitation accepted",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 	}
 }
 
@@ -522,14 +807,169 @@ func (api *API) DeclineInvitationHandler(_ http.ResponseWriter, r *http.Request)
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	err = api.DeclineInvitation(r.Context(), invitationID, userID)
+	status, message, err := api.DeclineInvitationHelper(r.Context(), invitationID, userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) GetGroupPresenceHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	if _, err := api.GetGroupMemberRole(r.Context(), groupID, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return respondWithError(err, "only group members can see presence", values.NotAllowed, &tc)
+		}
+		return respondWithError(err, "failed to check membership", values.Failed, &tc)
+	}
+
+	members, err := api.GetGroupMembers(r.Context(), groupID)
 	if err != nil {
-		return respondWithError(err, err.Error(), values.Failed, &tc)
+		return respondWithError(err, "failed to load group members", values.Failed, &tc)
+	}
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID.String()
+	}
+	online := api.Deps.WebSocket.OnlineUsers(r.Context(), ids)
+
+	var onlineIDs []uuid.UUID
+	for _, m := range members {
+		if online[m.UserID.String()] {
+			onlineIDs = append(onlineIDs, m.UserID)
+		}
+	}
+	navigating := map[uuid.UUID]bool{}
+	if len(onlineIDs) > 0 {
+		if navigating, err = api.GetNavigatingUserIDs(r.Context(), onlineIDs); err != nil {
+			return respondWithError(err, "failed to load navigation state", values.Failed, &tc)
+		}
+	}
+
+	presence := model.GroupPresence{GroupID: groupID, Members: make([]model.MemberPresence, len(members))}
+	for i, m := range members {
+		isOnline := online[m.UserID.String()]
+		if isOnline {
+			presence.Online++
+		}
+		presence.Members[i] = model.MemberPresence{
+			UserID:     m.UserID,
+			Username:   m.Username,
+			Online:     isOnline,
+			Navigating: navigating[m.UserID],
+		}
 	}
 
 	return &ServerResponse{
-		Message:    "Invitation declined",
+		Message:    "Group presence fetched",
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
+		Data:       presence,
+	}
+}
+
+func (api *API) UpdateLocationSharingHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpdateLocationSharingRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	share, status, message, err := api.UpdateLocationSharingHelper(r.Context(), groupID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       share,
+	}
+}
+
+func (api *API) ShareLocationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.SharedLocationRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	share, status, message, err := api.ShareLocationHelper(r.Context(), groupID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       share,
+	}
+}
+
+func (api *API) ListGroupLocationsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	shares, status, message, err := api.ListGroupLocationSharesHelper(r.Context(), groupID, userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       shares,
 	}
 }
@@ -2,11 +2,14 @@ package rest
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
@@ -37,17 +40,227 @@ func (api *API) SearchCommunityGroupsHelper(
 	lng float64,
 	radius float64,
 	filterType string,
-) ([]model.CommunityGroup, string, string, error) {
+	page pageParams,
+) (model.Page[model.CommunityGroup], string, string, error) {
 
-	groups, err := api.SearchCommunityGroup(ctx, currentUserID, lat, lng, radius, filterType)
+	groups, total, err := api.SearchCommunityGroup(ctx, currentUserID, lat, lng, radius, filterType, page.PageSize, page.offset())
 	if err != nil {
-		return []model.CommunityGroup{}, values.Error, "Failed to get groups", err
+		return model.Page[model.CommunityGroup]{}, values.Error, "Failed to get groups", err
 	}
 
-	return groups, values.Success, "Groups returned successfully", nil
+	return offsetPage(groups, page, total), values.Success, "Groups returned successfully", nil
 }
 
 func (api *API) GetCommunityGroupByIDHelper(ctx context.Context, groupID uuid.UUID) (string, string, error) {
 
 	return "", "", nil
 }
+
+const (
+	defaultGroupSearchPageSize = 20
+	maxGroupSearchPageSize     = 100
+
+	defaultGroupMessagesPageSize = 50
+	maxGroupMessagesPageSize     = 100
+)
+
+// GetGroupMessagesHelper returns a page of the group's message history, newest first.
+// Pages are keyed on (created_at, id), so messages sent while scrolling back don't shift
+// older pages.
+func (api *API) GetGroupMessagesHelper(ctx context.Context, groupID uuid.UUID, page pageParams) (model.Page[model.GroupMessage], string, string, error) {
+	var beforeAt *time.Time
+	var beforeID uuid.UUID
+	if page.Cursor != "" {
+		at, id, err := decodeKeysetCursor(page.Cursor)
+		if err == nil {
+			beforeID, err = uuid.Parse(id)
+		}
+		if err != nil {
+			return model.Page[model.GroupMessage]{}, values.BadRequestBody, "invalid cursor", err
+		}
+		beforeAt = &at
+	}
+
+	total, err := api.CountGroupMessages(ctx, groupID)
+	if err != nil {
+		return model.Page[model.GroupMessage]{}, values.Failed, "failed to get group messages", err
+	}
+	messages, err := api.GetGroupMessages(ctx, groupID, page.PageSize+1, beforeAt, beforeID)
+	if err != nil {
+		return model.Page[model.GroupMessage]{}, values.Failed, "failed to get group messages", err
+	}
+	return keysetPage(messages, page, total, func(last model.GroupMessage) string {
+		return encodeKeysetCursor(last.CreatedAt, last.ID.String())
+	}), values.Success, "Messages retrieved", nil
+}
+
+const (
+	defaultGroupMembersPageSize = 50
+	maxGroupMembersPageSize     = 200
+)
+
+// JoinGroupHelper joins the group identified by groupRef, its ID or short code. Public
+// groups and users holding an invitation are let straight in; anyone else asking to join
+// a private group waits as pending until an admin approves them.
+func (api *API) JoinGroupHelper(ctx context.Context, groupRef string, userID uuid.UUID) (model.GroupMembership, string, string, error) {
+	var group model.CommunityGroup
+	var err error
+	if groupID, parseErr := uuid.Parse(groupRef); parseErr == nil {
+		group, err = api.GetCommunityGroupByID(ctx, groupID)
+	} else {
+		group, err = api.GetCommunityGroupByShortCode(ctx, groupRef)
+	}
+	if err != nil {
+		return model.GroupMembership{}, errorStatus(dbError(err), values.Error), "Group not found", err
+	}
+
+	status := values.GroupMemberActive
+	if group.Visibility == values.GroupVisibilityPrivate {
+		invited, err := api.HasPendingGroupInvitation(ctx, group.ID, userID)
+		if err != nil {
+			return model.GroupMembership{}, values.Error, "Failed to join group", err
+		}
+		if !invited {
+			status = values.GroupMemberPending
+		}
+	}
+
+	membership, err := api.JoinCommunityGroup(ctx, group.ID, userID, status)
+	switch {
+	case errors.Is(err, ErrAlreadyGroupMember):
+		return model.GroupMembership{}, values.Conflict, "You are already a member of this group", err
+	case errors.Is(err, ErrJoinRequestPending):
+		return model.GroupMembership{}, values.Conflict, "Your request to join is waiting for an admin", err
+	case err != nil:
+		return model.GroupMembership{}, errorStatus(err, values.Error), "Failed to join group", err
+	}
+	if status == values.GroupMemberPending {
+		return membership, values.Created, "Request to join sent; an admin must approve it", nil
+	}
+	return membership, values.Created, "Joined group successfully", nil
+}
+
+// LeaveGroupHelper removes the user from the group, or withdraws their join request.
+func (api *API) LeaveGroupHelper(ctx context.Context, groupID, userID uuid.UUID) (string, string, error) {
+	err := api.RemoveGroupMember(ctx, groupID, userID)
+	switch {
+	case errors.Is(err, ErrLastGroupAdmin):
+		return values.Conflict, "Make another member an admin before leaving", err
+	case errors.Is(err, ErrGroupMemberNotFound):
+		return values.NotFound, "You are not a member of this group", err
+	case err != nil:
+		return errorStatus(err, values.Error), "Failed to leave group", err
+	}
+	return values.Success, "Successfully left the group", nil
+}
+
+// ListGroupMembersHelper returns a page of the group's members to one of them. Pending
+// join requests (status "pending") are listed to admins only.
+func (api *API) ListGroupMembersHelper(ctx context.Context, groupID, callerID uuid.UUID, status string, page, pageSize int) (model.GroupMemberList, string, string, error) {
+	if status == "" {
+		status = values.GroupMemberActive
+	}
+	if status != values.GroupMemberActive && status != values.GroupMemberPending {
+		return model.GroupMemberList{}, values.BadRequestBody, "status must be active or pending", nil
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultGroupMembersPageSize
+	}
+	pageSize = min(pageSize, maxGroupMembersPageSize)
+
+	if !callerCan(ctx, PermModerateGroups) {
+		role, err := api.GetGroupMemberRole(ctx, groupID, callerID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return model.GroupMemberList{}, values.NotAllowed, "Only group members can see the member list", err
+		}
+		if err != nil {
+			return model.GroupMemberList{}, values.Error, "Failed to check membership", err
+		}
+		if status == values.GroupMemberPending && role != values.GroupRoleAdmin {
+			return model.GroupMemberList{}, values.NotAllowed, "Only group admins can see join requests", nil
+		}
+	}
+
+	members, total, err := api.ListGroupMembers(ctx, groupID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return model.GroupMemberList{}, values.Error, "Failed to get group members", err
+	}
+	list := model.GroupMemberList{Members: members, Total: total, Page: page, PageSize: pageSize}
+	return list, values.Success, "Group members retrieved", nil
+}
+
+// requireGroupAdmin checks that the caller is an active admin of the group, or a site
+// moderator; it returns an empty status when they are.
+func (api *API) requireGroupAdmin(ctx context.Context, groupID, callerID uuid.UUID) (string, string, error) {
+	if callerCan(ctx, PermModerateGroups) {
+		return "", "", nil
+	}
+	role, err := api.GetGroupMemberRole(ctx, groupID, callerID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && role != values.GroupRoleAdmin) {
+		return values.NotAllowed, "Only group admins can manage the group", err
+	}
+	if err != nil {
+		return values.Error, "Failed to check membership", err
+	}
+	return "", "", nil
+}
+
+// ApproveGroupMemberHelper lets an admin accept a pending request to join the group.
+func (api *API) ApproveGroupMemberHelper(ctx context.Context, groupID, callerID, userID uuid.UUID) (model.GroupMembership, string, string, error) {
+	if status, message, err := api.requireGroupAdmin(ctx, groupID, callerID); status != "" {
+		return model.GroupMembership{}, status, message, err
+	}
+	membership, err := api.ApproveGroupMember(ctx, groupID, userID)
+	if err != nil {
+		return model.GroupMembership{}, errorStatus(err, values.Error), "No pending join request from this user", err
+	}
+	return membership, values.Success, "Join request approved", nil
+}
+
+// UpdateGroupMemberRoleHelper lets an admin promote or demote an active member, including
+// themselves as long as the group keeps an admin.
+func (api *API) UpdateGroupMemberRoleHelper(ctx context.Context, groupID, callerID, userID uuid.UUID, req model.UpdateGroupMemberRequest) (model.GroupMembership, string, string, error) {
+	if status, message, err := api.requireGroupAdmin(ctx, groupID, callerID); status != "" {
+		return model.GroupMembership{}, status, message, err
+	}
+	membership, err := api.UpdateGroupMemberRole(ctx, groupID, userID, req.Role)
+	switch {
+	case errors.Is(err, ErrLastGroupAdmin):
+		return model.GroupMembership{}, values.Conflict, "The group must keep at least one admin", err
+	case errors.Is(err, ErrGroupMemberNotFound):
+		return model.GroupMembership{}, values.NotFound, "User is not a member of this group", err
+	case err != nil:
+		return model.GroupMembership{}, errorStatus(err, values.Error), "Failed to update member role", err
+	}
+	return membership, values.Success, "Member role updated", nil
+}
+
+// RemoveGroupMemberHelper lets an admin kick a member or reject a join request. Admins
+// can't be removed this way; demote them first, and use leave to remove yourself.
+func (api *API) RemoveGroupMemberHelper(ctx context.Context, groupID, callerID, userID uuid.UUID) (string, string, error) {
+	if userID == callerID {
+		return values.BadRequestBody, "Use leave to remove yourself from the group", nil
+	}
+	if status, message, err := api.requireGroupAdmin(ctx, groupID, callerID); status != "" {
+		return status, message, err
+	}
+
+	target, err := api.GetGroupMembership(ctx, groupID, userID)
+	if err != nil {
+		return errorStatus(err, values.Error), "User is not a member of this group", err
+	}
+	if target.Role == values.GroupRoleAdmin {
+		return values.NotAllowed, "Demote an admin before removing them", nil
+	}
+
+	if err := api.RemoveGroupMember(ctx, groupID, userID); err != nil {
+		return errorStatus(err, values.Error), "Failed to remove member", err
+	}
+	if target.Status == values.GroupMemberPending {
+		return values.Success, "Join request rejected", nil
+	}
+	return values.Success, "Member removed from the group", nil
+}
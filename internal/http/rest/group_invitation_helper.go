@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/url"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// groupInviteLinkBase is the app deep link invitation emails open; the app shows the
+// group for the short code and the user's pending invitation to it.
+const groupInviteLinkBase = "wazekibris://groups/join"
+
+// groupInviteAppLink is typed as a trusted URL so html/template keeps the custom scheme in hrefs.
+func groupInviteAppLink(shortCode string) template.URL {
+	return template.URL(groupInviteLinkBase + "?code=" + url.QueryEscape(shortCode))
+}
+
+// CreateInvitationHelper lets a member invite another user, by ID or email, and emails
+// the invitee a link to the group.
+func (api *API) CreateInvitationHelper(ctx context.Context, groupID, callerID uuid.UUID, req CreateInvitationRequest) (model.GroupInvitation, string, string, error) {
+	ok, err := api.IsUserMemberOfGroup(ctx, groupID, callerID)
+	if err != nil {
+		return model.GroupInvitation{}, values.Error, "Failed to check membership", err
+	}
+	if !ok {
+		return model.GroupInvitation{}, values.NotAllowed, "You must be a member to invite others", nil
+	}
+
+	group, err := api.GetCommunityGroupByID(ctx, groupID)
+	if err != nil {
+		return model.GroupInvitation{}, errorStatus(dbError(err), values.Error), "Group not found", err
+	}
+
+	var invitedUserID uuid.UUID
+	switch {
+	case req.InvitedUserID != nil && *req.InvitedUserID != "":
+		invitedUserID, err = uuid.Parse(*req.InvitedUserID)
+		if err != nil {
+			return model.GroupInvitation{}, values.BadRequestBody, "Invalid invited_user_id", err
+		}
+	case req.InvitedUserEmail != nil && *req.InvitedUserEmail != "":
+		user, err := api.GetUserByEmail(ctx, *req.InvitedUserEmail)
+		if err != nil {
+			return model.GroupInvitation{}, errorStatus(err, values.Error), "User not found for email", err
+		}
+		invitedUserID = user.ID
+	default:
+		return model.GroupInvitation{}, values.BadRequestBody, "Provide invited_user_id or invited_user_email", nil
+	}
+
+	inv, err := api.CreateInvitation(ctx, groupID, invitedUserID, callerID)
+	switch {
+	case errors.Is(err, ErrInvitedUserNotFound):
+		return model.GroupInvitation{}, values.NotFound, "Invited user not found", err
+	case errors.Is(err, ErrAlreadyGroupMember):
+		return model.GroupInvitation{}, values.Conflict, "User is already a member or has asked to join", err
+	case errors.Is(err, ErrInvitationPending):
+		return model.GroupInvitation{}, values.Conflict, "User already has a pending invitation", err
+	case err != nil:
+		return model.GroupInvitation{}, errorStatus(err, values.Error), "Failed to create invitation", err
+	}
+	inv.GroupName = &group.Name
+	inv.GroupShortCode = &group.ShortCode
+
+	api.sendGroupInvitationEmail(ctx, group, callerID, invitedUserID)
+	return inv, values.Created, "Invitation created", nil
+}
+
+// sendGroupInvitationEmail emails the invitee in the background. The invitation stands
+// whether or not the email goes out, so failures are only logged.
+func (api *API) sendGroupInvitationEmail(ctx context.Context, group model.CommunityGroup, inviterID, invitedUserID uuid.UUID) {
+	invitee, err := api.GetUserByID(ctx, invitedUserID.String())
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Failed to load invitee for invitation email", "err", err)
+		return
+	}
+	if invitee.Email == "" {
+		return
+	}
+	data := map[string]interface{}{
+		"GroupName": group.Name,
+		"ShortCode": group.ShortCode,
+		"AppLink":   groupInviteAppLink(group.ShortCode),
+	}
+	if inviter, err := api.GetUserByID(ctx, inviterID.String()); err == nil && inviter.Username != nil {
+		data["InviterName"] = *inviter.Username
+	}
+
+	go func() {
+		if err := api.Mailer.Send(invitee.Email, data, "groupInvitation.tmpl"); err != nil {
+			api.logger().ErrorContext(ctx, "Failed to send group invitation email", "err", err)
+		}
+	}()
+}
+
+// AcceptInvitationHelper accepts the user's invitation, making them an active member.
+func (api *API) AcceptInvitationHelper(ctx context.Context, invitationID, userID uuid.UUID) (string, string, error) {
+	if err := api.AcceptInvitation(ctx, invitationID, userID); err != nil {
+		return invitationErrorStatus(err), invitationErrorMessage(err, "Failed to accept invitation"), err
+	}
+	return values.Success, "Invitation accepted", nil
+}
+
+// DeclineInvitationHelper declines the user's invitation.
+func (api *API) DeclineInvitationHelper(ctx context.Context, invitationID, userID uuid.UUID) (string, string, error) {
+	if err := api.DeclineInvitation(ctx, invitationID, userID); err != nil {
+		return invitationErrorStatus(err), invitationErrorMessage(err, "Failed to decline invitation"), err
+	}
+	return values.Success, "Invitation declined", nil
+}
+
+func invitationErrorStatus(err error) string {
+	if errors.Is(err, ErrInvitationNotYours) {
+		return values.NotAllowed
+	}
+	return errorStatus(err, values.Error)
+}
+
+func invitationErrorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, ErrInvitationNotFound):
+		return "Invitation not found"
+	case errors.Is(err, ErrInvitationNotYours):
+		return "This invitation is not for you"
+	case errors.Is(err, ErrInvitationAnswered):
+		return "Invitation is no longer pending"
+	}
+	return fallback
+}
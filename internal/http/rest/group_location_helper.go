@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultLocationShareDuration = time.Hour
+	// locationShareMinETASpeedMS is the slowest speed an ETA is estimated from; below it
+	// (stopped at lights, in a jam) the previous ETA is kept.
+	locationShareMinETASpeedMS = 1.0
+	// A session's average speed only replaces the fix's own once it has run this long
+	// and this far, so the first minute of a drive doesn't skew the ETA.
+	locationShareMinAverageAge = time.Minute
+	locationShareMinAverageM   = 200.0
+)
+
+// requireGroupMember fails unless the caller is an active member of the group.
+func (api *API) requireGroupMember(ctx context.Context, groupID, userID uuid.UUID, msg string) (string, string, error) {
+	if _, err := api.GetGroupMemberRole(ctx, groupID, userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return values.NotAllowed, msg, err
+		}
+		return values.Error, "Failed to check membership", err
+	}
+	return values.Success, "", nil
+}
+
+// UpdateLocationSharingHelper starts, extends or stops the caller's live location share
+// in a group. Stopping tells the group so members drop the marker right away.
+func (api *API) UpdateLocationSharingHelper(ctx context.Context, groupID, userID uuid.UUID, req model.UpdateLocationSharingRequest) (model.GroupLocationShare, string, string, error) {
+	if !req.Enabled {
+		stopped, err := api.StopGroupLocationShareRepo(ctx, groupID, userID)
+		if err != nil {
+			return model.GroupLocationShare{}, values.Error, "Failed to stop location sharing", err
+		}
+		share := model.GroupLocationShare{GroupID: groupID, UserID: userID, UpdatedAt: time.Now()}
+		if stopped {
+			api.publishSharedLocation(ctx, share)
+		}
+		return share, values.Success, "Location sharing stopped", nil
+	}
+
+	if status, msg, err := api.requireGroupMember(ctx, groupID, userID, "Only group members can share their location"); err != nil {
+		return model.GroupLocationShare{}, status, msg, err
+	}
+	if req.SessionID != nil {
+		session, err := api.GetNavigationSessionRepo(ctx, *req.SessionID, userID)
+		if err != nil {
+			if errors.Is(err, ErrNavigationSessionNotFound) {
+				return model.GroupLocationShare{}, values.NotFound, "Navigation session not found", err
+			}
+			return model.GroupLocationShare{}, values.Error, "Failed to load navigation session", err
+		}
+		if session.Status != "active" {
+			return model.GroupLocationShare{}, values.BadRequestBody, "Navigation session is not active", ErrNavigationSessionInactive
+		}
+	}
+
+	duration := defaultLocationShareDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	share, err := api.StartGroupLocationShareRepo(ctx, groupID, userID, req.SessionID, time.Now().Add(duration))
+	if err != nil {
+		return model.GroupLocationShare{}, values.Error, "Failed to start location sharing", err
+	}
+	api.publishSharedLocation(ctx, share)
+	return share, values.Success, "Location sharing started", nil
+}
+
+// ShareLocationHelper records a position on the caller's share and relays it to the
+// group. The ETA comes from the request, or else from the linked navigation session.
+func (api *API) ShareLocationHelper(ctx context.Context, groupID, userID uuid.UUID, req model.SharedLocationRequest) (model.GroupLocationShare, string, string, error) {
+	current, err := api.GetGroupLocationShareRepo(ctx, groupID, userID)
+	if err != nil {
+		if errors.Is(err, ErrLocationShareNotFound) {
+			return model.GroupLocationShare{}, values.NotFound, "Location sharing is not on for this group", err
+		}
+		return model.GroupLocationShare{}, values.Error, "Failed to load location share", err
+	}
+
+	var session *model.NavigationSession
+	if current.SessionID != nil && req.ETASeconds == nil {
+		s, err := api.GetNavigationSessionRepo(ctx, *current.SessionID, userID)
+		if err != nil && !errors.Is(err, ErrNavigationSessionNotFound) {
+			api.logger().WarnContext(ctx, "Failed to load navigation session for shared ETA", "session_id", *current.SessionID, "err", err)
+		}
+		if err == nil {
+			session = &s
+		}
+	}
+
+	share, err := api.RecordSharedLocationRepo(ctx, groupID, userID, req, sharedETA(time.Now(), req, session))
+	if err != nil {
+		if errors.Is(err, ErrLocationShareNotFound) {
+			return model.GroupLocationShare{}, values.NotFound, "Location sharing is not on for this group", err
+		}
+		return model.GroupLocationShare{}, values.Error, "Failed to record location", err
+	}
+	api.publishSharedLocation(ctx, share)
+	return share, values.Success, "Location shared", nil
+}
+
+// sharedETA estimates when a sharing member arrives. An explicit ETASeconds wins; else
+// the remaining route distance of an active session is divided by the session's average
+// speed, or by the fix's own speed early in the drive. Nil means keep the previous ETA.
+func sharedETA(now time.Time, req model.SharedLocationRequest, session *model.NavigationSession) *time.Time {
+	if req.ETASeconds != nil {
+		eta := now.Add(time.Duration(*req.ETASeconds) * time.Second)
+		return &eta
+	}
+	if session == nil || session.Status != "active" {
+		return nil
+	}
+	if session.RemainingM <= 0 {
+		return &now
+	}
+	var speed float64
+	if req.Speed != nil {
+		speed = *req.Speed
+	}
+	if elapsed := now.Sub(session.StartedAt); elapsed >= locationShareMinAverageAge && session.ProgressM >= locationShareMinAverageM {
+		speed = session.ProgressM / elapsed.Seconds()
+	}
+	if speed < locationShareMinETASpeedMS {
+		return nil
+	}
+	eta := now.Add(time.Duration(session.RemainingM / speed * float64(time.Second)))
+	return &eta
+}
+
+func (api *API) ListGroupLocationSharesHelper(ctx context.Context, groupID, userID uuid.UUID) ([]model.GroupLocationShare, string, string, error) {
+	if status, msg, err := api.requireGroupMember(ctx, groupID, userID, "Only group members can see shared locations"); err != nil {
+		return nil, status, msg, err
+	}
+	shares, err := api.ListGroupLocationSharesRepo(ctx, groupID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch shared locations", err
+	}
+	if shares == nil {
+		shares = []model.GroupLocationShare{}
+	}
+	return shares, values.Success, "Shared locations fetched", nil
+}
+
+// publishSharedLocation relays a share to the group's WebSocket topic. Best effort:
+// members who miss an update catch up from GET /groups/{groupID}/locations.
+func (api *API) publishSharedLocation(ctx context.Context, share model.GroupLocationShare) {
+	b, err := json.Marshal(share)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Encoding shared location failed", "group_id", share.GroupID, "err", err)
+		return
+	}
+	msg := websockets.Message{
+		Type:    websockets.MsgTypeGroupLocationUpdate,
+		UserID:  share.UserID.String(),
+		Content: string(b),
+		GroupID: share.GroupID.String(),
+	}
+	if share.Latitude != nil && share.Longitude != nil {
+		msg.Latitude, msg.Longitude = *share.Latitude, *share.Longitude
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Encoding shared location failed", "group_id", share.GroupID, "err", err)
+		return
+	}
+	api.Deps.WebSocket.BroadcastToGroup(share.GroupID.String(), raw)
+}
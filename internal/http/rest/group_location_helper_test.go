@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestSharedETA(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	speed := func(v float64) *float64 { return &v }
+	etaSeconds := 300
+	session := func(started time.Duration, progress, remaining float64) *model.NavigationSession {
+		return &model.NavigationSession{Status: "active", StartedAt: now.Add(-started), ProgressM: progress, RemainingM: remaining}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		req     model.SharedLocationRequest
+		session *model.NavigationSession
+		want    time.Duration // -1 for nil
+	}{
+		{"explicit eta wins", model.SharedLocationRequest{ETASeconds: &etaSeconds}, session(10*time.Minute, 6000, 1000), 5 * time.Minute},
+		{"no session", model.SharedLocationRequest{Speed: speed(10)}, nil, -1},
+		{"finished session", model.SharedLocationRequest{Speed: speed(10)}, &model.NavigationSession{Status: "finished", RemainingM: 1000}, -1},
+		{"early in the drive uses fix speed", model.SharedLocationRequest{Speed: speed(10)}, session(30*time.Second, 300, 3000), 5 * time.Minute},
+		{"average speed later on", model.SharedLocationRequest{Speed: speed(30)}, session(10*time.Minute, 6000, 6000), 10 * time.Minute},
+		{"stopped keeps previous", model.SharedLocationRequest{Speed: speed(0)}, session(30*time.Second, 0, 3000), -1},
+		{"arrived", model.SharedLocationRequest{}, session(10*time.Minute, 6000, 0), 0},
+	} {
+		got := sharedETA(now, tc.req, tc.session)
+		switch {
+		case tc.want < 0 && got != nil:
+			t.Errorf("%s: got %v, want nil", tc.name, *got)
+		case tc.want >= 0 && (got == nil || !got.Equal(now.Add(tc.want))):
+			t.Errorf("%s: got %v, want %v", tc.name, got, now.Add(tc.want))
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrLocationShareNotFound = fmt.Errorf("location share %w", ErrNotFound)
+
+// groupLocationShareColumns reads a share aliased s, joined to its user u and
+// navigation session ns.
+const groupLocationShareColumns = `
+	s.group_id, s.user_id, u.username, s.session_id,
+	ST_Y(s.position), ST_X(s.position), s.heading, s.speed, s.eta,
+	ns.destination_name, s.expires_at, s.position_at, s.updated_at
+`
+
+const groupLocationShareJoins = `
+	LEFT JOIN users u ON u.id = s.user_id
+	LEFT JOIN navigation_sessions ns ON ns.id = s.session_id
+`
+
+func scanGroupLocationShare(row pgx.Row) (model.GroupLocationShare, error) {
+	var s model.GroupLocationShare
+	err := row.Scan(
+		&s.GroupID, &s.UserID, &s.Username, &s.SessionID,
+		&s.Latitude, &s.Longitude, &s.Heading, &s.Speed, &s.ETA,
+		&s.DestinationName, &s.ExpiresAt, &s.PositionAt, &s.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.GroupLocationShare{}, ErrLocationShareNotFound
+	}
+	s.Sharing = err == nil
+	return s, err
+}
+
+// StartGroupLocationShareRepo starts or extends the user's share in the group. The last
+// known position is kept when an existing share is extended, and dropped when sharing
+// restarts after it had expired.
+func (api *API) StartGroupLocationShareRepo(ctx context.Context, groupID, userID uuid.UUID, sessionID *uuid.UUID, expiresAt time.Time) (model.GroupLocationShare, error) {
+	query := `
+		WITH s AS (
+			INSERT INTO group_location_shares (group_id, user_id, session_id, expires_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (group_id, user_id) DO UPDATE SET
+				session_id = EXCLUDED.session_id,
+				position = CASE WHEN group_location_shares.expires_at > NOW() THEN group_location_shares.position END,
+				heading = CASE WHEN group_location_shares.expires_at > NOW() THEN group_location_shares.heading END,
+				speed = CASE WHEN group_location_shares.expires_at > NOW() THEN group_location_shares.speed END,
+				eta = CASE WHEN group_location_shares.expires_at > NOW() THEN group_location_shares.eta END,
+				position_at = CASE WHEN group_location_shares.expires_at > NOW() THEN group_location_shares.position_at END,
+				expires_at = EXCLUDED.expires_at,
+				updated_at = NOW()
+			RETURNING *
+		)
+		SELECT ` + groupLocationShareColumns + ` FROM s ` + groupLocationShareJoins
+	return scanGroupLocationShare(api.DB.QueryRow(ctx, query, groupID, userID, sessionID, expiresAt))
+}
+
+// StopGroupLocationShareRepo removes the user's share in the group, reporting whether
+// there was one.
+func (api *API) StopGroupLocationShareRepo(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	tag, err := api.Deps.DB.Pool().Exec(ctx, `
+		DELETE FROM group_location_shares WHERE group_id = $1 AND user_id = $2
+	`, groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetGroupLocationShareRepo returns the user's unexpired share in the group.
+func (api *API) GetGroupLocationShareRepo(ctx context.Context, groupID, userID uuid.UUID) (model.GroupLocationShare, error) {
+	query := `
+		SELECT ` + groupLocationShareColumns + `
+		FROM group_location_shares s ` + groupLocationShareJoins + `
+		WHERE s.group_id = $1 AND s.user_id = $2 AND s.expires_at > NOW()
+	`
+	return scanGroupLocationShare(api.DB.QueryRow(ctx, query, groupID, userID))
+}
+
+// RecordSharedLocationRepo stores a position on the user's unexpired share. A nil eta
+// keeps the previous one, so a fix without an estimate doesn't blank the ETA.
+func (api *API) RecordSharedLocationRepo(ctx context.Context, groupID, userID uuid.UUID, req model.SharedLocationRequest, eta *time.Time) (model.GroupLocationShare, error) {
+	query := `
+		WITH s AS (
+			UPDATE group_location_shares SET
+				position = ST_SetSRID(ST_MakePoint($3, $4), 4326),
+				heading = $5,
+				speed = $6,
+				eta = COALESCE($7, eta),
+				position_at = NOW(),
+				updated_at = NOW()
+			WHERE group_id = $1 AND user_id = $2 AND expires_at > NOW()
+			RETURNING *
+		)
+		SELECT ` + groupLocationShareColumns + ` FROM s ` + groupLocationShareJoins
+	return scanGroupLocationShare(api.DB.QueryRow(ctx, query, groupID, userID,
+		req.Longitude, req.Latitude, req.Heading, req.Speed, eta))
+}
+
+// ListGroupLocationSharesRepo returns the unexpired shares of the group's active members,
+// most recently updated first.
+func (api *API) ListGroupLocationSharesRepo(ctx context.Context, groupID uuid.UUID) ([]model.GroupLocationShare, error) {
+	query := `
+		SELECT ` + groupLocationShareColumns + `
+		FROM group_location_shares s ` + groupLocationShareJoins + `
+		JOIN group_memberships gm ON gm.group_id = s.group_id AND gm.user_id = s.user_id AND gm.status = 'active'
+		WHERE s.group_id = $1 AND s.expires_at > NOW()
+		ORDER BY s.updated_at DESC
+	`
+	rows, err := api.DB.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("querying location shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []model.GroupLocationShare
+	for rows.Next() {
+		s, err := scanGroupLocationShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning location share: %w", err)
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
@@ -2,16 +2,44 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+var (
+	ErrPinLimitReached     = errors.New("pinned message limit reached")
+	ErrGroupMemberNotFound = fmt.Errorf("group member %w", ErrNotFound)
+	ErrLastGroupAdmin      = fmt.Errorf("group must keep an admin: %w", ErrConflict)
+	ErrAlreadyGroupMember  = fmt.Errorf("already a member: %w", ErrConflict)
+	ErrJoinRequestPending  = fmt.Errorf("join request already pending: %w", ErrConflict)
+	ErrJoinRequestNotFound = fmt.Errorf("join request %w", ErrNotFound)
+	ErrInvitationNotFound  = fmt.Errorf("invitation %w", ErrNotFound)
+	ErrInvitedUserNotFound = fmt.Errorf("invited user %w", ErrNotFound)
+	ErrInvitationPending   = fmt.Errorf("user already has a pending invitation: %w", ErrConflict)
+	ErrInvitationAnswered  = fmt.Errorf("invitation is no longer pending: %w", ErrConflict)
+	ErrInvitationNotYours  = errors.New("invitation is not for this user")
+)
+
+const groupMembershipColumns = `
+        gm.id, gm.group_id, gm.user_id, gm.role, gm.status, u.username,
+        gm.notification_level, gm.joined_at, gm.updated_at`
+
+func scanGroupMembership(row pgx.Row) (model.GroupMembership, error) {
+	var m model.GroupMembership
+	err := row.Scan(
+		&m.ID, &m.GroupID, &m.UserID, &m.Role, &m.Status, &m.Username,
+		&m.NotificationLevel, &m.JoinedAt, &m.UpdatedAt,
+	)
+	return m, err
+}
+
 func (api *API) CreateCommunityGroup(ctx context.Context, group model.CommunityGroup) (model.CommunityGroup, error) {
 	var createdGroup model.CommunityGroup
 
@@ -87,11 +115,15 @@ func (api *API) CreateCommunityGroup(ctx context.Context, group model.CommunityG
             INSERT INTO group_memberships (group_id, user_id, role, joined_at, updated_at)
             VALUES ($1, $2, 'admin', NOW(), NOW())
         `, createdGroup.ID, createdGroup.CreatorID)
+		if err != nil {
+			return err
+		}
+		createdGroup.MemberCount, err = refreshGroupMemberCount(ctx, tx, createdGroup.ID)
 		return err
 	})
 
 	if err != nil {
-		log.Println("error creating new group chat or adding creator to membership", err)
+		api.logger().ErrorContext(ctx, "Failed to create group or add creator as member", "err", err)
 		return model.CommunityGroup{}, err
 	}
 
@@ -102,7 +134,7 @@ func (api *API) GetCommunityGroupByID(ctx context.Context, groupID uuid.UUID) (m
 	query := `
         SELECT cg.id, cg.name, cg.description, cg.group_type, cg.destination_place_id, cg.destination_name,
                ST_AsText(cg.destination_location), cg.visibility, cg.creator_id, cg.icon_url,
-               (SELECT COUNT(*)::int FROM group_memberships gm WHERE gm.group_id = cg.id) AS member_count,
+               cg.member_count,
                false AS is_member,
                0 AS unread_count,
                NULL::timestamptz AS last_read_at,
@@ -155,7 +187,8 @@ func (api *API) SearchCommunityGroup(
 	lng float64,
 	radius float64,
 	filterType string,
-) ([]model.CommunityGroup, error) {
+	limit, offset int,
+) ([]model.CommunityGroup, int, error) {
 	userID := uuid.Nil
 	if currentUserID != nil {
 		userID = *currentUserID
@@ -190,7 +223,7 @@ func (api *API) SearchCommunityGroup(
 			whereClause += fmt.Sprintf(
 				` AND (` +
 					`cg.creator_id = $1 OR ` +
-					`EXISTS(SELECT 1 FROM group_memberships gm WHERE gm.group_id = cg.id AND gm.user_id = $1)` +
+					`EXISTS(SELECT 1 FROM group_memberships gm WHERE gm.group_id = cg.id AND gm.user_id = $1 AND gm.status = 'active')` +
 					`)`,
 			)
 		}
@@ -198,11 +231,19 @@ func (api *API) SearchCommunityGroup(
 		// default behaviour
 	}
 
+	var total int
+	// Only some filters use $1, the viewer; mentioning it keeps the arguments valid for
+	// the count too. It is never NULL (uuid.Nil when signed out).
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM community_groups cg WHERE $1::uuid IS NOT NULL AND %s`, whereClause)
+	if err := api.Deps.DB.Pool().QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting community groups: %w", err)
+	}
+
 	query := fmt.Sprintf(`
         SELECT cg.id, cg.name, cg.description, cg.group_type, cg.destination_place_id, cg.destination_name,
                ST_AsText(cg.destination_location), cg.visibility, cg.creator_id, cg.icon_url,
-               (SELECT COUNT(*)::int FROM group_memberships gm WHERE gm.group_id = cg.id) AS member_count,
-               EXISTS(SELECT 1 FROM group_memberships gm2 WHERE gm2.group_id = cg.id AND gm2.user_id = $1) AS is_member,
+               cg.member_count,
+               EXISTS(SELECT 1 FROM group_memberships gm2 WHERE gm2.group_id = cg.id AND gm2.user_id = $1 AND gm2.status = 'active') AS is_member,
                CASE
                  WHEN $1 = '00000000-0000-0000-0000-000000000000'::uuid THEN 0
                  ELSE (
@@ -221,11 +262,12 @@ func (api *API) SearchCommunityGroup(
                cg.last_message_at, cg.is_deleted, cg.created_at, cg.updated_at, cg.short_code
         FROM community_groups cg
         WHERE %s
-        ORDER BY %s
-    `, whereClause, orderByClause)
-	rows, err := api.Deps.DB.Pool().Query(ctx, query, args...)
+        ORDER BY %s, cg.id
+        LIMIT $%d OFFSET $%d
+    `, whereClause, orderByClause, nextArgIndex, nextArgIndex+1)
+	rows, err := api.Deps.DB.Pool().Query(ctx, query, append(args, limit, offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("querying community groups: %w", err)
+		return nil, 0, fmt.Errorf("querying community groups: %w", err)
 	}
 	defer rows.Close()
 
@@ -239,18 +281,18 @@ func (api *API) SearchCommunityGroup(
 			&group.LastMessageAt, &group.IsDeleted, &group.CreatedAt, &group.UpdatedAt, &group.ShortCode,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("scanning groups: %w", err)
+			return nil, 0, fmt.Errorf("scanning groups: %w", err)
 		}
 		groups = append(groups, group)
 	}
-	return groups, nil
+	return groups, total, rows.Err()
 }
 
 func (api *API) GetCommunityGroupByShortCode(ctx context.Context, shortCode string) (model.CommunityGroup, error) {
 	query := `
         SELECT cg.id, cg.name, cg.description, cg.group_type, cg.destination_place_id, cg.destination_name,
                ST_AsText(cg.destination_location), cg.visibility, cg.creator_id, cg.icon_url,
-               (SELECT COUNT(*)::int FROM group_memberships gm WHERE gm.group_id = cg.id) AS member_count,
+               cg.member_count,
                false AS is_member,
                0 AS unread_count,
                NULL::timestamptz AS last_read_at,
@@ -268,17 +310,20 @@ func (api *API) GetCommunityGroupByShortCode(ctx context.Context, shortCode stri
 	return group, err
 }
 
-func (api *API) GetGroupMessages(ctx context.Context, groupID uuid.UUID, limit int) ([]model.GroupMessage, error) {
+// GetGroupMessages returns up to limit of the group's messages, newest first. When
+// beforeAt is set only messages older than the (beforeAt, beforeID) position are returned.
+func (api *API) GetGroupMessages(ctx context.Context, groupID uuid.UUID, limit int, beforeAt *time.Time, beforeID uuid.UUID) ([]model.GroupMessage, error) {
 	query := `
-        SELECT m.id, m.group_id, m.sender_id, m.message_type, m.content, m.is_deleted, m.created_at, m.updated_at,
-               u.username AS sender_username
+        SELECT m.id, m.group_id, m.sender_id, m.message_type, m.content, m.attachment, m.is_deleted,
+               m.pinned_at, m.pinned_by, m.created_at, m.updated_at, u.username AS sender_username
         FROM messages m
         LEFT JOIN users u ON u.id = m.sender_id
         WHERE m.group_id = $1 AND m.is_deleted = FALSE
-        ORDER BY m.created_at DESC
+          AND ($3::timestamptz IS NULL OR (m.created_at, m.id) < ($3, $4))
+        ORDER BY m.created_at DESC, m.id DESC
         LIMIT $2
     `
-	rows, err := api.Deps.DB.Pool().Query(ctx, query, groupID, limit)
+	rows, err := api.Deps.DB.Pool().Query(ctx, query, groupID, limit, beforeAt, beforeID)
 	if err != nil {
 		return nil, fmt.Errorf("querying group messages: %w", err)
 	}
@@ -290,8 +335,8 @@ func (api *API) GetGroupMessages(ctx context.Context, groupID uuid.UUID, limit i
 		var senderUsername *string
 		err := rows.Scan(
 			&msg.ID, &msg.GroupID, &msg.UserID, &msg.MessageType,
-			&msg.Content, &msg.IsDeleted, &msg.CreatedAt, &msg.UpdatedAt,
-			&senderUsername,
+			&msg.Content, &msg.Attachment, &msg.IsDeleted, &msg.PinnedAt, &msg.PinnedBy,
+			&msg.CreatedAt, &msg.UpdatedAt, &senderUsername,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scanning group message: %w", err)
@@ -302,18 +347,355 @@ func (api *API) GetGroupMessages(ctx context.Context, groupID uuid.UUID, limit i
 
 	// Reverse so oldest is first, if preferred by client
 	// Or leave DESC so newest is first.
-	return messages, nil
+	return messages, rows.Err()
 }
 
-func (api *API) LeaveCommunityGroup(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error {
+// CountGroupMessages counts the group's messages that aren't deleted.
+func (api *API) CountGroupMessages(ctx context.Context, groupID uuid.UUID) (int, error) {
+	var total int
+	err := api.Deps.DB.Pool().QueryRow(ctx,
+		`SELECT COUNT(*) FROM messages WHERE group_id = $1 AND is_deleted = FALSE`, groupID,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("counting group messages: %w", err)
+	}
+	return total, nil
+}
+
+// GetPinnedGroupMessages returns the group's pinned messages, most recently pinned first.
+func (api *API) GetPinnedGroupMessages(ctx context.Context, groupID uuid.UUID) ([]model.GroupMessage, error) {
 	query := `
-        DELETE FROM group_memberships
-        WHERE group_id = $1 AND user_id = $2
+        SELECT m.id, m.group_id, m.sender_id, m.message_type, m.content, m.attachment, m.is_deleted,
+               m.pinned_at, m.pinned_by, m.created_at, m.updated_at, u.username AS sender_username
+        FROM messages m
+        LEFT JOIN users u ON u.id = m.sender_id
+        WHERE m.group_id = $1 AND m.pinned_at IS NOT NULL AND m.is_deleted = FALSE
+        ORDER BY m.pinned_at DESC
     `
-	_, err := api.Deps.DB.Pool().Exec(ctx, query, groupID, userID)
+	rows, err := api.Deps.DB.Pool().Query(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("querying pinned group messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []model.GroupMessage{}
+	for rows.Next() {
+		var msg model.GroupMessage
+		err := rows.Scan(
+			&msg.ID, &msg.GroupID, &msg.UserID, &msg.MessageType,
+			&msg.Content, &msg.Attachment, &msg.IsDeleted, &msg.PinnedAt, &msg.PinnedBy,
+			&msg.CreatedAt, &msg.UpdatedAt, &msg.SenderUsername,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning pinned group message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// SetGroupMessagePinned pins or unpins a message in the group. Pinning is capped at
+// maxPinned messages per group; it returns ErrPinLimitReached when the group is full
+// and pgx.ErrNoRows when the message is not in the group.
+func (api *API) SetGroupMessagePinned(ctx context.Context, groupID, messageID, userID uuid.UUID, pinned bool, maxPinned int) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var alreadyPinned bool
+		err := tx.QueryRow(ctx, `
+            SELECT pinned_at IS NOT NULL FROM messages
+            WHERE id = $1 AND group_id = $2 AND is_deleted = FALSE
+            FOR UPDATE
+        `, messageID, groupID).Scan(&alreadyPinned)
+		if err != nil {
+			return err
+		}
+
+		if !pinned {
+			_, err = tx.Exec(ctx, `UPDATE messages SET pinned_at = NULL, pinned_by = NULL WHERE id = $1`, messageID)
+			return err
+		}
+		if alreadyPinned {
+			return nil
+		}
+
+		// Lock the group row so concurrent pins cannot both pass the cap.
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM community_groups WHERE id = $1 FOR UPDATE`, groupID); err != nil {
+			return err
+		}
+		var count int
+		err = tx.QueryRow(ctx, `
+            SELECT COUNT(*) FROM messages
+            WHERE group_id = $1 AND pinned_at IS NOT NULL AND is_deleted = FALSE
+        `, groupID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count >= maxPinned {
+			return ErrPinLimitReached
+		}
+		_, err = tx.Exec(ctx, `UPDATE messages SET pinned_at = NOW(), pinned_by = $2 WHERE id = $1`, messageID, userID)
+		return err
+	})
+}
+
+// CountRecentAnnouncements counts the group's announcements sent since the given time.
+func (api *API) CountRecentAnnouncements(ctx context.Context, groupID uuid.UUID, since time.Time) (int, error) {
+	var count int
+	err := api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT COUNT(*) FROM messages
+        WHERE group_id = $1 AND message_type = 'announcement' AND created_at >= $2
+    `, groupID, since).Scan(&count)
+	return count, err
+}
+
+// lockGroupMemberships serialises membership changes to a group for the rest of tx, so
+// member_count and the last-admin check see a stable set of members.
+func lockGroupMemberships(ctx context.Context, tx pgx.Tx, groupID uuid.UUID) error {
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, `
+        SELECT id FROM community_groups WHERE id = $1 AND is_deleted = FALSE FOR UPDATE
+    `, groupID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("group %w", ErrNotFound)
+	}
 	return err
 }
 
+// refreshGroupMemberCount recounts the group's active members into
+// community_groups.member_count and returns the new count.
+func refreshGroupMemberCount(ctx context.Context, tx pgx.Tx, groupID uuid.UUID) (int, error) {
+	var count int
+	err := tx.QueryRow(ctx, `
+        UPDATE community_groups
+        SET member_count = (
+            SELECT COUNT(*) FROM group_memberships
+            WHERE group_id = $1 AND status = 'active'
+        )
+        WHERE id = $1
+        RETURNING member_count
+    `, groupID).Scan(&count)
+	return count, err
+}
+
+// ensureGroupHasAdmin fails with ErrLastGroupAdmin when the group has active members but
+// no active admin.
+func ensureGroupHasAdmin(ctx context.Context, tx pgx.Tx, groupID uuid.UUID) error {
+	var members, admins int
+	err := tx.QueryRow(ctx, `
+        SELECT COUNT(*), COUNT(*) FILTER (WHERE role = 'admin')
+        FROM group_memberships
+        WHERE group_id = $1 AND status = 'active'
+    `, groupID).Scan(&members, &admins)
+	if err != nil {
+		return err
+	}
+	if members > 0 && admins == 0 {
+		return ErrLastGroupAdmin
+	}
+	return nil
+}
+
+// JoinCommunityGroup adds the user to the group as a member with the given status: active,
+// or pending for a request to join a private group. Joining as active also accepts any
+// pending invitation to the group. A user who already has a membership gets
+// ErrAlreadyGroupMember or ErrJoinRequestPending.
+func (api *API) JoinCommunityGroup(ctx context.Context, groupID, userID uuid.UUID, status string) (model.GroupMembership, error) {
+	var membership model.GroupMembership
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockGroupMemberships(ctx, tx, groupID); err != nil {
+			return err
+		}
+
+		var existing string
+		err := tx.QueryRow(ctx, `
+            SELECT status FROM group_memberships WHERE group_id = $1 AND user_id = $2
+        `, groupID, userID).Scan(&existing)
+		switch {
+		case err == nil && existing == values.GroupMemberPending:
+			return ErrJoinRequestPending
+		case err == nil:
+			return ErrAlreadyGroupMember
+		case !errors.Is(err, pgx.ErrNoRows):
+			return err
+		}
+
+		membership, err = scanGroupMembership(tx.QueryRow(ctx, `
+            WITH inserted AS (
+                INSERT INTO group_memberships (group_id, user_id, role, status, joined_at, updated_at)
+                VALUES ($1, $2, 'member', $3, NOW(), NOW())
+                RETURNING *
+            )
+            SELECT `+groupMembershipColumns+`
+            FROM inserted gm
+            LEFT JOIN users u ON u.id = gm.user_id
+        `, groupID, userID, status))
+		if err != nil || status != values.GroupMemberActive {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+            UPDATE group_invitations SET status = 'accepted', updated_at = NOW()
+            WHERE group_id = $1 AND invited_user_id = $2 AND status = 'pending'
+        `, groupID, userID)
+		if err != nil {
+			return err
+		}
+		_, err = refreshGroupMemberCount(ctx, tx, groupID)
+		return err
+	})
+	return membership, dbError(err)
+}
+
+// RemoveGroupMember deletes the user's membership, active or pending, for leaving, kicks
+// and rejected join requests. Removing the last admin of a group that still has other
+// members fails with ErrLastGroupAdmin.
+func (api *API) RemoveGroupMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockGroupMemberships(ctx, tx, groupID); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+            DELETE FROM group_memberships
+            WHERE group_id = $1 AND user_id = $2
+        `, groupID, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrGroupMemberNotFound
+		}
+		if err := ensureGroupHasAdmin(ctx, tx, groupID); err != nil {
+			return err
+		}
+		_, err = refreshGroupMemberCount(ctx, tx, groupID)
+		return err
+	})
+	return dbError(err)
+}
+
+// ApproveGroupMember turns a pending join request into an active membership.
+func (api *API) ApproveGroupMember(ctx context.Context, groupID, userID uuid.UUID) (model.GroupMembership, error) {
+	var membership model.GroupMembership
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockGroupMemberships(ctx, tx, groupID); err != nil {
+			return err
+		}
+		var err error
+		membership, err = scanGroupMembership(tx.QueryRow(ctx, `
+            WITH updated AS (
+                UPDATE group_memberships
+                SET status = 'active', joined_at = NOW(), updated_at = NOW()
+                WHERE group_id = $1 AND user_id = $2 AND status = 'pending'
+                RETURNING *
+            )
+            SELECT `+groupMembershipColumns+`
+            FROM updated gm
+            LEFT JOIN users u ON u.id = gm.user_id
+        `, groupID, userID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrJoinRequestNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = refreshGroupMemberCount(ctx, tx, groupID)
+		return err
+	})
+	return membership, dbError(err)
+}
+
+// UpdateGroupMemberRole changes an active member's role. Demoting the group's last admin
+// fails with ErrLastGroupAdmin.
+func (api *API) UpdateGroupMemberRole(ctx context.Context, groupID, userID uuid.UUID, role string) (model.GroupMembership, error) {
+	var membership model.GroupMembership
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockGroupMemberships(ctx, tx, groupID); err != nil {
+			return err
+		}
+		var err error
+		membership, err = scanGroupMembership(tx.QueryRow(ctx, `
+            WITH updated AS (
+                UPDATE group_memberships
+                SET role = $3, updated_at = NOW()
+                WHERE group_id = $1 AND user_id = $2 AND status = 'active'
+                RETURNING *
+            )
+            SELECT `+groupMembershipColumns+`
+            FROM updated gm
+            LEFT JOIN users u ON u.id = gm.user_id
+        `, groupID, userID, role))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrGroupMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return ensureGroupHasAdmin(ctx, tx, groupID)
+	})
+	return membership, dbError(err)
+}
+
+// GetGroupMembership returns the user's membership in the group, active or pending.
+func (api *API) GetGroupMembership(ctx context.Context, groupID, userID uuid.UUID) (model.GroupMembership, error) {
+	m, err := scanGroupMembership(api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT `+groupMembershipColumns+`
+        FROM group_memberships gm
+        LEFT JOIN users u ON u.id = gm.user_id
+        WHERE gm.group_id = $1 AND gm.user_id = $2
+    `, groupID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.GroupMembership{}, ErrGroupMemberNotFound
+	}
+	return m, err
+}
+
+// ListGroupMembers returns one page of the group's memberships with the given status,
+// admins first, and how many memberships have that status.
+func (api *API) ListGroupMembers(ctx context.Context, groupID uuid.UUID, status string, limit, offset int) ([]model.GroupMembership, int, error) {
+	var total int
+	err := api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT COUNT(*) FROM group_memberships WHERE group_id = $1 AND status = $2
+    `, groupID, status).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("counting group members: %w", err)
+	}
+
+	rows, err := api.Deps.DB.Pool().Query(ctx, `
+        SELECT `+groupMembershipColumns+`
+        FROM group_memberships gm
+        LEFT JOIN users u ON u.id = gm.user_id
+        WHERE gm.group_id = $1 AND gm.status = $2
+        ORDER BY CASE gm.role WHEN 'admin' THEN 0 WHEN 'moderator' THEN 1 ELSE 2 END, gm.joined_at, gm.id
+        LIMIT $3 OFFSET $4
+    `, groupID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying group members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []model.GroupMembership{}
+	for rows.Next() {
+		m, err := scanGroupMembership(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scanning group member: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, total, rows.Err()
+}
+
+// HasPendingGroupInvitation reports whether the user has an unanswered invitation to the
+// group.
+func (api *API) HasPendingGroupInvitation(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT EXISTS(
+            SELECT 1 FROM group_invitations
+            WHERE group_id = $1 AND invited_user_id = $2 AND status = 'pending'
+        )
+    `, groupID, userID).Scan(&exists)
+	return exists, err
+}
+
 func (api *API) MarkCommunityGroupRead(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error {
 	_, err := api.Deps.DB.Pool().Exec(ctx, `
         UPDATE group_memberships
@@ -323,11 +705,43 @@ func (api *API) MarkCommunityGroupRead(ctx context.Context, groupID uuid.UUID, u
 	return err
 }
 
+// GetGroupNotificationLevel returns the member's push setting for the group.
+func (api *API) GetGroupNotificationLevel(ctx context.Context, groupID, userID uuid.UUID) (string, error) {
+	var level string
+	err := api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT notification_level FROM group_memberships
+        WHERE group_id = $1 AND user_id = $2 AND status = 'active'
+    `, groupID, userID).Scan(&level)
+	return level, err
+}
+
+// GetGroupMemberRole returns the user's role in the group, or pgx.ErrNoRows if they are
+// not an active member.
+func (api *API) GetGroupMemberRole(ctx context.Context, groupID, userID uuid.UUID) (string, error) {
+	var role string
+	err := api.Deps.DB.Pool().QueryRow(ctx, `
+        SELECT role FROM group_memberships
+        WHERE group_id = $1 AND user_id = $2 AND status = 'active'
+    `, groupID, userID).Scan(&role)
+	return role, err
+}
+
+func (api *API) UpdateGroupNotificationLevel(ctx context.Context, groupID, userID uuid.UUID, level string) error {
+	_, err := api.Deps.DB.Pool().Exec(ctx, `
+        UPDATE group_memberships
+        SET notification_level = $3, updated_at = NOW()
+        WHERE group_id = $1 AND user_id = $2 AND status = 'active'
+    `, groupID, userID, level)
+	return err
+}
+
+// GetGroupMembers returns every active member of the group, for fan-out and presence.
 func (api *API) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]model.GroupMembership, error) {
 	query := `
-        SELECT id, group_id, user_id, role, 'active' AS status, joined_at, updated_at
-        FROM group_memberships
-        WHERE group_id = $1
+        SELECT ` + groupMembershipColumns + `
+        FROM group_memberships gm
+        LEFT JOIN users u ON u.id = gm.user_id
+        WHERE gm.group_id = $1 AND gm.status = 'active'
     `
 	rows, err := api.Deps.DB.Pool().Query(ctx, query, groupID)
 	if err != nil {
@@ -337,10 +751,7 @@ func (api *API) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]model
 
 	var members []model.GroupMembership
 	for rows.Next() {
-		var m model.GroupMembership
-		err := rows.Scan(
-			&m.ID, &m.GroupID, &m.UserID, &m.Role, &m.Status, &m.JoinedAt, &m.UpdatedAt,
-		)
+		m, err := scanGroupMembership(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning group member: %w", err)
 		}
@@ -349,30 +760,60 @@ func (api *API) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]model
 	return members, nil
 }
 
+// GetNavigatingUserIDs returns which of userIDs have an active navigation session.
+func (api *API) GetNavigatingUserIDs(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	rows, err := api.Deps.DB.Pool().Query(ctx, `
+        SELECT DISTINCT user_id FROM navigation_sessions
+        WHERE user_id = ANY($1) AND status = 'active'
+    `, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("querying navigating members: %w", err)
+	}
+	defer rows.Close()
+
+	navigating := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning navigating member: %w", err)
+		}
+		navigating[id] = true
+	}
+	return navigating, rows.Err()
+}
+
 func (api *API) InsertGroupMessage(ctx context.Context, message model.GroupMessage) (model.GroupMessage, error) {
 	message.ID = uuid.New()
 	message.CreatedAt = time.Now()
 	message.UpdatedAt = time.Now()
 
 	query := `
-        INSERT INTO messages (id, group_id, sender_id, message_type, content, is_deleted, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        INSERT INTO messages (id, group_id, sender_id, message_type, content, attachment, is_deleted, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
         RETURNING id, created_at, updated_at
     `
-	err := api.Deps.DB.Pool().QueryRow(ctx, query,
-		message.ID, message.GroupID, message.UserID, message.MessageType,
-		message.Content, message.IsDeleted, message.CreatedAt, message.UpdatedAt,
-	).Scan(&message.ID, &message.CreatedAt, &message.UpdatedAt)
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, query,
+			message.ID, message.GroupID, message.UserID, message.MessageType,
+			message.Content, message.Attachment, message.IsDeleted, message.CreatedAt, message.UpdatedAt,
+		).Scan(&message.ID, &message.CreatedAt, &message.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("inserting group message: %w", err)
+		}
 
+		// Also update last_message_at in the group
+		_, _ = tx.Exec(ctx, `
+			UPDATE community_groups SET last_message_at = $1 WHERE id = $2
+		`, message.CreatedAt, message.GroupID)
+
+		id := message.ID.String()
+		return insertOutboxEvent(ctx, tx, OutboxEventGroupMessage, message.GroupID.String(), OutboxEventGroupMessage+":"+id,
+			model.GroupMessageEvent{Message: message})
+	})
 	if err != nil {
-		return message, fmt.Errorf("inserting group message: %w", err)
+		return message, err
 	}
 
-	// Also update last_message_at in the group
-	_, _ = api.Deps.DB.Pool().Exec(ctx, `
-        UPDATE community_groups SET last_message_at = $1 WHERE id = $2
-    `, message.CreatedAt, message.GroupID)
-
 	return message, nil
 }
 
@@ -382,8 +823,11 @@ func (api *API) CreateInvitation(ctx context.Context, groupID, invitedUserID, in
 	// Check invited user exists
 	var exists bool
 	err := api.Deps.DB.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, invitedUserID).Scan(&exists)
-	if err != nil || !exists {
-		return model.GroupInvitation{}, fmt.Errorf("invited user not found")
+	if err != nil {
+		return model.GroupInvitation{}, err
+	}
+	if !exists {
+		return model.GroupInvitation{}, ErrInvitedUserNotFound
 	}
 	// Check not already a member
 	err = api.Deps.DB.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM group_memberships WHERE group_id = $1 AND user_id = $2)`, groupID, invitedUserID).Scan(&exists)
@@ -391,7 +835,7 @@ func (api *API) CreateInvitation(ctx context.Context, groupID, invitedUserID, in
 		return model.GroupInvitation{}, err
 	}
 	if exists {
-		return model.GroupInvitation{}, fmt.Errorf("user is already a member")
+		return model.GroupInvitation{}, ErrAlreadyGroupMember
 	}
 	// Check no pending invite
 	err = api.Deps.DB.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM group_invitations WHERE group_id = $1 AND invited_user_id = $2 AND status = 'pending')`, groupID, invitedUserID).Scan(&exists)
@@ -399,7 +843,7 @@ func (api *API) CreateInvitation(ctx context.Context, groupID, invitedUserID, in
 		return model.GroupInvitation{}, err
 	}
 	if exists {
-		return model.GroupInvitation{}, fmt.Errorf("user already has a pending invitation")
+		return model.GroupInvitation{}, ErrInvitationPending
 	}
 
 	inv := model.GroupInvitation{
@@ -416,7 +860,7 @@ func (api *API) CreateInvitation(ctx context.Context, groupID, invitedUserID, in
         VALUES ($1, $2, $3, $4, $5, $6, $7)
     `, inv.ID, inv.GroupID, inv.InvitedUserID, inv.InvitedBy, inv.Status, inv.CreatedAt, inv.UpdatedAt)
 	if err != nil {
-		return model.GroupInvitation{}, fmt.Errorf("inserting invitation: %w", err)
+		return model.GroupInvitation{}, fmt.Errorf("inserting invitation: %w", dbError(err))
 	}
 	return inv, nil
 }
@@ -453,12 +897,12 @@ func (api *API) ListInvitationsByGroup(ctx context.Context, groupID uuid.UUID) (
 func (api *API) ListInvitationsForUser(ctx context.Context, userID uuid.UUID) ([]model.GroupInvitation, error) {
 	query := `
         SELECT gi.id, gi.group_id, gi.invited_user_id, gi.invited_by, gi.status, gi.created_at, gi.updated_at,
-               cg.name AS group_name,
+               cg.name AS group_name, cg.short_code,
                u.username AS invited_by_name
         FROM group_invitations gi
         JOIN community_groups cg ON cg.id = gi.group_id
         LEFT JOIN users u ON u.id = gi.invited_by
-        WHERE gi.invited_user_id = $1 AND gi.status = 'pending'
+        WHERE gi.invited_user_id = $1 AND gi.status = 'pending' AND cg.is_deleted = FALSE
         ORDER BY gi.created_at DESC
     `
 	rows, err := api.Deps.DB.Pool().Query(ctx, query, userID)
@@ -470,13 +914,14 @@ func (api *API) ListInvitationsForUser(ctx context.Context, userID uuid.UUID) ([
 	var list []model.GroupInvitation
 	for rows.Next() {
 		var inv model.GroupInvitation
-		var groupName string
+		var groupName, shortCode string
 		var invitedByName *string
-		err := rows.Scan(&inv.ID, &inv.GroupID, &inv.InvitedUserID, &inv.InvitedBy, &inv.Status, &inv.CreatedAt, &inv.UpdatedAt, &groupName, &invitedByName)
+		err := rows.Scan(&inv.ID, &inv.GroupID, &inv.InvitedUserID, &inv.InvitedBy, &inv.Status, &inv.CreatedAt, &inv.UpdatedAt, &groupName, &shortCode, &invitedByName)
 		if err != nil {
 			return nil, fmt.Errorf("scanning invitation: %w", err)
 		}
 		inv.GroupName = &groupName
+		inv.GroupShortCode = &shortCode
 		inv.InvitedByName = invitedByName
 		list = append(list, inv)
 	}
@@ -489,6 +934,9 @@ func (api *API) GetInvitationByID(ctx context.Context, id uuid.UUID) (model.Grou
         SELECT id, group_id, invited_user_id, invited_by, status, created_at, updated_at
         FROM group_invitations WHERE id = $1
     `, id).Scan(&inv.ID, &inv.GroupID, &inv.InvitedUserID, &inv.InvitedBy, &inv.Status, &inv.CreatedAt, &inv.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.GroupInvitation{}, ErrInvitationNotFound
+	}
 	if err != nil {
 		return model.GroupInvitation{}, err
 	}
@@ -501,23 +949,35 @@ func (api *API) AcceptInvitation(ctx context.Context, invitationID, userID uuid.
 		return err
 	}
 	if inv.InvitedUserID != userID {
-		return fmt.Errorf("invitation is not for this user")
+		return ErrInvitationNotYours
 	}
 	if inv.Status != "pending" {
-		return fmt.Errorf("invitation is no longer pending")
+		return ErrInvitationAnswered
 	}
 
-	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+	err = api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := lockGroupMemberships(ctx, tx, inv.GroupID); err != nil {
+			return err
+		}
+		// A join request sent before the invitation is accepted along with it.
 		_, err := tx.Exec(ctx, `
             INSERT INTO group_memberships (group_id, user_id, role, joined_at, updated_at)
             VALUES ($1, $2, 'member', NOW(), NOW())
+            ON CONFLICT (group_id, user_id) DO UPDATE
+            SET status = 'active', joined_at = NOW(), updated_at = NOW()
+            WHERE group_memberships.status = 'pending'
         `, inv.GroupID, userID)
 		if err != nil {
 			return err
 		}
 		_, err = tx.Exec(ctx, `UPDATE group_invitations SET status = 'accepted', updated_at = NOW() WHERE id = $1`, invitationID)
+		if err != nil {
+			return err
+		}
+		_, err = refreshGroupMemberCount(ctx, tx, inv.GroupID)
 		return err
 	})
+	return dbError(err)
 }
 
 func (api *API) DeclineInvitation(ctx context.Context, invitationID, userID uuid.UUID) error {
@@ -526,18 +986,19 @@ func (api *API) DeclineInvitation(ctx context.Context, invitationID, userID uuid
 		return err
 	}
 	if inv.InvitedUserID != userID {
-		return fmt.Errorf("invitation is not for this user")
+		return ErrInvitationNotYours
 	}
 	if inv.Status != "pending" {
-		return fmt.Errorf("invitation is no longer pending")
+		return ErrInvitationAnswered
 	}
 	_, err = api.Deps.DB.Pool().Exec(ctx, `UPDATE group_invitations SET status = 'declined', updated_at = NOW() WHERE id = $1`, invitationID)
 	return err
 }
 
-// IsUserMemberOfGroup returns true if the user is in the group (any role).
+// IsUserMemberOfGroup returns true if the user is an active member of the group (any
+// role); pending join requests don't count.
 func (api *API) IsUserMemberOfGroup(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
 	var exists bool
-	err := api.Deps.DB.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM group_memberships WHERE group_id = $1 AND user_id = $2)`, groupID, userID).Scan(&exists)
+	err := api.Deps.DB.Pool().QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM group_memberships WHERE group_id = $1 AND user_id = $2 AND status = 'active')`, groupID, userID).Scan(&exists)
 	return exists, err
 }
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (api *API) CreateGroupRideHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.GroupRideRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	ride, status, message, err := api.CreateGroupRideHelper(r.Context(), groupID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       ride,
+	}
+}
+
+func (api *API) ListGroupRidesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	rides, status, message, err := api.ListGroupRidesHelper(r.Context(), groupID, userID, r.URL.Query().Get("kind"))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       rides,
+	}
+}
+
+func (api *API) ListGroupRideMatchesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideID"))
+	if err != nil {
+		return respondWithError(err, "invalid ride ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	matches, status, message, err := api.ListGroupRideMatchesHelper(r.Context(), groupID, rideID, userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       matches,
+	}
+}
+
+func (api *API) CancelGroupRideHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	groupID, err := uuid.Parse(chi.URLParam(r, "groupID"))
+	if err != nil {
+		return respondWithError(err, "invalid group ID format", values.BadRequestBody, &tc)
+	}
+	rideID, err := uuid.Parse(chi.URLParam(r, "rideID"))
+	if err != nil {
+		return respondWithError(err, "invalid ride ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	status, message, err := api.CancelGroupRideHelper(r.Context(), groupID, rideID, userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
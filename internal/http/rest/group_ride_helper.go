@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
+)
+
+const (
+	// A request's pickup must be within groupRideCorridorM of the driver's route.
+	groupRideCorridorM = 1000.0
+	// Rides can be posted up to groupRideMaxLead ahead, with a departure window of at
+	// most groupRideMaxWindow.
+	groupRideMaxLead   = 7 * 24 * time.Hour
+	groupRideMaxWindow = 12 * time.Hour
+	maxGroupRides      = 200
+)
+
+// groupRideWindow checks a ride's departure window against now.
+func groupRideWindow(now, from, until time.Time) error {
+	switch {
+	case until.Before(from):
+		return errors.New("depart_until must not be before depart_from")
+	case !until.After(now):
+		return errors.New("depart_until must be in the future")
+	case from.After(now.Add(groupRideMaxLead)):
+		return errors.New("depart_from must be within 7 days")
+	case until.Sub(from) > groupRideMaxWindow:
+		return errors.New("the departure window can be at most 12 hours")
+	}
+	return nil
+}
+
+// CreateGroupRideHelper posts a ride offer or request to the group's destination and
+// tells both sides of any match it makes.
+func (api *API) CreateGroupRideHelper(ctx context.Context, groupID, userID uuid.UUID, req model.GroupRideRequest) (model.GroupRide, string, string, error) {
+	if status, msg, err := api.requireGroupMember(ctx, groupID, userID, "Only group members can share rides"); err != nil {
+		return model.GroupRide{}, status, msg, err
+	}
+	if err := groupRideWindow(time.Now(), req.DepartFrom, req.DepartUntil); err != nil {
+		return model.GroupRide{}, values.BadRequestBody, err.Error(), err
+	}
+	originLat, originLng, err := util.NormalizeCoordinate(req.OriginLatitude, req.OriginLongitude)
+	if err != nil {
+		return model.GroupRide{}, values.BadRequestBody, "invalid origin latitude or longitude", err
+	}
+	destLat, destLng, ok, err := api.GroupDestinationRepo(ctx, groupID)
+	if err != nil {
+		return model.GroupRide{}, errorStatus(err, values.Error), "Failed to load group destination", err
+	}
+	if !ok {
+		return model.GroupRide{}, values.BadRequestBody, "Rides can only be shared in groups with a destination", errors.New("group has no destination")
+	}
+
+	ride := model.GroupRide{
+		GroupID:        groupID,
+		UserID:         userID,
+		Kind:           req.Kind,
+		OriginLat:      originLat,
+		OriginLng:      originLng,
+		OriginName:     req.OriginName,
+		DestinationLat: destLat,
+		DestinationLng: destLng,
+		DepartFrom:     req.DepartFrom,
+		DepartUntil:    req.DepartUntil,
+		Seats:          req.Seats,
+		Note:           req.Note,
+	}
+	var routeGeoJSON string
+	if ride.Kind == values.GroupRideOffer {
+		routeGeoJSON, err = lineStringGeoJSON(api.groupRideRoute(ctx, ride))
+		if err != nil {
+			return model.GroupRide{}, values.Error, "Failed to post ride", err
+		}
+	}
+
+	created, err := api.CreateGroupRideRepo(ctx, ride, routeGeoJSON)
+	if err != nil {
+		return model.GroupRide{}, values.Error, "Failed to post ride", err
+	}
+	matches, err := api.MatchGroupRideRepo(ctx, created.ID, groupRideCorridorM)
+	if err != nil {
+		// The ride is posted; it is matched again when the other side posts.
+		api.logger().ErrorContext(ctx, "Group rides: matching failed", "ride_id", created.ID, "err", err)
+	}
+	for _, m := range matches {
+		api.notifyGroupRideMatch(ctx, created, m)
+	}
+	return created, values.Created, fmt.Sprintf("Ride posted with %d matches", len(matches)), nil
+}
+
+// groupRideRoute is the driver's route as [lng, lat] pairs, or a straight line when it
+// can't be routed.
+func (api *API) groupRideRoute(ctx context.Context, ride model.GroupRide) [][]float64 {
+	straight := [][]float64{{ride.OriginLng, ride.OriginLat}, {ride.DestinationLng, ride.DestinationLat}}
+	if api.ValhallaClient == nil {
+		return straight
+	}
+	route, err := api.ValhallaClient.GetRoute(ctx, valhalla.RouteRequest{
+		Locations: []valhalla.Location{{Lat: ride.OriginLat, Lon: ride.OriginLng}, {Lat: ride.DestinationLat, Lon: ride.DestinationLng}},
+		Costing:   "auto",
+	})
+	if err != nil {
+		api.logger().WarnContext(ctx, "Group rides: routing failed, matching along a straight line", "err", err)
+		return straight
+	}
+	var coords [][]float64
+	for _, leg := range route.Trip.Legs {
+		coords = append(coords, leg.Coordinates...)
+	}
+	if len(coords) < 2 {
+		return straight
+	}
+	return coords
+}
+
+// notifyGroupRideMatch tells both riders about a new match over WebSocket, each with the
+// other's ride. Best effort: GET .../rides/{rideID}/matches lists them too.
+func (api *API) notifyGroupRideMatch(ctx context.Context, ride model.GroupRide, m model.GroupRideMatch) {
+	for _, side := range []struct {
+		to    uuid.UUID
+		match model.GroupRideMatch
+	}{
+		{ride.UserID, m},
+		{m.Ride.UserID, model.GroupRideMatch{Ride: ride, PickupDistanceM: m.PickupDistanceM, MatchedAt: m.MatchedAt}},
+	} {
+		content, err := json.Marshal(side.match)
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Encoding ride match failed", "ride_id", ride.ID, "err", err)
+			return
+		}
+		raw, err := json.Marshal(websockets.Message{
+			Type:    websockets.MsgTypeRideMatch,
+			UserID:  side.to.String(),
+			Content: string(content),
+			GroupID: ride.GroupID.String(),
+		})
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Encoding ride match failed", "ride_id", ride.ID, "err", err)
+			return
+		}
+		api.Deps.WebSocket.SendToUser(side.to.String(), raw)
+	}
+}
+
+func (api *API) ListGroupRidesHelper(ctx context.Context, groupID, userID uuid.UUID, kind string) ([]model.GroupRide, string, string, error) {
+	if kind != "" && kind != values.GroupRideOffer && kind != values.GroupRideRequest {
+		return nil, values.BadRequestBody, "kind must be offer or request", fmt.Errorf("unknown ride kind %q", kind)
+	}
+	if status, msg, err := api.requireGroupMember(ctx, groupID, userID, "Only group members can see rides"); err != nil {
+		return nil, status, msg, err
+	}
+	rides, err := api.ListGroupRidesRepo(ctx, groupID, kind, maxGroupRides)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch rides", err
+	}
+	if rides == nil {
+		rides = []model.GroupRide{}
+	}
+	return rides, values.Success, "Rides fetched", nil
+}
+
+// ListGroupRideMatchesHelper lists the matches of one of the caller's own rides.
+func (api *API) ListGroupRideMatchesHelper(ctx context.Context, groupID, rideID, userID uuid.UUID) ([]model.GroupRideMatch, string, string, error) {
+	ride, err := api.GetGroupRideRepo(ctx, groupID, rideID)
+	if err == nil && ride.UserID != userID {
+		err = ErrGroupRideNotFound
+	}
+	if errors.Is(err, ErrGroupRideNotFound) {
+		return nil, values.NotFound, "Ride not found", err
+	}
+	if err != nil {
+		return nil, values.Error, "Failed to load ride", err
+	}
+
+	matches, err := api.ListGroupRideMatchesRepo(ctx, rideID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch ride matches", err
+	}
+	if matches == nil {
+		matches = []model.GroupRideMatch{}
+	}
+	return matches, values.Success, "Ride matches fetched", nil
+}
+
+func (api *API) CancelGroupRideHelper(ctx context.Context, groupID, rideID, userID uuid.UUID) (string, string, error) {
+	err := api.CancelGroupRideRepo(ctx, groupID, rideID, userID)
+	if errors.Is(err, ErrGroupRideNotFound) {
+		return values.NotFound, "Ride not found", err
+	}
+	if err != nil {
+		return values.Error, "Failed to cancel ride", err
+	}
+	return values.Success, "Ride cancelled", nil
+}
@@ -0,0 +1,27 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupRideWindow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		from, until time.Time
+		ok          bool
+	}{
+		{"upcoming", now.Add(time.Hour), now.Add(2 * time.Hour), true},
+		{"already open", now.Add(-time.Hour), now.Add(time.Hour), true},
+		{"reversed", now.Add(2 * time.Hour), now.Add(time.Hour), false},
+		{"over", now.Add(-2 * time.Hour), now.Add(-time.Hour), false},
+		{"too far ahead", now.Add(8 * 24 * time.Hour), now.Add(8*24*time.Hour + time.Hour), false},
+		{"window too wide", now, now.Add(13 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		if err := groupRideWindow(now, tt.from, tt.until); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrGroupRideNotFound = fmt.Errorf("group ride %w", ErrNotFound)
+
+// groupRideColumns selects a ride aliased r, joined to its owner aliased u.
+const groupRideColumns = `
+    r.id, r.group_id, r.user_id, u.username, r.kind, ST_Y(r.origin), ST_X(r.origin), r.origin_name,
+    ST_Y(r.destination), ST_X(r.destination), r.depart_from, r.depart_until, r.seats, r.note,
+    r.status, r.created_at, r.updated_at`
+
+func groupRideDest(r *model.GroupRide) []any {
+	return []any{&r.ID, &r.GroupID, &r.UserID, &r.Username, &r.Kind, &r.OriginLat, &r.OriginLng, &r.OriginName,
+		&r.DestinationLat, &r.DestinationLng, &r.DepartFrom, &r.DepartUntil, &r.Seats, &r.Note,
+		&r.Status, &r.CreatedAt, &r.UpdatedAt}
+}
+
+func scanGroupRide(row pgx.Row) (model.GroupRide, error) {
+	var r model.GroupRide
+	err := row.Scan(groupRideDest(&r)...)
+	return r, err
+}
+
+func scanGroupRideMatches(rows pgx.Rows) ([]model.GroupRideMatch, error) {
+	defer rows.Close()
+	var matches []model.GroupRideMatch
+	for rows.Next() {
+		var m model.GroupRideMatch
+		if err := rows.Scan(append([]any{&m.PickupDistanceM, &m.MatchedAt}, groupRideDest(&m.Ride)...)...); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// GroupDestinationRepo returns the destination of a live group; ok is false when the
+// group has none.
+func (api *API) GroupDestinationRepo(ctx context.Context, groupID uuid.UUID) (lat, lng float64, ok bool, err error) {
+	var latPtr, lngPtr *float64
+	err = api.DB.QueryRow(ctx, `
+        SELECT ST_Y(destination_location), ST_X(destination_location)
+        FROM community_groups
+        WHERE id = $1 AND is_deleted = FALSE
+    `, groupID).Scan(&latPtr, &lngPtr)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, false, ErrNotFound
+	}
+	if err != nil || latPtr == nil || lngPtr == nil {
+		return 0, 0, false, err
+	}
+	return *latPtr, *lngPtr, true, nil
+}
+
+// CreateGroupRideRepo stores a ride. routeGeoJSON is the driver's route as a GeoJSON
+// LineString, and empty for requests.
+func (api *API) CreateGroupRideRepo(ctx context.Context, ride model.GroupRide, routeGeoJSON string) (model.GroupRide, error) {
+	return scanGroupRide(api.DB.QueryRow(ctx, `
+        WITH r AS (
+            INSERT INTO group_rides
+                (group_id, user_id, kind, origin, origin_name, destination, route,
+                 depart_from, depart_until, seats, note)
+            VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($5, $4), 4326), $6,
+                    ST_SetSRID(ST_MakePoint($8, $7), 4326),
+                    CASE WHEN $9::text = '' THEN NULL ELSE ST_SetSRID(ST_GeomFromGeoJSON($9::text), 4326) END,
+                    $10, $11, $12, $13)
+            RETURNING *
+        )
+        SELECT`+groupRideColumns+`
+        FROM r JOIN users u ON u.id = r.user_id
+    `, ride.GroupID, ride.UserID, ride.Kind, ride.OriginLat, ride.OriginLng, ride.OriginName,
+		ride.DestinationLat, ride.DestinationLng, routeGeoJSON,
+		ride.DepartFrom, ride.DepartUntil, ride.Seats, ride.Note))
+}
+
+func (api *API) GetGroupRideRepo(ctx context.Context, groupID, rideID uuid.UUID) (model.GroupRide, error) {
+	ride, err := scanGroupRide(api.DB.QueryRow(ctx, `
+        SELECT`+groupRideColumns+`
+        FROM group_rides r JOIN users u ON u.id = r.user_id
+        WHERE r.id = $1 AND r.group_id = $2
+    `, rideID, groupID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ride, ErrGroupRideNotFound
+	}
+	return ride, err
+}
+
+// ListGroupRidesRepo returns the group's open rides that haven't left yet, soonest
+// first. An empty kind lists offers and requests.
+func (api *API) ListGroupRidesRepo(ctx context.Context, groupID uuid.UUID, kind string, limit int) ([]model.GroupRide, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT`+groupRideColumns+`
+        FROM group_rides r JOIN users u ON u.id = r.user_id
+        WHERE r.group_id = $1 AND r.status = $2 AND r.depart_until > NOW()
+          AND ($3::text = '' OR r.kind = $3)
+        ORDER BY r.depart_from
+        LIMIT $4
+    `, groupID, values.GroupRideOpen, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rides []model.GroupRide
+	for rows.Next() {
+		ride, err := scanGroupRide(rows)
+		if err != nil {
+			return nil, err
+		}
+		rides = append(rides, ride)
+	}
+	return rides, rows.Err()
+}
+
+func (api *API) CancelGroupRideRepo(ctx context.Context, groupID, rideID, userID uuid.UUID) error {
+	result, err := api.DB.Exec(ctx, `
+        UPDATE group_rides SET status = $4, updated_at = NOW()
+        WHERE id = $1 AND group_id = $2 AND user_id = $3 AND status = $5
+    `, rideID, groupID, userID, values.GroupRideCancelled, values.GroupRideOpen)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrGroupRideNotFound
+	}
+	return nil
+}
+
+// MatchGroupRideRepo pairs an open ride with open rides of the other kind in its group:
+// departure windows overlap, the offer has the seats, and the request's pickup is within
+// corridorM of the offer's route. It records and returns only pairs not matched before,
+// each with the other side's ride.
+func (api *API) MatchGroupRideRepo(ctx context.Context, rideID uuid.UUID, corridorM float64) ([]model.GroupRideMatch, error) {
+	rows, err := api.DB.Query(ctx, `
+        WITH new AS (
+            INSERT INTO group_ride_matches (offer_id, request_id, pickup_distance_m)
+            SELECT o.id, q.id, ST_Distance(o.route::geography, q.origin::geography)
+            FROM group_rides o
+            JOIN group_rides q ON q.group_id = o.group_id AND q.kind = $4
+            WHERE o.kind = $3 AND (o.id = $1 OR q.id = $1)
+              AND o.status = $5 AND q.status = $5
+              AND o.user_id <> q.user_id
+              AND o.depart_from <= q.depart_until AND q.depart_from <= o.depart_until
+              AND o.depart_until > NOW() AND q.depart_until > NOW()
+              AND o.seats >= q.seats
+              AND ST_DWithin(o.route::geography, q.origin::geography, $2)
+            ON CONFLICT DO NOTHING
+            RETURNING offer_id, request_id, pickup_distance_m, created_at
+        )
+        SELECT new.pickup_distance_m, new.created_at,`+groupRideColumns+`
+        FROM new
+        JOIN group_rides r ON r.id = CASE WHEN new.offer_id = $1 THEN new.request_id ELSE new.offer_id END
+        JOIN users u ON u.id = r.user_id
+        ORDER BY new.pickup_distance_m
+    `, rideID, corridorM, values.GroupRideOffer, values.GroupRideRequest, values.GroupRideOpen)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroupRideMatches(rows)
+}
+
+// ListGroupRideMatchesRepo returns the open rides matched with rideID, closest pickup
+// first.
+func (api *API) ListGroupRideMatchesRepo(ctx context.Context, rideID uuid.UUID) ([]model.GroupRideMatch, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT m.pickup_distance_m, m.created_at,`+groupRideColumns+`
+        FROM group_ride_matches m
+        JOIN group_rides r ON r.id = CASE WHEN m.offer_id = $1 THEN m.request_id ELSE m.offer_id END
+        JOIN users u ON u.id = r.user_id
+        WHERE (m.offer_id = $1 OR m.request_id = $1) AND r.status = $2 AND r.depart_until > NOW()
+        ORDER BY m.pickup_distance_m
+    `, rideID, values.GroupRideOpen)
+	if err != nil {
+		return nil, err
+	}
+	return scanGroupRideMatches(rows)
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func (api *API) GetIsochroneHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	query := r.URL.Query()
+
+	lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+	if err != nil {
+		return respondWithError(err, "invalid lat", values.BadRequestBody, &tc)
+	}
+	lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+	if err != nil {
+		return respondWithError(err, "invalid lon", values.BadRequestBody, &tc)
+	}
+	lat, lon, err = util.NormalizeCoordinate(lat, lon)
+	if err != nil {
+		return respondWithError(err, "invalid latitude or longitude", values.BadRequestBody, &tc)
+	}
+	minutes, err := parseIsochroneMinutes(query.Get("minutes"))
+	if err != nil {
+		return respondWithError(err, err.Error(), values.BadRequestBody, &tc)
+	}
+
+	params := isochroneParams{
+		Lat:        lat,
+		Lon:        lon,
+		Minutes:    minutes,
+		Profile:    query.Get("profile"),
+		Preference: query.Get("preference"),
+		Exclude:    query.Get("exclude"),
+	}
+	isochrone, status, message, err := api.GetIsochroneHelper(r.Context(), params)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       isochrone,
+	}
+}
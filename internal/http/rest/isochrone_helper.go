@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	defaultIsochroneMinutes = 15
+	maxIsochroneMinutes     = 60
+	// Valhalla's default max_contours.
+	maxIsochroneContours = 4
+	// isochroneGeneralizeM smooths the polygons enough for a phone map.
+	isochroneGeneralizeM = 50.0
+	// isochroneDenoise drops islands under a fifth of the largest area.
+	isochroneDenoise = 0.2
+)
+
+// isochroneColors tints the contours from the nearest (first) outwards.
+var isochroneColors = []string{"2ecc71", "f1c40f", "e67e22", "e74c3c"}
+
+type isochroneParams struct {
+	Lat, Lon   float64
+	Minutes    []int
+	Profile    string // as on /route; empty means driving
+	Preference string // "fastest" (default) or "safe"
+	Exclude    string // comma separated: toll, ferry, motorway, unpaved
+}
+
+// parseIsochroneMinutes parses the comma separated ?minutes= into distinct, ascending
+// contour times.
+func parseIsochroneMinutes(raw string) ([]int, error) {
+	if strings.TrimSpace(raw) == "" {
+		return []int{defaultIsochroneMinutes}, nil
+	}
+	var minutes []int
+	for _, part := range strings.Split(raw, ",") {
+		m, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || m < 1 || m > maxIsochroneMinutes {
+			return nil, fmt.Errorf("minutes must be whole numbers from 1 to %d", maxIsochroneMinutes)
+		}
+		if !slices.Contains(minutes, m) {
+			minutes = append(minutes, m)
+		}
+	}
+	if len(minutes) > maxIsochroneContours {
+		return nil, fmt.Errorf("at most %d contours can be requested", maxIsochroneContours)
+	}
+	slices.Sort(minutes)
+	return minutes, nil
+}
+
+// GetIsochroneHelper returns the areas reachable from a point within each of the given
+// times.
+func (api *API) GetIsochroneHelper(ctx context.Context, params isochroneParams) (*valhalla.IsochroneResponse, string, string, error) {
+	if api.ValhallaClient == nil {
+		return nil, values.Unavailable, "Reachability maps are not available", errors.New("valhalla client not configured")
+	}
+	switch params.Profile {
+	case "", "driving", "driving-traffic", "walking", "cycling", profileMotorcycle:
+	default:
+		return nil, values.BadRequestBody, "profile must be driving, walking, cycling or motorcycle", fmt.Errorf("unknown profile %q", params.Profile)
+	}
+	if params.Preference != "" && params.Preference != values.RoutePreferenceFastest && params.Preference != values.RoutePreferenceSafe {
+		return nil, values.BadRequestBody, "preference must be fastest or safe", fmt.Errorf("unknown preference %q", params.Preference)
+	}
+	costing := valhallaCosting(params.Profile)
+	options, err := valhallaCostingOptions(costing, params.Preference, params.Exclude)
+	if err != nil {
+		return nil, values.BadRequestBody, err.Error(), err
+	}
+
+	contours := make([]valhalla.Contour, len(params.Minutes))
+	for i, m := range params.Minutes {
+		contours[i] = valhalla.Contour{Time: float64(m), Color: isochroneColors[i]}
+	}
+	denoise, generalize := isochroneDenoise, isochroneGeneralizeM
+	isochrone, err := api.ValhallaClient.Isochrone(ctx, valhalla.IsochroneRequest{
+		Locations:      []valhalla.Location{{Lat: params.Lat, Lon: params.Lon}},
+		Costing:        costing,
+		CostingOptions: options,
+		Contours:       contours,
+		Polygons:       true,
+		Denoise:        &denoise,
+		Generalize:     &generalize,
+	})
+	var verr *valhalla.Error
+	if errors.As(err, &verr) && verr.NoPath() {
+		return nil, values.Unprocessable, "No roads found near this location", err
+	}
+	if err != nil {
+		return nil, values.Error, "Failed to compute reachable area", err
+	}
+	return isochrone, values.Success, "Reachable area computed", nil
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func TestParseIsochroneMinutes(t *testing.T) {
+	got, err := parseIsochroneMinutes("")
+	if err != nil || !slices.Equal(got, []int{defaultIsochroneMinutes}) {
+		t.Errorf("default = %v, %v", got, err)
+	}
+	got, err = parseIsochroneMinutes("15, 5,10,5")
+	if err != nil || !slices.Equal(got, []int{5, 10, 15}) {
+		t.Errorf("got %v, %v; want [5 10 15]", got, err)
+	}
+	for _, bad := range []string{"0", "61", "ten", "5,10,15,20,30"} {
+		if _, err := parseIsochroneMinutes(bad); err == nil {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+}
+
+func TestValhallaCostingOptions(t *testing.T) {
+	options, err := valhallaCostingOptions("auto", values.RoutePreferenceSafe, "toll, ferry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auto := options.Auto
+	if auto == nil || auto.UseTracks == nil || auto.AvoidTolls == nil || !*auto.AvoidTolls || auto.AvoidFerry == nil || auto.AvoidHighways != nil {
+		t.Errorf("safe options without tolls and ferries = %+v", auto)
+	}
+
+	if options, err := valhallaCostingOptions("motorcycle", values.RoutePreferenceFastest, ""); err != nil || options.Motorcycle == nil {
+		t.Errorf("motorcycle options = %+v, %v", options, err)
+	}
+	if options, err := valhallaCostingOptions("bicycle", values.RoutePreferenceSafe, ""); err != nil || options == nil || options.Bicycle == nil {
+		t.Errorf("safe cycling options = %+v, %v", options, err)
+	}
+	if _, err := valhallaCostingOptions("pedestrian", "", "toll"); err == nil {
+		t.Error("exclude should be rejected for walking")
+	}
+	if _, err := valhallaCostingOptions("auto", "", "bridges"); err == nil {
+		t.Error("unknown exclude should be rejected")
+	}
+}
@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const (
+	// Routes are sampled this often when matched to lit ways, and at most this many times.
+	litSampleSpacingM = 25.0
+	maxLitSamples     = 400
+	// A sample belongs to a way whose centreline is this close: roads are a few lanes wide.
+	litWayMatchRadiusM = 15.0
+
+	// A safe vehicle route is ranked by its duration, stretched by these factors for the
+	// share of it that is unlit, or has no lit tag. Unknown counts against a route, but
+	// less than a way OSM says is dark.
+	unlitRoutePenalty      = 0.5
+	unknownLightingPenalty = 0.25
+
+	litWayImportBatchSize = 1000
+)
+
+// litTagValue maps an OSM lit value to "yes" or "no". Values that don't say whether the
+// way is lit at night (e.g. an opening-hours range) are not imported.
+func litTagValue(v string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "24/7", "automatic", "limited", "interval", "sunset-sunrise", "dusk-dawn":
+		return "yes", true
+	case "no", "disused":
+		return "no", true
+	}
+	return "", false
+}
+
+// osmWayID reads a way id as written by osmium ("w123") or Overpass ("way/123"), or a bare
+// number. Nodes and relations are rejected.
+func osmWayID(v interface{}) (int64, bool) {
+	switch id := v.(type) {
+	case float64:
+		return int64(id), id > 0
+	case string:
+		id = strings.TrimPrefix(strings.TrimPrefix(id, "way/"), "w")
+		n, err := strconv.ParseInt(id, 10, 64)
+		return n, err == nil && n > 0
+	}
+	return 0, false
+}
+
+// ReadLitWays decodes a GeoJSON FeatureCollection of OSM ways and passes the LineStrings
+// with a usable lit tag to store in batches. It returns how many ways were stored and
+// how many features were skipped.
+func ReadLitWays(r io.Reader, store func([]model.LitWay) (int64, error)) (stored int64, skipped int, err error) {
+	var fc struct {
+		Features []struct {
+			ID       interface{} `json:"id"`
+			Geometry *struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return 0, 0, fmt.Errorf("decoding GeoJSON: %w", err)
+	}
+
+	batch := make([]model.LitWay, 0, litWayImportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := store(batch)
+		stored += n
+		batch = batch[:0]
+		return err
+	}
+	for _, f := range fc.Features {
+		id, ok := osmWayID(f.ID)
+		if !ok {
+			id, ok = osmWayID(f.Properties["@id"])
+		}
+		if !ok {
+			id, ok = osmWayID(f.Properties["osm_id"])
+		}
+		tag, _ := f.Properties["lit"].(string)
+		lit, litOK := litTagValue(tag)
+		var coords [][]float64
+		if !ok || !litOK || f.Geometry == nil || f.Geometry.Type != "LineString" ||
+			json.Unmarshal(f.Geometry.Coordinates, &coords) != nil || !validLineString(coords) {
+			skipped++
+			continue
+		}
+		batch = append(batch, model.LitWay{OSMWayID: id, Lit: lit, Coordinates: coords})
+		if len(batch) == litWayImportBatchSize {
+			if err := flush(); err != nil {
+				return stored, skipped, err
+			}
+		}
+	}
+	return stored, skipped, flush()
+}
+
+func validLineString(coords [][]float64) bool {
+	if len(coords) < 2 {
+		return false
+	}
+	for _, c := range coords {
+		if len(c) != 2 || c[0] < -180 || c[0] > 180 || c[1] < -90 || c[1] > 90 {
+			return false
+		}
+	}
+	return true
+}
+
+// safeRouteScore is the duration a safe vehicle route is ranked by: lower is better.
+func safeRouteScore(route mapbox.Route) float64 {
+	var lit, unlit float64
+	if route.Lighting != nil {
+		lit, unlit = route.Lighting.LitShare, route.Lighting.UnlitShare
+	}
+	unknown := max(0, 1-lit-unlit)
+	return route.Duration * (1 + unlitRoutePenalty*unlit + unknownLightingPenalty*unknown)
+}
+
+// orderRoutesByLighting puts the best lit routes first, weighed against their duration. If
+// none of the routes has lit data the provider's order is kept.
+func orderRoutesByLighting(routes []mapbox.Route) {
+	known := slices.ContainsFunc(routes, func(r mapbox.Route) bool {
+		return r.Lighting != nil && r.Lighting.LitShare+r.Lighting.UnlitShare > 0
+	})
+	if !known {
+		return
+	}
+	slices.SortStableFunc(routes, func(a, b mapbox.Route) int {
+		sa, sb := safeRouteScore(a), safeRouteScore(b)
+		switch {
+		case sa < sb:
+			return -1
+		case sa > sb:
+			return 1
+		}
+		return 0
+	})
+}
+
+// preferLitRoutes matches each route to the imported lit ways, sets Route.Lighting and
+// reorders the routes so the best lit one comes first. Without the database, or if the
+// lookup fails, the routes are left as they are.
+func (api *API) preferLitRoutes(ctx context.Context, resp *mapbox.DirectionsResponse) {
+	if resp == nil || !api.dbAvailable() {
+		return
+	}
+	lighting := make([]*mapbox.RouteLighting, len(resp.Routes))
+	for i, route := range resp.Routes {
+		if len(route.Geometry.Coordinates) < 2 {
+			continue
+		}
+		l, err := api.GetRouteLightingRepo(ctx, route.Geometry.Coordinates, litSampleSpacingM, maxLitSamples, litWayMatchRadiusM)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Route lighting unavailable", "err", err)
+			return
+		}
+		lighting[i] = &l
+	}
+	for i := range resp.Routes {
+		resp.Routes[i].Lighting = lighting[i]
+	}
+	orderRoutesByLighting(resp.Routes)
+}
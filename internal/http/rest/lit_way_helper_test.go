@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestReadLitWays(t *testing.T) {
+	geojson := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","id":"w10","geometry":{"type":"LineString","coordinates":[[33.3,35.1],[33.31,35.11]]},"properties":{"lit":"24/7"}},
+		{"type":"Feature","geometry":{"type":"LineString","coordinates":[[33.3,35.1],[33.31,35.11]]},"properties":{"@id":"way/11","lit":"no"}},
+		{"type":"Feature","id":"n12","geometry":{"type":"Point","coordinates":[33.3,35.1]},"properties":{"lit":"yes"}},
+		{"type":"Feature","id":"w13","geometry":{"type":"LineString","coordinates":[[33.3,35.1],[33.31,35.11]]},"properties":{"lit":"18:00-24:00"}},
+		{"type":"Feature","id":"w14","geometry":{"type":"LineString","coordinates":[[33.3],[33.31,35.11]]},"properties":{"lit":"yes"}}
+	]}`
+	var got []model.LitWay
+	stored, skipped, err := ReadLitWays(strings.NewReader(geojson), func(ways []model.LitWay) (int64, error) {
+		got = append(got, ways...)
+		return int64(len(ways)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != 2 || skipped != 3 {
+		t.Errorf("stored %d, skipped %d; want 2 and 3", stored, skipped)
+	}
+	if len(got) != 2 || got[0].OSMWayID != 10 || got[0].Lit != "yes" || got[1].OSMWayID != 11 || got[1].Lit != "no" {
+		t.Errorf("ways = %+v", got)
+	}
+}
+
+func TestOrderRoutesByLighting(t *testing.T) {
+	routes := []mapbox.Route{
+		{Duration: 600, Lighting: &mapbox.RouteLighting{LitShare: 0.2, UnlitShare: 0.8}},
+		{Duration: 660, Lighting: &mapbox.RouteLighting{LitShare: 1}},
+		{Duration: 900, Lighting: &mapbox.RouteLighting{LitShare: 1}},
+	}
+	orderRoutesByLighting(routes)
+	if routes[0].Duration != 660 || routes[1].Duration != 600 || routes[2].Duration != 900 {
+		t.Errorf("order = %v, %v, %v; want the lit 660 s route first", routes[0].Duration, routes[1].Duration, routes[2].Duration)
+	}
+
+	// Without any lit data the provider's order stands.
+	routes = []mapbox.Route{{Duration: 700}, {Duration: 600, Lighting: &mapbox.RouteLighting{}}}
+	orderRoutesByLighting(routes)
+	if routes[0].Duration != 700 {
+		t.Errorf("routes without lit data were reordered")
+	}
+}
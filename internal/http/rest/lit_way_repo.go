@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// UpsertLitWaysRepo stores the ways' lit tag and geometry, replacing what an earlier import
+// stored for the same OSM ways. It returns the number of rows written.
+func (api *API) UpsertLitWaysRepo(ctx context.Context, ways []model.LitWay) (int64, error) {
+	ids := make([]int64, len(ways))
+	lit := make([]string, len(ways))
+	geoms := make([]string, len(ways))
+	for i, w := range ways {
+		geojson, err := lineStringGeoJSON(w.Coordinates)
+		if err != nil {
+			return 0, err
+		}
+		ids[i], lit[i], geoms[i] = w.OSMWayID, w.Lit, geojson
+	}
+	tag, err := api.DB.Exec(ctx, `
+		INSERT INTO lit_ways (osm_way_id, lit, geom)
+		SELECT id, lit, ST_SetSRID(ST_GeomFromGeoJSON(geom), 4326)
+		FROM unnest($1::bigint[], $2::text[], $3::text[]) AS w(id, lit, geom)
+		ON CONFLICT (osm_way_id) DO UPDATE SET
+			lit = EXCLUDED.lit,
+			geom = EXCLUDED.geom,
+			imported_at = NOW()
+	`, ids, lit, geoms)
+	if err != nil {
+		return 0, fmt.Errorf("upserting lit ways: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetRouteLightingRepo samples the route every spacingM meters (wider on long routes, so
+// at most maxSamples points) and matches each sample to the nearest lit_ways way within
+// radiusM. It returns the shares of samples on lit and on unlit ways.
+func (api *API) GetRouteLightingRepo(ctx context.Context, geometry [][]float64, spacingM float64, maxSamples int, radiusM float64) (mapbox.RouteLighting, error) {
+	geojson, err := lineStringGeoJSON(geometry)
+	if err != nil {
+		return mapbox.RouteLighting{}, err
+	}
+	query := `
+        WITH route AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326)::geography AS geog
+        ),
+        samples AS (
+            SELECT (ST_Dump(ST_LineInterpolatePoints(route.geog,
+                        LEAST(1.0, GREATEST($2, ST_Length(route.geog) / $3) / GREATEST(ST_Length(route.geog), 1.0)),
+                        true, true)::geometry)).geom AS pt
+            FROM route
+        )
+        SELECT
+            COUNT(*),
+            COUNT(*) FILTER (WHERE nearest.lit = 'yes'),
+            COUNT(*) FILTER (WHERE nearest.lit = 'no')
+        FROM samples
+        LEFT JOIN LATERAL (
+            SELECT w.lit
+            FROM lit_ways w
+            WHERE ST_DWithin(w.geom::geography, samples.pt::geography, $4)
+            ORDER BY w.geom::geography <-> samples.pt::geography
+            LIMIT 1
+        ) nearest ON TRUE
+    `
+	var total, lit, unlit int
+	if err := api.DB.QueryRow(ctx, query, geojson, spacingM, maxSamples, radiusM).Scan(&total, &lit, &unlit); err != nil {
+		return mapbox.RouteLighting{}, fmt.Errorf("querying route lighting: %w", err)
+	}
+	if total == 0 {
+		return mapbox.RouteLighting{}, nil
+	}
+	return mapbox.RouteLighting{
+		LitShare:   float64(lit) / float64(total),
+		UnlitShare: float64(unlit) / float64(total),
+	}, nil
+}
@@ -0,0 +1,12 @@
+package rest
+
+import "log/slog"
+
+// logger is the API's structured logger. Log with the request's context (the *Context
+// methods) so records carry its request ID, user and route.
+func (api *API) logger() *slog.Logger {
+	if api.Logger != nil {
+		return api.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) MapFeatureRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	// Speed cameras, speed bumps and railway crossings near a point; no login needed
+	// Query Params: ?latitude=35.18&longitude=33.36&radius=2000 (max 10000) &type=speed_camera (repeatable)
+	mux.Method(http.MethodGet, "/nearby", Handler(api.GetNearbyMapFeaturesHandler))
+
+	return mux
+}
+
+func (api *API) GetNearbyMapFeaturesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+
+	latitude, err := strconv.ParseFloat(q.Get("latitude"), 64)
+	if err != nil {
+		return respondWithError(err, "invalid latitude", values.BadRequestBody, &tc)
+	}
+	longitude, err := strconv.ParseFloat(q.Get("longitude"), 64)
+	if err != nil {
+		return respondWithError(err, "invalid longitude", values.BadRequestBody, &tc)
+	}
+	radius, _ := strconv.ParseFloat(q.Get("radius"), 64)
+
+	features, status, message, err := api.NearbyMapFeaturesHelper(r.Context(), latitude, longitude, radius, q["type"])
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       features,
+	}
+}
@@ -0,0 +1,173 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// Map feature types.
+const (
+	MapFeatureSpeedCamera     = "speed_camera"
+	MapFeatureSpeedBump       = "speed_bump"
+	MapFeatureRailwayCrossing = "railway_crossing"
+)
+
+const (
+	defaultMapFeaturesPageSize = 50
+	maxMapFeaturesPageSize     = 500
+
+	defaultNearbyMapFeatureRadiusM = 2000.0
+	maxNearbyMapFeatureRadiusM     = 10000.0
+	maxNearbyMapFeatures           = 200
+
+	// Fixed features sit on the road, so only those this close to a route are on it.
+	routeMapFeatureRadiusM = 30
+	maxRouteMapFeatures    = 200
+	// mapFeatureBearingToleranceDeg is how far the direction of travel may be from a
+	// feature's bearing for the feature to apply.
+	mapFeatureBearingToleranceDeg = 45.0
+)
+
+var mapFeatureTypes = []string{MapFeatureSpeedCamera, MapFeatureSpeedBump, MapFeatureRailwayCrossing}
+
+func (api *API) ListMapFeaturesHelper(ctx context.Context, featureType string, page, pageSize int) ([]model.MapFeature, string, string, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultMapFeaturesPageSize
+	}
+	pageSize = min(pageSize, maxMapFeaturesPageSize)
+
+	features, err := api.ListMapFeaturesRepo(ctx, featureType, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch map features", err
+	}
+	if features == nil {
+		features = []model.MapFeature{}
+	}
+	return features, values.Success, "Map features fetched successfully", nil
+}
+
+func (api *API) CreateMapFeatureHelper(ctx context.Context, req model.MapFeatureRequest, adminID uuid.UUID) (model.MapFeature, string, string, error) {
+	if req.SpeedLimitKmh != nil && req.Type != MapFeatureSpeedCamera {
+		return model.MapFeature{}, values.BadRequestBody, "Only speed cameras have a speed limit", errors.New("speed limit on non-camera map feature")
+	}
+	active := req.Active == nil || *req.Active
+	f, err := api.CreateMapFeatureRepo(ctx, req, active, adminID)
+	if err != nil {
+		return model.MapFeature{}, values.Error, "Failed to create map feature", err
+	}
+	return f, values.Created, "Map feature created", nil
+}
+
+func (api *API) UpdateMapFeatureHelper(ctx context.Context, id int64, req model.MapFeatureRequest) (model.MapFeature, string, string, error) {
+	if req.SpeedLimitKmh != nil && req.Type != MapFeatureSpeedCamera {
+		return model.MapFeature{}, values.BadRequestBody, "Only speed cameras have a speed limit", errors.New("speed limit on non-camera map feature")
+	}
+	active := req.Active == nil || *req.Active
+	f, err := api.UpdateMapFeatureRepo(ctx, id, req, active)
+	if err != nil {
+		if errors.Is(err, ErrMapFeatureNotFound) {
+			return model.MapFeature{}, values.NotFound, "Map feature not found", err
+		}
+		return model.MapFeature{}, values.Error, "Failed to update map feature", err
+	}
+	return f, values.Success, "Map feature updated", nil
+}
+
+func (api *API) DeleteMapFeatureHelper(ctx context.Context, id int64) (string, string, error) {
+	if err := api.DeleteMapFeatureRepo(ctx, id); err != nil {
+		if errors.Is(err, ErrMapFeatureNotFound) {
+			return values.NotFound, "Map feature not found", err
+		}
+		return values.Error, "Failed to delete map feature", err
+	}
+	return values.Success, "Map feature deleted", nil
+}
+
+func (api *API) NearbyMapFeaturesHelper(ctx context.Context, lat, lng, radiusM float64, types []string) ([]model.MapFeature, string, string, error) {
+	lat, lng, err := util.NormalizeCoordinate(lat, lng)
+	if err != nil {
+		return nil, values.BadRequestBody, "Invalid coordinates", err
+	}
+	if radiusM <= 0 {
+		radiusM = defaultNearbyMapFeatureRadiusM
+	}
+	radiusM = min(radiusM, maxNearbyMapFeatureRadiusM)
+	for _, t := range types {
+		if !slices.Contains(mapFeatureTypes, t) {
+			return nil, values.BadRequestBody, "type must be speed_camera, speed_bump or railway_crossing", errors.New("unknown map feature type " + t)
+		}
+	}
+
+	features, err := api.NearbyMapFeaturesRepo(ctx, lat, lng, radiusM, types, maxNearbyMapFeatures)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch map features", err
+	}
+	if features == nil {
+		features = []model.MapFeature{}
+	}
+	return features, values.Success, "Map features fetched successfully", nil
+}
+
+// addRouteMapFeatures attaches the fixed hazards on each route that apply in its
+// direction of travel, so the app can warn ahead of them. Like route reports, lookup
+// errors just leave the routes without them.
+func (api *API) addRouteMapFeatures(ctx context.Context, resp *mapbox.DirectionsResponse) {
+	if resp == nil || !api.dbAvailable() {
+		return
+	}
+	for i := range resp.Routes {
+		route := &resp.Routes[i]
+		if len(route.Geometry.Coordinates) < 2 {
+			continue
+		}
+		features, err := api.GetMapFeaturesAlongRouteRepo(ctx, route.Geometry.Coordinates, routeMapFeatureRadiusM, maxRouteMapFeatures)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Route map features unavailable", "err", err)
+			return
+		}
+		route.MapFeatures = featuresInTravelDirection(route.Geometry.Coordinates, features)
+	}
+}
+
+// featuresInTravelDirection keeps the features without a bearing and those whose bearing
+// matches the route's heading where it passes them.
+func featuresInTravelDirection(coords [][]float64, features []mapbox.RouteMapFeature) []mapbox.RouteMapFeature {
+	var out []mapbox.RouteMapFeature
+	for _, f := range features {
+		if f.Bearing == nil || bearingDiff(*f.Bearing, routeBearingAt(coords, f.DistanceAlongM)) <= mapFeatureBearingToleranceDeg {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// routeBearingAt returns the heading of the route ([lng, lat] pairs) alongM meters from
+// its start, clamped to the first and last segments.
+func routeBearingAt(coords [][]float64, alongM float64) float64 {
+	var travelled float64
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		travelled += util.HaversineMeters(a[1], a[0], b[1], b[0])
+		if travelled >= alongM || i == len(coords)-1 {
+			return initialBearing(a, b)
+		}
+	}
+	return 0
+}
+
+// bearingDiff is the angle between two bearings, in [0, 180].
+func bearingDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+)
+
+func TestFeaturesInTravelDirection(t *testing.T) {
+	// ~1.1 km north, then ~0.9 km east.
+	route := [][]float64{{33.36, 35.18}, {33.36, 35.19}, {33.37, 35.19}}
+	bearing := func(b float64) *float64 { return &b }
+	features := []mapbox.RouteMapFeature{
+		{ID: 1, Type: MapFeatureSpeedBump, DistanceAlongM: 500},                             // both directions
+		{ID: 2, Type: MapFeatureSpeedCamera, DistanceAlongM: 500, Bearing: bearing(10)},     // northbound
+		{ID: 3, Type: MapFeatureSpeedCamera, DistanceAlongM: 500, Bearing: bearing(180)},    // southbound
+		{ID: 4, Type: MapFeatureSpeedCamera, DistanceAlongM: 1500, Bearing: bearing(80)},    // eastbound
+		{ID: 5, Type: MapFeatureRailwayCrossing, DistanceAlongM: 1500, Bearing: bearing(0)}, // northbound, on the east leg
+		{ID: 6, Type: MapFeatureSpeedCamera, DistanceAlongM: 5000, Bearing: bearing(355)},   // past the end: last leg
+	}
+
+	got := featuresInTravelDirection(route, features)
+	var ids []int64
+	for _, f := range got {
+		ids = append(ids, f.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 4 {
+		t.Errorf("kept features %v, want [1 2 4]", ids)
+	}
+}
+
+func TestBearingDiff(t *testing.T) {
+	for _, tc := range []struct{ a, b, want float64 }{
+		{0, 90, 90},
+		{350, 10, 20},
+		{10, 350, 20},
+		{0, 180, 180},
+		{720, 0, 0},
+	} {
+		if got := bearingDiff(tc.a, tc.b); got != tc.want {
+			t.Errorf("bearingDiff(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
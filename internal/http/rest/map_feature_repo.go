@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrMapFeatureNotFound = fmt.Errorf("map feature %w", ErrNotFound)
+
+const mapFeatureColumns = `
+	id, type, ST_Y(position), ST_X(position), bearing, speed_limit_kmh,
+	name, notes, active, created_by, created_at, updated_at
+`
+
+func scanMapFeature(row pgx.Row, extra ...any) (model.MapFeature, error) {
+	var f model.MapFeature
+	dest := []any{&f.ID, &f.Type, &f.Latitude, &f.Longitude, &f.Bearing, &f.SpeedLimitKmh,
+		&f.Name, &f.Notes, &f.Active, &f.CreatedBy, &f.CreatedAt, &f.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.MapFeature{}, ErrMapFeatureNotFound
+	}
+	return f, err
+}
+
+// ListMapFeaturesRepo returns features for the admin list, newest first. An empty
+// featureType lists every type.
+func (api *API) ListMapFeaturesRepo(ctx context.Context, featureType string, limit, offset int) ([]model.MapFeature, error) {
+	query := `
+        SELECT ` + mapFeatureColumns + `
+        FROM map_features
+        WHERE ($1 = '' OR type = $1)
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3
+    `
+	rows, err := api.DB.Query(ctx, query, featureType, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying map features: %w", err)
+	}
+	defer rows.Close()
+
+	var features []model.MapFeature
+	for rows.Next() {
+		f, err := scanMapFeature(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning map feature: %w", err)
+		}
+		features = append(features, f)
+	}
+	return features, rows.Err()
+}
+
+func (api *API) GetMapFeatureRepo(ctx context.Context, id int64) (model.MapFeature, error) {
+	query := `SELECT ` + mapFeatureColumns + ` FROM map_features WHERE id = $1`
+	return scanMapFeature(api.DB.QueryRow(ctx, query, id))
+}
+
+func (api *API) CreateMapFeatureRepo(ctx context.Context, req model.MapFeatureRequest, active bool, createdBy uuid.UUID) (model.MapFeature, error) {
+	query := `
+        INSERT INTO map_features (type, position, bearing, speed_limit_kmh, name, notes, active, created_by)
+        VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), $4, $5, $6, $7, $8, $9)
+        RETURNING ` + mapFeatureColumns
+	return scanMapFeature(api.DB.QueryRow(ctx, query,
+		req.Type, req.Longitude, req.Latitude, req.Bearing, req.SpeedLimitKmh,
+		req.Name, req.Notes, active, createdBy))
+}
+
+func (api *API) UpdateMapFeatureRepo(ctx context.Context, id int64, req model.MapFeatureRequest, active bool) (model.MapFeature, error) {
+	query := `
+        UPDATE map_features
+        SET type = $2, position = ST_SetSRID(ST_MakePoint($3, $4), 4326), bearing = $5,
+            speed_limit_kmh = $6, name = $7, notes = $8, active = $9, updated_at = NOW()
+        WHERE id = $1
+        RETURNING ` + mapFeatureColumns
+	return scanMapFeature(api.DB.QueryRow(ctx, query, id,
+		req.Type, req.Longitude, req.Latitude, req.Bearing, req.SpeedLimitKmh,
+		req.Name, req.Notes, active))
+}
+
+func (api *API) DeleteMapFeatureRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM map_features WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrMapFeatureNotFound
+	}
+	return nil
+}
+
+// NearbyMapFeaturesRepo returns active features of the given types (all when empty)
+// within radiusM of the point, nearest first.
+func (api *API) NearbyMapFeaturesRepo(ctx context.Context, lat, lng, radiusM float64, types []string, limit int) ([]model.MapFeature, error) {
+	query := `
+        SELECT ` + mapFeatureColumns + `,
+            ST_Distance(position::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance_m
+        FROM map_features
+        WHERE active
+          AND ST_DWithin(position::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+          AND (cardinality($4::text[]) = 0 OR type = ANY($4))
+        ORDER BY distance_m
+        LIMIT $5
+    `
+	rows, err := api.DB.Query(ctx, query, lng, lat, radiusM, types, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying nearby map features: %w", err)
+	}
+	defer rows.Close()
+
+	var features []model.MapFeature
+	for rows.Next() {
+		var distance float64
+		f, err := scanMapFeature(rows, &distance)
+		if err != nil {
+			return nil, fmt.Errorf("scanning nearby map feature: %w", err)
+		}
+		f.DistanceM = &distance
+		features = append(features, f)
+	}
+	return features, rows.Err()
+}
+
+// GetMapFeaturesAlongRouteRepo returns active features within radiusM of the route,
+// ordered by how far along the route they are.
+func (api *API) GetMapFeaturesAlongRouteRepo(ctx context.Context, geometry [][]float64, radiusM, limit int) ([]mapbox.RouteMapFeature, error) {
+	geojson, err := lineStringGeoJSON(geometry)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        WITH route AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) AS geom
+        )
+        SELECT
+            f.id, f.type, ST_Y(f.position), ST_X(f.position), f.speed_limit_kmh, f.bearing,
+            ST_LineLocatePoint(route.geom, f.position) * ST_Length(route.geom::geography) AS along_m
+        FROM map_features f, route
+        WHERE f.active
+          AND ST_DWithin(f.position::geography, route.geom::geography, $2)
+        ORDER BY along_m
+        LIMIT $3
+    `
+	rows, err := api.DB.Query(ctx, query, geojson, radiusM, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying map features along route: %w", err)
+	}
+	defer rows.Close()
+
+	var features []mapbox.RouteMapFeature
+	for rows.Next() {
+		var f mapbox.RouteMapFeature
+		if err := rows.Scan(&f.ID, &f.Type, &f.Latitude, &f.Longitude, &f.SpeedLimitKmh, &f.Bearing,
+			&f.DistanceAlongM); err != nil {
+			return nil, fmt.Errorf("scanning map feature along route: %w", err)
+		}
+		features = append(features, f)
+	}
+	return features, rows.Err()
+}
@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// Map issue statuses. Exported is only set by the OSM notes export.
+const (
+	MapIssueOpen     = "open"
+	MapIssueAccepted = "accepted"
+	MapIssueRejected = "rejected"
+	MapIssueFixed    = "fixed"
+	MapIssueExported = "exported"
+)
+
+const mapIssueExportMax = 500
+
+// mapIssueCategoryLabels name each category in OSM notes and pushes.
+var mapIssueCategoryLabels = map[string]string{
+	"missing_road":     "Missing road",
+	"wrong_one_way":    "Wrong one-way direction",
+	"speed_limit":      "Wrong speed limit",
+	"turn_restriction": "Wrong turn restriction",
+	"road_name":        "Wrong road name",
+	"road_closed":      "Road permanently closed",
+	"other":            "Map error",
+}
+
+// mapIssuePushText is what the reporter is told when their issue changes status.
+var mapIssuePushText = map[string]string{
+	MapIssueOpen:     "Your map report has been reopened for review.",
+	MapIssueAccepted: "Thanks! Your map report was confirmed and will be fixed.",
+	MapIssueRejected: "Your map report was reviewed and no change is needed.",
+	MapIssueFixed:    "The map error you reported has been fixed.",
+	MapIssueExported: "Your map report was sent to OpenStreetMap for fixing.",
+}
+
+func (api *API) CreateMapIssueHelper(ctx context.Context, userID uuid.UUID, req model.CreateMapIssueRequest) (model.MapIssue, string, string, error) {
+	req.Description = strings.TrimSpace(req.Description)
+	issue, err := api.CreateMapIssueRepo(ctx, userID, req)
+	if err != nil {
+		return model.MapIssue{}, values.Error, "Failed to report map issue", err
+	}
+	return issue, values.Created, "Map issue reported", nil
+}
+
+func (api *API) ListUserMapIssuesHelper(ctx context.Context, userID uuid.UUID) ([]model.MapIssue, string, string, error) {
+	issues, err := api.ListUserMapIssuesRepo(ctx, userID, 100)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch map issues", err
+	}
+	if issues == nil {
+		issues = []model.MapIssue{}
+	}
+	return issues, values.Success, "Map issues fetched successfully", nil
+}
+
+func (api *API) ListMapIssuesHelper(ctx context.Context, status, limitStr string) ([]model.MapIssue, string, string, error) {
+	switch status {
+	case "", MapIssueOpen, MapIssueAccepted, MapIssueRejected, MapIssueFixed, MapIssueExported:
+	default:
+		return nil, values.BadRequestBody, "status must be open, accepted, rejected, fixed or exported", fmt.Errorf("invalid status %q", status)
+	}
+	limit := 50
+	if limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > 200 {
+			return nil, values.BadRequestBody, "limit must be between 1 and 200", fmt.Errorf("invalid limit %q", limitStr)
+		}
+		limit = n
+	}
+
+	issues, err := api.ListMapIssuesRepo(ctx, status, limit)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch map issues", err
+	}
+	if issues == nil {
+		issues = []model.MapIssue{}
+	}
+	return issues, values.Success, "Map issues fetched successfully", nil
+}
+
+func (api *API) UpdateMapIssueStatusHelper(ctx context.Context, id int64, req model.UpdateMapIssueStatusRequest, adminID uuid.UUID) (model.MapIssue, string, string, error) {
+	issue, err := api.UpdateMapIssueStatusRepo(ctx, id, req, adminID)
+	if err != nil {
+		if errors.Is(err, ErrMapIssueNotFound) {
+			return model.MapIssue{}, values.NotFound, "Map issue not found", err
+		}
+		return model.MapIssue{}, values.Error, "Failed to update map issue", err
+	}
+	api.NotifyOutbox()
+	return issue, values.Success, "Map issue " + issue.Status, nil
+}
+
+// ExportMapIssuesHelper marks accepted issues exported and returns them as OSM notes for
+// whoever files them with OpenStreetMap.
+func (api *API) ExportMapIssuesHelper(ctx context.Context, req model.ExportMapIssuesRequest) ([]model.OSMNote, string, string, error) {
+	issues, err := api.ExportMapIssuesRepo(ctx, req.IDs, mapIssueExportMax)
+	if err != nil {
+		return nil, values.Error, "Failed to export map issues", err
+	}
+	notes := make([]model.OSMNote, len(issues))
+	for i, issue := range issues {
+		notes[i] = osmNote(issue)
+	}
+	if len(notes) > 0 {
+		api.NotifyOutbox()
+	}
+	return notes, values.Success, fmt.Sprintf("%d map issues exported", len(notes)), nil
+}
+
+// osmNote writes an issue the way OSM mappers expect a note: what is wrong, the reporter's
+// words, and a reference back to the issue.
+func osmNote(issue model.MapIssue) model.OSMNote {
+	label, ok := mapIssueCategoryLabels[issue.Category]
+	if !ok {
+		label = mapIssueCategoryLabels["other"]
+	}
+	text := label + ": " + issue.Description
+	if issue.AdminNote != nil && *issue.AdminNote != "" {
+		text += "\n\nReviewer: " + *issue.AdminNote
+	}
+	text += fmt.Sprintf("\n\n(map issue #%d, reported %s)", issue.ID, issue.CreatedAt.UTC().Format("2006-01-02"))
+	return model.OSMNote{MapIssueID: issue.ID, Lat: issue.Latitude, Lon: issue.Longitude, Text: text}
+}
+
+// publishMapIssueStatusChanged pushes the status change to the reporter.
+func (api *API) publishMapIssueStatusChanged(ctx context.Context, eventID string, p model.MapIssueStatusChangedEvent) error {
+	body, ok := mapIssuePushText[p.Status]
+	if !ok {
+		return nil
+	}
+	data := map[string]string{
+		"type":         OutboxEventMapIssueStatusChanged,
+		"map_issue_id": strconv.FormatInt(p.MapIssueID, 10),
+		"status":       p.Status,
+		"event_id":     eventID,
+	}
+	return api.SendFCMToUser(ctx, p.UserID.String(), "Map report update", body, data)
+}
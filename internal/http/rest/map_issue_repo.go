@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrMapIssueNotFound = fmt.Errorf("map issue %w", ErrNotFound)
+
+const mapIssueColumns = `
+	id, user_id, category, ST_Y(location), ST_X(location), description, status,
+	admin_note, reviewed_by, reviewed_at, exported_at, created_at, updated_at
+`
+
+func scanMapIssue(row pgx.Row) (model.MapIssue, error) {
+	var m model.MapIssue
+	err := row.Scan(&m.ID, &m.UserID, &m.Category, &m.Latitude, &m.Longitude, &m.Description, &m.Status,
+		&m.AdminNote, &m.ReviewedBy, &m.ReviewedAt, &m.ExportedAt, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+func collectMapIssues(rows pgx.Rows) ([]model.MapIssue, error) {
+	defer rows.Close()
+	var issues []model.MapIssue
+	for rows.Next() {
+		m, err := scanMapIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, m)
+	}
+	return issues, rows.Err()
+}
+
+func (api *API) CreateMapIssueRepo(ctx context.Context, userID uuid.UUID, req model.CreateMapIssueRequest) (model.MapIssue, error) {
+	query := `
+        INSERT INTO map_issues (user_id, category, location, description)
+        VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326), $5)
+        RETURNING ` + mapIssueColumns
+	return scanMapIssue(api.DB.QueryRow(ctx, query, userID, req.Category, req.Longitude, req.Latitude, req.Description))
+}
+
+func (api *API) ListUserMapIssuesRepo(ctx context.Context, userID uuid.UUID, limit int) ([]model.MapIssue, error) {
+	rows, err := api.DB.Query(ctx, `SELECT `+mapIssueColumns+` FROM map_issues WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectMapIssues(rows)
+}
+
+// ListMapIssuesRepo returns issues for triage, oldest first so the queue is worked in
+// order. An empty status lists every status.
+func (api *API) ListMapIssuesRepo(ctx context.Context, status string, limit int) ([]model.MapIssue, error) {
+	query := `
+        SELECT ` + mapIssueColumns + `
+        FROM map_issues
+        WHERE ($1 = '' OR status = $1)
+        ORDER BY created_at
+        LIMIT $2
+    `
+	rows, err := api.DB.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectMapIssues(rows)
+}
+
+// UpdateMapIssueStatusRepo records an admin's triage decision and, when the status
+// actually changed and the reporter still exists, queues a notification for them.
+func (api *API) UpdateMapIssueStatusRepo(ctx context.Context, id int64, req model.UpdateMapIssueStatusRequest, adminID uuid.UUID) (model.MapIssue, error) {
+	query := `
+        UPDATE map_issues
+        SET status = $2,
+            admin_note = COALESCE($3, admin_note),
+            reviewed_by = $4,
+            reviewed_at = NOW(),
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING ` + mapIssueColumns
+	var issue model.MapIssue
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var previous string
+		err := tx.QueryRow(ctx, `SELECT status FROM map_issues WHERE id = $1 FOR UPDATE`, id).Scan(&previous)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMapIssueNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if issue, err = scanMapIssue(tx.QueryRow(ctx, query, id, req.Status, req.AdminNote, adminID)); err != nil {
+			return err
+		}
+		if previous == issue.Status {
+			return nil
+		}
+		return queueMapIssueStatusChanged(ctx, tx, issue)
+	})
+	return issue, err
+}
+
+// ExportMapIssuesRepo marks accepted issues exported and returns them, queueing a
+// notification for each reporter. With no ids it takes every accepted issue, up to limit.
+func (api *API) ExportMapIssuesRepo(ctx context.Context, ids []int64, limit int) ([]model.MapIssue, error) {
+	query := `
+        UPDATE map_issues
+        SET status = 'exported', exported_at = NOW(), updated_at = NOW()
+        WHERE id IN (
+            SELECT id FROM map_issues
+            WHERE status = 'accepted' AND (cardinality($1::bigint[]) = 0 OR id = ANY($1))
+            ORDER BY created_at
+            LIMIT $2
+            FOR UPDATE
+        )
+        RETURNING ` + mapIssueColumns
+	if ids == nil {
+		ids = []int64{}
+	}
+	var issues []model.MapIssue
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, query, ids, limit)
+		if err != nil {
+			return err
+		}
+		if issues, err = collectMapIssues(rows); err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			if err := queueMapIssueStatusChanged(ctx, tx, issue); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return issues, err
+}
+
+func queueMapIssueStatusChanged(ctx context.Context, tx pgx.Tx, issue model.MapIssue) error {
+	if issue.UserID == nil {
+		return nil
+	}
+	id := strconv.FormatInt(issue.ID, 10)
+	return insertOutboxEvent(ctx, tx, OutboxEventMapIssueStatusChanged, id,
+		OutboxEventMapIssueStatusChanged+":"+id+":"+issue.Status+":"+strconv.FormatInt(issue.UpdatedAt.UnixNano(), 10),
+		model.MapIssueStatusChangedEvent{
+			MapIssueID: issue.ID,
+			UserID:     *issue.UserID,
+			Category:   issue.Category,
+			Status:     issue.Status,
+			AdminNote:  issue.AdminNote,
+			ChangedAt:  issue.UpdatedAt,
+		})
+}
@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// maxMatrixPairs caps sources × targets per request.
+const maxMatrixPairs = 100
+
+// MatrixRequest asks for the travel time from each source to each target, e.g. from the
+// user's position to their saved places or to parking options.
+type MatrixRequest struct {
+	Sources    []Location `json:"sources" validate:"min=1,max=10"`
+	Targets    []Location `json:"targets" validate:"min=1,max=50"`
+	Profile    string     `json:"profile,omitempty" validate:"omitempty,oneof=driving driving-traffic walking cycling motorcycle"`
+	Preference string     `json:"preference,omitempty" validate:"omitempty,oneof=fastest safe"`
+}
+
+// MatrixResponse has a row per source with a value per target; values are null when the
+// target can't be reached.
+type MatrixResponse struct {
+	Durations [][]*float64 `json:"durations"` // seconds
+	Distances [][]*float64 `json:"distances"` // meters
+	// Ranked lists, per source, the indices of the reachable targets, nearest by travel
+	// time first.
+	Ranked [][]int `json:"ranked"`
+}
+
+// GetMatrixHelper returns the travel times and distances between the sources and targets.
+func (api *API) GetMatrixHelper(ctx context.Context, req MatrixRequest) (MatrixResponse, string, string, error) {
+	if api.ValhallaClient == nil {
+		return MatrixResponse{}, values.Unavailable, "Travel time matrices are not available", errors.New("valhalla client not configured")
+	}
+	if len(req.Sources)*len(req.Targets) > maxMatrixPairs {
+		return MatrixResponse{}, values.BadRequestBody, fmt.Sprintf("at most %d source-target pairs can be requested", maxMatrixPairs),
+			errors.New("too many matrix pairs")
+	}
+	sources, err := matrixLocations(req.Sources)
+	if err != nil {
+		return MatrixResponse{}, values.BadRequestBody, "sources: " + err.Error(), err
+	}
+	targets, err := matrixLocations(req.Targets)
+	if err != nil {
+		return MatrixResponse{}, values.BadRequestBody, "targets: " + err.Error(), err
+	}
+
+	costing := valhallaCosting(req.Profile)
+	options, _ := valhallaCostingOptions(costing, req.Preference, "")
+	matrix, err := api.ValhallaClient.SourcesToTargets(ctx, valhalla.MatrixRequest{
+		Sources:        sources,
+		Targets:        targets,
+		Costing:        costing,
+		CostingOptions: options,
+	})
+	var verr *valhalla.Error
+	if errors.As(err, &verr) && verr.NoPath() {
+		return MatrixResponse{}, values.Unprocessable, "No roads found near one of the locations", err
+	}
+	if err != nil {
+		return MatrixResponse{}, values.Error, "Failed to compute travel times", err
+	}
+	return matrixResponse(matrix, len(sources), len(targets)), values.Success, "Travel times computed", nil
+}
+
+func matrixLocations(locations []Location) ([]valhalla.Location, error) {
+	out := make([]valhalla.Location, len(locations))
+	for i, loc := range locations {
+		lat, lng, err := util.NormalizeCoordinate(loc.Lat, loc.Lng)
+		if err != nil {
+			return nil, fmt.Errorf("location %d: invalid latitude or longitude", i)
+		}
+		out[i] = valhalla.Location{Lat: lat, Lon: lng}
+	}
+	return out, nil
+}
+
+// matrixResponse lays Valhalla's cells out by source and target index, in meters, and
+// ranks each source's reachable targets.
+func matrixResponse(m *valhalla.MatrixResponse, sources, targets int) MatrixResponse {
+	metersPer := 1000.0
+	if m.Units == "miles" {
+		metersPer = 1609.344
+	}
+	resp := MatrixResponse{
+		Durations: make([][]*float64, sources),
+		Distances: make([][]*float64, sources),
+		Ranked:    make([][]int, sources),
+	}
+	for i := range sources {
+		resp.Durations[i] = make([]*float64, targets)
+		resp.Distances[i] = make([]*float64, targets)
+	}
+	for _, row := range m.SourcesToTargets {
+		for _, c := range row {
+			if c.FromIndex < 0 || c.FromIndex >= sources || c.ToIndex < 0 || c.ToIndex >= targets {
+				continue
+			}
+			resp.Durations[c.FromIndex][c.ToIndex] = c.Time
+			if c.Distance != nil {
+				meters := *c.Distance * metersPer
+				resp.Distances[c.FromIndex][c.ToIndex] = &meters
+			}
+		}
+	}
+	for i, row := range resp.Durations {
+		ranked := []int{}
+		for j, d := range row {
+			if d != nil {
+				ranked = append(ranked, j)
+			}
+		}
+		slices.SortStableFunc(ranked, func(a, b int) int { return cmp.Compare(*row[a], *row[b]) })
+		resp.Ranked[i] = ranked
+	}
+	return resp
+}
@@ -0,0 +1,31 @@
+package rest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+)
+
+func TestMatrixResponse(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	m := &valhalla.MatrixResponse{
+		Units: "kilometers",
+		SourcesToTargets: [][]valhalla.MatrixCell{{
+			{FromIndex: 0, ToIndex: 0, Time: f(900), Distance: f(12.5)},
+			{FromIndex: 0, ToIndex: 1}, // unreachable
+			{FromIndex: 0, ToIndex: 2, Time: f(300), Distance: f(2)},
+		}},
+	}
+
+	resp := matrixResponse(m, 1, 3)
+	if got := resp.Ranked[0]; !slices.Equal(got, []int{2, 0}) {
+		t.Errorf("ranked = %v, want [2 0]", got)
+	}
+	if d := resp.Distances[0][0]; d == nil || *d != 12500 {
+		t.Errorf("distance = %v, want 12500 m", d)
+	}
+	if resp.Durations[0][1] != nil || resp.Distances[0][1] != nil {
+		t.Error("unreachable target should have no duration or distance")
+	}
+}
@@ -0,0 +1,224 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Group message attachment kinds (messages.attachment->>'kind').
+const (
+	AttachmentLocation = "location"
+	AttachmentETA      = "eta"
+	AttachmentRoute    = "route"
+)
+
+const (
+	// etaMaxAhead rejects shared arrival times that cannot be a real trip.
+	etaMaxAhead = 24 * time.Hour
+	// liveETAMaxPingAge hides the sender's position once their last ping is this old.
+	liveETAMaxPingAge = 5 * time.Minute
+	attachmentNameMax = 100
+)
+
+// prepareMessageAttachment validates an attachment sent by userID and returns the payload
+// to store. Saved locations and navigation sessions must belong to the sender and are
+// resolved here, so names, coordinates and destinations cannot be forged by the client.
+func (api *API) prepareMessageAttachment(ctx context.Context, userID uuid.UUID, att *model.MessageAttachment) (*model.MessageAttachment, string, string, error) {
+	if att == nil {
+		return nil, values.Success, "", nil
+	}
+
+	switch att.Kind {
+	case AttachmentLocation:
+		if att.Location == nil {
+			return nil, values.BadRequestBody, "location attachment requires a location", fmt.Errorf("missing location payload")
+		}
+		loc, status, msg, err := api.prepareLocationAttachment(ctx, userID, *att.Location)
+		if err != nil {
+			return nil, status, msg, err
+		}
+		return &model.MessageAttachment{Kind: AttachmentLocation, Location: &loc}, values.Success, "", nil
+
+	case AttachmentETA:
+		if att.ETA == nil {
+			return nil, values.BadRequestBody, "eta attachment requires an eta", fmt.Errorf("missing eta payload")
+		}
+		session, status, msg, err := api.sharedNavigationSession(ctx, att.ETA.SessionID, userID)
+		if err != nil {
+			return nil, status, msg, err
+		}
+		now := time.Now()
+		if att.ETA.ArriveAt.Before(now.Add(-time.Minute)) || att.ETA.ArriveAt.After(now.Add(etaMaxAhead)) {
+			return nil, values.BadRequestBody, "arrive_at must be within the next 24 hours", fmt.Errorf("invalid arrive_at %s", att.ETA.ArriveAt)
+		}
+		return &model.MessageAttachment{Kind: AttachmentETA, ETA: &model.ETAAttachment{
+			SessionID:       session.ID,
+			ArriveAt:        att.ETA.ArriveAt.UTC(),
+			DestinationName: session.DestinationName,
+			DestinationLat:  session.DestinationLat,
+			DestinationLng:  session.DestinationLng,
+		}}, values.Success, "", nil
+
+	case AttachmentRoute:
+		if att.Route == nil {
+			return nil, values.BadRequestBody, "route attachment requires a route", fmt.Errorf("missing route payload")
+		}
+		session, status, msg, err := api.sharedNavigationSession(ctx, att.Route.SessionID, userID)
+		if err != nil {
+			return nil, status, msg, err
+		}
+		return &model.MessageAttachment{Kind: AttachmentRoute, Route: &model.RouteAttachment{
+			SessionID:       session.ID,
+			RouteVersion:    session.RouteVersion,
+			DestinationName: session.DestinationName,
+			DestinationLat:  session.DestinationLat,
+			DestinationLng:  session.DestinationLng,
+		}}, values.Success, "", nil
+	}
+	return nil, values.BadRequestBody, "attachment kind must be location, eta or route", fmt.Errorf("unknown attachment kind %q", att.Kind)
+}
+
+func (api *API) prepareLocationAttachment(ctx context.Context, userID uuid.UUID, req model.LocationAttachment) (model.LocationAttachment, string, string, error) {
+	if req.SavedLocationID != nil {
+		loc, err := api.GetSavedLocationAttachmentRepo(ctx, *req.SavedLocationID, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return loc, values.NotFound, "Saved location not found", err
+		}
+		if err != nil {
+			return loc, values.Error, "Failed to load saved location", err
+		}
+		return loc, values.Success, "", nil
+	}
+
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return req, values.BadRequestBody, "location latitude or longitude is out of range", fmt.Errorf("invalid coordinates %f,%f", req.Latitude, req.Longitude)
+	}
+	for _, s := range []*string{req.Name, req.Address} {
+		if s != nil && len(*s) > attachmentNameMax {
+			return req, values.BadRequestBody, fmt.Sprintf("location name and address must be at most %d characters", attachmentNameMax), fmt.Errorf("location text too long")
+		}
+	}
+	return model.LocationAttachment{
+		Name:      trimmedOrNil(req.Name),
+		Address:   trimmedOrNil(req.Address),
+		PlaceID:   trimmedOrNil(req.PlaceID),
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+	}, values.Success, "", nil
+}
+
+// sharedNavigationSession loads one of the sender's navigation sessions for sharing.
+// Only active sessions can be shared.
+func (api *API) sharedNavigationSession(ctx context.Context, sessionID, userID uuid.UUID) (model.NavigationSession, string, string, error) {
+	session, err := api.GetNavigationSessionRepo(ctx, sessionID, userID)
+	if errors.Is(err, ErrNavigationSessionNotFound) {
+		return session, values.NotFound, "Navigation session not found", err
+	}
+	if err != nil {
+		return session, values.Error, "Failed to load navigation session", err
+	}
+	if session.Status != "active" {
+		return session, values.Unprocessable, "Only active navigation sessions can be shared", ErrNavigationSessionInactive
+	}
+	return session, values.Success, "", nil
+}
+
+// expandMessageAttachments fills the live fields of ETA and route attachments from the
+// sender's navigation session. It never fails the read; on error attachments are left
+// as stored.
+func (api *API) expandMessageAttachments(ctx context.Context, messages []model.GroupMessage) {
+	var ids []uuid.UUID
+	for _, m := range messages {
+		if id, ok := attachmentSessionID(m.Attachment); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	states, err := api.GetSharedSessionStatesRepo(ctx, ids, liveETAMaxPingAge)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to expand message attachments", "err", err)
+		return
+	}
+	for _, m := range messages {
+		id, ok := attachmentSessionID(m.Attachment)
+		if !ok {
+			continue
+		}
+		st, ok := states[id]
+		if !ok {
+			continue
+		}
+		switch {
+		case m.Attachment.ETA != nil:
+			live := st.Live
+			m.Attachment.ETA.Live = &live
+		case m.Attachment.Route != nil:
+			m.Attachment.Route.Geometry = st.Geometry
+			m.Attachment.Route.Rerouted = st.RouteVersion != m.Attachment.Route.RouteVersion
+		}
+	}
+}
+
+func attachmentSessionID(att *model.MessageAttachment) (uuid.UUID, bool) {
+	switch {
+	case att == nil:
+		return uuid.Nil, false
+	case att.Kind == AttachmentETA && att.ETA != nil:
+		return att.ETA.SessionID, true
+	case att.Kind == AttachmentRoute && att.Route != nil:
+		return att.Route.SessionID, true
+	}
+	return uuid.Nil, false
+}
+
+// attachmentMessageType is the message_type used when a message with an attachment is
+// sent without one.
+func attachmentMessageType(att *model.MessageAttachment) string {
+	if att != nil {
+		switch att.Kind {
+		case AttachmentLocation:
+			return "location_pin"
+		case AttachmentETA:
+			return "eta_update"
+		}
+	}
+	return "text"
+}
+
+// attachmentPushText is the push body for a message that has an attachment but no text.
+func attachmentPushText(att *model.MessageAttachment) string {
+	switch att.Kind {
+	case AttachmentLocation:
+		if att.Location != nil && att.Location.Name != nil {
+			return "Shared a location: " + *att.Location.Name
+		}
+		return "Shared a location"
+	case AttachmentETA:
+		return "Shared their ETA"
+	case AttachmentRoute:
+		return "Shared a route"
+	}
+	return "Sent an attachment"
+}
+
+func trimmedOrNil(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*s)
+	if t == "" {
+		return nil
+	}
+	return &t
+}
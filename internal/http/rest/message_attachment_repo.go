@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+// sharedSessionState is what a reader of an ETA or route attachment sees of the
+// sender's navigation session.
+type sharedSessionState struct {
+	Status       string
+	RouteVersion int
+	Geometry     [][]float64
+	Live         model.LiveETA
+}
+
+// GetSavedLocationAttachmentRepo loads one of the user's saved locations as an attachment.
+// It returns pgx.ErrNoRows if the location does not exist or belongs to someone else.
+func (api *API) GetSavedLocationAttachmentRepo(ctx context.Context, id int64, userID uuid.UUID) (model.LocationAttachment, error) {
+	loc := model.LocationAttachment{SavedLocationID: &id}
+	err := api.DB.QueryRow(ctx, `
+        SELECT name, address, place_id, ST_Y(location::geometry), ST_X(location::geometry)
+        FROM saved_locations
+        WHERE id = $1 AND user_id = $2
+    `, id, userID).Scan(&loc.Name, &loc.Address, &loc.PlaceID, &loc.Latitude, &loc.Longitude)
+	return loc, err
+}
+
+// GetSharedSessionStatesRepo returns the current state of the given navigation sessions.
+// The sender's position is only included while the session is active and the last
+// ping is recent.
+func (api *API) GetSharedSessionStatesRepo(ctx context.Context, sessionIDs []uuid.UUID, maxPingAge time.Duration) (map[uuid.UUID]sharedSessionState, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT s.id, s.status, s.route_version, ST_AsGeoJSON(s.route_geom),
+               ST_Y(p.position), ST_X(p.position), p.recorded_at,
+               CASE WHEN s.destination IS NOT NULL AND p.position IS NOT NULL
+                    THEN ST_Distance(p.position::geography, s.destination::geography) END
+        FROM navigation_sessions s
+        LEFT JOIN LATERAL (
+            SELECT position, recorded_at FROM user_location_pings
+            WHERE user_id = s.user_id AND recorded_at >= NOW() - make_interval(secs => $2)
+            ORDER BY recorded_at DESC
+            LIMIT 1
+        ) p ON s.status = 'active'
+        WHERE s.id = ANY($1)
+    `, sessionIDs, maxPingAge.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[uuid.UUID]sharedSessionState, len(sessionIDs))
+	for rows.Next() {
+		var id uuid.UUID
+		var st sharedSessionState
+		var geom string
+		if err := rows.Scan(&id, &st.Status, &st.RouteVersion, &geom,
+			&st.Live.Latitude, &st.Live.Longitude, &st.Live.PositionAt, &st.Live.RemainingDistanceM); err != nil {
+			return nil, err
+		}
+		var line struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		}
+		if err := json.Unmarshal([]byte(geom), &line); err != nil {
+			return nil, err
+		}
+		st.Geometry = line.Coordinates
+		st.Live.Status = st.Status
+		states[id] = st
+	}
+	return states, rows.Err()
+}
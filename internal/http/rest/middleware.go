@@ -1,16 +1,21 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/tracing"
 	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt"
 	"github.com/lucsky/cuid"
 )
@@ -40,6 +45,12 @@ func RequestTracing(next http.Handler) http.Handler {
 
 		ctx = context.WithValue(ctx, values.ContextTracingKey, tracingContext)
 		next.ServeHTTP(w, r.WithContext(ctx))
+
+		var route string
+		if rc := chi.RouteContext(ctx); rc != nil {
+			route = rc.RoutePattern()
+		}
+		telemetry.NameServerSpan(ctx, r.Method, route, requestID)
 	}
 
 	return http.HandlerFunc(fn)
@@ -56,7 +67,7 @@ func (api *API) RequireLogin(next http.Handler) http.Handler {
 
 		claims, err := api.verifyToken(authorization[1], false)
 		if err != nil {
-			if err.Error() == "token expired" {
+			if errors.Is(err, ErrTokenExpired) {
 				// Handle the expired token case
 				writeErrorResponse(w, err, values.TokenExpired, "token-expired")
 				return
@@ -65,11 +76,16 @@ func (api *API) RequireLogin(next http.Handler) http.Handler {
 			return
 		}
 
-		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		if api.accessTokenRevoked(r.Context(), claims) {
+			writeErrorResponse(w, ErrTokenRevoked, values.NotAuthorised, "token-revoked")
+			return
+		}
 
-		// Get additional user info from database if needed
-		user, err := api.GetUserByID(dbCtx, claims.UserID)
+		userID, role, err := api.resolveLoginUser(r.Context(), claims)
+		if errors.Is(err, ErrAccountDeactivated) {
+			writeErrorResponse(w, err, values.NotAuthorised, "account-deactivated")
+			return
+		}
 		if err != nil {
 			writeErrorResponse(w, err, values.NotAuthorised, "user-not-found")
 			return
@@ -77,12 +93,144 @@ func (api *API) RequireLogin(next http.Handler) http.Handler {
 
 		// Add minimal information to context
 		ctx := r.Context()
-		ctx = context.WithValue(ctx, "user_id", user.ID.String())
+		ctx = context.WithValue(ctx, "user_id", userID)
+		ctx = context.WithValue(ctx, "user_role", role)
 		// ctx = context.WithValue(ctx, "user", user) // Add full user object if needed
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// resolveLoginUser loads the user behind a verified token, refusing accounts waiting to
+// be deleted. While the database is unreachable the token alone is trusted, with the
+// role downgraded to user so admin endpoints stay closed. The lookup ends with the
+// request.
+func (api *API) resolveLoginUser(ctx context.Context, claims *TokenClaims) (string, string, error) {
+	if !api.dbAvailable() {
+		return claims.UserID, values.RoleUser, nil
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	user, err := api.GetUserByID(dbCtx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", "", err
+		}
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		api.logger().WarnContext(ctx, "User lookup failed, falling back to token claims", "user_id", claims.UserID, "err", err)
+		return claims.UserID, values.RoleUser, nil
+	}
+	if user.DeactivatedAt != nil {
+		return "", "", ErrAccountDeactivated
+	}
+	return user.ID.String(), user.Role, nil
+}
+
+// Why VerifySignedRequest rejected a request.
+var (
+	ErrSignatureRequired = errors.New("request signature required")
+	ErrInvalidSignature  = errors.New("invalid request signature")
+	ErrSignatureExpired  = errors.New("request signature expired")
+	ErrUnknownDevice     = errors.New("unknown signing device")
+)
+
+// signatureMaxSkew is how far the signed timestamp may drift from server time.
+// Nonces are remembered for twice this window so a replay can never slip past both checks.
+const signatureMaxSkew = 5 * time.Minute
+
+// VerifySignedRequest checks the device HMAC signature and replay protection headers.
+// Must run after RequireLogin. Unsigned requests are let through unless
+// Config.RequireSignedRequests is set, so older app versions keep working.
+// Signed bodies are capped at signedRequestMaxBytes; routes taking larger bodies use
+// VerifySignedRequestUpTo.
+func (api *API) VerifySignedRequest(next http.Handler) http.Handler {
+	return api.VerifySignedRequestUpTo(signedRequestMaxBytes)(next)
+}
+
+// VerifySignedRequestUpTo is VerifySignedRequest for routes whose signed bodies may be
+// up to maxBytes long, such as photo reports.
+func (api *API) VerifySignedRequestUpTo(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return api.verifySignedRequest(next, maxBytes)
+	}
+}
+
+func (api *API) verifySignedRequest(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get(values.HeaderSignature)
+		if signature == "" {
+			if api.Config.RequireSignedRequests {
+				writeErrorResponse(w, ErrSignatureRequired, values.NotAuthorised, "signature-required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := util.GetUserIDFromContext(r.Context())
+		if err != nil {
+			writeErrorResponse(w, err, values.NotAuthorised, "not-authorized")
+			return
+		}
+
+		deviceID := r.Header.Get(values.HeaderDeviceID)
+		timestamp := r.Header.Get(values.HeaderSignatureTimestamp)
+		nonce := r.Header.Get(values.HeaderSignatureNonce)
+		if deviceID == "" || timestamp == "" || nonce == "" {
+			writeErrorResponse(w, fmt.Errorf("%w: incomplete signature headers", ErrInvalidSignature), values.NotAuthorised, "invalid-signature")
+			return
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			writeErrorResponse(w, fmt.Errorf("%w: %w", ErrInvalidSignature, err), values.NotAuthorised, "invalid-signature")
+			return
+		}
+		if skew := time.Since(time.Unix(unix, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+			writeErrorResponse(w, ErrSignatureExpired, values.NotAuthorised, "signature-expired")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			writeErrorResponse(w, err, values.BadRequestBody, "unable to read request body")
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		dbCtx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		secret, err := api.GetDeviceSecret(dbCtx, userID.String(), deviceID)
+		if err != nil {
+			writeErrorResponse(w, fmt.Errorf("%w: %w", ErrUnknownDevice, err), values.NotAuthorised, "unknown-device")
+			return
+		}
+
+		if !util.ValidSignature(secret, r.Method, r.URL.Path, timestamp, nonce, body, signature) {
+			api.logger().WarnContext(r.Context(), "Invalid request signature", "device_id", deviceID)
+			writeErrorResponse(w, fmt.Errorf("%w: signature mismatch", ErrInvalidSignature), values.NotAuthorised, "invalid-signature")
+			return
+		}
+
+		if err := api.ConsumeRequestNonce(dbCtx, userID.String(), nonce, time.Now().Add(2*signatureMaxSkew)); err != nil {
+			if errors.Is(err, ErrNonceReused) {
+				api.logger().WarnContext(r.Context(), "Replayed request nonce", "device_id", deviceID)
+				writeErrorResponse(w, err, values.NotAuthorised, "replayed-request")
+				return
+			}
+			writeErrorResponse(w, err, values.Error, "unable to verify request")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (api *API) verifyToken(tokenString string, isRefresh bool) (*TokenClaims, error) {
 	// Determine the correct secret key based on token type
 	secret := api.Config.JwtSecret
@@ -94,7 +242,7 @@ func (api *API) verifyToken(tokenString string, isRefresh bool) (*TokenClaims, e
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Ensure the signing method is correct
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			log.Println("unexpected signing method")
+			api.logger().Info("Token has unexpected signing method")
 			return nil, fmt.Errorf("unexpected signing method")
 		}
 		return []byte(secret), nil
@@ -103,29 +251,28 @@ func (api *API) verifyToken(tokenString string, isRefresh bool) (*TokenClaims, e
 	// Specifically handle token expiration
 	if ve, ok := err.(*jwt.ValidationError); ok {
 		if ve.Errors&jwt.ValidationErrorExpired != 0 {
-			log.Println("token expired")
-			return nil, fmt.Errorf("token expired")
+			api.logger().Debug("Token expired")
+			return nil, ErrTokenExpired
 		}
 	}
 
 	// Check for errors or invalid token
 	if err != nil || !token.Valid {
-		log.Println("error verifying token", err)
-		return nil, fmt.Errorf("invalid token")
+		api.logger().Info("Token verification failed", "err", err)
+		return nil, ErrInvalidToken
 	}
 
 	// Extract claims
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		log.Println("error extracting claims")
+		api.logger().Info("Token claims unreadable")
 		return nil, fmt.Errorf("invalid claims")
 	}
 
 	// Check the token type (use "typ" instead of "type")
 	tokenType, _ := claims["typ"].(string)
-	log.Println("token type", tokenType, "expected", isRefresh)
 	if (isRefresh && tokenType != "refresh") || (!isRefresh && tokenType != "access") {
-		log.Println("invalid token type")
+		api.logger().Info("Token has wrong type", "token_type", tokenType, "want_refresh", isRefresh)
 		return nil, fmt.Errorf("invalid token type")
 	}
 
@@ -136,13 +283,13 @@ func (api *API) verifyToken(tokenString string, isRefresh bool) (*TokenClaims, e
 	}
 
 	// Log extracted user ID and token type
-	log.Println("user id", userID)
-	log.Println("token type", tokenType)
+	jti, _ := claims["jti"].(string)
 
 	// Return the extracted claims
 	return &TokenClaims{
 		UserID: userID,
 		Type:   tokenType,
 		Exp:    int64(claims["exp"].(float64)),
+		JTI:    jti,
 	}, nil
 }
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/config"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func TestVerifySignedRequestBodyLimit(t *testing.T) {
+	api := &API{Config: &config.Config{}}
+	handler := api.VerifySignedRequest(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("an oversized body reached the handler")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/traffic/samples", strings.NewReader(strings.Repeat("x", signedRequestMaxBytes+1)))
+	r = r.WithContext(context.WithValue(r.Context(), "user_id", "6f1c2d9e-4b8a-4f7e-9a51-0c3d2b1e8f77"))
+	r.Header.Set(values.HeaderSignature, "sig")
+	r.Header.Set(values.HeaderDeviceID, "device")
+	r.Header.Set(values.HeaderSignatureTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	r.Header.Set(values.HeaderSignatureNonce, "nonce")
+	w := httptest.NewRecorder()
+
+	// There is no database here: the body must be rejected before the secret lookup.
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/http/vision"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/storage"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// Moderation decisions for images attached to reports.
+const (
+	ImageDecisionApproved = "approved"
+	ImageDecisionHeld     = "held"
+	ImageDecisionRejected = "rejected"
+)
+
+const (
+	reportImageFolder = "reports"
+	// moderationImageFolder keeps held and rejected images out of the public folder
+	// while they wait for review or an appeal.
+	moderationImageFolder = "reports-moderation"
+
+	moderationProviderVision = "cloud_vision"
+	moderationProviderNone   = "none"
+)
+
+// imageVerdict is the outcome of moderating one image.
+type imageVerdict struct {
+	Provider string
+	Decision string
+	Reason   *string
+	Scores   map[string]string
+}
+
+// moderateImage runs SafeSearch on an uploaded image. Clear adult or violent content is
+// rejected, anything borderline is held for review. If moderation is not configured the
+// image is approved as before; if the API fails it is held rather than published.
+func (api *API) moderateImage(ctx context.Context, image []byte) imageVerdict {
+	if !api.VisionClient.Configured() {
+		return imageVerdict{Provider: moderationProviderNone, Decision: ImageDecisionApproved}
+	}
+
+	ss, err := api.VisionClient.SafeSearch(ctx, image)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Image moderation failed, holding image for review", "err", err)
+		reason := "moderation_error"
+		return imageVerdict{Provider: moderationProviderVision, Decision: ImageDecisionHeld, Reason: &reason}
+	}
+	return safeSearchVerdict(ss)
+}
+
+func safeSearchVerdict(ss *vision.SafeSearchAnnotation) imageVerdict {
+	v := imageVerdict{
+		Provider: moderationProviderVision,
+		Decision: ImageDecisionApproved,
+		Scores: map[string]string{
+			"adult":    ss.Adult,
+			"violence": ss.Violence,
+			"racy":     ss.Racy,
+			"medical":  ss.Medical,
+			"spoof":    ss.Spoof,
+		},
+	}
+	flag := func(decision, category, likelihood string) {
+		reason := category + ":" + likelihood
+		v.Decision, v.Reason = decision, &reason
+	}
+
+	// Checked strictest first so the reason names what triggered the decision.
+	switch {
+	case vision.LikelihoodRank(ss.Adult) >= vision.LikelihoodRank(vision.LikelihoodLikely):
+		flag(ImageDecisionRejected, "adult", ss.Adult)
+	case vision.LikelihoodRank(ss.Violence) >= vision.LikelihoodRank(vision.LikelihoodLikely):
+		flag(ImageDecisionRejected, "violence", ss.Violence)
+	case ss.Racy == vision.LikelihoodVeryLikely:
+		flag(ImageDecisionRejected, "racy", ss.Racy)
+	case vision.LikelihoodRank(ss.Adult) >= vision.LikelihoodRank(vision.LikelihoodPossible):
+		flag(ImageDecisionHeld, "adult", ss.Adult)
+	case vision.LikelihoodRank(ss.Violence) >= vision.LikelihoodRank(vision.LikelihoodPossible):
+		flag(ImageDecisionHeld, "violence", ss.Violence)
+	case vision.LikelihoodRank(ss.Racy) >= vision.LikelihoodRank(vision.LikelihoodPossible):
+		flag(ImageDecisionHeld, "racy", ss.Racy)
+	}
+	return v
+}
+
+// uploadReportImage stores an image in Cloudinary under folder.
+func (api *API) uploadReportImage(ctx context.Context, image []byte, folder string) (storage.ImageAsset, error) {
+	tmp, err := os.CreateTemp("", "report-*")
+	if err != nil {
+		return storage.ImageAsset{}, fmt.Errorf("failed to create temp file for image: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+	if _, err := tmp.Write(image); err != nil {
+		tmp.Close()
+		return storage.ImageAsset{}, fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return storage.ImageAsset{}, fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return api.Deps.Cloudinary.UploadImageAsset(ctx, tmpPath, folder)
+}
+
+// logImageModeration records a verdict for review and appeals. Failures are logged only;
+// they never fail the report.
+func (api *API) logImageModeration(ctx context.Context, userID uuid.UUID, reportID *int64, imageURL *string, v imageVerdict) {
+	err := api.InsertImageModerationRepo(ctx, model.ImageModeration{
+		ReportID: reportID,
+		UserID:   userID,
+		ImageURL: imageURL,
+		Provider: v.Provider,
+		Decision: v.Decision,
+		Reason:   v.Reason,
+		Scores:   v.Scores,
+	})
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Failed to log image moderation", "err", err)
+	}
+}
+
+func (api *API) ListImageModerationsHelper(ctx context.Context, decision, limitStr string) ([]model.ImageModeration, string, string, error) {
+	switch decision {
+	case "", ImageDecisionApproved, ImageDecisionHeld, ImageDecisionRejected:
+	default:
+		return nil, values.BadRequestBody, "decision must be approved, held or rejected", fmt.Errorf("invalid decision %q", decision)
+	}
+	limit := 50
+	if limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > 200 {
+			return nil, values.BadRequestBody, "limit must be between 1 and 200", fmt.Errorf("invalid limit %q", limitStr)
+		}
+		limit = n
+	}
+
+	moderations, err := api.ListImageModerationsRepo(ctx, decision, limit)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch image moderations", err
+	}
+	if moderations == nil {
+		moderations = []model.ImageModeration{}
+	}
+	return moderations, values.Success, "Image moderations fetched successfully", nil
+}
+
+func (api *API) ReviewImageModerationHelper(ctx context.Context, id int64, req model.ReviewImageModerationRequest, adminID uuid.UUID) (string, string, error) {
+	err := api.ReviewImageModerationRepo(ctx, id, req.Decision, adminID)
+	if err != nil {
+		if errors.Is(err, ErrImageModerationNotFound) {
+			return values.NotFound, "Image moderation not found", err
+		}
+		return values.Error, "Failed to review image", err
+	}
+	return values.Success, "Image " + req.Decision, nil
+}
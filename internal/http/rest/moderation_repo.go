@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrImageModerationNotFound = fmt.Errorf("image moderation %w", ErrNotFound)
+
+// InsertImageModerationRepo logs a moderation verdict. reportID is nil when the
+// submission was rejected and no report was created.
+func (api *API) InsertImageModerationRepo(ctx context.Context, m model.ImageModeration) error {
+	scores, err := json.Marshal(m.Scores)
+	if err != nil {
+		return err
+	}
+	query := `
+        INSERT INTO report_image_moderations (report_id, user_id, image_url, provider, decision, reason, scores)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+	_, err = api.DB.Exec(ctx, query, m.ReportID, m.UserID, m.ImageURL, m.Provider, m.Decision, m.Reason, scores)
+	return err
+}
+
+func (api *API) ListImageModerationsRepo(ctx context.Context, decision string, limit int) ([]model.ImageModeration, error) {
+	query := `
+        SELECT id, report_id, user_id, image_url, provider, decision, reason, scores,
+               reviewed_by, reviewed_at, created_at
+        FROM report_image_moderations
+        WHERE ($1 = '' OR decision = $1)
+        ORDER BY created_at DESC
+        LIMIT $2
+    `
+	rows, err := api.DB.Query(ctx, query, decision, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var moderations []model.ImageModeration
+	for rows.Next() {
+		var m model.ImageModeration
+		var scores []byte
+		if err := rows.Scan(&m.ID, &m.ReportID, &m.UserID, &m.ImageURL, &m.Provider, &m.Decision, &m.Reason, &scores,
+			&m.ReviewedBy, &m.ReviewedAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(scores) > 0 {
+			if err := json.Unmarshal(scores, &m.Scores); err != nil {
+				return nil, err
+			}
+		}
+		moderations = append(moderations, m)
+	}
+	return moderations, rows.Err()
+}
+
+// ReviewImageModerationRepo records an admin's verdict. Approving publishes the image on
+// its report; rejecting takes it down if it was already public.
+func (api *API) ReviewImageModerationRepo(ctx context.Context, id int64, decision string, reviewedBy uuid.UUID) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var reportID *int64
+		var imageURL *string
+		err := tx.QueryRow(ctx, `
+            UPDATE report_image_moderations
+            SET decision = $2, reviewed_by = $3, reviewed_at = NOW()
+            WHERE id = $1
+            RETURNING report_id, image_url
+        `, id, decision, reviewedBy).Scan(&reportID, &imageURL)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrImageModerationNotFound
+		}
+		if err != nil || reportID == nil || imageURL == nil {
+			return err
+		}
+
+		if decision == ImageDecisionApproved {
+			_, err = tx.Exec(ctx, `UPDATE reports SET image_url = $2, updated_at = NOW() WHERE id = $1`, *reportID, *imageURL)
+		} else {
+			_, err = tx.Exec(ctx, `UPDATE reports SET image_url = NULL, updated_at = NOW() WHERE id = $1 AND image_url = $2`, *reportID, *imageURL)
+		}
+		return err
+	})
+}
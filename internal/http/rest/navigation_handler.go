@@ -0,0 +1,217 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (api *API) NavigationRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		// Start navigating a route returned by /route; the route corridor is buffered once here
+		r.Method(http.MethodPost, "/sessions", Handler(api.StartNavigationHandler))
+		r.Method(http.MethodGet, "/sessions/{sessionID}", Handler(api.GetNavigationSessionHandler))
+		// Replace the route after a reroute; recomputes the corridor and bumps route_version
+		r.Method(http.MethodPost, "/sessions/{sessionID}/reroute", Handler(api.RerouteNavigationHandler))
+		// Report a GPS fix; tracks progress along the route and reroutes automatically
+		// after a few consecutive off-route fixes (the new route is in the response)
+		r.Method(http.MethodPost, "/sessions/{sessionID}/position", Handler(api.UpdateNavigationPositionHandler))
+		// End the session
+		// Query Params: ?cancelled=true when the driver gave up instead of arriving
+		r.Method(http.MethodPost, "/sessions/{sessionID}/finish", Handler(api.FinishNavigationHandler))
+		// Active reports along the route, evaluated against the cached corridor
+		// Query Params: ?type=POLICE&type=ACCIDENT
+		r.Method(http.MethodGet, "/sessions/{sessionID}/alerts", Handler(api.GetNavigationAlertsHandler))
+	})
+
+	return mux
+}
+
+func (api *API) StartNavigationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.StartNavigationRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	session, status, message, err := api.StartNavigationHelper(r.Context(), userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       session,
+	}
+}
+
+func (api *API) GetNavigationSessionHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		return respondWithError(err, "invalid session ID format", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	session, err := api.GetNavigationSessionRepo(r.Context(), sessionID, userID)
+	if err != nil {
+		if errors.Is(err, ErrNavigationSessionNotFound) {
+			return respondWithError(err, "Navigation session not found", values.NotFound, &tc)
+		}
+		return respondWithError(err, "Failed to fetch navigation session", values.Error, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    "Navigation session fetched successfully",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+		Data:       session,
+	}
+}
+
+func (api *API) RerouteNavigationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		return respondWithError(err, "invalid session ID format", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.RerouteRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	session, status, message, err := api.RerouteNavigationHelper(r.Context(), sessionID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       session,
+	}
+}
+
+func (api *API) UpdateNavigationPositionHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		return respondWithError(err, "invalid session ID format", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpdatePositionRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	progress, status, message, err := api.UpdateNavigationPositionHelper(r.Context(), sessionID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       progress,
+	}
+}
+
+func (api *API) FinishNavigationHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		return respondWithError(err, "invalid session ID format", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	cancelled := r.URL.Query().Get("cancelled") == "true"
+	session, status, message, err := api.FinishNavigationHelper(r.Context(), sessionID, userID, cancelled)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       session,
+	}
+}
+
+func (api *API) GetNavigationAlertsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "sessionID"))
+	if err != nil {
+		return respondWithError(err, "invalid session ID format", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var types []string
+	for _, t := range r.URL.Query()["type"] {
+		types = append(types, strings.ToUpper(t))
+	}
+
+	alerts, status, message, err := api.GetSessionAlertsHelper(r.Context(), sessionID, userID, types)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       alerts,
+	}
+}
@@ -0,0 +1,290 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultCorridorWidthM = 100
+	minCorridorWidthM     = 25
+	maxCorridorWidthM     = 500
+)
+
+const (
+	// A fix outside the session's corridor (after snapping it to the road) is off route.
+	// offRouteFixesBeforeReroute consecutive off-route fixes trigger an automatic reroute,
+	// so a single bad fix or a brief detour into a petrol station doesn't.
+	offRouteFixesBeforeReroute = 3
+	// autoRerouteCooldown is the minimum gap between two reroutes of the same session.
+	autoRerouteCooldown = 30 * time.Second
+	// offRouteSnapRadiusM is how far an off-route fix may be moved onto a road.
+	offRouteSnapRadiusM = 30
+)
+
+var navigationProfiles = map[string]bool{
+	"driving": true, "driving-traffic": true, "walking": true, "cycling": true, profileMotorcycle: true,
+}
+
+func (api *API) StartNavigationHelper(ctx context.Context, userID uuid.UUID, req model.StartNavigationRequest) (model.NavigationSession, string, string, error) {
+	width := req.CorridorWidthM
+	if width == 0 {
+		width = defaultCorridorWidthM
+	}
+	if width < minCorridorWidthM || width > maxCorridorWidthM {
+		return model.NavigationSession{}, values.BadRequestBody, "corridor_width_m must be between 25 and 500", errors.New("corridor width out of range")
+	}
+
+	profile := req.Profile
+	if profile == "" {
+		profile = "driving"
+	}
+	if !navigationProfiles[profile] {
+		return model.NavigationSession{}, values.BadRequestBody, "unsupported profile", fmt.Errorf("unsupported profile %q", profile)
+	}
+	preference := req.Preference
+	if preference == "" {
+		preference = values.RoutePreferenceFastest
+	}
+	if preference != values.RoutePreferenceFastest && preference != values.RoutePreferenceSafe {
+		return model.NavigationSession{}, values.BadRequestBody, "preference must be fastest or safe", fmt.Errorf("unsupported preference %q", preference)
+	}
+
+	session, err := api.CreateNavigationSessionRepo(ctx, userID, req, width, profile, preference)
+	if err != nil {
+		return model.NavigationSession{}, values.Error, "Failed to start navigation session", err
+	}
+	if req.RouteChoiceToken != "" {
+		api.recordRouteChoice(ctx, userID, req.RouteChoiceToken, req.RouteIndex)
+	}
+	api.recordRecentDestination(ctx, userID, req)
+	return session, values.Created, "Navigation session started", nil
+}
+
+func (api *API) RerouteNavigationHelper(ctx context.Context, sessionID, userID uuid.UUID, req model.RerouteRequest) (model.NavigationSession, string, string, error) {
+	session, err := api.RerouteNavigationSessionRepo(ctx, sessionID, userID, req.Geometry, nil)
+	if err != nil {
+		if errors.Is(err, ErrNavigationSessionNotFound) {
+			return model.NavigationSession{}, values.NotFound, "Active navigation session not found", err
+		}
+		return model.NavigationSession{}, values.Error, "Failed to update route", err
+	}
+	api.publishNavigationUpdate(session)
+	return session, values.Success, "Route updated", nil
+}
+
+// UpdateNavigationPositionHelper records a GPS fix, advances the session's progress and
+// reroutes from the fix to the destination once the driver has clearly left the route.
+// Failing to reroute isn't an error: the response still says the driver is off route and
+// the app can ask /route itself.
+func (api *API) UpdateNavigationPositionHelper(ctx context.Context, sessionID, userID uuid.UUID, req model.UpdatePositionRequest) (model.NavigationProgress, string, string, error) {
+	lat, lng, err := util.NormalizeCoordinate(req.Latitude, req.Longitude)
+	if err != nil {
+		return model.NavigationProgress{}, values.BadRequestBody, "invalid latitude or longitude", err
+	}
+
+	distanceM, inCorridor, err := api.NavigationRouteDistanceRepo(ctx, sessionID, userID, lat, lng)
+	if err != nil {
+		if errors.Is(err, ErrNavigationSessionNotFound) {
+			return model.NavigationProgress{}, values.NotFound, "Active navigation session not found", err
+		}
+		return model.NavigationProgress{}, values.Error, "Failed to update position", err
+	}
+	// Raw GPS drifts off the road in town and under trees; only count the fix as off route
+	// if it is still outside the corridor once snapped to the nearest road.
+	if !inCorridor {
+		if snappedLat, snappedLng, ok := api.snapNavigationFix(ctx, lat, lng); ok {
+			snappedM, snappedIn, err := api.NavigationRouteDistanceRepo(ctx, sessionID, userID, snappedLat, snappedLng)
+			if err == nil {
+				lat, lng, distanceM, inCorridor = snappedLat, snappedLng, snappedM, snappedIn
+			}
+		}
+	}
+	offRoute := !inCorridor
+
+	session, err := api.RecordNavigationPositionRepo(ctx, sessionID, userID, lat, lng, offRoute)
+	if err != nil {
+		if errors.Is(err, ErrNavigationSessionNotFound) {
+			return model.NavigationProgress{}, values.NotFound, "Active navigation session not found", err
+		}
+		return model.NavigationProgress{}, values.Error, "Failed to update position", err
+	}
+	progress := model.NavigationProgress{
+		Session:            session,
+		DistanceFromRouteM: distanceM,
+		OffRoute:           offRoute,
+	}
+	if !offRoute || !needsAutoReroute(session) {
+		api.publishNavigationUpdate(session)
+		return progress, values.Success, "Position updated", nil
+	}
+
+	directions, err := api.rerouteDirections(ctx, session, lat, lng)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Navigation auto reroute failed", "session_id", sessionID, "err", err)
+		api.publishNavigationUpdate(session)
+		return progress, values.Success, "Position updated", nil
+	}
+	// The app's route is only worth diffing against while it is still the session's.
+	var prevGeometry [][]float64
+	var prevSteps []model.DrivingStep
+	canDiff := req.RouteVersion != nil && *req.RouteVersion == session.RouteVersion
+	if canDiff {
+		if prevGeometry, prevSteps, err = api.NavigationRouteRepo(ctx, sessionID, userID); err != nil {
+			api.logger().WarnContext(ctx, "Navigation loading route to diff against failed", "session_id", sessionID, "err", err)
+			canDiff = false
+		}
+	}
+	route := drivingRoute(directions)
+	rerouted, err := api.RerouteNavigationSessionRepo(ctx, sessionID, userID, directions.Routes[0].Geometry.Coordinates, route.Steps)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Navigation saving auto reroute failed", "session_id", sessionID, "err", err)
+		api.publishNavigationUpdate(session)
+		return progress, values.Success, "Position updated", nil
+	}
+	progress.Session = rerouted
+	progress.Rerouted = true
+	if canDiff {
+		diff := diffRoute(prevGeometry, prevSteps, route, session.RouteVersion)
+		progress.RouteDiff = &diff
+	} else {
+		progress.Route = &route
+	}
+	api.publishNavigationUpdate(rerouted)
+	return progress, values.Success, "Off route, rerouted", nil
+}
+
+// needsAutoReroute reports whether an off-route session has been off long enough, and not
+// rerouted too recently, to plan a new route. Without a destination there is nothing to
+// route to.
+func needsAutoReroute(session model.NavigationSession) bool {
+	if session.OffRouteCount < offRouteFixesBeforeReroute {
+		return false
+	}
+	if session.DestinationLat == nil || session.DestinationLng == nil {
+		return false
+	}
+	return session.LastReroutedAt == nil || time.Since(*session.LastReroutedAt) >= autoRerouteCooldown
+}
+
+// snapNavigationFix snaps a fix to the road network, Valhalla first (see snapToRoad).
+func (api *API) snapNavigationFix(ctx context.Context, lat, lng float64) (float64, float64, bool) {
+	snappedLat, snappedLng, _, err := api.snapToRoad(ctx, lat, lng, offRouteSnapRadiusM)
+	return snappedLat, snappedLng, err == nil
+}
+
+// rerouteDirections plans a route from the fix to the session's destination with the
+// provider the session was planned with, falling back to the other one.
+func (api *API) rerouteDirections(ctx context.Context, session model.NavigationSession, lat, lng float64) (*mapbox.DirectionsResponse, error) {
+	req := RouteRequest{
+		Locations: []Location{
+			{Lat: lat, Lng: lng},
+			{Lat: *session.DestinationLat, Lng: *session.DestinationLng},
+		},
+		Profile: session.Profile,
+	}
+
+	valhallaFirst := session.Preference == values.RoutePreferenceSafe || session.Profile == profileMotorcycle
+	providers := []func() (*mapbox.DirectionsResponse, error){
+		func() (*mapbox.DirectionsResponse, error) { return api.getValhallaRoute(ctx, req, session.Preference) },
+		func() (*mapbox.DirectionsResponse, error) { return api.mapboxRerouteDirections(ctx, req) },
+	}
+	if !valhallaFirst {
+		providers[0], providers[1] = providers[1], providers[0]
+	}
+
+	var lastErr error
+	for _, provider := range providers {
+		resp, err := provider()
+		if err == nil && len(resp.Routes) == 0 {
+			err = fmt.Errorf("no route returned")
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		api.addArrivalWindows(ctx, resp)
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// mapboxRerouteDirections asks Mapbox for a route with the turn-by-turn instructions the
+// app needs to carry on. Mapbox has no motorcycle profile, so those use driving.
+func (api *API) mapboxRerouteDirections(ctx context.Context, req RouteRequest) (*mapbox.DirectionsResponse, error) {
+	if api.MapboxClient == nil {
+		return nil, fmt.Errorf("mapbox client not configured")
+	}
+	profile := req.Profile
+	if profile == profileMotorcycle {
+		profile = "driving"
+	}
+	return api.MapboxClient.DirectionsWithNavigation(ctx, mapboxCoordinates(req.Locations), profile, false,
+		&mapbox.NavigationOptions{
+			VoiceInstructions:  true,
+			BannerInstructions: true,
+			RoundaboutExits:    true,
+			VoiceUnits:         "metric",
+			Language:           "en",
+		})
+}
+
+// FinishNavigationHelper ends an active session, as cancelled when the driver gave up
+// rather than arrived, and adds it to the user's trip history.
+func (api *API) FinishNavigationHelper(ctx context.Context, sessionID, userID uuid.UUID, cancelled bool) (model.NavigationSession, string, string, error) {
+	status := "finished"
+	if cancelled {
+		status = "cancelled"
+	}
+	session, err := api.FinishNavigationSessionRepo(ctx, sessionID, userID, status, tripMinDistanceM)
+	if err != nil {
+		if errors.Is(err, ErrNavigationSessionNotFound) {
+			return model.NavigationSession{}, values.NotFound, "Active navigation session not found", err
+		}
+		return model.NavigationSession{}, values.Error, "Failed to finish navigation session", err
+	}
+	api.publishNavigationUpdate(session)
+	return session, values.Success, "Navigation session " + status, nil
+}
+
+// publishNavigationUpdate sends the session's current state to WebSocket clients
+// following it on its navigation topic, e.g. group members watching the driver's ETA.
+// Best effort: nobody needs to be listening, and missed updates are just stale.
+func (api *API) publishNavigationUpdate(session model.NavigationSession) {
+	b, err := json.Marshal(session)
+	if err != nil {
+		api.logger().Error("Navigation encoding update failed", "session_id", session.ID, "err", err)
+		return
+	}
+	raw, err := json.Marshal(websockets.Message{
+		Type:    websockets.MsgTypeNavigationUpdate,
+		UserID:  session.UserID.String(),
+		Content: string(b),
+	})
+	if err != nil {
+		api.logger().Error("Navigation encoding update failed", "session_id", session.ID, "err", err)
+		return
+	}
+	api.Deps.WebSocket.Publish(raw, websockets.NavigationTopic(session.ID.String()))
+}
+
+func (api *API) GetSessionAlertsHelper(ctx context.Context, sessionID, userID uuid.UUID, types []string) ([]model.RouteAlert, string, string, error) {
+	alerts, err := api.GetSessionAlertsRepo(ctx, sessionID, userID, types)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch route alerts", err
+	}
+	if alerts == nil {
+		alerts = []model.RouteAlert{}
+	}
+	return alerts, values.Success, "Route alerts fetched successfully", nil
+}
@@ -0,0 +1,302 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNavigationSessionNotFound = fmt.Errorf("navigation session %w", ErrNotFound)
+	ErrNavigationSessionInactive = errors.New("navigation session is not active")
+)
+
+const navigationSessionColumns = `
+	id, user_id, status, corridor_width_m, route_version,
+	ST_Y(destination), ST_X(destination), destination_name,
+	profile, preference, ST_Length(route_geom::geography), progress_m,
+	off_route_count, reroute_count, last_position_at, last_rerouted_at,
+	started_at, updated_at, finished_at
+`
+
+func scanNavigationSession(row pgx.Row) (model.NavigationSession, error) {
+	var s model.NavigationSession
+	err := row.Scan(
+		&s.ID, &s.UserID, &s.Status, &s.CorridorWidthM, &s.RouteVersion,
+		&s.DestinationLat, &s.DestinationLng, &s.DestinationName,
+		&s.Profile, &s.Preference, &s.RouteLengthM, &s.ProgressM,
+		&s.OffRouteCount, &s.RerouteCount, &s.LastPositionAt, &s.LastReroutedAt,
+		&s.StartedAt, &s.UpdatedAt, &s.FinishedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.NavigationSession{}, ErrNavigationSessionNotFound
+	}
+	s.RemainingM = math.Max(s.RouteLengthM-s.ProgressM, 0)
+	return s, err
+}
+
+// lineStringGeoJSON turns [lng, lat] pairs into a GeoJSON LineString for ST_GeomFromGeoJSON.
+func lineStringGeoJSON(coords [][]float64) (string, error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"type":        "LineString",
+		"coordinates": coords,
+	})
+	return string(b), err
+}
+
+// CreateNavigationSessionRepo cancels any active session for the user and starts a new one,
+// buffering the route into its corridor once.
+func (api *API) CreateNavigationSessionRepo(ctx context.Context, userID uuid.UUID, req model.StartNavigationRequest, corridorWidthM int, profile, preference string) (model.NavigationSession, error) {
+	geojson, err := lineStringGeoJSON(req.Geometry)
+	if err != nil {
+		return model.NavigationSession{}, err
+	}
+
+	var session model.NavigationSession
+	err = api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE navigation_sessions
+			SET status = 'cancelled', finished_at = NOW(), updated_at = NOW()
+			WHERE user_id = $1 AND status = 'active'
+		`, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			WITH route AS (
+				SELECT ST_SetSRID(ST_GeomFromGeoJSON($2), 4326) AS geom
+			)
+			INSERT INTO navigation_sessions (
+				user_id, route_geom, corridor, corridor_width_m, destination, destination_name,
+				profile, preference
+			)
+			SELECT
+				$1,
+				route.geom,
+				ST_Buffer(route.geom::geography, $3)::geometry,
+				$3,
+				CASE WHEN $4::float8 IS NULL OR $5::float8 IS NULL THEN NULL
+				     ELSE ST_SetSRID(ST_MakePoint($5, $4), 4326) END,
+				$6, $7, $8
+			FROM route
+			RETURNING ` + navigationSessionColumns
+		session, err = scanNavigationSession(tx.QueryRow(ctx, query,
+			userID, geojson, corridorWidthM, req.DestinationLat, req.DestinationLng, req.DestinationName,
+			profile, preference,
+		))
+		return err
+	})
+	return session, err
+}
+
+func (api *API) GetNavigationSessionRepo(ctx context.Context, sessionID, userID uuid.UUID) (model.NavigationSession, error) {
+	query := `SELECT ` + navigationSessionColumns + ` FROM navigation_sessions WHERE id = $1 AND user_id = $2`
+	return scanNavigationSession(api.DB.QueryRow(ctx, query, sessionID, userID))
+}
+
+// CanFollowNavigationSessionRepo reports whether userID may watch the session's updates:
+// the driver, or a user sharing an active group with them.
+func (api *API) CanFollowNavigationSessionRepo(ctx context.Context, sessionID, userID uuid.UUID) (bool, error) {
+	var ok bool
+	err := api.DB.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM navigation_sessions s
+			WHERE s.id = $1
+			  AND (s.user_id = $2 OR EXISTS (
+				SELECT 1
+				FROM group_memberships driver
+				JOIN group_memberships follower ON follower.group_id = driver.group_id
+				WHERE driver.user_id = s.user_id AND driver.status = 'active'
+				  AND follower.user_id = $2 AND follower.status = 'active'
+			  ))
+		)
+	`, sessionID, userID).Scan(&ok)
+	return ok, err
+}
+
+// NavigationRouteRepo returns an active session's route geometry and, when the server
+// planned it, its steps.
+func (api *API) NavigationRouteRepo(ctx context.Context, sessionID, userID uuid.UUID) ([][]float64, []model.DrivingStep, error) {
+	var geom string
+	var stepsJSON []byte
+	err := api.DB.QueryRow(ctx, `
+		SELECT ST_AsGeoJSON(route_geom), route_steps
+		FROM navigation_sessions
+		WHERE id = $1 AND user_id = $2 AND status = 'active'
+	`, sessionID, userID).Scan(&geom, &stepsJSON)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNavigationSessionNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var line struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal([]byte(geom), &line); err != nil {
+		return nil, nil, fmt.Errorf("decoding route geometry: %w", err)
+	}
+	var steps []model.DrivingStep
+	if stepsJSON != nil {
+		if err := json.Unmarshal(stepsJSON, &steps); err != nil {
+			return nil, nil, fmt.Errorf("decoding route steps: %w", err)
+		}
+	}
+	return line.Coordinates, steps, nil
+}
+
+// RerouteNavigationSessionRepo replaces the session route and recomputes the corridor,
+// bumping route_version so clients and caches can tell the old corridor is stale.
+// Progress starts over on the new route. steps is nil when the app supplied the route.
+func (api *API) RerouteNavigationSessionRepo(ctx context.Context, sessionID, userID uuid.UUID, coords [][]float64, steps []model.DrivingStep) (model.NavigationSession, error) {
+	geojson, err := lineStringGeoJSON(coords)
+	if err != nil {
+		return model.NavigationSession{}, err
+	}
+	var stepsJSON []byte
+	if steps != nil {
+		if stepsJSON, err = json.Marshal(steps); err != nil {
+			return model.NavigationSession{}, err
+		}
+	}
+	query := `
+		WITH route AS (
+			SELECT ST_SetSRID(ST_GeomFromGeoJSON($3), 4326) AS geom
+		)
+		UPDATE navigation_sessions s
+		SET route_geom = route.geom,
+			route_steps = $4,
+			corridor = ST_Buffer(route.geom::geography, s.corridor_width_m)::geometry,
+			route_version = s.route_version + 1,
+			progress_m = 0,
+			off_route_count = 0,
+			reroute_count = s.reroute_count + 1,
+			last_rerouted_at = NOW(),
+			updated_at = NOW()
+		FROM route
+		WHERE s.id = $1 AND s.user_id = $2 AND s.status = 'active'
+		RETURNING ` + navigationSessionColumns
+	return scanNavigationSession(api.DB.QueryRow(ctx, query, sessionID, userID, geojson, stepsJSON))
+}
+
+// NavigationRouteDistanceRepo reports whether a point is inside an active session's cached
+// corridor, which is what decides if a fix is on route, and how far it is from route_geom
+// for the progress followers see. Nothing is buffered per fix: the corridor was buffered
+// when the route was set, the same one GetSessionAlertsRepo matches reports against.
+func (api *API) NavigationRouteDistanceRepo(ctx context.Context, sessionID, userID uuid.UUID, lat, lng float64) (distanceM float64, inCorridor bool, err error) {
+	query := `
+		WITH pos AS (
+			SELECT ST_SetSRID(ST_MakePoint($4, $3), 4326) AS geom
+		)
+		SELECT ST_Distance(s.route_geom::geography, pos.geom::geography), ST_Intersects(s.corridor, pos.geom)
+		FROM navigation_sessions s, pos
+		WHERE s.id = $1 AND s.user_id = $2 AND s.status = 'active'
+	`
+	err = api.DB.QueryRow(ctx, query, sessionID, userID, lat, lng).Scan(&distanceM, &inCorridor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, ErrNavigationSessionNotFound
+	}
+	return distanceM, inCorridor, err
+}
+
+// RecordNavigationPositionRepo stores the latest fix and adds the distance from the previous
+// one to driven_m. An on-route fix moves progress_m to its position along the route and
+// clears off_route_count; an off-route fix leaves progress alone and counts towards a reroute.
+func (api *API) RecordNavigationPositionRepo(ctx context.Context, sessionID, userID uuid.UUID, lat, lng float64, offRoute bool) (model.NavigationSession, error) {
+	query := `
+		WITH pos AS (
+			SELECT ST_SetSRID(ST_MakePoint($4, $3), 4326) AS geom
+		)
+		UPDATE navigation_sessions s
+		SET last_position = pos.geom,
+			last_position_at = NOW(),
+			driven_m = s.driven_m + COALESCE(ST_Distance(s.last_position::geography, pos.geom::geography), 0),
+			progress_m = CASE WHEN $5 THEN s.progress_m
+				ELSE ST_LineLocatePoint(s.route_geom, pos.geom) * ST_Length(s.route_geom::geography) END,
+			off_route_count = CASE WHEN $5 THEN s.off_route_count + 1 ELSE 0 END,
+			updated_at = NOW()
+		FROM pos
+		WHERE s.id = $1 AND s.user_id = $2 AND s.status = 'active'
+		RETURNING ` + navigationSessionColumns
+	return scanNavigationSession(api.DB.QueryRow(ctx, query, sessionID, userID, lat, lng, offRoute))
+}
+
+// FinishNavigationSessionRepo closes an active session as finished or cancelled and, when
+// at least minTripM was driven, records it as a trip in the same statement.
+func (api *API) FinishNavigationSessionRepo(ctx context.Context, sessionID, userID uuid.UUID, status string, minTripM float64) (model.NavigationSession, error) {
+	query := `
+		WITH s AS (
+			UPDATE navigation_sessions
+			SET status = $3, finished_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND user_id = $2 AND status = 'active'
+			RETURNING *
+		), trip AS (
+			INSERT INTO trips (user_id, session_id, status, distance_m, duration_s, avg_speed_ms,
+				route_geom, destination, destination_name, profile, started_at, finished_at)
+			SELECT user_id, id, status, driven_m,
+				EXTRACT(EPOCH FROM finished_at - started_at)::int,
+				driven_m / GREATEST(EXTRACT(EPOCH FROM finished_at - started_at), 1),
+				route_geom, destination, destination_name, profile, started_at, finished_at
+			FROM s
+			WHERE driven_m >= $4
+			ON CONFLICT (session_id) DO NOTHING
+		)
+		SELECT ` + navigationSessionColumns + ` FROM s`
+	return scanNavigationSession(api.DB.QueryRow(ctx, query, sessionID, userID, status, minTripM))
+}
+
+// GetSessionAlertsRepo returns active reports inside the session's cached corridor,
+// ordered by how far along the route they are.
+func (api *API) GetSessionAlertsRepo(ctx context.Context, sessionID, userID uuid.UUID, types []string) ([]model.RouteAlert, error) {
+	query := `
+		SELECT
+			r.id, r.user_id, u.username, r.type, r.subtype,
+			ST_X(r.position) AS longitude, ST_Y(r.position) AS latitude,
+			r.description, r.severity, r.verified_count, r.active, r.resolved,
+			r.created_at, r.updated_at, r.expires_at, r.image_url,
+			r.report_source, r.report_status, r.comments_count, r.upvotes_count, r.downvotes_count,
+			ST_LineLocatePoint(s.route_geom, r.position) * ST_Length(s.route_geom::geography) AS distance_from_start
+		FROM navigation_sessions s
+		JOIN reports r ON ST_Intersects(r.position, s.corridor)
+		JOIN users u ON u.id = r.user_id
+		WHERE s.id = $1 AND s.user_id = $2 AND s.status = 'active'
+		  AND r.active = true
+		  AND r.expires_at > NOW()
+		  AND (cardinality($3::text[]) = 0 OR r.type = ANY($3))
+		  AND ` + reportNotDismissedSQL("$2") + `
+		ORDER BY distance_from_start
+	`
+	if types == nil {
+		types = []string{}
+	}
+	rows, err := api.DB.Query(ctx, query, sessionID, userID, types)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []model.RouteAlert
+	for rows.Next() {
+		var a model.RouteAlert
+		err := rows.Scan(
+			&a.ID, &a.UserID, &a.Username, &a.Type, &a.Subtype,
+			&a.Longitude, &a.Latitude, &a.Description, &a.Severity,
+			&a.VerifiedCount, &a.Active, &a.Resolved, &a.CreatedAt,
+			&a.UpdatedAt, &a.ExpiresAt, &a.ImageURL, &a.ReportSource,
+			&a.ReportStatus, &a.CommentsCount, &a.UpvotesCount,
+			&a.DownvotesCount, &a.DistanceFromStartM,
+		)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/internal/notifications"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// Users are alerted from their latest location ping, if it is this recent.
+	reportAlertRecentWindow = "30 minutes"
+	// reportAlertMinSeverity is the lowest severity anyone can be alerted about; the
+	// preferences' min_severity can only raise it.
+	reportAlertMinSeverity = 3
+	reportAlertPushType    = "report.alert"
+)
+
+// defaultNotificationPreferences apply to users who never saved their own.
+var defaultNotificationPreferences = model.NotificationPreferences{
+	ReportAlerts: true,
+	AlertRadiusM: 2000,
+	MinSeverity:  4,
+	ReportTypes:  []string{},
+}
+
+// pushReportAlerts pushes a new report to nearby users whose preferences accept it. Each
+// user is claimed in report_alert_deliveries before sending, so a retried event never
+// alerts anyone twice; the flip side is that a failed send is not retried.
+func (api *API) pushReportAlerts(ctx context.Context, eventID string, p model.ReportCreatedEvent) error {
+	if !api.Notifier.Enabled() || p.Severity < reportAlertMinSeverity {
+		return nil
+	}
+	r := p.Report
+	users, err := api.ClaimReportAlertRecipientsRepo(ctx, r, p.Severity, reportAlertRecentWindow, defaultNotificationPreferences)
+	if err != nil || len(users) == 0 {
+		return err
+	}
+	tokens, err := api.GetFCMTokensForUsersRepo(ctx, users)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Report alerts: failed to load tokens", "report_id", r.ID, "users", len(users), "err", err)
+		return nil
+	}
+
+	label := reportAlertLabel(r)
+	body := strings.TrimSpace(r.Description)
+	if body == "" {
+		body = "Tap to see it on the map."
+	}
+	stale, failed, err := api.Notifier.Send(ctx, tokens, notifications.Notification{
+		Title: label + " reported nearby",
+		Body:  body,
+		Data: map[string]string{
+			"type":      reportAlertPushType,
+			"report_id": strconv.FormatInt(r.ID, 10),
+			"latitude":  strconv.FormatFloat(r.Latitude, 'f', 6, 64),
+			"longitude": strconv.FormatFloat(r.Longitude, 'f', 6, 64),
+			"event_id":  eventID,
+		},
+		CollapseKey:  "report-" + strconv.FormatInt(r.ID, 10),
+		HighPriority: true,
+	})
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Report alerts failed", "report_id", r.ID, "err", err)
+	} else if failed > 0 {
+		api.logger().WarnContext(ctx, "Report alerts: sends failed", "report_id", r.ID, "failed", failed, "tokens", len(tokens))
+	}
+	api.forgetStaleTokens(ctx, stale)
+	return nil
+}
+
+func reportAlertLabel(r model.CreateReportResponse) string {
+	report := model.Report{Type: r.Type}
+	if r.Subtype != "" {
+		report.Subtype = &r.Subtype
+	}
+	return reportLabel(report)
+}
+
+// forgetStaleTokens drops tokens FCM says are no longer registered.
+func (api *API) forgetStaleTokens(ctx context.Context, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	if err := api.DeleteFCMTokensRepo(ctx, tokens); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to delete stale FCM tokens", "tokens", len(tokens), "err", err)
+	}
+}
+
+func (api *API) ListPushDevicesHelper(ctx context.Context, userID uuid.UUID) ([]model.PushDevice, string, string, error) {
+	devices, err := api.ListPushDevicesRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch devices", err
+	}
+	if devices == nil {
+		devices = []model.PushDevice{}
+	}
+	return devices, values.Success, "Devices fetched successfully", nil
+}
+
+func (api *API) RegisterPushDeviceHelper(ctx context.Context, userID uuid.UUID, req model.RegisterDeviceRequest) (model.PushDevice, string, string, error) {
+	device, err := api.RegisterPushDeviceRepo(ctx, userID, strings.TrimSpace(req.Token), req.Platform)
+	if err != nil {
+		return model.PushDevice{}, values.Error, "Failed to register device", err
+	}
+	return device, values.Created, "Device registered", nil
+}
+
+func (api *API) DeletePushDeviceHelper(ctx context.Context, userID, deviceID uuid.UUID) (string, string, error) {
+	if err := api.DeletePushDeviceRepo(ctx, userID, deviceID); err != nil {
+		return errorStatus(err, values.Error), "Failed to remove device", err
+	}
+	return values.Success, "Device removed", nil
+}
+
+func (api *API) GetNotificationPreferencesHelper(ctx context.Context, userID uuid.UUID) (model.NotificationPreferences, string, string, error) {
+	prefs, err := api.GetNotificationPreferencesRepo(ctx, userID)
+	if errors.Is(err, ErrNotFound) {
+		return defaultNotificationPreferences, values.Success, "Notification preferences fetched successfully", nil
+	}
+	if err != nil {
+		return model.NotificationPreferences{}, values.Error, "Failed to fetch notification preferences", err
+	}
+	return prefs, values.Success, "Notification preferences fetched successfully", nil
+}
+
+func (api *API) UpdateNotificationPreferencesHelper(ctx context.Context, userID uuid.UUID, req model.UpdateNotificationPreferencesRequest) (model.NotificationPreferences, string, string, error) {
+	prefs, err := api.UpsertNotificationPreferencesRepo(ctx, userID, req)
+	if err != nil {
+		return model.NotificationPreferences{}, values.Error, "Failed to save notification preferences", err
+	}
+	return prefs, values.Success, "Notification preferences saved", nil
+}
@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+var ErrPushDeviceNotFound = fmt.Errorf("push device %w", ErrNotFound)
+
+func (api *API) ListPushDevicesRepo(ctx context.Context, userID uuid.UUID) ([]model.PushDevice, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT id, platform, created_at, updated_at
+        FROM user_fcm_tokens
+        WHERE user_id = $1
+        ORDER BY updated_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []model.PushDevice
+	for rows.Next() {
+		var d model.PushDevice
+		if err := rows.Scan(&d.ID, &d.Platform, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// RegisterPushDeviceRepo stores the token like UpsertFCMToken and returns the device.
+func (api *API) RegisterPushDeviceRepo(ctx context.Context, userID uuid.UUID, token, platform string) (model.PushDevice, error) {
+	query := `
+        INSERT INTO user_fcm_tokens (user_id, token, platform, updated_at)
+        VALUES ($1, $2, $3, NOW())
+        ON CONFLICT (user_id, token) DO UPDATE SET
+            platform = EXCLUDED.platform,
+            updated_at = NOW()
+        RETURNING id, platform, created_at, updated_at
+    `
+	var d model.PushDevice
+	err := api.DB.QueryRow(ctx, query, userID, token, platform).Scan(&d.ID, &d.Platform, &d.CreatedAt, &d.UpdatedAt)
+	return d, dbError(err)
+}
+
+func (api *API) DeletePushDeviceRepo(ctx context.Context, userID, deviceID uuid.UUID) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM user_fcm_tokens WHERE id = $1 AND user_id = $2`, deviceID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPushDeviceNotFound
+	}
+	return nil
+}
+
+// DeleteFCMTokensRepo forgets tokens FCM reported as unregistered, whoever they belong to.
+func (api *API) DeleteFCMTokensRepo(ctx context.Context, tokens []string) error {
+	_, err := api.DB.Exec(ctx, `DELETE FROM user_fcm_tokens WHERE token = ANY($1)`, tokens)
+	return err
+}
+
+// GetFCMTokensForUsersRepo returns every token registered to any of userIDs.
+func (api *API) GetFCMTokensForUsersRepo(ctx context.Context, userIDs []uuid.UUID) ([]string, error) {
+	rows, err := api.DB.Query(ctx, `SELECT token FROM user_fcm_tokens WHERE user_id = ANY($1)`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetNotificationPreferencesRepo returns ErrNotFound for users who never saved preferences.
+func (api *API) GetNotificationPreferencesRepo(ctx context.Context, userID uuid.UUID) (model.NotificationPreferences, error) {
+	var p model.NotificationPreferences
+	err := api.DB.QueryRow(ctx, `
+        SELECT report_alerts, alert_radius_m, min_severity, report_types, updated_at
+        FROM user_notification_preferences
+        WHERE user_id = $1
+    `, userID).Scan(&p.ReportAlerts, &p.AlertRadiusM, &p.MinSeverity, &p.ReportTypes, &p.UpdatedAt)
+	return p, dbError(err)
+}
+
+func (api *API) UpsertNotificationPreferencesRepo(ctx context.Context, userID uuid.UUID, req model.UpdateNotificationPreferencesRequest) (model.NotificationPreferences, error) {
+	query := `
+        INSERT INTO user_notification_preferences (user_id, report_alerts, alert_radius_m, min_severity, report_types, updated_at)
+        VALUES ($1, $2, $3, $4, $5, NOW())
+        ON CONFLICT (user_id) DO UPDATE SET
+            report_alerts = EXCLUDED.report_alerts,
+            alert_radius_m = EXCLUDED.alert_radius_m,
+            min_severity = EXCLUDED.min_severity,
+            report_types = EXCLUDED.report_types,
+            updated_at = NOW()
+        RETURNING report_alerts, alert_radius_m, min_severity, report_types, updated_at
+    `
+	types := req.ReportTypes
+	if types == nil {
+		types = []string{}
+	}
+	var p model.NotificationPreferences
+	err := api.DB.QueryRow(ctx, query, userID, req.ReportAlerts, req.AlertRadiusM, req.MinSeverity, types).
+		Scan(&p.ReportAlerts, &p.AlertRadiusM, &p.MinSeverity, &p.ReportTypes, &p.UpdatedAt)
+	return p, err
+}
+
+// ClaimReportAlertRecipientsRepo picks the users to alert about a new report: those with a
+// push device whose latest location ping within recentWindow is inside their alert radius,
+// and whose preferences accept the report's type and severity. Users without preferences
+// get def. Recipients are recorded in report_alert_deliveries in the same statement, so a
+// user already claimed for this report is never returned again.
+func (api *API) ClaimReportAlertRecipientsRepo(ctx context.Context, r model.CreateReportResponse, severity int, recentWindow string, def model.NotificationPreferences) ([]uuid.UUID, error) {
+	query := `
+        WITH report AS (
+            SELECT ST_SetSRID(ST_MakePoint($3, $2), 4326)::geography AS geog
+        ),
+        recent AS (
+            SELECT DISTINCT ON (p.user_id) p.user_id, p.position
+            FROM user_location_pings p
+            WHERE p.recorded_at > NOW() - $7::interval
+            ORDER BY p.user_id, p.recorded_at DESC
+        ),
+        eligible AS (
+            SELECT recent.user_id
+            FROM recent
+            CROSS JOIN report
+            LEFT JOIN user_notification_preferences np ON np.user_id = recent.user_id
+            WHERE recent.user_id <> $4
+              AND COALESCE(np.report_alerts, $8)
+              AND $6 >= COALESCE(np.min_severity, $10)
+              AND (np.report_types IS NULL OR cardinality(np.report_types) = 0 OR $5 = ANY(np.report_types))
+              AND ST_DWithin(recent.position::geography, report.geog, COALESCE(np.alert_radius_m, $9))
+              AND EXISTS (SELECT 1 FROM user_fcm_tokens t WHERE t.user_id = recent.user_id)
+        )
+        INSERT INTO report_alert_deliveries (report_id, user_id)
+        SELECT $1, user_id FROM eligible
+        ON CONFLICT (report_id, user_id) DO NOTHING
+        RETURNING user_id
+    `
+	rows, err := api.DB.Query(ctx, query,
+		r.ID, r.Latitude, r.Longitude, r.UserID, r.Type, severity, recentWindow,
+		def.ReportAlerts, def.AlertRadiusM, def.MinSeverity,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		users = append(users, id)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,85 @@
+package rest
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) OfflineRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+
+		// Active reports, the caller's saved locations and POIs in a region, for offline use.
+		// bbox is minLng,minLat,maxLng,maxLat; each side may span at most 2 degrees.
+		// Query Params: ?format=geojson (default; one FeatureCollection) or ?format=zip
+		//   (reports.geojson, saved_locations.geojson, pois.geojson and manifest.json)
+		r.Get("/regions/{bbox}", api.GetOfflineRegion)
+	})
+
+	return mux
+}
+
+// GetOfflineRegion streams an offline region bundle. It writes the body itself rather than
+// returning the JSON envelope, so bundles of any size go out without being held in memory;
+// only errors found before the first byte get an envelope.
+func (api *API) GetOfflineRegion(w http.ResponseWriter, r *http.Request) {
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		writeErrorResponse(w, err, values.NotAuthorised, "unable to get user ID from context")
+		return
+	}
+	box, err := parseBBox(chi.URLParam(r, "bbox"))
+	if err != nil {
+		writeErrorResponse(w, err, values.BadRequestBody, "bbox must be minLng,minLat,maxLng,maxLat")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "geojson"
+	case "geojson", "zip":
+	default:
+		writeErrorResponse(w, fmt.Errorf("invalid format %q", format), values.BadRequestBody, "format must be geojson or zip")
+		return
+	}
+
+	region, status, message, err := api.PrepareOfflineRegionHelper(r.Context(), userID, *box, requestLanguage(r))
+	if err != nil {
+		writeErrorResponse(w, err, status, message)
+		return
+	}
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(offlineRegionWriteTimeout)); err != nil {
+		api.logger().WarnContext(r.Context(), "Offline region: can't extend write deadline", "err", err)
+	}
+	filename := fmt.Sprintf("region-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+	} else {
+		w.Header().Set("Content-Type", "application/geo+json")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriterSize(w, 32*1024)
+	if format == "zip" {
+		err = api.writeOfflineRegionZip(r.Context(), bw, region)
+	} else {
+		err = api.writeOfflineRegionGeoJSON(r.Context(), bw, region)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		// The status line is gone; the truncated body is all the client will see.
+		api.logger().ErrorContext(r.Context(), "Offline region failed mid-stream", "err", err)
+	}
+}
@@ -0,0 +1,205 @@
+package rest
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// offlineRegionMaxSpanDeg caps each side of an offline region; all of Northern Cyprus
+	// fits comfortably.
+	offlineRegionMaxSpanDeg = 2.0
+	// Bundles can take longer to stream than the server's default write timeout allows.
+	offlineRegionWriteTimeout = 2 * time.Minute
+)
+
+// Feature kinds in an offline region bundle.
+const (
+	offlineKindReport        = "report"
+	offlineKindSavedLocation = "saved_location"
+	offlineKindPOI           = "poi"
+)
+
+// offlineRegion is a validated offline bundle request.
+type offlineRegion struct {
+	box       model.BoundingBox
+	userID    uuid.UUID
+	lang      string
+	dismissed map[int64]bool
+}
+
+// PrepareOfflineRegionHelper checks an offline region request before anything is written,
+// so errors can still be sent as a normal response.
+func (api *API) PrepareOfflineRegionHelper(ctx context.Context, userID uuid.UUID, box model.BoundingBox, lang string) (offlineRegion, string, string, error) {
+	if box.MaxLat-box.MinLat > offlineRegionMaxSpanDeg || box.MaxLng-box.MinLng > offlineRegionMaxSpanDeg {
+		return offlineRegion{}, values.BadRequestBody, fmt.Sprintf("Region too large; each side may span at most %.0f degrees", offlineRegionMaxSpanDeg),
+			fmt.Errorf("bbox spans %.2f x %.2f degrees", box.MaxLat-box.MinLat, box.MaxLng-box.MinLng)
+	}
+	if !api.dbAvailable() {
+		return offlineRegion{}, values.Unavailable, "Offline regions are temporarily unavailable, please retry", errDBUnavailable
+	}
+
+	dismissals, err := api.ListReportDismissalsRepo(ctx, userID)
+	if err != nil {
+		return offlineRegion{}, values.Error, "Failed to build offline region", err
+	}
+	dismissed := make(map[int64]bool, len(dismissals))
+	for _, d := range dismissals {
+		dismissed[d.ReportID] = true
+	}
+	return offlineRegion{box: box, userID: userID, lang: lang, dismissed: dismissed}, values.Success, "", nil
+}
+
+// writeOfflineRegionGeoJSON streams the region as one FeatureCollection. Each feature's
+// "kind" property tells reports, saved locations and POIs apart, and a "manifest" member
+// after the features carries the counts.
+func (api *API) writeOfflineRegionGeoJSON(ctx context.Context, w io.Writer, region offlineRegion) error {
+	manifest := model.OfflineRegionManifest{BBox: region.box, GeneratedAt: time.Now().UTC()}
+	s, err := startGeoJSON(w, region.box)
+	if err != nil {
+		return err
+	}
+	if manifest.Reports, err = api.writeOfflineReports(ctx, s, region); err != nil {
+		return err
+	}
+	if manifest.SavedLocations, err = api.writeOfflineSavedLocations(ctx, s, region); err != nil {
+		return err
+	}
+	if manifest.POIs, err = api.writeOfflinePOIs(ctx, s, region); err != nil {
+		return err
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, `],"manifest":%s}`, b)
+	return err
+}
+
+// writeOfflineRegionZip streams the region as a zip of reports.geojson,
+// saved_locations.geojson, pois.geojson and manifest.json.
+func (api *API) writeOfflineRegionZip(ctx context.Context, w io.Writer, region offlineRegion) error {
+	manifest := model.OfflineRegionManifest{BBox: region.box, GeneratedAt: time.Now().UTC()}
+	zw := zip.NewWriter(w)
+	layers := []struct {
+		name  string
+		write func(context.Context, *geoJSONStream, offlineRegion) (int, error)
+		count *int
+	}{
+		{"reports.geojson", api.writeOfflineReports, &manifest.Reports},
+		{"saved_locations.geojson", api.writeOfflineSavedLocations, &manifest.SavedLocations},
+		{"pois.geojson", api.writeOfflinePOIs, &manifest.POIs},
+	}
+	for _, layer := range layers {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: layer.name, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		s, err := startGeoJSON(f, region.box)
+		if err != nil {
+			return err
+		}
+		if *layer.count, err = layer.write(ctx, s, region); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, "]}"); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.GeneratedAt})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (api *API) writeOfflineReports(ctx context.Context, s *geoJSONStream, region offlineRegion) (int, error) {
+	count := 0
+	err := api.StreamActiveReportsInBBoxRepo(ctx, region.box, func(r model.Report) error {
+		if region.dismissed[r.ID] {
+			return nil
+		}
+		localizeReportLabel(&r, region.lang)
+		count++
+		return s.point(r.Longitude, r.Latitude, struct {
+			Kind string `json:"kind"`
+			*model.Report
+		}{offlineKindReport, &r})
+	})
+	return count, err
+}
+
+func (api *API) writeOfflineSavedLocations(ctx context.Context, s *geoJSONStream, region offlineRegion) (int, error) {
+	count := 0
+	err := api.StreamSavedLocationsInBBoxRepo(ctx, region.userID, region.box, func(l model.SavedLocationResponse) error {
+		count++
+		return s.point(l.Longitude, l.Latitude, struct {
+			Kind string `json:"kind"`
+			*model.SavedLocationResponse
+		}{offlineKindSavedLocation, &l})
+	})
+	return count, err
+}
+
+func (api *API) writeOfflinePOIs(ctx context.Context, s *geoJSONStream, region offlineRegion) (int, error) {
+	count := 0
+	err := api.StreamPOIsInBBoxRepo(ctx, region.box, func(p model.POI) error {
+		count++
+		return s.point(p.Longitude, p.Latitude, struct {
+			Kind string `json:"kind"`
+			*model.POI
+		}{offlineKindPOI, &p})
+	})
+	return count, err
+}
+
+// geoJSONStream writes the features of a FeatureCollection one at a time. The caller
+// closes the features array (and the collection) itself.
+type geoJSONStream struct {
+	w io.Writer
+	n int
+}
+
+func startGeoJSON(w io.Writer, box model.BoundingBox) (*geoJSONStream, error) {
+	_, err := fmt.Fprintf(w, `{"type":"FeatureCollection","bbox":[%g,%g,%g,%g],"features":[`,
+		box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+	return &geoJSONStream{w: w}, err
+}
+
+func (s *geoJSONStream) point(lng, lat float64, properties interface{}) error {
+	type feature struct {
+		Type     string `json:"type"`
+		Geometry struct {
+			Type        string     `json:"type"`
+			Coordinates [2]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties interface{} `json:"properties"`
+	}
+	f := feature{Type: "Feature", Properties: properties}
+	f.Geometry.Type = "Point"
+	f.Geometry.Coordinates = [2]float64{lng, lat}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if s.n > 0 {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	s.n++
+	_, err = s.w.Write(b)
+	return err
+}
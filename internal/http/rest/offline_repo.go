@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+// StreamActiveReportsInBBoxRepo calls fn for every live report inside box, newest first.
+func (api *API) StreamActiveReportsInBBoxRepo(ctx context.Context, box model.BoundingBox, fn func(model.Report) error) error {
+	query := `
+        SELECT
+            r.id, r.user_id, u.username, r.type, r.subtype,
+            ST_X(r.position::geometry), ST_Y(r.position::geometry),
+            r.description, r.severity, r.verified_count,
+            r.active, r.resolved, r.created_at, r.updated_at,
+            r.expires_at, r.image_url, r.report_source, r.report_status,
+            r.comments_count, r.upvotes_count, r.downvotes_count, r.location_labels
+        FROM reports r
+        JOIN users u ON u.id = r.user_id
+        WHERE r.position && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+        AND r.expires_at > NOW()
+        AND r.active = true
+        ORDER BY r.created_at DESC, r.id DESC
+    `
+	rows, err := api.DB.Query(ctx, query, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+	if err != nil {
+		return fmt.Errorf("querying reports in bbox: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report model.Report
+		err := rows.Scan(
+			&report.ID, &report.UserID, &report.Username, &report.Type, &report.Subtype,
+			&report.Longitude, &report.Latitude, &report.Description,
+			&report.Severity, &report.VerifiedCount, &report.Active,
+			&report.Resolved, &report.CreatedAt, &report.UpdatedAt,
+			&report.ExpiresAt, &report.ImageURL, &report.ReportSource,
+			&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
+			&report.DownvotesCount, &report.LocationLabels,
+		)
+		if err != nil {
+			return fmt.Errorf("scanning report: %w", err)
+		}
+		if err := fn(report); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamSavedLocationsInBBoxRepo calls fn for each of the user's saved locations inside box.
+func (api *API) StreamSavedLocationsInBBoxRepo(ctx context.Context, userID uuid.UUID, box model.BoundingBox, fn func(model.SavedLocationResponse) error) error {
+	stmt := `
+		SELECT ` + savedLocationColumns + `
+		FROM saved_locations
+		WHERE user_id = $1
+		  AND location::geometry && ST_MakeEnvelope($2, $3, $4, $5, 4326)
+		ORDER BY sort_order, id
+	`
+	rows, err := api.DB.Query(ctx, stmt, userID, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+	if err != nil {
+		return fmt.Errorf("querying saved locations in bbox: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		location, err := scanSavedLocation(rows)
+		if err != nil {
+			return fmt.Errorf("scanning saved location: %w", err)
+		}
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamPOIsInBBoxRepo calls fn for every POI inside box, by category and name.
+func (api *API) StreamPOIsInBBoxRepo(ctx context.Context, box model.BoundingBox, fn func(model.POI) error) error {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id, name, category, ST_Y(location), ST_X(location)
+		FROM pois
+		WHERE location && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+		ORDER BY category, name
+	`, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat)
+	if err != nil {
+		return fmt.Errorf("querying pois in bbox: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p model.POI
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Latitude, &p.Longitude); err != nil {
+			return fmt.Errorf("scanning poi: %w", err)
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
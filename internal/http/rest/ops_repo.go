@@ -0,0 +1,198 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/db/migrations"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/jackc/pgx/v5"
+)
+
+// Operational queries used by the admin CLI (cmd/admin). None of these are exposed over HTTP.
+
+// CreateAdminUserRepo creates a verified email user with the admin role, or promotes
+// the existing user with that email.
+func (api *API) CreateAdminUserRepo(ctx context.Context, email string, username *string) (model.User, error) {
+	var user model.User
+	err := api.DB.QueryRow(ctx, `
+		INSERT INTO users (email, username, auth_provider, is_verified, role)
+		VALUES ($1, $2, 'email', TRUE, $3)
+		ON CONFLICT (email) DO UPDATE SET
+			role = EXCLUDED.role,
+			username = COALESCE(users.username, EXCLUDED.username),
+			updated_at = NOW()
+		RETURNING id, email, username, role
+	`, email, username, values.RoleAdmin).Scan(&user.ID, &user.Email, &user.Username, &user.Role)
+	if err != nil {
+		return model.User{}, fmt.Errorf("creating admin user: %w", err)
+	}
+	return user, nil
+}
+
+// RevokeUserTokensRepo revokes every refresh token and drops every device signing secret
+// for a user, so all sessions must log in again once their access token expires.
+func (api *API) RevokeUserTokensRepo(ctx context.Context, userID string) (refreshTokens, deviceSecrets int64, err error) {
+	err = api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE auth_tokens SET is_revoked = TRUE
+			WHERE user_id = $1 AND is_revoked = FALSE
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("revoking refresh tokens: %w", err)
+		}
+		refreshTokens = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `DELETE FROM device_secrets WHERE user_id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("deleting device secrets: %w", err)
+		}
+		deviceSecrets = tag.RowsAffected()
+		return nil
+	})
+	return refreshTokens, deviceSecrets, err
+}
+
+// RegeocodeSavedLocationsRepo reverse geocodes saved locations and stores the formatted
+// address (and place id when missing). Unless all is set, only rows without an address
+// are touched. delay throttles calls to the geocoding API.
+func (api *API) RegeocodeSavedLocationsRepo(ctx context.Context, all bool, delay time.Duration) (updated, failed int, err error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id, ST_Y(location), ST_X(location)
+		FROM saved_locations
+		WHERE $1 OR address IS NULL OR address = ''
+		ORDER BY id
+	`, all)
+	if err != nil {
+		return 0, 0, fmt.Errorf("listing saved locations: %w", err)
+	}
+	type pending struct {
+		id       int64
+		lat, lng float64
+	}
+	var locations []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.lat, &p.lng); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("scanning saved location: %w", err)
+		}
+		locations = append(locations, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, loc := range locations {
+		if err := ctx.Err(); err != nil {
+			return updated, failed, err
+		}
+		resp, err := api.GoogleMapsClient.ReverseGeocode(ctx, loc.lat, loc.lng, "")
+		if err != nil || len(resp.Results) == 0 {
+			api.logger().WarnContext(ctx, "Re-geocoding saved location failed", "saved_location_id", loc.id, "err", err)
+			failed++
+			continue
+		}
+		res := resp.Results[0]
+		_, err = api.DB.Exec(ctx, `
+			UPDATE saved_locations
+			SET address = $2, place_id = COALESCE(NULLIF(place_id, ''), $3)
+			WHERE id = $1
+		`, loc.id, res.FormattedAddress, res.PlaceID)
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Updating saved location failed", "saved_location_id", loc.id, "err", err)
+			failed++
+			continue
+		}
+		updated++
+		time.Sleep(delay)
+	}
+	return updated, failed, nil
+}
+
+// BackfillReportGeohashesRepo fills reports.geohash for rows that don't have one yet.
+func (api *API) BackfillReportGeohashesRepo(ctx context.Context) (int64, error) {
+	tag, err := api.DB.Exec(ctx, `
+		UPDATE reports SET geohash = ST_GeoHash(position, $1)
+		WHERE geohash IS NULL
+	`, ReportGeohashPrecision)
+	if err != nil {
+		return 0, fmt.Errorf("backfilling report geohashes: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RecomputeCountersRepo rebuilds the denormalized vote/comment counts on reports and
+// member_count on community groups from their source tables, fixing any drift.
+func (api *API) RecomputeCountersRepo(ctx context.Context) (reports, groups int64, err error) {
+	err = api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			WITH counts AS (
+				SELECT r.id,
+					(SELECT COUNT(*) FROM votes v WHERE v.report_id = r.id AND v.vote_type = 'UPVOTE')::int AS upvotes,
+					(SELECT COUNT(*) FROM votes v WHERE v.report_id = r.id AND v.vote_type = 'DOWNVOTE')::int AS downvotes,
+					(SELECT COUNT(*) FROM comments c WHERE c.report_id = r.id AND c.deleted_at IS NULL)::int AS comments
+				FROM reports r
+			)
+			UPDATE reports r SET
+				upvotes_count = counts.upvotes,
+				downvotes_count = counts.downvotes,
+				comments_count = counts.comments
+			FROM counts
+			WHERE r.id = counts.id
+			  AND (r.upvotes_count IS DISTINCT FROM counts.upvotes
+			    OR r.downvotes_count IS DISTINCT FROM counts.downvotes
+			    OR r.comments_count IS DISTINCT FROM counts.comments)
+		`)
+		if err != nil {
+			return fmt.Errorf("recomputing report counters: %w", err)
+		}
+		reports = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `
+			WITH counts AS (
+				SELECT cg.id,
+					(SELECT COUNT(*) FROM group_memberships gm WHERE gm.group_id = cg.id AND gm.status = 'active')::int AS members
+				FROM community_groups cg
+			)
+			UPDATE community_groups cg SET member_count = counts.members
+			FROM counts
+			WHERE cg.id = counts.id AND cg.member_count IS DISTINCT FROM counts.members
+		`)
+		if err != nil {
+			return fmt.Errorf("recomputing group member counts: %w", err)
+		}
+		groups = tag.RowsAffected()
+		return nil
+	})
+	return reports, groups, err
+}
+
+// MissingMigrationObjectsRepo returns the migration objects the database doesn't have.
+func (api *API) MissingMigrationObjectsRepo(ctx context.Context, objects []migrations.Object) ([]migrations.Object, error) {
+	var missing []migrations.Object
+	for _, o := range objects {
+		var exists bool
+		var err error
+		if o.Kind == "column" {
+			err = api.DB.QueryRow(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = $1 AND column_name = $2
+				)
+			`, o.Table, o.Name).Scan(&exists)
+		} else {
+			err = api.DB.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, o.Name).Scan(&exists)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking %s %s: %w", o.Kind, o.Name, err)
+		}
+		if !exists {
+			missing = append(missing, o)
+		}
+	}
+	return missing, nil
+}
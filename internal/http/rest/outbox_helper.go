@@ -0,0 +1,417 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/bwise1/waze_kibris/util/websockets"
+)
+
+const (
+	outboxBatchSize    = 50
+	outboxPollInterval = 2 * time.Second
+	// outboxMaxAttempts is how many publishes are tried before an event is parked as failed.
+	outboxMaxAttempts = 10
+	outboxMaxBackoff  = 10 * time.Minute
+	outboxSinkTimeout = 10 * time.Second
+)
+
+// outboxDispatcher holds the dispatcher's wake-up signal so writers can trigger a
+// poll right after their transaction commits instead of waiting for the next tick.
+type outboxDispatcher struct {
+	wake       chan struct{}
+	httpClient *http.Client
+}
+
+// newOutboxDispatcher is set up in Init, before handlers or the dispatcher run, so a
+// wake-up sent before the dispatcher starts waits in the channel.
+func newOutboxDispatcher() outboxDispatcher {
+	return outboxDispatcher{
+		wake:       make(chan struct{}, 1),
+		httpClient: &http.Client{Timeout: outboxSinkTimeout},
+	}
+}
+
+func (d *outboxDispatcher) notify() {
+	if d.wake == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// NotifyOutbox wakes the dispatcher. Call it after a transaction that wrote outbox events commits.
+func (api *API) NotifyOutbox() {
+	api.outbox.notify()
+}
+
+// RunOutboxDispatcher publishes pending outbox events until ctx is cancelled. Events are
+// published at least once; sinks see the event id so they can drop duplicates.
+func (api *API) RunOutboxDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		api.dispatchOutboxBatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-api.outbox.wake:
+		}
+	}
+}
+
+func (api *API) dispatchOutboxBatch(ctx context.Context) {
+	for {
+		events, err := api.ClaimOutboxEventsRepo(ctx, outboxBatchSize)
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Outbox batch failed", "err", err)
+			return
+		}
+		for _, ev := range events {
+			api.dispatchOutboxEvent(ctx, ev)
+		}
+		if len(events) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (api *API) dispatchOutboxEvent(ctx context.Context, ev model.OutboxEvent) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic publishing event: %v", r)
+			}
+		}()
+		return api.publishOutboxEvent(ctx, ev)
+	}()
+	if err == nil {
+		if err := api.MarkOutboxEventPublishedRepo(ctx, ev.ID); err != nil {
+			api.logger().ErrorContext(ctx, "Outbox failed to mark event published", "event_id", ev.ID, "err", err)
+		}
+		return
+	}
+
+	giveUp := ev.Attempts >= outboxMaxAttempts
+	if giveUp {
+		api.logger().ErrorContext(ctx, "Outbox giving up on event", "event_id", ev.ID, "event_type", ev.EventType, "attempts", ev.Attempts, "err", err)
+	} else {
+		api.logger().WarnContext(ctx, "Outbox event attempt failed", "event_id", ev.ID, "event_type", ev.EventType, "attempt", ev.Attempts, "err", err)
+	}
+	next := time.Now().Add(outboxBackoff(ev.Attempts))
+	if err := api.MarkOutboxEventRetryRepo(ctx, ev.ID, err.Error(), next, giveUp); err != nil {
+		api.logger().ErrorContext(ctx, "Outbox failed to reschedule event", "event_id", ev.ID, "err", err)
+	}
+}
+
+// outboxBackoff doubles from 5s per attempt, capped at outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := 5 * time.Second
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > outboxMaxBackoff {
+		d = outboxMaxBackoff
+	}
+	return d
+}
+
+// publishOutboxEvent fans an event out to the WebSocket, push and webhook sinks. The
+// WebSocket sink is best effort (offline clients catch up via REST); report push and
+// webhook failures are returned so the event is retried.
+func (api *API) publishOutboxEvent(ctx context.Context, ev model.OutboxEvent) error {
+	eventID := strconv.FormatInt(ev.ID, 10)
+
+	switch ev.EventType {
+	case OutboxEventReportCreated:
+		var p model.ReportCreatedEvent
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", ev.EventType, err)
+		}
+		if err := api.publishReportCreated(ctx, eventID, p); err != nil {
+			return err
+		}
+		if err := api.pushReportAlerts(ctx, eventID, p); err != nil {
+			return err
+		}
+		if err := api.notifyAlertZones(ctx, eventID, p); err != nil {
+			return err
+		}
+	case OutboxEventReportResolved:
+		var p model.ReportResolvedEvent
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", ev.EventType, err)
+		}
+		if err := api.publishReportResolved(ctx, eventID, p); err != nil {
+			return err
+		}
+	case OutboxEventReportExpired:
+		var p model.ReportExpiredEvent
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", ev.EventType, err)
+		}
+		if err := api.publishReportExpired(ctx, eventID, p); err != nil {
+			return err
+		}
+	case OutboxEventGroupMessage:
+		var p model.GroupMessageEvent
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", ev.EventType, err)
+		}
+		if err := api.publishGroupMessage(ctx, eventID, p); err != nil {
+			return err
+		}
+		api.pushGroupMessage(ctx, eventID, p)
+	case OutboxEventMapIssueStatusChanged:
+		var p model.MapIssueStatusChangedEvent
+		if err := json.Unmarshal(ev.Payload, &p); err != nil {
+			return fmt.Errorf("decoding %s payload: %w", ev.EventType, err)
+		}
+		if err := api.publishMapIssueStatusChanged(ctx, eventID, p); err != nil {
+			return err
+		}
+	case OutboxEventValhallaRebuild:
+		// Webhook only: the tile build pipeline listens there.
+	default:
+		return fmt.Errorf("unknown outbox event type %q", ev.EventType)
+	}
+
+	return api.publishOutboxWebhook(ctx, ev)
+}
+
+func (api *API) publishReportCreated(ctx context.Context, eventID string, p model.ReportCreatedEvent) error {
+	r := p.Report
+	b, err := json.Marshal(websockets.ReportUpdatePayload{
+		ID:             r.ID,
+		UserID:         r.UserID.String(),
+		Type:           r.Type,
+		Latitude:       r.Latitude,
+		Longitude:      r.Longitude,
+		Active:         r.Active,
+		Resolved:       r.Resolved,
+		UpvotesCount:   r.UpvotesCount,
+		DownvotesCount: r.DownvotesCount,
+	})
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(websockets.Message{
+		Type:    websockets.MsgTypeReportUpdate,
+		UserID:  r.UserID.String(),
+		Content: string(b),
+		EventID: eventID,
+	})
+	if err != nil {
+		return err
+	}
+
+	dismissedBy, err := api.ReportDismissedUserIDsRepo(ctx, r.ID)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to load dismissals, broadcasting to everyone", "report_id", r.ID, "err", err)
+	}
+	radius := api.BroadcastRadiusFor(ctx, r.Type, p.Severity)
+	api.Deps.WebSocket.BroadcastReportUpdate(raw, r.Latitude, r.Longitude, radius, dismissedBy)
+	return nil
+}
+
+// publishReportResolved tells nearby clients to clear the report. Everyone in range gets it,
+// including users who dismissed the report, so no client is left showing a stale jam.
+func (api *API) publishReportResolved(ctx context.Context, eventID string, p model.ReportResolvedEvent) error {
+	b, err := json.Marshal(websockets.ReportUpdatePayload{
+		ID:        p.ReportID,
+		UserID:    p.UserID.String(),
+		Type:      p.Type,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Active:    true,
+		Resolved:  true,
+	})
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(websockets.Message{
+		Type:    websockets.MsgTypeReportUpdate,
+		UserID:  p.UserID.String(),
+		Content: string(b),
+		EventID: eventID,
+	})
+	if err != nil {
+		return err
+	}
+
+	api.reportTiles.invalidatePoint(p.Latitude, p.Longitude)
+	radius := api.BroadcastRadiusFor(ctx, p.Type, p.Severity)
+	api.Deps.WebSocket.BroadcastReportUpdate(raw, p.Latitude, p.Longitude, radius, nil)
+	return nil
+}
+
+// publishReportExpired tells nearby clients to drop a report the lifecycle worker
+// deactivated. Like resolutions, it goes to everyone in range.
+func (api *API) publishReportExpired(ctx context.Context, eventID string, p model.ReportExpiredEvent) error {
+	b, err := json.Marshal(websockets.ReportUpdatePayload{
+		ID:        p.ReportID,
+		UserID:    p.UserID.String(),
+		Type:      p.Type,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		Active:    false,
+	})
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(websockets.Message{
+		Type:    websockets.MsgTypeReportUpdate,
+		UserID:  p.UserID.String(),
+		Content: string(b),
+		EventID: eventID,
+	})
+	if err != nil {
+		return err
+	}
+
+	api.reportTiles.invalidatePoint(p.Latitude, p.Longitude)
+	radius := api.BroadcastRadiusFor(ctx, p.Type, p.Severity)
+	api.Deps.WebSocket.BroadcastReportUpdate(raw, p.Latitude, p.Longitude, radius, nil)
+	return nil
+}
+
+// publishGroupMessage broadcasts a group message to the members connected over WebSocket.
+func (api *API) publishGroupMessage(ctx context.Context, eventID string, p model.GroupMessageEvent) error {
+	m := p.Message
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(websockets.Message{
+		Type:    websockets.MsgTypeGroupChat,
+		UserID:  m.UserID.String(),
+		Content: string(b),
+		GroupID: m.GroupID.String(),
+		EventID: eventID,
+	})
+	if err != nil {
+		return err
+	}
+	api.Deps.WebSocket.BroadcastToGroup(m.GroupID.String(), raw)
+	return nil
+}
+
+// pushGroupMessage notifies members by push, honouring their notification levels. It is
+// best effort: a failed push is logged, and never fails the event, so a retry doesn't
+// broadcast the message again.
+func (api *API) pushGroupMessage(ctx context.Context, eventID string, p model.GroupMessageEvent) {
+	if api.FirebaseMessaging == nil {
+		return
+	}
+	m := p.Message
+	members, err := api.GetGroupMembers(ctx, m.GroupID)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Group message push skipped, failed to load members", "group_id", m.GroupID, "event_id", eventID, "err", err)
+		return
+	}
+	data := map[string]string{
+		"type":       websockets.MsgTypeGroupChat,
+		"group_id":   m.GroupID.String(),
+		"message_id": m.ID.String(),
+		"event_id":   eventID,
+	}
+	// Announcements reach every member, whatever their notification level.
+	announcement := m.MessageType == values.GroupMessageAnnouncement
+	title := "New group message"
+	if announcement {
+		title = "Group announcement"
+	}
+	body := m.Content
+	if strings.TrimSpace(body) == "" && m.Attachment != nil {
+		body = attachmentPushText(m.Attachment)
+	}
+	mentions := messageMentions(m.Content)
+	for _, member := range members {
+		if member.UserID == m.UserID || (!announcement && !wantsGroupPush(member, mentions)) {
+			continue
+		}
+		if err := api.SendFCMToUser(ctx, member.UserID.String(), title, body, data); err != nil {
+			api.logger().WarnContext(ctx, "Group message push failed", "user_id", member.UserID, "event_id", eventID, "err", err)
+		}
+	}
+}
+
+// wantsGroupPush applies the member's notification level. Muted and mentions-only members
+// still get the message over the WebSocket; only the push is skipped.
+func wantsGroupPush(member model.GroupMembership, mentions map[string]bool) bool {
+	switch member.NotificationLevel {
+	case values.GroupNotifyMuted:
+		return false
+	case values.GroupNotifyMentions:
+		return member.Username != nil && mentions[strings.ToLower(*member.Username)]
+	}
+	return true
+}
+
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_.]+)`)
+
+// messageMentions returns the lower-cased @usernames in a message.
+func messageMentions(content string) map[string]bool {
+	mentions := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		mentions[strings.ToLower(strings.TrimRight(match[1], "."))] = true
+	}
+	return mentions
+}
+
+// publishOutboxWebhook POSTs the event to OUTBOX_WEBHOOK_URL, signed with
+// X-Outbox-Signature = hex(HMAC-SHA256(secret, body)). Receivers dedupe on X-Outbox-Event-ID.
+func (api *API) publishOutboxWebhook(ctx context.Context, ev model.OutboxEvent) error {
+	if api.Config.OutboxWebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, api.Config.OutboxWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Outbox-Event-ID", ev.DedupKey)
+	req.Header.Set("X-Outbox-Event-Type", ev.EventType)
+	if api.Config.OutboxWebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(api.Config.OutboxWebhookSecret))
+		mac.Write(body)
+		req.Header.Set("X-Outbox-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := api.outbox.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: outboxSinkTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package rest
+
+import "testing"
+
+func TestOutboxNotifyBeforeDispatcherStarts(t *testing.T) {
+	d := newOutboxDispatcher()
+	d.notify()
+	d.notify() // coalesces with the one already waiting
+	select {
+	case <-d.wake:
+	default:
+		t.Fatal("wake-up sent before the dispatcher started was dropped")
+	}
+	select {
+	case <-d.wake:
+		t.Fatal("wake-ups were not coalesced")
+	default:
+	}
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// Outbox event types.
+const (
+	OutboxEventReportCreated         = "report.created"
+	OutboxEventReportResolved        = "report.resolved"
+	OutboxEventReportExpired         = "report.expired"
+	OutboxEventGroupMessage          = "group.message_created"
+	OutboxEventValhallaRebuild       = "valhalla.rebuild_requested"
+	OutboxEventMapIssueStatusChanged = "map_issue.status_changed"
+)
+
+// outboxLease is how long a claimed event is hidden from other dispatchers. If the
+// process dies mid-publish the event becomes visible again once the lease runs out.
+const outboxLease = 30 * time.Second
+
+// insertOutboxEvent records an event inside the caller's transaction. Events with a
+// dedup key that was already recorded are ignored.
+func insertOutboxEvent(ctx context.Context, tx pgx.Tx, eventType, aggregateID, dedupKey string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshalling outbox payload: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO event_outbox (event_type, aggregate_id, dedup_key, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (dedup_key) DO NOTHING
+	`, eventType, aggregateID, dedupKey, b)
+	if err != nil {
+		return fmt.Errorf("inserting outbox event: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutboxEventsRepo leases up to limit due events. SKIP LOCKED lets several
+// instances dispatch concurrently without handing out the same event twice.
+func (api *API) ClaimOutboxEventsRepo(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	q := `
+		UPDATE event_outbox SET
+			attempts = attempts + 1,
+			next_attempt_at = now() + $2::interval
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_id, dedup_key, payload, attempts, created_at
+	`
+	rows, err := api.DB.Query(ctx, q, limit, outboxLease.String())
+	if err != nil {
+		return nil, fmt.Errorf("claiming outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.OutboxEvent
+	for rows.Next() {
+		var ev model.OutboxEvent
+		if err := rows.Scan(&ev.ID, &ev.EventType, &ev.AggregateID, &ev.DedupKey, &ev.Payload, &ev.Attempts, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning outbox event: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
+
+// MarkOutboxEventPublishedRepo marks an event as delivered to every sink.
+func (api *API) MarkOutboxEventPublishedRepo(ctx context.Context, id int64) error {
+	_, err := api.DB.Exec(ctx, `
+		UPDATE event_outbox SET status = 'published', published_at = now(), last_error = NULL
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// MarkOutboxEventRetryRepo records a failed publish. The event is retried at nextAttempt,
+// or parked as failed when giveUp is set.
+func (api *API) MarkOutboxEventRetryRepo(ctx context.Context, id int64, lastErr string, nextAttempt time.Time, giveUp bool) error {
+	_, err := api.DB.Exec(ctx, `
+		UPDATE event_outbox SET
+			status = CASE WHEN $4 THEN 'failed' ELSE 'pending' END,
+			last_error = $2,
+			next_attempt_at = $3
+		WHERE id = $1
+	`, id, lastErr, nextAttempt, giveUp)
+	return err
+}
+
+// LastOutboxEventAtRepo returns when the most recent event of the given type was recorded,
+// or nil if there is none.
+func (api *API) LastOutboxEventAtRepo(ctx context.Context, eventType string) (*time.Time, error) {
+	var at *time.Time
+	err := api.DB.QueryRow(ctx, `SELECT MAX(created_at) FROM event_outbox WHERE event_type = $1`, eventType).Scan(&at)
+	if err != nil {
+		return nil, fmt.Errorf("querying last outbox event: %w", err)
+	}
+	return at, nil
+}
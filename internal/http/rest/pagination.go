@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// pageParams is a page request read from ?page=, ?page_size= (?pageSize= is still
+// accepted) and ?cursor=, the next_cursor of the previous page.
+type pageParams struct {
+	Page     int
+	PageSize int
+	// Cursor is the raw ?cursor=. On offset-paged lists it is the page number and already
+	// applied to Page; keyset-paged lists decode it themselves.
+	Cursor string
+}
+
+// parsePageParams reads the page request, defaulting the page size to defaultSize and
+// capping it at maxSize. Missing or invalid numbers fall back to the defaults.
+func parsePageParams(q url.Values, defaultSize, maxSize int) pageParams {
+	p := pageParams{Page: 1, PageSize: defaultSize, Cursor: q.Get("cursor")}
+	if n, err := strconv.Atoi(q.Get("page")); err == nil && n > 0 {
+		p.Page = n
+	}
+	if n, err := strconv.Atoi(p.Cursor); err == nil && n > 0 {
+		p.Page = n
+	}
+	size := q.Get("page_size")
+	if size == "" {
+		size = q.Get("pageSize")
+	}
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		p.PageSize = min(n, maxSize)
+	}
+	return p
+}
+
+func (p pageParams) offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// offsetPage wraps one page of an offset-paged list of total items. Its next cursor is
+// simply the next page number.
+func offsetPage[T any](items []T, p pageParams, total int) model.Page[T] {
+	page := model.Page[T]{Data: items, Page: p.Page, PageSize: p.PageSize, Total: total}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if p.offset()+len(items) < total {
+		page.NextCursor = strconv.Itoa(p.Page + 1)
+	}
+	return page
+}
+
+// paginate pages through a list that is small enough to load whole.
+func paginate[T any](items []T, p pageParams) model.Page[T] {
+	start := min(p.offset(), len(items))
+	end := min(start+p.PageSize, len(items))
+	return offsetPage(items[start:end], p, len(items))
+}
+
+// keysetPage wraps a keyset-paged list fetched with one row more than the page size,
+// which tells whether another page follows. cursor builds the next cursor from the last
+// item kept.
+func keysetPage[T any](items []T, p pageParams, total int, cursor func(last T) string) model.Page[T] {
+	page := model.Page[T]{Data: items, PageSize: p.PageSize, Total: total}
+	if len(items) > p.PageSize {
+		page.Data = items[:p.PageSize]
+		page.NextCursor = cursor(page.Data[p.PageSize-1])
+	}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	return page
+}
+
+// Keyset cursors are opaque to clients: base64 of "<created_at unix nanos>:<id>".
+func encodeKeysetCursor(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", createdAt.UnixNano(), id)))
+}
+
+func decodeKeysetCursor(s string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return time.Time{}, "", fmt.Errorf("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	return time.Unix(0, n), id, nil
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParsePageParams(t *testing.T) {
+	tests := []struct {
+		query         string
+		page, size    int
+		defaultSize   int
+		maxSize       int
+		wantCursorRaw string
+	}{
+		{query: "", page: 1, size: 20, defaultSize: 20, maxSize: 100},
+		{query: "page=3&page_size=10", page: 3, size: 10, defaultSize: 20, maxSize: 100},
+		{query: "page=2&pageSize=500", page: 2, size: 100, defaultSize: 20, maxSize: 100},
+		{query: "page=0&page_size=-5", page: 1, size: 20, defaultSize: 20, maxSize: 100},
+		{query: "page=2&cursor=4", page: 4, size: 20, defaultSize: 20, maxSize: 100, wantCursorRaw: "4"},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		p := parsePageParams(q, tt.defaultSize, tt.maxSize)
+		if p.Page != tt.page || p.PageSize != tt.size || p.Cursor != tt.wantCursorRaw {
+			t.Errorf("%q: got %+v, want page %d size %d", tt.query, p, tt.page, tt.size)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	page := paginate(items, pageParams{Page: 2, PageSize: 2})
+	if len(page.Data) != 2 || page.Data[0] != 3 || page.Total != 5 || page.NextCursor != "3" {
+		t.Errorf("page 2 = %+v", page)
+	}
+	page = paginate(items, pageParams{Page: 3, PageSize: 2})
+	if len(page.Data) != 1 || page.NextCursor != "" {
+		t.Errorf("last page = %+v, want one item and no cursor", page)
+	}
+	page = paginate(items, pageParams{Page: 9, PageSize: 2})
+	if page.Data == nil || len(page.Data) != 0 || page.Total != 5 {
+		t.Errorf("past the end = %+v, want an empty page with the total", page)
+	}
+}
+
+func TestKeysetPage(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	type msg struct {
+		at time.Time
+		id string
+	}
+	cursor := func(m msg) string { return encodeKeysetCursor(m.at, m.id) }
+
+	// One row more than the page size means another page follows.
+	items := []msg{{at, "c"}, {at.Add(-time.Minute), "b"}, {at.Add(-2 * time.Minute), "a"}}
+	page := keysetPage(items, pageParams{PageSize: 2}, 10, cursor)
+	if len(page.Data) != 2 || page.Total != 10 || page.Page != 0 || page.NextCursor == "" {
+		t.Fatalf("page = %+v", page)
+	}
+	gotAt, gotID, err := decodeKeysetCursor(page.NextCursor)
+	if err != nil || !gotAt.Equal(at.Add(-time.Minute)) || gotID != "b" {
+		t.Errorf("cursor decodes to %v %q %v, want the last kept message", gotAt, gotID, err)
+	}
+
+	if page := keysetPage(items[:2], pageParams{PageSize: 2}, 2, cursor); page.NextCursor != "" {
+		t.Errorf("last page has cursor %q", page.NextCursor)
+	}
+	if _, _, err := decodeKeysetCursor("not a cursor"); err == nil {
+		t.Error("malformed cursor accepted")
+	}
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	WalkingLegParking        = "parking"
+	WalkingLegPedestrianZone = "pedestrian_zone"
+
+	// A driving route that stops at least this far from the destination couldn't get there
+	// by car, which in practice means a pedestrian zone or a closed precinct.
+	pedestrianZoneGapM = 75
+	// Beyond this the destination is more likely off the road network entirely than a
+	// short walk away, so no walking leg is offered.
+	maxWalkingLegM = 2000
+)
+
+// addWalkingLeg turns a driving route into park-and-walk when the trip has to end on foot:
+// the arrival point is a curated parking spot, or the route stops well short of the
+// destination. The walking leg is routed by Valhalla's pedestrian costing from where the
+// driving route ends. Failures leave the driving route as it is.
+func (api *API) addWalkingLeg(ctx context.Context, resp *mapbox.DirectionsResponse, req RouteRequest, destination Location) {
+	if resp == nil || len(resp.Routes) == 0 || api.ValhallaClient == nil {
+		return
+	}
+	coords := resp.Routes[0].Geometry.Coordinates
+	if len(coords) == 0 || len(coords[len(coords)-1]) < 2 {
+		return
+	}
+	end := Location{Lat: coords[len(coords)-1][1], Lng: coords[len(coords)-1][0]}
+
+	gap := util.HaversineMeters(end.Lat, end.Lng, destination.Lat, destination.Lng)
+	var reason string
+	switch {
+	case resp.Arrival != nil && resp.Arrival.Kind == WalkingLegParking:
+		reason = WalkingLegParking
+	case gap >= pedestrianZoneGapM:
+		reason = WalkingLegPedestrianZone
+	default:
+		return
+	}
+	if gap > maxWalkingLegM {
+		return
+	}
+
+	walkReq := RouteRequest{
+		Locations:          []Location{end, destination},
+		Profile:            "walking",
+		VoiceInstructions:  req.VoiceInstructions,
+		BannerInstructions: req.BannerInstructions,
+		VoiceUnits:         req.VoiceUnits,
+		Language:           req.Language,
+	}
+	walk, err := api.getValhallaRoute(ctx, walkReq, values.RoutePreferenceFastest)
+	if err != nil || len(walk.Routes) == 0 {
+		api.logger().WarnContext(ctx, "Failed to route walking leg, returning driving route only", "err", err)
+		return
+	}
+
+	leg := walk.Routes[0]
+	resp.ParkAndWalk = true
+	resp.WalkingLeg = &mapbox.WalkingLeg{
+		Reason:   reason,
+		StartsAt: []float64{end.Lng, end.Lat},
+		Route:    leg,
+	}
+	// The walk is now routed, so report its real length rather than the straight line.
+	if resp.Arrival != nil {
+		resp.Arrival.WalkingDistanceM = leg.Distance
+		resp.Arrival.WalkingDurationS = leg.Duration
+	}
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	phoneCodeLength = 6
+	phoneCodeTTL    = 10 * time.Minute
+	// A number gets one code a minute and five an hour, which bounds SMS spend and
+	// keeps a number from being flooded; the phone_code rate limit bounds callers.
+	phoneCodeCooldown   = time.Minute
+	phoneCodesPerHour   = 5
+	phoneCodeMaxGuesses = 5
+)
+
+// RequestPhoneCodeHelper texts a login code to the number. The number doesn't need an
+// account yet; verifying the code creates one.
+func (api *API) RequestPhoneCodeHelper(ctx context.Context, req model.PhoneCodeRequest) (model.PhoneCodeResponse, string, string, error) {
+	if api.SMS == nil {
+		return model.PhoneCodeResponse{}, values.Unavailable, "Phone login is not available", errors.New("sms sender not configured")
+	}
+	phone, err := util.NormalizePhone(req.Phone)
+	if err != nil {
+		return model.PhoneCodeResponse{}, values.BadRequestBody, "Invalid phone number", err
+	}
+
+	sent, latest, err := api.PhoneCodeStatsRepo(ctx, phone, time.Now().Add(-time.Hour))
+	if err != nil {
+		return model.PhoneCodeResponse{}, values.Error, "Failed to send code", err
+	}
+	if sent >= phoneCodesPerHour || time.Since(latest) < phoneCodeCooldown {
+		return model.PhoneCodeResponse{}, values.TooManyRequests, "A code was sent recently, please wait before asking again",
+			fmt.Errorf("phone code throttled: %d sent in the last hour", sent)
+	}
+
+	code, err := util.GenerateNumericCode(phoneCodeLength)
+	if err != nil {
+		return model.PhoneCodeResponse{}, values.Error, "Failed to send code", err
+	}
+	expiresAt := time.Now().Add(phoneCodeTTL)
+	if err := api.StorePhoneCodeRepo(ctx, phone, code, expiresAt); err != nil {
+		return model.PhoneCodeResponse{}, values.Error, "Failed to send code", err
+	}
+	api.logger().DebugContext(ctx, "Phone code issued", "phone", util.MaskPhone(phone))
+
+	body := fmt.Sprintf("Your Waze Kibris code is %s. It expires in %d minutes.", code, int(phoneCodeTTL.Minutes()))
+	if err := api.SMS.Send(ctx, phone, body); err != nil {
+		return model.PhoneCodeResponse{}, values.Error, "Failed to send code", err
+	}
+	return model.PhoneCodeResponse{Phone: phone, ExpiresAt: expiresAt}, values.Success, "Verification code sent", nil
+}
+
+// VerifyPhoneCodeHelper exchanges a code for tokens, signing up the number on its first
+// login.
+func (api *API) VerifyPhoneCodeHelper(ctx context.Context, req model.PhoneVerifyRequest) (model.LoginResponse, string, string, error) {
+	phone, err := util.NormalizePhone(req.Phone)
+	if err != nil {
+		return model.LoginResponse{}, values.BadRequestBody, "Invalid phone number", err
+	}
+
+	err = api.ConsumePhoneCodeRepo(ctx, phone, req.Code, phoneCodeMaxGuesses)
+	switch {
+	case errors.Is(err, ErrPhoneCodeLocked):
+		return model.LoginResponse{}, values.NotAuthorised, "Too many wrong codes, please request a new one", err
+	case errors.Is(err, ErrPhoneCodeInvalid):
+		return model.LoginResponse{}, values.NotAuthorised, "Invalid or expired verification code", err
+	case err != nil:
+		return model.LoginResponse{}, values.Error, "Failed to verify code", err
+	}
+
+	user, err := api.phoneUser(ctx, phone)
+	if err != nil {
+		return model.LoginResponse{}, values.Error, "Failed to sign in", err
+	}
+	return api.generateAndStoreTokens(user)
+}
+
+// phoneUser finds the account for a verified number or creates one, retrying when the
+// generated username is taken.
+func (api *API) phoneUser(ctx context.Context, phone string) (model.User, error) {
+	const maxAttempts = 5
+	icon := defaultProfileIcons[rand.Intn(len(defaultProfileIcons))]
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		user, err := api.GetUserByPhoneRepo(ctx, phone)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return model.User{}, err
+		}
+
+		displayName := util.GenerateDisplayName()
+		user = model.User{
+			ID:           util.GenerateUUID(),
+			Phone:        &phone,
+			AuthProvider: "phone",
+			Username:     &displayName,
+			ProfileIcon:  &icon,
+		}
+		err = api.CreatePhoneUserRepo(ctx, user)
+		if err == nil {
+			return user, nil
+		}
+		// Either the username was taken or the same number signed up concurrently;
+		// the next pass tells them apart.
+		if !errors.Is(err, ErrConflict) {
+			return model.User{}, err
+		}
+	}
+	return model.User{}, errors.New("failed to generate unique username")
+}
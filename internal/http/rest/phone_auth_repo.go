@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPhoneCodeInvalid = errors.New("invalid or expired phone code")
+	// ErrPhoneCodeLocked means the newest code took too many wrong guesses; a new one has
+	// to be requested.
+	ErrPhoneCodeLocked = errors.New("phone code locked after too many attempts")
+)
+
+// PhoneCodeStatsRepo returns how many codes were sent to phone since the given time and
+// when the newest of them was sent (zero if none).
+func (api *API) PhoneCodeStatsRepo(ctx context.Context, phone string, since time.Time) (int, time.Time, error) {
+	var count int
+	var latest *time.Time
+	err := api.DB.QueryRow(ctx, `
+		SELECT COUNT(*), MAX(created_at)
+		FROM phone_verifications
+		WHERE phone = $1 AND created_at > $2`, phone, since).Scan(&count, &latest)
+	if err != nil {
+		return 0, time.Time{}, dbError(err)
+	}
+	if latest == nil {
+		return count, time.Time{}, nil
+	}
+	return count, *latest, nil
+}
+
+func (api *API) StorePhoneCodeRepo(ctx context.Context, phone, code string, expiresAt time.Time) error {
+	_, err := api.DB.Exec(ctx, `
+		INSERT INTO phone_verifications (phone, code, expires_at)
+		VALUES ($1, $2, $3)`, phone, code, expiresAt)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// ConsumePhoneCodeRepo checks code against the newest unexpired code for phone. A wrong
+// guess is counted against that code; a right one deletes every code for the number so
+// none can be used again.
+func (api *API) ConsumePhoneCodeRepo(ctx context.Context, phone, code string, maxAttempts int) error {
+	return pgx.BeginFunc(ctx, api.DB, func(tx pgx.Tx) error {
+		var id int64
+		var stored string
+		var attempts int
+		err := tx.QueryRow(ctx, `
+			SELECT id, code, attempts
+			FROM phone_verifications
+			WHERE phone = $1 AND expires_at > NOW()
+			ORDER BY created_at DESC
+			LIMIT 1
+			FOR UPDATE`, phone).Scan(&id, &stored, &attempts)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPhoneCodeInvalid
+		}
+		if err != nil {
+			return dbError(err)
+		}
+		if attempts >= maxAttempts {
+			return ErrPhoneCodeLocked
+		}
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(code)) != 1 {
+			if _, err := tx.Exec(ctx, `UPDATE phone_verifications SET attempts = attempts + 1 WHERE id = $1`, id); err != nil {
+				return dbError(err)
+			}
+			return ErrPhoneCodeInvalid
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM phone_verifications WHERE phone = $1`, phone); err != nil {
+			return dbError(err)
+		}
+		return nil
+	})
+}
+
+func (api *API) GetUserByPhoneRepo(ctx context.Context, phone string) (model.User, error) {
+	var user model.User
+	err := api.DB.QueryRow(ctx, `SELECT id, phone FROM users WHERE phone = $1`, phone).Scan(&user.ID, &user.Phone)
+	if err != nil {
+		return model.User{}, dbError(err)
+	}
+	return user, nil
+}
+
+// CreatePhoneUserRepo inserts an account for a verified phone number. ErrConflict means
+// the username or the number is already taken.
+func (api *API) CreatePhoneUserRepo(ctx context.Context, user model.User) error {
+	_, err := api.DB.Exec(ctx, `
+		INSERT INTO users (id, phone, auth_provider, is_verified, username, profile_icon)
+		VALUES ($1, $2, $3, TRUE, $4, $5)`,
+		user.ID, user.Phone, user.AuthProvider, user.Username, user.ProfileIcon)
+	if err != nil {
+		return fmt.Errorf("creating phone user: %w", dbError(err))
+	}
+	return nil
+}
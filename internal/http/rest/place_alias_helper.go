@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const placeAliasCacheTTL = 5 * time.Minute
+
+// placeAliasIndex looks aliases up by provider id and by any of their names.
+type placeAliasIndex struct {
+	byGID  map[string]*model.PlaceAlias
+	byName map[string]*model.PlaceAlias
+}
+
+func newPlaceAliasIndex(aliases []model.PlaceAlias) *placeAliasIndex {
+	ix := &placeAliasIndex{byGID: make(map[string]*model.PlaceAlias), byName: make(map[string]*model.PlaceAlias)}
+	for i := range aliases {
+		a := &aliases[i]
+		for _, gid := range a.GIDs {
+			ix.byGID[gid] = a
+		}
+		for _, name := range a.Names {
+			ix.byName[normalizePlaceName(name)] = a
+		}
+	}
+	return ix
+}
+
+func normalizePlaceName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// lookup finds the alias for a place, by gid first and then by name.
+func (ix *placeAliasIndex) lookup(gid, name string) *model.PlaceAlias {
+	if ix == nil {
+		return nil
+	}
+	if a, ok := ix.byGID[gid]; ok && gid != "" {
+		return a
+	}
+	return ix.byName[normalizePlaceName(name)]
+}
+
+// name returns the place's name in lang, and all its names, when it has an alias.
+// Otherwise name comes back unchanged with no names.
+func (ix *placeAliasIndex) name(gid, name, lang string) (string, map[string]string) {
+	a := ix.lookup(gid, name)
+	if a == nil {
+		return name, nil
+	}
+	if localized, ok := a.Names[lang]; ok {
+		return localized, a.Names
+	}
+	return name, a.Names
+}
+
+// address localizes each comma-separated part of an address or label, e.g.
+// "Dereboyu Caddesi, Lefkoşa, Cyprus" -> "Dereboyu Caddesi, Nicosia, Cyprus".
+func (ix *placeAliasIndex) address(address, lang string) string {
+	if ix == nil || address == "" {
+		return address
+	}
+	parts := strings.Split(address, ",")
+	for i, part := range parts {
+		if localized, names := ix.name("", part, lang); names != nil {
+			parts[i] = strings.Replace(part, strings.TrimSpace(part), localized, 1)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// placeAliasCache keeps the alias table in memory, like expiryPolicyCache. Admin edits
+// invalidate it.
+type placeAliasCache struct {
+	mu       sync.RWMutex
+	index    *placeAliasIndex
+	loadedAt time.Time
+}
+
+func (c *placeAliasCache) get() (*placeAliasIndex, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadedAt.IsZero() || time.Since(c.loadedAt) > placeAliasCacheTTL {
+		return nil, false
+	}
+	return c.index, true
+}
+
+func (c *placeAliasCache) set(index *placeAliasIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index = index
+	c.loadedAt = time.Now()
+}
+
+func (c *placeAliasCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadedAt = time.Time{}
+}
+
+// placeAliasLookup returns the cached aliases. If they can't be loaded, names are left as
+// the provider returned them.
+func (api *API) placeAliasLookup(ctx context.Context) *placeAliasIndex {
+	if ix, ok := api.placeAliases.get(); ok {
+		return ix
+	}
+	aliases, err := api.ListPlaceAliasesRepo(ctx)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to load place aliases", "err", err)
+		return nil
+	}
+	ix := newPlaceAliasIndex(aliases)
+	api.placeAliases.set(ix)
+	return ix
+}
+
+// localizePlaces renames places with a known alias into lang, along with their town and
+// region names.
+func (api *API) localizePlaces(ctx context.Context, places []model.Place, lang string) {
+	ix := api.placeAliasLookup(ctx)
+	for i := range places {
+		p := &places[i]
+		p.Name, p.Names = ix.name(p.GID, p.Name, lang)
+		p.Address = ix.address(p.Address, lang)
+		if p.Locality != "" {
+			p.Locality, _ = ix.name("", p.Locality, lang)
+		}
+		if p.Region != "" {
+			p.Region, _ = ix.name("", p.Region, lang)
+		}
+	}
+}
+
+func (api *API) localizeGooglePlaceDetails(ctx context.Context, r *googlemaps.PlaceDetailsResult, lang string) {
+	ix := api.placeAliasLookup(ctx)
+	r.Name, _ = ix.name(googleGID(r.PlaceID), r.Name, lang)
+	r.FormattedAddress = ix.address(r.FormattedAddress, lang)
+}
+
+func (api *API) ListPlaceAliasesHelper(ctx context.Context) ([]model.PlaceAlias, string, string, error) {
+	aliases, err := api.ListPlaceAliasesRepo(ctx)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch place aliases", err
+	}
+	if aliases == nil {
+		aliases = []model.PlaceAlias{}
+	}
+	return aliases, values.Success, "Place aliases fetched successfully", nil
+}
+
+func (api *API) UpsertPlaceAliasHelper(ctx context.Context, req model.UpsertPlaceAliasRequest, adminID uuid.UUID) (model.PlaceAlias, string, string, error) {
+	req.Key = strings.ToLower(strings.TrimSpace(req.Key))
+	names := make(map[string]string, len(req.Names))
+	for lang, name := range req.Names {
+		names[strings.ToLower(lang)] = strings.TrimSpace(name)
+	}
+	req.Names = names
+
+	alias, err := api.UpsertPlaceAliasRepo(ctx, req, adminID)
+	if err != nil {
+		return model.PlaceAlias{}, values.Error, "Failed to save place alias", err
+	}
+	api.placeAliases.invalidate()
+	return alias, values.Success, "Place alias saved", nil
+}
+
+func (api *API) DeletePlaceAliasHelper(ctx context.Context, id int64) (string, string, error) {
+	if err := api.DeletePlaceAliasRepo(ctx, id); err != nil {
+		if errors.Is(err, ErrPlaceAliasNotFound) {
+			return values.NotFound, "Place alias not found", err
+		}
+		return values.Error, "Failed to delete place alias", err
+	}
+	api.placeAliases.invalidate()
+	return values.Success, "Place alias deleted", nil
+}
+
+// setGeocodeLanguage asks the geocoder for names in lang unless the request chose one.
+func setGeocodeLanguage(params *stadiamaps.GeocodeQuery, lang string) {
+	if params.Lang == nil && lang != "" {
+		params.Lang = &lang
+	}
+}
@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrPlaceAliasNotFound = fmt.Errorf("place alias %w", ErrNotFound)
+
+const placeAliasColumns = `id, key, names, gids, updated_by, updated_at`
+
+func scanPlaceAlias(row pgx.Row) (model.PlaceAlias, error) {
+	var a model.PlaceAlias
+	err := row.Scan(&a.ID, &a.Key, &a.Names, &a.GIDs, &a.UpdatedBy, &a.UpdatedAt)
+	return a, err
+}
+
+func (api *API) ListPlaceAliasesRepo(ctx context.Context) ([]model.PlaceAlias, error) {
+	rows, err := api.DB.Query(ctx, `SELECT `+placeAliasColumns+` FROM place_name_aliases ORDER BY key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []model.PlaceAlias
+	for rows.Next() {
+		a, err := scanPlaceAlias(rows)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+func (api *API) UpsertPlaceAliasRepo(ctx context.Context, req model.UpsertPlaceAliasRequest, updatedBy uuid.UUID) (model.PlaceAlias, error) {
+	query := `
+        INSERT INTO place_name_aliases (key, names, gids, updated_by, updated_at)
+        VALUES ($1, $2, $3, $4, NOW())
+        ON CONFLICT (key) DO UPDATE SET
+            names = EXCLUDED.names,
+            gids = EXCLUDED.gids,
+            updated_by = EXCLUDED.updated_by,
+            updated_at = NOW()
+        RETURNING ` + placeAliasColumns
+	gids := req.GIDs
+	if gids == nil {
+		gids = []string{}
+	}
+	return scanPlaceAlias(api.DB.QueryRow(ctx, query, req.Key, req.Names, gids, updatedBy))
+}
+
+func (api *API) DeletePlaceAliasRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM place_name_aliases WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPlaceAliasNotFound
+	}
+	return nil
+}
@@ -2,15 +2,17 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
 	"github.com/bwise1/waze_kibris/internal/http/mapbox"
 	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps" // Import stadia_maps
+	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/tracing"
 	"github.com/bwise1/waze_kibris/util/values"
@@ -24,26 +26,41 @@ func (api *API) PlacesRoutes() chi.Router {
 	mux.Group(func(r chi.Router) {
 		r.Use(api.RequireLogin) // Authentication required for all Places API endpoints
 
+		// Every geocoding endpoint answers with model.Place and takes ?provider=stadia|google
+		// to skip automatic provider selection, and ?format=geojson for a FeatureCollection
+		// of place Points instead.
+
 		// Forward Geocoding (Search for an address/place)
 		// Query Params: ?text=...&size=...&layers=...&boundary.country=...
-		r.Method(http.MethodGet, "/search", Handler(api.SearchPlacesHandler))
+		r.With(api.RateLimit(RateLimitPlaces)).Method(http.MethodGet, "/search", Handler(api.SearchPlacesHandler))
+
+		// Saved locations and all geocoders in one request; slow sources are dropped and
+		// listed in the response's sources with degraded set.
+		// Query Params: same as /search
+		r.With(api.RateLimit(RateLimitPlaces)).Method(http.MethodGet, "/unified-search", Handler(api.UnifiedSearchHandler))
 
 		// Reverse Geocoding (Find address for lat/lon)
 		// Query Params: ?point.lat=...&point.lon=...&size=...&layers=...
-		r.Method(http.MethodGet, "/reverse", Handler(api.ReverseGeocodeHandler))
+		r.With(api.RateLimit(RateLimitPlaces)).Method(http.MethodGet, "/reverse", Handler(api.ReverseGeocodeHandler))
 
 		// Autocomplete (Get suggestions for partial address/place)
 		// Query Params: ?text=...&size=...&focus.point.lat=...&focus.point.lon=... (optional focus)
-		r.Method(http.MethodGet, "/autocomplete", Handler(api.AutocompletePlaceHandler))
+		r.With(api.RateLimit(RateLimitPlaces), api.EnforceQuota(QuotaAutocomplete)).Method(http.MethodGet, "/autocomplete", Handler(api.AutocompletePlaceHandler))
 
-		// r.Method(http.MethodGet, "/placedetails", Handler(api.PlaceDetailHandler))
-		r.Method(http.MethodGet, "/googleplacedetails", Handler(api.GooglePlaceDetailHandler))
+		// Details of a place returned by the endpoints above, from the provider it came from
+		// Query Params: ?gid=...
+		r.With(api.RateLimit(RateLimitPlaces)).Method(http.MethodGet, "/placedetails", Handler(api.PlaceDetailHandler))
+		r.With(api.RateLimit(RateLimitPlaces)).Method(http.MethodGet, "/googleplacedetails", Handler(api.GooglePlaceDetailHandler))
 
-		r.Method(http.MethodGet, "/googleautocomplete", Handler(api.GoogleAutocompleteHandler))
+		r.With(api.RateLimit(RateLimitPlaces), api.EnforceQuota(QuotaAutocomplete)).Method(http.MethodGet, "/googleautocomplete", Handler(api.GoogleAutocompleteHandler))
 
-		r.Method(http.MethodGet, "/googledirections", Handler(api.GoogleDirectionsHandler))
-		r.Method(http.MethodGet, "/mapboxdirections", Handler(api.MapboxDirectionsHandler))
+		r.With(api.RateLimit(RateLimitDirections), api.EnforceQuota(QuotaDirections)).Method(http.MethodGet, "/googledirections", Handler(api.GoogleDirectionsHandler))
+		r.With(api.RateLimit(RateLimitDirections), api.EnforceQuota(QuotaDirections)).Method(http.MethodGet, "/mapboxdirections", Handler(api.MapboxDirectionsHandler))
 		
+		// Local POIs (fuel, pharmacies, restaurants, ...) along a route, grouped by category
+		// Request Body: { "geometry": [[33.36, 35.18], ...], "categories": ["fuel"], "corridor_width_m": 300, "limit": 20 }
+		r.Method(http.MethodPost, "/prefetch", Handler(api.PrefetchRoutePOIsHandler))
+
 		// Map Matching for edge cases - POST to handle GPS coordinate arrays
 		r.Method(http.MethodPost, "/mapboxmapmatching", Handler(api.MapboxMapMatchingHandler))
 	})
@@ -58,10 +75,48 @@ func (api *API) SearchPlacesHandler(w http.ResponseWriter, r *http.Request) *Ser
 		return respondWithError(nil, "Missing tracing context", values.SystemErr, nil)
 	}
 
-	queryParams := r.URL.Query()
+	geocodeParams, msg, err := parsePlaceSearchQuery(r.URL.Query())
+	if msg != "" {
+		return respondWithError(err, msg, values.BadRequestBody, &tc)
+	}
+	provider, msg := api.geocoderParam(r)
+	if msg != "" {
+		return respondWithError(nil, msg, values.BadRequestBody, &tc)
+	}
+	text := geocodeParams.Text
+	lang := requestLanguage(r)
+	setGeocodeLanguage(geocodeParams, lang)
+
+	// Search with the healthiest geocoding provider, falling back to the others
+	results, err := api.searchPlaces(r.Context(), provider, text, geocodeParams)
+	if err != nil {
+		if providerRateLimited(err) {
+			return respondWithError(err, "Rate limit exceeded", values.SystemErr, &tc)
+		}
+		return respondWithError(err, "Failed to search places", values.SystemErr, &tc)
+	}
+	api.localizePlaces(r.Context(), results, lang)
+
+	response := &ServerResponse{
+		Message:    "Places searched successfully",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+		Data:       results,
+	}
+	// if err := json.NewEncoder(w).Encode(response); err != nil {
+	// 	log.Printf("Error encoding response [%s]: %v", tc.RequestID, err)
+	// 	return respondWithError(err, "Failed to encode response", values.SystemErr, &tc)
+	// }
+
+	return response
+}
+
+// parsePlaceSearchQuery reads the /search and /unified-search query parameters. A non-empty
+// message means the request is invalid.
+func parsePlaceSearchQuery(queryParams url.Values) (*stadiamaps.GeocodeQuery, string, error) {
 	text := strings.TrimSpace(queryParams.Get("text"))
 	if text == "" {
-		return respondWithError(nil, "Missing or empty 'text' query parameter", values.BadRequestBody, &tc)
+		return nil, "Missing or empty 'text' query parameter", nil
 	}
 
 	// Build GeocodeQuery from URL parameters
@@ -69,7 +124,7 @@ func (api *API) SearchPlacesHandler(w http.ResponseWriter, r *http.Request) *Ser
 	if sizeStr := queryParams.Get("size"); sizeStr != "" {
 		size, err := strconv.Atoi(sizeStr)
 		if err != nil || size < 1 || size > 100 { // Stadia typically limits to 100
-			return respondWithError(err, "Invalid 'size' parameter", values.BadRequestBody, &tc)
+			return nil, "Invalid 'size' parameter", err
 		}
 		geocodeParams.Size = util.IntPtr(size)
 	}
@@ -77,7 +132,7 @@ func (api *API) SearchPlacesHandler(w http.ResponseWriter, r *http.Request) *Ser
 		validLayers := map[string]bool{"address": true, "venue": true, "street": true, "locality": true} // Add more as needed
 		for _, layer := range layers {
 			if !validLayers[layer] {
-				return respondWithError(nil, "Invalid 'layers' parameter", values.BadRequestBody, &tc)
+				return nil, "Invalid 'layers' parameter", nil
 			}
 		}
 		geocodeParams.Layers = layers
@@ -86,55 +141,49 @@ func (api *API) SearchPlacesHandler(w http.ResponseWriter, r *http.Request) *Ser
 		lat, err1 := strconv.ParseFloat(latStr, 64)
 		lon, err2 := strconv.ParseFloat(lonStr, 64)
 		if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
-			return respondWithError(nil, "Invalid 'focus.point' coordinates", values.BadRequestBody, &tc)
+			return nil, "Invalid 'focus.point' coordinates", nil
 		}
 		geocodeParams.FocusPointLat = &lat
 		geocodeParams.FocusPointLon = &lon
 	}
+	return geocodeParams, "", nil
+}
+
+// UnifiedSearchHandler searches the user's saved locations and every geocoder at once,
+// answering with whatever came back within the per-source budget.
+func (api *API) UnifiedSearchHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
-	// Perform search using Stadia Maps v2 API
-	results, err := api.StadiaClient.Search(r.Context(), text, geocodeParams)
+	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
-		// Check for specific API errors (e.g., rate limits)
-		if strings.Contains(err.Error(), "429") {
-			return respondWithError(err, "Rate limit exceeded", values.SystemErr, &tc)
-		}
-		return respondWithError(err, "Failed to search places", values.SystemErr, &tc)
+		return respondWithError(err, "Unauthorized", values.NotAuthorised, &tc)
 	}
-
-	// Optionally transform results for the frontend
-	type simplifiedResult struct {
-		Name        string    `json:"name"`
-		Address     string    `json:"address"`
-		Coordinates []float64 `json:"coordinates"`
-		GID         string    `json:"gid"`
+	geocodeParams, msg, err := parsePlaceSearchQuery(r.URL.Query())
+	if msg != "" {
+		return respondWithError(err, msg, values.BadRequestBody, &tc)
 	}
-	var simplified []simplifiedResult
-	for _, feature := range results.Features {
-		coords := []float64{}
-		if feature.Geometry != nil {
-			coords = feature.Geometry.Coordinates
-		}
-		simplified = append(simplified, simplifiedResult{
-			Name:        feature.Properties["name"].(string),
-			Address:     feature.Properties["label"].(string),
-			Coordinates: coords,
-			GID:         feature.Properties["gid"].(string),
-		})
+	provider, msg := api.geocoderParam(r)
+	if msg != "" {
+		return respondWithError(nil, msg, values.BadRequestBody, &tc)
 	}
 
-	response := &ServerResponse{
-		Message:    "Places searched successfully",
+	lang := requestLanguage(r)
+	setGeocodeLanguage(geocodeParams, lang)
+	results, err := api.unifiedSearch(r.Context(), userID, provider, geocodeParams.Text, geocodeParams)
+	if err != nil {
+		return respondWithError(err, "Failed to search places", values.SystemErr, &tc)
+	}
+	api.localizePlaces(r.Context(), results.Results, lang)
+	message := "Places searched successfully"
+	if results.Degraded {
+		message = "Places searched; some sources were unavailable"
+	}
+	return &ServerResponse{
+		Message:    message,
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
-		Data:       simplified,
+		Data:       results,
 	}
-	// if err := json.NewEncoder(w).Encode(response); err != nil {
-	// 	log.Printf("Error encoding response [%s]: %v", tc.RequestID, err)
-	// 	return respondWithError(err, "Failed to encode response", values.SystemErr, &tc)
-	// }
-
-	return response
 }
 
 func (api *API) ReverseGeocodeHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
@@ -154,6 +203,10 @@ func (api *API) ReverseGeocodeHandler(_ http.ResponseWriter, r *http.Request) *S
 	if errLat != nil || errLon != nil {
 		return respondWithError(nil, "Invalid latitude or longitude format", values.BadRequestBody, &tc)
 	}
+	provider, msg := api.geocoderParam(r)
+	if msg != "" {
+		return respondWithError(nil, msg, values.BadRequestBody, &tc)
+	}
 
 	geocodeParams := &stadiamaps.GeocodeQuery{} // Initialize empty or parse other params
 	if sizeStr := queryParams.Get("size"); sizeStr != "" {
@@ -165,12 +218,15 @@ func (api *API) ReverseGeocodeHandler(_ http.ResponseWriter, r *http.Request) *S
 		geocodeParams.Layers = layers
 	}
 	// Add more params as needed
+	lang := requestLanguage(r)
+	setGeocodeLanguage(geocodeParams, lang)
 
-	results, err := api.StadiaClient.ReverseGeocode(r.Context(), lat, lon, geocodeParams)
+	results, err := api.reverseGeocode(r.Context(), provider, lat, lon, geocodeParams)
 	if err != nil {
-		log.Printf("Error reverse geocoding with Stadia: %v", err)
+		api.logger().ErrorContext(r.Context(), "Reverse geocoding failed", "err", err)
 		return respondWithError(err, "Failed to reverse geocode", values.Error, &tc)
 	}
+	api.localizePlaces(r.Context(), results, lang)
 
 	return &ServerResponse{
 		Message:    "Reverse geocoding successful",
@@ -188,6 +244,10 @@ func (api *API) AutocompletePlaceHandler(_ http.ResponseWriter, r *http.Request)
 	if text == "" {
 		return respondWithError(nil, "Missing 'text' query parameter for autocomplete", values.BadRequestBody, &tc)
 	}
+	provider, msg := api.geocoderParam(r)
+	if msg != "" {
+		return respondWithError(nil, msg, values.BadRequestBody, &tc)
+	}
 
 	geocodeParams := &stadiamaps.GeocodeQuery{Text: text}
 	if sizeStr := queryParams.Get("size"); sizeStr != "" {
@@ -204,12 +264,15 @@ func (api *API) AutocompletePlaceHandler(_ http.ResponseWriter, r *http.Request)
 	// 	}
 	// }
 	// Add more params as needed
+	lang := requestLanguage(r)
+	setGeocodeLanguage(geocodeParams, lang)
 
-	results, err := api.StadiaClient.Autocomplete(r.Context(), text, geocodeParams)
+	results, err := api.autocompletePlaces(r.Context(), provider, text, geocodeParams)
 	if err != nil {
-		log.Printf("Error autocompleting place with Stadia: %v", err)
+		api.logger().ErrorContext(r.Context(), "Place autocomplete failed", "err", err)
 		return respondWithError(err, "Failed to autocomplete place", values.Error, &tc)
 	}
+	api.localizePlaces(r.Context(), results, lang)
 
 	return &ServerResponse{
 		Message:    "Autocomplete successful",
@@ -219,56 +282,49 @@ func (api *API) AutocompletePlaceHandler(_ http.ResponseWriter, r *http.Request)
 	}
 }
 
-// ... (other existing code in places_handler.go)
-
+// PlaceDetailHandler looks up a gid from search, reverse or autocomplete with the provider
+// that returned it; ?provider= may only name that provider.
 func (api *API) PlaceDetailHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
-	tc, ok := r.Context().Value(values.ContextTracingKey).(tracing.Context)
-	if !ok {
-		log.Println("Warning: Missing tracing context in PlaceDetailHandler")
-		// Consider returning a default error or handling as appropriate
-	}
-	queryParams := r.URL.Query()
-	gid := strings.TrimSpace(queryParams.Get("gid"))
-
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	gid := strings.TrimSpace(r.URL.Query().Get("gid"))
 	if gid == "" {
 		return respondWithError(nil, "Missing 'gid' query parameter", values.BadRequestBody, &tc)
 	}
+	provider, msg := api.geocoderParam(r)
+	if msg != "" {
+		return respondWithError(nil, msg, values.BadRequestBody, &tc)
+	}
+	owner := gidGeocoder(gid)
+	if provider != "" && provider != owner {
+		return respondWithError(nil, fmt.Sprintf("gid belongs to provider %s", owner), values.BadRequestBody, &tc)
+	}
+	if !api.geocoderConfigured(owner) {
+		return respondWithError(nil, fmt.Sprintf("provider %s is not configured", owner), values.BadRequestBody, &tc)
+	}
 
-	// The PlaceDetail method in stadia.go now takes only the GID
-	// and returns *stadiamaps.PlaceDetailResponse
-	placeData, err := api.StadiaClient.PlaceDetail(r.Context(), gid)
+	place, err := api.placeDetails(r.Context(), gid)
 	if err != nil {
-		log.Printf("Error fetching place details from Stadia (v2) for GID %s: %v", gid, err)
-		// Check for specific API errors (e.g., rate limits, not found)
-		// The error message from c.do in stadia.go will include the status code.
-		if strings.Contains(err.Error(), "status 404") { // Example check for 404
+		api.logger().ErrorContext(r.Context(), "Place details failed", "gid", gid, "err", err)
+		if providerNotFound(err) {
 			return respondWithError(err, "Place details not found for the given GID", values.NotFound, &tc)
 		}
-		if strings.Contains(err.Error(), "status 429") { // Example check for 429
-			return respondWithError(err, "Rate limit exceeded", values.SystemErr, &tc)
-		}
 		return respondWithError(err, "Failed to fetch place details", values.SystemErr, &tc)
 	}
-
-	// The placeData is now directly *stadiamaps.PlaceDetailResponse,
-	// which represents a single GeoJSON Feature.
-	if placeData == nil { // Should not happen if err is nil, but good for robustness
-		log.Printf("No data returned for GID %s from PlaceDetail (v2), though API call succeeded.", gid)
-		return respondWithError(nil, "Place details not found for the given GID (no data)", values.NotFound, &tc)
-	}
+	places := []model.Place{*place}
+	api.localizePlaces(r.Context(), places, requestLanguage(r))
 
 	return &ServerResponse{
 		Message:    "Place details retrieved successfully",
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
-		Data:       placeData, // Return the PlaceDetailResponse directly
+		Data:       places[0],
 	}
 }
 
 func (api *API) GooglePlaceDetailHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc, ok := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 	if !ok {
-		log.Println("Warning: Missing tracing context in GooglePlaceDetailHandler")
+		api.logger().WarnContext(r.Context(), "Missing tracing context")
 	}
 	queryParams := r.URL.Query()
 	placeID := strings.TrimSpace(queryParams.Get("place_id"))
@@ -284,14 +340,15 @@ func (api *API) GooglePlaceDetailHandler(_ http.ResponseWriter, r *http.Request)
 
 	placeData, err := api.GoogleMapsClient.GetPlaceDetails(r.Context(), placeID, fields)
 	if err != nil {
-		log.Printf("Error fetching place details from Google for PlaceID %s: %v", placeID, err)
+		api.logger().ErrorContext(r.Context(), "Google place details failed", "place_id", placeID, "err", err)
 		return respondWithError(err, "Failed to fetch place details", values.SystemErr, &tc)
 	}
 
 	if placeData == nil {
-		log.Printf("No data returned for PlaceID %s from Google Place Details.", placeID)
+		api.logger().WarnContext(r.Context(), "Google place details returned no data", "place_id", placeID)
 		return respondWithError(nil, "No place details found", values.NotFound, &tc)
 	}
+	api.localizeGooglePlaceDetails(r.Context(), placeData, requestLanguage(r))
 
 	return &ServerResponse{
 		Message:    "Place details fetched successfully (Google)",
@@ -326,7 +383,7 @@ func (api *API) GoogleAutocompleteHandler(_ http.ResponseWriter, r *http.Request
 			origin = &googlemaps.LatLng{Lat: lat, Lng: lon}
 		} else {
 			// Optional: return an error for invalid coordinates
-			log.Printf("Invalid latitude/longitude format: lat=%s, lon=%s", latStr, lonStr)
+			api.logger().InfoContext(r.Context(), "Invalid latitude/longitude", "lat", latStr, "lon", lonStr)
 			return respondWithError(nil, "Invalid 'lat' or 'lon' query parameter format", values.BadRequestBody, &tc)
 		}
 	}
@@ -344,7 +401,7 @@ func (api *API) GoogleAutocompleteHandler(_ http.ResponseWriter, r *http.Request
 	// Pass the parsed 'origin' to your client function.
 	results, err := api.GoogleMapsClient.PlaceAutocomplete(r.Context(), text, origin, radius)
 	if err != nil {
-		log.Printf("Error autocompleting place with Google: %v", err)
+		api.logger().ErrorContext(r.Context(), "Google place autocomplete failed", "err", err)
 		return respondWithError(err, "Failed to autocomplete place (Google)", values.Error, &tc)
 	}
 
@@ -364,14 +421,14 @@ func (api *API) GoogleDirectionsHandler(_ http.ResponseWriter, r *http.Request)
 	waypoints := q["waypoint"] // e.g. ?waypoint=Benin&waypoint=Ibadan
 
 	requestSource := r.Header.Get("X-Request-Source")
-	log.Printf("[NAVIGATION-TRACKING] Google Directions Request -, Origin: %s, Destination: %s, Mode: %s, Waypoints: %v, Source: %s",
-		origin, destination, mode, waypoints, requestSource)
+	api.logger().InfoContext(r.Context(), "Google directions request",
+		"origin", origin, "destination", destination, "mode", mode, "waypoints", waypoints, "source", requestSource)
 
 	if origin == "" || destination == "" {
 		return respondWithError(nil, "Missing 'origin' or 'destination'", values.BadRequestBody, &tc)
 	}
 
-	result, err := api.GoogleMapsClient.Directions(r.Context(), origin, destination, waypoints, mode, true)
+	result, err := api.GoogleMapsClient.Directions(r.Context(), origin, destination, waypoints, mode, true, requestLanguage(r))
 	if err != nil {
 		return respondWithError(err, "Failed to get directions", values.SystemErr, &tc)
 	}
@@ -395,7 +452,8 @@ func (api *API) MapboxDirectionsHandler(_ http.ResponseWriter, r *http.Request)
 
 	// Log navigation request for tracking
 	requestSource := r.Header.Get("X-Request-Source")
-	log.Printf("[NAVIGATION-TRACKING] Mapbox Directions Request - %s, Origin: %s, Destination: %s, Profile: %s, Waypoints: %v, Source: %s", origin, destination, profile, waypoints, requestSource)
+	api.logger().InfoContext(r.Context(), "Mapbox directions request",
+		"origin", origin, "destination", destination, "profile", profile, "waypoints", waypoints, "source", requestSource)
 
 	if origin == "" || destination == "" {
 		return respondWithError(nil, "Missing 'origin' or 'destination'", values.BadRequestBody, &tc)
@@ -423,7 +481,7 @@ func (api *API) MapboxDirectionsHandler(_ http.ResponseWriter, r *http.Request)
 	// Get road-snapped directions from Mapbox with alternatives
 	result, err := api.MapboxClient.Directions(r.Context(), coordinates, profile, alternatives, true, "geojson")
 	if err != nil {
-		log.Printf("Error getting Mapbox directions: %v", err)
+		api.logger().ErrorContext(r.Context(), "Mapbox directions failed", "err", err)
 		return respondWithError(err, "Failed to get Mapbox directions", values.SystemErr, &tc)
 	}
 
@@ -441,17 +499,17 @@ func (api *API) MapboxDirectionsHandler(_ http.ResponseWriter, r *http.Request)
 
 // MapMatchingCoordinate represents a GPS coordinate for map matching
 type MapMatchingCoordinate struct {
-	Lat       float64 `json:"lat"`
-	Lng       float64 `json:"lng"`
+	Lat       float64 `json:"lat" validate:"latitude"`
+	Lng       float64 `json:"lng" validate:"longitude"`
 	Timestamp *int64  `json:"timestamp,omitempty"` // Unix timestamp in milliseconds
 }
 
 // MapMatchingRequest represents the request payload for map matching
 type MapMatchingRequest struct {
-	Coordinates []MapMatchingCoordinate `json:"coordinates"`
-	Radiuses    []float64               `json:"radiuses,omitempty"`    // Search radius per coordinate in meters
-	Approach    string                  `json:"approach,omitempty"`    // "unrestricted" or "curb"
-	Geometries  string                  `json:"geometries,omitempty"`  // "geojson" (default) or "polyline"
+	Coordinates []MapMatchingCoordinate `json:"coordinates" validate:"min=2,max=100,dive"`
+	Radiuses    []float64               `json:"radiuses,omitempty"` // Search radius per coordinate in meters
+	Approach    string                  `json:"approach,omitempty" validate:"omitempty,oneof=unrestricted curb"`
+	Geometries  string                  `json:"geometries,omitempty" validate:"omitempty,oneof=geojson polyline"` // geojson is the default
 }
 
 // MapboxMapMatchingHandler provides map matching for GPS traces using Mapbox Map Matching API
@@ -462,17 +520,12 @@ func (api *API) MapboxMapMatchingHandler(_ http.ResponseWriter, r *http.Request)
 	// Parse request body
 	var req MapMatchingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding map matching request body: %v", err)
+		api.logger().InfoContext(r.Context(), "Invalid map matching request body", "err", err)
 		return respondWithError(err, "Invalid request payload", values.BadRequestBody, &tc)
 	}
 
-	// Validate coordinates
-	if len(req.Coordinates) < 2 {
-		return respondWithError(nil, "At least 2 coordinates required for map matching", values.BadRequestBody, &tc)
-	}
-
-	if len(req.Coordinates) > 100 {
-		return respondWithError(nil, "Maximum 100 coordinates allowed per request", values.BadRequestBody, &tc)
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
 	}
 
 	// Set defaults
@@ -485,8 +538,8 @@ func (api *API) MapboxMapMatchingHandler(_ http.ResponseWriter, r *http.Request)
 
 	// Log Map Matching request for cost tracking
 	requestSource := r.Header.Get("X-Request-Source")
-	log.Printf("[MAP-MATCHING-USAGE] Request - %d coordinates, Approach: %s, Source: %s", 
-		len(req.Coordinates), req.Approach, requestSource)
+	api.logger().InfoContext(r.Context(), "Map matching request",
+		"coordinates", len(req.Coordinates), "approach", req.Approach, "source", requestSource)
 
 	// Convert coordinates to Mapbox format (lng,lat strings)
 	coordinates := make([]string, len(req.Coordinates))
@@ -508,23 +561,23 @@ func (api *API) MapboxMapMatchingHandler(_ http.ResponseWriter, r *http.Request)
 	// Call Mapbox Map Matching API
 	result, err := api.MapboxClient.MapMatching(r.Context(), coordinates, req.Approach, req.Geometries, radiusesParam)
 	if err != nil {
-		log.Printf("Error calling Mapbox Map Matching API: %v", err)
-		
-		// Check for specific Mapbox API errors
-		if strings.Contains(err.Error(), "422") {
+		api.logger().ErrorContext(r.Context(), "Mapbox map matching failed", "err", err)
+
+		var merr *mapbox.MatchingError
+		if errors.Is(err, mapbox.ErrNoMatch) || (errors.As(err, &merr) && merr.InvalidInput()) {
 			return respondWithError(err, "Invalid coordinates or no matching found", values.BadRequestBody, &tc)
 		}
-		if strings.Contains(err.Error(), "429") {
+		if providerRateLimited(err) {
 			return respondWithError(err, "Rate limit exceeded", values.SystemErr, &tc)
 		}
-		
+
 		return respondWithError(err, "Failed to match GPS trace to roads", values.SystemErr, &tc)
 	}
 
 	// Log successful usage for monitoring
 	if result != nil && len(result.Matchings) > 0 {
-		log.Printf("[MAP-MATCHING-SUCCESS] Matched %d coordinates to %d road segments", 
-			len(req.Coordinates), len(result.Matchings))
+		api.logger().InfoContext(r.Context(), "Map matching succeeded",
+			"coordinates", len(req.Coordinates), "matchings", len(result.Matchings))
 	}
 
 	return &ServerResponse{
@@ -534,3 +587,27 @@ func (api *API) MapboxMapMatchingHandler(_ http.ResponseWriter, r *http.Request)
 		Data:       result,
 	}
 }
+
+func (api *API) PrefetchRoutePOIsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req model.PrefetchPOIsRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	pois, status, message, err := api.PrefetchRoutePOIsHelper(r.Context(), req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       pois,
+	}
+}
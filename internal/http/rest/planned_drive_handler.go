@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) PlannedDriveRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Route("/", func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		// Upcoming drives, scheduled and suggested from the calendar, soonest departure first
+		r.Method(http.MethodGet, "/", Handler(api.ListPlannedDrives))
+		// Schedule a drive; body: { "destination_name": "Airport", "latitude": 35.15,
+		//   "longitude": 33.27, "arrive_by": "2024-05-01T09:00:00+03:00" } plus optional
+		//   "title" and "origin_latitude"/"origin_longitude". A push says when to leave.
+		r.Method(http.MethodPost, "/", Handler(api.CreatePlannedDrive))
+		r.Method(http.MethodPut, "/{driveID}", Handler(api.UpdatePlannedDrive))
+		r.Method(http.MethodDelete, "/{driveID}", Handler(api.CancelPlannedDrive))
+	})
+	return mux
+}
+
+func (api *API) CreatePlannedDrive(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.PlannedDriveRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	drive, status, message, err := api.CreatePlannedDriveHelper(r.Context(), userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       drive,
+	}
+}
+
+func (api *API) UpdatePlannedDrive(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	driveID, err := strconv.ParseInt(chi.URLParam(r, "driveID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid planned drive ID", values.BadRequestBody, &tc)
+	}
+
+	var req model.PlannedDriveRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	drive, status, message, err := api.UpdatePlannedDriveHelper(r.Context(), userID, driveID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       drive,
+	}
+}
+
+func (api *API) CancelPlannedDrive(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	driveID, err := strconv.ParseInt(chi.URLParam(r, "driveID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid planned drive ID", values.BadRequestBody, &tc)
+	}
+
+	status, message, err := api.CancelPlannedDriveHelper(r.Context(), userID, driveID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
@@ -0,0 +1,212 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// departureBuffer is added on top of the routed duration for parking etc.
+	departureBuffer = 10 * time.Minute
+	// Drives can be scheduled up to plannedDriveMaxLead ahead.
+	plannedDriveMaxLead = 14 * 24 * time.Hour
+
+	plannedDriveMonitorInterval = time.Minute
+	// Drives departing within plannedDriveRecomputeWithin have their drive time
+	// re-estimated from traffic every plannedDriveRecomputeEvery (Postgres intervals).
+	plannedDriveRecomputeWithin = "2 hours"
+	plannedDriveRecomputeEvery  = "10 minutes"
+	// Users are told to leave plannedDriveLeaveNotice before depart_at (Postgres interval).
+	plannedDriveLeaveNotice = "5 minutes"
+	plannedDriveBatch       = 50
+	plannedDrivePushType    = "planned_drive.leave"
+)
+
+// departureTime is when to leave to arrive by arriveBy, given the drive time.
+func departureTime(arriveBy time.Time, durationS float64) time.Time {
+	return arriveBy.Add(-time.Duration(durationS*float64(time.Second)) - departureBuffer)
+}
+
+// plannedDriveFromRequest checks the arrival time and estimates the drive from the
+// request's origin, else the user's latest location.
+func (api *API) plannedDriveFromRequest(ctx context.Context, userID uuid.UUID, req model.PlannedDriveRequest) (model.PlannedDrive, string, string, error) {
+	now := time.Now()
+	if !req.ArriveBy.After(now) {
+		return model.PlannedDrive{}, values.BadRequestBody, "arrive_by must be in the future", errors.New("arrival time in the past")
+	}
+	if req.ArriveBy.After(now.Add(plannedDriveMaxLead)) {
+		return model.PlannedDrive{}, values.BadRequestBody, "arrive_by must be within 14 days", errors.New("arrival time too far ahead")
+	}
+	lat, lng, err := util.NormalizeCoordinate(req.Latitude, req.Longitude)
+	if err != nil {
+		return model.PlannedDrive{}, values.BadRequestBody, "invalid latitude or longitude", err
+	}
+
+	drive := model.PlannedDrive{
+		UserID:          userID,
+		Title:           req.Title,
+		DestinationName: req.DestinationName,
+		Latitude:        lat,
+		Longitude:       lng,
+		ArriveBy:        req.ArriveBy,
+	}
+	var originLat, originLng float64
+	if req.OriginLatitude != nil {
+		originLat, originLng, err = util.NormalizeCoordinate(*req.OriginLatitude, *req.OriginLongitude)
+		if err != nil {
+			return model.PlannedDrive{}, values.BadRequestBody, "invalid origin latitude or longitude", err
+		}
+		drive.OriginLat, drive.OriginLng = &originLat, &originLng
+	} else {
+		var ok bool
+		originLat, originLng, ok, err = api.LatestUserLocationRepo(ctx, userID)
+		if err != nil {
+			return model.PlannedDrive{}, values.Error, "Failed to look up your location", err
+		}
+		if !ok {
+			return model.PlannedDrive{}, values.BadRequestBody, "origin_latitude and origin_longitude are required until you share your location",
+				errors.New("no origin for planned drive")
+		}
+	}
+
+	drive.DurationS = api.estimateDriveSeconds(ctx, originLat, originLng, lat, lng)
+	drive.DepartAt = departureTime(drive.ArriveBy, drive.DurationS)
+	return drive, values.Success, "", nil
+}
+
+func (api *API) CreatePlannedDriveHelper(ctx context.Context, userID uuid.UUID, req model.PlannedDriveRequest) (model.PlannedDrive, string, string, error) {
+	drive, status, message, err := api.plannedDriveFromRequest(ctx, userID, req)
+	if err != nil {
+		return drive, status, message, err
+	}
+	created, err := api.CreatePlannedDriveRepo(ctx, drive)
+	if err != nil {
+		return model.PlannedDrive{}, values.Error, "Failed to schedule drive", err
+	}
+	return created, values.Created, "Drive scheduled", nil
+}
+
+func (api *API) UpdatePlannedDriveHelper(ctx context.Context, userID uuid.UUID, id int64, req model.PlannedDriveRequest) (model.PlannedDrive, string, string, error) {
+	drive, status, message, err := api.plannedDriveFromRequest(ctx, userID, req)
+	if err != nil {
+		return drive, status, message, err
+	}
+	drive.ID = id
+	updated, err := api.UpdatePlannedDriveRepo(ctx, drive)
+	if errors.Is(err, ErrPlannedDriveNotFound) {
+		return model.PlannedDrive{}, values.NotFound, "Scheduled drive not found", err
+	}
+	if err != nil {
+		return model.PlannedDrive{}, values.Error, "Failed to update scheduled drive", err
+	}
+	return updated, values.Success, "Scheduled drive updated", nil
+}
+
+func (api *API) CancelPlannedDriveHelper(ctx context.Context, userID uuid.UUID, id int64) (string, string, error) {
+	err := api.CancelPlannedDriveRepo(ctx, userID, id)
+	if errors.Is(err, ErrPlannedDriveNotFound) {
+		return values.NotFound, "Planned drive not found", err
+	}
+	if err != nil {
+		return values.Error, "Failed to cancel planned drive", err
+	}
+	return values.Success, "Planned drive cancelled", nil
+}
+
+// RunPlannedDriveMonitor keeps departure times of upcoming drives current with traffic
+// and tells users when to leave, until ctx is cancelled.
+func (api *API) RunPlannedDriveMonitor(ctx context.Context) {
+	ticker := time.NewTicker(plannedDriveMonitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if api.dbAvailable() {
+				api.recomputePlannedDrives(ctx)
+				api.notifyPlannedDepartures(ctx)
+			}
+		}
+	}
+}
+
+// recomputePlannedDrives re-estimates the drive time of drives departing soon. Drives
+// without their own origin start from wherever the user is now; if that isn't known the
+// previous estimate stands.
+func (api *API) recomputePlannedDrives(ctx context.Context) {
+	drives, err := api.PlannedDrivesToRecomputeRepo(ctx, plannedDriveRecomputeWithin, plannedDriveRecomputeEvery, plannedDriveBatch)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Planned drives: failed to load drives to recompute", "err", err)
+		return
+	}
+	for _, d := range drives {
+		duration := d.DurationS
+		if originLat, originLng, ok := api.plannedDriveOrigin(ctx, d); ok {
+			duration = api.estimateDriveSeconds(ctx, originLat, originLng, d.Latitude, d.Longitude)
+		}
+		if err := api.UpdatePlannedDriveEstimateRepo(ctx, d.ID, departureTime(d.ArriveBy, duration), duration); err != nil {
+			api.logger().ErrorContext(ctx, "Planned drives: failed to save estimate", "drive_id", d.ID, "err", err)
+		}
+	}
+}
+
+func (api *API) plannedDriveOrigin(ctx context.Context, d model.PlannedDrive) (float64, float64, bool) {
+	if d.OriginLat != nil && d.OriginLng != nil {
+		return *d.OriginLat, *d.OriginLng, true
+	}
+	lat, lng, ok, err := api.LatestUserLocationRepo(ctx, d.UserID)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Planned drives: failed to load user location", "drive_id", d.ID, "err", err)
+	}
+	return lat, lng, ok
+}
+
+// notifyPlannedDepartures pushes a leave reminder for each drive that is due.
+func (api *API) notifyPlannedDepartures(ctx context.Context) {
+	drives, err := api.ClaimPlannedDeparturesRepo(ctx, plannedDriveLeaveNotice, plannedDriveBatch)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Planned drives: failed to claim departures", "err", err)
+		return
+	}
+	now := time.Now()
+	for _, d := range drives {
+		title, body := departureReminder(d, now)
+		err := api.SendFCMToUser(ctx, d.UserID.String(), title, body, map[string]string{
+			"type":      plannedDrivePushType,
+			"drive_id":  strconv.FormatInt(d.ID, 10),
+			"latitude":  strconv.FormatFloat(d.Latitude, 'f', 6, 64),
+			"longitude": strconv.FormatFloat(d.Longitude, 'f', 6, 64),
+			"arrive_by": d.ArriveBy.Format(time.RFC3339),
+		})
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Planned drives: leave reminder failed", "drive_id", d.ID, "err", err)
+		}
+	}
+}
+
+// departureReminder words the leave reminder, warning when traffic already makes the user
+// late.
+func departureReminder(d model.PlannedDrive, now time.Time) (title, body string) {
+	destination := d.DestinationName
+	if d.Title != nil && *d.Title != "" {
+		destination = *d.Title
+	}
+	arriveBy := d.ArriveBy.In(calendarTimeZone).Format("15:04")
+	drive := time.Duration(d.DurationS * float64(time.Second)).Round(time.Minute)
+
+	title = "Time to leave for " + destination
+	late := now.Add(drive).Sub(d.ArriveBy).Round(time.Minute)
+	if late >= time.Minute {
+		return title, fmt.Sprintf("Leave now: with traffic you'll arrive about %d min after %s.", int(late.Minutes()), arriveBy)
+	}
+	return title, fmt.Sprintf("The drive takes about %d min. Leave now to arrive by %s.", int(drive.Minutes()), arriveBy)
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestDepartureReminder(t *testing.T) {
+	arriveBy := time.Date(2024, 5, 1, 9, 0, 0, 0, calendarTimeZone)
+	title := "Dentist"
+	d := model.PlannedDrive{
+		Title:           &title,
+		DestinationName: "Makariou Ave 12",
+		ArriveBy:        arriveBy,
+		DurationS:       25 * 60,
+	}
+	if got := departureTime(d.ArriveBy, d.DurationS); !got.Equal(arriveBy.Add(-35 * time.Minute)) {
+		t.Errorf("departureTime = %v, want 35 min before arrival", got)
+	}
+
+	gotTitle, body := departureReminder(d, arriveBy.Add(-35*time.Minute))
+	if gotTitle != "Time to leave for Dentist" {
+		t.Errorf("title = %q", gotTitle)
+	}
+	if want := "The drive takes about 25 min. Leave now to arrive by 09:00."; body != want {
+		t.Errorf("on-time body = %q, want %q", body, want)
+	}
+
+	_, body = departureReminder(d, arriveBy.Add(-10*time.Minute))
+	if want := "Leave now: with traffic you'll arrive about 15 min after 09:00."; body != want {
+		t.Errorf("late body = %q, want %q", body, want)
+	}
+}
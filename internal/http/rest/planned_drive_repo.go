@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const plannedDriveColumns = `
+    id, user_id, source, title, destination_name, ST_Y(destination), ST_X(destination),
+    ST_Y(origin), ST_X(origin), arrive_by, depart_at, duration_s, status, notified_at,
+    created_at, updated_at`
+
+func scanPlannedDrive(row pgx.Row) (model.PlannedDrive, error) {
+	var d model.PlannedDrive
+	err := row.Scan(&d.ID, &d.UserID, &d.Source, &d.Title, &d.DestinationName, &d.Latitude, &d.Longitude,
+		&d.OriginLat, &d.OriginLng, &d.ArriveBy, &d.DepartAt, &d.DurationS, &d.Status, &d.NotifiedAt,
+		&d.CreatedAt, &d.UpdatedAt)
+	return d, err
+}
+
+func scanPlannedDrives(rows pgx.Rows) ([]model.PlannedDrive, error) {
+	defer rows.Close()
+	var drives []model.PlannedDrive
+	for rows.Next() {
+		d, err := scanPlannedDrive(rows)
+		if err != nil {
+			return nil, err
+		}
+		drives = append(drives, d)
+	}
+	return drives, rows.Err()
+}
+
+// CreatePlannedDriveRepo stores a drive the user scheduled. d.OriginLat/OriginLng are nil
+// when it starts from the user's latest location.
+func (api *API) CreatePlannedDriveRepo(ctx context.Context, d model.PlannedDrive) (model.PlannedDrive, error) {
+	return scanPlannedDrive(api.DB.QueryRow(ctx, `
+        INSERT INTO planned_drives
+            (user_id, source, title, destination_name, destination, origin,
+             arrive_by, depart_at, duration_s, computed_at)
+        VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($6, $5), 4326),
+                CASE WHEN $7::float8 IS NULL THEN NULL ELSE ST_SetSRID(ST_MakePoint($8, $7), 4326) END,
+                $9, $10, $11, NOW())
+        RETURNING`+plannedDriveColumns,
+		d.UserID, values.PlannedDriveSourceManual, d.Title, d.DestinationName, d.Latitude, d.Longitude,
+		d.OriginLat, d.OriginLng, d.ArriveBy, d.DepartAt, d.DurationS))
+}
+
+// UpdatePlannedDriveRepo replaces a scheduled drive that is still pending. Its leave
+// reminder is sent again for the new time.
+func (api *API) UpdatePlannedDriveRepo(ctx context.Context, d model.PlannedDrive) (model.PlannedDrive, error) {
+	updated, err := scanPlannedDrive(api.DB.QueryRow(ctx, `
+        UPDATE planned_drives SET
+            title = $3,
+            destination_name = $4,
+            destination = ST_SetSRID(ST_MakePoint($6, $5), 4326),
+            origin = CASE WHEN $7::float8 IS NULL THEN NULL ELSE ST_SetSRID(ST_MakePoint($8, $7), 4326) END,
+            arrive_by = $9,
+            depart_at = $10,
+            duration_s = $11,
+            computed_at = NOW(),
+            notified_at = NULL,
+            updated_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND source = $12 AND status = $13
+        RETURNING`+plannedDriveColumns,
+		d.ID, d.UserID, d.Title, d.DestinationName, d.Latitude, d.Longitude,
+		d.OriginLat, d.OriginLng, d.ArriveBy, d.DepartAt, d.DurationS,
+		values.PlannedDriveSourceManual, values.PlannedDriveSuggested))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return updated, ErrPlannedDriveNotFound
+	}
+	return updated, err
+}
+
+// CancelPlannedDriveRepo cancels a scheduled drive. Calendar suggestions are dismissed
+// instead, so the next sync doesn't bring them back.
+func (api *API) CancelPlannedDriveRepo(ctx context.Context, userID uuid.UUID, id int64) error {
+	result, err := api.DB.Exec(ctx, `
+        UPDATE planned_drives
+        SET status = CASE WHEN source = $3 THEN $4 ELSE $5 END, updated_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND status = $6
+    `, id, userID, values.PlannedDriveSourceManual, values.PlannedDriveCancelled,
+		values.PlannedDriveDismissed, values.PlannedDriveSuggested)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPlannedDriveNotFound
+	}
+	return nil
+}
+
+// PlannedDrivesToRecomputeRepo returns pending drives departing within within (a Postgres
+// interval) whose estimate is older than staleAfter, soonest first.
+func (api *API) PlannedDrivesToRecomputeRepo(ctx context.Context, within, staleAfter string, limit int) ([]model.PlannedDrive, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT`+plannedDriveColumns+`
+        FROM planned_drives
+        WHERE status = $1 AND notified_at IS NULL AND arrive_by > NOW()
+          AND depart_at <= NOW() + $2::interval
+          AND (computed_at IS NULL OR computed_at < NOW() - $3::interval)
+        ORDER BY depart_at
+        LIMIT $4
+    `, values.PlannedDriveSuggested, within, staleAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanPlannedDrives(rows)
+}
+
+// UpdatePlannedDriveEstimateRepo records a fresh drive time estimate.
+func (api *API) UpdatePlannedDriveEstimateRepo(ctx context.Context, id int64, departAt time.Time, durationS float64) error {
+	_, err := api.DB.Exec(ctx, `
+        UPDATE planned_drives SET depart_at = $2, duration_s = $3, computed_at = NOW(), updated_at = NOW()
+        WHERE id = $1
+    `, id, departAt, durationS)
+	return err
+}
+
+// ClaimPlannedDeparturesRepo marks pending drives departing within notice (a Postgres
+// interval) as notified and returns them. Claiming before sending means a drive is never
+// announced twice, even by two instances; a failed send is not retried.
+func (api *API) ClaimPlannedDeparturesRepo(ctx context.Context, notice string, limit int) ([]model.PlannedDrive, error) {
+	rows, err := api.DB.Query(ctx, `
+        UPDATE planned_drives SET notified_at = NOW()
+        WHERE id IN (
+            SELECT id FROM planned_drives
+            WHERE status = $1 AND notified_at IS NULL AND arrive_by > NOW()
+              AND depart_at <= NOW() + $2::interval
+            ORDER BY depart_at
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING`+plannedDriveColumns,
+		values.PlannedDriveSuggested, notice, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanPlannedDrives(rows)
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// checkLocationPlausibility compares a new location (heartbeat or report position)
+// against the user's previous ping and flags the account when the jump is physically
+// impossible or the device is a known emulator. Data is still accepted; flagged
+// accounts are left for a moderator to review.
+func (api *API) checkLocationPlausibility(ctx context.Context, userID, deviceID, source string, sample util.LocationSample) {
+	prev, err := api.GetLastLocationSampleRepo(ctx, userID, sample.At)
+	if err != nil && !errors.Is(err, ErrNoLocationHistory) {
+		api.logger().WarnContext(ctx, "Plausibility: failed to load last location", "subject_id", userID, "err", err)
+	}
+	if err == nil {
+		for _, reason := range util.CheckLocationPlausibility(prev, sample) {
+			api.flagSuspiciousActivity(ctx, userID, reason, source, map[string]interface{}{
+				"from":       []float64{prev.Latitude, prev.Longitude},
+				"to":         []float64{sample.Latitude, sample.Longitude},
+				"from_at":    prev.At,
+				"to_at":      sample.At,
+				"distance_m": util.HaversineMeters(prev.Latitude, prev.Longitude, sample.Latitude, sample.Longitude),
+				"device_id":  deviceID,
+			})
+		}
+	}
+
+	if deviceID == "" {
+		return
+	}
+	device, err := api.GetDevice(ctx, userID, deviceID)
+	if err == nil && device.IsEmulator {
+		api.flagSuspiciousActivity(ctx, userID, util.SuspicionEmulator, source, map[string]interface{}{
+			"device_id":    device.DeviceID,
+			"device_model": device.Model,
+		})
+	}
+}
+
+func (api *API) flagSuspiciousActivity(ctx context.Context, userID, reason, source string, details map[string]interface{}) {
+	api.logger().WarnContext(ctx, "Flagging user for review", "subject_id", userID, "reason", reason, "source", source)
+	if err := api.FlagSuspiciousActivityRepo(ctx, userID, reason, source, details); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to flag suspicious activity", "subject_id", userID, "err", err)
+	}
+}
+
+// runPlausibilityCheck runs checkLocationPlausibility off the request path.
+func (api *API) runPlausibilityCheck(userID, deviceID, source string, sample util.LocationSample) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				api.logger().Error("Panic in plausibility check", "panic", r)
+			}
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		api.checkLocationPlausibility(ctx, userID, deviceID, source, sample)
+	}()
+}
+
+func (api *API) ListSuspiciousActivityFlagsHelper(ctx context.Context, status, limitStr string) ([]model.SuspiciousActivityFlag, string, string, error) {
+	switch status {
+	case "", "pending", "confirmed", "dismissed":
+	default:
+		return nil, values.BadRequestBody, "status must be pending, confirmed or dismissed", fmt.Errorf("invalid status %q", status)
+	}
+	limit := 50
+	if limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > 200 {
+			return nil, values.BadRequestBody, "limit must be between 1 and 200", fmt.Errorf("invalid limit %q", limitStr)
+		}
+		limit = n
+	}
+
+	flags, err := api.ListSuspiciousActivityFlagsRepo(ctx, status, limit)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch suspicious activity flags", err
+	}
+	if flags == nil {
+		flags = []model.SuspiciousActivityFlag{}
+	}
+	return flags, values.Success, "Suspicious activity flags fetched successfully", nil
+}
+
+func (api *API) ResolveSuspiciousActivityFlagHelper(ctx context.Context, id int64, req model.ResolveSuspiciousActivityRequest, reviewerID uuid.UUID) (string, string, error) {
+	err := api.ResolveSuspiciousActivityFlagRepo(ctx, id, req.Status, reviewerID)
+	if err != nil {
+		if errors.Is(err, ErrSuspiciousFlagNotFound) {
+			return values.NotFound, "Suspicious activity flag not found", err
+		}
+		return values.Error, "Failed to resolve suspicious activity flag", err
+	}
+	return values.Success, "Suspicious activity flag " + req.Status, nil
+}
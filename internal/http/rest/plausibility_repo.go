@@ -0,0 +1,106 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNoLocationHistory      = errors.New("no previous location for user")
+	ErrSuspiciousFlagNotFound = fmt.Errorf("suspicious activity flag %w", ErrNotFound)
+)
+
+// FlagSuspiciousActivityRepo opens a review-queue entry for the user, or bumps the
+// occurrence count of the already pending one for the same reason.
+func (api *API) FlagSuspiciousActivityRepo(ctx context.Context, userID, reason, source string, details map[string]interface{}) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	q := `
+		INSERT INTO suspicious_activity_flags (user_id, reason, source, details)
+		VALUES ($1::uuid, $2, $3, $4)
+		ON CONFLICT (user_id, reason) WHERE status = 'pending' DO UPDATE SET
+			occurrences = suspicious_activity_flags.occurrences + 1,
+			details = EXCLUDED.details,
+			source = EXCLUDED.source,
+			last_seen_at = now()
+	`
+	_, err = api.DB.Exec(ctx, q, userID, reason, source, detailsJSON)
+	return err
+}
+
+// ListSuspiciousActivityFlagsRepo returns the review queue, most recently seen first.
+// An empty status lists flags in every status.
+func (api *API) ListSuspiciousActivityFlagsRepo(ctx context.Context, status string, limit int) ([]model.SuspiciousActivityFlag, error) {
+	q := `
+		SELECT id, user_id, reason, source, details, occurrences, status,
+		       reviewed_by, reviewed_at, created_at, last_seen_at
+		FROM suspicious_activity_flags
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY last_seen_at DESC
+		LIMIT $2
+	`
+	rows, err := api.DB.Query(ctx, q, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []model.SuspiciousActivityFlag
+	for rows.Next() {
+		var f model.SuspiciousActivityFlag
+		var details []byte
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Reason, &f.Source, &details, &f.Occurrences, &f.Status,
+			&f.ReviewedBy, &f.ReviewedAt, &f.CreatedAt, &f.LastSeenAt); err != nil {
+			return nil, err
+		}
+		if len(details) > 0 {
+			f.Details = details
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// ResolveSuspiciousActivityFlagRepo records a reviewer's verdict on a flag. Once a flag
+// is no longer pending, the next offence for the same reason opens a new one.
+func (api *API) ResolveSuspiciousActivityFlagRepo(ctx context.Context, id int64, status string, reviewedBy uuid.UUID) error {
+	tag, err := api.DB.Exec(ctx, `
+		UPDATE suspicious_activity_flags
+		SET status = $2, reviewed_by = $3, reviewed_at = now()
+		WHERE id = $1
+	`, id, status, reviewedBy)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSuspiciousFlagNotFound
+	}
+	return nil
+}
+
+// GetLastLocationSampleRepo returns the user's most recent location ping recorded before the given time.
+func (api *API) GetLastLocationSampleRepo(ctx context.Context, userID string, before time.Time) (util.LocationSample, error) {
+	var sample util.LocationSample
+	q := `
+		SELECT ST_Y(position), ST_X(position), speed, heading, recorded_at
+		FROM user_location_pings
+		WHERE user_id = $1::uuid AND recorded_at < $2
+		ORDER BY recorded_at DESC
+		LIMIT 1
+	`
+	err := api.DB.QueryRow(ctx, q, userID, before).Scan(&sample.Latitude, &sample.Longitude, &sample.Speed, &sample.Heading, &sample.At)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return util.LocationSample{}, ErrNoLocationHistory
+	}
+	return sample, err
+}
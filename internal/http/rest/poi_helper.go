@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	defaultPOICorridorWidthM = 300
+	minPOICorridorWidthM     = 50
+	maxPOICorridorWidthM     = 2000
+	defaultRoutePOILimit     = 20
+
+	routePOICacheTTL        = 10 * time.Minute
+	routePOICacheMaxEntries = 500
+)
+
+// defaultRoutePOICategories are the stops the "stops along route" sheet shows.
+var defaultRoutePOICategories = []string{"fuel", "pharmacy", "restaurant"}
+
+// routePOICache keeps recent prefetch results so the same route asked for again (a
+// re-render, or other riders on a popular route) doesn't re-run the corridor query.
+type routePOICache struct {
+	mu      sync.Mutex
+	entries map[string]routePOICacheEntry
+}
+
+type routePOICacheEntry struct {
+	result   model.RoutePOIs
+	storedAt time.Time
+}
+
+func (c *routePOICache) get(key string) (model.RoutePOIs, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.storedAt) > routePOICacheTTL {
+		return model.RoutePOIs{}, false
+	}
+	return e.result, true
+}
+
+func (c *routePOICache) put(key string, result model.RoutePOIs) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= routePOICacheMaxEntries {
+		c.entries = make(map[string]routePOICacheEntry)
+	}
+	c.entries[key] = routePOICacheEntry{result: result, storedAt: time.Now()}
+}
+
+func (c *routePOICache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// routePOICacheKey hashes the request with coordinates rounded to ~1 m, so the same route
+// decoded on different devices maps to one entry.
+func routePOICacheKey(geometry [][]float64, categories []string, widthM, limit int) string {
+	h := sha256.New()
+	for _, c := range geometry {
+		fmt.Fprintf(h, "%.5f,%.5f;", c[0], c[1])
+	}
+	fmt.Fprintf(h, "|%s|%d|%d", strings.Join(categories, ","), widthM, limit)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PrefetchRoutePOIsHelper returns local POIs along a route grouped by category, from the
+// cache when the same route was asked for recently.
+func (api *API) PrefetchRoutePOIsHelper(ctx context.Context, req model.PrefetchPOIsRequest) (model.RoutePOIs, string, string, error) {
+	width := req.CorridorWidthM
+	if width == 0 {
+		width = defaultPOICorridorWidthM
+	}
+	if width < minPOICorridorWidthM || width > maxPOICorridorWidthM {
+		return model.RoutePOIs{}, values.BadRequestBody, "corridor_width_m must be between 50 and 2000", errors.New("corridor width out of range")
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultRoutePOILimit
+	}
+	categories := req.Categories
+	if len(categories) == 0 {
+		categories = defaultRoutePOICategories
+	}
+	categories = append([]string(nil), categories...)
+	sort.Strings(categories)
+
+	key := routePOICacheKey(req.Geometry, categories, width, limit)
+	if result, ok := api.routePOIs.get(key); ok {
+		return result, values.Success, "Route POIs fetched successfully", nil
+	}
+
+	pois, err := api.RoutePOIsRepo(ctx, req.Geometry, categories, width, limit)
+	if err != nil {
+		return model.RoutePOIs{}, values.Error, "Failed to fetch route POIs", err
+	}
+	result := model.RoutePOIs{
+		CorridorWidthM: width,
+		Categories:     make(map[string][]model.RoutePOI, len(categories)),
+	}
+	for _, c := range categories {
+		result.Categories[c] = []model.RoutePOI{}
+	}
+	for _, p := range pois {
+		result.Categories[p.Category] = append(result.Categories[p.Category], p)
+	}
+	api.routePOIs.put(key, result)
+	return result, values.Success, "Route POIs fetched successfully", nil
+}
+
+func (api *API) UpsertPOIsHelper(ctx context.Context, req model.UpsertPOIsRequest) (map[string]int, string, string, error) {
+	n, err := api.UpsertPOIsRepo(ctx, req.POIs)
+	if err != nil {
+		return nil, values.Error, "Failed to save POIs", err
+	}
+	api.routePOIs.invalidate()
+	return map[string]int{"upserted": n}, values.Success, "POIs saved", nil
+}
+
+func (api *API) DeletePOIHelper(ctx context.Context, id int64) (string, string, error) {
+	if err := api.DeletePOIRepo(ctx, id); err != nil {
+		if errors.Is(err, ErrPOINotFound) {
+			return values.NotFound, "POI not found", err
+		}
+		return values.Error, "Failed to delete POI", err
+	}
+	api.routePOIs.invalidate()
+	return values.Success, "POI deleted", nil
+}
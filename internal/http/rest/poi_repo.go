@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrPOINotFound = fmt.Errorf("poi %w", ErrNotFound)
+
+// RoutePOIsRepo returns POIs of the given categories within widthM of the route, at most
+// limit per category (those closest to the route), ordered by distance along it.
+func (api *API) RoutePOIsRepo(ctx context.Context, geometry [][]float64, categories []string, widthM, limit int) ([]model.RoutePOI, error) {
+	geojson, err := lineStringGeoJSON(geometry)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        WITH route AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) AS geom
+        ),
+        near AS (
+            SELECT p.id, p.name, p.category, ST_Y(p.location) AS lat, ST_X(p.location) AS lng,
+                   ST_LineLocatePoint(route.geom, p.location) * ST_Length(route.geom::geography) AS along_m,
+                   ST_Distance(p.location::geography, route.geom::geography) AS detour_m
+            FROM pois p, route
+            WHERE p.category = ANY($2)
+              AND ST_DWithin(p.location::geography, route.geom::geography, $3)
+        ),
+        ranked AS (
+            SELECT *, ROW_NUMBER() OVER (PARTITION BY category ORDER BY detour_m) AS rn
+            FROM near
+        )
+        SELECT id, name, category, lat, lng, along_m, detour_m
+        FROM ranked
+        WHERE rn <= $4
+        ORDER BY category, along_m
+    `
+	rows, err := api.DB.Query(ctx, query, geojson, categories, widthM, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pois []model.RoutePOI
+	for rows.Next() {
+		var p model.RoutePOI
+		if err := rows.Scan(&p.ID, &p.Name, &p.Category, &p.Latitude, &p.Longitude, &p.DistanceAlongM, &p.DetourM); err != nil {
+			return nil, err
+		}
+		pois = append(pois, p)
+	}
+	return pois, rows.Err()
+}
+
+// UpsertPOIsRepo inserts or updates POIs by (source, source_id) in one transaction and
+// returns how many were written.
+func (api *API) UpsertPOIsRepo(ctx context.Context, pois []model.UpsertPOIRequest) (int, error) {
+	query := `
+        INSERT INTO pois (source, source_id, name, category, location)
+        VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($6, $5), 4326))
+        ON CONFLICT (source, source_id) DO UPDATE SET
+            name = EXCLUDED.name,
+            category = EXCLUDED.category,
+            location = EXCLUDED.location,
+            updated_at = NOW()
+    `
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		for _, p := range pois {
+			if _, err := tx.Exec(ctx, query, p.Source, p.SourceID, p.Name, p.Category, p.Latitude, p.Longitude); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pois), nil
+}
+
+func (api *API) DeletePOIRepo(ctx context.Context, id int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM pois WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPOINotFound
+	}
+	return nil
+}
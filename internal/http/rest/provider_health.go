@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const (
+	// providerWindow is how many recent calls per provider are scored.
+	providerWindow = 50
+	// providerMaxAge drops older outcomes so a provider that recovered is tried again.
+	providerMaxAge = 10 * time.Minute
+	// providerLatencyBudget is the average latency that costs a full providerLatencyWeight.
+	providerLatencyBudget = 2 * time.Second
+	providerLatencyWeight = 0.25
+	// providerCooldown is how long a provider stays down after it rate-limits us or its
+	// circuit breaker turns a call away.
+	providerCooldown = time.Minute
+)
+
+type providerOutcome struct {
+	at      time.Time
+	latency time.Duration
+	ok      bool
+}
+
+type providerStats struct {
+	outcomes    [providerWindow]providerOutcome
+	next, count int
+	lastErr     string
+	lastErrAt   time.Time
+	downUntil   time.Time
+}
+
+// providerHealth keeps a rolling window of outcomes per external provider, and which
+// providers are down. Failures in a row are counted once, by the circuit breaker of the
+// resilient HTTP client the provider clients share: a provider is down while its breaker
+// is open, or for a while after it rate-limits us. The zero value is ready to use.
+type providerHealth struct {
+	mu    sync.Mutex
+	stats map[string]*providerStats
+}
+
+func (h *providerHealth) record(provider string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stats == nil {
+		h.stats = make(map[string]*providerStats)
+	}
+	s, ok := h.stats[provider]
+	if !ok {
+		s = &providerStats{}
+		h.stats[provider] = s
+	}
+	now := time.Now()
+	s.outcomes[s.next] = providerOutcome{at: now, latency: latency, ok: err == nil}
+	s.next = (s.next + 1) % providerWindow
+	if s.count < providerWindow {
+		s.count++
+	}
+	switch {
+	case err == nil:
+		s.downUntil = time.Time{}
+	case providerUnavailable(err):
+		s.lastErr, s.lastErrAt = err.Error(), now
+		s.downUntil = now.Add(providerCooldown)
+	default:
+		s.lastErr, s.lastErrAt = err.Error(), now
+	}
+}
+
+// down reports whether the provider is to be tried only after the others.
+func (h *providerHealth) down(provider string, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.stats[provider]
+	return ok && now.Before(s.downUntil)
+}
+
+// snapshot scores a provider: success rate minus a penalty for slow answers.
+// A provider with no recent calls scores 1 so it gets tried.
+func (h *providerHealth) snapshot(provider string) model.ProviderHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph := model.ProviderHealth{Provider: provider, SuccessRate: 1, Score: 1}
+	s, ok := h.stats[provider]
+	if !ok {
+		return ph
+	}
+	if s.lastErr != "" {
+		lastErrAt := s.lastErrAt
+		ph.LastError, ph.LastErrorAt = s.lastErr, &lastErrAt
+	}
+
+	cutoff := time.Now().Add(-providerMaxAge)
+	var succeeded int
+	var latency time.Duration
+	for i := 0; i < s.count; i++ {
+		o := s.outcomes[i]
+		if o.at.Before(cutoff) {
+			continue
+		}
+		ph.Samples++
+		latency += o.latency
+		if o.ok {
+			succeeded++
+		}
+	}
+	if ph.Samples == 0 {
+		return ph
+	}
+
+	avg := latency / time.Duration(ph.Samples)
+	ph.SuccessRate = float64(succeeded) / float64(ph.Samples)
+	ph.AvgLatencyMs = float64(avg.Milliseconds())
+	penalty := math.Min(float64(avg)/float64(providerLatencyBudget), 1) * providerLatencyWeight
+	ph.Score = ph.SuccessRate - penalty
+	return ph
+}
+
+// providerUnavailable reports whether an error means the provider can't be used for now:
+// its circuit breaker is open or it rate-limited us.
+func providerUnavailable(err error) bool {
+	if errors.Is(err, resilient.ErrCircuitOpen) {
+		return true
+	}
+	var verr *valhalla.Error
+	var merr *mapbox.Error
+	var gerr *googlemaps.Error
+	return errors.As(err, &verr) && verr.RateLimited() ||
+		errors.As(err, &merr) && merr.RateLimited() ||
+		errors.As(err, &gerr) && gerr.RateLimited()
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+)
+
+func TestProviderHealthDown(t *testing.T) {
+	var h providerHealth
+	now := time.Now()
+
+	// Plain failures are left to the breaker: they lower the score but don't mark the
+	// provider down.
+	for i := 0; i < 5; i++ {
+		h.record(GeocoderStadia, time.Second, errors.New("execute request: timeout"))
+	}
+	if h.down(GeocoderStadia, now) {
+		t.Error("down after plain failures")
+	}
+	if ph := h.snapshot(GeocoderStadia); ph.SuccessRate != 0 || ph.LastError == "" {
+		t.Errorf("snapshot = %+v", ph)
+	}
+
+	h.record(GeocoderStadia, 0, fmt.Errorf("execute request: %w", resilient.ErrCircuitOpen))
+	if !h.down(GeocoderStadia, time.Now()) {
+		t.Fatal("not down while the breaker is open")
+	}
+	if h.down(GeocoderStadia, time.Now().Add(providerCooldown)) {
+		t.Error("still down after the cooldown")
+	}
+	h.record(GeocoderStadia, time.Second, nil)
+	if h.down(GeocoderStadia, time.Now()) {
+		t.Error("still down after a success")
+	}
+
+	h.record(GeocoderGoogle, time.Second, &googlemaps.Error{StatusCode: http.StatusOK, Status: "OVER_QUERY_LIMIT"})
+	if !h.down(GeocoderGoogle, time.Now()) {
+		t.Error("rate-limited provider not marked down")
+	}
+}
@@ -0,0 +1,163 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Quota classes: groups of expensive endpoints that share a daily counter.
+const (
+	QuotaDirections   = "directions"
+	QuotaAutocomplete = "autocomplete"
+	QuotaMatrix       = "matrix"
+)
+
+var quotaClasses = []string{QuotaDirections, QuotaAutocomplete, QuotaMatrix}
+
+// quotaLimits is the number of requests per UTC day for each tier and class.
+var quotaLimits = map[string]map[string]int{
+	values.QuotaTierFree:    {QuotaDirections: 100, QuotaAutocomplete: 500, QuotaMatrix: 20},
+	values.QuotaTierPower:   {QuotaDirections: 1000, QuotaAutocomplete: 5000, QuotaMatrix: 200},
+	values.QuotaTierPartner: {QuotaDirections: 10000, QuotaAutocomplete: 50000, QuotaMatrix: 2000},
+}
+
+const quotaTimeout = 2 * time.Second
+
+var ErrQuotaExceeded = errors.New("daily quota exceeded")
+
+func quotaLimit(tier, class string) int {
+	if limits, ok := quotaLimits[tier]; ok {
+		return limits[class]
+	}
+	return quotaLimits[values.QuotaTierFree][class]
+}
+
+func quotaDay(now time.Time) (day, resetAt time.Time) {
+	day = now.UTC().Truncate(24 * time.Hour)
+	return day, day.Add(24 * time.Hour)
+}
+
+func userQuotaSubject(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+// quotaSubject identifies who a request counts against: the signed-in user, or the
+// client IP for endpoints that allow anonymous access.
+func (api *API) quotaSubject(r *http.Request) (string, *uuid.UUID) {
+	if userID := api.requestUserID(r); userID != nil {
+		return userQuotaSubject(*userID), userID
+	}
+	return "ip:" + api.clientIP(r), nil
+}
+
+// requestUserID is the signed-in caller, from RequireLogin or, on routes where login is
+// optional, a valid bearer token. It is nil for anonymous requests.
+func (api *API) requestUserID(r *http.Request) *uuid.UUID {
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		return &userID
+	}
+	authorization := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(authorization) == 2 && authorization[0] == "Bearer" {
+		if claims, err := api.verifyToken(authorization[1], false); err == nil {
+			if userID, err := uuid.Parse(claims.UserID); err == nil {
+				return &userID
+			}
+		}
+	}
+	return nil
+}
+
+// EnforceQuota counts the request against the caller's daily quota for class, sets the
+// X-Quota-* headers, and rejects the request with 429 once the tier's limit is used up.
+// It fails open: while the database is unavailable requests are not counted.
+func (api *API) EnforceQuota(class string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !api.dbAvailable() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			subject, userID := api.quotaSubject(r)
+			day, resetAt := quotaDay(time.Now())
+			ctx, cancel := context.WithTimeout(r.Context(), quotaTimeout)
+			count, tier, err := api.IncrementQuotaUsageRepo(ctx, subject, class, day, userID)
+			cancel()
+			if err != nil {
+				api.logger().WarnContext(r.Context(), "Quota check failed, allowing request", "subject", subject, "class", class, "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			limit := quotaLimit(tier, class)
+			h := w.Header()
+			h.Set(values.HeaderQuotaTier, tier)
+			h.Set(values.HeaderQuotaLimit, strconv.Itoa(limit))
+			h.Set(values.HeaderQuotaRemaining, strconv.Itoa(max(limit-count, 0)))
+			h.Set(values.HeaderQuotaReset, strconv.FormatInt(resetAt.Unix(), 10))
+
+			if count > limit {
+				retryAfter := int(time.Until(resetAt).Seconds()) + 1
+				h.Set("Retry-After", strconv.Itoa(retryAfter))
+				err := withDetails(fmt.Errorf("%w: %s for %s", ErrQuotaExceeded, class, subject), map[string]any{
+					"class": class, "tier": tier, "limit": limit, "reset_at": resetAt, "retry_after_seconds": retryAfter,
+				})
+				writeErrorResponse(w, err, values.TooManyRequests,
+					fmt.Sprintf("Daily %s quota of %d requests reached for the %s tier", class, limit, tier))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (api *API) GetUserQuotaHelper(ctx context.Context, userID uuid.UUID) (model.UserQuota, string, string, error) {
+	tier, err := api.GetUserQuotaTierRepo(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.UserQuota{}, values.NotFound, "User not found", err
+	}
+	if err != nil {
+		return model.UserQuota{}, values.Error, "Failed to fetch quota", err
+	}
+
+	day, resetAt := quotaDay(time.Now())
+	used, err := api.GetQuotaUsageRepo(ctx, userQuotaSubject(userID), day)
+	if err != nil {
+		return model.UserQuota{}, values.Error, "Failed to fetch quota", err
+	}
+
+	quota := model.UserQuota{UserID: userID, Tier: tier, ResetAt: resetAt}
+	for _, class := range quotaClasses {
+		limit := quotaLimit(tier, class)
+		quota.Usage = append(quota.Usage, model.QuotaUsage{
+			Class:     class,
+			Limit:     limit,
+			Used:      used[class],
+			Remaining: max(limit-used[class], 0),
+		})
+	}
+	return quota, values.Success, "Quota fetched successfully", nil
+}
+
+func (api *API) UpdateUserQuotaTierHelper(ctx context.Context, userID uuid.UUID, req model.UpdateQuotaTierRequest, adminID uuid.UUID) (model.UserQuota, string, string, error) {
+	err := api.UpdateUserQuotaTierRepo(ctx, userID, req.Tier)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.UserQuota{}, values.NotFound, "User not found", err
+	}
+	if err != nil {
+		return model.UserQuota{}, values.Error, "Failed to update quota tier", err
+	}
+	api.logger().InfoContext(ctx, "Quota tier changed", "admin_id", adminID, "subject_id", userID, "tier", req.Tier)
+	return api.GetUserQuotaHelper(ctx, userID)
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// IncrementQuotaUsageRepo counts one request against subject's daily quota for class and
+// returns the new count with the tier that applies. userID is nil for anonymous subjects,
+// which are always on the free tier.
+func (api *API) IncrementQuotaUsageRepo(ctx context.Context, subject, class string, day time.Time, userID *uuid.UUID) (int, string, error) {
+	var count int
+	var tier string
+	err := api.DB.QueryRow(ctx, `
+        WITH usage AS (
+            INSERT INTO api_quota_usage (subject, quota_class, day, count)
+            VALUES ($1, $2, $3, 1)
+            ON CONFLICT (subject, quota_class, day) DO UPDATE SET count = api_quota_usage.count + 1
+            RETURNING count
+        )
+        SELECT usage.count, COALESCE((SELECT quota_tier FROM users WHERE id = $4), 'free')
+        FROM usage
+    `, subject, class, day, userID).Scan(&count, &tier)
+	return count, tier, err
+}
+
+// GetQuotaUsageRepo returns subject's request count per quota class for the day.
+func (api *API) GetQuotaUsageRepo(ctx context.Context, subject string, day time.Time) (map[string]int, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT quota_class, count FROM api_quota_usage WHERE subject = $1 AND day = $2
+    `, subject, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int)
+	for rows.Next() {
+		var class string
+		var count int
+		if err := rows.Scan(&class, &count); err != nil {
+			return nil, err
+		}
+		usage[class] = count
+	}
+	return usage, rows.Err()
+}
+
+func (api *API) GetUserQuotaTierRepo(ctx context.Context, userID uuid.UUID) (string, error) {
+	var tier string
+	err := api.DB.QueryRow(ctx, `SELECT quota_tier FROM users WHERE id = $1`, userID).Scan(&tier)
+	return tier, err
+}
+
+// UpdateUserQuotaTierRepo sets the user's tier. It returns pgx.ErrNoRows if there is no such user.
+func (api *API) UpdateUserQuotaTierRepo(ctx context.Context, userID uuid.UUID, tier string) error {
+	tag, err := api.DB.Exec(ctx, `UPDATE users SET quota_tier = $2, updated_at = NOW() WHERE id = $1`, userID, tier)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
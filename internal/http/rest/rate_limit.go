@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/util/ratelimit"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// Rate limit groups: expensive endpoints that share a token bucket per caller. Unlike
+// quotas, which cap a day's usage, these smooth out bursts.
+const (
+	RateLimitPlaces       = "places"
+	RateLimitDirections   = "directions"
+	RateLimitReportCreate = "report_create"
+	// Each traffic batch is a paid Mapbox map matching call.
+	RateLimitTrafficSamples = "traffic_samples"
+	// Each image upload is a SafeSearch call and a Cloudinary upload.
+	RateLimitImageUpload = "image_upload"
+	// Each phone code request is a paid SMS; verify shares the bucket to slow guessing.
+	RateLimitPhoneCode = "phone_code"
+)
+
+// rateLimitRule is a group's per-user and per-IP bucket. The IP bucket is more generous
+// because carrier-grade NAT puts many drivers behind one address.
+type rateLimitRule struct {
+	User ratelimit.Limit
+	IP   ratelimit.Limit
+}
+
+var rateLimitDefaults = map[string]rateLimitRule{
+	RateLimitPlaces:         {User: ratelimit.PerMinute(60, 20), IP: ratelimit.PerMinute(300, 60)},
+	RateLimitDirections:     {User: ratelimit.PerMinute(20, 10), IP: ratelimit.PerMinute(100, 30)},
+	RateLimitReportCreate:   {User: ratelimit.PerMinute(6, 3), IP: ratelimit.PerMinute(30, 10)},
+	RateLimitTrafficSamples: {User: ratelimit.PerMinute(4, 2), IP: ratelimit.PerMinute(60, 20)},
+	RateLimitImageUpload:    {User: ratelimit.PerMinute(6, 3), IP: ratelimit.PerMinute(30, 10)},
+	RateLimitPhoneCode:      {User: ratelimit.PerMinute(3, 3), IP: ratelimit.PerMinute(10, 5)},
+}
+
+const rateLimitTimeout = time.Second
+
+// rateLimiter holds the buckets and the rules, with RATE_LIMITS applied on first use.
+type rateLimiter struct {
+	once  sync.Once
+	store ratelimit.Store
+	rules map[string]rateLimitRule
+}
+
+// SetRateLimitStore replaces the in-memory buckets, e.g. with a store every instance
+// shares. Call it before serving requests.
+func (api *API) SetRateLimitStore(store ratelimit.Store) {
+	api.rateLimits.store = store
+}
+
+func (api *API) rateLimitRules() (ratelimit.Store, map[string]rateLimitRule) {
+	api.rateLimits.once.Do(func() {
+		if api.rateLimits.store == nil {
+			api.rateLimits.store = ratelimit.NewMemoryStore()
+		}
+		rules, err := parseRateLimits(api.Config.RateLimits, rateLimitDefaults)
+		if err != nil {
+			api.logger().Warn("Ignoring RATE_LIMITS", "err", err)
+			rules = rateLimitDefaults
+		}
+		api.rateLimits.rules = rules
+	})
+	return api.rateLimits.store, api.rateLimits.rules
+}
+
+// RateLimit takes a token from the caller's per-IP bucket for group and, when they are
+// signed in, from their per-user bucket, rejecting the request with 429 and Retry-After
+// once either is empty. It fails open if the store errors.
+func (api *API) RateLimit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			store, rules := api.rateLimitRules()
+			rule := rules[group]
+			now := time.Now()
+			ctx, cancel := context.WithTimeout(r.Context(), rateLimitTimeout)
+			defer cancel()
+
+			type bucket struct {
+				key   string
+				limit ratelimit.Limit
+			}
+			buckets := []bucket{{"ratelimit:" + group + ":ip:" + api.clientIP(r), rule.IP}}
+			if userID := api.requestUserID(r); userID != nil {
+				buckets = append(buckets, bucket{"ratelimit:" + group + ":user:" + userID.String(), rule.User})
+			}
+
+			remaining := -1
+			for _, b := range buckets {
+				if !b.limit.Enabled() {
+					continue
+				}
+				res, err := store.Take(ctx, b.key, b.limit, now)
+				if err != nil {
+					api.logger().WarnContext(ctx, "Rate limit check failed, allowing request", "key", b.key, "err", err)
+					continue
+				}
+				if !res.Allowed {
+					retryAfter := int(math.Ceil(res.RetryAfter.Seconds()))
+					w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+					err := withDetails(fmt.Errorf("rate limit exceeded for %s", b.key), map[string]any{"retry_after_seconds": retryAfter})
+					writeErrorResponse(w, err, values.TooManyRequests,
+						"Too many requests, please slow down")
+					return
+				}
+				if remaining < 0 || res.Remaining < remaining {
+					remaining = res.Remaining
+				}
+			}
+			if remaining >= 0 {
+				w.Header().Set(values.HeaderRateLimitRemaining, strconv.Itoa(remaining))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseRateLimits applies "group:scope=per_minute/burst" overrides (scope user or ip,
+// comma separated) to defaults. A malformed entry fails the whole value.
+func parseRateLimits(s string, defaults map[string]rateLimitRule) (map[string]rateLimitRule, error) {
+	rules := make(map[string]rateLimitRule, len(defaults))
+	for group, rule := range defaults {
+		rules[group] = rule
+	}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, spec, ok := strings.Cut(entry, "=")
+		group, scope, ok2 := strings.Cut(target, ":")
+		perMinute, burst, ok3 := strings.Cut(spec, "/")
+		if !ok || !ok2 || !ok3 {
+			return nil, fmt.Errorf("%q: want group:scope=per_minute/burst", entry)
+		}
+		rule, known := rules[strings.TrimSpace(group)]
+		if !known {
+			return nil, fmt.Errorf("%q: unknown group", entry)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(perMinute), 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%q: invalid rate", entry)
+		}
+		b, err := strconv.Atoi(strings.TrimSpace(burst))
+		if err != nil || b < 0 {
+			return nil, fmt.Errorf("%q: invalid burst", entry)
+		}
+		switch strings.TrimSpace(scope) {
+		case "user":
+			rule.User = ratelimit.PerMinute(n, b)
+		case "ip":
+			rule.IP = ratelimit.PerMinute(n, b)
+		default:
+			return nil, fmt.Errorf("%q: scope must be user or ip", entry)
+		}
+		rules[strings.TrimSpace(group)] = rule
+	}
+	return rules, nil
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/ratelimit"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	rules, err := parseRateLimits(" places:user=120/30, directions:ip=0/0 ", rateLimitDefaults)
+	if err != nil {
+		t.Fatalf("parseRateLimits: %v", err)
+	}
+	if got, want := rules[RateLimitPlaces].User, ratelimit.PerMinute(120, 30); got != want {
+		t.Errorf("places user = %+v, want %+v", got, want)
+	}
+	if got := rules[RateLimitPlaces].IP; got != rateLimitDefaults[RateLimitPlaces].IP {
+		t.Errorf("places ip = %+v, want the default", got)
+	}
+	if rules[RateLimitDirections].IP.Enabled() {
+		t.Error("directions ip still enabled")
+	}
+	if got := rules[RateLimitReportCreate]; got != rateLimitDefaults[RateLimitReportCreate] {
+		t.Errorf("report_create = %+v, want the default", got)
+	}
+	if rateLimitDefaults[RateLimitPlaces].User == rules[RateLimitPlaces].User {
+		t.Error("overrides leaked into the defaults")
+	}
+
+	for _, bad := range []string{"places=60/20", "places:user=60", "nowhere:user=60/20", "places:device=60/20", "places:user=x/20", "places:user=60/-1"} {
+		if _, err := parseRateLimits(bad, rateLimitDefaults); err == nil {
+			t.Errorf("parseRateLimits(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// readCacheTTL bounds how stale a fallback read may be.
+	readCacheTTL        = 5 * time.Minute
+	readCacheMaxEntries = 2000
+)
+
+// readCache remembers recent successful reads so they can be served when the database
+// is unavailable. It is never consulted while the database is healthy.
+type readCache struct {
+	mu      sync.Mutex
+	entries map[string]readCacheEntry
+}
+
+type readCacheEntry struct {
+	value    interface{}
+	storedAt time.Time
+}
+
+func (c *readCache) put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]readCacheEntry)
+	}
+	if len(c.entries) >= readCacheMaxEntries {
+		c.evictExpiredLocked()
+		if len(c.entries) >= readCacheMaxEntries {
+			c.entries = make(map[string]readCacheEntry)
+		}
+	}
+	c.entries[key] = readCacheEntry{value: value, storedAt: time.Now()}
+}
+
+func (c *readCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.storedAt) > readCacheTTL {
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *readCache) evictExpiredLocked() {
+	for k, e := range c.entries {
+		if time.Since(e.storedAt) > readCacheTTL {
+			delete(c.entries, k)
+		}
+	}
+}
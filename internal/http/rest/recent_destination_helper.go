@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// recentDestinationMergeM: a destination this close to an earlier one is the same
+	// place, e.g. the other side of the same car park.
+	recentDestinationMergeM = 100.0
+	// recentDestinationHalfLife is how long until a visit counts for half as much.
+	recentDestinationHalfLife = 14 * 24 * time.Hour
+	// Destinations not visited for recentDestinationMaxAge drop off the list.
+	recentDestinationMaxAge = 180 * 24 * time.Hour
+
+	defaultRecentDestinations = 20
+	maxRecentDestinations     = 50
+	// recentDestinationCandidates bounds how many rows are ranked per request.
+	recentDestinationCandidates = 200
+)
+
+// recordRecentDestination adds a navigation session's destination to the user's history.
+// Best effort: navigation must not fail because history couldn't be written.
+func (api *API) recordRecentDestination(ctx context.Context, userID uuid.UUID, req model.StartNavigationRequest) {
+	if req.DestinationLat == nil || req.DestinationLng == nil {
+		return
+	}
+	err := api.RecordRecentDestinationRepo(ctx, userID, *req.DestinationLat, *req.DestinationLng, req.DestinationName, recentDestinationMergeM)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to record recent destination", "user_id", userID, "err", err)
+	}
+}
+
+// rankRecentDestinations scores each destination by its visits, halved for every
+// recentDestinationHalfLife since the last one, and sorts the best first. A place
+// visited often stays above one visited once a little more recently.
+func rankRecentDestinations(destinations []model.RecentDestination, now time.Time) {
+	for i := range destinations {
+		d := &destinations[i]
+		age := max(now.Sub(d.LastVisitedAt), 0)
+		score := float64(d.VisitCount) * math.Pow(0.5, age.Hours()/recentDestinationHalfLife.Hours())
+		d.Score = math.Round(score*1000) / 1000
+	}
+	sort.SliceStable(destinations, func(i, j int) bool {
+		if destinations[i].Score != destinations[j].Score {
+			return destinations[i].Score > destinations[j].Score
+		}
+		return destinations[i].LastVisitedAt.After(destinations[j].LastVisitedAt)
+	})
+}
+
+func (api *API) ListRecentDestinationsHelper(ctx context.Context, userID uuid.UUID, limit int) ([]model.RecentDestination, string, string, error) {
+	if limit < 1 {
+		limit = defaultRecentDestinations
+	}
+	limit = min(limit, maxRecentDestinations)
+
+	now := time.Now()
+	destinations, err := api.ListRecentDestinationsRepo(ctx, userID, now.Add(-recentDestinationMaxAge), recentDestinationCandidates)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch recent destinations", err
+	}
+	rankRecentDestinations(destinations, now)
+	if len(destinations) > limit {
+		destinations = destinations[:limit]
+	}
+	if destinations == nil {
+		destinations = []model.RecentDestination{}
+	}
+	return destinations, values.Success, "Recent destinations fetched successfully", nil
+}
+
+func (api *API) DeleteRecentDestinationHelper(ctx context.Context, userID uuid.UUID, id int64) (string, string, error) {
+	err := api.DeleteRecentDestinationRepo(ctx, userID, id)
+	if errors.Is(err, ErrRecentDestinationNotFound) {
+		return values.NotFound, "Recent destination not found", err
+	}
+	if err != nil {
+		return values.Error, "Failed to delete recent destination", err
+	}
+	return values.Success, "Recent destination deleted", nil
+}
+
+func (api *API) ClearRecentDestinationsHelper(ctx context.Context, userID uuid.UUID) (map[string]int64, string, string, error) {
+	cleared, err := api.ClearRecentDestinationsRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to clear recent destinations", err
+	}
+	return map[string]int64{"cleared": cleared}, values.Success, "Recent destinations cleared", nil
+}
+
+func (api *API) UpdateRecentDestinationSettingsHelper(ctx context.Context, userID uuid.UUID, enabled bool) (map[string]bool, string, string, error) {
+	if err := api.SetRecentDestinationsEnabledRepo(ctx, userID, enabled); err != nil {
+		return nil, errorStatus(err, values.Error), "Failed to update recent destination settings", err
+	}
+	message := "Recent destinations turned on"
+	if !enabled {
+		message = "Recent destinations turned off and cleared"
+	}
+	return map[string]bool{"enabled": enabled}, values.Success, message, nil
+}
@@ -0,0 +1,36 @@
+package rest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestRankRecentDestinations(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	destinations := []model.RecentDestination{
+		{ID: 1, VisitCount: 1, LastVisitedAt: now.Add(-time.Hour)},
+		{ID: 2, VisitCount: 8, LastVisitedAt: now.Add(-recentDestinationHalfLife)},
+		{ID: 3, VisitCount: 2, LastVisitedAt: now.Add(-4 * recentDestinationHalfLife)},
+		{ID: 4, VisitCount: 1, LastVisitedAt: now.Add(-time.Minute)},
+	}
+	rankRecentDestinations(destinations, now)
+
+	var order []int64
+	for _, d := range destinations {
+		order = append(order, d.ID)
+	}
+	want := []int64{2, 4, 1, 3}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if got := destinations[0].Score; got != 4 {
+		t.Errorf("8 visits one half-life ago scored %v, want 4", got)
+	}
+	if got := destinations[3].Score; got != 0.125 {
+		t.Errorf("2 visits four half-lives ago scored %v, want 0.125", got)
+	}
+}
@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrRecentDestinationNotFound = fmt.Errorf("recent destination %w", ErrNotFound)
+
+// RecordRecentDestinationRepo counts a visit to the destination nearest lat/lng within
+// mergeM, or adds a new one. Nothing is recorded for users who turned history off.
+func (api *API) RecordRecentDestinationRepo(ctx context.Context, userID uuid.UUID, lat, lng float64, name *string, mergeM float64) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var enabled bool
+		err := tx.QueryRow(ctx, `SELECT recent_destinations_enabled FROM users WHERE id = $1`, userID).Scan(&enabled)
+		if err != nil {
+			return dbError(err)
+		}
+		if !enabled {
+			return nil
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE recent_destinations
+			SET visit_count = visit_count + 1, last_visited_at = NOW(), name = COALESCE($4, name)
+			WHERE id = (
+				SELECT id FROM recent_destinations
+				WHERE user_id = $1
+				  AND ST_DWithin(location::geography, ST_SetSRID(ST_MakePoint($3, $2), 4326)::geography, $5)
+				ORDER BY location::geography <-> ST_SetSRID(ST_MakePoint($3, $2), 4326)::geography
+				LIMIT 1
+			)`, userID, lat, lng, name, mergeM)
+		if err != nil {
+			return fmt.Errorf("updating recent destination: %w", err)
+		}
+		if tag.RowsAffected() > 0 {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO recent_destinations (user_id, name, location)
+			VALUES ($1, $4, ST_SetSRID(ST_MakePoint($3, $2), 4326))`, userID, lat, lng, name)
+		if err != nil {
+			return fmt.Errorf("inserting recent destination: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListRecentDestinationsRepo returns up to limit of the user's destinations visited since
+// since, most recent first.
+func (api *API) ListRecentDestinationsRepo(ctx context.Context, userID uuid.UUID, since time.Time, limit int) ([]model.RecentDestination, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id, name, ST_Y(location), ST_X(location), visit_count, first_visited_at, last_visited_at
+		FROM recent_destinations
+		WHERE user_id = $1 AND last_visited_at >= $2
+		ORDER BY last_visited_at DESC
+		LIMIT $3`, userID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying recent destinations: %w", err)
+	}
+	defer rows.Close()
+
+	var destinations []model.RecentDestination
+	for rows.Next() {
+		var d model.RecentDestination
+		if err := rows.Scan(&d.ID, &d.Name, &d.Latitude, &d.Longitude, &d.VisitCount, &d.FirstVisitedAt, &d.LastVisitedAt); err != nil {
+			return nil, fmt.Errorf("scanning recent destination: %w", err)
+		}
+		destinations = append(destinations, d)
+	}
+	return destinations, rows.Err()
+}
+
+func (api *API) DeleteRecentDestinationRepo(ctx context.Context, userID uuid.UUID, id int64) error {
+	tag, err := api.DB.Exec(ctx, `DELETE FROM recent_destinations WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("deleting recent destination: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecentDestinationNotFound
+	}
+	return nil
+}
+
+// ClearRecentDestinationsRepo deletes the user's whole history and returns how many
+// destinations it held.
+func (api *API) ClearRecentDestinationsRepo(ctx context.Context, userID uuid.UUID) (int64, error) {
+	tag, err := api.DB.Exec(ctx, `DELETE FROM recent_destinations WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("clearing recent destinations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SetRecentDestinationsEnabledRepo turns history collection on or off; off also clears
+// the history.
+func (api *API) SetRecentDestinationsEnabledRepo(ctx context.Context, userID uuid.UUID, enabled bool) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE users SET recent_destinations_enabled = $2, updated_at = NOW()
+			WHERE id = $1`, userID, enabled)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		if enabled {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM recent_destinations WHERE user_id = $1`, userID)
+		return err
+	})
+}
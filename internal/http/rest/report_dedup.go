@@ -0,0 +1,90 @@
+package rest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reportDedupRule decides when a new report is folded into an existing one instead of
+// being inserted: an active report of the same type within RadiusM metres, created in the
+// last Window. A zero radius or window turns deduplication off for the type.
+type reportDedupRule struct {
+	RadiusM float64
+	Window  time.Duration
+}
+
+func (r reportDedupRule) enabled() bool {
+	return r.RadiusM > 0 && r.Window > 0
+}
+
+var defaultReportDedupRule = reportDedupRule{RadiusM: 100, Window: 30 * time.Minute}
+
+// reportDedupDefaults overrides defaultReportDedupRule per type. Every shared photo is
+// kept on its own.
+var reportDedupDefaults = map[string]reportDedupRule{
+	"PHOTOSHARING": {},
+}
+
+// reportDedupPolicy holds the REPORT_DEDUP_RULES overrides, parsed on first use.
+type reportDedupPolicy struct {
+	once      sync.Once
+	overrides map[string]reportDedupRule
+}
+
+// reportDedupRuleFor returns the rule for reportType: the REPORT_DEDUP_RULES entry if
+// there is one, then the built-in default for the type, then defaultReportDedupRule.
+func (api *API) reportDedupRuleFor(reportType string) reportDedupRule {
+	api.reportDedup.once.Do(func() {
+		rules, err := parseReportDedupRules(api.Config.ReportDedupRules)
+		if err != nil {
+			api.logger().Warn("Ignoring REPORT_DEDUP_RULES", "err", err)
+		}
+		api.reportDedup.overrides = rules
+	})
+	return lookupReportDedupRule(api.reportDedup.overrides, reportType)
+}
+
+func lookupReportDedupRule(overrides map[string]reportDedupRule, reportType string) reportDedupRule {
+	reportType = strings.ToUpper(reportType)
+	if r, ok := overrides[reportType]; ok {
+		return r
+	}
+	if r, ok := reportDedupDefaults[reportType]; ok {
+		return r
+	}
+	return defaultReportDedupRule
+}
+
+// parseReportDedupRules parses "TYPE=radius_m/window_min" entries separated by commas,
+// e.g. "POLICE=200/20,HAZARD=50/60,ACCIDENT=0/0" (0 turns deduplication off). A malformed
+// entry fails the whole value so a typo doesn't silently apply half the rules.
+func parseReportDedupRules(s string) (map[string]reportDedupRule, error) {
+	rules := map[string]reportDedupRule{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		reportType, spec, ok := strings.Cut(entry, "=")
+		radius, window, ok2 := strings.Cut(spec, "/")
+		if !ok || !ok2 || strings.TrimSpace(reportType) == "" {
+			return nil, fmt.Errorf("%q: want TYPE=radius_m/window_min", entry)
+		}
+		radiusM, err := strconv.ParseFloat(strings.TrimSpace(radius), 64)
+		if err != nil || radiusM < 0 {
+			return nil, fmt.Errorf("%q: invalid radius", entry)
+		}
+		minutes, err := strconv.Atoi(strings.TrimSpace(window))
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("%q: invalid window", entry)
+		}
+		rules[strings.ToUpper(strings.TrimSpace(reportType))] = reportDedupRule{
+			RadiusM: radiusM,
+			Window:  time.Duration(minutes) * time.Minute,
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReportDedupRules(t *testing.T) {
+	rules, err := parseReportDedupRules(" police=200/20, ACCIDENT=0/0,,HAZARD=50.5/60 ")
+	if err != nil {
+		t.Fatalf("parseReportDedupRules: %v", err)
+	}
+	want := map[string]reportDedupRule{
+		"POLICE":   {RadiusM: 200, Window: 20 * time.Minute},
+		"ACCIDENT": {},
+		"HAZARD":   {RadiusM: 50.5, Window: time.Hour},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d: %v", len(rules), len(want), rules)
+	}
+	for k, w := range want {
+		if rules[k] != w {
+			t.Errorf("rules[%s] = %+v, want %+v", k, rules[k], w)
+		}
+	}
+
+	if rules, err := parseReportDedupRules(""); err != nil || len(rules) != 0 {
+		t.Errorf("empty value = %v, %v; want no rules", rules, err)
+	}
+
+	for _, bad := range []string{"POLICE", "POLICE=200", "=200/20", "POLICE=x/20", "POLICE=200/x", "POLICE=-1/20", "POLICE=200/20,HAZARD"} {
+		if _, err := parseReportDedupRules(bad); err == nil {
+			t.Errorf("parseReportDedupRules(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestLookupReportDedupRule(t *testing.T) {
+	overrides := map[string]reportDedupRule{
+		"POLICE":       {RadiusM: 200, Window: 20 * time.Minute},
+		"PHOTOSHARING": {RadiusM: 10, Window: time.Minute},
+	}
+	cases := []struct {
+		reportType string
+		overrides  map[string]reportDedupRule
+		want       reportDedupRule
+	}{
+		{"POLICE", overrides, reportDedupRule{RadiusM: 200, Window: 20 * time.Minute}},
+		{"police", overrides, reportDedupRule{RadiusM: 200, Window: 20 * time.Minute}},
+		{"TRAFFIC", overrides, defaultReportDedupRule},
+		{"PHOTOSHARING", nil, reportDedupRule{}}, // built-in: off
+		{"PHOTOSHARING", overrides, reportDedupRule{RadiusM: 10, Window: time.Minute}},
+	}
+	for _, tc := range cases {
+		if got := lookupReportDedupRule(tc.overrides, tc.reportType); got != tc.want {
+			t.Errorf("lookupReportDedupRule(%s) = %+v, want %+v", tc.reportType, got, tc.want)
+		}
+	}
+	if lookupReportDedupRule(nil, "PHOTOSHARING").enabled() {
+		t.Error("PHOTOSHARING deduplication enabled by default")
+	}
+	if !defaultReportDedupRule.enabled() {
+		t.Error("default rule disabled")
+	}
+}
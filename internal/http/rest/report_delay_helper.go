@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"context"
+	"math"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+)
+
+const (
+	// reportDelayRadiusM is how close a report must be to count against a route; further
+	// out it is more likely on a parallel road or the other carriageway.
+	reportDelayRadiusM = 30
+	maxDelayReports    = 50
+	// maxReportDelayShare caps the total report delay relative to the routed duration, so
+	// a cluster of stale reports can't triple an ETA.
+	maxReportDelayShare = 1.0
+)
+
+var reportDelayTypes = []string{"TRAFFIC", "ACCIDENT"}
+
+// reportDelayBaseSeconds is the delay a severity 3 report adds, by type or type/subtype.
+var reportDelayBaseSeconds = map[string]float64{
+	"TRAFFIC":             120,
+	"TRAFFIC/LIGHT":       60,
+	"TRAFFIC/HEAVY":       240,
+	"TRAFFIC/STAND_STILL": 480,
+	"ACCIDENT":            240,
+	"ACCIDENT/MINOR":      120,
+	"ACCIDENT/MAJOR":      600,
+}
+
+// reportDelaySeconds is the expected delay of one report. Severity 3 gets the base
+// delay; each step above or below adds or removes a quarter of it.
+func reportDelaySeconds(reportType string, subtype *string, severity int) float64 {
+	base := reportDelayBaseSeconds[reportType]
+	if subtype != nil {
+		if b, ok := reportDelayBaseSeconds[reportType+"/"+*subtype]; ok {
+			base = b
+		}
+	}
+	return base * (1 + 0.25*float64(severity-3))
+}
+
+// routeReportDelays returns the TRAFFIC and ACCIDENT reports on the route with their
+// delay set, and the total delay capped against durationS.
+func (api *API) routeReportDelays(ctx context.Context, geometry [][]float64, durationS float64) ([]mapbox.RouteReport, float64, error) {
+	reports, err := api.GetReportsAlongRouteRepo(ctx, geometry, reportDelayRadiusM, reportDelayTypes, nil, maxDelayReports)
+	if err != nil {
+		return nil, 0, err
+	}
+	var total float64
+	for i := range reports {
+		reports[i].DelayS = reportDelaySeconds(reports[i].Type, reports[i].Subtype, reports[i].Severity)
+		total += reports[i].DelayS
+	}
+	return reports, math.Min(total, durationS*maxReportDelayShare), nil
+}
+
+// addReportDelays sets an adjusted duration on every route. It never fails the request;
+// on errors, or with the database down, routes are left with Mapbox/Valhalla's estimate.
+func (api *API) addReportDelays(ctx context.Context, resp *mapbox.DirectionsResponse) {
+	if resp == nil || !api.dbAvailable() {
+		return
+	}
+	for i := range resp.Routes {
+		route := &resp.Routes[i]
+		if len(route.Geometry.Coordinates) < 2 {
+			continue
+		}
+		reports, delayS, err := api.routeReportDelays(ctx, route.Geometry.Coordinates, route.Duration)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Report delays unavailable", "err", err)
+			return
+		}
+		route.AdjustedDuration = route.Duration + delayS
+		route.ReportDelays = reports
+	}
+}
+
+// addMobileTripReportDelays does the same for a Valhalla mobile trip, filling
+// AdjustedTimeSeconds and ReportDelays in its summary.
+func (api *API) addMobileTripReportDelays(ctx context.Context, trip *valhalla.MobileTrip) {
+	if trip == nil || !api.dbAvailable() {
+		return
+	}
+	var geometry [][]float64
+	for _, leg := range trip.Legs {
+		geometry = append(geometry, leg.Coordinates...)
+	}
+	if len(geometry) < 2 {
+		return
+	}
+	reports, delayS, err := api.routeReportDelays(ctx, geometry, trip.Summary.TotalTimeSeconds)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Report delays unavailable", "err", err)
+		return
+	}
+	trip.Summary.AdjustedTimeSeconds = trip.Summary.TotalTimeSeconds + delayS
+	for _, r := range reports {
+		trip.Summary.ReportDelays = append(trip.Summary.ReportDelays, valhalla.ReportDelay{
+			ReportID:       r.ID,
+			Type:           r.Type,
+			Subtype:        r.Subtype,
+			Severity:       r.Severity,
+			DistanceMeters: r.DistanceAlongM,
+			DelaySeconds:   r.DelayS,
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrReportDismissalNotFound = fmt.Errorf("report dismissal %w", ErrNotFound)
+
+// reportNotDismissedSQL filters out reports (aliased r) that the user in userParam has
+// dismissed or snoozed, unless the report has changed materially since.
+func reportNotDismissedSQL(userParam string) string {
+	return `NOT EXISTS (
+            SELECT 1 FROM report_dismissals d
+            WHERE d.report_id = r.id AND d.user_id = ` + userParam + `
+              AND d.report_version = r.material_version
+              AND (d.snoozed_until IS NULL OR d.snoozed_until > NOW())
+        )`
+}
+
+// DismissReportRepo records (or replaces) the user's dismissal of an active report against
+// its current material version. It returns ErrReportNotFound for missing or inactive reports.
+func (api *API) DismissReportRepo(ctx context.Context, userID uuid.UUID, reportID int64, snoozedUntil *time.Time) (model.ReportDismissal, error) {
+	query := `
+        INSERT INTO report_dismissals (user_id, report_id, report_version, snoozed_until)
+        SELECT $1, r.id, r.material_version, $3
+        FROM reports r
+        WHERE r.id = $2 AND r.active = true
+        ON CONFLICT (user_id, report_id) DO UPDATE
+        SET report_version = EXCLUDED.report_version,
+            snoozed_until = EXCLUDED.snoozed_until,
+            created_at = NOW()
+        RETURNING report_id, snoozed_until, created_at
+    `
+	var d model.ReportDismissal
+	err := api.DB.QueryRow(ctx, query, userID, reportID, snoozedUntil).Scan(&d.ReportID, &d.SnoozedUntil, &d.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrReportNotFound
+	}
+	return d, err
+}
+
+func (api *API) DeleteReportDismissalRepo(ctx context.Context, userID uuid.UUID, reportID int64) error {
+	result, err := api.DB.Exec(ctx, `DELETE FROM report_dismissals WHERE user_id = $1 AND report_id = $2`, userID, reportID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrReportDismissalNotFound
+	}
+	return nil
+}
+
+// ListReportDismissalsRepo returns the user's dismissals that are still in effect on
+// live reports, for syncing to the user's other devices.
+func (api *API) ListReportDismissalsRepo(ctx context.Context, userID uuid.UUID) ([]model.ReportDismissal, error) {
+	query := `
+        SELECT d.report_id, d.snoozed_until, d.created_at
+        FROM report_dismissals d
+        JOIN reports r ON r.id = d.report_id
+        WHERE d.user_id = $1
+          AND d.report_version = r.material_version
+          AND (d.snoozed_until IS NULL OR d.snoozed_until > NOW())
+          AND r.active = true
+          AND r.expires_at > NOW()
+        ORDER BY d.created_at DESC
+    `
+	rows, err := api.DB.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dismissals []model.ReportDismissal
+	for rows.Next() {
+		var d model.ReportDismissal
+		if err := rows.Scan(&d.ReportID, &d.SnoozedUntil, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		dismissals = append(dismissals, d)
+	}
+	return dismissals, rows.Err()
+}
+
+// ReportDismissedUserIDsRepo returns the users for whom reportID is currently dismissed,
+// so report pushes can skip them.
+func (api *API) ReportDismissedUserIDsRepo(ctx context.Context, reportID int64) (map[string]bool, error) {
+	query := `
+        SELECT d.user_id::text
+        FROM report_dismissals d
+        JOIN reports r ON r.id = d.report_id
+        WHERE d.report_id = $1
+          AND d.report_version = r.material_version
+          AND (d.snoozed_until IS NULL OR d.snoozed_until > NOW())
+    `
+	rows, err := api.DB.Query(ctx, query, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		users[id] = true
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,226 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	reportExportFolder = "report-exports"
+	// reportExportMaxRange caps one export at a year of reports.
+	reportExportMaxRange = 366 * 24 * time.Hour
+	reportExportTimeout  = 15 * time.Minute
+)
+
+func (api *API) CreateReportExportHelper(ctx context.Context, req model.CreateReportExportRequest, adminID uuid.UUID) (model.ReportExport, string, string, error) {
+	if !req.To.After(req.From) {
+		return model.ReportExport{}, values.BadRequestBody, "'to' must be after 'from'", fmt.Errorf("invalid date range")
+	}
+	if req.To.Sub(req.From) > reportExportMaxRange {
+		return model.ReportExport{}, values.BadRequestBody, "Date range cannot exceed one year", fmt.Errorf("date range too long")
+	}
+	if r := req.Region; r != nil && (r.MinLat >= r.MaxLat || r.MinLng >= r.MaxLng) {
+		return model.ReportExport{}, values.BadRequestBody, "Region min values must be below max values", fmt.Errorf("invalid region")
+	}
+	types := make([]string, 0, len(req.Types))
+	for _, t := range req.Types {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	filters := model.ReportExportFilters{Region: req.Region, Types: types, From: req.From, To: req.To}
+	export, err := api.InsertReportExportRepo(ctx, adminID, req.Format, filters)
+	if err != nil {
+		return model.ReportExport{}, values.Error, "Failed to create report export", err
+	}
+
+	go api.runReportExport(export)
+
+	return export, values.Created, "Report export started", nil
+}
+
+func (api *API) GetReportExportHelper(ctx context.Context, id int64) (model.ReportExport, string, string, error) {
+	export, err := api.GetReportExportRepo(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrReportExportNotFound) {
+			return model.ReportExport{}, values.NotFound, "Report export not found", err
+		}
+		return model.ReportExport{}, values.Error, "Failed to fetch report export", err
+	}
+	return export, values.Success, "Report export fetched successfully", nil
+}
+
+func (api *API) ListReportExportsHelper(ctx context.Context) ([]model.ReportExport, string, string, error) {
+	exports, err := api.ListReportExportsRepo(ctx, 50)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch report exports", err
+	}
+	if exports == nil {
+		exports = []model.ReportExport{}
+	}
+	return exports, values.Success, "Report exports fetched successfully", nil
+}
+
+// runReportExport writes the export to a temp file, uploads it and records the link.
+func (api *API) runReportExport(export model.ReportExport) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportExportTimeout)
+	defer cancel()
+
+	fail := func(err error) {
+		api.logger().ErrorContext(ctx, "Report export failed", "export_id", export.ID, "err", err)
+		if dbErr := api.FailReportExportRepo(context.Background(), export.ID, err.Error()); dbErr != nil {
+			api.logger().ErrorContext(ctx, "Failed to mark report export failed", "export_id", export.ID, "err", dbErr)
+		}
+	}
+
+	if err := api.MarkReportExportRunningRepo(ctx, export.ID); err != nil {
+		fail(err)
+		return
+	}
+
+	tmp, err := os.CreateTemp("", fmt.Sprintf("report-export-%d-*.%s", export.ID, export.Format))
+	if err != nil {
+		fail(err)
+		return
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	rowCount, err := api.writeReportExport(ctx, tmp, export)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	url, err := api.Deps.Cloudinary.UploadFile(ctx, tmpPath, reportExportFolder)
+	if err != nil {
+		fail(fmt.Errorf("upload failed: %w", err))
+		return
+	}
+	if err := api.CompleteReportExportRepo(ctx, export.ID, url, rowCount); err != nil {
+		fail(err)
+		return
+	}
+	api.logger().InfoContext(ctx, "Report export done", "export_id", export.ID, "reports", rowCount)
+}
+
+func (api *API) writeReportExport(ctx context.Context, f *os.File, export model.ReportExport) (int, error) {
+	w := bufio.NewWriter(f)
+	var count int
+	var err error
+	switch export.Format {
+	case "csv":
+		count, err = api.writeReportExportCSV(ctx, w, export.Filters)
+	case "geojson":
+		count, err = api.writeReportExportGeoJSON(ctx, w, export.Filters)
+	default:
+		err = fmt.Errorf("unknown export format %q", export.Format)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, w.Flush()
+}
+
+func (api *API) writeReportExportCSV(ctx context.Context, w *bufio.Writer, filters model.ReportExportFilters) (int, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{
+		"type", "subtype", "severity", "latitude", "longitude", "created_hour",
+		"resolved", "report_status", "verified_count", "upvotes", "downvotes",
+	}); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err := api.StreamAnonymizedReportsRepo(ctx, filters, func(r model.AnonymizedReport) error {
+		count++
+		return cw.Write([]string{
+			r.Type,
+			derefString(r.Subtype),
+			derefInt(r.Severity),
+			strconv.FormatFloat(r.Latitude, 'f', exportCoordinateDecimals, 64),
+			strconv.FormatFloat(r.Longitude, 'f', exportCoordinateDecimals, 64),
+			r.CreatedHour.UTC().Format(time.RFC3339),
+			strconv.FormatBool(r.Resolved),
+			derefString(r.ReportStatus),
+			strconv.Itoa(r.VerifiedCount),
+			strconv.Itoa(r.Upvotes),
+			strconv.Itoa(r.Downvotes),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// writeReportExportGeoJSON streams a FeatureCollection one feature at a time so large
+// exports never sit in memory.
+func (api *API) writeReportExportGeoJSON(ctx context.Context, w *bufio.Writer, filters model.ReportExportFilters) (int, error) {
+	type feature struct {
+		Type     string `json:"type"`
+		Geometry struct {
+			Type        string     `json:"type"`
+			Coordinates [2]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties model.AnonymizedReport `json:"properties"`
+	}
+
+	if _, err := w.WriteString(`{"type":"FeatureCollection","features":[`); err != nil {
+		return 0, err
+	}
+	count := 0
+	err := api.StreamAnonymizedReportsRepo(ctx, filters, func(r model.AnonymizedReport) error {
+		f := feature{Type: "Feature", Properties: r}
+		f.Geometry.Type = "Point"
+		f.Geometry.Coordinates = [2]float64{r.Longitude, r.Latitude}
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			if err := w.WriteByte(','); err != nil {
+				return err
+			}
+		}
+		count++
+		_, err = w.Write(b)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.WriteString("]}")
+	return count, err
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrReportExportNotFound = fmt.Errorf("report export %w", ErrNotFound)
+
+// exportCoordinateDecimals rounds exported positions to roughly 10 m.
+const exportCoordinateDecimals = 4
+
+const reportExportColumns = `id, requested_by, format, filters, status, file_url, row_count, error, created_at, completed_at`
+
+func scanReportExport(row pgx.Row) (model.ReportExport, error) {
+	var e model.ReportExport
+	var filters []byte
+	err := row.Scan(&e.ID, &e.RequestedBy, &e.Format, &filters, &e.Status, &e.FileURL, &e.RowCount, &e.Error, &e.CreatedAt, &e.CompletedAt)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(filters, &e.Filters)
+	return e, err
+}
+
+func (api *API) InsertReportExportRepo(ctx context.Context, requestedBy uuid.UUID, format string, filters model.ReportExportFilters) (model.ReportExport, error) {
+	filtersJSON, err := json.Marshal(filters)
+	if err != nil {
+		return model.ReportExport{}, err
+	}
+	query := `
+        INSERT INTO report_exports (requested_by, format, filters)
+        VALUES ($1, $2, $3)
+        RETURNING ` + reportExportColumns
+	return scanReportExport(api.DB.QueryRow(ctx, query, requestedBy, format, filtersJSON))
+}
+
+func (api *API) GetReportExportRepo(ctx context.Context, id int64) (model.ReportExport, error) {
+	query := `SELECT ` + reportExportColumns + ` FROM report_exports WHERE id = $1`
+	e, err := scanReportExport(api.DB.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return e, ErrReportExportNotFound
+	}
+	return e, err
+}
+
+func (api *API) ListReportExportsRepo(ctx context.Context, limit int) ([]model.ReportExport, error) {
+	query := `SELECT ` + reportExportColumns + ` FROM report_exports ORDER BY created_at DESC LIMIT $1`
+	rows, err := api.DB.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []model.ReportExport
+	for rows.Next() {
+		e, err := scanReportExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+func (api *API) MarkReportExportRunningRepo(ctx context.Context, id int64) error {
+	_, err := api.DB.Exec(ctx, `UPDATE report_exports SET status = 'running' WHERE id = $1`, id)
+	return err
+}
+
+func (api *API) CompleteReportExportRepo(ctx context.Context, id int64, fileURL string, rowCount int) error {
+	_, err := api.DB.Exec(ctx, `
+        UPDATE report_exports
+        SET status = 'done', file_url = $2, row_count = $3, error = NULL, completed_at = NOW()
+        WHERE id = $1
+    `, id, fileURL, rowCount)
+	return err
+}
+
+func (api *API) FailReportExportRepo(ctx context.Context, id int64, reason string) error {
+	_, err := api.DB.Exec(ctx, `
+        UPDATE report_exports SET status = 'failed', error = $2, completed_at = NOW() WHERE id = $1
+    `, id, reason)
+	return err
+}
+
+// FailInterruptedReportExportsRepo fails jobs left pending or running by a previous
+// process; exports run in-process and do not survive a restart.
+func (api *API) FailInterruptedReportExportsRepo(ctx context.Context) (int64, error) {
+	result, err := api.DB.Exec(ctx, `
+        UPDATE report_exports
+        SET status = 'failed', error = 'interrupted by server restart', completed_at = NOW()
+        WHERE status IN ('pending', 'running')
+    `)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// StreamAnonymizedReportsRepo calls fn for every report matching the filters, oldest first.
+func (api *API) StreamAnonymizedReportsRepo(ctx context.Context, f model.ReportExportFilters, fn func(model.AnonymizedReport) error) error {
+	query := fmt.Sprintf(`
+        SELECT type, subtype, severity,
+               ROUND(ST_Y(position)::numeric, %[1]d)::float8,
+               ROUND(ST_X(position)::numeric, %[1]d)::float8,
+               date_trunc('hour', created_at), COALESCE(resolved, false), report_status,
+               COALESCE(verified_count, 0), COALESCE(upvotes_count, 0), COALESCE(downvotes_count, 0)
+        FROM reports
+        WHERE created_at >= $1 AND created_at < $2
+          AND (cardinality($3::text[]) = 0 OR type = ANY($3))
+          AND ($4::float8 IS NULL OR position && ST_MakeEnvelope($5::float8, $4::float8, $7::float8, $6::float8, 4326))
+        ORDER BY created_at
+    `, exportCoordinateDecimals)
+
+	types := f.Types
+	if types == nil {
+		types = []string{}
+	}
+	var minLat, minLng, maxLat, maxLng *float64
+	if f.Region != nil {
+		minLat, minLng, maxLat, maxLng = &f.Region.MinLat, &f.Region.MinLng, &f.Region.MaxLat, &f.Region.MaxLng
+	}
+
+	rows, err := api.DB.Query(ctx, query, f.From, f.To, types, minLat, minLng, maxLat, maxLng)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.AnonymizedReport
+		if err := rows.Scan(&r.Type, &r.Subtype, &r.Severity, &r.Latitude, &r.Longitude,
+			&r.CreatedHour, &r.Resolved, &r.ReportStatus, &r.VerifiedCount, &r.Upvotes, &r.Downvotes); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	stadiamaps "github.com/bwise1/waze_kibris/internal/http/stadia_maps"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+)
+
+// reportLabelLanguages are the languages report location labels are resolved in; the
+// first is the fallback for callers asking for anything else.
+var reportLabelLanguages = []string{"en", "tr"}
+
+const reportLabelTimeout = 15 * time.Second
+
+// labelReportLocation reverse geocodes a new report once per label language and stores
+// the labels. It runs in the background after creation; failures just leave the report
+// without a label.
+func (api *API) labelReportLocation(reportID int64, lat, lng float64) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportLabelTimeout)
+	defer cancel()
+
+	labels := make(map[string]string, len(reportLabelLanguages))
+	for _, lang := range reportLabelLanguages {
+		params := &stadiamaps.GeocodeQuery{
+			Size:   util.IntPtr(1),
+			Layers: []string{"address", "street"},
+			Lang:   &lang,
+		}
+		results, err := api.reverseGeocode(ctx, "", lat, lng, params)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Failed to label report", "report_id", reportID, "lang", lang, "err", err)
+			continue
+		}
+		if len(results) == 0 {
+			continue
+		}
+		if label := reportLocationLabel(results[0]); label != "" {
+			labels[lang] = label
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+	if err := api.SetReportLocationLabelsRepo(ctx, reportID, labels); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to store report labels", "report_id", reportID, "err", err)
+	}
+}
+
+// reportLocationLabel shortens a reverse geocoding result to "street, town".
+func reportLocationLabel(p model.Place) string {
+	// Google only gives a full formatted address; its first two parts are street and town.
+	if p.Source == GeocoderGoogle {
+		parts := strings.Split(p.Address, ",")
+		if len(parts) > 2 {
+			parts = parts[:2]
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	street := cmp.Or(p.Street, p.Name)
+	town := cmp.Or(p.Locality, p.Region)
+	switch {
+	case street == "":
+		return town
+	case town == "" || town == street:
+		return street
+	}
+	return street + ", " + town
+}
+
+// requestLanguage returns the caller's label language: the lang query parameter, else the
+// first Accept-Language tag, else the default.
+func requestLanguage(r *http.Request) string {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = r.Header.Get("Accept-Language")
+	}
+	lang, _, _ = strings.Cut(lang, ",")
+	lang, _, _ = strings.Cut(lang, ";")
+	lang, _, _ = strings.Cut(lang, "-")
+	return strings.ToLower(strings.TrimSpace(lang))
+}
+
+// localizeReportLabel sets the report's LocationLabel for lang, falling back to the first
+// label language.
+func localizeReportLabel(report *model.Report, lang string) {
+	label, ok := report.LocationLabels[lang]
+	if !ok {
+		label, ok = report.LocationLabels[reportLabelLanguages[0]]
+	}
+	if ok {
+		report.LocationLabel = &label
+	}
+}
+
+// localizedReports returns a copy of reports with labels for lang; the input may be shared
+// with the read cache, so it is left untouched.
+func localizedReports(reports []model.Report, lang string) []model.Report {
+	out := make([]model.Report, len(reports))
+	copy(out, reports)
+	for i := range out {
+		localizeReportLabel(&out[i], lang)
+	}
+	return out
+}
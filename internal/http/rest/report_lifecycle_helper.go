@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	reportLifecycleInterval  = time.Minute
+	reportLifecycleBatchSize = 500
+	defaultReportMaxLifetime = 24 * time.Hour
+	// A "still there" answer keeps the report up for at least this share of its type's TTL.
+	reportConfirmExtendShare = 0.5
+	minReportConfirmExtend   = 15 * time.Minute
+	// Reports expire early once downvotes plus "not there" answers reach the type's
+	// threshold and are at least reportDisputeRatio times the upvotes plus confirmations (+1).
+	defaultReportDisputeMin = 3
+	reportDisputeRatio      = 2.0
+)
+
+// reportDisputeMins overrides defaultReportDisputeMin for types that go stale faster:
+// police checks move on and cleared hazards rarely get a second look.
+var reportDisputeMins = map[string]int{
+	"POLICE": 2,
+	"HAZARD": 2,
+}
+
+func (api *API) reportMaxLifetime() time.Duration {
+	if api.Config.ReportMaxLifetimeHours > 0 {
+		return time.Duration(api.Config.ReportMaxLifetimeHours) * time.Hour
+	}
+	return defaultReportMaxLifetime
+}
+
+// ConfirmReportHelper records a "still there?" answer. Confirmations extend the report by
+// part of its type's TTL; denials count towards expiring it early.
+func (api *API) ConfirmReportHelper(ctx context.Context, userID uuid.UUID, reportID int64, req model.ConfirmReportRequest) (model.ReportConfirmation, string, string, error) {
+	report, err := api.GetReportByIDRepo(ctx, strconv.FormatInt(reportID, 10))
+	if err != nil {
+		if errors.Is(err, ErrReportNotFound) {
+			return model.ReportConfirmation{}, values.NotFound, "Report not found", err
+		}
+		return model.ReportConfirmation{}, values.Error, "Failed to confirm report", err
+	}
+	extend := time.Duration(float64(api.ReportTTLFor(ctx, report.Type, report.Subtype)) * reportConfirmExtendShare)
+	if extend < minReportConfirmExtend {
+		extend = minReportConfirmExtend
+	}
+
+	confirmation, err := api.ConfirmReportRepo(ctx, userID, reportID, *req.StillThere, extend, api.reportMaxLifetime())
+	if err != nil {
+		if errors.Is(err, ErrReportNotFound) {
+			return model.ReportConfirmation{}, values.NotFound, "Report is no longer active", err
+		}
+		return model.ReportConfirmation{}, values.Error, "Failed to confirm report", err
+	}
+	api.reportTiles.invalidateReport(reportID)
+	return confirmation, values.Success, "Report confirmation recorded", nil
+}
+
+// RunReportLifecycle deactivates expired and disputed reports every minute until ctx is
+// cancelled, telling nearby clients through report.expired events.
+func (api *API) RunReportLifecycle(ctx context.Context) {
+	ticker := time.NewTicker(reportLifecycleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if api.dbAvailable() {
+				api.expireReports(ctx)
+			}
+		}
+	}
+}
+
+func (api *API) expireReports(ctx context.Context) {
+	types := make([]string, 0, len(reportDisputeMins))
+	mins := make([]int, 0, len(reportDisputeMins))
+	for t, n := range reportDisputeMins {
+		types = append(types, t)
+		mins = append(mins, n)
+	}
+
+	for {
+		expired, err := api.ExpireReportsRepo(ctx, types, mins, defaultReportDisputeMin, reportDisputeRatio, reportLifecycleBatchSize)
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Report expiry failed", "err", err)
+			return
+		}
+		if len(expired) > 0 {
+			api.NotifyOutbox()
+			for _, e := range expired {
+				if e.Reason != "ttl" {
+					api.logger().InfoContext(ctx, "Expired report early", "report_type", e.Type, "report_id", e.ReportID, "reason", e.Reason)
+				}
+			}
+		}
+		if len(expired) < reportLifecycleBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ConfirmReportRepo records the user's "still there?" answer for a live report, replacing
+// any earlier answer of theirs. A "still there" answer pushes expires_at out to at least
+// extendBy from now, but never past created_at + maxLifetime, and earns the author
+// reputation. It returns ErrReportNotFound for missing, inactive or expired reports.
+func (api *API) ConfirmReportRepo(ctx context.Context, userID uuid.UUID, reportID int64, stillThere bool, extendBy, maxLifetime time.Duration) (model.ReportConfirmation, error) {
+	c := model.ReportConfirmation{ReportID: reportID, StillThere: stillThere}
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var exists int
+		err := tx.QueryRow(ctx, `
+			SELECT 1 FROM reports
+			WHERE id = $1 AND active = true AND expires_at > NOW()
+			FOR UPDATE
+		`, reportID).Scan(&exists)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReportNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var previous *bool
+		err = tx.QueryRow(ctx, `
+			SELECT still_there FROM report_confirmations WHERE report_id = $1 AND user_id = $2
+		`, reportID, userID).Scan(&previous)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO report_confirmations (report_id, user_id, still_there)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (report_id, user_id) DO UPDATE
+			SET still_there = EXCLUDED.still_there, created_at = NOW()
+		`, reportID, userID, stillThere)
+		if err != nil {
+			return err
+		}
+
+		// Move the user's previous answer, if any, to the new one.
+		confirmDelta, notThereDelta := 0, 0
+		if stillThere {
+			confirmDelta++
+		} else {
+			notThereDelta++
+		}
+		if previous != nil && *previous {
+			confirmDelta--
+		} else if previous != nil {
+			notThereDelta--
+		}
+
+		if stillThere {
+			if err := awardReportAuthor(ctx, tx, reportID, &userID, reputationReportConfirmed, reputationConfirmedPoints); err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRow(ctx, `
+			UPDATE reports
+			SET confirmations_count = confirmations_count + $2,
+			    not_there_count = not_there_count + $3,
+			    last_confirmed_at = CASE WHEN $4 THEN NOW() ELSE last_confirmed_at END,
+			    expires_at = CASE WHEN $4 THEN LEAST(
+			                     created_at + $6 * INTERVAL '1 second',
+			                     GREATEST(expires_at, NOW() + $5 * INTERVAL '1 second'))
+			                 ELSE expires_at END,
+			    updated_at = NOW()
+			WHERE id = $1
+			RETURNING confirmations_count, not_there_count, last_confirmed_at, expires_at
+		`, reportID, confirmDelta, notThereDelta, stillThere, int(extendBy.Seconds()), int(maxLifetime.Seconds())).Scan(
+			&c.ConfirmationsCount, &c.NotThereCount, &c.LastConfirmedAt, &c.ExpiresAt,
+		)
+	})
+	return c, err
+}
+
+// ExpireReportsRepo deactivates up to limit active reports that are past expires_at, or
+// whose downvotes and "not there" answers reach the type's dispute threshold (default
+// minDisputes) and outweigh the upvotes and confirmations by disputeRatio. A report.expired
+// event is queued for each in the same transaction, and authors of disputed reports lose
+// reputation.
+func (api *API) ExpireReportsRepo(ctx context.Context, disputeTypes []string, disputeMins []int, minDisputes int, disputeRatio float64, limit int) ([]model.ReportExpiredEvent, error) {
+	query := `
+        WITH due AS (
+            SELECT r.id
+            FROM reports r
+            WHERE r.active = true
+              AND (
+                r.expires_at <= NOW()
+                OR (
+                    r.downvotes_count + r.not_there_count >= COALESCE(
+                        (SELECT t.n FROM unnest($1::text[], $2::int[]) AS t(type, n) WHERE t.type = r.type), $3)
+                    AND r.downvotes_count + r.not_there_count >= $4 * (r.upvotes_count + r.confirmations_count + 1)
+                )
+              )
+            ORDER BY r.expires_at
+            LIMIT $5
+            FOR UPDATE SKIP LOCKED
+        )
+        UPDATE reports r
+        SET active = false,
+            expired_at = NOW(),
+            expiry_reason = CASE WHEN r.expires_at <= NOW() THEN 'ttl' ELSE 'disputed' END,
+            expires_at = LEAST(r.expires_at, NOW()),
+            updated_at = NOW()
+        FROM due
+        WHERE r.id = due.id
+        RETURNING r.id, r.user_id, r.type, ST_Y(r.position), ST_X(r.position),
+                  COALESCE(r.severity, 4), r.expiry_reason, r.expired_at
+    `
+	var expired []model.ReportExpiredEvent
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		expired = nil
+		rows, err := tx.Query(ctx, query, disputeTypes, disputeMins, minDisputes, disputeRatio, limit)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var e model.ReportExpiredEvent
+			if err := rows.Scan(&e.ReportID, &e.UserID, &e.Type, &e.Latitude, &e.Longitude,
+				&e.Severity, &e.Reason, &e.ExpiredAt); err != nil {
+				rows.Close()
+				return err
+			}
+			expired = append(expired, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, e := range expired {
+			id := strconv.FormatInt(e.ReportID, 10)
+			if err := insertOutboxEvent(ctx, tx, OutboxEventReportExpired, id, OutboxEventReportExpired+":"+id, e); err != nil {
+				return err
+			}
+			if e.Reason == "disputed" {
+				if err := awardReportAuthor(ctx, tx, e.ReportID, nil, reputationReportDisputed, reputationDisputedPoints); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return expired, err
+}
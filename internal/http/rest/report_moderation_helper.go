@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// Report statuses (reports.report_status).
+const (
+	ReportStatusPending  = "PENDING"
+	ReportStatusVerified = "VERIFIED"
+	ReportStatusResolved = "RESOLVED"
+	ReportStatusRejected = "REJECTED"
+	ReportStatusMerged   = "MERGED"
+)
+
+// Moderator actions on reports (report_moderation_actions.action).
+const (
+	ReportModerationApprove = "approve"
+	ReportModerationReject  = "reject"
+	ReportModerationMerge   = "merge"
+)
+
+func (api *API) ListReportsForModerationHelper(ctx context.Context, status, limitStr string) ([]model.Report, string, string, error) {
+	switch status {
+	case "":
+		status = ReportStatusPending
+	case ReportStatusPending, ReportStatusVerified:
+	default:
+		return nil, values.BadRequestBody, "status must be PENDING or VERIFIED", fmt.Errorf("invalid status %q", status)
+	}
+	limit := 50
+	if limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > 200 {
+			return nil, values.BadRequestBody, "limit must be between 1 and 200", fmt.Errorf("invalid limit %q", limitStr)
+		}
+		limit = n
+	}
+
+	reports, err := api.ListReportsForModerationRepo(ctx, status, limit)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch reports", err
+	}
+	if reports == nil {
+		reports = []model.Report{}
+	}
+	return reports, values.Success, "Reports fetched successfully", nil
+}
+
+// ApproveReportHelper verifies a PENDING report.
+func (api *API) ApproveReportHelper(ctx context.Context, reportID int64, moderatorID uuid.UUID, req model.ModerateReportRequest) (model.ReportModerationAction, string, string, error) {
+	action, err := api.ModerateReportRepo(ctx, reportID, moderatorID, ReportModerationApprove, ReportStatusVerified,
+		[]string{ReportStatusPending}, req.Reason)
+	if err != nil {
+		return model.ReportModerationAction{}, errorStatus(err, values.Error), reportModerationErrorMessage(err, "Only pending reports can be approved"), err
+	}
+	api.reportTiles.invalidateReport(reportID)
+	return action, values.Success, "Report approved", nil
+}
+
+// RejectReportHelper rejects a PENDING or VERIFIED report and takes it off the map.
+func (api *API) RejectReportHelper(ctx context.Context, reportID int64, moderatorID uuid.UUID, req model.ModerateReportRequest) (model.ReportModerationAction, string, string, error) {
+	action, err := api.ModerateReportRepo(ctx, reportID, moderatorID, ReportModerationReject, ReportStatusRejected,
+		[]string{ReportStatusPending, ReportStatusVerified}, req.Reason)
+	if err != nil {
+		return model.ReportModerationAction{}, errorStatus(err, values.Error), reportModerationErrorMessage(err, "Only pending or verified reports can be rejected"), err
+	}
+	api.reportTiles.invalidateReport(reportID)
+	api.NotifyOutbox()
+	return action, values.Success, "Report rejected", nil
+}
+
+// MergeReportHelper folds a duplicate report into another report of the same type.
+func (api *API) MergeReportHelper(ctx context.Context, reportID int64, moderatorID uuid.UUID, req model.MergeReportRequest) (model.ReportModerationAction, string, string, error) {
+	if req.IntoReportID == reportID {
+		return model.ReportModerationAction{}, values.BadRequestBody, "A report can't be merged into itself", errors.New("merge into self")
+	}
+	action, err := api.MergeReportRepo(ctx, reportID, req.IntoReportID, moderatorID, req.Reason)
+	if err != nil {
+		return model.ReportModerationAction{}, errorStatus(err, values.Error), reportModerationErrorMessage(err, "Report has already been rejected or merged"), err
+	}
+	api.reportTiles.invalidateReport(reportID)
+	api.reportTiles.invalidateReport(req.IntoReportID)
+	api.NotifyOutbox()
+	return action, values.Success, "Report merged", nil
+}
+
+func (api *API) ListReportModerationActionsHelper(ctx context.Context, reportID int64) ([]model.ReportModerationAction, string, string, error) {
+	actions, err := api.ListReportModerationActionsRepo(ctx, reportID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch moderation history", err
+	}
+	if actions == nil {
+		actions = []model.ReportModerationAction{}
+	}
+	return actions, values.Success, "Moderation history fetched successfully", nil
+}
+
+// reportModerationErrorMessage describes a failed moderation action; transition is the
+// message for a report in the wrong status.
+func reportModerationErrorMessage(err error, transition string) string {
+	switch {
+	case errors.Is(err, ErrReportNotFound):
+		return "Report not found"
+	case errors.Is(err, ErrReportTransition):
+		return transition
+	case errors.Is(err, ErrReportMergeTarget):
+		return "Target report must be a live report of the same type that hasn't been rejected or merged"
+	}
+	return "Failed to moderate report"
+}
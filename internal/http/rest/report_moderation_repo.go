@@ -0,0 +1,256 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrReportTransition  = fmt.Errorf("report status transition %w", ErrConflict)
+	ErrReportMergeTarget = fmt.Errorf("merge target %w", ErrValidation)
+)
+
+const reportModerationActionColumns = `id, report_id, moderator_id, action, from_status, to_status, merged_into, reason, created_at`
+
+func scanReportModerationAction(row pgx.Row) (model.ReportModerationAction, error) {
+	var a model.ReportModerationAction
+	err := row.Scan(&a.ID, &a.ReportID, &a.ModeratorID, &a.Action, &a.FromStatus, &a.ToStatus,
+		&a.MergedInto, &a.Reason, &a.CreatedAt)
+	return a, err
+}
+
+// moderatedReport is the part of a report a moderation action checks and, when the report
+// comes down, puts in its report.expired event.
+type moderatedReport struct {
+	model.ReportExpiredEvent
+	Status    *string
+	Active    bool
+	Upvotes   int
+	Confirmed int
+}
+
+func lockModeratedReport(ctx context.Context, tx pgx.Tx, reportID int64) (moderatedReport, error) {
+	var m moderatedReport
+	err := tx.QueryRow(ctx, `
+		SELECT id, user_id, type, ST_Y(position), ST_X(position), COALESCE(severity, 4),
+		       report_status, active, upvotes_count, confirmations_count
+		FROM reports
+		WHERE id = $1
+		FOR UPDATE
+	`, reportID).Scan(&m.ReportID, &m.UserID, &m.Type, &m.Latitude, &m.Longitude, &m.Severity,
+		&m.Status, &m.Active, &m.Upvotes, &m.Confirmed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return m, ErrReportNotFound
+	}
+	return m, err
+}
+
+// insertReportModerationAction appends to the audit trail.
+func insertReportModerationAction(ctx context.Context, tx pgx.Tx, a model.ReportModerationAction) (model.ReportModerationAction, error) {
+	return scanReportModerationAction(tx.QueryRow(ctx, `
+		INSERT INTO report_moderation_actions (report_id, moderator_id, action, from_status, to_status, merged_into, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+reportModerationActionColumns,
+		a.ReportID, a.ModeratorID, a.Action, a.FromStatus, a.ToStatus, a.MergedInto, a.Reason))
+}
+
+// takeDownModeratedReport deactivates a rejected or merged report and, if it was still on
+// the map, queues a report.expired event so clients drop it.
+func takeDownModeratedReport(ctx context.Context, tx pgx.Tx, m moderatedReport, reason string) error {
+	err := tx.QueryRow(ctx, `
+		UPDATE reports
+		SET active = false,
+		    expired_at = COALESCE(expired_at, NOW()),
+		    expiry_reason = COALESCE(expiry_reason, $2),
+		    expires_at = LEAST(expires_at, NOW()),
+		    updated_at = NOW()
+		WHERE id = $1
+		RETURNING expired_at
+	`, m.ReportID, reason).Scan(&m.ExpiredAt)
+	if err != nil || !m.Active {
+		return err
+	}
+	m.Reason = reason
+	id := strconv.FormatInt(m.ReportID, 10)
+	return insertOutboxEvent(ctx, tx, OutboxEventReportExpired, id, OutboxEventReportExpired+":"+id, m.ReportExpiredEvent)
+}
+
+// ListReportsForModerationRepo returns up to limit live reports in the given status,
+// oldest first.
+func (api *API) ListReportsForModerationRepo(ctx context.Context, status string, limit int) ([]model.Report, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT
+            r.id, r.user_id, u.username, r.type, r.subtype, ST_X(r.position) as longitude,
+            ST_Y(r.position) as latitude, r.description, r.severity, r.verified_count,
+            r.active, r.resolved, r.created_at, r.updated_at, r.expires_at, r.image_url,
+            r.report_source, r.report_status, r.comments_count, r.upvotes_count, r.downvotes_count,
+            r.location_labels
+        FROM reports r
+        JOIN users u ON u.id = r.user_id
+        WHERE r.report_status = $1
+          AND r.active = true
+          AND r.expires_at > NOW()
+        ORDER BY r.created_at
+        LIMIT $2
+    `, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []model.Report
+	for rows.Next() {
+		var report model.Report
+		if err := rows.Scan(
+			&report.ID, &report.UserID, &report.Username, &report.Type, &report.Subtype,
+			&report.Longitude, &report.Latitude, &report.Description, &report.Severity,
+			&report.VerifiedCount, &report.Active, &report.Resolved, &report.CreatedAt,
+			&report.UpdatedAt, &report.ExpiresAt, &report.ImageURL, &report.ReportSource,
+			&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
+			&report.DownvotesCount, &report.LocationLabels,
+		); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// ModerateReportRepo moves a report to toStatus if its current status is one of from, and
+// records the action. Rejected reports are taken off the map. It returns ErrReportNotFound
+// or, for any other starting status, ErrReportTransition.
+func (api *API) ModerateReportRepo(ctx context.Context, reportID int64, moderatorID uuid.UUID, action, toStatus string, from []string, reason *string) (model.ReportModerationAction, error) {
+	var out model.ReportModerationAction
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		m, err := lockModeratedReport(ctx, tx, reportID)
+		if err != nil {
+			return err
+		}
+		fromStatus := ReportStatusPending
+		if m.Status != nil {
+			fromStatus = *m.Status
+		}
+		if !slices.Contains(from, fromStatus) {
+			return ErrReportTransition
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE reports SET report_status = $2, updated_at = NOW() WHERE id = $1
+		`, reportID, toStatus); err != nil {
+			return err
+		}
+		if toStatus == ReportStatusRejected {
+			if err := takeDownModeratedReport(ctx, tx, m, "rejected"); err != nil {
+				return err
+			}
+		}
+
+		out, err = insertReportModerationAction(ctx, tx, model.ReportModerationAction{
+			ReportID:    reportID,
+			ModeratorID: &moderatorID,
+			Action:      action,
+			FromStatus:  m.Status,
+			ToStatus:    toStatus,
+			Reason:      reason,
+		})
+		return err
+	})
+	return out, err
+}
+
+// MergeReportRepo folds a duplicate report into intoID: the target keeps the duplicate's
+// upvotes and confirmations and the later of the two expiries, and the duplicate becomes
+// MERGED and comes off the map. Both must be of the same type, and the target must be live
+// and neither rejected nor merged itself (ErrReportMergeTarget).
+func (api *API) MergeReportRepo(ctx context.Context, reportID, intoID int64, moderatorID uuid.UUID, reason *string) (model.ReportModerationAction, error) {
+	var out model.ReportModerationAction
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		// Lock in id order so two merges of the same pair can't deadlock.
+		first, second := reportID, intoID
+		if second < first {
+			first, second = second, first
+		}
+		a, err := lockModeratedReport(ctx, tx, first)
+		if err != nil {
+			return err
+		}
+		b, err := lockModeratedReport(ctx, tx, second)
+		if err != nil {
+			return err
+		}
+		dup, into := a, b
+		if dup.ReportID != reportID {
+			dup, into = b, a
+		}
+
+		if dup.Status != nil && (*dup.Status == ReportStatusMerged || *dup.Status == ReportStatusRejected) {
+			return ErrReportTransition
+		}
+		if !into.Active || dup.Type != into.Type ||
+			(into.Status != nil && (*into.Status == ReportStatusMerged || *into.Status == ReportStatusRejected)) {
+			return ErrReportMergeTarget
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE reports
+			SET upvotes_count = upvotes_count + $2,
+			    confirmations_count = confirmations_count + $3,
+			    expires_at = GREATEST(expires_at, (SELECT expires_at FROM reports WHERE id = $4)),
+			    updated_at = NOW()
+			WHERE id = $1
+		`, intoID, dup.Upvotes, dup.Confirmed, reportID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE reports SET report_status = $2, merged_into = $3, updated_at = NOW() WHERE id = $1
+		`, reportID, ReportStatusMerged, intoID); err != nil {
+			return err
+		}
+		if err := takeDownModeratedReport(ctx, tx, dup, "merged"); err != nil {
+			return err
+		}
+
+		out, err = insertReportModerationAction(ctx, tx, model.ReportModerationAction{
+			ReportID:    reportID,
+			ModeratorID: &moderatorID,
+			Action:      ReportModerationMerge,
+			FromStatus:  dup.Status,
+			ToStatus:    ReportStatusMerged,
+			MergedInto:  &intoID,
+			Reason:      reason,
+		})
+		return err
+	})
+	return out, err
+}
+
+// ListReportModerationActionsRepo returns a report's audit trail, newest first.
+func (api *API) ListReportModerationActionsRepo(ctx context.Context, reportID int64) ([]model.ReportModerationAction, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT `+reportModerationActionColumns+`
+		FROM report_moderation_actions
+		WHERE report_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []model.ReportModerationAction
+	for rows.Next() {
+		a, err := scanReportModerationAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// Report types the resolver watches: both show up as slow traffic and clear once it flows again.
+var autoResolveReportTypes = []string{"TRAFFIC", "ACCIDENT"}
+
+const (
+	defaultAutoResolveGraceMinutes = 10
+	defaultAutoResolveSpeedRatio   = 0.8
+	autoResolveInterval            = 2 * time.Minute
+	// Reports younger than this are left alone; the first pings past a fresh accident are
+	// often from before it slowed anyone down.
+	autoResolveMinAge = "10 minutes"
+	// Pings within autoResolveRadiusM of the report over autoResolveRecent count as current speed.
+	autoResolveRecent   = "10 minutes"
+	autoResolveRadiusM  = 75
+	autoResolveMinPings = 3
+	// Locations with fewer historical pings than this are compared against autoResolveFallbackMS.
+	autoResolveMinUsualPings = 10
+	autoResolveFallbackMS    = 8.3 // ~30 km/h
+	// Resolved reports stay on the map this long so drivers see that it cleared.
+	autoResolveLinger = "15 minutes"
+)
+
+func (api *API) autoResolveGrace() time.Duration {
+	if api.Config.ReportAutoResolveGraceMinutes > 0 {
+		return time.Duration(api.Config.ReportAutoResolveGraceMinutes) * time.Minute
+	}
+	return defaultAutoResolveGraceMinutes * time.Minute
+}
+
+func (api *API) autoResolveSpeedRatio() float64 {
+	if r := api.Config.ReportAutoResolveSpeedRatio; r > 0 && r <= 1 {
+		return r
+	}
+	return defaultAutoResolveSpeedRatio
+}
+
+// speedsRecovered reports whether traffic around the report is moving normally again, and
+// the speed it counted as normal. ok is false when there were too few recent pings to tell.
+func speedsRecovered(c model.ReportSpeedCheck, ratio float64) (recovered bool, normalMS float64, ok bool) {
+	if c.RecentSamples < autoResolveMinPings {
+		return false, 0, false
+	}
+	normalMS = autoResolveFallbackMS
+	if c.UsualSamples >= autoResolveMinUsualPings && c.UsualMedianMS > 0 {
+		normalMS = c.UsualMedianMS
+	}
+	return c.RecentMedianMS >= ratio*normalMS, normalMS, true
+}
+
+// RunReportAutoResolver resolves TRAFFIC and ACCIDENT reports once speeds around them have
+// stayed back to normal for the grace period, until ctx is cancelled.
+func (api *API) RunReportAutoResolver(ctx context.Context) {
+	if api.Config.ReportAutoResolveGraceMinutes < 0 {
+		return
+	}
+	ticker := time.NewTicker(autoResolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if api.dbAvailable() {
+				api.autoResolveReports(ctx)
+			}
+		}
+	}
+}
+
+func (api *API) autoResolveReports(ctx context.Context) {
+	checks, err := api.ReportSpeedChecksRepo(ctx, autoResolveReportTypes, autoResolveMinAge, autoResolveRecent, autoResolveRadiusM)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Report auto-resolution check failed", "err", err)
+		return
+	}
+
+	grace, ratio := api.autoResolveGrace(), api.autoResolveSpeedRatio()
+	resolved := 0
+	for _, c := range checks {
+		recovered, normalMS, ok := speedsRecovered(c, ratio)
+		if !ok {
+			// No traffic to judge by: keep whatever grace period is running.
+			continue
+		}
+		switch {
+		case !recovered && c.ReopenDetectedAt != nil:
+			err = api.SetReportReopenDetectedRepo(ctx, c.ReportID, false)
+		case recovered && c.ReopenDetectedAt == nil:
+			err = api.SetReportReopenDetectedRepo(ctx, c.ReportID, true)
+		case recovered && time.Since(*c.ReopenDetectedAt) >= grace:
+			var done bool
+			done, err = api.AutoResolveReportRepo(ctx, c, normalMS, autoResolveLinger)
+			if done {
+				resolved++
+				api.logger().InfoContext(ctx, "Auto-resolved report", "report_type", c.Type, "report_id", c.ReportID,
+					"median_ms", c.RecentMedianMS, "normal_ms", normalMS)
+			}
+		}
+		if err != nil {
+			api.logger().ErrorContext(ctx, "Report auto-resolution failed", "report_id", c.ReportID, "err", err)
+			err = nil
+		}
+	}
+	if resolved > 0 {
+		api.NotifyOutbox()
+	}
+}
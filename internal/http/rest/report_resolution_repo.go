@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReportSpeedChecksRepo returns, for each open report of the given types older than minAge,
+// the median ping speed within radiusM over the recent window and the usual median there
+// within an hour of the current time of day over the 28 days before the report.
+func (api *API) ReportSpeedChecksRepo(ctx context.Context, types []string, minAge, recent string, radiusM float64) ([]model.ReportSpeedCheck, error) {
+	query := `
+        SELECT
+            r.id, r.user_id, r.type, ST_Y(r.position), ST_X(r.position),
+            COALESCE(r.severity, 4), r.reopen_detected_at,
+            recent.samples, recent.median, usual.samples, usual.median
+        FROM reports r
+        CROSS JOIN LATERAL (
+            SELECT COUNT(*)::int AS samples,
+                   COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY p.speed), 0) AS median
+            FROM user_location_pings p
+            WHERE p.speed IS NOT NULL
+              AND p.recorded_at > NOW() - $3::interval
+              AND ST_DWithin(p.position::geography, r.position::geography, $4)
+        ) recent
+        CROSS JOIN LATERAL (
+            SELECT COUNT(*)::int AS samples,
+                   COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY p.speed), 0) AS median
+            FROM user_location_pings p
+            WHERE p.speed IS NOT NULL
+              AND p.recorded_at > r.created_at - INTERVAL '28 days'
+              AND p.recorded_at < r.created_at
+              AND LEAST(
+                    ABS(EXTRACT(HOUR FROM p.recorded_at) - EXTRACT(HOUR FROM NOW())),
+                    24 - ABS(EXTRACT(HOUR FROM p.recorded_at) - EXTRACT(HOUR FROM NOW()))
+                  ) <= 1
+              AND ST_DWithin(p.position::geography, r.position::geography, $4)
+        ) usual
+        WHERE r.active = true
+          AND r.resolved IS NOT TRUE
+          AND r.expires_at > NOW()
+          AND r.type = ANY($1)
+          AND r.created_at < NOW() - $2::interval
+    `
+	rows, err := api.DB.Query(ctx, query, types, minAge, recent, radiusM)
+	if err != nil {
+		return nil, fmt.Errorf("querying report speed checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []model.ReportSpeedCheck
+	for rows.Next() {
+		var c model.ReportSpeedCheck
+		if err := rows.Scan(&c.ReportID, &c.UserID, &c.Type, &c.Latitude, &c.Longitude,
+			&c.Severity, &c.ReopenDetectedAt,
+			&c.RecentSamples, &c.RecentMedianMS, &c.UsualSamples, &c.UsualMedianMS); err != nil {
+			return nil, fmt.Errorf("scanning report speed check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// SetReportReopenDetectedRepo starts (detected) or cancels (!detected) the grace period
+// of an open report.
+func (api *API) SetReportReopenDetectedRepo(ctx context.Context, reportID int64, detected bool) error {
+	query := `UPDATE reports SET reopen_detected_at = NULL WHERE id = $1`
+	if detected {
+		query = `UPDATE reports SET reopen_detected_at = NOW() WHERE id = $1 AND reopen_detected_at IS NULL`
+	}
+	_, err := api.DB.Exec(ctx, query, reportID)
+	return err
+}
+
+// AutoResolveReportRepo resolves a report whose grace period has run, shortens its expiry to
+// lingerFor so it drops off the map soon, credits the author and queues the report.resolved
+// event. It returns false if the report was resolved, reopened or deactivated in the meantime.
+func (api *API) AutoResolveReportRepo(ctx context.Context, c model.ReportSpeedCheck, normalMS float64, lingerFor string) (bool, error) {
+	query := `
+        UPDATE reports
+        SET resolved = true,
+            auto_resolved_at = NOW(),
+            reopen_detected_at = NULL,
+            material_version = material_version + 1,
+            expires_at = LEAST(expires_at, NOW() + $2::interval),
+            updated_at = NOW()
+        WHERE id = $1
+          AND active = true
+          AND resolved IS NOT TRUE
+          AND reopen_detected_at IS NOT NULL
+        RETURNING auto_resolved_at
+    `
+	resolved := false
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var at time.Time
+		if err := tx.QueryRow(ctx, query, c.ReportID, lingerFor).Scan(&at); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		resolved = true
+
+		if err := awardReportAuthor(ctx, tx, c.ReportID, nil, reputationReportResolved, reputationResolvedPoints); err != nil {
+			return err
+		}
+		id := strconv.FormatInt(c.ReportID, 10)
+		return insertOutboxEvent(ctx, tx, OutboxEventReportResolved, id, OutboxEventReportResolved+":"+id,
+			model.ReportResolvedEvent{
+				ReportID:      c.ReportID,
+				UserID:        c.UserID,
+				Type:          c.Type,
+				Latitude:      c.Latitude,
+				Longitude:     c.Longitude,
+				Severity:      c.Severity,
+				Reason:        "speeds_recovered",
+				RecentSpeedMS: c.RecentMedianMS,
+				NormalSpeedMS: normalMS,
+				ResolvedAt:    at,
+			})
+	})
+	return resolved, err
+}
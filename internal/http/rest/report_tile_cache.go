@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+const (
+	// reportTileZoom is the web-mercator zoom of the cached tiles (~4 km across at
+	// Cyprus' latitude).
+	reportTileZoom            = 13
+	reportTileCacheTTL        = 30 * time.Second
+	reportTileCacheMaxEntries = 4096
+	// reportTileMaxReports caps one tile's query; busier tiles are better viewed as clusters.
+	reportTileMaxReports = 1000
+)
+
+// reportTile is a web-mercator tile at reportTileZoom.
+type reportTile struct {
+	X, Y int
+}
+
+// reportTileAt returns the tile containing a point.
+func reportTileAt(lat, lng float64) reportTile {
+	n := float64(int(1) << reportTileZoom)
+	lat = math.Max(-85.05112878, math.Min(85.05112878, lat))
+	latRad := lat * math.Pi / 180
+	x := int(math.Floor((lng + 180) / 360 * n))
+	y := int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n))
+	last := int(n) - 1
+	return reportTile{X: max(0, min(x, last)), Y: max(0, min(y, last))}
+}
+
+// bounds returns the tile's extent; tile rows count down from the north.
+func (t reportTile) bounds() model.BoundingBox {
+	n := float64(int(1) << reportTileZoom)
+	lat := func(y int) float64 {
+		return math.Atan(math.Sinh(math.Pi*(1-2*float64(y)/n))) * 180 / math.Pi
+	}
+	return model.BoundingBox{
+		MinLng: float64(t.X)/n*360 - 180,
+		MaxLng: float64(t.X+1)/n*360 - 180,
+		MinLat: lat(t.Y + 1),
+		MaxLat: lat(t.Y),
+	}
+}
+
+// reportTilesIn lists the tiles covering box, west to east and north to south.
+func reportTilesIn(box model.BoundingBox) []reportTile {
+	nw := reportTileAt(box.MaxLat, box.MinLng)
+	se := reportTileAt(box.MinLat, box.MaxLng)
+	tiles := make([]reportTile, 0, (se.X-nw.X+1)*(se.Y-nw.Y+1))
+	for y := nw.Y; y <= se.Y; y++ {
+		for x := nw.X; x <= se.X; x++ {
+			tiles = append(tiles, reportTile{X: x, Y: y})
+		}
+	}
+	return tiles
+}
+
+// reportTileCache keeps the active reports of recently viewed tiles so many clients
+// panning over the same area share one PostGIS query per tile. Entries hold every active
+// report in the tile; per-viewer filters are applied on the way out. Writes through this
+// instance invalidate the affected tile, and the short TTL bounds staleness from writes
+// elsewhere.
+type reportTileCache struct {
+	mu      sync.Mutex
+	entries map[reportTile]reportTileEntry
+	tileOf  map[int64]reportTile // report ID -> cached tile holding it
+}
+
+type reportTileEntry struct {
+	reports  []model.Report
+	storedAt time.Time
+}
+
+func (c *reportTileCache) get(tile reportTile) ([]model.Report, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[tile]
+	if !ok || time.Since(e.storedAt) > reportTileCacheTTL {
+		return nil, false
+	}
+	return e.reports, true
+}
+
+func (c *reportTileCache) put(tile reportTile, reports []model.Report) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= reportTileCacheMaxEntries {
+		c.entries = make(map[reportTile]reportTileEntry)
+		c.tileOf = make(map[int64]reportTile)
+	}
+	c.entries[tile] = reportTileEntry{reports: reports, storedAt: time.Now()}
+	for _, r := range reports {
+		c.tileOf[r.ID] = tile
+	}
+}
+
+// invalidatePoint drops the tile containing a report's (new) position.
+func (c *reportTileCache) invalidatePoint(lat, lng float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, reportTileAt(lat, lng))
+}
+
+// invalidateReport drops the cached tile holding a report, for changes whose position
+// isn't at hand (or that moved the report out of its tile).
+func (c *reportTileCache) invalidateReport(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tile, ok := c.tileOf[id]; ok {
+		delete(c.entries, tile)
+		delete(c.tileOf, id)
+	}
+}
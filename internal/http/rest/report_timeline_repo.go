@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// GetReportTimelineRepo merges the report's creation, votes, comments and recorded changes,
+// newest first, returning at most limit events. Events at the same instant keep their
+// causal order (created, then activity, then changes). It returns ErrReportNotFound when
+// the report doesn't exist.
+func (api *API) GetReportTimelineRepo(ctx context.Context, reportID int64, limit int) ([]model.ReportTimelineEvent, error) {
+	query := `
+        SELECT kind, at, user_id, username, comment, old_value, new_value, automatic
+        FROM (
+            SELECT 'created' AS kind, r.created_at AS at, r.user_id, u.username,
+                   NULL::text AS comment, NULL::text AS old_value, NULL::text AS new_value,
+                   false AS automatic, 0 AS seq
+            FROM reports r
+            JOIN users u ON u.id = r.user_id
+            WHERE r.id = $1
+            UNION ALL
+            SELECT CASE WHEN v.vote_type = 'UPVOTE' THEN 'confirmed' ELSE 'disputed' END,
+                   v.created_at, v.user_id, u.username, NULL, NULL, NULL, false, 1
+            FROM votes v
+            LEFT JOIN users u ON u.id = v.user_id
+            WHERE v.report_id = $1
+            UNION ALL
+            SELECT 'comment', c.created_at, c.user_id, u.username, c.content, NULL, NULL, false, 1
+            FROM comments c
+            LEFT JOIN users u ON u.id = c.user_id
+            WHERE c.report_id = $1 AND c.deleted_at IS NULL
+            UNION ALL
+            SELECT CASE
+                       WHEN ch.field = 'severity' THEN 'severity_changed'
+                       WHEN ch.field = 'report_status' THEN 'status_changed'
+                       WHEN ch.new_value = 'true' THEN 'resolved'
+                       ELSE 'reopened'
+                   END,
+                   ch.created_at, NULL, NULL, NULL,
+                   CASE WHEN ch.field = 'resolved' THEN NULL ELSE ch.old_value END,
+                   CASE WHEN ch.field = 'resolved' THEN NULL ELSE ch.new_value END,
+                   ch.automatic, 2
+            FROM report_changes ch
+            WHERE ch.report_id = $1
+        ) e
+        ORDER BY at DESC, seq DESC
+        LIMIT $2
+    `
+	rows, err := api.DB.Query(ctx, query, reportID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.ReportTimelineEvent
+	for rows.Next() {
+		var e model.ReportTimelineEvent
+		if err := rows.Scan(&e.Kind, &e.At, &e.UserID, &e.Username, &e.Comment, &e.From, &e.To, &e.Automatic); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Every existing report has at least its created event.
+	if len(events) == 0 {
+		return nil, ErrReportNotFound
+	}
+	return events, nil
+}
@@ -5,10 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -16,9 +14,11 @@ import (
 	"github.com/bwise1/waze_kibris/internal/http/mapbox"
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/storage"
 	"github.com/bwise1/waze_kibris/util/tracing"
 	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 func (api *API) ReportRoutes() chi.Router {
@@ -26,16 +26,50 @@ func (api *API) ReportRoutes() chi.Router {
 
 	mux.Group(func(r chi.Router) {
 		r.Use(api.RequireLogin)
-		r.Method(http.MethodPost, "/", Handler(api.CreateReport))
+		r.With(api.VerifySignedRequestUpTo(signedReportMaxBytes), api.RateLimit(RateLimitReportCreate)).Method(http.MethodPost, "/", Handler(api.CreateReport))
+		// Active reports around a point, nearest first, one page at a time
+		// Query Params: ?latitude=&longitude= (required), ?radius=1000, ?type=..., ?status=...,
+		// ?page=1, ?page_size=10 (max 100) or ?cursor=<next_cursor>; ?format=geojson for a
+		// FeatureCollection of report Points
 		r.Method(http.MethodGet, "/nearby", Handler(api.GetNearbyReports))
+		// Reports in a time window, including expired ones, for support lookups
+		// Query Params: ?from=&to= (RFC3339, default last 24h, max 31 days), ?type=..., ?status=...,
+		// ?bbox=minLng,minLat,maxLng,maxLat, ?reporter=<user id> (admins only), ?limit=50, ?cursor=<next_cursor>
+		r.Method(http.MethodGet, "/search", Handler(api.SearchReports))
+		// Active reports in a map viewport, served from per-tile caches shared between clients
+		// Query Params: ?minLat=&minLng=&maxLat=&maxLng= (required), ?type=...
+		r.Method(http.MethodGet, "/bbox", Handler(api.GetReportsInBBox))
+		// Active reports in a map viewport grouped into clusters (count, centroid, dominant type)
+		// Query Params: ?bbox=minLng,minLat,maxLng,maxLat (required), ?zoom=0-22 (required), ?type=...
+		r.Method(http.MethodGet, "/clusters", Handler(api.GetReportClusters))
+		// Reports the user has dismissed or snoozed, for syncing across devices
+		r.Method(http.MethodGet, "/dismissals", Handler(api.ListReportDismissals))
 
 		r.Method(http.MethodGet, "/{reportID}", Handler(api.GetReportByID))
 		r.Method(http.MethodPut, "/{id}", Handler(api.UpdateReport))
 		r.Method(http.MethodDelete, "/{id}", Handler(api.DeleteReport))
+		// Request Body: { "vote_type": "upvote" | "downvote" }; one vote per user, voting
+		// again with the other type switches it
 		r.Method(http.MethodPost, "/{reportID}/votes", Handler(api.VoteOnReport))
+		r.Method(http.MethodDelete, "/{reportID}/votes", Handler(api.RetractVote))
 		r.Method(http.MethodGet, "/{reportID}/votes", Handler(api.GetVotes))
+		// Request Body: { "content": "...", "parent_comment_id": "<comment id, for replies>" }
 		r.Method(http.MethodPost, "/{reportID}/comments", Handler(api.CommentOnReport))
+		// Comments threaded by parent_comment_id, oldest first; deleted comments stay as
+		// placeholders while they have replies. Paged by thread: ?page=1, ?page_size=20
+		// (max 100) or ?cursor=<next_cursor>
 		r.Method(http.MethodGet, "/{reportID}/comments", Handler(api.GetComments))
+		// Request Body: { "content": "..." }; authors edit, authors and moderators delete
+		r.Method(http.MethodPut, "/{reportID}/comments/{commentID}", Handler(api.UpdateComment))
+		r.Method(http.MethodDelete, "/{reportID}/comments/{commentID}", Handler(api.DeleteComment))
+		// Creation, votes, comments and severity/status/resolution changes, oldest first
+		r.Method(http.MethodGet, "/{reportID}/timeline", Handler(api.GetReportTimeline))
+		// "Still there?" answer; confirmations keep the report up longer, denials expire it early
+		// Request Body: { "still_there": true }
+		r.Method(http.MethodPost, "/{reportID}/confirmation", Handler(api.ConfirmReport))
+		// Request Body (POST): {} to dismiss, { "snooze_minutes": 30 } to snooze
+		r.Method(http.MethodPost, "/{reportID}/dismissal", Handler(api.DismissReport))
+		r.Method(http.MethodDelete, "/{reportID}/dismissal", Handler(api.UndoReportDismissal))
 	})
 
 	return mux
@@ -48,7 +82,7 @@ type EnhancedCreateReportRequest struct {
 	// Road snapping options
 	EnableRoadSnapping bool   `json:"enable_road_snapping,omitempty"` // Default: true
 	OppositeSide       bool   `json:"opposite_side,omitempty"`        // Place on opposite side of road
-	Direction          string `json:"direction,omitempty"`            // BOTH_SIDES, MY_SIDE, OPPOSITE_SIDE
+	Direction          string `json:"direction,omitempty" validate:"omitempty,oneof=BOTH_SIDES MY_SIDE OPPOSITE_SIDE"`
 }
 
 func (api *API) CreateReport(_ http.ResponseWriter, r *http.Request) *ServerResponse {
@@ -63,51 +97,78 @@ func (api *API) CreateReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	userId, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
+	req.Latitude, req.Longitude, err = util.NormalizeCoordinate(req.Latitude, req.Longitude)
+	if err != nil {
+		return respondWithError(err, "invalid latitude or longitude", values.BadRequestBody, &tc)
+	}
+
 	req.UserID = userId
-	req.ExpiresAt = time.Now().Add(time.Hour * 6) // Default expiry time is 6 hours
+	req.ExpiresAt = time.Now().Add(api.ReportTTLFor(r.Context(), req.Type, req.Subtype))
+
+	api.runPlausibilityCheck(userId.String(), r.Header.Get(values.HeaderDeviceID), "report", util.LocationSample{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		At:        time.Now(),
+	})
 
 	// Apply road snapping to report location (enabled by default)
 	originalLat := req.Latitude
 	originalLng := req.Longitude
 	snapApplied := false
+	snapProvider := ""
 
 	if req.EnableRoadSnapping == false {
 		// Explicitly disabled
-		log.Printf("📍 Road snapping disabled for %s report at %.6f,%.6f", req.Type, req.Latitude, req.Longitude)
+		api.logger().DebugContext(r.Context(), "Road snapping disabled", "report_type", req.Type, "lat", req.Latitude, "lng", req.Longitude)
 	} else {
 		// Apply road snapping (default behavior)
-		snappedLat, snappedLng, err := api.snapReportToRoad(r.Context(), req.Latitude, req.Longitude, req.Type, req.OppositeSide || req.Direction == "OPPOSITE_SIDE")
+		snappedLat, snappedLng, provider, err := api.snapReportToRoad(r.Context(), req.Latitude, req.Longitude, req.Type, req.OppositeSide || req.Direction == "OPPOSITE_SIDE")
 		if err != nil {
-			log.Printf("⚠️ Road snapping failed for %s report: %v. Using original coordinates.", req.Type, err)
+			api.logger().WarnContext(r.Context(), "Road snapping failed, using original coordinates", "report_type", req.Type, "err", err)
 		} else {
 			req.Latitude = snappedLat
 			req.Longitude = snappedLng
 			snapApplied = true
+			snapProvider = provider
 
-			log.Printf("✅ %s report location snapped: %.6f,%.6f -> %.6f,%.6f",
-				req.Type, originalLat, originalLng, req.Latitude, req.Longitude)
+			api.logger().DebugContext(r.Context(), "Report location snapped", "report_type", req.Type,
+				"from_lat", originalLat, "from_lng", originalLng, "lat", req.Latitude, "lng", req.Longitude, "provider", provider)
 		}
 	}
 
-	// // Handle image upload if provided
-	// if req.ImageURL != "" {
-	// 	imageURL, err := api.Deps.Cloudinary.UploadImage(r.Context(), req.ImageURL, "reports")
-	// 	if err != nil {
-	// 		return respondWithError(err, "failed to upload image", values.Error, &tc)
-	// 	}
-	// 	req.ImageURL = imageURL
-	// }
+	// Images are uploaded beforehand through POST /uploads; a held one only reaches the
+	// report once a moderator approves it.
+	var upload *reportUpload
+	if req.ImageURL != nil && *req.ImageURL != "" {
+		u, status, message, err := api.reportUploadFor(r.Context(), userId, *req.ImageURL)
+		if err != nil {
+			return respondWithError(err, message, status, &tc)
+		}
+		upload = &u
+		if u.Verdict.Decision != ImageDecisionApproved {
+			req.ImageURL = nil
+		}
+	}
 
 	newReport, status, message, err := api.CreateReportHelper(r.Context(), req.CreateReportRequest)
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
+	if upload != nil && !newReport.Duplicate {
+		api.attachReportUpload(r.Context(), *upload, newReport.ID)
+		if upload.Verdict.Decision == ImageDecisionHeld {
+			message = "Report created; image is pending review"
+		}
+	}
 
 	// Add snapping metadata to response
 	responseData := struct {
@@ -118,6 +179,7 @@ func (api *API) CreateReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 			OriginalLng  float64 `json:"original_lng,omitempty"`
 			SnapDistance float64 `json:"snap_distance,omitempty"`
 			SnapType     string  `json:"snap_type,omitempty"`
+			Provider     string  `json:"provider,omitempty"`
 			OppositeSide bool    `json:"opposite_side,omitempty"`
 		} `json:"road_snapping,omitempty"`
 	}{
@@ -134,6 +196,7 @@ func (api *API) CreateReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 			OriginalLng  float64 `json:"original_lng,omitempty"`
 			SnapDistance float64 `json:"snap_distance,omitempty"`
 			SnapType     string  `json:"snap_type,omitempty"`
+			Provider     string  `json:"provider,omitempty"`
 			OppositeSide bool    `json:"opposite_side,omitempty"`
 		}{
 			Applied:      true,
@@ -141,6 +204,7 @@ func (api *API) CreateReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 			OriginalLng:  originalLng,
 			SnapDistance: snapDistance,
 			SnapType:     "road",
+			Provider:     snapProvider,
 			OppositeSide: req.OppositeSide || req.Direction == "OPPOSITE_SIDE",
 		}
 	}
@@ -175,52 +239,77 @@ func (api *API) createReportMultipart(r *http.Request, tc *tracing.Context) *Ser
 	if err != nil {
 		return respondWithError(err, "type, latitude, longitude required", values.BadRequestBody, tc)
 	}
+	latitude, longitude, err = util.NormalizeCoordinate(latitude, longitude)
+	if err != nil {
+		return respondWithError(err, "invalid latitude or longitude", values.BadRequestBody, tc)
+	}
 
 	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, tc)
 	}
 
-	var imageURL *string
+	// Images are moderated before upload; held and rejected ones never reach the public folder.
+	var imageURL, heldImageURL *string
+	var verdict *imageVerdict
+	var asset storage.ImageAsset
+	var imageInfo uploadImageInfo
 	if file, _, err := r.FormFile("image"); err == nil {
 		defer file.Close()
-		tmp, err := os.CreateTemp("", "report-*")
+		image, err := io.ReadAll(file)
 		if err != nil {
-			return respondWithError(err, "failed to create temp file for image", values.Error, tc)
+			return respondWithError(err, "failed to read image", values.Error, tc)
 		}
-		tmpPath := tmp.Name()
-		defer os.Remove(tmpPath)
-		if _, err := io.Copy(tmp, file); err != nil {
-			tmp.Close()
-			return respondWithError(err, "failed to write image", values.Error, tc)
+		if imageInfo, err = validateUploadImage(image); err != nil {
+			return respondWithError(err, "image must be a JPEG, PNG or WebP of at most 10 MB", values.BadRequestBody, tc)
 		}
-		if err := tmp.Close(); err != nil {
-			return respondWithError(err, "failed to close temp file", values.Error, tc)
+		v := api.moderateImage(r.Context(), image)
+		verdict = &v
+
+		folder := reportImageFolder
+		if v.Decision != ImageDecisionApproved {
+			folder = moderationImageFolder
 		}
-		url, err := api.Deps.Cloudinary.UploadImage(r.Context(), tmpPath, "reports")
+		asset, err = api.uploadReportImage(r.Context(), image, folder)
 		if err != nil {
-			log.Printf("Cloudinary upload failed: %v", err)
+			api.logger().ErrorContext(r.Context(), "Cloudinary upload failed", "err", err)
 			return respondWithError(err, "failed to upload image", values.Error, tc)
 		}
-		imageURL = &url
+		url := asset.URL
+
+		switch v.Decision {
+		case ImageDecisionRejected:
+			api.logImageModeration(r.Context(), userID, nil, &url, v)
+			return respondWithError(fmt.Errorf("image rejected: %s", *v.Reason), "image rejected by content moderation", values.Unprocessable, tc)
+		case ImageDecisionHeld:
+			heldImageURL = &url
+		default:
+			imageURL = &url
+		}
 	}
 
+	api.runPlausibilityCheck(userID.String(), r.Header.Get(values.HeaderDeviceID), "report", util.LocationSample{
+		Latitude:  latitude,
+		Longitude: longitude,
+		At:        time.Now(),
+	})
+
 	userStr, pendingStr := "USER", "PENDING"
 	req := model.CreateReportRequest{
 		UserID:       userID,
 		Type:         reportType,
 		Latitude:     latitude,
 		Longitude:    longitude,
-		ExpiresAt:    time.Now().Add(6 * time.Hour),
+		ExpiresAt:    time.Now().Add(api.ReportTTLFor(r.Context(), reportType, nil)),
 		ImageURL:     imageURL,
 		ReportSource: &userStr,
 		ReportStatus: &pendingStr,
 	}
 
 	// Apply road snapping (same as JSON path)
-	snappedLat, snappedLng, err := api.snapReportToRoad(r.Context(), req.Latitude, req.Longitude, req.Type, false)
+	snappedLat, snappedLng, _, err := api.snapReportToRoad(r.Context(), req.Latitude, req.Longitude, req.Type, false)
 	if err != nil {
-		log.Printf("⚠️ Road snapping failed for %s report: %v. Using original coordinates.", req.Type, err)
+		api.logger().WarnContext(r.Context(), "Road snapping failed, using original coordinates", "report_type", req.Type, "err", err)
 	} else {
 		req.Latitude = snappedLat
 		req.Longitude = snappedLng
@@ -230,16 +319,32 @@ func (api *API) createReportMultipart(r *http.Request, tc *tracing.Context) *Ser
 	if err != nil {
 		return respondWithError(err, message, status, tc)
 	}
+	if verdict != nil {
+		logged := imageURL
+		if verdict.Decision == ImageDecisionHeld {
+			logged = heldImageURL
+			message = "Report created; image is pending review"
+		}
+		api.logImageModeration(r.Context(), userID, &newReport.ID, logged, *verdict)
+		reportID := &newReport.ID
+		if newReport.Duplicate {
+			reportID = nil // nothing shows the image, so the cleaner removes it
+		}
+		if _, err := api.recordUpload(r.Context(), userID, reportID, asset, imageInfo, *verdict); err != nil {
+			api.logger().WarnContext(r.Context(), "Failed to record report image upload", "report_id", newReport.ID, "err", err)
+		}
+	}
 	return &ServerResponse{
 		Message:    message,
 		Status:     status,
-		StatusCode: http.StatusCreated,
+		StatusCode: util.StatusCode(status),
 		Data:       newReport,
 	}
 }
 
-// snapReportToRoad uses Map Matching API to snap report location to nearest road
-func (api *API) snapReportToRoad(ctx context.Context, lat, lng float64, reportType string, oppositeSide bool) (float64, float64, error) {
+// snapReportToRoad snaps a report location to the nearest road, within a radius that
+// depends on the report type, and reports which provider snapped it.
+func (api *API) snapReportToRoad(ctx context.Context, lat, lng float64, reportType string, oppositeSide bool) (float64, float64, string, error) {
 	// Set snap radius based on report type (normalize for switch)
 	snapRadius := 25
 	switch reportType {
@@ -255,6 +360,23 @@ func (api *API) snapReportToRoad(ctx context.Context, lat, lng float64, reportTy
 		// HAZARD, ROAD_CLOSED, etc. use default 25m
 	}
 
+	snappedLat, snappedLng, provider, err := api.snapToRoad(ctx, lat, lng, snapRadius)
+	if err != nil {
+		return lat, lng, "", err
+	}
+
+	// Apply opposite side offset if requested
+	if oppositeSide {
+		// Simple perpendicular offset of ~15 meters
+		offsetDistance := 15.0 / 111111.0 // rough degrees per meter
+		snappedLat += offsetDistance      // This is simplified - in production you'd calculate proper perpendicular
+	}
+
+	return snappedLat, snappedLng, provider, nil
+}
+
+// snapToRoadMapbox snaps a point with the Mapbox Map Matching API.
+func (api *API) snapToRoadMapbox(ctx context.Context, lat, lng float64, snapRadius int) (float64, float64, error) {
 	// Call Map Matching API directly
 	coordinates := fmt.Sprintf("%.6f,%.6f", lng, lat) // Mapbox expects lng,lat
 	baseURL := fmt.Sprintf("https://api.mapbox.com/matching/v5/mapbox/driving/%s", coordinates)
@@ -301,17 +423,7 @@ func (api *API) snapReportToRoad(ctx context.Context, lat, lng float64, reportTy
 		return lat, lng, fmt.Errorf("invalid tracepoint location")
 	}
 
-	snappedLng := tracepoint.Location[0]
-	snappedLat := tracepoint.Location[1]
-
-	// Apply opposite side offset if requested
-	if oppositeSide {
-		// Simple perpendicular offset of ~15 meters
-		offsetDistance := 15.0 / 111111.0 // rough degrees per meter
-		snappedLat += offsetDistance      // This is simplified - in production you'd calculate proper perpendicular
-	}
-
-	return snappedLat, snappedLng, nil
+	return tracepoint.Location[1], tracepoint.Location[0], nil
 }
 
 // Helper functions
@@ -339,6 +451,10 @@ func (api *API) GetReportByID(_ http.ResponseWriter, r *http.Request) *ServerRes
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
+	localizeReportLabel(&report, requestLanguage(r))
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		api.setUserVote(r.Context(), &report, userID)
+	}
 
 	return &ServerResponse{
 		Message:    message,
@@ -368,14 +484,7 @@ func (api *API) GetNearbyReports(_ http.ResponseWriter, r *http.Request) *Server
 
 	types := r.URL.Query()["type"]
 	status := r.URL.Query().Get("status")
-	page, err := strconv.Atoi(r.URL.Query().Get("page"))
-	if err != nil {
-		page = 1
-	}
-	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	if err != nil {
-		pageSize = 10
-	}
+	page := parsePageParams(r.URL.Query(), defaultNearbyReportsPageSize, maxNearbyReportsPageSize)
 
 	params := model.NearbyReportsParams{
 		Latitude:  latitude,
@@ -383,17 +492,18 @@ func (api *API) GetNearbyReports(_ http.ResponseWriter, r *http.Request) *Server
 		Radius:    radius,
 		Types:     types,
 		Status:    status,
-		Page:      page,
-		PageSize:  pageSize,
+		Page:      page.Page,
+		PageSize:  page.PageSize,
+	}
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		params.ViewerID = &userID
 	}
 
 	reports, status, message, err := api.GetNearbyReportsHelper(r.Context(), params)
 	if err != nil {
 		return respondWithError(err, message, status, &tc)
 	}
-	if len(reports) == 0 {
-		reports = []model.Report{}
-	}
+	reports.Data = localizedReports(reports.Data, requestLanguage(r))
 	return &ServerResponse{
 		Message:    message,
 		Status:     status,
@@ -402,6 +512,151 @@ func (api *API) GetNearbyReports(_ http.ResponseWriter, r *http.Request) *Server
 	}
 }
 
+func (api *API) SearchReports(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+
+	params := model.ReportSearchParams{
+		Types:  q["type"],
+		Status: q.Get("status"),
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if params.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return respondWithError(err, "from must be an RFC3339 timestamp", values.BadRequestBody, &tc)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if params.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return respondWithError(err, "to must be an RFC3339 timestamp", values.BadRequestBody, &tc)
+		}
+	}
+	if v := q.Get("bbox"); v != "" {
+		if params.BBox, err = parseBBox(v); err != nil {
+			return respondWithError(err, "bbox must be minLng,minLat,maxLng,maxLat", values.BadRequestBody, &tc)
+		}
+	}
+	if v := q.Get("reporter"); v != "" {
+		if !callerCan(r.Context(), PermViewReporters) {
+			return respondWithError(nil, "only admins can filter by reporter", values.NotAllowed, &tc)
+		}
+		reporterID, err := uuid.Parse(v)
+		if err != nil {
+			return respondWithError(err, "invalid reporter ID format", values.BadRequestBody, &tc)
+		}
+		params.ReporterID = &reporterID
+	}
+	if v := q.Get("cursor"); v != "" {
+		if params.After, err = decodeReportSearchCursor(v); err != nil {
+			return respondWithError(err, "invalid cursor", values.BadRequestBody, &tc)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil {
+			return respondWithError(err, "invalid limit", values.BadRequestBody, &tc)
+		}
+	}
+
+	result, status, message, err := api.SearchReportsHelper(r.Context(), params)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+	result.Reports = localizedReports(result.Reports, requestLanguage(r))
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       result,
+	}
+}
+
+func (api *API) GetReportsInBBox(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+
+	bbox, err := parseBBox(strings.Join([]string{q.Get("minLng"), q.Get("minLat"), q.Get("maxLng"), q.Get("maxLat")}, ","))
+	if err != nil {
+		return respondWithError(err, "minLat, minLng, maxLat and maxLng must describe a valid box", values.BadRequestBody, &tc)
+	}
+
+	params := model.ReportBBoxParams{
+		BBox:  *bbox,
+		Types: q["type"],
+	}
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		params.ViewerID = &userID
+	}
+
+	reports, status, message, err := api.GetReportsInBBoxHelper(r.Context(), params)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       localizedReports(reports, requestLanguage(r)),
+	}
+}
+
+func (api *API) GetReportClusters(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+
+	bbox, err := parseBBox(q.Get("bbox"))
+	if err != nil {
+		return respondWithError(err, "bbox must be minLng,minLat,maxLng,maxLat", values.BadRequestBody, &tc)
+	}
+	zoom, err := strconv.Atoi(q.Get("zoom"))
+	if err != nil {
+		return respondWithError(err, "zoom must be a whole number", values.BadRequestBody, &tc)
+	}
+
+	params := model.ReportClusterParams{
+		BBox:  *bbox,
+		Zoom:  zoom,
+		Types: q["type"],
+	}
+	if userID, err := util.GetUserIDFromContext(r.Context()); err == nil {
+		params.ViewerID = &userID
+	}
+
+	clusters, status, message, err := api.GetReportClustersHelper(r.Context(), params)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       clusters,
+	}
+}
+
+// parseBBox parses "minLng,minLat,maxLng,maxLat".
+func parseBBox(s string) (*model.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox needs 4 values, got %d", len(parts))
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = f
+	}
+	box := &model.BoundingBox{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	if box.MinLng >= box.MaxLng || box.MinLat >= box.MaxLat {
+		return nil, fmt.Errorf("bbox min must be below max")
+	}
+	if err := util.ValidateStruct(*box); err != nil {
+		return nil, err
+	}
+	return box, nil
+}
+
 // func (api *API) GetAllReports(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 //     tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
@@ -435,7 +690,7 @@ func (api *API) UpdateReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 
 	// Validate the request
 	if err := util.ValidateStruct(req); err != nil {
-		return respondWithError(err, "validation failed", values.BadRequestBody, &tc)
+		return respondWithValidationError(err, &tc)
 	}
 
 	userId, err := util.GetUserIDFromContext(r.Context())
@@ -500,8 +755,7 @@ func (api *API) DeleteReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 func (api *API) VoteOnReport(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
-	reportID := chi.URLParam(r, "reportID")
-	id, err := strconv.ParseInt(reportID, 10, 64)
+	id, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
 	if err != nil {
 		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
 	}
@@ -520,86 +774,82 @@ func (api *API) VoteOnReport(_ http.ResponseWriter, r *http.Request) *ServerResp
 
 	// Normalize vote type for DB (expects UPVOTE, DOWNVOTE)
 	voteType := strings.ToUpper(strings.TrimSpace(req.VoteType))
-	if voteType != "UPVOTE" && voteType != "DOWNVOTE" {
+	if voteType != values.VoteUp && voteType != values.VoteDown {
 		return respondWithError(fmt.Errorf("invalid vote_type"), "vote_type must be upvote or downvote", values.BadRequestBody, &tc)
 	}
 
-	vote := model.Vote{
-		ReportID: id,
-		UserID:   userID,
-		VoteType: voteType,
-	}
-
-	err = api.AddVoteRepo(r.Context(), vote)
+	report, status, message, err := api.VoteOnReportHelper(r.Context(), id, userID, voteType)
 	if err != nil {
-		return respondWithError(err, "failed to add vote", values.Error, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
+	return api.votedReportResponse(r, report, status, message)
+}
 
-	// Update report vote counts so response reflects new totals
-	up, down := 0, 0
-	if voteType == "UPVOTE" {
-		up = 1
-	} else {
-		down = 1
+func (api *API) RetractVote(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
 	}
-	if err := api.UpdateReportVotesRepo(r.Context(), reportID, up, down); err != nil {
-		log.Printf("warning: failed to update report vote counts: %v", err)
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	// Return updated report so app's GetReportsResponse.fromJson and data.isNotEmpty work
-	report, _, _, err := api.GetReportByIDHelper(r.Context(), reportID)
+	report, status, message, err := api.RetractVoteHelper(r.Context(), id, userID)
 	if err != nil {
-		return &ServerResponse{
-			Message:    "Vote recorded",
-			Status:     values.Success,
-			StatusCode: util.StatusCode(values.Success),
-			Data:       []model.Report{},
-		}
+		return respondWithError(err, message, status, &tc)
+	}
+	return api.votedReportResponse(r, report, status, message)
+}
+
+// votedReportResponse wraps the report in a list, which the app's GetReportsResponse
+// parsing expects; the list is empty when the report couldn't be reloaded.
+func (api *API) votedReportResponse(r *http.Request, report model.Report, status, message string) *ServerResponse {
+	data := []model.Report{}
+	if report.ID != 0 {
+		localizeReportLabel(&report, requestLanguage(r))
+		data = append(data, report)
 	}
 	return &ServerResponse{
-		Message:    "Vote recorded",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
-		Data:       []model.Report{report},
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       data,
 	}
 }
 
 func (api *API) CommentOnReport(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
-	reportID := chi.URLParam(r, "reportID")
-	id, err := util.StringToUUID(reportID)
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
 	if err != nil {
 		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
 	}
 
-	var req struct {
-		Content string `json:"content"`
-	}
+	var req model.CreateCommentRequest
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
 	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
 
 	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	comment := model.Comment{
-		ReportID: id,
-		UserID:   userID,
-		Comment:  req.Content,
-	}
-
-	err = api.AddCommentRepo(r.Context(), comment)
+	comment, status, message, err := api.CreateCommentHelper(r.Context(), reportID, userID, req)
 	if err != nil {
-		return respondWithError(err, "failed to add comment", values.Error, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Comment added successfully",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 		Data:       comment,
 	}
 }
@@ -607,28 +857,119 @@ func (api *API) CommentOnReport(_ http.ResponseWriter, r *http.Request) *ServerR
 func (api *API) GetComments(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
-	reportID := chi.URLParam(r, "reportID")
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
 
-	comments, err := api.GetCommentsRepo(r.Context(), reportID)
+	page := parsePageParams(r.URL.Query(), defaultCommentsPageSize, maxCommentsPageSize)
+	comments, status, message, err := api.GetCommentsHelper(r.Context(), reportID, page)
 	if err != nil {
-		return respondWithError(err, "failed to get comments", values.Error, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Comments retrieved successfully",
-		Status:     values.Success,
-		StatusCode: util.StatusCode(values.Success),
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
 		Data:       comments,
 	}
 }
 
+// commentParams reads the report and comment IDs from the path and the caller from the context.
+func commentParams(r *http.Request, tc *tracing.Context) (int64, uuid.UUID, uuid.UUID, *ServerResponse) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, respondWithError(err, "invalid report ID", values.BadRequestBody, tc)
+	}
+	commentID, err := uuid.Parse(chi.URLParam(r, "commentID"))
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, respondWithError(err, "invalid comment ID", values.BadRequestBody, tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return 0, uuid.Nil, uuid.Nil, respondWithError(err, "unable to get user ID from context", values.NotAuthorised, tc)
+	}
+	return reportID, commentID, userID, nil
+}
+
+func (api *API) UpdateComment(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, commentID, userID, resp := commentParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	var req model.UpdateCommentRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	comment, status, message, err := api.UpdateCommentHelper(r.Context(), reportID, commentID, userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       comment,
+	}
+}
+
+func (api *API) DeleteComment(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, commentID, userID, resp := commentParams(r, &tc)
+	if resp != nil {
+		return resp
+	}
+
+	status, message, err := api.DeleteCommentHelper(r.Context(), reportID, commentID, userID, util.GetUserRoleFromContext(r.Context()))
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) GetReportTimeline(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
+
+	timeline, status, message, err := api.GetReportTimelineHelper(r.Context(), reportID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       timeline,
+	}
+}
+
 func (api *API) GetVotes(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 	reportID := chi.URLParam(r, "reportID")
 
 	votes, err := api.GetVotesRepo(r.Context(), reportID)
 	if err != nil {
-		log.Println("error getting votes", err)
+		api.logger().ErrorContext(r.Context(), "Failed to get votes", "err", err)
 		return respondWithError(err, "failed to get votes", values.Error, &tc)
 	}
 
@@ -639,3 +980,114 @@ func (api *API) GetVotes(_ http.ResponseWriter, r *http.Request) *ServerResponse
 		Data:       votes,
 	}
 }
+
+func (api *API) ConfirmReport(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.ConfirmReportRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	confirmation, status, message, err := api.ConfirmReportHelper(r.Context(), userID, reportID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       confirmation,
+	}
+}
+
+func (api *API) DismissReport(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.DismissReportRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	dismissal, status, message, err := api.DismissReportHelper(r.Context(), userID, reportID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       dismissal,
+	}
+}
+
+func (api *API) UndoReportDismissal(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "reportID"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid report ID", values.BadRequestBody, &tc)
+	}
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	status, message, err := api.UndoReportDismissalHelper(r.Context(), userID, reportID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+func (api *API) ListReportDismissals(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	dismissals, status, message, err := api.ListReportDismissalsHelper(r.Context(), userID)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       dismissals,
+	}
+}
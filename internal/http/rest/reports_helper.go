@@ -2,87 +2,116 @@ package rest
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util/values"
-	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
 )
 
+// defaultReportSeverity matches the COALESCE default in CreateReportRepo.
+const defaultReportSeverity = 4
+
 func (api *API) CreateReportHelper(ctx context.Context, report model.CreateReportRequest) (model.CreateReportResponse, string, string, error) {
-	newReport, err := api.CreateReportRepo(ctx, report)
+	if (report.ReportStatus == nil || *report.ReportStatus == ReportStatusPending) && api.reporterIsTrusted(ctx, report.UserID) {
+		verified := ReportStatusVerified
+		report.ReportStatus = &verified
+	}
+	if status, message, err := api.prepareClosureSegment(ctx, &report); err != nil {
+		return model.CreateReportResponse{}, status, message, err
+	}
+	newReport, err := api.CreateReportRepo(ctx, report, api.reportDedupRuleFor(report.Type))
 	if err != nil {
 		return model.CreateReportResponse{}, values.Error, "Failed to create report", err
 	}
+	if newReport.Duplicate {
+		api.reportTiles.invalidateReport(newReport.ID)
+		return newReport, values.Success, "Matched an existing report nearby", nil
+	}
 
-	// Broadcast a WebSocket report_update to nearby users
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("panic in CreateReportHelper websocket broadcast: %v", r)
-			}
-		}()
-
-		payload := websockets.ReportUpdatePayload{
-			ID:             newReport.ID,
-			UserID:         newReport.UserID.String(),
-			Type:           newReport.Type,
-			Latitude:       newReport.Latitude,
-			Longitude:      newReport.Longitude,
-			Active:         newReport.Active,
-			Resolved:       newReport.Resolved,
-			UpvotesCount:   newReport.UpvotesCount,
-			DownvotesCount: newReport.DownvotesCount,
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			log.Printf("failed to marshal ReportUpdatePayload: %v", err)
-			return
-		}
-
-		msg := websockets.Message{
-			Type:    websockets.MsgTypeReportUpdate,
-			UserID:  newReport.UserID.String(),
-			Content: string(b),
-		}
-
-		raw, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("failed to marshal websocket Message: %v", err)
-			return
-		}
-
-		// 5km radius for now; can be tuned later
-		api.Deps.WebSocket.BroadcastReportUpdate(
-			raw,
-			newReport.Latitude,
-			newReport.Longitude,
-			5000,
-		)
-	}()
+	// The report_update broadcast is published from the outbox so it survives a crash here.
+	api.NotifyOutbox()
+	api.reportTiles.invalidatePoint(newReport.Latitude, newReport.Longitude)
+	go api.labelReportLocation(newReport.ID, newReport.Latitude, newReport.Longitude)
 
 	return newReport, values.Created, "Report created successfully", nil
 }
 
 func (api *API) GetReportByIDHelper(ctx context.Context, reportID string) (model.Report, string, string, error) {
+	key := "report:" + reportID
+	if !api.dbAvailable() {
+		return api.cachedReport(key, errDBUnavailable)
+	}
+
 	report, err := api.GetReportByIDRepo(ctx, reportID)
 	if err != nil {
-		if err == ErrReportNotFound {
+		if errors.Is(err, ErrReportNotFound) {
 			return model.Report{}, values.NotFound, "Report not found", err
 		}
-		return model.Report{}, values.Error, "Failed to fetch report", err
+		return api.cachedReport(key, err)
 	}
+	api.reportReadCache.put(key, report)
 	return report, values.Success, "Report fetched successfully", nil
 }
 
-func (api *API) GetNearbyReportsHelper(ctx context.Context, params model.NearbyReportsParams) ([]model.Report, string, string, error) {
-	reports, err := api.GetNearbyReportsRepo(ctx, params)
+const (
+	defaultNearbyReportsPageSize = 10
+	maxNearbyReportsPageSize     = 100
+)
+
+func (api *API) GetNearbyReportsHelper(ctx context.Context, params model.NearbyReportsParams) (model.Page[model.Report], string, string, error) {
+	key := nearbyReportsCacheKey(params)
+	if !api.dbAvailable() {
+		return api.cachedNearbyReports(key, errDBUnavailable)
+	}
+
+	reports, total, err := api.GetNearbyReportsRepo(ctx, params)
 	if err != nil {
-		return nil, values.Error, "Failed to fetch nearby reports", err
+		return api.cachedNearbyReports(key, err)
 	}
-	return reports, values.Success, "Nearby reports fetched successfully", nil
+	page := offsetPage(reports, pageParams{Page: params.Page, PageSize: params.PageSize}, total)
+	api.reportReadCache.put(key, page)
+	return page, values.Success, "Nearby reports fetched successfully", nil
+}
+
+// cachedReport serves a report from the read cache after the database failed with err.
+func (api *API) cachedReport(key string, err error) (model.Report, string, string, error) {
+	if v, ok := api.reportReadCache.get(key); ok {
+		return v.(model.Report), values.Success, "Report fetched from cache (database unavailable)", nil
+	}
+	if errors.Is(err, errDBUnavailable) {
+		return model.Report{}, values.Unavailable, "Reports are temporarily unavailable, please retry", err
+	}
+	return model.Report{}, values.Error, "Failed to fetch report", err
+}
+
+// cachedNearbyReports serves nearby reports from the read cache after the database failed with err.
+func (api *API) cachedNearbyReports(key string, err error) (model.Page[model.Report], string, string, error) {
+	if v, ok := api.reportReadCache.get(key); ok {
+		return v.(model.Page[model.Report]), values.Success, "Nearby reports fetched from cache (database unavailable)", nil
+	}
+	if errors.Is(err, errDBUnavailable) {
+		return model.Page[model.Report]{}, values.Unavailable, "Reports are temporarily unavailable, please retry", err
+	}
+	return model.Page[model.Report]{}, values.Error, "Failed to fetch nearby reports", err
+}
+
+// nearbyReportsCacheKey rounds the centre to ~100m so nearby requests share cache entries.
+func nearbyReportsCacheKey(p model.NearbyReportsParams) string {
+	key := fmt.Sprintf("nearby:%.3f:%.3f:%.0f:%s:%s:%d:%d",
+		p.Latitude, p.Longitude, p.Radius, strings.Join(p.Types, ","), p.Status, p.Page, p.PageSize)
+	// Results exclude the viewer's dismissals, so they can't be shared between users.
+	if p.ViewerID != nil {
+		key += ":" + p.ViewerID.String()
+	}
+	return key
 }
 
 // func (api *API) GetAllReportsHelper(ctx context.Context) ([]model.Report, string, string, error) {
@@ -96,21 +125,322 @@ func (api *API) GetNearbyReportsHelper(ctx context.Context, params model.NearbyR
 func (api *API) UpdateReportHelper(ctx context.Context, report model.Report) (string, string, error) {
 	err := api.UpdateReportRepo(ctx, report)
 	if err != nil {
-		if err == ErrUpdateFailed {
+		if errors.Is(err, ErrUpdateFailed) {
 			return values.NotFound, "Report not found", err
 		}
 		return values.Error, "Failed to update report", err
 	}
+	api.reportTiles.invalidateReport(report.ID)
+	api.reportTiles.invalidatePoint(report.Latitude, report.Longitude)
 	return values.Success, "Report updated successfully", nil
 }
 
 func (api *API) DeleteReportHelper(ctx context.Context, id string, userID string) (string, string, error) {
 	err := api.DeleteReportRepo(ctx, id, userID)
 	if err != nil {
-		if err == ErrDeleteFailed {
+		if errors.Is(err, ErrDeleteFailed) {
 			return values.NotFound, "Report not found", err
 		}
 		return values.Error, "Failed to delete report", err
 	}
+	if reportID, err := strconv.ParseInt(id, 10, 64); err == nil {
+		api.reportTiles.invalidateReport(reportID)
+	}
 	return values.Success, "Report deleted successfully", nil
 }
+
+// DismissReportHelper dismisses a report for the user, or snoozes it for SnoozeMinutes.
+func (api *API) DismissReportHelper(ctx context.Context, userID uuid.UUID, reportID int64, req model.DismissReportRequest) (model.ReportDismissal, string, string, error) {
+	var snoozedUntil *time.Time
+	message := "Report dismissed"
+	if req.SnoozeMinutes > 0 {
+		t := time.Now().Add(time.Duration(req.SnoozeMinutes) * time.Minute)
+		snoozedUntil = &t
+		message = "Report snoozed"
+	}
+
+	dismissal, err := api.DismissReportRepo(ctx, userID, reportID, snoozedUntil)
+	if err != nil {
+		if errors.Is(err, ErrReportNotFound) {
+			return model.ReportDismissal{}, values.NotFound, "Report not found", err
+		}
+		return model.ReportDismissal{}, values.Error, "Failed to dismiss report", err
+	}
+	return dismissal, values.Success, message, nil
+}
+
+// VoteOnReportHelper sets the user's vote on a report, switching any earlier vote, and
+// returns the report with its new counts and the user's vote.
+func (api *API) VoteOnReportHelper(ctx context.Context, reportID int64, userID uuid.UUID, voteType string) (model.Report, string, string, error) {
+	previous, err := api.SetVoteRepo(ctx, reportID, userID, voteType)
+	if err != nil {
+		if errors.Is(err, ErrReportNotFound) {
+			return model.Report{}, values.NotFound, "Report not found", err
+		}
+		return model.Report{}, values.Error, "Failed to record vote", err
+	}
+	if voteType == values.VoteUp && previous != values.VoteUp {
+		api.awardReportUpvote(ctx, reportID, userID)
+	}
+	message := "Vote recorded"
+	if previous != "" && previous != voteType {
+		message = "Vote changed"
+	}
+	return api.votedReport(ctx, reportID, voteType, message)
+}
+
+// RetractVoteHelper removes the user's vote on a report and returns the report with its
+// new counts.
+func (api *API) RetractVoteHelper(ctx context.Context, reportID int64, userID uuid.UUID) (model.Report, string, string, error) {
+	if _, err := api.RetractVoteRepo(ctx, reportID, userID); err != nil {
+		if errors.Is(err, ErrVoteNotFound) {
+			return model.Report{}, values.NotFound, "You haven't voted on this report", err
+		}
+		return model.Report{}, values.Error, "Failed to retract vote", err
+	}
+	return api.votedReport(ctx, reportID, "", "Vote retracted")
+}
+
+// setUserVote fills in the user's vote on the report. It is left out while the database
+// is down or the lookup fails.
+func (api *API) setUserVote(ctx context.Context, report *model.Report, userID uuid.UUID) {
+	if !api.dbAvailable() {
+		return
+	}
+	voteType, err := api.GetUserVoteRepo(ctx, report.ID, userID)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to look up user's vote", "report_id", report.ID, "err", err)
+		return
+	}
+	if voteType != "" {
+		report.UserVote = &voteType
+	}
+}
+
+// votedReport reloads a report after a vote. The vote is already in, so a failed reload
+// is only logged and the report left empty.
+func (api *API) votedReport(ctx context.Context, reportID int64, voteType, message string) (model.Report, string, string, error) {
+	report, err := api.GetReportByIDRepo(ctx, strconv.FormatInt(reportID, 10))
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to reload report after vote", "report_id", reportID, "err", err)
+		return model.Report{}, values.Success, message, nil
+	}
+	if voteType != "" {
+		report.UserVote = &voteType
+	}
+	return report, values.Success, message, nil
+}
+
+func (api *API) UndoReportDismissalHelper(ctx context.Context, userID uuid.UUID, reportID int64) (string, string, error) {
+	if err := api.DeleteReportDismissalRepo(ctx, userID, reportID); err != nil {
+		if errors.Is(err, ErrReportDismissalNotFound) {
+			return values.NotFound, "Report is not dismissed", err
+		}
+		return values.Error, "Failed to restore report", err
+	}
+	return values.Success, "Report restored", nil
+}
+
+func (api *API) ListReportDismissalsHelper(ctx context.Context, userID uuid.UUID) ([]model.ReportDismissal, string, string, error) {
+	dismissals, err := api.ListReportDismissalsRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch dismissed reports", err
+	}
+	if dismissals == nil {
+		dismissals = []model.ReportDismissal{}
+	}
+	return dismissals, values.Success, "Dismissed reports fetched successfully", nil
+}
+
+const (
+	reportSearchDefaultWindow = 24 * time.Hour
+	reportSearchMaxWindow     = 31 * 24 * time.Hour
+	reportSearchDefaultLimit  = 50
+	reportSearchMaxLimit      = 500
+)
+
+// SearchReportsHelper returns one page of reports in the time window. It fetches one extra
+// row to tell whether another page follows.
+func (api *API) SearchReportsHelper(ctx context.Context, params model.ReportSearchParams) (model.ReportSearchResult, string, string, error) {
+	if params.To.IsZero() {
+		params.To = time.Now()
+	}
+	if params.From.IsZero() {
+		params.From = params.To.Add(-reportSearchDefaultWindow)
+	}
+	if !params.From.Before(params.To) {
+		return model.ReportSearchResult{}, values.BadRequestBody, "from must be before to", fmt.Errorf("empty time window")
+	}
+	if params.To.Sub(params.From) > reportSearchMaxWindow {
+		return model.ReportSearchResult{}, values.BadRequestBody, "time window can be at most 31 days", fmt.Errorf("time window too large")
+	}
+	if params.Limit <= 0 {
+		params.Limit = reportSearchDefaultLimit
+	}
+	if params.Limit > reportSearchMaxLimit {
+		params.Limit = reportSearchMaxLimit
+	}
+
+	limit := params.Limit
+	params.Limit++
+	reports, err := api.SearchReportsRepo(ctx, params)
+	if err != nil {
+		return model.ReportSearchResult{}, values.Error, "Failed to search reports", err
+	}
+
+	result := model.ReportSearchResult{Reports: reports}
+	if len(reports) > limit {
+		result.Reports = reports[:limit]
+		last := result.Reports[limit-1]
+		result.NextCursor = encodeReportSearchCursor(model.ReportSearchCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	if result.Reports == nil {
+		result.Reports = []model.Report{}
+	}
+	return result, values.Success, "Reports fetched successfully", nil
+}
+
+const (
+	reportClusterMaxZoom = 22
+	reportClusterLimit   = 500
+)
+
+// reportClusterPrecision picks the geohash length for a map zoom level: one character per
+// two zoom levels keeps a cell around a hundred pixels wide on screen. It tops out at the
+// precision stored on reports (~150m cells from zoom 14).
+func reportClusterPrecision(zoom int) int {
+	return max(1, min(zoom/2, ReportGeohashPrecision))
+}
+
+// GetReportClustersHelper clusters the active reports in a map viewport for rendering at
+// low zoom, where individual pins would overwhelm the client.
+func (api *API) GetReportClustersHelper(ctx context.Context, params model.ReportClusterParams) (model.ReportClusters, string, string, error) {
+	if params.Zoom < 0 || params.Zoom > reportClusterMaxZoom {
+		return model.ReportClusters{}, values.BadRequestBody, fmt.Sprintf("zoom must be between 0 and %d", reportClusterMaxZoom), fmt.Errorf("zoom %d out of range", params.Zoom)
+	}
+	params.Precision = reportClusterPrecision(params.Zoom)
+	params.Limit = reportClusterLimit + 1
+
+	clusters, err := api.GetReportClustersRepo(ctx, params)
+	if err != nil {
+		return model.ReportClusters{}, values.Error, "Failed to cluster reports", err
+	}
+
+	result := model.ReportClusters{Zoom: params.Zoom, Precision: params.Precision, Clusters: clusters}
+	if len(clusters) > reportClusterLimit {
+		result.Clusters = clusters[:reportClusterLimit]
+		result.Truncated = true
+	}
+	return result, values.Success, "Report clusters fetched successfully", nil
+}
+
+// reportBBoxMaxTiles bounds a bbox request to about a city's worth of tiles; wider
+// viewports should use /reports/clusters.
+const reportBBoxMaxTiles = 64
+
+// GetReportsInBBoxHelper returns the active reports in a viewport, newest first. Tiles
+// come from reportTiles when another request loaded them recently, so clients panning
+// over the same area share the PostGIS work.
+func (api *API) GetReportsInBBoxHelper(ctx context.Context, params model.ReportBBoxParams) ([]model.Report, string, string, error) {
+	tiles := reportTilesIn(params.BBox)
+	if len(tiles) > reportBBoxMaxTiles {
+		return nil, values.BadRequestBody, "Area too large; zoom in or use /reports/clusters", fmt.Errorf("bbox spans %d tiles", len(tiles))
+	}
+
+	var inArea []model.Report
+	for _, tile := range tiles {
+		reports, ok := api.reportTiles.get(tile)
+		if !ok {
+			if !api.dbAvailable() {
+				return nil, values.Unavailable, "Reports are temporarily unavailable, please retry", errDBUnavailable
+			}
+			var err error
+			reports, err = api.GetActiveReportsInBBoxRepo(ctx, tile.bounds(), reportTileMaxReports)
+			if err != nil {
+				return nil, values.Error, "Failed to fetch reports", err
+			}
+			api.reportTiles.put(tile, reports)
+		}
+		inArea = append(inArea, reports...)
+	}
+
+	var dismissed map[int64]bool
+	if params.ViewerID != nil {
+		dismissals, err := api.ListReportDismissalsRepo(ctx, *params.ViewerID)
+		if err != nil {
+			return nil, values.Error, "Failed to fetch reports", err
+		}
+		dismissed = make(map[int64]bool, len(dismissals))
+		for _, d := range dismissals {
+			dismissed[d.ReportID] = true
+		}
+	}
+
+	now := time.Now()
+	box := params.BBox
+	reports := []model.Report{}
+	for _, r := range inArea {
+		switch {
+		case r.Latitude < box.MinLat || r.Latitude > box.MaxLat || r.Longitude < box.MinLng || r.Longitude > box.MaxLng:
+		case !r.ExpiresAt.After(now):
+		case len(params.Types) > 0 && !slices.Contains(params.Types, r.Type):
+		case dismissed[r.ID]:
+		default:
+			reports = append(reports, r)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].CreatedAt.After(reports[j].CreatedAt)
+	})
+	return reports, values.Success, "Reports fetched successfully", nil
+}
+
+// Search cursors are opaque to clients: base64 of "<created_at unix nanos>:<id>".
+func encodeReportSearchCursor(c model.ReportSearchCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)))
+}
+
+func decodeReportSearchCursor(s string) (*model.ReportSearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	c := model.ReportSearchCursor{CreatedAt: time.Unix(0, n)}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// reportTimelineMaxEvents caps the timeline; the newest events are kept.
+const reportTimelineMaxEvents = 500
+
+func (api *API) GetReportTimelineHelper(ctx context.Context, reportID int64) (model.ReportTimeline, string, string, error) {
+	events, err := api.GetReportTimelineRepo(ctx, reportID, reportTimelineMaxEvents+1)
+	if errors.Is(err, ErrReportNotFound) {
+		return model.ReportTimeline{}, values.NotFound, "Report not found", err
+	}
+	if err != nil {
+		return model.ReportTimeline{}, values.Error, "Failed to fetch report timeline", err
+	}
+
+	timeline := model.ReportTimeline{ReportID: reportID}
+	if len(events) > reportTimelineMaxEvents {
+		events = events[:reportTimelineMaxEvents]
+		timeline.Truncated = true
+	}
+	// The repo returns newest first so truncation drops the oldest; clients get oldest first.
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	timeline.Events = events
+	return timeline, values.Success, "Report timeline fetched", nil
+}
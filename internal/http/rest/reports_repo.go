@@ -2,11 +2,16 @@ package rest
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"strconv"
+	"time"
 
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
 	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -16,44 +21,163 @@ type ReportRepo struct {
 }
 
 var (
-	ErrReportNotFound = errors.New("report not found")
+	ErrReportNotFound = fmt.Errorf("report %w", ErrNotFound)
 	ErrUpdateFailed   = errors.New("failed to update report")
 	ErrDeleteFailed   = errors.New("failed to delete report")
 )
 
-// Create inserts a new report
-func (api *API) CreateReportRepo(ctx context.Context, report model.CreateReportRequest) (model.CreateReportResponse, error) {
+// ReportGeohashPrecision is the geohash length stored on reports (~150m cells).
+const ReportGeohashPrecision = 7
+
+// createdReportColumns is what creating a report returns.
+const createdReportColumns = `
+    id, user_id, type, ST_X(position) as longitude, ST_Y(position) as latitude, created_at, updated_at, verified_count, active,
+    resolved, report_source, report_status, expires_at, comments_count, upvotes_count, downvotes_count,
+    ST_AsGeoJSON(closure_segment), closure_direction`
+
+func scanCreatedReport(row pgx.Row, out *model.CreateReportResponse) error {
+	var segment, direction *string
+	err := row.Scan(
+		&out.ID, &out.UserID, &out.Type, &out.Longitude, &out.Latitude, &out.CreatedAt, &out.UpdatedAt, &out.VerifiedCount,
+		&out.Active, &out.Resolved, &out.ReportSource, &out.ReportStatus, &out.ExpiresAt, &out.CommentsCount,
+		&out.UpvotesCount, &out.DownvotesCount, &segment, &direction,
+	)
+	if err != nil || segment == nil {
+		return err
+	}
+	var line struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal([]byte(*segment), &line); err != nil {
+		return fmt.Errorf("decoding closure segment: %w", err)
+	}
+	out.ClosureSegment = line.Coordinates
+	if direction != nil {
+		out.ClosureDirection = *direction
+	}
+	return nil
+}
+
+// closureSegmentGeoJSON encodes a closure segment as a GeoJSON LineString, or "" when
+// there is none.
+func closureSegmentGeoJSON(segment [][]float64) (string, error) {
+	if len(segment) == 0 {
+		return "", nil
+	}
+	return lineStringGeoJSON(segment)
+}
+
+// Create inserts a new report. Without an expiry it gets the configured TTL for its type.
+// If dedup is enabled and an active report of the same type matches it, nothing is
+// inserted: the existing report gains a verification (unless it is the reporter's own)
+// and is returned with Duplicate set.
+func (api *API) CreateReportRepo(ctx context.Context, report model.CreateReportRequest, dedup reportDedupRule) (model.CreateReportResponse, error) {
+	if report.ExpiresAt.IsZero() {
+		report.ExpiresAt = time.Now().Add(api.ReportTTLFor(ctx, report.Type, report.Subtype))
+	}
 	query := `
         INSERT INTO reports (
             user_id, type, subtype, position, description, severity,
-            expires_at, image_url, report_source, report_status
+            expires_at, image_url, report_source, report_status, geohash,
+            closure_segment, closure_direction
         ) VALUES (
             $1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326), $6,
             COALESCE($7, 4), -- default severity
-            COALESCE($8, NOW() + INTERVAL '24 hours'), -- default expiration
+            $8,
             $9,
             COALESCE($10, 'USER'), -- default report_source
-            COALESCE($11, 'PENDING') -- default report_status
-        ) RETURNING id, user_id, type, ST_X(position) as longitude, ST_Y(position) as latitude, created_at, updated_at, verified_count, active,
-            resolved, report_source, report_status, expires_at, comments_count, upvotes_count, downvotes_count
-    `
+            COALESCE($11, 'PENDING'), -- default report_status
+            ST_GeoHash(ST_SetSRID(ST_MakePoint($4, $5), 4326), $12),
+            CASE WHEN $13::text = '' THEN NULL ELSE ST_SetSRID(ST_GeomFromGeoJSON($13::text), 4326) END,
+            $14
+        ) RETURNING ` + createdReportColumns
+	segment, err := closureSegmentGeoJSON(report.ClosureSegment)
+	if err != nil {
+		return model.CreateReportResponse{}, err
+	}
 	var newReport model.CreateReportResponse
-	err := api.DB.QueryRow(ctx, query,
-		report.UserID, report.Type, report.Subtype, report.Longitude, report.Latitude,
-		report.Description, report.Severity, report.ExpiresAt, report.ImageURL,
-		report.ReportSource, report.ReportStatus,
-	).Scan(
-		&newReport.ID, &newReport.UserID, &newReport.Type, &newReport.Longitude, &newReport.Latitude, &newReport.CreatedAt, &newReport.UpdatedAt, &newReport.VerifiedCount,
-		&newReport.Active, &newReport.Resolved, &newReport.ReportSource, &newReport.ReportStatus, &newReport.ExpiresAt, &newReport.CommentsCount,
-		&newReport.UpvotesCount, &newReport.DownvotesCount,
-	)
+	err = api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if dedup.enabled() {
+			found, err := mergeDuplicateReport(ctx, tx, report, segment, dedup, &newReport)
+			if err != nil || found {
+				return err
+			}
+		}
+
+		err := scanCreatedReport(tx.QueryRow(ctx, query,
+			report.UserID, report.Type, report.Subtype, report.Longitude, report.Latitude,
+			report.Description, report.Severity, report.ExpiresAt, report.ImageURL,
+			report.ReportSource, report.ReportStatus, ReportGeohashPrecision, segment, report.ClosureDirection,
+		), &newReport)
+		if err != nil {
+			return err
+		}
+
+		severity := defaultReportSeverity
+		if report.Severity != nil {
+			severity = *report.Severity
+		}
+		id := strconv.FormatInt(newReport.ID, 10)
+		return insertOutboxEvent(ctx, tx, OutboxEventReportCreated, id, OutboxEventReportCreated+":"+id,
+			model.ReportCreatedEvent{Report: newReport, Severity: severity})
+	})
 	if err != nil {
-		log.Println(err)
 		return model.CreateReportResponse{}, err
 	}
 	return newReport, nil
 }
 
+// mergeDuplicateReport looks for the nearest active report of the same type within
+// rule.RadiusM created in the last rule.Window and, if there is one, counts the new report
+// as a verification of it and scans it into out. It is locked first, so concurrent
+// duplicates are each counted. A closure segment fills in one the existing report lacks.
+func mergeDuplicateReport(ctx context.Context, tx pgx.Tx, report model.CreateReportRequest, segment string, rule reportDedupRule, out *model.CreateReportResponse) (bool, error) {
+	var id int64
+	err := tx.QueryRow(ctx, `
+        SELECT id FROM reports
+        WHERE type = $1
+          AND active = true
+          AND expires_at > NOW()
+          AND created_at > NOW() - $4 * INTERVAL '1 second'
+          AND ST_DWithin(position::geography, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography, $5)
+        ORDER BY position <-> ST_SetSRID(ST_MakePoint($2, $3), 4326)
+        LIMIT 1
+        FOR UPDATE
+    `, report.Type, report.Longitude, report.Latitude, int(rule.Window.Seconds()), rule.RadiusM).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = scanCreatedReport(tx.QueryRow(ctx, `
+        UPDATE reports
+        SET verified_count = COALESCE(verified_count, 0) + CASE WHEN user_id = $2 THEN 0 ELSE 1 END,
+            closure_direction = CASE WHEN closure_segment IS NULL AND $3::text <> '' THEN $4 ELSE closure_direction END,
+            closure_segment = COALESCE(closure_segment,
+                CASE WHEN $3::text = '' THEN NULL ELSE ST_SetSRID(ST_GeomFromGeoJSON($3::text), 4326) END),
+            updated_at = NOW()
+        WHERE id = $1
+        RETURNING `+createdReportColumns, id, report.UserID, segment, report.ClosureDirection), out)
+	if err != nil {
+		return false, err
+	}
+	out.Duplicate = true
+
+	// An independent report of the same thing is as good as a "still there" answer.
+	if err := awardReportAuthor(ctx, tx, id, &report.UserID, reputationReportConfirmed, reputationConfirmedPoints); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SetReportLocationLabelsRepo stores a report's reverse geocoded labels, keyed by language.
+func (api *API) SetReportLocationLabelsRepo(ctx context.Context, id int64, labels map[string]string) error {
+	_, err := api.DB.Exec(ctx, `UPDATE reports SET location_labels = $2 WHERE id = $1`, id, labels)
+	return err
+}
+
 // GetByID retrieves a report by ID
 func (api *API) GetReportByIDRepo(ctx context.Context, id string) (model.Report, error) {
 	query := `
@@ -61,7 +185,8 @@ func (api *API) GetReportByIDRepo(ctx context.Context, id string) (model.Report,
             r.id, r.user_id, u.username, r.type, r.subtype, ST_X(r.position) as longitude,
             ST_Y(r.position) as latitude, r.description, r.severity, r.verified_count,
             r.active, r.resolved, r.created_at, r.updated_at, r.expires_at, r.image_url,
-            r.report_source, r.report_status, r.comments_count, r.upvotes_count, r.downvotes_count
+            r.report_source, r.report_status, r.comments_count, r.upvotes_count, r.downvotes_count,
+            r.location_labels
         FROM reports r
         JOIN users u ON u.id = r.user_id
         WHERE r.id = $1
@@ -73,12 +198,11 @@ func (api *API) GetReportByIDRepo(ctx context.Context, id string) (model.Report,
 		&report.VerifiedCount, &report.Active, &report.Resolved, &report.CreatedAt,
 		&report.UpdatedAt, &report.ExpiresAt, &report.ImageURL, &report.ReportSource,
 		&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
-		&report.DownvotesCount,
+		&report.DownvotesCount, &report.LocationLabels,
 	)
-	if err == pgx.ErrNoRows {
+	if errors.Is(err, pgx.ErrNoRows) {
 		return model.Report{}, ErrReportNotFound
 	}
-	log.Println(err)
 	return report, err
 }
 
@@ -124,8 +248,26 @@ func (api *API) GetReportByIDRepo(ctx context.Context, id string) (model.Report,
 // 	return reports, rows.Err()
 // }
 
-// repository/report.go
-func (api *API) GetNearbyReportsRepo(ctx context.Context, params model.NearbyReportsParams) ([]model.Report, error) {
+// nearbyReportsQuery builds the SQL and arguments for GetNearbyReportsRepo. The admin
+// explain endpoint uses it too, so both see the same query.
+func nearbyReportsQuery(params model.NearbyReportsParams) (string, []interface{}) {
+	filtered, args := nearbyReportsFilter(params)
+	query := fmt.Sprintf(`
+        %s
+        ORDER BY distance
+        LIMIT $%d OFFSET $%d
+    `, filtered, len(args)+1, len(args)+2)
+	return query, append(args, params.PageSize, (params.Page-1)*params.PageSize)
+}
+
+// nearbyReportsCountQuery counts every report nearbyReportsQuery pages through.
+func nearbyReportsCountQuery(params model.NearbyReportsParams) (string, []interface{}) {
+	filtered, args := nearbyReportsFilter(params)
+	return `SELECT COUNT(*) FROM (` + filtered + `) nearby`, args
+}
+
+// nearbyReportsFilter is the nearby reports SELECT with its filters, unordered.
+func nearbyReportsFilter(params model.NearbyReportsParams) (string, []interface{}) {
 	// Build dynamic query with optional filters
 	baseQuery := `
         SELECT
@@ -135,7 +277,7 @@ func (api *API) GetNearbyReportsRepo(ctx context.Context, params model.NearbyRep
             r.description, r.severity, r.verified_count,
             r.active, r.resolved, r.created_at, r.updated_at,
             r.expires_at, r.image_url, r.report_source, r.report_status,
-            r.comments_count, r.upvotes_count, r.downvotes_count,
+            r.comments_count, r.upvotes_count, r.downvotes_count, r.location_labels,
             ST_Distance(r.position::geography, ST_MakePoint($1, $2)::geography) as distance  -- Returns meters directly
         FROM reports r
         JOIN users u ON u.id = r.user_id
@@ -171,26 +313,33 @@ func (api *API) GetNearbyReportsRepo(ctx context.Context, params model.NearbyRep
 		args = append(args, params.Status)
 	}
 
-	// Add ordering and pagination
-	query := fmt.Sprintf(`
-        %s %s
-        ORDER BY distance
-        LIMIT $%d OFFSET $%d
-    `, baseQuery, whereClause, argCount+1, argCount+2)
+	// Hide reports the viewer has dismissed or snoozed
+	if params.ViewerID != nil {
+		argCount++
+		whereClause += " AND " + reportNotDismissedSQL(fmt.Sprintf("$%d", argCount))
+		args = append(args, *params.ViewerID)
+	}
 
-	args = append(args,
-		params.PageSize,
-		(params.Page-1)*params.PageSize,
-	)
+	return baseQuery + whereClause, args
+}
 
+// GetNearbyReportsRepo returns one page of the reports around a point, nearest first,
+// and how many there are in all.
+func (api *API) GetNearbyReportsRepo(ctx context.Context, params model.NearbyReportsParams) ([]model.Report, int, error) {
+	countQuery, countArgs := nearbyReportsCountQuery(params)
+	var total int
+	if err := api.DB.QueryRow(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting nearby reports: %w", err)
+	}
+
+	query, args := nearbyReportsQuery(params)
 	rows, err := api.DB.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("querying nearby reports: %w", err)
+		return nil, 0, fmt.Errorf("querying nearby reports: %w", err)
 	}
 	defer rows.Close()
 
-	log.Println("query", query)
-	log.Println("args", args)
+	api.logger().DebugContext(ctx, "Nearby reports query", "query", query, "args", args)
 	var reports []model.Report
 	for rows.Next() {
 		var report model.Report
@@ -203,17 +352,17 @@ func (api *API) GetNearbyReportsRepo(ctx context.Context, params model.NearbyRep
 			&report.Resolved, &report.CreatedAt, &report.UpdatedAt,
 			&report.ExpiresAt, &report.ImageURL, &report.ReportSource,
 			&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
-			&report.DownvotesCount, &distance,
+			&report.DownvotesCount, &report.LocationLabels, &distance,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("scanning report: %w", err)
+			return nil, 0, fmt.Errorf("scanning report: %w", err)
 		}
 
 		// report.Distance = distance // Add distance to report model
 		reports = append(reports, report)
 	}
 
-	return reports, nil
+	return reports, total, rows.Err()
 }
 
 // Update updates an existing report
@@ -231,6 +380,15 @@ func (api *API) UpdateReportRepo(ctx context.Context, report model.Report) error
             expires_at = $9,
             image_url = $10,
             report_status = $11,
+            -- A material change lifts users' dismissals and snoozes of the report.
+            material_version = material_version + CASE
+                WHEN type IS DISTINCT FROM $1
+                  OR subtype IS DISTINCT FROM $2
+                  OR severity IS DISTINCT FROM $6
+                  OR active IS DISTINCT FROM $7
+                  OR resolved IS DISTINCT FROM $8
+                  OR NOT ST_DWithin(position::geography, ST_SetSRID(ST_MakePoint($3, $4), 4326)::geography, 50)
+                THEN 1 ELSE 0 END,
             updated_at = NOW()
         WHERE id = $12 AND user_id = $13
         RETURNING updated_at
@@ -328,70 +486,103 @@ func (api *API) GetUserReportsRepo(ctx context.Context, userID string) ([]model.
 Votes related repo codes
 */
 
-// AddVote adds a vote to a report
-func (api *API) AddVoteRepo(ctx context.Context, vote model.Vote) error {
-	query := `
-        INSERT INTO votes (report_id, user_id, vote_type, created_at)
-        VALUES ($1, $2, $3, NOW())
-    `
-	_, err := api.DB.Exec(ctx, query, vote.ReportID, vote.UserID, vote.VoteType)
-	return err
+var ErrVoteNotFound = fmt.Errorf("vote %w", ErrNotFound)
+
+// voteCountDelta is how a report's upvote and downvote counts move when a user's vote
+// goes from previous to next, either of which may be "" for no vote.
+func voteCountDelta(previous, next string) (up, down int) {
+	count := func(vote string, sign int) {
+		switch vote {
+		case values.VoteUp:
+			up += sign
+		case values.VoteDown:
+			down += sign
+		}
+	}
+	count(previous, -1)
+	count(next, 1)
+	return up, down
 }
 
-// UpdateVotes updates the vote counts for a report
-func (api *API) UpdateReportVotesRepo(ctx context.Context, id string, upvotes, downvotes int) error {
-	query := `
+// adjustVoteCounts applies the count change of a vote going from previous to next.
+func adjustVoteCounts(ctx context.Context, tx pgx.Tx, reportID int64, previous, next string) error {
+	up, down := voteCountDelta(previous, next)
+	if up == 0 && down == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
         UPDATE reports
-        SET
-            upvotes_count = upvotes_count + $1,
-            downvotes_count = downvotes_count + $2,
+        SET upvotes_count = GREATEST(upvotes_count + $1, 0),
+            downvotes_count = GREATEST(downvotes_count + $2, 0),
             updated_at = NOW()
         WHERE id = $3
-    `
-	result, err := api.DB.Exec(ctx, query, upvotes, downvotes, id)
-	if err != nil {
-		return err
-	}
-	if result.RowsAffected() == 0 {
-		return ErrUpdateFailed
-	}
-	return nil
-}
-
-// AddComment adds a comment to a report
-func (api *API) AddCommentRepo(ctx context.Context, comment model.Comment) error {
-	query := `
-        INSERT INTO comments (report_id, user_id, content, created_at)
-        VALUES ($1, $2, $3, NOW())
-    `
-	_, err := api.DB.Exec(ctx, query, comment.ReportID, comment.UserID, comment.Comment)
+    `, up, down, reportID)
 	return err
 }
 
-// GetComments retrieves comments for a report
-func (api *API) GetCommentsRepo(ctx context.Context, reportID string) ([]model.Comment, error) {
-	query := `
-        SELECT id, report_id, user_id, content, created_at
-        FROM comments
-        WHERE report_id = $1
-        ORDER BY created_at ASC
-    `
-	rows, err := api.DB.Query(ctx, query, reportID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// SetVoteRepo records the user's vote on a report, switching an earlier vote of the other
+// type, and keeps the report's counts in step in the same transaction. It returns the
+// user's previous vote ("" for none) and ErrReportNotFound for missing reports.
+func (api *API) SetVoteRepo(ctx context.Context, reportID int64, userID uuid.UUID, voteType string) (string, error) {
+	var previous string
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		// Locking the report serializes votes on it, so a double tap can't insert twice.
+		var id int64
+		if err := tx.QueryRow(ctx, `SELECT id FROM reports WHERE id = $1 FOR UPDATE`, reportID).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrReportNotFound
+			}
+			return err
+		}
+		err := tx.QueryRow(ctx, `SELECT vote_type FROM votes WHERE report_id = $1 AND user_id = $2`, reportID, userID).Scan(&previous)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			_, err = tx.Exec(ctx, `
+                INSERT INTO votes (report_id, user_id, vote_type, created_at)
+                VALUES ($1, $2, $3, NOW())
+            `, reportID, userID, voteType)
+		case err == nil && previous != voteType:
+			_, err = tx.Exec(ctx, `
+                UPDATE votes SET vote_type = $3, created_at = NOW()
+                WHERE report_id = $1 AND user_id = $2
+            `, reportID, userID, voteType)
+		}
+		if err != nil {
+			return err
+		}
+		return adjustVoteCounts(ctx, tx, reportID, previous, voteType)
+	})
+	return previous, err
+}
 
-	var comments []model.Comment
-	for rows.Next() {
-		var comment model.Comment
-		err := rows.Scan(&comment.ID, &comment.ReportID, &comment.UserID, &comment.Comment, &comment.CreatedAt)
+// RetractVoteRepo removes the user's vote on a report and takes it off the report's
+// counts. It returns the retracted vote type, or ErrVoteNotFound.
+func (api *API) RetractVoteRepo(ctx context.Context, reportID int64, userID uuid.UUID) (string, error) {
+	var previous string
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+            DELETE FROM votes WHERE report_id = $1 AND user_id = $2
+            RETURNING vote_type
+        `, reportID, userID).Scan(&previous)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrVoteNotFound
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-		comments = append(comments, comment)
+		return adjustVoteCounts(ctx, tx, reportID, previous, "")
+	})
+	return previous, err
+}
+
+// GetUserVoteRepo returns the user's vote type on a report, or "" when they haven't voted.
+func (api *API) GetUserVoteRepo(ctx context.Context, reportID int64, userID uuid.UUID) (string, error) {
+	var voteType string
+	err := api.DB.QueryRow(ctx, `SELECT vote_type FROM votes WHERE report_id = $1 AND user_id = $2`, reportID, userID).Scan(&voteType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
 	}
-	return comments, rows.Err()
+	return voteType, err
 }
 
 // GetVotes retrieves all votes for a specific report
@@ -419,3 +610,317 @@ func (api *API) GetVotesRepo(ctx context.Context, reportID string) ([]model.Vote
 	}
 	return votes, rows.Err()
 }
+
+// routeHazardsQuery is the bounding-box (viewport) lookup over reports.
+// Args: minLat, minLng, maxLat, maxLng, subtypes, minSeverity, limit.
+const routeHazardsQuery = `
+        SELECT ST_X(position), ST_Y(position)
+        FROM reports
+        WHERE type = 'HAZARD'
+          AND active = true
+          AND expires_at > NOW()
+          AND position && ST_MakeEnvelope($2, $1, $4, $3, 4326)
+          AND (subtype = ANY($5) OR severity >= $6)
+        ORDER BY severity DESC NULLS LAST, created_at DESC
+        LIMIT $7
+    `
+
+// GetRouteHazardsRepo returns [lng, lat] positions of active HAZARD reports inside the
+// bounding box that either have one of subtypes or at least minSeverity, most severe first.
+func (api *API) GetRouteHazardsRepo(ctx context.Context, minLat, minLng, maxLat, maxLng float64, subtypes []string, minSeverity, limit int) ([][]float64, error) {
+	rows, err := api.DB.Query(ctx, routeHazardsQuery, minLat, minLng, maxLat, maxLng, subtypes, minSeverity, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying route hazards: %w", err)
+	}
+	defer rows.Close()
+
+	var hazards [][]float64
+	for rows.Next() {
+		var lng, lat float64
+		if err := rows.Scan(&lng, &lat); err != nil {
+			return nil, fmt.Errorf("scanning route hazard: %w", err)
+		}
+		hazards = append(hazards, []float64{lng, lat})
+	}
+	return hazards, rows.Err()
+}
+
+// GetRouteClosuresRepo returns the active, unrejected ROAD_CLOSED reports whose point or
+// segment lies inside the bounding box, most severe first. Two-way segments come with
+// their outline buffered by bufferM.
+func (api *API) GetRouteClosuresRepo(ctx context.Context, minLat, minLng, maxLat, maxLng, bufferM float64, limit int) ([]roadClosure, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT ST_X(position), ST_Y(position), ST_AsGeoJSON(closure_segment), COALESCE(closure_direction, $8),
+               CASE WHEN closure_segment IS NOT NULL AND COALESCE(closure_direction, $8) = $8
+                    THEN ST_AsGeoJSON(ST_Buffer(closure_segment::geography, $5, 'quad_segs=2')::geometry) END
+        FROM reports
+        WHERE type = $7
+          AND active = true
+          AND expires_at > NOW()
+          AND report_status = ANY($9)
+          AND COALESCE(closure_segment, position) && ST_MakeEnvelope($2, $1, $4, $3, 4326)
+        ORDER BY severity DESC NULLS LAST, created_at DESC
+        LIMIT $6
+    `, minLat, minLng, maxLat, maxLng, bufferM, limit, reportTypeRoadClosed, ClosureDirectionBoth,
+		[]string{ReportStatusPending, ReportStatusVerified})
+	if err != nil {
+		return nil, fmt.Errorf("querying route closures: %w", err)
+	}
+	defer rows.Close()
+
+	var closures []roadClosure
+	for rows.Next() {
+		var c roadClosure
+		var lng, lat float64
+		var segment, area *string
+		if err := rows.Scan(&lng, &lat, &segment, &c.Direction, &area); err != nil {
+			return nil, fmt.Errorf("scanning route closure: %w", err)
+		}
+		c.Position = []float64{lng, lat}
+		if segment != nil {
+			var line struct {
+				Coordinates [][]float64 `json:"coordinates"`
+			}
+			if err := json.Unmarshal([]byte(*segment), &line); err != nil {
+				return nil, fmt.Errorf("decoding closure segment: %w", err)
+			}
+			c.Segment = line.Coordinates
+		}
+		if area != nil {
+			var polygon struct {
+				Coordinates [][][]float64 `json:"coordinates"`
+			}
+			if err := json.Unmarshal([]byte(*area), &polygon); err != nil {
+				return nil, fmt.Errorf("decoding closure area: %w", err)
+			}
+			if len(polygon.Coordinates) > 0 {
+				c.Area = polygon.Coordinates[0]
+			}
+		}
+		closures = append(closures, c)
+	}
+	return closures, rows.Err()
+}
+
+// GetReportsAlongRouteRepo returns active reports of the given types within radiusM of
+// the route, ordered by how far along the route they are. viewerID, when set, hides the
+// reports that user dismissed.
+func (api *API) GetReportsAlongRouteRepo(ctx context.Context, geometry [][]float64, radiusM int, types []string, viewerID *uuid.UUID, limit int) ([]mapbox.RouteReport, error) {
+	geojson, err := lineStringGeoJSON(geometry)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        WITH route AS (
+            SELECT ST_SetSRID(ST_GeomFromGeoJSON($1), 4326) AS geom
+        )
+        SELECT
+            r.id, r.type, r.subtype,
+            ST_Y(r.position) AS latitude, ST_X(r.position) AS longitude,
+            r.severity,
+            ST_LineLocatePoint(route.geom, r.position) * ST_Length(route.geom::geography) AS along_m,
+            ST_Distance(r.position::geography, route.geom::geography) AS to_route_m
+        FROM reports r, route
+        WHERE ST_DWithin(r.position::geography, route.geom::geography, $2)
+          AND r.active = true
+          AND r.expires_at > NOW()
+          AND r.type = ANY($3)
+          AND ($4::uuid IS NULL OR ` + reportNotDismissedSQL("$4") + `)
+        ORDER BY along_m
+        LIMIT $5
+    `
+	rows, err := api.DB.Query(ctx, query, geojson, radiusM, types, viewerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying reports along route: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []mapbox.RouteReport
+	for rows.Next() {
+		var rr mapbox.RouteReport
+		err := rows.Scan(&rr.ID, &rr.Type, &rr.Subtype, &rr.Latitude, &rr.Longitude,
+			&rr.Severity, &rr.DistanceAlongM, &rr.DistanceToRouteM)
+		if err != nil {
+			return nil, fmt.Errorf("scanning report along route: %w", err)
+		}
+		reports = append(reports, rr)
+	}
+	return reports, rows.Err()
+}
+
+// SearchReportsRepo returns reports created in [From, To) matching the filters, ordered by
+// (created_at, id) so pages stay stable while new reports come in.
+func (api *API) SearchReportsRepo(ctx context.Context, p model.ReportSearchParams) ([]model.Report, error) {
+	query := `
+        SELECT
+            r.id, r.user_id, u.username, r.type, r.subtype,
+            ST_X(r.position::geometry), ST_Y(r.position::geometry),
+            r.description, r.severity, r.verified_count,
+            r.active, r.resolved, r.created_at, r.updated_at,
+            r.expires_at, r.image_url, r.report_source, r.report_status,
+            r.comments_count, r.upvotes_count, r.downvotes_count, r.location_labels
+        FROM reports r
+        JOIN users u ON u.id = r.user_id
+        WHERE r.created_at >= $1 AND r.created_at < $2
+    `
+	args := []interface{}{p.From, p.To}
+	argCount := 2
+
+	if len(p.Types) > 0 {
+		argCount++
+		query += fmt.Sprintf(" AND r.type = ANY($%d)", argCount)
+		args = append(args, p.Types)
+	}
+	if p.Status != "" {
+		argCount++
+		query += fmt.Sprintf(" AND r.report_status = $%d", argCount)
+		args = append(args, p.Status)
+	}
+	if p.BBox != nil {
+		query += fmt.Sprintf(" AND r.position && ST_MakeEnvelope($%d, $%d, $%d, $%d, 4326)",
+			argCount+1, argCount+2, argCount+3, argCount+4)
+		args = append(args, p.BBox.MinLng, p.BBox.MinLat, p.BBox.MaxLng, p.BBox.MaxLat)
+		argCount += 4
+	}
+	if p.ReporterID != nil {
+		argCount++
+		query += fmt.Sprintf(" AND r.user_id = $%d", argCount)
+		args = append(args, *p.ReporterID)
+	}
+	if p.After != nil {
+		query += fmt.Sprintf(" AND (r.created_at, r.id) > ($%d, $%d)", argCount+1, argCount+2)
+		args = append(args, p.After.CreatedAt, p.After.ID)
+		argCount += 2
+	}
+	query += fmt.Sprintf(" ORDER BY r.created_at, r.id LIMIT $%d", argCount+1)
+	args = append(args, p.Limit)
+
+	rows, err := api.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []model.Report
+	for rows.Next() {
+		var report model.Report
+		err := rows.Scan(
+			&report.ID, &report.UserID, &report.Username, &report.Type, &report.Subtype,
+			&report.Longitude, &report.Latitude, &report.Description,
+			&report.Severity, &report.VerifiedCount, &report.Active,
+			&report.Resolved, &report.CreatedAt, &report.UpdatedAt,
+			&report.ExpiresAt, &report.ImageURL, &report.ReportSource,
+			&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
+			&report.DownvotesCount, &report.LocationLabels,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// GetReportClustersRepo groups the active reports inside params.BBox by the first
+// params.Precision characters of their geohash, largest clusters first. Rows written
+// before reports.geohash existed are hashed on the fly.
+func (api *API) GetReportClustersRepo(ctx context.Context, params model.ReportClusterParams) ([]model.ReportCluster, error) {
+	query, args := reportClustersQuery(params)
+	rows, err := api.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying report clusters: %w", err)
+	}
+	defer rows.Close()
+
+	clusters := []model.ReportCluster{}
+	for rows.Next() {
+		var c model.ReportCluster
+		if err := rows.Scan(&c.Geohash, &c.Count, &c.Latitude, &c.Longitude, &c.DominantType, &c.ReportID); err != nil {
+			return nil, fmt.Errorf("scanning report cluster: %w", err)
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, rows.Err()
+}
+
+// reportClustersQuery builds the SQL and arguments for GetReportClustersRepo. The admin
+// explain endpoint uses it too, so both see the same query.
+func reportClustersQuery(params model.ReportClusterParams) (string, []interface{}) {
+	query := fmt.Sprintf(`
+        SELECT
+            LEFT(COALESCE(r.geohash, ST_GeoHash(r.position::geometry, %d)), $5) AS cell,
+            COUNT(*)::int,
+            ST_Y(ST_Centroid(ST_Collect(r.position::geometry))),
+            ST_X(ST_Centroid(ST_Collect(r.position::geometry))),
+            MODE() WITHIN GROUP (ORDER BY r.type),
+            CASE WHEN COUNT(*) = 1 THEN MIN(r.id) END
+        FROM reports r
+        WHERE r.position && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+        AND r.expires_at > NOW()
+        AND r.active = true
+    `, ReportGeohashPrecision)
+	args := []interface{}{params.BBox.MinLng, params.BBox.MinLat, params.BBox.MaxLng, params.BBox.MaxLat, params.Precision}
+	argCount := 5
+
+	if len(params.Types) > 0 {
+		argCount++
+		query += fmt.Sprintf(" AND r.type = ANY($%d)", argCount)
+		args = append(args, params.Types)
+	}
+	if params.ViewerID != nil {
+		argCount++
+		query += " AND " + reportNotDismissedSQL(fmt.Sprintf("$%d", argCount))
+		args = append(args, *params.ViewerID)
+	}
+	query += fmt.Sprintf(" GROUP BY cell ORDER BY COUNT(*) DESC, cell LIMIT $%d", argCount+1)
+	args = append(args, params.Limit)
+	return query, args
+}
+
+// GetActiveReportsInBBoxRepo returns up to limit active, unexpired reports inside box,
+// newest first. The bbox endpoint calls it once per cache tile.
+func (api *API) GetActiveReportsInBBoxRepo(ctx context.Context, box model.BoundingBox, limit int) ([]model.Report, error) {
+	rows, err := api.DB.Query(ctx, activeReportsInBBoxQuery, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying reports in bbox: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []model.Report{}
+	for rows.Next() {
+		var report model.Report
+		err := rows.Scan(
+			&report.ID, &report.UserID, &report.Username, &report.Type, &report.Subtype,
+			&report.Longitude, &report.Latitude, &report.Description,
+			&report.Severity, &report.VerifiedCount, &report.Active,
+			&report.Resolved, &report.CreatedAt, &report.UpdatedAt,
+			&report.ExpiresAt, &report.ImageURL, &report.ReportSource,
+			&report.ReportStatus, &report.CommentsCount, &report.UpvotesCount,
+			&report.DownvotesCount, &report.LocationLabels,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// activeReportsInBBoxQuery is GetActiveReportsInBBoxRepo's SQL, shared with the admin
+// explain endpoint. Arguments: min_lng, min_lat, max_lng, max_lat, limit.
+const activeReportsInBBoxQuery = `
+        SELECT
+            r.id, r.user_id, u.username, r.type, r.subtype,
+            ST_X(r.position::geometry), ST_Y(r.position::geometry),
+            r.description, r.severity, r.verified_count,
+            r.active, r.resolved, r.created_at, r.updated_at,
+            r.expires_at, r.image_url, r.report_source, r.report_status,
+            r.comments_count, r.upvotes_count, r.downvotes_count, r.location_labels
+        FROM reports r
+        JOIN users u ON u.id = r.user_id
+        WHERE r.position && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+        AND r.expires_at > NOW()
+        AND r.active = true
+        ORDER BY r.created_at DESC, r.id DESC
+        LIMIT $5
+    `
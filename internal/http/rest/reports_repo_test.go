@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func TestVoteCountDelta(t *testing.T) {
+	tests := []struct {
+		previous, next string
+		up, down       int
+	}{
+		{"", values.VoteUp, 1, 0},
+		{"", values.VoteDown, 0, 1},
+		{values.VoteUp, values.VoteUp, 0, 0},
+		{values.VoteUp, values.VoteDown, -1, 1},
+		{values.VoteDown, values.VoteUp, 1, -1},
+		{values.VoteDown, "", 0, -1},
+	}
+	for _, tt := range tests {
+		if up, down := voteCountDelta(tt.previous, tt.next); up != tt.up || down != tt.down {
+			t.Errorf("voteCountDelta(%q, %q) = %d, %d; want %d, %d", tt.previous, tt.next, up, down, tt.up, tt.down)
+		}
+	}
+}
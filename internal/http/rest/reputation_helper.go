@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Reputation reasons (reputation_events.reason) and what each is worth to the report's
+// author. Confirmations and upvotes count once per user and report; resolution and
+// dispute count once per report.
+const (
+	reputationReportConfirmed = "report_confirmed"
+	reputationReportUpvoted   = "report_upvoted"
+	reputationReportResolved  = "report_resolved"
+	reputationReportDisputed  = "report_disputed"
+
+	reputationConfirmedPoints = 2
+	reputationUpvotedPoints   = 1
+	reputationResolvedPoints  = 5
+	reputationDisputedPoints  = -10
+
+	// Reports from users with at least this score go live as VERIFIED instead of PENDING.
+	trustedReporterScore = 50
+)
+
+// reporterIsTrusted reports whether userID has earned enough reputation for their reports
+// to skip verification. Lookup failures leave the report PENDING.
+func (api *API) reporterIsTrusted(ctx context.Context, userID uuid.UUID) bool {
+	score, err := api.GetUserReputationRepo(ctx, userID)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Reputation lookup failed", "reporter_id", userID, "err", err)
+		return false
+	}
+	return score >= trustedReporterScore
+}
+
+// awardReportUpvote credits the author of reportID for voterID's upvote. Failures are
+// logged; the vote itself has already been recorded.
+func (api *API) awardReportUpvote(ctx context.Context, reportID int64, voterID uuid.UUID) {
+	if err := api.AwardReportAuthorRepo(ctx, reportID, voterID, reputationReportUpvoted, reputationUpvotedPoints); err != nil {
+		api.logger().WarnContext(ctx, "Reputation award failed", "report_id", reportID, "err", err)
+	}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// awardReportAuthor records a reputation event for the author of reportID and adds points
+// (negative for penalties) to their reputation_score. The event is keyed by reason, report
+// and actor, so repeating the trigger - the same user upvoting twice, the lifecycle worker
+// retrying a batch - counts once. Nothing is awarded for the author's own actions or for
+// reports held by the anonymous user.
+func awardReportAuthor(ctx context.Context, tx pgx.Tx, reportID int64, actorID *uuid.UUID, reason string, points int) error {
+	dedupKey := reason + ":" + strconv.FormatInt(reportID, 10)
+	if actorID != nil {
+		dedupKey += ":" + actorID.String()
+	}
+	_, err := tx.Exec(ctx, `
+		WITH ev AS (
+			INSERT INTO reputation_events (user_id, report_id, reason, points, dedup_key)
+			SELECT r.user_id, r.id, $3, $4, $5
+			FROM reports r
+			WHERE r.id = $1
+			  AND r.user_id <> $6
+			  AND r.user_id IS DISTINCT FROM $2
+			ON CONFLICT (dedup_key) DO NOTHING
+			RETURNING user_id, points
+		)
+		UPDATE users u
+		SET reputation_score = u.reputation_score + ev.points
+		FROM ev
+		WHERE u.id = ev.user_id
+	`, reportID, actorID, reason, points, dedupKey, anonymousUserID)
+	if err != nil {
+		return fmt.Errorf("recording reputation event: %w", err)
+	}
+	return nil
+}
+
+// AwardReportAuthorRepo is awardReportAuthor for callers that aren't already in a transaction.
+func (api *API) AwardReportAuthorRepo(ctx context.Context, reportID int64, actorID uuid.UUID, reason string, points int) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		return awardReportAuthor(ctx, tx, reportID, &actorID, reason, points)
+	})
+}
+
+// GetUserReputationRepo returns the user's reputation score.
+func (api *API) GetUserReputationRepo(ctx context.Context, userID uuid.UUID) (int, error) {
+	var score int
+	err := api.DB.QueryRow(ctx, `SELECT reputation_score FROM users WHERE id = $1`, userID).Scan(&score)
+	return score, err
+}
@@ -3,31 +3,76 @@ package rest
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
 )
 
+// ResponseEnvelopeVersion is sent as "version" on every response. Bump it whenever
+// field names or the envelope shape change so clients can tell which contract they got.
+//
+// Serialization policy: every JSON field the API emits is snake_case, including nested
+// structs from the routing packages. Upstream payloads we pass through untouched (Mapbox
+// Directions, Google Places) keep the provider's names.
+//
+// History:
+//
+//	1: original envelope, Valhalla mobile route structs in camelCase
+//	2: adds "version"; Valhalla mobile route structs switched to snake_case
+//	3: adds "errors", one {field, rule, message} per invalid request field
+//	4: adds "code" (see values.Code*) and "details" to every error response
+//	5: nearby reports, group search, comments, saved locations and group messages return
+//	   a page: {data, page, page_size, total, next_cursor}
+const ResponseEnvelopeVersion = 5
+
 type ServerResponse struct {
+	Version    int             `json:"version"`
 	Err        error           `json:"err,omitempty"`
 	Message    string          `json:"message"`
 	Status     string          `json:"status"`
 	StatusCode int             `json:"status_code"`
 	Context    context.Context `json:"context,omitempty"`
 	Data       interface{}     `json:"data,omitempty"`
+	// Code is the machine-readable reason an error response failed; see values.Code*.
+	Code string `json:"code,omitempty"`
+	// Details adds context to some codes, e.g. retry_after_seconds when rate limited.
+	Details map[string]any `json:"details,omitempty"`
+	// Errors lists each invalid request field when Status is BadRequestBody.
+	Errors []util.FieldError `json:"errors,omitempty"`
 }
 
-// respondWithError logs an error with zap and parses the error to the ServerResponse
+// respondWithError logs server errors and parses the error to the ServerResponse
 func respondWithError(err error, message, status string, tracingContext *tracing.Context) *ServerResponse {
-	// logger.Log.Error(err.Error(), message, status)
+	if code := util.StatusCode(status); code >= http.StatusInternalServerError {
+		attrs := []any{"status", code, "err", err}
+		if tracingContext != nil {
+			attrs = append(attrs, "request_id", tracingContext.RequestID)
+		}
+		slog.Error(message, attrs...)
+	}
 	return &ServerResponse{
 		// Err:        err,
 		Message:    message,
 		Status:     status,
 		StatusCode: util.StatusCode(status),
+		Code:       errorCode(err, status),
+		Details:    errorDetails(err),
+	}
+}
+
+// respondWithValidationError rejects a request that failed util.ValidateStruct, listing
+// every invalid field. A single problem is also used as the message.
+func respondWithValidationError(err error, tracingContext *tracing.Context) *ServerResponse {
+	resp := respondWithError(err, "validation failed", values.BadRequestBody, tracingContext)
+	resp.Code = values.CodeValidationFailed
+	resp.Errors = util.FieldErrors(err)
+	if len(resp.Errors) == 1 {
+		resp.Message = resp.Errors[0].Message
 	}
+	return resp
 }
 
 func writeJSONResponse(w http.ResponseWriter, content []byte, statusCode int) {
@@ -35,13 +80,14 @@ func writeJSONResponse(w http.ResponseWriter, content []byte, statusCode int) {
 	w.WriteHeader(statusCode)
 	if _, err := w.Write(content); err != nil {
 		// logger.Log.Error("unable to write json response")
-		log.Println("unable to write json response")
+		slog.Warn("Unable to write JSON response", "err", err)
 	}
 }
 
 // writeErrorResponse writes an error response to the client
 func writeErrorResponse(w http.ResponseWriter, err error, status, errMessage string) {
 	r := respondWithError(err, errMessage, status, nil)
+	r.Version = ResponseEnvelopeVersion
 	response, _ := json.Marshal(r)
 	writeJSONResponse(w, response, r.StatusCode)
 }
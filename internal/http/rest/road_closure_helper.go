@@ -0,0 +1,176 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const reportTypeRoadClosed = "ROAD_CLOSED"
+
+// Closure directions (reports.closure_direction). A ONE_WAY closure only blocks traffic
+// travelling in the order its segment is drawn in.
+const (
+	ClosureDirectionBoth   = "BOTH"
+	ClosureDirectionOneWay = "ONE_WAY"
+)
+
+const (
+	// maxClosureSegmentM caps how long a stretch a single report can close.
+	maxClosureSegmentM = 5000.0
+	// A snapped segment longer than this many times the drawn one took a detour and is
+	// dropped in favour of what was drawn.
+	maxClosureSnapStretch = 2.0
+	// closureBufferM is how far around a two-way segment Valhalla keeps off, enough to
+	// cover both carriageways without catching parallel streets.
+	closureBufferM = 8.0
+	// closureSearchPaddingDeg pads the bounding box of the route's locations (~5 km).
+	closureSearchPaddingDeg = 0.05
+	maxRouteClosures        = 100
+	// One-way segments are excluded at points at least closureExclusionSpacingM apart
+	// along them, so a long snapped segment doesn't use up the exclusion budget.
+	closureExclusionSpacingM = 100.0
+)
+
+// roadClosure is an active ROAD_CLOSED report near a trip.
+type roadClosure struct {
+	Position  []float64   // [lng, lat]
+	Segment   [][]float64 // [lng, lat] pairs; nil for point closures
+	Direction string
+	// Area is the outer ring of a two-way segment buffered by closureBufferM.
+	Area [][]float64
+}
+
+// prepareClosureSegment validates a report's closure segment and snaps it to the road.
+// Reports without a segment are left as points.
+func (api *API) prepareClosureSegment(ctx context.Context, report *model.CreateReportRequest) (string, string, error) {
+	if len(report.ClosureSegment) == 0 {
+		report.ClosureDirection = nil
+		return "", "", nil
+	}
+	if report.Type != reportTypeRoadClosed {
+		return values.BadRequestBody, "closure_segment is only allowed on ROAD_CLOSED reports",
+			errors.New("closure segment on a non-closure report")
+	}
+	for i, p := range report.ClosureSegment {
+		lat, lng, err := util.NormalizeCoordinate(p[1], p[0])
+		if err != nil {
+			return values.BadRequestBody, "closure_segment: invalid latitude or longitude", err
+		}
+		report.ClosureSegment[i] = []float64{lng, lat}
+	}
+	if lineLengthMeters(report.ClosureSegment) > maxClosureSegmentM {
+		return values.BadRequestBody, "closure_segment can be at most 5 km long", errors.New("closure segment too long")
+	}
+	if report.ClosureDirection == nil {
+		both := ClosureDirectionBoth
+		report.ClosureDirection = &both
+	}
+	report.ClosureSegment = api.snapClosureSegment(ctx, report.ClosureSegment)
+	return "", "", nil
+}
+
+// snapClosureSegment routes along the drawn segment so it follows the road, in the
+// direction it was drawn. The drawn segment is kept when that fails or detours.
+func (api *API) snapClosureSegment(ctx context.Context, segment [][]float64) [][]float64 {
+	if api.ValhallaClient == nil {
+		return segment
+	}
+	locations := make([]valhalla.Location, len(segment))
+	for i, p := range segment {
+		locations[i] = valhalla.Location{Lat: p[1], Lon: p[0]}
+	}
+	route, err := api.ValhallaClient.GetRoute(ctx, valhalla.RouteRequest{Locations: locations, Costing: "auto"})
+	if err != nil {
+		api.logger().WarnContext(ctx, "Road closures: snapping failed, keeping the drawn segment", "err", err)
+		return segment
+	}
+	var snapped [][]float64
+	for _, leg := range route.Trip.Legs {
+		snapped = append(snapped, leg.Coordinates...)
+	}
+	if len(snapped) < 2 || lineLengthMeters(snapped) > maxClosureSnapStretch*lineLengthMeters(segment) {
+		return segment
+	}
+	return snapped
+}
+
+func lineLengthMeters(line [][]float64) float64 {
+	var total float64
+	for i := 1; i < len(line); i++ {
+		total += util.HaversineMeters(line[i-1][1], line[i-1][0], line[i][1], line[i][0])
+	}
+	return total
+}
+
+// tripRoadClosures returns the active closures around the trip. It returns nil when they
+// couldn't be loaded, which just means none are avoided.
+func (api *API) tripRoadClosures(ctx context.Context, locations []Location) []roadClosure {
+	if !api.dbAvailable() {
+		return nil
+	}
+	minLat, minLng, maxLat, maxLng := tripBounds(locations, closureSearchPaddingDeg)
+	closures, err := api.GetRouteClosuresRepo(ctx, minLat, minLng, maxLat, maxLng, closureBufferM, maxRouteClosures)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to load road closures for route", "err", err)
+		return nil
+	}
+	if closures == nil {
+		closures = []roadClosure{}
+	}
+	return closures
+}
+
+// tripBounds is the bounding box of the trip's locations padded by padDeg.
+func tripBounds(locations []Location, padDeg float64) (minLat, minLng, maxLat, maxLng float64) {
+	minLat, minLng = locations[0].Lat, locations[0].Lng
+	maxLat, maxLng = minLat, minLng
+	for _, loc := range locations[1:] {
+		minLat, maxLat = min(minLat, loc.Lat), max(maxLat, loc.Lat)
+		minLng, maxLng = min(minLng, loc.Lng), max(maxLng, loc.Lng)
+	}
+	return minLat - padDeg, minLng - padDeg, maxLat + padDeg, maxLng + padDeg
+}
+
+// closureExclusions turns closures into what Valhalla avoids: two-way segments become
+// exclude_polygons, one-way segments exclude_locations spaced along them heading the
+// closed way, and point closures an exclude_location each. At most maxLocations
+// locations are returned; closures come most important first.
+func closureExclusions(closures []roadClosure, maxLocations int) ([]valhalla.Location, [][][]float64) {
+	var locations []valhalla.Location
+	var polygons [][][]float64
+	add := func(loc valhalla.Location) {
+		if len(locations) < maxLocations {
+			locations = append(locations, loc)
+		}
+	}
+	for _, c := range closures {
+		switch {
+		case len(c.Segment) < 2:
+			add(valhalla.Location{Lat: c.Position[1], Lon: c.Position[0]})
+		case c.Direction == ClosureDirectionOneWay:
+			sinceLast := closureExclusionSpacingM
+			for i := 1; i < len(c.Segment); i++ {
+				a, b := c.Segment[i-1], c.Segment[i]
+				pieceM := util.HaversineMeters(a[1], a[0], b[1], b[0])
+				sinceLast += pieceM / 2
+				if sinceLast >= closureExclusionSpacingM {
+					heading := int(math.Round(initialBearing(a, b))) % 360
+					add(valhalla.Location{Lat: (a[1] + b[1]) / 2, Lon: (a[0] + b[0]) / 2, Heading: &heading})
+					sinceLast = 0
+				}
+				sinceLast += pieceM / 2
+			}
+		case len(c.Area) > 0:
+			polygons = append(polygons, c.Area)
+		default:
+			add(valhalla.Location{Lat: c.Position[1], Lon: c.Position[0]})
+		}
+	}
+	return locations, polygons
+}
@@ -0,0 +1,38 @@
+package rest
+
+import "testing"
+
+func TestClosureExclusions(t *testing.T) {
+	area := [][]float64{{33.36, 35.17}, {33.37, 35.17}, {33.37, 35.18}, {33.36, 35.17}}
+	closures := []roadClosure{
+		{Position: []float64{33.30, 35.10}, Direction: ClosureDirectionBoth},
+		{Position: []float64{33.36, 35.17}, Segment: [][]float64{{33.36, 35.17}, {33.37, 35.18}}, Direction: ClosureDirectionBoth, Area: area},
+		// Heading east, ~90 m per stretch: excluded at the first and third stretch.
+		{Position: []float64{33.40, 35.20}, Direction: ClosureDirectionOneWay, Segment: [][]float64{
+			{33.400, 35.20}, {33.401, 35.20}, {33.402, 35.20}, {33.403, 35.20},
+		}},
+	}
+
+	locations, polygons := closureExclusions(closures, 50)
+	if len(polygons) != 1 || len(polygons[0]) != len(area) {
+		t.Fatalf("polygons = %v, want the two-way segment's area", polygons)
+	}
+	if len(locations) != 3 {
+		t.Fatalf("locations = %+v, want the point closure and two one-way points", locations)
+	}
+	if locations[0].Heading != nil || locations[0].Lon != 33.30 {
+		t.Errorf("point closure = %+v", locations[0])
+	}
+	for _, loc := range locations[1:] {
+		if loc.Heading == nil || *loc.Heading != 90 {
+			t.Errorf("one-way exclusion = %+v, want heading 90", loc)
+		}
+	}
+	if locations[2].Lon != 33.4025 {
+		t.Errorf("second one-way exclusion at %v, want the third stretch", locations[2].Lon)
+	}
+
+	if locations, _ := closureExclusions(closures, 2); len(locations) != 2 {
+		t.Errorf("got %d locations, want the cap of 2", len(locations))
+	}
+}
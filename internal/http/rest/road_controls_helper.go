@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"context"
+	"slices"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/util"
+)
+
+// addRoadControls fills in the traffic signals and stop signs along each step of a
+// Valhalla route. It costs a trace_attributes call per leg; failures are logged and
+// leave the leg's steps without road controls.
+func (api *API) addRoadControls(ctx context.Context, costing string, resp *mapbox.DirectionsResponse) {
+	for r := range resp.Routes {
+		for l := range resp.Routes[r].Legs {
+			leg := &resp.Routes[r].Legs[l]
+			coords, spans := legShape(leg.Steps)
+			if len(coords) < 2 {
+				continue
+			}
+			controls, err := api.ValhallaClient.TraceRoadControls(ctx, costing, coords)
+			if err != nil {
+				api.logger().WarnContext(ctx, "Failed to fetch road controls", "route", r, "leg", l, "err", err)
+				continue
+			}
+			for _, c := range controls {
+				attachStepRoadControl(leg.Steps, coords, spans, c.Type, c.ShapeIndex)
+			}
+		}
+	}
+}
+
+// stepSpan is the range of a step's geometry within its leg's shape.
+type stepSpan struct{ begin, end int }
+
+// legShape joins a leg's step geometries into one line, dropping the point each step
+// shares with the one before, and returns where each step lies in it.
+func legShape(steps []mapbox.Step) ([][]float64, []stepSpan) {
+	var coords [][]float64
+	spans := make([]stepSpan, len(steps))
+	for i, step := range steps {
+		g := step.Geometry.Coordinates
+		begin := len(coords)
+		if len(coords) > 0 && len(g) > 0 && slices.Equal(coords[len(coords)-1], g[0]) {
+			begin--
+			g = g[1:]
+		}
+		coords = append(coords, g...)
+		spans[i] = stepSpan{begin: max(begin, 0), end: len(coords) - 1}
+	}
+	return coords, spans
+}
+
+// attachStepRoadControl assigns a control at shape index idx to the step whose span
+// (begin, end] contains it, like the mobile formatter does for maneuvers.
+func attachStepRoadControl(steps []mapbox.Step, coords [][]float64, spans []stepSpan, controlType string, idx int) {
+	if idx < 0 || idx >= len(coords) {
+		return
+	}
+	for i, span := range spans {
+		if idx <= span.begin || idx > span.end {
+			continue
+		}
+		distance := 0.0
+		for j := span.begin + 1; j <= idx; j++ {
+			prev, cur := coords[j-1], coords[j]
+			distance += util.HaversineMeters(prev[1], prev[0], cur[1], cur[0])
+		}
+		steps[i].RoadControls = append(steps[i].RoadControls, mapbox.RoadControl{
+			Type:           controlType,
+			DistanceMeters: distance,
+			Coordinates:    coords[idx],
+			AtManeuverEnd:  idx == span.end,
+		})
+		return
+	}
+}
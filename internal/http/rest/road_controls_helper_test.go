@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+)
+
+// osrmTwoSteps is a Valhalla OSRM-format route whose first step runs east over three
+// points and whose second turns north; the steps share their corner point.
+const osrmTwoSteps = `{"code":"Ok","routes":[{"distance":300,"duration":40,"geometry":{"type":"LineString","coordinates":[]},
+"legs":[{"steps":[
+ {"geometry":{"type":"LineString","coordinates":[[33.36,35.17],[33.361,35.17],[33.362,35.17]]},"maneuver":{"type":"depart"}},
+ {"geometry":{"type":"LineString","coordinates":[[33.362,35.17],[33.362,35.171]]},"maneuver":{"type":"turn"}}
+]}]}]}`
+
+func TestValhallaRouteRoadControls(t *testing.T) {
+	var traced []map[string]float64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/route":
+			io.WriteString(w, osrmTwoSteps)
+		case "/trace_attributes":
+			var req struct {
+				Shape []map[string]float64 `json:"shape"`
+			}
+			json.Unmarshal(body, &req)
+			traced = req.Shape
+			// A signal at the corner, where the turn happens, and a stop sign at the
+			// end of the second step.
+			io.WriteString(w, `{"edges":[
+				{"begin_shape_index":0,"end_shape_index":1},
+				{"begin_shape_index":1,"end_shape_index":2,"end_node":{"traffic_signal":true}},
+				{"begin_shape_index":2,"end_shape_index":3,"stop_sign":true}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	api := &API{ValhallaClient: valhalla.NewValhallaClient(srv.URL)}
+	req := RouteRequest{Profile: "walking", Locations: []Location{{Lat: 35.17, Lng: 33.36}, {Lat: 35.171, Lng: 33.362}}}
+
+	resp, err := api.getValhallaRoute(context.Background(), req, "fastest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if traced != nil || resp.Routes[0].Legs[0].Steps[0].RoadControls != nil {
+		t.Fatal("road controls fetched without being asked for")
+	}
+
+	req.RoadControls = true
+	resp, err = api.getValhallaRoute(context.Background(), req, "fastest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traced) != 4 || traced[3]["lat"] != 35.171 || traced[3]["lon"] != 33.362 {
+		t.Fatalf("traced shape = %v, want the 4 distinct points of the leg", traced)
+	}
+	steps := resp.Routes[0].Legs[0].Steps
+	if len(steps[0].RoadControls) != 1 || len(steps[1].RoadControls) != 1 {
+		t.Fatalf("road controls = %+v, %+v", steps[0].RoadControls, steps[1].RoadControls)
+	}
+	signal, stop := steps[0].RoadControls[0], steps[1].RoadControls[0]
+	if signal.Type != valhalla.RoadControlTrafficSignal || !signal.AtManeuverEnd || signal.DistanceMeters < 180 || signal.DistanceMeters > 184 {
+		t.Errorf("signal = %+v, want at the end of the first step, ~182 m in", signal)
+	}
+	if stop.Type != valhalla.RoadControlStopSign || !stop.AtManeuverEnd || stop.Coordinates[1] != 35.171 {
+		t.Errorf("stop sign = %+v", stop)
+	}
+}
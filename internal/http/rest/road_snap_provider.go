@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+)
+
+// Road snapping providers.
+const (
+	SnapProviderValhalla = "valhalla"
+	SnapProviderMapbox   = "mapbox"
+)
+
+// snappingPriority is the order providers are tried in when SNAPPING_PROVIDERS is unset:
+// our own Valhalla first, the paid Mapbox Map Matching API only when it fails.
+var snappingPriority = []string{SnapProviderValhalla, SnapProviderMapbox}
+
+var errNoSnappingProvider = errors.New("no road snapping provider configured")
+
+// snappingOrder is the configured priority (SNAPPING_PROVIDERS, comma separated) or the
+// default one. Unknown and repeated names are ignored.
+func (api *API) snappingOrder() []string {
+	var order []string
+	for _, name := range strings.Split(api.Config.SnappingProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if slices.Contains(snappingPriority, name) && !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	if len(order) == 0 {
+		return snappingPriority
+	}
+	return order
+}
+
+// snapToRoad moves a point onto the nearest drivable road within radiusM, asking each
+// configured provider in turn, and reports which one snapped it.
+func (api *API) snapToRoad(ctx context.Context, lat, lng float64, radiusM int) (float64, float64, string, error) {
+	err := errNoSnappingProvider
+	for _, provider := range api.snappingOrder() {
+		var snappedLat, snappedLng float64
+		switch provider {
+		case SnapProviderValhalla:
+			if api.ValhallaClient == nil {
+				continue
+			}
+			snappedLat, snappedLng, err = api.snapToRoadValhalla(ctx, lat, lng, radiusM)
+		case SnapProviderMapbox:
+			if api.MapboxClient == nil || api.MapboxClient.APIKey == "" {
+				continue
+			}
+			snappedLat, snappedLng, err = api.snapToRoadMapbox(ctx, lat, lng, radiusM)
+		}
+		if err == nil {
+			return snappedLat, snappedLng, provider, nil
+		}
+		if ctx.Err() != nil {
+			return lat, lng, "", ctx.Err()
+		}
+		api.logger().WarnContext(ctx, "Road snapping provider failed", "provider", provider, "err", err)
+	}
+	return lat, lng, "", err
+}
+
+// snapToRoadValhalla snaps a point by map-matching it on our Valhalla instance.
+func (api *API) snapToRoadValhalla(ctx context.Context, lat, lng float64, radiusM int) (float64, float64, error) {
+	matched, err := api.ValhallaClient.MatchTrace(ctx, []valhalla.Location{{Lat: lat, Lon: lng}}, "auto", radiusM)
+	if err != nil {
+		return lat, lng, err
+	}
+	if p := matched[0]; p.Type != valhalla.MatchTypeUnmatched {
+		return p.Lat, p.Lon, nil
+	}
+	return lat, lng, fmt.Errorf("no road within %d m", radiusM)
+}
@@ -0,0 +1,19 @@
+package rest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/config"
+)
+
+func TestSnappingOrder(t *testing.T) {
+	api := &API{Config: &config.Config{}}
+	if got := api.snappingOrder(); !slices.Equal(got, []string{"valhalla", "mapbox"}) {
+		t.Errorf("default order = %v, want valhalla before mapbox", got)
+	}
+	api.Config.SnappingProviders = " Mapbox, google,mapbox, valhalla"
+	if got := api.snappingOrder(); !slices.Equal(got, []string{"mapbox", "valhalla"}) {
+		t.Errorf("configured order = %v, want [mapbox valhalla]", got)
+	}
+}
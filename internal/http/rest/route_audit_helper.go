@@ -0,0 +1,225 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	routeAuditTimeout = 30 * time.Second
+	// routeAuditMaxInFlight drops samples rather than queueing them when the audit
+	// provider is slow, so auditing can never pile up behind production traffic.
+	routeAuditMaxInFlight = 4
+	// An audit is flagged when the providers disagree by more than either threshold.
+	routeAuditFlagDurationPct = 25.0
+	routeAuditFlagDivergenceM = 1000.0
+	// routeDivergenceMaxPoints caps the vertices compared per route.
+	routeDivergenceMaxPoints = 300
+)
+
+// routeAuditor limits how many audits run at once.
+type routeAuditor struct {
+	inFlight atomic.Int32
+}
+
+// maybeAuditRoute samples ROUTE_AUDIT_SAMPLE_PERCENT of served routes and re-runs them on
+// the other provider in the background. It never delays or fails the route response.
+func (api *API) maybeAuditRoute(req RouteRequest, preference, provider string, served *mapbox.DirectionsResponse) {
+	pct := api.Config.RouteAuditSamplePercent
+	if pct <= 0 || served == nil || len(served.Routes) == 0 || rand.Float64()*100 >= pct {
+		return
+	}
+	if api.routeAudits.inFlight.Add(1) > routeAuditMaxInFlight {
+		api.routeAudits.inFlight.Add(-1)
+		return
+	}
+	primary := served.Routes[0]
+
+	go func() {
+		defer api.routeAudits.inFlight.Add(-1)
+		ctx, cancel := context.WithTimeout(context.Background(), routeAuditTimeout)
+		defer cancel()
+
+		audit := api.auditRoute(ctx, req, preference, provider, primary)
+		if audit.Flagged {
+			api.logger().Warn("Route audit flagged",
+				"primary", audit.PrimaryProvider, "audit", audit.AuditProvider, "profile", audit.Profile,
+				"preference", audit.Preference, "duration_diff_pct", derefFloat(audit.DurationDiffPct),
+				"divergence_m", derefFloat(audit.GeometryDivergenceM))
+		}
+		if err := api.InsertRouteAuditRepo(ctx, audit); err != nil {
+			api.logger().Error("Failed to record route audit", "err", err)
+		}
+	}()
+}
+
+func (api *API) auditRoute(ctx context.Context, req RouteRequest, preference, provider string, primary mapbox.Route) model.RouteAudit {
+	origin, destination := req.Locations[0], req.Locations[len(req.Locations)-1]
+	audit := model.RouteAudit{
+		PrimaryProvider:  provider,
+		Profile:          req.Profile,
+		Preference:       preference,
+		OriginLat:        origin.Lat,
+		OriginLng:        origin.Lng,
+		DestinationLat:   destination.Lat,
+		DestinationLng:   destination.Lng,
+		PrimaryDurationS: primary.Duration,
+		PrimaryDistanceM: primary.Distance,
+	}
+
+	var resp *mapbox.DirectionsResponse
+	var err error
+	if provider == RouteProviderValhalla {
+		audit.AuditProvider = RouteProviderMapbox
+		resp, err = api.auditMapboxRoute(ctx, req)
+	} else {
+		audit.AuditProvider = RouteProviderValhalla
+		resp, err = api.getValhallaRoute(ctx, req, values.RoutePreferenceFastest)
+	}
+	if err == nil && len(resp.Routes) == 0 {
+		err = fmt.Errorf("no route returned")
+	}
+	if err != nil {
+		msg := err.Error()
+		audit.Error = &msg
+		return audit
+	}
+
+	alt := resp.Routes[0]
+	durationDiff := percentDiff(primary.Duration, alt.Duration)
+	distanceDiff := percentDiff(primary.Distance, alt.Distance)
+	divergence := routeDivergenceMeters(primary.Geometry.Coordinates, alt.Geometry.Coordinates)
+	audit.AuditDurationS = &alt.Duration
+	audit.AuditDistanceM = &alt.Distance
+	audit.DurationDiffPct = &durationDiff
+	audit.DistanceDiffPct = &distanceDiff
+	audit.GeometryDivergenceM = &divergence
+	audit.Flagged = math.Abs(durationDiff) > routeAuditFlagDurationPct || divergence > routeAuditFlagDivergenceM
+	return audit
+}
+
+// auditMapboxRoute asks Mapbox for the same trip. Mapbox has no motorcycle profile, so
+// those are compared against its driving profile.
+func (api *API) auditMapboxRoute(ctx context.Context, req RouteRequest) (*mapbox.DirectionsResponse, error) {
+	if api.MapboxClient == nil {
+		return nil, fmt.Errorf("mapbox client not configured")
+	}
+	profile := req.Profile
+	if profile == profileMotorcycle {
+		profile = "driving"
+	}
+	return api.MapboxClient.DirectionsWithNavigation(ctx, mapboxCoordinates(req.Locations), profile, false,
+		&mapbox.NavigationOptions{VoiceUnits: "metric", Language: "en"})
+}
+
+// mapboxCoordinates formats locations as Mapbox "lng,lat" strings.
+func mapboxCoordinates(locations []Location) []string {
+	coordinates := make([]string, len(locations))
+	for i, loc := range locations {
+		coordinates[i] = fmt.Sprintf("%s,%s",
+			strconv.FormatFloat(loc.Lng, 'f', 6, 64),
+			strconv.FormatFloat(loc.Lat, 'f', 6, 64))
+	}
+	return coordinates
+}
+
+func (api *API) RouteAuditSummaryHelper(ctx context.Context, hoursStr string) (model.RouteAuditSummary, string, string, error) {
+	hours := 24
+	if hoursStr != "" {
+		n, err := strconv.Atoi(hoursStr)
+		if err != nil || n < 1 || n > 24*30 {
+			return model.RouteAuditSummary{}, values.BadRequestBody, "hours must be between 1 and 720", fmt.Errorf("invalid hours %q", hoursStr)
+		}
+		hours = n
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	groups, err := api.RouteAuditGroupsRepo(ctx, since)
+	if err != nil {
+		return model.RouteAuditSummary{}, values.Error, "Failed to fetch route audits", err
+	}
+	flagged, err := api.ListFlaggedRouteAuditsRepo(ctx, since, 20)
+	if err != nil {
+		return model.RouteAuditSummary{}, values.Error, "Failed to fetch route audits", err
+	}
+	return model.RouteAuditSummary{
+		Since:         since,
+		SamplePercent: api.Config.RouteAuditSamplePercent,
+		Groups:        groups,
+		RecentFlagged: flagged,
+	}, values.Success, "Route audits fetched successfully", nil
+}
+
+func percentDiff(primary, audit float64) float64 {
+	if primary == 0 {
+		return 0
+	}
+	return (audit - primary) / primary * 100
+}
+
+// routeDivergenceMeters is the symmetric Hausdorff distance between two [lng, lat]
+// polylines: the furthest any vertex of one route gets from the other route.
+func routeDivergenceMeters(a, b [][]float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	a, b = downsampleLine(a, routeDivergenceMaxPoints), downsampleLine(b, routeDivergenceMaxPoints)
+	return math.Max(directedDivergence(a, b), directedDivergence(b, a))
+}
+
+func directedDivergence(from, to [][]float64) float64 {
+	var worst float64
+	for _, p := range from {
+		nearest := math.Inf(1)
+		for i := 1; i < len(to); i++ {
+			nearest = math.Min(nearest, pointSegmentMeters(p, to[i-1], to[i]))
+		}
+		worst = math.Max(worst, nearest)
+	}
+	return worst
+}
+
+// pointSegmentMeters is the distance from p to segment ab, projecting onto a local
+// equirectangular plane around p, which is accurate at route scales.
+func pointSegmentMeters(p, a, b []float64) float64 {
+	cosLat := math.Cos(p[1] * math.Pi / 180)
+	ax, ay := (a[0]-p[0])*cosLat, a[1]-p[1]
+	bx, by := (b[0]-p[0])*cosLat, b[1]-p[1]
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	// Back to degrees around p for the haversine.
+	cx, cy := ax+t*dx, ay+t*dy
+	return util.HaversineMeters(p[1], p[0], p[1]+cy, p[0]+cx/cosLat)
+}
+
+func downsampleLine(coords [][]float64, maxPoints int) [][]float64 {
+	if len(coords) <= maxPoints {
+		return coords
+	}
+	out := make([][]float64, 0, maxPoints)
+	step := float64(len(coords)-1) / float64(maxPoints-1)
+	for i := 0; i < maxPoints; i++ {
+		out = append(out, coords[int(math.Round(float64(i)*step))])
+	}
+	return out
+}
+
+func derefFloat(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func (api *API) InsertRouteAuditRepo(ctx context.Context, a model.RouteAudit) error {
+	_, err := api.DB.Exec(ctx, `
+        INSERT INTO route_audits (
+            primary_provider, audit_provider, profile, preference,
+            origin, destination,
+            primary_duration_s, primary_distance_m, audit_duration_s, audit_distance_m,
+            duration_diff_pct, distance_diff_pct, geometry_divergence_m, flagged, error
+        )
+        VALUES (
+            $1, $2, $3, $4,
+            ST_SetSRID(ST_MakePoint($5, $6), 4326), ST_SetSRID(ST_MakePoint($7, $8), 4326),
+            $9, $10, $11, $12, $13, $14, $15, $16, $17
+        )
+    `, a.PrimaryProvider, a.AuditProvider, a.Profile, a.Preference,
+		a.OriginLng, a.OriginLat, a.DestinationLng, a.DestinationLat,
+		a.PrimaryDurationS, a.PrimaryDistanceM, a.AuditDurationS, a.AuditDistanceM,
+		a.DurationDiffPct, a.DistanceDiffPct, a.GeometryDivergenceM, a.Flagged, a.Error)
+	return err
+}
+
+// RouteAuditGroupsRepo aggregates audits since the given time by provider pair and profile.
+func (api *API) RouteAuditGroupsRepo(ctx context.Context, since time.Time) ([]model.RouteAuditGroup, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT primary_provider, audit_provider, profile, preference,
+               COUNT(*)::int,
+               COUNT(*) FILTER (WHERE error IS NOT NULL)::int,
+               COUNT(*) FILTER (WHERE flagged)::int,
+               COALESCE(AVG(ABS(duration_diff_pct)), 0),
+               COALESCE(AVG(ABS(distance_diff_pct)), 0),
+               COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY geometry_divergence_m), 0)
+        FROM route_audits
+        WHERE created_at >= $1
+        GROUP BY primary_provider, audit_provider, profile, preference
+        ORDER BY COUNT(*) DESC
+    `, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []model.RouteAuditGroup{}
+	for rows.Next() {
+		var g model.RouteAuditGroup
+		if err := rows.Scan(&g.PrimaryProvider, &g.AuditProvider, &g.Profile, &g.Preference,
+			&g.Audits, &g.Errors, &g.Flagged,
+			&g.AvgAbsDurationDiffPct, &g.AvgAbsDistanceDiffPct, &g.P90GeometryDivergenceM); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (api *API) ListFlaggedRouteAuditsRepo(ctx context.Context, since time.Time, limit int) ([]model.RouteAudit, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT id, primary_provider, audit_provider, profile, preference,
+               ST_Y(origin), ST_X(origin), ST_Y(destination), ST_X(destination),
+               primary_duration_s, primary_distance_m, audit_duration_s, audit_distance_m,
+               duration_diff_pct, distance_diff_pct, geometry_divergence_m, flagged, error, created_at
+        FROM route_audits
+        WHERE flagged AND created_at >= $1
+        ORDER BY created_at DESC
+        LIMIT $2
+    `, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audits := []model.RouteAudit{}
+	for rows.Next() {
+		var a model.RouteAudit
+		if err := rows.Scan(&a.ID, &a.PrimaryProvider, &a.AuditProvider, &a.Profile, &a.Preference,
+			&a.OriginLat, &a.OriginLng, &a.DestinationLat, &a.DestinationLng,
+			&a.PrimaryDurationS, &a.PrimaryDistanceM, &a.AuditDurationS, &a.AuditDistanceM,
+			&a.DurationDiffPct, &a.DistanceDiffPct, &a.GeometryDivergenceM, &a.Flagged, &a.Error, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		audits = append(audits, a)
+	}
+	return audits, rows.Err()
+}
@@ -0,0 +1,237 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/google/uuid"
+)
+
+const (
+	// Alternatives keep the provider's order until the user has picked this many times.
+	routeRankingMinChoices = 5
+	// routeChoiceAlpha is the weight of each new choice in the moving averages.
+	routeChoiceAlpha = 0.2
+	// How much extra travel time (as a share of the fastest) a full preference is worth:
+	// a user who always avoids motorways takes a motorway-free route up to 30% slower.
+	routeHighwayWeight    = 0.3
+	routeSimplicityWeight = 0.2
+	// Routes slower than the user's usual detour plus this slack are penalised twice over.
+	routeDetourSlack = 0.1
+	// Differences smaller than these don't teach anything about the user.
+	routeHighwayShareMinSpread = 0.1
+	routeManeuversMinSpread    = 3
+	routeChoiceTokenTTL        = 6 * time.Hour
+)
+
+// routeChoiceToken is the payload of DirectionsResponse.ChoiceToken: the ranked routes as
+// they were offered, so the pick can be learned from without storing every /route call.
+type routeChoiceToken struct {
+	UserID    uuid.UUID             `json:"u"`
+	Routes    []model.RouteFeatures `json:"r"`
+	ExpiresAt int64                 `json:"e"`
+}
+
+// routeFeatures summarises a route from its steps. A step counts as motorway when any of
+// its intersections is classed as one.
+func routeFeatures(route mapbox.Route) model.RouteFeatures {
+	f := model.RouteFeatures{DurationS: route.Duration, DistanceM: route.Distance}
+	var highwayM float64
+	for _, leg := range route.Legs {
+		for _, step := range leg.Steps {
+			f.Maneuvers++
+		classes:
+			for _, in := range step.Intersections {
+				for _, c := range in.Classes {
+					if c == "motorway" {
+						highwayM += step.Distance
+						break classes
+					}
+				}
+			}
+		}
+	}
+	if route.Distance > 0 {
+		f.HighwayShare = math.Min(highwayM/route.Distance, 1)
+	}
+	return f
+}
+
+func fastestRoute(features []model.RouteFeatures) int {
+	fastest := 0
+	for i, f := range features {
+		if f.DurationS < features[fastest].DurationS {
+			fastest = i
+		}
+	}
+	return fastest
+}
+
+// routeScore rates a route against the fastest one for a user's preferences; higher is better.
+func routeScore(f, fastest model.RouteFeatures, stats *model.RouteChoiceStats) float64 {
+	extra := 0.0
+	if fastest.DurationS > 0 {
+		extra = f.DurationS/fastest.DurationS - 1
+	}
+	score := -extra
+	if stats == nil {
+		return score
+	}
+	score += routeHighwayWeight * stats.HighwayAvoidance * (fastest.HighwayShare - f.HighwayShare)
+	if fastest.Maneuvers > 0 {
+		score += routeSimplicityWeight * stats.SimplicityPreference *
+			float64(fastest.Maneuvers-f.Maneuvers) / float64(fastest.Maneuvers)
+	}
+	if extra > stats.DetourTolerance+routeDetourSlack {
+		score -= extra
+	}
+	return score
+}
+
+// routeReason explains a route relative to the fastest in a few words.
+func routeReason(f, fastest model.RouteFeatures, isFastest, learned bool, stats *model.RouteChoiceStats) string {
+	if isFastest {
+		return "Fastest route"
+	}
+	var because string
+	switch {
+	case f.HighwayShare < fastest.HighwayShare-routeHighwayShareMinSpread:
+		because = "Fewer motorways"
+		if learned && stats.HighwayAvoidance > 0 {
+			because = "Avoids motorways, like you usually do"
+		}
+	case f.HighwayShare > fastest.HighwayShare+routeHighwayShareMinSpread:
+		because = "More motorway"
+		if learned && stats.HighwayAvoidance < 0 {
+			because = "Uses motorways, like you usually do"
+		}
+	case f.Maneuvers <= fastest.Maneuvers-routeManeuversMinSpread:
+		because = "Fewer turns"
+		if learned && stats.SimplicityPreference > 0 {
+			because = "Fewer turns, like you usually prefer"
+		}
+	default:
+		because = "Alternative route"
+	}
+	slower := math.Round((f.DurationS - fastest.DurationS) / 60)
+	if slower < 1 {
+		return because + ", about the same time"
+	}
+	return fmt.Sprintf("%s, %.0f min slower", because, slower)
+}
+
+// rankRouteAlternatives orders resp.Routes for the user and attaches a recommendation
+// reason to each. Until the user has made enough choices the provider's order is kept.
+// For signed-in users it also sets the choice token the app returns on navigation start.
+func (api *API) rankRouteAlternatives(ctx context.Context, resp *mapbox.DirectionsResponse, userID string) {
+	if resp == nil || len(resp.Routes) < 2 {
+		return
+	}
+	var stats *model.RouteChoiceStats
+	uid, uidErr := uuid.Parse(userID)
+	if uidErr == nil && api.dbAvailable() {
+		var err error
+		if stats, err = api.GetRouteChoiceStatsRepo(ctx, uid); err != nil {
+			api.logger().WarnContext(ctx, "Failed to load route choice stats", "err", err)
+		}
+	}
+	learned := stats != nil && stats.Choices >= routeRankingMinChoices
+
+	features := make([]model.RouteFeatures, len(resp.Routes))
+	for i, route := range resp.Routes {
+		features[i] = routeFeatures(route)
+	}
+	fastest := fastestRoute(features)
+
+	order := make([]int, len(resp.Routes))
+	for i := range order {
+		order[i] = i
+	}
+	if learned {
+		scores := make([]float64, len(features))
+		for i, f := range features {
+			scores[i] = routeScore(f, features[fastest], stats)
+		}
+		sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	}
+
+	routes := make([]mapbox.Route, len(order))
+	ranked := make([]model.RouteFeatures, len(order))
+	for rank, i := range order {
+		routes[rank] = resp.Routes[i]
+		ranked[rank] = features[i]
+		reason := routeReason(features[i], features[fastest], i == fastest, learned, stats)
+		if rank == 0 && learned && i == fastest {
+			reason = "Fastest route, and it fits how you usually drive"
+		}
+		routes[rank].Recommendation = &mapbox.RouteRecommendation{Rank: rank + 1, Reason: reason}
+	}
+	resp.Routes = routes
+
+	if uidErr == nil {
+		payload, err := json.Marshal(routeChoiceToken{
+			UserID:    uid,
+			Routes:    ranked,
+			ExpiresAt: time.Now().Add(routeChoiceTokenTTL).Unix(),
+		})
+		if err == nil {
+			resp.ChoiceToken = util.SignToken(api.Config.JwtSecret, payload)
+		}
+	}
+}
+
+// routeChoiceSignal reads the user's preferences off which of the offered routes they took.
+func routeChoiceSignal(offered []model.RouteFeatures, chosen int) model.RouteChoiceSignal {
+	c := offered[chosen]
+	fastest := offered[fastestRoute(offered)]
+
+	var signal model.RouteChoiceSignal
+	if fastest.DurationS > 0 {
+		signal.DetourShare = math.Max(c.DurationS/fastest.DurationS-1, 0)
+	}
+
+	minHW, maxHW := c.HighwayShare, c.HighwayShare
+	minM, maxM := c.Maneuvers, c.Maneuvers
+	for _, f := range offered {
+		minHW, maxHW = math.Min(minHW, f.HighwayShare), math.Max(maxHW, f.HighwayShare)
+		minM, maxM = min(minM, f.Maneuvers), max(maxM, f.Maneuvers)
+	}
+	// Map the pick onto -1 (the most of it) .. 1 (the least of it).
+	if maxHW-minHW >= routeHighwayShareMinSpread {
+		v := (maxHW-c.HighwayShare)/(maxHW-minHW)*2 - 1
+		signal.HighwayAvoidance = &v
+	}
+	if maxM-minM >= routeManeuversMinSpread {
+		v := float64(maxM-c.Maneuvers)/float64(maxM-minM)*2 - 1
+		signal.SimplicityPreference = &v
+	}
+	return signal
+}
+
+// recordRouteChoice learns from the alternative the user started navigating. Bad or
+// expired tokens are ignored: the choice is a hint, not something to fail navigation over.
+func (api *API) recordRouteChoice(ctx context.Context, userID uuid.UUID, token string, index int) {
+	payload, err := util.OpenToken(api.Config.JwtSecret, token)
+	if err != nil {
+		api.logger().InfoContext(ctx, "Ignoring route choice", "err", err)
+		return
+	}
+	var t routeChoiceToken
+	if err := json.Unmarshal(payload, &t); err != nil {
+		api.logger().InfoContext(ctx, "Ignoring route choice", "err", err)
+		return
+	}
+	if t.UserID != userID || time.Now().Unix() > t.ExpiresAt || index < 0 || index >= len(t.Routes) {
+		return
+	}
+	if err := api.RecordRouteChoiceRepo(ctx, userID, routeChoiceSignal(t.Routes, index), routeChoiceAlpha); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to record route choice", "err", err)
+	}
+}
@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// GetRouteChoiceStatsRepo returns the user's learned route preferences, or nil if they
+// have never picked an alternative.
+func (api *API) GetRouteChoiceStatsRepo(ctx context.Context, userID uuid.UUID) (*model.RouteChoiceStats, error) {
+	s := model.RouteChoiceStats{UserID: userID}
+	err := api.DB.QueryRow(ctx, `
+        SELECT choices, highway_avoidance, simplicity_preference, detour_tolerance
+        FROM user_route_choice_stats
+        WHERE user_id = $1
+    `, userID).Scan(&s.Choices, &s.HighwayAvoidance, &s.SimplicityPreference, &s.DetourTolerance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RecordRouteChoiceRepo folds one choice into the user's moving averages with weight alpha.
+// Axes without a signal keep their value.
+func (api *API) RecordRouteChoiceRepo(ctx context.Context, userID uuid.UUID, signal model.RouteChoiceSignal, alpha float64) error {
+	_, err := api.DB.Exec(ctx, `
+        INSERT INTO user_route_choice_stats AS s
+            (user_id, choices, highway_avoidance, simplicity_preference, detour_tolerance)
+        VALUES ($1, 1, COALESCE($2::float8, 0) * $5, COALESCE($3::float8, 0) * $5, $4::float8 * $5)
+        ON CONFLICT (user_id) DO UPDATE SET
+            choices = s.choices + 1,
+            highway_avoidance = CASE WHEN $2::float8 IS NULL THEN s.highway_avoidance
+                ELSE s.highway_avoidance + $5 * ($2::float8 - s.highway_avoidance) END,
+            simplicity_preference = CASE WHEN $3::float8 IS NULL THEN s.simplicity_preference
+                ELSE s.simplicity_preference + $5 * ($3::float8 - s.simplicity_preference) END,
+            detour_tolerance = s.detour_tolerance + $5 * ($4::float8 - s.detour_tolerance),
+            updated_at = NOW()
+    `, userID, signal.HighwayAvoidance, signal.SimplicityPreference, signal.DetourShare, alpha)
+	return err
+}
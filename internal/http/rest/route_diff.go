@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"math"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+// samePointDeg is how close two coordinates must be to count as the same point. Stored
+// routes round-trip through PostGIS, so exact equality is too strict.
+const samePointDeg = 1e-7
+
+// diffRoute expresses next as a RouteDiff against the route the app has at baseVersion.
+// A reroute usually rejoins the old route, so the stretch they share at the end is kept
+// and only the new head is sent. When prevSteps is unknown all of next's steps are sent.
+func diffRoute(prevGeometry [][]float64, prevSteps []model.DrivingStep, next model.DrivingRoute, baseVersion int) model.RouteDiff {
+	keptPoints := commonSuffix(len(prevGeometry), len(next.Geometry), func(i, j int) bool {
+		return samePoint(prevGeometry[i], next.Geometry[j])
+	})
+	keptSteps := commonSuffix(len(prevSteps), len(next.Steps), func(i, j int) bool {
+		return prevSteps[i] == next.Steps[j]
+	})
+	return model.RouteDiff{
+		BaseVersion:   baseVersion,
+		DurationS:     next.DurationS,
+		DistanceM:     next.DistanceM,
+		Summary:       next.Summary,
+		ETA:           next.ETA,
+		Geometry:      next.Geometry[:len(next.Geometry)-keptPoints],
+		KeepFromPoint: len(prevGeometry) - keptPoints,
+		Steps:         next.Steps[:len(next.Steps)-keptSteps],
+		KeepFromStep:  len(prevSteps) - keptSteps,
+	}
+}
+
+// commonSuffix counts how many trailing elements two sequences of lengths m and n share.
+func commonSuffix(m, n int, equal func(i, j int) bool) int {
+	k := 0
+	for k < m && k < n && equal(m-1-k, n-1-k) {
+		k++
+	}
+	return k
+}
+
+func samePoint(a, b []float64) bool {
+	return len(a) >= 2 && len(b) >= 2 &&
+		math.Abs(a[0]-b[0]) <= samePointDeg && math.Abs(a[1]-b[1]) <= samePointDeg
+}
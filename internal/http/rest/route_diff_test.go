@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestDiffRoute(t *testing.T) {
+	prevGeometry := [][]float64{{33.30, 35.10}, {33.31, 35.10}, {33.32, 35.11}, {33.33, 35.12}}
+	prevSteps := []model.DrivingStep{
+		{Instruction: "Head east", Type: "depart", DistanceM: 900},
+		{Instruction: "Turn left onto Atatürk Cd.", Type: "turn", Modifier: "left", DistanceM: 1500},
+		{Instruction: "You have arrived", Type: "arrive"},
+	}
+	next := model.DrivingRoute{
+		DurationS: 240,
+		DistanceM: 2100,
+		// Rejoins the old route at its third point, within rounding.
+		Geometry: [][]float64{{33.305, 35.105}, {33.32 + 1e-9, 35.11}, {33.33, 35.12}},
+		Steps: []model.DrivingStep{
+			{Instruction: "Head north", Type: "depart", DistanceM: 600},
+			{Instruction: "Turn left onto Atatürk Cd.", Type: "turn", Modifier: "left", DistanceM: 1500},
+			{Instruction: "You have arrived", Type: "arrive"},
+		},
+	}
+
+	diff := diffRoute(prevGeometry, prevSteps, next, 3)
+	if diff.BaseVersion != 3 || diff.DurationS != 240 || diff.DistanceM != 2100 {
+		t.Errorf("diff = %+v, want the new route's totals against version 3", diff)
+	}
+	if len(diff.Geometry) != 1 || diff.KeepFromPoint != 2 {
+		t.Errorf("geometry = %v keep from %d, want 1 new point then old points from 2", diff.Geometry, diff.KeepFromPoint)
+	}
+	if len(diff.Steps) != 1 || diff.Steps[0].Instruction != "Head north" || diff.KeepFromStep != 1 {
+		t.Errorf("steps = %+v keep from %d, want the new depart then old steps from 1", diff.Steps, diff.KeepFromStep)
+	}
+
+	// Steps of a route the app planned itself aren't known: all new steps are sent.
+	diff = diffRoute(prevGeometry, nil, next, 3)
+	if len(diff.Steps) != len(next.Steps) || diff.KeepFromStep != 0 {
+		t.Errorf("unknown old steps: steps = %d keep from %d, want all steps and none kept", len(diff.Steps), diff.KeepFromStep)
+	}
+
+	// A route that shares nothing keeps nothing.
+	diff = diffRoute([][]float64{{33.0, 35.0}, {33.1, 35.0}}, prevSteps[:1], next, 3)
+	if len(diff.Geometry) != len(next.Geometry) || diff.KeepFromPoint != 2 || diff.KeepFromStep != 1 {
+		t.Errorf("disjoint: %+v, want the whole route and nothing kept", diff)
+	}
+}
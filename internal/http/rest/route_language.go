@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"html"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+)
+
+// Instruction languages are picked from RouteRequest.Language, else the caller's
+// Accept-Language. Turkish and Greek are what the app needs besides English: Valhalla
+// narrates all three, while Mapbox has no Greek, so Greek Mapbox routes are fetched in
+// English and their maneuvers translated from templates.
+
+// valhallaLanguage is the narrative language Valhalla is asked for. Supported languages
+// get their full locale; others are passed on for Valhalla to handle.
+func valhallaLanguage(lang string) string {
+	if lang == "" || valhalla.NormalizeLanguage(lang) != valhalla.DefaultLanguage {
+		return valhalla.NarrativeLocale(lang)
+	}
+	return lang
+}
+
+// mapboxLanguage is the instruction language Mapbox is asked for, and whether the
+// response must be translated into lang afterwards.
+func mapboxLanguage(lang string) (string, bool) {
+	switch {
+	case lang == "":
+		return valhalla.DefaultLanguage, false
+	case valhalla.NormalizeLanguage(lang) == valhalla.LanguageGreek:
+		return valhalla.DefaultLanguage, true
+	}
+	return lang, false
+}
+
+// translateDirections rewrites step instructions and voice announcements in lang from
+// the maneuver templates. A step's voice instructions announce the next step's maneuver.
+// Steps whose maneuver has no template keep the provider's text.
+func translateDirections(resp *mapbox.DirectionsResponse, lang string) {
+	if resp == nil {
+		return
+	}
+	for r := range resp.Routes {
+		for l := range resp.Routes[r].Legs {
+			steps := resp.Routes[r].Legs[l].Steps
+			instructions := make([]string, len(steps))
+			for i := range steps {
+				if text, ok := valhalla.ManeuverInstruction(lang, osrmManeuverType(steps[i].Maneuver), steps[i].Name); ok {
+					steps[i].Maneuver.Instruction = text
+					instructions[i] = text
+				}
+			}
+			for i := range steps {
+				next := i
+				if steps[i].Maneuver.Type != "arrive" && i+1 < len(steps) {
+					next = i + 1
+				}
+				if instructions[next] == "" {
+					continue
+				}
+				// Mapbox measures a voice instruction's distance back from the maneuver.
+				for v := range steps[i].VoiceInstructions {
+					vi := &steps[i].VoiceInstructions[v]
+					vi.Announcement = valhalla.ManeuverAnnouncement(lang, instructions[next], vi.DistanceAlongGeometry)
+					vi.SSMLAnnouncement = `<speak><amazon:effect name="drc"><prosody rate="1.08">` + html.EscapeString(vi.Announcement) + `</prosody></amazon:effect></speak>`
+				}
+			}
+		}
+	}
+}
+
+// osrmManeuverType maps an OSRM maneuver type and modifier to the maneuver type names
+// the templates are keyed by (see util.MapValhallaManeuverType).
+func osrmManeuverType(m mapbox.Maneuver) string {
+	left := strings.Contains(m.Modifier, "left")
+	switch m.Type {
+	case "depart":
+		return "Start"
+	case "arrive":
+		switch m.Modifier {
+		case "left", "slight left", "sharp left":
+			return "DestinationLeft"
+		case "right", "slight right", "sharp right":
+			return "DestinationRight"
+		}
+		return "Destination"
+	case "merge":
+		return "Merge"
+	case "on ramp":
+		if left {
+			return "RampLeft"
+		}
+		return "RampRight"
+	case "off ramp":
+		if left {
+			return "ExitLeft"
+		}
+		return "ExitRight"
+	case "fork":
+		switch {
+		case left:
+			return "StayLeft"
+		case strings.Contains(m.Modifier, "right"):
+			return "StayRight"
+		}
+		return "Continue"
+	case "roundabout", "rotary":
+		return "RoundaboutEnter"
+	case "exit roundabout", "exit rotary":
+		return "RoundaboutExit"
+	}
+	switch m.Modifier {
+	case "uturn":
+		return "UturnLeft"
+	case "sharp right":
+		return "SharpRight"
+	case "right":
+		return "Right"
+	case "slight right":
+		return "SlightRight"
+	case "slight left":
+		return "SlightLeft"
+	case "left":
+		return "Left"
+	case "sharp left":
+		return "SharpLeft"
+	}
+	return "Continue"
+}
@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+)
+
+func TestLanguageSelection(t *testing.T) {
+	if got := valhallaLanguage("tr"); got != "tr-TR" {
+		t.Errorf("valhallaLanguage(tr) = %q, want tr-TR", got)
+	}
+	if got := valhallaLanguage(""); got != "en-US" {
+		t.Errorf("valhallaLanguage(\"\") = %q, want en-US", got)
+	}
+	if lang, translate := mapboxLanguage("el-GR"); lang != "en" || !translate {
+		t.Errorf("mapboxLanguage(el-GR) = %q, %v; want en, true", lang, translate)
+	}
+	if lang, translate := mapboxLanguage("tr"); lang != "tr" || translate {
+		t.Errorf("mapboxLanguage(tr) = %q, %v; want tr, false", lang, translate)
+	}
+}
+
+func TestOSRMManeuverType(t *testing.T) {
+	tests := []struct {
+		m    mapbox.Maneuver
+		want string
+	}{
+		{mapbox.Maneuver{Type: "depart"}, "Start"},
+		{mapbox.Maneuver{Type: "turn", Modifier: "slight left"}, "SlightLeft"},
+		{mapbox.Maneuver{Type: "end of road", Modifier: "right"}, "Right"},
+		{mapbox.Maneuver{Type: "off ramp", Modifier: "slight left"}, "ExitLeft"},
+		{mapbox.Maneuver{Type: "fork", Modifier: "straight"}, "Continue"},
+		{mapbox.Maneuver{Type: "arrive", Modifier: "right"}, "DestinationRight"},
+		{mapbox.Maneuver{Type: "continue", Modifier: "uturn"}, "UturnLeft"},
+	}
+	for _, tt := range tests {
+		if got := osrmManeuverType(tt.m); got != tt.want {
+			t.Errorf("osrmManeuverType(%+v) = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateDirections(t *testing.T) {
+	resp := &mapbox.DirectionsResponse{Routes: []mapbox.Route{{Legs: []mapbox.Leg{{Steps: []mapbox.Step{
+		{
+			Maneuver:          mapbox.Maneuver{Type: "depart", Instruction: "Head north"},
+			VoiceInstructions: []mapbox.VoiceInstruction{{DistanceAlongGeometry: 300, Announcement: "In 300 meters, turn left"}},
+		},
+		{Name: "Λεωφόρος Μακαρίου", Maneuver: mapbox.Maneuver{Type: "turn", Modifier: "left", Instruction: "Turn left onto Makariou"}},
+		{Maneuver: mapbox.Maneuver{Type: "arrive", Instruction: "You have arrived"}},
+	}}}}}}
+
+	translateDirections(resp, "el")
+	steps := resp.Routes[0].Legs[0].Steps
+	if got, want := steps[1].Maneuver.Instruction, "Στρίψτε αριστερά προς Λεωφόρος Μακαρίου"; got != want {
+		t.Errorf("instruction = %q, want %q", got, want)
+	}
+	vi := steps[0].VoiceInstructions[0]
+	if want := "Σε 300 μ., στρίψτε αριστερά προς Λεωφόρος Μακαρίου"; vi.Announcement != want {
+		t.Errorf("announcement = %q, want %q", vi.Announcement, want)
+	}
+	if !strings.Contains(vi.SSMLAnnouncement, vi.Announcement) {
+		t.Errorf("ssml %q does not carry the announcement", vi.SSMLAnnouncement)
+	}
+}
@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/google/uuid"
+)
+
+const (
+	// The radius is capped at 500 m by RouteRequest's validate tag.
+	defaultRouteReportRadiusM = 50
+	// maxRouteReports caps the reports attached to one route.
+	maxRouteReports = 50
+)
+
+// defaultRouteReportTypes are the reports worth drawing on a planned route.
+var defaultRouteReportTypes = []string{"POLICE", "ACCIDENT", "HAZARD", "ROAD_CLOSED"}
+
+// addRouteReports attaches the active reports near each route so the app can draw them
+// without a second request. It never fails the request; lookup errors just leave the
+// routes without reports.
+func (api *API) addRouteReports(ctx context.Context, resp *mapbox.DirectionsResponse, req RouteRequest, userID string) {
+	if resp == nil || req.SkipReports || !api.dbAvailable() {
+		return
+	}
+	radiusM := req.ReportRadiusM
+	if radiusM == 0 {
+		radiusM = defaultRouteReportRadiusM
+	}
+	types := req.ReportTypes
+	if len(types) == 0 {
+		types = defaultRouteReportTypes
+	}
+	var viewerID *uuid.UUID
+	if id, err := uuid.Parse(userID); err == nil {
+		viewerID = &id
+	}
+
+	for i := range resp.Routes {
+		route := &resp.Routes[i]
+		if len(route.Geometry.Coordinates) < 2 {
+			continue
+		}
+		reports, err := api.GetReportsAlongRouteRepo(ctx, route.Geometry.Coordinates, radiusM, types, viewerID, maxRouteReports)
+		if err != nil {
+			api.logger().WarnContext(ctx, "Route reports unavailable", "err", err)
+			return
+		}
+		route.Reports = reports
+	}
+}
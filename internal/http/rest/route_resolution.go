@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/util"
+)
+
+// Route geometry resolutions (?resolution= or "resolution" on /route).
+const (
+	RouteResolutionLow    = "low"
+	RouteResolutionMedium = "medium"
+	RouteResolutionFull   = "full"
+)
+
+// routeResolutionToleranceM is how far, in meters, a simplified line may stray from the
+// full geometry: low is enough for an overview map, medium for following the route.
+var routeResolutionToleranceM = map[string]float64{
+	RouteResolutionLow:    25,
+	RouteResolutionMedium: 5,
+}
+
+// simplifyRouteGeometry reduces the route and step geometries to the given resolution.
+// Leg annotations hold a value per original coordinate, so they are dropped from
+// simplified routes. Full (or empty) resolution leaves the response untouched.
+func simplifyRouteGeometry(resp *mapbox.DirectionsResponse, resolution string) {
+	toleranceM, ok := routeResolutionToleranceM[resolution]
+	if !ok {
+		return
+	}
+	for i := range resp.Routes {
+		simplifyRoute(&resp.Routes[i], toleranceM)
+	}
+	if resp.WalkingLeg != nil {
+		simplifyRoute(&resp.WalkingLeg.Route, toleranceM)
+	}
+}
+
+func simplifyRoute(route *mapbox.Route, toleranceM float64) {
+	route.Geometry.Coordinates = util.SimplifyLine(route.Geometry.Coordinates, toleranceM)
+	for l := range route.Legs {
+		leg := &route.Legs[l]
+		leg.Annotation = nil
+		for s := range leg.Steps {
+			leg.Steps[s].Geometry.Coordinates = util.SimplifyLine(leg.Steps[s].Geometry.Coordinates, toleranceM)
+		}
+	}
+}
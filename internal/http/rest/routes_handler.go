@@ -3,12 +3,13 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
 	"github.com/bwise1/waze_kibris/util"
 	"github.com/bwise1/waze_kibris/util/tracing"
 	"github.com/bwise1/waze_kibris/util/values"
@@ -20,8 +21,27 @@ func (api *API) RoutingRoutes() chi.Router {
 
 	mux.Group(func(r chi.Router) {
 		// r.Use(api.RequireLogin)
+		// Login is optional; anonymous callers are counted per IP on the free tier.
+		r.Use(api.RateLimit(RateLimitDirections))
+		r.Use(api.EnforceQuota(QuotaDirections))
+		// Add ?format=geojson for a FeatureCollection with a LineString per route
 		r.Method(http.MethodPost, "/", Handler(api.GetRouteHandler))
 		r.Method(http.MethodPost, "/enhanced", Handler(api.GetRouteHandler)) // Alias for enhanced navigation
+		// Areas reachable within each time, as GeoJSON polygons (largest first)
+		// Query Params: ?lat=&lon= (required), ?minutes=5,10,15 (default 15, up to 4, max 60 each),
+		// ?profile=driving|walking|cycling|motorcycle, ?preference=fastest|safe,
+		// ?exclude=toll,ferry,motorway,unpaved (driving only)
+		r.Method(http.MethodGet, "/isochrone", Handler(api.GetIsochroneHandler))
+	})
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RateLimit(RateLimitDirections))
+		r.Use(api.EnforceQuota(QuotaMatrix))
+		// Travel time and distance from each source to each target, to rank saved places
+		// or parking options by drive time
+		// Request Body: { "sources": [{ "lat": 35.17, "lng": 33.36 }], "targets": [...],
+		//   "profile": "driving", "preference": "fastest" }; at most 100 pairs
+		r.Method(http.MethodPost, "/matrix", Handler(api.GetMatrixHandler))
 	})
 
 	return mux
@@ -35,17 +55,37 @@ type Location struct {
 
 // RouteRequest represents the request payload for route calculation
 type RouteRequest struct {
-	Locations          []Location `json:"locations"`
-	Profile            string     `json:"profile,omitempty"` // "driving", "driving-traffic", "walking", "cycling"
+	Locations          []Location `json:"locations" validate:"min=2"`
+	Profile            string     `json:"profile,omitempty"` // "driving", "driving-traffic", "walking", "cycling", "motorcycle"
 	Alternatives       bool       `json:"alternatives,omitempty"`
 	VoiceInstructions  bool       `json:"voice_instructions,omitempty"`
 	BannerInstructions bool       `json:"banner_instructions,omitempty"`
-	VoiceUnits         string     `json:"voice_units,omitempty"` // "metric" or "imperial"
-	Language           string     `json:"language,omitempty"`    // "en", "es", etc.
+	VoiceUnits         string     `json:"voice_units,omitempty" validate:"omitempty,oneof=metric imperial"`
+	Language           string     `json:"language,omitempty"` // "en", "tr", "el", etc.; defaults to Accept-Language
 	RoundaboutExits    bool       `json:"roundabout_exits,omitempty"`
 	WaypointNames      bool       `json:"waypoint_names,omitempty"`
 	Approaches         string     `json:"approaches,omitempty"` // "unrestricted", "curb", etc.
 	Exclude            string     `json:"exclude,omitempty"`    // "toll", "ferry", "motorway"
+	// Preference is "fastest" or "safe" (lit main roads over dark shortcuts). Empty falls
+	// back to the signed-in user's default.
+	Preference string `json:"preference,omitempty" validate:"omitempty,oneof=fastest safe"`
+	// DestinationPlaceID is the geocoder gid of the last location, used to pick its arrival point.
+	DestinationPlaceID string `json:"destination_place_id,omitempty"`
+	// SkipReports leaves out the active reports along each route. ReportRadiusM (default
+	// 50, max 500) and ReportTypes (default police, accidents, hazards and closures)
+	// choose which reports are included.
+	SkipReports   bool     `json:"skip_reports,omitempty"`
+	ReportRadiusM int      `json:"report_radius_m,omitempty" validate:"min=0,max=500"`
+	ReportTypes   []string `json:"report_types,omitempty"`
+	// Resolution simplifies the returned geometries: "low" (within 25 m, for overview
+	// maps), "medium" (within 5 m) or "full" (default). Also accepted as ?resolution=.
+	Resolution string `json:"resolution,omitempty" validate:"omitempty,oneof=low medium full"`
+	// RoadControls adds the traffic signals and stop signs passed on each step. Only
+	// Valhalla routes carry them, and they cost an extra lookup per leg.
+	RoadControls bool `json:"road_controls,omitempty"`
+
+	// closures are the active road closures around the trip, nil until loaded.
+	closures []roadClosure
 }
 
 func (api *API) GetRouteHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
@@ -54,78 +94,273 @@ func (api *API) GetRouteHandler(_ http.ResponseWriter, r *http.Request) *ServerR
 	// Parse request parameters
 	var req RouteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request body: %v", err)
+		api.logger().InfoContext(r.Context(), "Invalid route request body", "err", err)
 		return respondWithError(err, "Invalid request payload", values.BadRequestBody, &tc)
 	}
+	if req.Resolution == "" {
+		req.Resolution = r.URL.Query().Get("resolution")
+	}
 
-	if req.Locations == nil || len(req.Locations) < 2 {
-		log.Printf("No locations provided or insufficient locations")
-		return respondWithError(nil, "At least 2 locations required", values.BadRequestBody, &tc)
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+	for i, loc := range req.Locations {
+		lat, lng, err := util.NormalizeCoordinate(loc.Lat, loc.Lng)
+		if err != nil {
+			return respondWithError(err, fmt.Sprintf("location %d: invalid latitude or longitude", i), values.BadRequestBody, &tc)
+		}
+		req.Locations[i] = Location{Lat: lat, Lng: lng}
 	}
 
 	// Set defaults
 	if req.Profile == "" {
 		req.Profile = "driving" // Use basic driving profile for lane guidance support
 	}
+	if req.Language == "" {
+		req.Language = requestLanguage(r)
+	}
+
+	userID := api.routeUserID(r)
+	preference := req.Preference
+	if preference == "" {
+		preference = api.defaultRoutePreference(r, userID)
+	}
+	if preference != values.RoutePreferenceFastest && preference != values.RoutePreferenceSafe {
+		return respondWithError(nil, "preference must be fastest or safe", values.BadRequestBody, &tc)
+	}
+	for i, t := range req.ReportTypes {
+		req.ReportTypes[i] = strings.ToUpper(t)
+	}
 
-	// Use existing Mapbox client
-	if api.MapboxClient == nil {
-		return respondWithError(nil, "Mapbox client not configured", values.SystemErr, &tc)
+	// Drive to the destination's entrance or parking rather than its centroid; the rest is
+	// reported as a walk. Walking routes already end at the requested point.
+	destination := req.Locations[len(req.Locations)-1]
+	var arrival *mapbox.ArrivalPoint
+	if req.Profile != "walking" {
+		arrival = api.resolveArrivalPoint(r.Context(), req)
+	}
+	if arrival != nil {
+		req.Locations = append(req.Locations[:len(req.Locations)-1:len(req.Locations)-1],
+			Location{Lat: arrival.Latitude, Lng: arrival.Longitude})
 	}
 
-	// Convert locations to coordinate strings in Mapbox format (lng,lat)
-	coordinates := make([]string, len(req.Locations))
-	for i, loc := range req.Locations {
-		coordinates[i] = fmt.Sprintf("%s,%s",
-			strconv.FormatFloat(loc.Lng, 'f', 6, 64),
-			strconv.FormatFloat(loc.Lat, 'f', 6, 64))
-	}
-
-	// Configure navigation options
-	navOptions := &mapbox.NavigationOptions{
-		VoiceInstructions:  req.VoiceInstructions,
-		BannerInstructions: req.BannerInstructions,
-		VoiceUnits:         req.VoiceUnits,
-		Language:           req.Language,
-		RoundaboutExits:    req.RoundaboutExits,
-		WaypointNames:      req.WaypointNames,
-		Approaches:         req.Approaches,
-		Exclude:            req.Exclude,
-	}
-
-	// Set defaults if not specified
-	if navOptions.VoiceUnits == "" {
-		navOptions.VoiceUnits = "metric"
-	}
-	if navOptions.Language == "" {
-		navOptions.Language = "en"
-	}
-	// Enable voice and banner instructions by default
-	if !req.VoiceInstructions && !req.BannerInstructions {
-		navOptions.VoiceInstructions = true
-		navOptions.BannerInstructions = true
-		navOptions.RoundaboutExits = true
-		// Only enable waypoint_names if explicitly requested
-		// navOptions.WaypointNames = true
-	}
-
-	// Fetch the route with enhanced navigation features
-	routeResponse, err := api.MapboxClient.DirectionsWithNavigation(
-		context.TODO(),
-		coordinates,
-		req.Profile,
-		req.Alternatives,
-		navOptions,
-	)
+	if req.Profile != "walking" {
+		req.closures = api.tripRoadClosures(r.Context(), req.Locations)
+	}
+
+	routeResponse, provider, err := api.routeWithFallback(r.Context(), req, preference)
 	if err != nil {
-		log.Printf("Error fetching Mapbox route: %v", err)
-		return respondWithError(err, "Failed to calculate route", values.SystemErr, &tc)
+		api.logger().ErrorContext(r.Context(), "Route failed on every provider", "preference", preference, "profile", req.Profile, "err", err)
+		if errors.Is(err, ErrNoRoute) {
+			return respondWithError(err, "No route found between these locations", errorStatus(err, values.Error), &tc)
+		}
+		return respondWithError(err, "Failed to calculate route", errorStatus(err, values.Error), &tc)
+	}
+	routeResponse.Provider = provider
+	routeResponse.Arrival = arrival
+	api.addArrivalWindows(r.Context(), routeResponse)
+	api.addReportDelays(r.Context(), routeResponse)
+	api.maybeAuditRoute(req, preference, provider, routeResponse)
+	api.rankRouteAlternatives(r.Context(), routeResponse, userID)
+	api.addRouteReports(r.Context(), routeResponse, req, userID)
+	if req.Profile != "walking" {
+		api.addRouteMapFeatures(r.Context(), routeResponse)
+		api.addWalkingLeg(r.Context(), routeResponse, req, destination)
 	}
+	simplifyRouteGeometry(routeResponse, req.Resolution)
 
 	return &ServerResponse{
-		Message:    "Routes retrieved successfully with enhanced navigation data",
+		Message:    "Routes retrieved successfully",
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
 		Data:       routeResponse,
 	}
 }
+
+const profileMotorcycle = "motorcycle"
+
+// Hazards a motorcycle route steers around: the two-wheeler subtypes always, any other
+// hazard from this severity up.
+var twoWheelerHazardSubtypes = []string{"GRAVEL", "OIL_SLICK"}
+
+const (
+	twoWheelerHazardMinSeverity = 4
+	// Valhalla's default max_exclude_locations is 50.
+	maxHazardExclusions = 50
+	// hazardSearchPaddingDeg pads the bounding box of the route's locations (~5 km).
+	hazardSearchPaddingDeg = 0.05
+)
+
+// twoWheelerHazardExclusions returns the active hazards around the trip as Valhalla
+// exclude_locations. Errors are logged and just mean no hazards are avoided.
+func (api *API) twoWheelerHazardExclusions(ctx context.Context, locations []Location) []valhalla.Location {
+	if !api.dbAvailable() {
+		return nil
+	}
+	minLat, minLng, maxLat, maxLng := tripBounds(locations, hazardSearchPaddingDeg)
+	hazards, err := api.GetRouteHazardsRepo(ctx, minLat, minLng, maxLat, maxLng,
+		twoWheelerHazardSubtypes, twoWheelerHazardMinSeverity, maxHazardExclusions,
+	)
+	if err != nil {
+		api.logger().WarnContext(ctx, "Failed to load hazards for motorcycle route", "err", err)
+		return nil
+	}
+	exclusions := make([]valhalla.Location, len(hazards))
+	for i, h := range hazards {
+		exclusions[i] = valhalla.Location{Lat: h[1], Lon: h[0]}
+	}
+	return exclusions
+}
+
+// routeUserID returns the signed-in caller's user ID. /route doesn't require login, so a
+// missing or invalid token just gives "".
+func (api *API) routeUserID(r *http.Request) string {
+	authorization := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(authorization) != 2 || authorization[0] != "Bearer" {
+		return ""
+	}
+	claims, err := api.verifyToken(authorization[1], false)
+	if err != nil {
+		return ""
+	}
+	return claims.UserID
+}
+
+// defaultRoutePreference returns the signed-in user's saved preference, or "fastest".
+func (api *API) defaultRoutePreference(r *http.Request, userID string) string {
+	if userID == "" || !api.dbAvailable() {
+		return values.RoutePreferenceFastest
+	}
+	user, err := api.GetUserByID(r.Context(), userID)
+	if err != nil || user.RoutePreference == "" {
+		return values.RoutePreferenceFastest
+	}
+	return user.RoutePreference
+}
+
+// valhallaCosting maps a /route profile to its Valhalla costing model.
+func valhallaCosting(profile string) string {
+	switch profile {
+	case "walking":
+		return "pedestrian"
+	case "cycling":
+		return "bicycle"
+	case profileMotorcycle:
+		return "motorcycle"
+	}
+	return "auto"
+}
+
+// valhallaCostingOptions returns the costing options /route uses for a costing model and
+// preference, plus the excluded road types (comma separated: toll, ferry, motorway,
+// unpaved), which only driving supports.
+func valhallaCostingOptions(costing, preference, exclude string) (*valhalla.CostingOptions, error) {
+	var options *valhalla.CostingOptions
+	switch {
+	case preference == values.RoutePreferenceSafe:
+		options = valhalla.SafeCostingOptions(costing)
+	case costing == "motorcycle":
+		options = valhalla.DefaultMotorcycleCostingOptions()
+	}
+	if strings.TrimSpace(exclude) == "" {
+		return options, nil
+	}
+	if costing != "auto" {
+		return nil, errors.New("exclude is only supported for driving")
+	}
+	if options == nil {
+		options = &valhalla.CostingOptions{}
+	}
+	if options.Auto == nil {
+		options.Auto = &valhalla.AutoCostingOptions{}
+	}
+	avoid := true
+	for _, e := range strings.Split(exclude, ",") {
+		switch strings.ToLower(strings.TrimSpace(e)) {
+		case "toll":
+			options.Auto.AvoidTolls = &avoid
+		case "ferry":
+			options.Auto.AvoidFerry = &avoid
+		case "motorway":
+			options.Auto.AvoidHighways = &avoid
+		case "unpaved":
+			options.Auto.AvoidUnpaved = &avoid
+		default:
+			return nil, fmt.Errorf("unknown exclude %q", e)
+		}
+	}
+	return options, nil
+}
+
+// getValhallaRoute routes through our Valhalla instance, the only provider with the safe
+// preference (main roads, cycleways) and the motorcycle profile. Valhalla answers in the OSRM format so clients get the
+// same shape as from Mapbox. Vehicle routes steer around active road closures. Safe
+// vehicle routes are also ranked by street lighting: Valhalla's vehicle costing can't
+// weigh it, so alternatives are always asked for and scored against the lit_ways import.
+func (api *API) getValhallaRoute(ctx context.Context, req RouteRequest, preference string) (*mapbox.DirectionsResponse, error) {
+	if api.ValhallaClient == nil {
+		return nil, fmt.Errorf("valhalla client not configured")
+	}
+
+	costing := valhallaCosting(req.Profile)
+	locations := make([]valhalla.Location, len(req.Locations))
+	for i, loc := range req.Locations {
+		locations[i] = valhalla.Location{Lat: loc.Lat, Lon: loc.Lng}
+	}
+
+	voice, banner := true, true
+	if req.VoiceInstructions || req.BannerInstructions {
+		voice, banner = req.VoiceInstructions, req.BannerInstructions
+	}
+	language := valhallaLanguage(req.Language)
+	units := "kilometers"
+	if req.VoiceUnits == "imperial" {
+		units = "miles"
+	}
+
+	vreq := valhalla.RouteRequest{
+		Locations:          locations,
+		Costing:            costing,
+		Language:           &language,
+		Units:              &units,
+		VoiceInstructions:  &voice,
+		BannerInstructions: &banner,
+	}
+	litRanked := preference == values.RoutePreferenceSafe && costing != "pedestrian"
+	if req.Alternatives || litRanked {
+		alternates := 2
+		vreq.Alternates = &alternates
+	}
+	vreq.CostingOptions, _ = valhallaCostingOptions(costing, preference, "")
+	if costing != "pedestrian" {
+		closures := req.closures
+		if closures == nil {
+			closures = api.tripRoadClosures(ctx, req.Locations)
+		}
+		vreq.ExcludeLocations, vreq.ExcludePolygons = closureExclusions(closures, maxHazardExclusions)
+	}
+	if costing == "motorcycle" {
+		hazards := api.twoWheelerHazardExclusions(ctx, req.Locations)
+		room := maxHazardExclusions - len(vreq.ExcludeLocations)
+		vreq.ExcludeLocations = append(vreq.ExcludeLocations, hazards[:min(len(hazards), room)]...)
+	}
+
+	body, err := api.ValhallaClient.GetRouteOSRM(ctx, vreq)
+	if err != nil {
+		return nil, err
+	}
+	var resp mapbox.DirectionsResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla OSRM response: %w", err)
+	}
+	if litRanked {
+		api.preferLitRoutes(ctx, &resp)
+		if !req.Alternatives && len(resp.Routes) > 1 {
+			resp.Routes = resp.Routes[:1]
+		}
+	}
+	if req.RoadControls {
+		api.addRoadControls(ctx, costing, &resp)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,327 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+// Routing providers behind /route, also compared by route audits.
+const (
+	RouteProviderValhalla = "valhalla"
+	RouteProviderMapbox   = "mapbox"
+	RouteProviderGoogle   = "google"
+)
+
+// routingPriority is the order providers are tried in when ROUTING_PROVIDERS is unset.
+var routingPriority = []string{RouteProviderMapbox, RouteProviderValhalla, RouteProviderGoogle}
+
+var (
+	errNoRoutingProvider = errors.New("no routing provider configured for this request")
+	errNoRoutes          = errors.New("provider returned no routes")
+)
+
+var (
+	// ErrNoRoute means the request was fine but no provider found a way between the
+	// locations.
+	ErrNoRoute = errors.New("no route between the locations")
+	// ErrRoutingFailed means every provider failed or none could serve the request.
+	ErrRoutingFailed = errors.New("routing failed")
+)
+
+// MobileRouteResponse is what /route answers with whichever provider computed the route:
+// the Mapbox Directions shape the app's navigation SDK reads, which the Valhalla and
+// Google adapters convert their replies into.
+type MobileRouteResponse = mapbox.DirectionsResponse
+
+// RoutingProvider computes routes for /route. Every provider answers with a
+// MobileRouteResponse, so callers never branch on the provider.
+type RoutingProvider interface {
+	Name() string
+	// Configured reports whether the provider has the client and credentials it needs.
+	Configured() bool
+	// Supports reports whether the provider can serve the request at all. Only Valhalla
+	// has a motorcycle profile and the costing options behind the safe preference.
+	Supports(req RouteRequest, preference string) bool
+	GetRoute(ctx context.Context, req RouteRequest, preference string) (*MobileRouteResponse, error)
+}
+
+// routingProvider returns the adapter for a provider name, or nil for unknown names.
+func (api *API) routingProvider(name string) RoutingProvider {
+	switch name {
+	case RouteProviderValhalla:
+		return valhallaRouting{api: api}
+	case RouteProviderMapbox:
+		return mapboxRouting{client: api.MapboxClient}
+	case RouteProviderGoogle:
+		return googleRouting{client: api.GoogleMapsClient}
+	}
+	return nil
+}
+
+// routingOrder is the configured priority (ROUTING_PROVIDERS, comma separated) or the
+// default one. Unknown and repeated names are ignored.
+func (api *API) routingOrder() []string {
+	var order []string
+	for _, name := range strings.Split(api.Config.RoutingProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if api.routingProvider(name) != nil && !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	if len(order) == 0 {
+		return routingPriority
+	}
+	return order
+}
+
+// routingChain returns the providers able to serve the request, in priority order with
+// the ones currently marked down moved to the end: they are still tried before giving up.
+// Only Valhalla steers around reported road closures, so with closures near the trip it
+// goes ahead of the other providers that are up.
+func (api *API) routingChain(req RouteRequest, preference string) []RoutingProvider {
+	var up, down []RoutingProvider
+	now := time.Now()
+	for _, name := range api.routingOrder() {
+		p := api.routingProvider(name)
+		if !p.Configured() || !p.Supports(req, preference) {
+			continue
+		}
+		if api.routingHealth.down(name, now) {
+			down = append(down, p)
+		} else {
+			up = append(up, p)
+		}
+	}
+	if len(req.closures) > 0 {
+		if i := slices.IndexFunc(up, func(p RoutingProvider) bool { return p.Name() == RouteProviderValhalla }); i > 0 {
+			valhalla := up[i]
+			up = slices.Insert(slices.Delete(up, i, i+1), 0, valhalla)
+		}
+	}
+	return append(up, down...)
+}
+
+// routeWithFallback asks each provider in the chain until one returns a route, and
+// reports which one did.
+func (api *API) routeWithFallback(ctx context.Context, req RouteRequest, preference string) (*MobileRouteResponse, string, error) {
+	err := errNoRoutingProvider
+	for _, p := range api.routingChain(req, preference) {
+		start := time.Now()
+		var resp *MobileRouteResponse
+		resp, err = p.GetRoute(ctx, req, preference)
+		if err == nil && len(resp.Routes) == 0 {
+			err = fmt.Errorf("%s: %w", p.Name(), errNoRoutes)
+		}
+		if ctx.Err() != nil {
+			// The client went away; that says nothing about the provider.
+			return nil, "", ctx.Err()
+		}
+		if err == nil {
+			api.routingHealth.record(p.Name(), time.Since(start), nil)
+			return resp, p.Name(), nil
+		}
+		// A trip with no route says nothing about the provider's health either, but the
+		// next provider may still know a road this one doesn't.
+		if !routeNotFound(err) {
+			api.routingHealth.record(p.Name(), time.Since(start), err)
+		}
+		api.logger().WarnContext(ctx, "Routing provider failed", "provider", p.Name(), "err", err)
+	}
+	if routeNotFound(err) {
+		return nil, "", fmt.Errorf("%w: %w", ErrNoRoute, err)
+	}
+	return nil, "", fmt.Errorf("%w: %w", ErrRoutingFailed, err)
+}
+
+// routeNotFound reports whether a provider error only means there is no route between
+// the locations.
+func routeNotFound(err error) bool {
+	var verr *valhalla.Error
+	var merr *mapbox.Error
+	var gerr *googlemaps.Error
+	return errors.Is(err, errNoRoutes) ||
+		errors.As(err, &verr) && verr.NoPath() ||
+		errors.As(err, &merr) && merr.NoRoute() ||
+		errors.As(err, &gerr) && gerr.NoResults()
+}
+
+// valhallaRouting routes through our own Valhalla instance.
+type valhallaRouting struct {
+	api *API
+}
+
+func (valhallaRouting) Name() string { return RouteProviderValhalla }
+
+func (v valhallaRouting) Configured() bool { return v.api.ValhallaClient != nil }
+
+func (valhallaRouting) Supports(RouteRequest, string) bool { return true }
+
+func (v valhallaRouting) GetRoute(ctx context.Context, req RouteRequest, preference string) (*MobileRouteResponse, error) {
+	return v.api.getValhallaRoute(ctx, req, preference)
+}
+
+// mapboxRouting routes through the Mapbox Directions API with navigation extras.
+type mapboxRouting struct {
+	client *mapbox.MapboxClient
+}
+
+func (mapboxRouting) Name() string { return RouteProviderMapbox }
+
+func (m mapboxRouting) Configured() bool { return m.client != nil }
+
+// Supports rejects the motorcycle profile and the safe preference, which Mapbox has no
+// equivalent for.
+func (mapboxRouting) Supports(req RouteRequest, preference string) bool {
+	return preference == values.RoutePreferenceFastest && req.Profile != profileMotorcycle
+}
+
+func (m mapboxRouting) GetRoute(ctx context.Context, req RouteRequest, _ string) (*MobileRouteResponse, error) {
+	navOptions := &mapbox.NavigationOptions{
+		VoiceInstructions:  req.VoiceInstructions,
+		BannerInstructions: req.BannerInstructions,
+		VoiceUnits:         req.VoiceUnits,
+		Language:           req.Language,
+		RoundaboutExits:    req.RoundaboutExits,
+		WaypointNames:      req.WaypointNames,
+		Approaches:         req.Approaches,
+		Exclude:            req.Exclude,
+	}
+	if navOptions.VoiceUnits == "" {
+		navOptions.VoiceUnits = "metric"
+	}
+	var translate bool
+	navOptions.Language, translate = mapboxLanguage(req.Language)
+	// Enable voice and banner instructions by default
+	if !req.VoiceInstructions && !req.BannerInstructions {
+		navOptions.VoiceInstructions = true
+		navOptions.BannerInstructions = true
+		navOptions.RoundaboutExits = true
+	}
+	resp, err := m.client.DirectionsWithNavigation(ctx, mapboxCoordinates(req.Locations), req.Profile, req.Alternatives, navOptions)
+	if err == nil && translate {
+		translateDirections(resp, req.Language)
+	}
+	return resp, err
+}
+
+// googleRouting routes through the Google Directions API. Google sends no voice or
+// banner instructions, so it is the last resort in the default order.
+type googleRouting struct {
+	client *googlemaps.GoogleMapsClient
+}
+
+func (googleRouting) Name() string { return RouteProviderGoogle }
+
+func (g googleRouting) Configured() bool { return g.client != nil && g.client.APIKey != "" }
+
+func (googleRouting) Supports(req RouteRequest, preference string) bool {
+	return preference == values.RoutePreferenceFastest && req.Profile != profileMotorcycle
+}
+
+func (g googleRouting) GetRoute(ctx context.Context, req RouteRequest, _ string) (*MobileRouteResponse, error) {
+	points := make([]string, len(req.Locations))
+	for i, loc := range req.Locations {
+		points[i] = fmt.Sprintf("%f,%f", loc.Lat, loc.Lng)
+	}
+	mode := googleTravelMode(req.Profile)
+	resp, err := g.client.Directions(ctx, points[0], points[len(points)-1], points[1:len(points)-1], mode, req.Alternatives, req.Language)
+	if err != nil {
+		return nil, err
+	}
+	return googleToDirections(resp, mode)
+}
+
+// googleTravelMode maps a /route profile to a Google Directions mode.
+func googleTravelMode(profile string) string {
+	switch profile {
+	case "walking":
+		return "walking"
+	case "cycling":
+		return "bicycling"
+	}
+	return "driving"
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainInstruction turns Google's html_instructions into plain text.
+func plainInstruction(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTag.ReplaceAllString(s, " "))), " ")
+}
+
+// googleToDirections converts a Google Directions reply into the Mapbox shape. Google
+// polylines are [lat, lng]; Mapbox geometry is [lng, lat]. Each leg ends with an arrive
+// step as Mapbox legs do.
+func googleToDirections(g *googlemaps.DirectionsResponse, mode string) (*mapbox.DirectionsResponse, error) {
+	out := &mapbox.DirectionsResponse{Code: "Ok", Routes: make([]mapbox.Route, 0, len(g.Routes))}
+	for _, gr := range g.Routes {
+		coords, err := lngLatPolyline(gr.OverviewPolyline.Points)
+		if err != nil {
+			return nil, err
+		}
+		route := mapbox.Route{
+			Geometry:   mapbox.LineString{Type: "LineString", Coordinates: coords},
+			WeightName: "duration",
+		}
+		for li, gl := range gr.Legs {
+			leg := mapbox.Leg{Summary: gr.Summary, Duration: float64(gl.Duration.Value), Distance: float64(gl.Distance.Value)}
+			leg.Weight = leg.Duration
+			for si, gs := range gl.Steps {
+				geometry, err := lngLatPolyline(gs.Polyline.Points)
+				if err != nil {
+					return nil, err
+				}
+				maneuver := "turn"
+				if li == 0 && si == 0 {
+					maneuver = "depart"
+				}
+				leg.Steps = append(leg.Steps, mapbox.Step{
+					Geometry: mapbox.LineString{Type: "LineString", Coordinates: geometry},
+					Maneuver: mapbox.Maneuver{
+						Type:        maneuver,
+						Instruction: plainInstruction(gs.HtmlInstr),
+						Location:    []float64{gs.StartLoc.Lng, gs.StartLoc.Lat},
+					},
+					Duration: float64(gs.Duration.Value),
+					Distance: float64(gs.Distance.Value),
+					Mode:     mode,
+				})
+			}
+			end := []float64{gl.EndLocation.Lng, gl.EndLocation.Lat}
+			leg.Steps = append(leg.Steps, mapbox.Step{
+				Geometry: mapbox.LineString{Type: "LineString", Coordinates: [][]float64{end, end}},
+				Maneuver: mapbox.Maneuver{Type: "arrive", Instruction: "You have arrived at your destination", Location: end},
+				Mode:     mode,
+			})
+			route.Legs = append(route.Legs, leg)
+			route.Duration += leg.Duration
+			route.Distance += leg.Distance
+		}
+		route.Weight = route.Duration
+		out.Routes = append(out.Routes, route)
+	}
+	return out, nil
+}
+
+func lngLatPolyline(points string) ([][]float64, error) {
+	decoded, err := util.DecodePolyLines(points)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range decoded {
+		c[0], c[1] = c[1], c[0]
+	}
+	return decoded, nil
+}
@@ -0,0 +1,132 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/config"
+	googlemaps "github.com/bwise1/waze_kibris/internal/http/google"
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/http/valhalla"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func chainNames(chain []RoutingProvider) []string {
+	names := make([]string, len(chain))
+	for i, p := range chain {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+func TestRoutingChain(t *testing.T) {
+	api := &API{
+		Config:           &config.Config{RoutingProviders: " Google, bogus, valhalla,google "},
+		ValhallaClient:   &valhalla.ValhallaClient{},
+		MapboxClient:     &mapbox.MapboxClient{},
+		GoogleMapsClient: &googlemaps.GoogleMapsClient{APIKey: "key"},
+	}
+	fastest := RouteRequest{Profile: "driving"}
+
+	if got, want := chainNames(api.routingChain(fastest, values.RoutePreferenceFastest)), []string{"google", "valhalla"}; !slices.Equal(got, want) {
+		t.Errorf("configured chain = %v, want %v", got, want)
+	}
+	api.Config.RoutingProviders = ""
+	if got, want := chainNames(api.routingChain(fastest, values.RoutePreferenceFastest)), routingPriority; !slices.Equal(got, want) {
+		t.Errorf("default chain = %v, want %v", got, want)
+	}
+	if got, want := chainNames(api.routingChain(fastest, values.RoutePreferenceSafe)), []string{"valhalla"}; !slices.Equal(got, want) {
+		t.Errorf("safe chain = %v, want %v", got, want)
+	}
+	moto := RouteRequest{Profile: profileMotorcycle}
+	if got, want := chainNames(api.routingChain(moto, values.RoutePreferenceFastest)), []string{"valhalla"}; !slices.Equal(got, want) {
+		t.Errorf("motorcycle chain = %v, want %v", got, want)
+	}
+
+	closed := RouteRequest{Profile: "driving", closures: []roadClosure{{Position: []float64{33.36, 35.17}}}}
+	if got, want := chainNames(api.routingChain(closed, values.RoutePreferenceFastest)), []string{"valhalla", "mapbox", "google"}; !slices.Equal(got, want) {
+		t.Errorf("chain with closures = %v, want %v", got, want)
+	}
+
+	api.routingHealth.record(RouteProviderMapbox, time.Second, &mapbox.Error{StatusCode: http.StatusTooManyRequests})
+	if got, want := chainNames(api.routingChain(fastest, values.RoutePreferenceFastest)), []string{"valhalla", "google", "mapbox"}; !slices.Equal(got, want) {
+		t.Errorf("chain with mapbox down = %v, want %v", got, want)
+	}
+
+	api.GoogleMapsClient.APIKey = ""
+	if got, want := chainNames(api.routingChain(fastest, values.RoutePreferenceFastest)), []string{"valhalla", "mapbox"}; !slices.Equal(got, want) {
+		t.Errorf("chain without google key = %v, want %v", got, want)
+	}
+}
+
+func TestRouteNotFound(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("google: %w", errNoRoutes), true},
+		{&valhalla.Error{HTTPStatus: 400, Code: 442, Message: "No path could be found for input"}, true},
+		{&valhalla.Error{HTTPStatus: 502, Message: "<html>bad gateway</html>"}, false},
+		{&mapbox.Error{StatusCode: http.StatusOK, Code: "NoSegment"}, true},
+		{&mapbox.Error{StatusCode: http.StatusUnprocessableEntity, Body: "NoRoute"}, false},
+		{fmt.Errorf("wrapped: %w", &googlemaps.Error{StatusCode: http.StatusOK, Status: "ZERO_RESULTS"}), true},
+		{&googlemaps.Error{StatusCode: http.StatusOK, Status: "REQUEST_DENIED", Message: "ZERO_RESULTS"}, false},
+		{errors.New("mapbox directions API error: NoRoute"), false},
+	}
+	for _, tt := range tests {
+		if got := routeNotFound(tt.err); got != tt.want {
+			t.Errorf("routeNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGoogleToDirections(t *testing.T) {
+	g := &googlemaps.DirectionsResponse{
+		Status: "OK",
+		Routes: []googlemaps.Route{{
+			Summary:          "A1",
+			OverviewPolyline: googlemaps.Polyline{Points: "_p~iF~ps|U_ulLnnqC_mqNvxq`@"},
+			Legs: []googlemaps.Leg{{
+				Distance:    googlemaps.TextValue{Value: 1200},
+				Duration:    googlemaps.TextValue{Value: 90},
+				EndLocation: googlemaps.LatLng{Lat: 43.252, Lng: -126.453},
+				Steps: []googlemaps.Step{{
+					Distance:  googlemaps.TextValue{Value: 1200},
+					Duration:  googlemaps.TextValue{Value: 90},
+					HtmlInstr: "Turn <b>left</b> onto <b>Main St</b><div style=\"font-size:0.9em\">Destination &amp; parking on the right</div>",
+					Polyline:  googlemaps.Polyline{Points: "_p~iF~ps|U_ulLnnqC"},
+					StartLoc:  googlemaps.LatLng{Lat: 38.5, Lng: -120.2},
+				}},
+			}},
+		}},
+	}
+
+	resp, err := googleToDirections(g, "driving")
+	if err != nil {
+		t.Fatalf("googleToDirections: %v", err)
+	}
+	if resp.Code != "Ok" || len(resp.Routes) != 1 {
+		t.Fatalf("got code %q with %d routes", resp.Code, len(resp.Routes))
+	}
+	route := resp.Routes[0]
+	if route.Duration != 90 || route.Distance != 1200 {
+		t.Errorf("duration, distance = %v, %v, want 90, 1200", route.Duration, route.Distance)
+	}
+	if got := route.Geometry.Coordinates[0]; got[0] != -120.2 || got[1] != 38.5 {
+		t.Errorf("first coordinate = %v, want [lng lat] [-120.2 38.5]", got)
+	}
+	steps := route.Legs[0].Steps
+	if len(steps) != 2 {
+		t.Fatalf("got %d steps, want 2", len(steps))
+	}
+	if steps[0].Maneuver.Type != "depart" || steps[1].Maneuver.Type != "arrive" {
+		t.Errorf("maneuvers = %q, %q, want depart, arrive", steps[0].Maneuver.Type, steps[1].Maneuver.Type)
+	}
+	if want := "Turn left onto Main St Destination & parking on the right"; steps[0].Maneuver.Instruction != want {
+		t.Errorf("instruction = %q, want %q", steps[0].Maneuver.Instruction, want)
+	}
+}
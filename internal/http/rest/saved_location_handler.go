@@ -1,9 +1,7 @@
 package rest
 
 import (
-	"context"
-	"fmt"
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -20,22 +18,20 @@ func (api *API) SavedLocationRoutes() chi.Router {
 	mux.Route("/", func(r chi.Router) {
 		r.Use(api.RequireLogin)
 		r.Method(http.MethodPost, "/", Handler(api.CreateSavedLocation))
+		r.Method(http.MethodPut, "/order", Handler(api.ReorderSavedLocations))
 		r.Method(http.MethodGet, "/{id}", Handler(api.GetSavedLocation))
+		r.Method(http.MethodPut, "/{id}", Handler(api.UpdateSavedLocation))
+		r.Method(http.MethodDelete, "/{id}", Handler(api.DeleteSavedLocation))
+		// Query Params: ?category=home|work|favorite|custom, ?page=1, ?page_size=50 (max 200)
+		// or ?cursor=<next_cursor>
 		r.Method(http.MethodGet, "/", Handler(api.GetAllSavedLocation))
 	})
-
-	// mux.Method(http.MethodPost, "/", Handler(api.CreateSavedLocation))
-	// mux.Method(http.MethodGet, "/{id}", Handler(api.GetSavedLocation))
-	// mux.Method(http.MethodPut, "/{id}", Handler(api.UpdateSavedLocation))
-	// mux.Method(http.MethodDelete, "/{id}", Handler(api.DeleteSavedLocation))
 	return mux
 }
 
 func (api *API) CreateSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
-	var ctx = context.TODO()
-
 	var req model.LocationRequest
 
 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
@@ -44,71 +40,58 @@ func (api *API) CreateSavedLocation(_ http.ResponseWriter, r *http.Request) *Ser
 
 	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
-		log.Println("unable to get user ID from context", err)
+		api.logger().WarnContext(r.Context(), "Unable to get user ID from context", "err", err)
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
 	if err := util.ValidateStruct(req); err != nil {
-		return respondWithError(err, "validation failed", values.BadRequestBody, &tc)
+		return respondWithValidationError(err, &tc)
 	}
 
-	// Check if a location with the same name already exists for this user
-	exists, err := api.CheckSavedLocationExistsRepo(ctx, userID, req.Name)
+	location, status, message, err := api.CreateSavedLocationHelper(r.Context(), userID, req)
 	if err != nil {
-		log.Println("failed to check if saved location exists", err)
-		return respondWithError(err, "failed to check existing locations", values.Error, &tc)
-	}
-	if exists {
-		return &ServerResponse{
-			Message:    fmt.Sprintf("A location named '%s' already exists. Please use a different name.", req.Name),
-			Status:     values.BadRequestBody,
-			StatusCode: util.StatusCode(values.BadRequestBody),
-			Data:       nil,
-		}
-	}
-
-	newLocation := model.SavedLocation{
-		UserID:   userID,
-		Name:     req.Name,
-		Address:  req.Address,
-		Location: util.PointFromLatLon(req.Latitude, req.Longitude),
-		PlaceID:  req.PlaceID,
-	}
-
-	err = api.CreateSavedLocationRepo(ctx, newLocation)
-	if err != nil {
-		return respondWithError(err, "failed to create saved location", values.Error, &tc)
+		return respondWithError(err, message, status, &tc)
 	}
 
 	return &ServerResponse{
-		Message:    "Saved location created successfully",
-		Status:     values.Created,
-		StatusCode: util.StatusCode(values.Created),
-		Data:       req,
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       location,
 	}
 }
 
+// GetAllSavedLocation lists a page of the user's saved locations in their chosen order.
+// ?category= narrows the list to one category.
 func (api *API) GetAllSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
 	userID, err := util.GetUserIDFromContext(r.Context())
 	if err != nil {
-		log.Println("unable to get user ID from context", err)
+		api.logger().WarnContext(r.Context(), "Unable to get user ID from context", "err", err)
 		return respondWithError(err, "Not authorized", values.NotAuthorised, &tc)
 	}
 
-	locations, err := api.GetSavedLocationsRepo(r.Context(), userID)
+	category := r.URL.Query().Get("category")
+	switch category {
+	case "", values.SavedLocationHome, values.SavedLocationWork, values.SavedLocationFavorite, values.SavedLocationCustom:
+	default:
+		return respondWithError(nil, "category must be home, work, favorite or custom", values.BadRequestBody, &tc)
+	}
+
+	locations, err := api.GetSavedLocationsRepo(r.Context(), userID, category)
 	if err != nil {
-		log.Println("failed to get saved locations", err)
+		api.logger().ErrorContext(r.Context(), "Failed to get saved locations", "err", err)
 		return respondWithError(err, "failed to get saved locations", values.Error, &tc)
 	}
 
-	log.Println(locations)
+	api.logger().DebugContext(r.Context(), "Loaded saved locations", "count", len(locations))
+	page := parsePageParams(r.URL.Query(), defaultSavedLocationsPageSize, maxSavedLocationsPageSize)
 	return &ServerResponse{
 		Message:    "Saved locations retrieved successfully",
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
-		Data:       locations,
+		Data:       paginate(locations, page),
 	}
 }
 
@@ -120,13 +103,21 @@ func (api *API) GetSavedLocation(_ http.ResponseWriter, r *http.Request) *Server
 		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
 	}
 
-	location, err := api.GetSavedLocationRepo(r.Context(), id)
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	location, err := api.GetSavedLocationRepo(r.Context(), userID, id)
+	if errors.Is(err, ErrSavedLocationNotFound) {
+		return respondWithError(err, "Saved location not found", values.NotFound, &tc)
+	}
 	if err != nil {
 		return respondWithError(err, "failed to get saved location", values.Error, &tc)
 	}
 
 	lat, lon := util.PointToLatLon(location.Location)
-	log.Println(location)
+	api.logger().DebugContext(r.Context(), "Loaded saved location", "saved_location_id", location.ID)
 	return &ServerResponse{
 		Message:    "Saved location retrieved successfully",
 		Status:     values.Success,
@@ -135,57 +126,104 @@ func (api *API) GetSavedLocation(_ http.ResponseWriter, r *http.Request) *Server
 			"id":         location.ID,
 			"user_id":    location.UserID,
 			"name":       location.Name,
+			"address":    location.Address,
 			"latitude":   lat,
 			"longitude":  lon,
+			"place_id":   location.PlaceID,
+			"category":   location.Category,
+			"icon":       location.Icon,
+			"sort_order": location.SortOrder,
 			"created_at": location.CreatedAt,
 		},
 	}
 }
 
-// func (api *API) UpdateSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
-// 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
-
-// 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-// 	if err != nil {
-// 		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
-// 	}
-
-// 	var req model.SavedLocation
-// 	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
-// 		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
-// 	}
-
-// 	req.ID = id
-
-// 	err = api.UpdateSavedLocationRepo(r.Context(), req)
-// 	if err != nil {
-// 		return respondWithError(err, "failed to update saved location", values.Error, &tc)
-// 	}
-
-// 	return &ServerResponse{
-// 		Message:    "Saved location updated successfully",
-// 		Status:     values.Success,
-// 		StatusCode: util.StatusCode(values.Success),
-// 		Data:       req,
-// 	}
-// }
-
-// func (api *API) DeleteSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
-// 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
-
-// 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-// 	if err != nil {
-// 		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
-// 	}
-
-// 	err = api.DeleteSavedLocationRepo(r.Context(), id)
-// 	if err != nil {
-// 		return respondWithError(err, "failed to delete saved location", values.Error, &tc)
-// 	}
-
-// 	return &ServerResponse{
-// 		Message:    "Saved location deleted successfully",
-// 		Status:     values.Success,
-// 		StatusCode: util.StatusCode(values.Success),
-// 	}
-// }
+// UpdateSavedLocation replaces a saved location; the body is the same as for create.
+func (api *API) UpdateSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.LocationRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	location, status, message, err := api.UpdateSavedLocationHelper(r.Context(), userID, id, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       location,
+	}
+}
+
+func (api *API) DeleteSavedLocation(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return respondWithError(err, "invalid ID format", values.BadRequestBody, &tc)
+	}
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	status, message, err := api.DeleteSavedLocationHelper(r.Context(), userID, id)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+	}
+}
+
+// ReorderSavedLocations PUT /saved-locations/order — body {"ids": [...]} in the new order.
+func (api *API) ReorderSavedLocations(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.ReorderSavedLocationsRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	locations, status, message, err := api.ReorderSavedLocationsHelper(r.Context(), userID, req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       locations,
+	}
+}
@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSavedLocationsPageSize = 50
+	maxSavedLocationsPageSize     = 200
+)
+
+// savedLocationFromRequest builds the row for a validated request, normalizing the
+// position and defaulting the category.
+func savedLocationFromRequest(userID uuid.UUID, req model.LocationRequest) (model.SavedLocation, error) {
+	lat, lng, err := util.NormalizeCoordinate(req.Latitude, req.Longitude)
+	if err != nil {
+		return model.SavedLocation{}, err
+	}
+	category := req.Category
+	if category == "" {
+		category = values.SavedLocationFavorite
+	}
+	return model.SavedLocation{
+		UserID:   userID,
+		Name:     req.Name,
+		Address:  req.Address,
+		Location: util.PointFromLatLon(lat, lng),
+		PlaceID:  req.PlaceID,
+		Category: category,
+		Icon:     req.Icon,
+	}, nil
+}
+
+func (api *API) CreateSavedLocationHelper(ctx context.Context, userID uuid.UUID, req model.LocationRequest) (model.SavedLocationResponse, string, string, error) {
+	location, err := savedLocationFromRequest(userID, req)
+	if err != nil {
+		return model.SavedLocationResponse{}, values.BadRequestBody, "invalid latitude or longitude", err
+	}
+
+	// Check if a location with the same name already exists for this user
+	exists, err := api.CheckSavedLocationExistsRepo(ctx, userID, req.Name)
+	if err != nil {
+		return model.SavedLocationResponse{}, values.Error, "failed to check existing locations", err
+	}
+	if exists {
+		return model.SavedLocationResponse{}, values.BadRequestBody,
+			fmt.Sprintf("A location named '%s' already exists. Please use a different name.", req.Name),
+			fmt.Errorf("saved location %q: %w", req.Name, ErrConflict)
+	}
+
+	created, err := api.CreateSavedLocationRepo(ctx, location)
+	if err != nil {
+		return model.SavedLocationResponse{}, errorStatus(err, values.Error), "failed to create saved location", err
+	}
+	return created, values.Created, "Saved location created successfully", nil
+}
+
+func (api *API) UpdateSavedLocationHelper(ctx context.Context, userID uuid.UUID, id int64, req model.LocationRequest) (model.SavedLocationResponse, string, string, error) {
+	location, err := savedLocationFromRequest(userID, req)
+	if err != nil {
+		return model.SavedLocationResponse{}, values.BadRequestBody, "invalid latitude or longitude", err
+	}
+	location.ID = id
+
+	updated, err := api.UpdateSavedLocationRepo(ctx, location)
+	switch {
+	case errors.Is(err, ErrSavedLocationNotFound):
+		return model.SavedLocationResponse{}, values.NotFound, "Saved location not found", err
+	case errors.Is(err, ErrConflict):
+		// Same answer as a duplicate name on create.
+		return model.SavedLocationResponse{}, values.BadRequestBody,
+			fmt.Sprintf("A location named '%s' already exists. Please use a different name.", req.Name), err
+	case err != nil:
+		return model.SavedLocationResponse{}, values.Error, "failed to update saved location", err
+	}
+	return updated, values.Success, "Saved location updated successfully", nil
+}
+
+func (api *API) DeleteSavedLocationHelper(ctx context.Context, userID uuid.UUID, id int64) (string, string, error) {
+	err := api.DeleteSavedLocationRepo(ctx, userID, id)
+	if errors.Is(err, ErrSavedLocationNotFound) {
+		return values.NotFound, "Saved location not found", err
+	}
+	if err != nil {
+		return values.Error, "failed to delete saved location", err
+	}
+	return values.Success, "Saved location deleted successfully", nil
+}
+
+// ReorderSavedLocationsHelper applies a new order and returns the reordered list.
+func (api *API) ReorderSavedLocationsHelper(ctx context.Context, userID uuid.UUID, req model.ReorderSavedLocationsRequest) ([]model.SavedLocationResponse, string, string, error) {
+	err := api.ReorderSavedLocationsRepo(ctx, userID, req.IDs)
+	if errors.Is(err, ErrSavedLocationNotFound) {
+		return nil, values.NotFound, "Some saved locations were not found", err
+	}
+	if err != nil {
+		return nil, values.Error, "failed to reorder saved locations", err
+	}
+
+	locations, err := api.GetSavedLocationsRepo(ctx, userID, "")
+	if err != nil {
+		return nil, values.Error, "failed to get saved locations", err
+	}
+	return locations, values.Success, "Saved locations reordered successfully", nil
+}
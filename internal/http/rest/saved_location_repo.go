@@ -2,100 +2,157 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
-func (api *API) CreateSavedLocationRepo(ctx context.Context, location model.SavedLocation) error {
+var ErrSavedLocationNotFound = fmt.Errorf("saved location %w", ErrNotFound)
 
-	stmt := `
-        INSERT INTO saved_locations (user_id, name, address, location, place_id)
-        VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326), $6)
-    `
-	_, err := api.Deps.DB.Pool().Exec(ctx, stmt,
-		location.UserID,
-		location.Name,
-		location.Address,
-		location.Location.P.X,
-		location.Location.P.Y,
-		location.PlaceID,
-	)
+// savedLocationColumns are the columns scanSavedLocation reads, in order.
+const savedLocationColumns = `id, name, COALESCE(address, '') AS address,
+	ST_X(location::geometry) AS longitude, ST_Y(location::geometry) AS latitude,
+	place_id, category, icon, sort_order`
+
+func scanSavedLocation(row pgx.Row) (model.SavedLocationResponse, error) {
+	var l model.SavedLocationResponse
+	err := row.Scan(&l.ID, &l.Name, &l.Address, &l.Longitude, &l.Latitude, &l.PlaceID, &l.Category, &l.Icon, &l.SortOrder)
+	return l, err
+}
+
+// demoteSavedLocation moves the user's current home or work, other than keepID, back to
+// favorites so another place can take the category.
+func demoteSavedLocation(ctx context.Context, tx pgx.Tx, userID uuid.UUID, category string, keepID int64) error {
+	if category != values.SavedLocationHome && category != values.SavedLocationWork {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE saved_locations SET category = $3, updated_at = NOW()
+		WHERE user_id = $1 AND category = $2 AND id <> $4`,
+		userID, category, values.SavedLocationFavorite, keepID)
+	return err
+}
+
+// CreateSavedLocationRepo saves a location at the end of the user's list.
+func (api *API) CreateSavedLocationRepo(ctx context.Context, location model.SavedLocation) (model.SavedLocationResponse, error) {
+	var created model.SavedLocationResponse
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := demoteSavedLocation(ctx, tx, location.UserID, location.Category, 0); err != nil {
+			return err
+		}
+		var err error
+		created, err = scanSavedLocation(tx.QueryRow(ctx, `
+			INSERT INTO saved_locations (user_id, name, address, location, place_id, category, icon, sort_order)
+			VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326), $6, $7, $8,
+			        (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM saved_locations WHERE user_id = $1))
+			RETURNING `+savedLocationColumns,
+			location.UserID,
+			location.Name,
+			location.Address,
+			location.Location.P.X,
+			location.Location.P.Y,
+			location.PlaceID,
+			location.Category,
+			location.Icon,
+		))
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("creating saved location: %w", err)
+		return model.SavedLocationResponse{}, fmt.Errorf("creating saved location: %w", dbError(err))
 	}
-	return nil
+	return created, nil
 }
 
-func (api *API) GetSavedLocationRepo(ctx context.Context, id int64) (model.SavedLocation, error) {
+func (api *API) GetSavedLocationRepo(ctx context.Context, userID uuid.UUID, id int64) (model.SavedLocation, error) {
 	var location model.SavedLocation
 	stmt := `
-        SELECT id, user_id, name,
+        SELECT id, user_id, name, address,
                ST_X(location::geometry) as longitude,
                ST_Y(location::geometry) as latitude,
-               place_id,
+               place_id, category, icon, sort_order,
                created_at
         FROM saved_locations
-        WHERE id = $1
+        WHERE id = $1 AND user_id = $2
     `
 
-	err := api.Deps.DB.Pool().QueryRow(ctx, stmt, id).Scan(
+	err := api.Deps.DB.Pool().QueryRow(ctx, stmt, id, userID).Scan(
 		&location.ID,
 		&location.UserID,
 		&location.Name,
+		&location.Address,
 		&location.Location.P.X,
 		&location.Location.P.Y,
 		&location.PlaceID,
+		&location.Category,
+		&location.Icon,
+		&location.SortOrder,
 		&location.CreatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.SavedLocation{}, ErrSavedLocationNotFound
+	}
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return model.SavedLocation{}, nil
-		}
 		return model.SavedLocation{}, fmt.Errorf("getting saved location: %w", err)
 	}
 
 	return location, nil
 }
 
-func (api *API) UpdateSavedLocationRepo(ctx context.Context, location model.SavedLocation) error {
-	stmt := `
-        UPDATE public.saved_locations
-        SET name = $2,
-            location = ST_SetSRID(ST_MakePoint($3, $4), 4326),
-            place_id = $5,
-            updated_at = NOW()
-        WHERE id = $1
-    `
-	result, err := api.Deps.DB.Pool().Exec(ctx, stmt,
-		location.ID,
-		location.Name,
-		location.Location.P.X,
-		location.Location.P.Y,
-		location.PlaceID,
-	)
-	if err != nil {
-		return fmt.Errorf("updating saved location: %w", err)
+// UpdateSavedLocationRepo replaces one of the user's saved locations, keeping its place
+// in the list.
+func (api *API) UpdateSavedLocationRepo(ctx context.Context, location model.SavedLocation) (model.SavedLocationResponse, error) {
+	var updated model.SavedLocationResponse
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if err := demoteSavedLocation(ctx, tx, location.UserID, location.Category, location.ID); err != nil {
+			return err
+		}
+		var err error
+		updated, err = scanSavedLocation(tx.QueryRow(ctx, `
+			UPDATE saved_locations
+			SET name = $3,
+			    address = $4,
+			    location = ST_SetSRID(ST_MakePoint($5, $6), 4326),
+			    place_id = $7,
+			    category = $8,
+			    icon = $9,
+			    updated_at = NOW()
+			WHERE id = $1 AND user_id = $2
+			RETURNING `+savedLocationColumns,
+			location.ID,
+			location.UserID,
+			location.Name,
+			location.Address,
+			location.Location.P.X,
+			location.Location.P.Y,
+			location.PlaceID,
+			location.Category,
+			location.Icon,
+		))
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.SavedLocationResponse{}, ErrSavedLocationNotFound
 	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("location with ID %d not found", location.ID)
+	if err != nil {
+		return model.SavedLocationResponse{}, fmt.Errorf("updating saved location: %w", dbError(err))
 	}
-	return nil
+	return updated, nil
 }
 
-func (api *API) GetSavedLocationsRepo(ctx context.Context, userID uuid.UUID) ([]model.SavedLocationResponse, error) {
+// GetSavedLocationsRepo lists the user's saved locations in their chosen order, only
+// those in category when it is set.
+func (api *API) GetSavedLocationsRepo(ctx context.Context, userID uuid.UUID, category string) ([]model.SavedLocationResponse, error) {
 	stmt := `
-		SELECT id, name, COALESCE(address, '') as address,
-			   ST_X(location::geometry) as longitude,
-			   ST_Y(location::geometry) as latitude,
-			   place_id
+		SELECT ` + savedLocationColumns + `
 		FROM saved_locations
-		WHERE user_id = $1
+		WHERE user_id = $1 AND ($2 = '' OR category = $2)
+		ORDER BY sort_order, id
 	`
-	rows, err := api.Deps.DB.Pool().Query(ctx, stmt, userID)
+	rows, err := api.Deps.DB.Pool().Query(ctx, stmt, userID, category)
 	if err != nil {
 		return nil, fmt.Errorf("getting saved locations: %w", err)
 	}
@@ -103,37 +160,58 @@ func (api *API) GetSavedLocationsRepo(ctx context.Context, userID uuid.UUID) ([]
 
 	var locations []model.SavedLocationResponse
 	for rows.Next() {
-		var location model.SavedLocationResponse
-		err := rows.Scan(
-			&location.ID,
-			&location.Name,
-			&location.Address,
-			&location.Longitude,
-			&location.Latitude,
-			&location.PlaceID,
-		)
+		location, err := scanSavedLocation(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning saved location: %w", err)
 		}
 		locations = append(locations, location)
 	}
-	return locations, nil
+	return locations, rows.Err()
 }
 
-func (api *API) DeleteSavedLocationRepo(ctx context.Context, id int64) error {
-	stmt := `DELETE FROM public.saved_locations WHERE id = $1`
+func (api *API) DeleteSavedLocationRepo(ctx context.Context, userID uuid.UUID, id int64) error {
+	stmt := `DELETE FROM saved_locations WHERE id = $1 AND user_id = $2`
 
-	result, err := api.Deps.DB.Pool().Exec(ctx, stmt, id)
+	result, err := api.Deps.DB.Pool().Exec(ctx, stmt, id, userID)
 	if err != nil {
 		return fmt.Errorf("deleting saved location: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("location with ID %d not found", id)
+		return ErrSavedLocationNotFound
 	}
 	return nil
 }
 
+// ReorderSavedLocationsRepo numbers ids 1, 2, ... and the user's other saved locations
+// after them in their current order. Every id must be one of the user's.
+func (api *API) ReorderSavedLocationsRepo(ctx context.Context, userID uuid.UUID, ids []int64) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE saved_locations s SET sort_order = o.pos
+			FROM UNNEST($2::bigint[]) WITH ORDINALITY AS o(id, pos)
+			WHERE s.id = o.id AND s.user_id = $1`, userID, ids)
+		if err != nil {
+			return fmt.Errorf("reordering saved locations: %w", err)
+		}
+		if tag.RowsAffected() != int64(len(ids)) {
+			return ErrSavedLocationNotFound
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE saved_locations s SET sort_order = $3 + r.pos
+			FROM (
+				SELECT id, ROW_NUMBER() OVER (ORDER BY sort_order, id) AS pos
+				FROM saved_locations
+				WHERE user_id = $1 AND id <> ALL($2::bigint[])
+			) r
+			WHERE s.id = r.id`, userID, ids, len(ids))
+		if err != nil {
+			return fmt.Errorf("reordering remaining saved locations: %w", err)
+		}
+		return nil
+	})
+}
+
 // CheckSavedLocationExistsRepo checks if a location with the given name already exists for the user
 func (api *API) CheckSavedLocationExistsRepo(ctx context.Context, userID uuid.UUID, name string) (bool, error) {
 	stmt := `SELECT EXISTS(SELECT 1 FROM saved_locations WHERE user_id = $1 AND name = $2)`
@@ -146,3 +224,33 @@ func (api *API) CheckSavedLocationExistsRepo(ctx context.Context, userID uuid.UU
 
 	return exists, nil
 }
+
+// SearchSavedLocationsRepo returns the user's saved locations whose name or address
+// contains text, closest to focus first when one is given.
+func (api *API) SearchSavedLocationsRepo(ctx context.Context, userID uuid.UUID, text string, focusLat, focusLng *float64, limit int) ([]model.SavedLocationResponse, error) {
+	stmt := `
+		SELECT ` + savedLocationColumns + `
+		FROM saved_locations
+		WHERE user_id = $1
+		  AND (name ILIKE '%' || $2 || '%' OR address ILIKE '%' || $2 || '%')
+		ORDER BY CASE WHEN $3::float8 IS NULL OR $4::float8 IS NULL THEN 0
+		              ELSE ST_Distance(location::geography, ST_SetSRID(ST_MakePoint($4, $3), 4326)::geography) END,
+		         name
+		LIMIT $5
+	`
+	rows, err := api.Deps.DB.Pool().Query(ctx, stmt, userID, text, focusLat, focusLng, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching saved locations: %w", err)
+	}
+	defer rows.Close()
+
+	var locations []model.SavedLocationResponse
+	for rows.Next() {
+		location, err := scanSavedLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning saved location: %w", err)
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
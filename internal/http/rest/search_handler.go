@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) SearchRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Route("/", func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		// The app's search bar: the user's saved locations and recent destinations and
+		// public community groups, fuzzy matched by name, best first
+		// Query Params: ?q=kyrenia (2-100 characters), ?limit=10 (max 30),
+		// ?types=saved_location,recent_destination,group (default all)
+		r.Method(http.MethodGet, "/", Handler(api.SearchHandler))
+	})
+
+	return mux
+}
+
+func (api *API) SearchHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+	q := r.URL.Query()
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+	types, err := parseSearchTypes(q.Get("types"))
+	if err != nil {
+		return respondWithError(err, err.Error(), values.BadRequestBody, &tc)
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	results, status, message, err := api.SearchHelper(r.Context(), userID, q.Get("q"), types, limit)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       results,
+	}
+}
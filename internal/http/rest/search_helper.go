@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 30
+	minSearchQueryLen  = 2
+	maxSearchQueryLen  = 100
+	// Only the first maxSearchTerms words of a query go into the full-text match.
+	maxSearchTerms = 8
+	// searchMinScore is the trigram word similarity a saved location or recent
+	// destination needs to be a hit; about one typo in a short word.
+	searchMinScore = 0.3
+	// searchAddressWeight scales address matches below name matches.
+	searchAddressWeight = 0.8
+)
+
+// searchTypeWeights rank equally good matches: the user's own places before places they
+// only drove to, and both before public groups.
+var searchTypeWeights = map[string]float64{
+	model.SearchResultSavedLocation:     1.0,
+	model.SearchResultRecentDestination: 0.9,
+	model.SearchResultGroup:             0.75,
+}
+
+// searchTypes are the result types /search knows, in the order they're searched.
+var searchTypes = []string{
+	model.SearchResultSavedLocation,
+	model.SearchResultRecentDestination,
+	model.SearchResultGroup,
+}
+
+// parseSearchTypes parses the comma separated ?types=; empty means every type.
+func parseSearchTypes(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return searchTypes, nil
+	}
+	var types []string
+	for _, part := range strings.Split(raw, ",") {
+		t := strings.TrimSpace(part)
+		if !slices.Contains(searchTypes, t) {
+			return nil, fmt.Errorf("types must be saved_location, recent_destination or group, got %q", t)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// searchTerms splits a query into lowercase words of letters and digits, dropping
+// everything else so the terms are safe to put in a tsquery.
+func searchTerms(query string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// prefixTSQuery matches text containing every term as a word prefix, so a query still
+// being typed finds "Kyrenia" from "kyr".
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// rankSearchResults weights each result's score by its type, drops recent destinations
+// that are a saved location by another name, and returns the best limit results.
+// Ties keep the order they were searched in.
+func rankSearchResults(results []model.SearchResult, limit int) []model.SearchResult {
+	var saved []model.SearchResult
+	for _, r := range results {
+		if r.Type == model.SearchResultSavedLocation && r.Latitude != nil && r.Longitude != nil {
+			saved = append(saved, r)
+		}
+	}
+	ranked := make([]model.SearchResult, 0, len(results))
+	for _, r := range results {
+		if r.Type == model.SearchResultRecentDestination && nearSavedLocation(r, saved) {
+			continue
+		}
+		r.Score = math.Round(r.Score*searchTypeWeights[r.Type]*1000) / 1000
+		ranked = append(ranked, r)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// nearSavedLocation reports whether r is within recentDestinationMergeM of a saved
+// location, which the search already returns.
+func nearSavedLocation(r model.SearchResult, saved []model.SearchResult) bool {
+	if r.Latitude == nil || r.Longitude == nil {
+		return false
+	}
+	for _, s := range saved {
+		if util.HaversineMeters(*r.Latitude, *r.Longitude, *s.Latitude, *s.Longitude) <= recentDestinationMergeM {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchHelper searches the user's saved locations and recent destinations and the public
+// community groups for query, and returns up to limit results of the given types, best
+// first.
+func (api *API) SearchHelper(ctx context.Context, userID uuid.UUID, query string, types []string, limit int) (model.SearchResponse, string, string, error) {
+	query = strings.TrimSpace(query)
+	response := model.SearchResponse{Query: query, Results: []model.SearchResult{}}
+	if n := utf8.RuneCountInString(query); n < minSearchQueryLen || n > maxSearchQueryLen {
+		return response, values.BadRequestBody, fmt.Sprintf("q must be %d to %d characters", minSearchQueryLen, maxSearchQueryLen),
+			errors.New("search query length out of range")
+	}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return response, values.BadRequestBody, "q must contain letters or digits", errors.New("search query has no terms")
+	}
+	if limit < 1 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	var results []model.SearchResult
+	for _, t := range types {
+		var found []model.SearchResult
+		var err error
+		switch t {
+		case model.SearchResultSavedLocation:
+			found, err = api.SearchSavedLocationsFuzzyRepo(ctx, userID, query, searchMinScore, limit)
+		case model.SearchResultRecentDestination:
+			since := time.Now().Add(-recentDestinationMaxAge)
+			found, err = api.SearchRecentDestinationsRepo(ctx, userID, query, since, searchMinScore, limit)
+		case model.SearchResultGroup:
+			found, err = api.SearchPublicGroupsRepo(ctx, prefixTSQuery(terms), query, limit)
+		}
+		if err != nil {
+			return response, values.Error, "Failed to search", err
+		}
+		results = append(results, found...)
+	}
+	response.Results = rankSearchResults(results, limit)
+	return response, values.Success, "Search results fetched", nil
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestPrefixTSQuery(t *testing.T) {
+	terms := searchTerms("  Girne's  Harbour:*  & | !Kafe")
+	want := []string{"girne", "s", "harbour", "kafe"}
+	if !reflect.DeepEqual(terms, want) {
+		t.Fatalf("terms = %q, want %q", terms, want)
+	}
+	if got := prefixTSQuery(terms); got != "girne:* & s:* & harbour:* & kafe:*" {
+		t.Errorf("tsquery = %q", got)
+	}
+	if got := searchTerms("Güzelyurt Αμμόχωστος"); !reflect.DeepEqual(got, []string{"güzelyurt", "αμμόχωστος"}) {
+		t.Errorf("non-ASCII terms = %q", got)
+	}
+}
+
+func TestParseSearchTypes(t *testing.T) {
+	types, err := parseSearchTypes("group, saved_location,group")
+	if err != nil || !reflect.DeepEqual(types, []string{"group", "saved_location"}) {
+		t.Errorf("types = %v, %v", types, err)
+	}
+	if types, _ := parseSearchTypes(""); len(types) != 3 {
+		t.Errorf("default types = %v", types)
+	}
+	if _, err := parseSearchTypes("users"); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
+
+func TestRankSearchResults(t *testing.T) {
+	lat, lng := 35.3364, 33.3199
+	nearLat := lat + 0.0003 // ~33 m
+	farLat := lat + 0.01
+	results := []model.SearchResult{
+		{Type: model.SearchResultSavedLocation, ID: "1", Score: 0.6, Latitude: &lat, Longitude: &lng},
+		{Type: model.SearchResultRecentDestination, ID: "2", Score: 1, Latitude: &nearLat, Longitude: &lng},
+		{Type: model.SearchResultRecentDestination, ID: "3", Score: 0.8, Latitude: &farLat, Longitude: &lng},
+		{Type: model.SearchResultGroup, ID: "4", Score: 0.96},
+		{Type: model.SearchResultGroup, ID: "5", Score: 0.4},
+	}
+	ranked := rankSearchResults(results, 3)
+
+	var ids []string
+	for _, r := range ranked {
+		ids = append(ids, r.ID)
+	}
+	// The recent destination next to the saved location is dropped; the group's 0.96
+	// weighs 0.72, the recent destination's 0.8 the same, and it was searched first.
+	if want := []string{"3", "4", "1"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	if ranked[0].Score != 0.72 || ranked[2].Score != 0.6 {
+		t.Errorf("scores = %v, %v", ranked[0].Score, ranked[2].Score)
+	}
+}
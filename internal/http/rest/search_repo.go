@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SearchSavedLocationsFuzzyRepo returns up to limit of the user's saved locations whose
+// name or address is similar to text, scored by trigram word similarity. Address matches
+// count for less than name matches.
+func (api *API) SearchSavedLocationsFuzzyRepo(ctx context.Context, userID uuid.UUID, text string, minScore float64, limit int) ([]model.SearchResult, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id::text, name, NULLIF(address, ''), ST_Y(location::geometry), ST_X(location::geometry), score
+		FROM (
+			SELECT id, name, address, location,
+			       GREATEST(word_similarity($2, name), word_similarity($2, COALESCE(address, '')) * $3) AS score
+			FROM saved_locations
+			WHERE user_id = $1
+		) s
+		WHERE score >= $4
+		ORDER BY score DESC, name
+		LIMIT $5`, userID, text, searchAddressWeight, minScore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching saved locations: %w", err)
+	}
+	return collectSearchResults(rows, model.SearchResultSavedLocation)
+}
+
+// SearchRecentDestinationsRepo returns up to limit of the user's named destinations
+// visited since since whose name is similar to text.
+func (api *API) SearchRecentDestinationsRepo(ctx context.Context, userID uuid.UUID, text string, since time.Time, minScore float64, limit int) ([]model.SearchResult, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id::text, name, NULL::text, ST_Y(location), ST_X(location), score
+		FROM (
+			SELECT id, name, location, last_visited_at, word_similarity($2, name) AS score
+			FROM recent_destinations
+			WHERE user_id = $1 AND name IS NOT NULL AND last_visited_at >= $3
+		) d
+		WHERE score >= $4
+		ORDER BY score DESC, last_visited_at DESC
+		LIMIT $5`, userID, text, since, minScore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching recent destinations: %w", err)
+	}
+	return collectSearchResults(rows, model.SearchResultRecentDestination)
+}
+
+// SearchPublicGroupsRepo returns up to limit public groups whose name, destination or
+// description contains every word of tsQuery as a prefix, or whose name is similar to
+// text. Full-text matches score at least 0.5; the rest by trigram word similarity.
+func (api *API) SearchPublicGroupsRepo(ctx context.Context, tsQuery, text string, limit int) ([]model.SearchResult, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id::text, name, destination_name,
+		       ST_Y(destination_location), ST_X(destination_location), score
+		FROM (
+			SELECT id, name, destination_name, destination_location, member_count,
+			       CASE WHEN search_vector @@ to_tsquery('simple', $1)
+			            THEN 0.5 + 0.5 * word_similarity($2, name)::float8
+			            ELSE word_similarity($2, name)::float8 END AS score
+			FROM community_groups
+			WHERE visibility = 'public' AND is_deleted = FALSE
+			  AND (search_vector @@ to_tsquery('simple', $1) OR $2 <% name)
+		) g
+		ORDER BY score DESC, member_count DESC NULLS LAST
+		LIMIT $3`, tsQuery, text, limit)
+	if err != nil {
+		return nil, fmt.Errorf("searching community groups: %w", err)
+	}
+	return collectSearchResults(rows, model.SearchResultGroup)
+}
+
+func collectSearchResults(rows pgx.Rows, resultType string) ([]model.SearchResult, error) {
+	defer rows.Close()
+
+	var results []model.SearchResult
+	for rows.Next() {
+		result := model.SearchResult{Type: resultType}
+		if err := rows.Scan(&result.ID, &result.Name, &result.Subtitle, &result.Latitude, &result.Longitude, &result.Score); err != nil {
+			return nil, fmt.Errorf("scanning %s search result: %w", resultType, err)
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+// ListSessionsHelper returns the devices signed in to the account, most recently used
+// first. currentDeviceID (X-Device-ID) marks the caller's own session.
+func (api *API) ListSessionsHelper(ctx context.Context, userID uuid.UUID, currentDeviceID string) ([]model.Session, string, string, error) {
+	sessions, err := api.ListSessionsRepo(ctx, userID)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch sessions", err
+	}
+	if sessions == nil {
+		sessions = []model.Session{}
+	}
+	for i := range sessions {
+		d := sessions[i].DeviceID
+		sessions[i].Current = currentDeviceID != "" && d != nil && *d == currentDeviceID
+	}
+	return sessions, values.Success, "Sessions fetched successfully", nil
+}
+
+// RevokeSessionHelper signs one device out. Its access token keeps working until it
+// expires, but it can no longer be refreshed.
+func (api *API) RevokeSessionHelper(ctx context.Context, userID uuid.UUID, sessionID int64) (string, string, error) {
+	if err := api.RevokeSessionRepo(ctx, userID, sessionID); err != nil {
+		return errorStatus(err, values.Error), "Failed to sign out device", err
+	}
+	return values.Success, "Device signed out", nil
+}
+
+// RevokeAllSessionsHelper signs every device out, including the caller's.
+func (api *API) RevokeAllSessionsHelper(ctx context.Context, userID uuid.UUID) (int64, string, string, error) {
+	revoked, err := api.RevokeAllSessionsRepo(ctx, userID)
+	if err != nil {
+		return 0, values.Error, "Failed to sign out devices", err
+	}
+	return revoked, values.Success, "Signed out of all devices", nil
+}
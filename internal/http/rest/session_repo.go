@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSessionNotFound = fmt.Errorf("session %w", ErrNotFound)
+
+// TagSessionRepo records the device and client a refresh token was issued to.
+func (api *API) TagSessionRepo(ctx context.Context, refreshToken string, device model.DeviceInfo, ip, userAgent string) error {
+	_, err := api.DB.Exec(ctx, `
+		UPDATE auth_tokens
+		SET device_id = NULLIF($2, ''), device_model = NULLIF($3, ''), platform = NULLIF($4, ''),
+		    ip_address = NULLIF($5, ''), user_agent = NULLIF($6, '')
+		WHERE token_value = $1 AND token_type = 'refresh'`,
+		refreshToken, device.DeviceID, device.Model, device.Platform, ip, userAgent)
+	return err
+}
+
+func (api *API) ListSessionsRepo(ctx context.Context, userID uuid.UUID) ([]model.Session, error) {
+	rows, err := api.DB.Query(ctx, `
+		SELECT id, device_id, device_model, platform, ip_address, user_agent, created_at, last_used_at, expires_at
+		FROM auth_tokens
+		WHERE user_id = $1 AND token_type = 'refresh' AND is_revoked = FALSE AND expires_at > NOW()
+		ORDER BY COALESCE(last_used_at, created_at) DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("querying sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []model.Session
+	for rows.Next() {
+		var s model.Session
+		err := rows.Scan(&s.ID, &s.DeviceID, &s.DeviceModel, &s.Platform, &s.IPAddress, &s.UserAgent,
+			&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("scanning session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSessionRepo signs one device out: its refresh token stops working and its
+// signing secret is forgotten.
+func (api *API) RevokeSessionRepo(ctx context.Context, userID uuid.UUID, sessionID int64) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var deviceID *string
+		err := tx.QueryRow(ctx, `
+			UPDATE auth_tokens SET is_revoked = TRUE
+			WHERE id = $1 AND user_id = $2 AND token_type = 'refresh' AND is_revoked = FALSE AND expires_at > NOW()
+			RETURNING device_id`, sessionID, userID).Scan(&deviceID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if deviceID == nil {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM device_secrets WHERE user_id = $1 AND device_id = $2`, userID, *deviceID)
+		return err
+	})
+}
+
+// RevokeAllSessionsRepo signs every device out and returns how many sessions it ended.
+func (api *API) RevokeAllSessionsRepo(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var revoked int64
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE auth_tokens SET is_revoked = TRUE
+			WHERE user_id = $1 AND token_type = 'refresh' AND is_revoked = FALSE AND expires_at > NOW()`, userID)
+		if err != nil {
+			return err
+		}
+		revoked = tag.RowsAffected()
+		_, err = tx.Exec(ctx, `DELETE FROM device_secrets WHERE user_id = $1`, userID)
+		return err
+	})
+	return revoked, err
+}
+
+// RotateRefreshTokenRepo replaces oldToken with newToken, carrying over the session's
+// device details and login time.
+func (api *API) RotateRefreshTokenRepo(ctx context.Context, oldToken, newToken string, expiresAt time.Time) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO auth_tokens (user_id, token_type, token_value, expires_at, created_at,
+				device_id, device_model, platform, ip_address, user_agent, last_used_at)
+			SELECT user_id, 'refresh', $2, $3, created_at,
+				device_id, device_model, platform, ip_address, user_agent, NOW()
+			FROM auth_tokens
+			WHERE token_value = $1 AND token_type = 'refresh' AND is_revoked = FALSE`,
+			oldToken, newToken, expiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to store new refresh token: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			// Revoked since it was validated, e.g. by a sign-out from another device.
+			return ErrInvalidToken
+		}
+		if _, err := tx.Exec(ctx, `UPDATE auth_tokens SET is_revoked = TRUE WHERE token_value = $1`, oldToken); err != nil {
+			return fmt.Errorf("failed to revoke old refresh token: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// signedRequestMaxBytes caps the body VerifySignedRequest reads to check a signature.
+	signedRequestMaxBytes = 1 << 20
+	// signedReportMaxBytes caps signed POST /reports bodies: a photo of up to
+	// maxUploadBytes plus room for the multipart framing and form fields.
+	signedReportMaxBytes = maxUploadBytes + 1<<20
+	// nonceSweepInterval is how often nonces past their replay window are deleted.
+	nonceSweepInterval = 10 * time.Minute
+)
+
+// RunNonceSweeper deletes expired request nonces every 10 minutes until ctx is cancelled.
+// A nonce only has to outlive the window in which its signature is accepted.
+func (api *API) RunNonceSweeper(ctx context.Context) {
+	ticker := time.NewTicker(nonceSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !api.dbAvailable() {
+				continue
+			}
+			n, err := api.PurgeExpiredRequestNoncesRepo(ctx)
+			if err != nil {
+				api.logger().ErrorContext(ctx, "Purging expired request nonces failed", "err", err)
+			} else if n > 0 {
+				api.logger().InfoContext(ctx, "Purged expired request nonces", "count", n)
+			}
+		}
+	}
+}
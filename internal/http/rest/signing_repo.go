@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrDeviceSecretNotFound = fmt.Errorf("device secret %w", ErrNotFound)
+	ErrNonceReused          = errors.New("request nonce already used")
+)
+
+// UpsertDeviceSecret stores (or rotates) the signing secret for a user's device and
+// refreshes the device fingerprint recorded in the registry.
+func (api *API) UpsertDeviceSecret(ctx context.Context, userID string, device model.DeviceInfo, secret string) error {
+	q := `
+		INSERT INTO device_secrets (user_id, device_id, secret, device_model, platform, is_emulator, updated_at)
+		VALUES ($1::uuid, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, now())
+		ON CONFLICT (user_id, device_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			device_model = EXCLUDED.device_model,
+			platform = EXCLUDED.platform,
+			is_emulator = EXCLUDED.is_emulator,
+			updated_at = now()
+	`
+	_, err := api.DB.Exec(ctx, q, userID, device.DeviceID, secret, device.Model, device.Platform, device.IsEmulator)
+	return err
+}
+
+// GetDevice returns the registry entry for the user's device.
+func (api *API) GetDevice(ctx context.Context, userID, deviceID string) (model.DeviceInfo, error) {
+	var device model.DeviceInfo
+	var deviceModel, platform *string
+	q := `SELECT device_id, device_model, platform, is_emulator FROM device_secrets WHERE user_id = $1::uuid AND device_id = $2`
+	err := api.DB.QueryRow(ctx, q, userID, deviceID).Scan(&device.DeviceID, &deviceModel, &platform, &device.IsEmulator)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.DeviceInfo{}, ErrDeviceSecretNotFound
+	}
+	if err != nil {
+		return model.DeviceInfo{}, err
+	}
+	if deviceModel != nil {
+		device.Model = *deviceModel
+	}
+	if platform != nil {
+		device.Platform = *platform
+	}
+	return device, nil
+}
+
+// GetDeviceSecret returns the signing secret issued to the user's device.
+func (api *API) GetDeviceSecret(ctx context.Context, userID, deviceID string) (string, error) {
+	var secret string
+	q := `SELECT secret FROM device_secrets WHERE user_id = $1::uuid AND device_id = $2`
+	err := api.DB.QueryRow(ctx, q, userID, deviceID).Scan(&secret)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrDeviceSecretNotFound
+	}
+	return secret, err
+}
+
+// ConsumeRequestNonce records a nonce for the user. It returns ErrNonceReused if the
+// nonce was already seen and has not yet expired, which indicates a replayed request.
+func (api *API) ConsumeRequestNonce(ctx context.Context, userID, nonce string, expiresAt time.Time) error {
+	q := `
+		INSERT INTO request_nonces (user_id, nonce, expires_at)
+		VALUES ($1::uuid, $2, $3)
+		ON CONFLICT (user_id, nonce) DO UPDATE SET
+			expires_at = EXCLUDED.expires_at
+		WHERE request_nonces.expires_at < now()
+	`
+	tag, err := api.DB.Exec(ctx, q, userID, nonce, expiresAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNonceReused
+	}
+	return nil
+}
+
+// PurgeExpiredRequestNoncesRepo deletes nonces past their replay window and returns how
+// many went.
+func (api *API) PurgeExpiredRequestNoncesRepo(ctx context.Context) (int64, error) {
+	tag, err := api.DB.Exec(ctx, `DELETE FROM request_nonces WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
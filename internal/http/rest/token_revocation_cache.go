@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// tokenRevocationCacheTTL bounds how long another instance may keep accepting a token
+	// after it is signed out. Logouts on this instance take effect at once.
+	tokenRevocationCacheTTL        = 30 * time.Second
+	tokenRevocationCacheMaxEntries = 10000
+)
+
+// tokenRevocationCache remembers recent access token denylist lookups by JTI, so an
+// authenticated request doesn't cost a database round trip each time.
+type tokenRevocationCache struct {
+	mu      sync.Mutex
+	entries map[string]tokenRevocationEntry
+}
+
+type tokenRevocationEntry struct {
+	revoked  bool
+	storedAt time.Time
+}
+
+func (c *tokenRevocationCache) put(jti string, revoked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]tokenRevocationEntry)
+	}
+	if len(c.entries) >= tokenRevocationCacheMaxEntries {
+		for k, e := range c.entries {
+			if time.Since(e.storedAt) > tokenRevocationCacheTTL {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= tokenRevocationCacheMaxEntries {
+			c.entries = make(map[string]tokenRevocationEntry)
+		}
+	}
+	c.entries[jti] = tokenRevocationEntry{revoked: revoked, storedAt: time.Now()}
+}
+
+// get returns the cached lookup for jti, if there is a fresh one.
+func (c *tokenRevocationCache) get(jti string) (revoked, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, found := c.entries[jti]
+	if !found || time.Since(e.storedAt) > tokenRevocationCacheTTL {
+		return false, false
+	}
+	return e.revoked, true
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/config"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+func TestIntrospectRevokedToken(t *testing.T) {
+	api := &API{Config: &config.Config{JwtSecret: "secret", JwtExpires: "15m"}}
+	token, _, err := api.createToken("6f1c2d9e-4b8a-4f7e-9a51-0c3d2b1e8f77")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := api.verifyToken(token, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both lookups are answered from the cache; there is no database here.
+	api.revokedTokens.put(claims.JTI, false)
+	info, status, _, err := api.IntrospectAccessToken(context.Background(), token)
+	if err != nil || status != values.Success || !info.Active {
+		t.Fatalf("live token: %+v, %s, %v", info, status, err)
+	}
+
+	api.revokedTokens.put(claims.JTI, true)
+	info, status, _, err = api.IntrospectAccessToken(context.Background(), token)
+	if !errors.Is(err, ErrTokenRevoked) || status != values.NotAuthorised || info.Active {
+		t.Errorf("revoked token: %+v, %s, %v", info, status, err)
+	}
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) TrafficRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		// Batched GPS fixes from one drive, map-matched into per-segment speeds
+		// Request Body: { "samples": [{ "latitude": 35.18, "longitude": 33.36, "accuracy": 8,
+		//   "recorded_at": "2024-05-01T08:00:00Z" }, ...] } (2-100 samples)
+		r.With(api.VerifySignedRequest, api.RateLimit(RateLimitTrafficSamples)).Method(http.MethodPost, "/samples", Handler(api.IngestTrafficSamplesHandler))
+		// Current speed and congestion of recently driven segments, for the map overlay
+		// Query Params: ?bbox=minLng,minLat,maxLng,maxLat (each side at most 0.5 degrees)
+		r.Method(http.MethodGet, "/segments", Handler(api.ListTrafficSegmentsHandler))
+	})
+
+	return mux
+}
+
+func (api *API) IngestTrafficSamplesHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	var req model.TrafficSamplesRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	result, status, message, err := api.IngestTrafficSamplesHelper(r.Context(), req)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       result,
+	}
+}
+
+func (api *API) ListTrafficSegmentsHandler(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	bbox, err := parseBBox(r.URL.Query().Get("bbox"))
+	if err != nil {
+		return respondWithError(err, "bbox must be minLng,minLat,maxLng,maxLat", values.BadRequestBody, &tc)
+	}
+
+	segments, status, message, err := api.ListTrafficSegmentsHelper(r.Context(), *bbox)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       segments,
+	}
+}
@@ -0,0 +1,207 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/values"
+)
+
+const (
+	// trafficFreshness is how long a segment's speed is shown after its last observation.
+	trafficFreshness = "15 minutes"
+	// trafficMinSamples hides segments seen by fewer recent observations, both to smooth
+	// out one odd driver and so the overlay never traces a single user's drive.
+	trafficMinSamples = 3
+	// trafficSpeedSmoothing is the weight of a new observation in the moving average.
+	trafficSpeedSmoothing = 0.3
+	// trafficFreeFlowDecay lowers the free-flow maximum a little on every observation so
+	// one speeding driver doesn't make the road look congested forever.
+	trafficFreeFlowDecay = 0.995
+
+	// Fixes less accurate than this are dropped before map matching; Mapbox accepts
+	// radiuses up to 50 m.
+	trafficMaxAccuracyM     = 50.0
+	trafficDefaultAccuracyM = 25.0
+	// An observation spans at least trafficMinMoveM, so a crawling car still yields one,
+	// and at most trafficMaxGap; longer gaps are stops or lost signal.
+	trafficMinMoveM = 10.0
+	trafficMaxGap   = time.Minute
+	// trafficMaxSpeedMS (~250 km/h) drops matches that jumped between roads.
+	trafficMaxSpeedMS = 70.0
+
+	// trafficMaxBBoxDeg caps each side of the overlay box.
+	trafficMaxBBoxDeg  = 0.5
+	trafficMaxSegments = 2000
+)
+
+// Congestion levels of a traffic segment, from its speed against free flow.
+const (
+	TrafficFree     = "free"
+	TrafficModerate = "moderate"
+	TrafficHeavy    = "heavy"
+	TrafficSevere   = "severe"
+)
+
+// IngestTrafficSamplesHelper map-matches a batch of fixes and folds the speeds driven
+// between them into the traffic segments. Batches Mapbox can't match are accepted and
+// simply contribute nothing.
+func (api *API) IngestTrafficSamplesHelper(ctx context.Context, req model.TrafficSamplesRequest) (model.TrafficIngestResult, string, string, error) {
+	result := model.TrafficIngestResult{Samples: len(req.Samples)}
+	if api.MapboxClient == nil {
+		return result, values.SystemErr, "Traffic ingestion is not configured", errors.New("mapbox client not configured")
+	}
+
+	samples := make([]model.TrafficSample, 0, len(req.Samples))
+	for _, s := range req.Samples {
+		lat, lng, err := util.NormalizeCoordinate(s.Latitude, s.Longitude)
+		if err != nil || (s.Accuracy != nil && *s.Accuracy > trafficMaxAccuracyM) {
+			continue
+		}
+		s.Latitude, s.Longitude = lat, lng
+		samples = append(samples, s)
+	}
+	slices.SortStableFunc(samples, func(a, b model.TrafficSample) int {
+		return a.RecordedAt.Compare(b.RecordedAt)
+	})
+	if len(samples) < 2 {
+		return result, values.Success, "No usable samples in batch", nil
+	}
+
+	coordinates := make([]string, len(samples))
+	radiuses := make([]string, len(samples))
+	for i, s := range samples {
+		coordinates[i] = fmt.Sprintf("%f,%f", s.Longitude, s.Latitude)
+		accuracy := trafficDefaultAccuracyM
+		if s.Accuracy != nil {
+			accuracy = max(*s.Accuracy, 5)
+		}
+		radiuses[i] = strconv.FormatFloat(accuracy, 'f', 0, 64)
+	}
+	radiusParam := strings.Join(radiuses, ";")
+	match, err := api.MapboxClient.MapMatching(ctx, coordinates, "", "geojson", &radiusParam)
+	if err != nil {
+		if errors.Is(err, mapbox.ErrNoMatch) {
+			return result, values.Success, "No road matched the samples", nil
+		}
+		return result, values.Error, "Failed to match samples to roads", err
+	}
+
+	observations := trafficObservations(samples, match.Tracepoints)
+	for _, tp := range match.Tracepoints {
+		if len(tp.Location) == 2 {
+			result.Matched++
+		}
+	}
+	result.Segments = len(observations)
+	if len(observations) == 0 {
+		return result, values.Success, "Samples recorded", nil
+	}
+	if err := api.UpsertTrafficObservationsRepo(ctx, observations, trafficFreshness); err != nil {
+		return result, values.Error, "Failed to record traffic", err
+	}
+	return result, values.Success, "Samples recorded", nil
+}
+
+// trafficObservations turns consecutive matched fixes into speeds. Each observation runs
+// from an anchor fix to the first later fix at least trafficMinMoveM away on the same
+// matching; unmatched fixes and long gaps start over from the next fix.
+func trafficObservations(samples []model.TrafficSample, tracepoints []mapbox.Tracepoint) []model.TrafficObservation {
+	if len(tracepoints) != len(samples) {
+		return nil
+	}
+	var out []model.TrafficObservation
+	anchor := -1
+	for i, tp := range tracepoints {
+		if len(tp.Location) != 2 {
+			anchor = -1
+			continue
+		}
+		if anchor < 0 {
+			anchor = i
+			continue
+		}
+		a := tracepoints[anchor]
+		dt := samples[i].RecordedAt.Sub(samples[anchor].RecordedAt)
+		if tp.MatchingsIndex != a.MatchingsIndex || dt > trafficMaxGap {
+			anchor = i
+			continue
+		}
+		dist := util.HaversineMeters(a.Location[1], a.Location[0], tp.Location[1], tp.Location[0])
+		if dist < trafficMinMoveM || dt <= 0 {
+			continue
+		}
+		anchor = i
+		speed := dist / dt.Seconds()
+		if speed > trafficMaxSpeedMS {
+			continue
+		}
+		out = append(out, model.TrafficObservation{
+			RoadName:      strings.TrimSpace(a.Name),
+			BearingBucket: bearingBucket(a.Location, tp.Location),
+			From:          a.Location,
+			To:            tp.Location,
+			SpeedMS:       speed,
+		})
+	}
+	return out
+}
+
+// bearingBucket returns the compass direction from a to b ([lng, lat]) in eight 45°
+// buckets, 0 being north, so each direction of a road is its own segment.
+func bearingBucket(a, b []float64) int {
+	return int(math.Round(initialBearing(a, b)/45)) % 8
+}
+
+// initialBearing returns the compass bearing from a to b ([lng, lat]) in degrees
+// clockwise from north, in [0, 360).
+func initialBearing(a, b []float64) float64 {
+	lat1, lat2 := a[1]*math.Pi/180, b[1]*math.Pi/180
+	dLng := (b[0] - a[0]) * math.Pi / 180
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// trafficCongestion grades a segment's speed against its free-flow speed.
+func trafficCongestion(speed, freeFlow float64) string {
+	if freeFlow <= 0 {
+		return TrafficFree
+	}
+	switch ratio := speed / freeFlow; {
+	case ratio >= 0.75:
+		return TrafficFree
+	case ratio >= 0.5:
+		return TrafficModerate
+	case ratio >= 0.25:
+		return TrafficHeavy
+	}
+	return TrafficSevere
+}
+
+func msToKmh(ms float64) float64 {
+	return math.Round(ms*3.6*10) / 10
+}
+
+func (api *API) ListTrafficSegmentsHelper(ctx context.Context, box model.BoundingBox) ([]model.TrafficSegment, string, string, error) {
+	if box.MaxLng-box.MinLng > trafficMaxBBoxDeg || box.MaxLat-box.MinLat > trafficMaxBBoxDeg {
+		return nil, values.BadRequestBody, fmt.Sprintf("bbox sides may span at most %g degrees", trafficMaxBBoxDeg), errors.New("traffic bbox too large")
+	}
+	segments, err := api.ListTrafficSegmentsRepo(ctx, box, trafficFreshness, trafficMinSamples, trafficMaxSegments)
+	if err != nil {
+		return nil, values.Error, "Failed to fetch traffic", err
+	}
+	if segments == nil {
+		segments = []model.TrafficSegment{}
+	}
+	return segments, values.Success, "Traffic fetched successfully", nil
+}
@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/mapbox"
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestTrafficObservations(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	at := func(s int) model.TrafficSample {
+		return model.TrafficSample{RecordedAt: start.Add(time.Duration(s) * time.Second)}
+	}
+	samples := []model.TrafficSample{at(0), at(5), at(10), at(15), at(20), at(100), at(105)}
+	// Heading north on one road, ~1.1 m per 0.00001° of latitude.
+	tracepoints := []mapbox.Tracepoint{
+		{Name: "Ataturk Cd", Location: []float64{33.36, 35.18}},
+		{Name: "Ataturk Cd", Location: []float64{33.36, 35.18005}}, // 5.6 m: too short, kept as crawl
+		{Name: "Ataturk Cd", Location: []float64{33.36, 35.1801}},  // 11 m in 10 s from the anchor
+		{}, // unmatched: starts over
+		{Name: "Ataturk Cd", Location: []float64{33.36, 35.1802}},   // new anchor
+		{Name: "Ataturk Cd", Location: []float64{33.36, 35.1812}},   // 80 s later: gap too long
+		{Name: "Ataturk Cd", Location: []float64{33.3612, 35.1812}}, // ~109 m east in 5 s
+	}
+
+	got := trafficObservations(samples, tracepoints)
+	if len(got) != 2 {
+		t.Fatalf("got %d observations, want 2: %+v", len(got), got)
+	}
+	if o := got[0]; o.BearingBucket != 0 || math.Abs(o.SpeedMS-1.11) > 0.01 || o.RoadName != "Ataturk Cd" {
+		t.Errorf("first observation = %+v, want north at ~1.11 m/s", o)
+	}
+	if o := got[1]; o.BearingBucket != 2 || math.Abs(o.SpeedMS-21.9) > 0.2 {
+		t.Errorf("second observation = %+v, want east at ~21.9 m/s", o)
+	}
+
+	if got := trafficObservations(samples[:2], tracepoints); got != nil {
+		t.Errorf("mismatched tracepoints gave %+v", got)
+	}
+}
+
+func TestTrafficCongestion(t *testing.T) {
+	for _, tc := range []struct {
+		speed, freeFlow float64
+		want            string
+	}{
+		{20, 20, TrafficFree},
+		{12, 20, TrafficModerate},
+		{6, 20, TrafficHeavy},
+		{2, 20, TrafficSevere},
+		{5, 0, TrafficFree},
+	} {
+		if got := trafficCongestion(tc.speed, tc.freeFlow); got != tc.want {
+			t.Errorf("trafficCongestion(%v, %v) = %q, want %q", tc.speed, tc.freeFlow, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// UpsertTrafficObservationsRepo folds observations into their segments. The moving
+// average restarts from the observation when the segment has been quiet for longer than
+// freshness, so the overlay never shows how a road was driven hours ago.
+func (api *API) UpsertTrafficObservationsRepo(ctx context.Context, observations []model.TrafficObservation, freshness string) error {
+	stmt := `
+		INSERT INTO traffic_segments (segment_key, road_name, bearing_bucket, geom, speed_ms, free_flow_ms, sample_count, recent_samples)
+		VALUES (
+			$1::text || '|' || ST_GeoHash(ST_SetSRID(ST_MakePoint(($3::float8 + $5::float8) / 2, ($4::float8 + $6::float8) / 2), 4326), 7) || '|' || $2::smallint,
+			$1, $2, ST_SetSRID(ST_MakeLine(ST_MakePoint($3, $4), ST_MakePoint($5, $6)), 4326), $7, $7, 1, 1
+		)
+		ON CONFLICT (segment_key) DO UPDATE SET
+			speed_ms = CASE
+				WHEN traffic_segments.updated_at < NOW() - $8::interval THEN EXCLUDED.speed_ms
+				ELSE traffic_segments.speed_ms * (1 - $9::float8) + EXCLUDED.speed_ms * $9::float8
+			END,
+			free_flow_ms = GREATEST(traffic_segments.free_flow_ms * $10::float8, EXCLUDED.speed_ms),
+			sample_count = traffic_segments.sample_count + 1,
+			recent_samples = CASE
+				WHEN traffic_segments.updated_at < NOW() - $8::interval THEN 1
+				ELSE traffic_segments.recent_samples + 1
+			END,
+			updated_at = NOW()
+	`
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		for _, o := range observations {
+			if _, err := tx.Exec(ctx, stmt, o.RoadName, o.BearingBucket,
+				o.From[0], o.From[1], o.To[0], o.To[1], o.SpeedMS,
+				freshness, trafficSpeedSmoothing, trafficFreeFlowDecay); err != nil {
+				return fmt.Errorf("upserting traffic segment on %q: %w", o.RoadName, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListTrafficSegmentsRepo returns the segments in the box updated within freshness and
+// seen at least minSamples times since, slowest first.
+func (api *API) ListTrafficSegmentsRepo(ctx context.Context, box model.BoundingBox, freshness string, minSamples, limit int) ([]model.TrafficSegment, error) {
+	query := `
+		SELECT segment_key, road_name,
+			ST_X(ST_StartPoint(geom)), ST_Y(ST_StartPoint(geom)),
+			ST_X(ST_EndPoint(geom)), ST_Y(ST_EndPoint(geom)),
+			speed_ms, free_flow_ms, recent_samples, updated_at
+		FROM traffic_segments
+		WHERE geom && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+		  AND updated_at > NOW() - $5::interval
+		  AND recent_samples >= $6
+		ORDER BY speed_ms / NULLIF(free_flow_ms, 0)
+		LIMIT $7
+	`
+	rows, err := api.DB.Query(ctx, query, box.MinLng, box.MinLat, box.MaxLng, box.MaxLat, freshness, minSamples, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying traffic segments: %w", err)
+	}
+	defer rows.Close()
+
+	var segments []model.TrafficSegment
+	for rows.Next() {
+		var s model.TrafficSegment
+		var x1, y1, x2, y2, speed, freeFlow float64
+		if err := rows.Scan(&s.SegmentKey, &s.RoadName, &x1, &y1, &x2, &y2, &speed, &freeFlow, &s.Samples, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning traffic segment: %w", err)
+		}
+		s.Coordinates = [][]float64{{x1, y1}, {x2, y2}}
+		s.SpeedKmh = msToKmh(speed)
+		s.FreeFlowKmh = msToKmh(freeFlow)
+		s.Congestion = trafficCongestion(speed, freeFlow)
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
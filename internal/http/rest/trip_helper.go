@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	// tripMinDistanceM keeps sessions that were started and abandoned in the driveway out
+	// of the trip history.
+	tripMinDistanceM = 100.0
+
+	defaultTripsPageSize = 20
+	maxTripsPageSize     = 100
+
+	defaultTripStatsPeriods = 12
+	maxTripStatsPeriods     = 52
+	// tripStatsTimeZone decides where weeks and months start; users are in Cyprus.
+	tripStatsTimeZone = "Europe/Nicosia"
+)
+
+// Trip statistics periods.
+const (
+	TripPeriodWeek  = "week"
+	TripPeriodMonth = "month"
+)
+
+func (api *API) ListTripsHelper(ctx context.Context, userID uuid.UUID, page, pageSize int) (model.TripList, string, string, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultTripsPageSize
+	}
+	pageSize = min(pageSize, maxTripsPageSize)
+
+	trips, total, err := api.ListTripsRepo(ctx, userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return model.TripList{}, values.Error, "Failed to fetch trips", err
+	}
+	if trips == nil {
+		trips = []model.Trip{}
+	}
+	return model.TripList{Trips: trips, Total: total, Page: page, PageSize: pageSize}, values.Success, "Trips fetched successfully", nil
+}
+
+// TripStatsHelper aggregates the user's trips per week or month over the latest periods
+// they drove in.
+func (api *API) TripStatsHelper(ctx context.Context, userID uuid.UUID, period string, periods int) (model.TripStats, string, string, error) {
+	if period == "" {
+		period = TripPeriodWeek
+	}
+	if period != TripPeriodWeek && period != TripPeriodMonth {
+		return model.TripStats{}, values.BadRequestBody, "period must be week or month", fmt.Errorf("unknown trip stats period %q", period)
+	}
+	if periods < 1 {
+		periods = defaultTripStatsPeriods
+	}
+	periods = min(periods, maxTripStatsPeriods)
+
+	buckets, err := api.TripStatsRepo(ctx, userID, period, tripStatsTimeZone, periods)
+	if err != nil {
+		return model.TripStats{}, values.Error, "Failed to fetch trip statistics", err
+	}
+	return summarizeTrips(period, buckets), values.Success, "Trip statistics fetched successfully", nil
+}
+
+// summarizeTrips fills in each bucket's average speed and adds up the totals.
+func summarizeTrips(period string, buckets []model.TripStatsBucket) model.TripStats {
+	stats := model.TripStats{Period: period, Buckets: buckets}
+	if stats.Buckets == nil {
+		stats.Buckets = []model.TripStatsBucket{}
+	}
+	for i := range stats.Buckets {
+		b := &stats.Buckets[i]
+		b.AvgSpeedKmh = tripAvgSpeedKmh(b.DistanceM, b.DurationS)
+		stats.Totals.Trips += b.Trips
+		stats.Totals.DistanceM += b.DistanceM
+		stats.Totals.DurationS += b.DurationS
+	}
+	stats.Totals.AvgSpeedKmh = tripAvgSpeedKmh(stats.Totals.DistanceM, stats.Totals.DurationS)
+	return stats
+}
+
+func tripAvgSpeedKmh(distanceM float64, durationS int) float64 {
+	if durationS <= 0 {
+		return 0
+	}
+	return msToKmh(distanceM / float64(durationS))
+}
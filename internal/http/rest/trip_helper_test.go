@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"testing"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+)
+
+func TestSummarizeTrips(t *testing.T) {
+	stats := summarizeTrips(TripPeriodWeek, []model.TripStatsBucket{
+		{Start: "2024-04-29", Trips: 3, DistanceM: 30000, DurationS: 1800},
+		{Start: "2024-04-22", Trips: 1, DistanceM: 10000, DurationS: 1200},
+	})
+	if got := stats.Buckets[0].AvgSpeedKmh; got != 60 {
+		t.Errorf("first week average = %v km/h, want 60", got)
+	}
+	if got := stats.Buckets[1].AvgSpeedKmh; got != 30 {
+		t.Errorf("second week average = %v km/h, want 30", got)
+	}
+	want := model.TripStatsBucket{Trips: 4, DistanceM: 40000, DurationS: 3000, AvgSpeedKmh: 48}
+	if stats.Totals != want {
+		t.Errorf("totals = %+v, want %+v", stats.Totals, want)
+	}
+
+	empty := summarizeTrips(TripPeriodMonth, nil)
+	if empty.Buckets == nil || empty.Totals.AvgSpeedKmh != 0 {
+		t.Errorf("no trips gave %+v", empty)
+	}
+}
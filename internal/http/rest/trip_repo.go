@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+)
+
+// ListTripsRepo returns a page of the user's trips, newest first, and how many there are.
+func (api *API) ListTripsRepo(ctx context.Context, userID uuid.UUID, limit, offset int) ([]model.Trip, int, error) {
+	var total int
+	if err := api.DB.QueryRow(ctx, `SELECT COUNT(*) FROM trips WHERE user_id = $1`, userID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("counting trips: %w", err)
+	}
+
+	query := `
+		SELECT id, session_id, status, distance_m, duration_s, avg_speed_ms,
+			ST_AsEncodedPolyline(route_geom, 5), ST_Y(destination), ST_X(destination),
+			destination_name, profile, started_at, finished_at
+		FROM trips
+		WHERE user_id = $1
+		ORDER BY started_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := api.DB.Query(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying trips: %w", err)
+	}
+	defer rows.Close()
+
+	var trips []model.Trip
+	for rows.Next() {
+		var t model.Trip
+		var avgSpeed float64
+		if err := rows.Scan(&t.ID, &t.SessionID, &t.Status, &t.DistanceM, &t.DurationS, &avgSpeed,
+			&t.Polyline, &t.DestinationLat, &t.DestinationLng,
+			&t.DestinationName, &t.Profile, &t.StartedAt, &t.FinishedAt); err != nil {
+			return nil, 0, fmt.Errorf("scanning trip: %w", err)
+		}
+		t.AvgSpeedKmh = msToKmh(avgSpeed)
+		trips = append(trips, t)
+	}
+	return trips, total, rows.Err()
+}
+
+// TripStatsRepo aggregates the user's trips per period (week or month) in the given time
+// zone, for the latest periods that have any trips.
+func (api *API) TripStatsRepo(ctx context.Context, userID uuid.UUID, period, timeZone string, periods int) ([]model.TripStatsBucket, error) {
+	query := `
+		SELECT to_char(date_trunc($2, started_at AT TIME ZONE $3), 'YYYY-MM-DD') AS start,
+			COUNT(*), SUM(distance_m), SUM(duration_s)
+		FROM trips
+		WHERE user_id = $1
+		GROUP BY start
+		ORDER BY start DESC
+		LIMIT $4
+	`
+	rows, err := api.DB.Query(ctx, query, userID, period, timeZone, periods)
+	if err != nil {
+		return nil, fmt.Errorf("querying trip stats: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []model.TripStatsBucket
+	for rows.Next() {
+		var b model.TripStatsBucket
+		if err := rows.Scan(&b.Start, &b.Trips, &b.DistanceM, &b.DurationS); err != nil {
+			return nil, fmt.Errorf("scanning trip stats: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/util"
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+)
+
+func (api *API) UploadRoutes() chi.Router {
+	mux := chi.NewRouter()
+
+	mux.Group(func(r chi.Router) {
+		r.Use(api.RequireLogin)
+		// Report image, moderated and stored ahead of POST /reports; pass the returned url
+		// as the report's image_url. Unused uploads are deleted after a day.
+		// Request Body: multipart/form-data with an "image" file (JPEG, PNG or WebP, max 10 MB)
+		r.With(api.RateLimit(RateLimitImageUpload)).Method(http.MethodPost, "/", Handler(api.UploadImageHandler))
+	})
+
+	return mux
+}
+
+func (api *API) UploadImageHandler(w http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return respondWithError(err, "image file required (max 10 MB)", values.BadRequestBody, &tc)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadBytes+1))
+	if err != nil {
+		return respondWithError(err, "failed to read image", values.BadRequestBody, &tc)
+	}
+
+	upload, status, message, err := api.UploadImageHelper(r.Context(), userID, data)
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       upload,
+	}
+}
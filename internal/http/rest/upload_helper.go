@@ -0,0 +1,186 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/storage"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+)
+
+const (
+	maxUploadBytes     = 10 << 20
+	maxUploadDimension = 8000
+
+	uploadCleanInterval = 15 * time.Minute
+	uploadCleanBatch    = 100
+	// Uploads never used for a report are removed after unattachedUploadTTL; images of
+	// ended reports are kept for endedReportImageRetention for the timeline and appeals.
+	unattachedUploadTTL       = 24 * time.Hour
+	endedReportImageRetention = 24 * time.Hour
+)
+
+// uploadContentTypes are the image formats accepted for report images.
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+var errUnsupportedImage = errors.New("unsupported image")
+
+// uploadImageInfo is what validation learns about an image.
+type uploadImageInfo struct {
+	ContentType   string
+	SizeBytes     int
+	Width, Height *int
+}
+
+// validateUploadImage checks an image by its content rather than its name or declared
+// type. JPEG and PNG are also decoded far enough to check their dimensions; WebP, which
+// the standard library can't read, is accepted on its signature alone.
+func validateUploadImage(data []byte) (uploadImageInfo, error) {
+	if len(data) == 0 {
+		return uploadImageInfo{}, fmt.Errorf("%w: empty file", errUnsupportedImage)
+	}
+	if len(data) > maxUploadBytes {
+		return uploadImageInfo{}, fmt.Errorf("%w: larger than %d MB", errUnsupportedImage, maxUploadBytes>>20)
+	}
+	info := uploadImageInfo{ContentType: http.DetectContentType(data), SizeBytes: len(data)}
+	if !uploadContentTypes[info.ContentType] {
+		return uploadImageInfo{}, fmt.Errorf("%w: %s", errUnsupportedImage, info.ContentType)
+	}
+	if info.ContentType == "image/webp" {
+		return info, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return uploadImageInfo{}, fmt.Errorf("%w: %w", errUnsupportedImage, err)
+	}
+	if cfg.Width > maxUploadDimension || cfg.Height > maxUploadDimension {
+		return uploadImageInfo{}, fmt.Errorf("%w: larger than %dx%d", errUnsupportedImage, maxUploadDimension, maxUploadDimension)
+	}
+	info.Width, info.Height = &cfg.Width, &cfg.Height
+	return info, nil
+}
+
+// UploadImageHelper validates, moderates and stores a report image ahead of the report.
+// Rejected images are kept out of the public folder for appeals and fail the upload.
+func (api *API) UploadImageHelper(ctx context.Context, userID uuid.UUID, data []byte) (model.Upload, string, string, error) {
+	info, err := validateUploadImage(data)
+	if err != nil {
+		return model.Upload{}, values.BadRequestBody, "Image must be a JPEG, PNG or WebP of at most 10 MB", err
+	}
+
+	v := api.moderateImage(ctx, data)
+	folder := reportImageFolder
+	if v.Decision != ImageDecisionApproved {
+		folder = moderationImageFolder
+	}
+	asset, err := api.uploadReportImage(ctx, data, folder)
+	if err != nil {
+		return model.Upload{}, values.Error, "Failed to upload image", err
+	}
+	if v.Decision == ImageDecisionRejected {
+		api.logImageModeration(ctx, userID, nil, &asset.URL, v)
+		return model.Upload{}, values.Unprocessable, "Image rejected by content moderation", fmt.Errorf("image rejected: %s", *v.Reason)
+	}
+
+	upload, err := api.recordUpload(ctx, userID, nil, asset, info, v)
+	if err != nil {
+		return model.Upload{}, values.Error, "Failed to record upload", err
+	}
+	return upload, values.Success, "Image uploaded", nil
+}
+
+// recordUpload tracks a stored image so the cleaner can remove it later.
+func (api *API) recordUpload(ctx context.Context, userID uuid.UUID, reportID *int64, asset storage.ImageAsset, info uploadImageInfo, v imageVerdict) (model.Upload, error) {
+	return api.InsertUploadRepo(ctx, reportUpload{
+		Upload: model.Upload{
+			URL:          asset.URL,
+			ThumbnailURL: storage.ThumbnailURL(asset.URL),
+			ContentType:  info.ContentType,
+			SizeBytes:    info.SizeBytes,
+			Width:        info.Width,
+			Height:       info.Height,
+			Status:       v.Decision,
+		},
+		UserID:   userID,
+		ReportID: reportID,
+		PublicID: asset.PublicID,
+		Verdict:  v,
+	})
+}
+
+// reportUploadFor looks up the user's unattached upload for a report's image_url. Only
+// images from POST /uploads are accepted, so every report image has been moderated.
+func (api *API) reportUploadFor(ctx context.Context, userID uuid.UUID, imageURL string) (reportUpload, string, string, error) {
+	upload, err := api.GetAttachableUploadRepo(ctx, userID, imageURL)
+	if err != nil {
+		if errors.Is(err, ErrUploadNotFound) {
+			return reportUpload{}, values.BadRequestBody, "image_url must be an unused image from POST /uploads", err
+		}
+		return reportUpload{}, values.Error, "Failed to look up image", err
+	}
+	return upload, values.Success, "", nil
+}
+
+// attachReportUpload ties an upload to the report created with it and logs its
+// moderation verdict against the report. Failures are logged; the report stands.
+func (api *API) attachReportUpload(ctx context.Context, upload reportUpload, reportID int64) {
+	if err := api.AttachUploadRepo(ctx, upload.ID, reportID); err != nil {
+		api.logger().WarnContext(ctx, "Failed to attach upload to report", "upload_id", upload.ID, "report_id", reportID, "err", err)
+	}
+	api.logImageModeration(ctx, upload.UserID, &reportID, &upload.URL, upload.Verdict)
+}
+
+// RunUploadCleaner removes orphaned report images from storage every 15 minutes until ctx
+// is cancelled.
+func (api *API) RunUploadCleaner(ctx context.Context) {
+	ticker := time.NewTicker(uploadCleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if api.dbAvailable() {
+				api.cleanOrphanedUploads(ctx)
+			}
+		}
+	}
+}
+
+func (api *API) cleanOrphanedUploads(ctx context.Context) {
+	now := time.Now()
+	uploads, err := api.ListOrphanedUploadsRepo(ctx, now.Add(-unattachedUploadTTL), now.Add(-endedReportImageRetention), uploadCleanBatch)
+	if err != nil {
+		api.logger().ErrorContext(ctx, "Listing orphaned uploads failed", "err", err)
+		return
+	}
+	removed := 0
+	for _, u := range uploads {
+		// A failed delete leaves the row live, so the next run retries it.
+		if err := api.Deps.Cloudinary.DeleteImage(ctx, u.PublicID); err != nil {
+			api.logger().WarnContext(ctx, "Deleting orphaned upload failed", "upload_id", u.ID, "err", err)
+			continue
+		}
+		if err := api.MarkUploadDeletedRepo(ctx, u.ID, u.URL); err != nil {
+			api.logger().ErrorContext(ctx, "Recording upload deletion failed", "upload_id", u.ID, "err", err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		api.logger().InfoContext(ctx, "Removed orphaned uploads", "count", removed)
+	}
+}
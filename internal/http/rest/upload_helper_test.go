@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/storage"
+)
+
+func TestValidateUploadImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	info, err := validateUploadImage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("valid PNG rejected: %v", err)
+	}
+	if info.ContentType != "image/png" || *info.Width != 40 || *info.Height != 30 || info.SizeBytes != buf.Len() {
+		t.Errorf("got %+v", info)
+	}
+
+	webp := append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 16)...)
+	if info, err := validateUploadImage(webp); err != nil || info.ContentType != "image/webp" {
+		t.Errorf("WebP: got %+v, %v", info, err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("not an image"),
+		"truncated": buf.Bytes()[:20],
+		"too large": append(buf.Bytes(), make([]byte, maxUploadBytes)...),
+	} {
+		if _, err := validateUploadImage(data); !errors.Is(err, errUnsupportedImage) {
+			t.Errorf("%s: got %v, want errUnsupportedImage", name, err)
+		}
+	}
+}
+
+func TestThumbnailURL(t *testing.T) {
+	got := storage.ThumbnailURL("https://res.cloudinary.com/demo/image/upload/v123/reports/abc.jpg")
+	want := "https://res.cloudinary.com/demo/image/upload/c_fill,g_auto,w_320,h_320,q_auto,f_auto/v123/reports/abc.jpg"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if other := "https://example.com/a.jpg"; storage.ThumbnailURL(other) != other {
+		t.Errorf("non-Cloudinary URL was rewritten")
+	}
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrUploadNotFound = fmt.Errorf("upload %w", ErrNotFound)
+
+// reportUpload is a stored report image with what the cleaner and attaching need.
+type reportUpload struct {
+	model.Upload
+	UserID   uuid.UUID
+	ReportID *int64
+	PublicID string
+	Verdict  imageVerdict
+}
+
+// InsertUploadRepo records a stored image, attached to a report right away when
+// ReportID is set.
+func (api *API) InsertUploadRepo(ctx context.Context, u reportUpload) (model.Upload, error) {
+	scores, err := json.Marshal(u.Verdict.Scores)
+	if err != nil {
+		return model.Upload{}, err
+	}
+	query := `
+        INSERT INTO report_uploads (user_id, report_id, public_id, url, content_type, size_bytes,
+                                    width, height, provider, decision, reason, scores)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        RETURNING id, created_at
+    `
+	out := u.Upload
+	err = api.DB.QueryRow(ctx, query, u.UserID, u.ReportID, u.PublicID, u.URL, u.ContentType, u.SizeBytes,
+		u.Width, u.Height, u.Verdict.Provider, u.Verdict.Decision, u.Verdict.Reason, scores,
+	).Scan(&out.ID, &out.CreatedAt)
+	return out, err
+}
+
+// GetAttachableUploadRepo returns the user's upload with this URL if it isn't attached to
+// a report yet.
+func (api *API) GetAttachableUploadRepo(ctx context.Context, userID uuid.UUID, url string) (reportUpload, error) {
+	query := `
+        SELECT id, url, public_id, provider, decision, reason, scores
+        FROM report_uploads
+        WHERE user_id = $1 AND url = $2 AND report_id IS NULL AND deleted_at IS NULL
+    `
+	u := reportUpload{UserID: userID}
+	var scores []byte
+	err := api.DB.QueryRow(ctx, query, userID, url).Scan(
+		&u.ID, &u.URL, &u.PublicID, &u.Verdict.Provider, &u.Verdict.Decision, &u.Verdict.Reason, &scores,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return reportUpload{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return reportUpload{}, err
+	}
+	if len(scores) > 0 {
+		if err := json.Unmarshal(scores, &u.Verdict.Scores); err != nil {
+			return reportUpload{}, err
+		}
+	}
+	return u, nil
+}
+
+// AttachUploadRepo ties an unattached upload to a report.
+func (api *API) AttachUploadRepo(ctx context.Context, uploadID uuid.UUID, reportID int64) error {
+	tag, err := api.DB.Exec(ctx, `
+        UPDATE report_uploads SET report_id = $2
+        WHERE id = $1 AND report_id IS NULL AND deleted_at IS NULL
+    `, uploadID, reportID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUploadNotFound
+	}
+	return nil
+}
+
+// ListOrphanedUploadsRepo returns up to limit live uploads nothing shows any more: never
+// attached (or their report was deleted) and older than unattachedBefore, or attached to
+// a report that expired or was deactivated before endedBefore.
+func (api *API) ListOrphanedUploadsRepo(ctx context.Context, unattachedBefore, endedBefore time.Time, limit int) ([]reportUpload, error) {
+	query := `
+        SELECT u.id, u.url, u.public_id
+        FROM report_uploads u
+        LEFT JOIN reports r ON r.id = u.report_id
+        WHERE u.deleted_at IS NULL
+          AND ((u.report_id IS NULL AND u.created_at < $1)
+            OR (r.id IS NOT NULL AND (r.expires_at < $2 OR (NOT r.active AND r.updated_at < $2))))
+        ORDER BY u.created_at
+        LIMIT $3
+    `
+	rows, err := api.DB.Query(ctx, query, unattachedBefore, endedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying orphaned uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []reportUpload
+	for rows.Next() {
+		var u reportUpload
+		if err := rows.Scan(&u.ID, &u.URL, &u.PublicID); err != nil {
+			return nil, fmt.Errorf("scanning orphaned upload: %w", err)
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
+
+// MarkUploadDeletedRepo records that an upload was removed from storage and drops the
+// now broken link from any report still pointing at it.
+func (api *API) MarkUploadDeletedRepo(ctx context.Context, uploadID uuid.UUID, url string) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `UPDATE report_uploads SET deleted_at = NOW() WHERE id = $1`, uploadID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `UPDATE reports SET image_url = NULL WHERE image_url = $1`, url)
+		return err
+	})
+}
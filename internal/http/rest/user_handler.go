@@ -3,6 +3,7 @@ package rest
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
 	"github.com/bwise1/waze_kibris/util"
@@ -20,10 +21,47 @@ func (api *API) UserRoutes() chi.Router {
 		r.Method(http.MethodGet, "/profile", Handler(api.GetProfile))
 		r.Method(http.MethodPut, "/profile", Handler(api.UpdateProfile))
 		r.Method(http.MethodPut, "/language", Handler(api.UpdateLanguage))
+		r.Method(http.MethodPut, "/route-preference", Handler(api.UpdateRoutePreference))
+		// Deactivates the account; it is deleted after 30 days unless the user logs in again
 		r.Method(http.MethodDelete, "/account", Handler(api.DeleteAccount))
+		// Signed-in devices (one per refresh token); the caller's own is marked current by X-Device-ID
+		r.Method(http.MethodGet, "/sessions", Handler(api.ListSessions))
+		r.Method(http.MethodDelete, "/sessions", Handler(api.RevokeAllSessions))
+		r.Method(http.MethodDelete, "/sessions/{sessionID}", Handler(api.RevokeSession))
 		r.Method(http.MethodGet, "/nearby-users", Handler(api.GetNearbyUsersHandler))
 		r.Method(http.MethodPost, "/fcm-token", Handler(api.RegisterFCMToken))
 		r.Method(http.MethodDelete, "/fcm-token", Handler(api.UnregisterFCMToken))
+		// Push devices; POST body: { "token": "<FCM registration token>", "platform": "ios" }
+		r.Method(http.MethodGet, "/devices", Handler(api.ListPushDevices))
+		r.Method(http.MethodPost, "/devices", Handler(api.RegisterPushDevice))
+		r.Method(http.MethodDelete, "/devices/{deviceID}", Handler(api.DeletePushDevice))
+		// Nearby report alerts; PUT body: { "report_alerts": true, "alert_radius_m": 2000,
+		//   "min_severity": 4, "report_types": ["ACCIDENT", "ROAD_CLOSED"] } (empty types = all)
+		r.Method(http.MethodGet, "/notification-preferences", Handler(api.GetNotificationPreferences))
+		r.Method(http.MethodPut, "/notification-preferences", Handler(api.UpdateNotificationPreferences))
+		// Watched areas alerted about new reports; POST/PUT body: { "name": "Commute",
+		//   "route": [[33.36, 35.18], ...], "corridor_width_m": 200 } or "polygon": [[lng, lat], ...],
+		//   plus optional "report_types", "min_severity" and "active"
+		r.Method(http.MethodGet, "/alert-zones", Handler(api.ListAlertZones))
+		r.Method(http.MethodPost, "/alert-zones", Handler(api.CreateAlertZone))
+		r.Method(http.MethodPut, "/alert-zones/{zoneID}", Handler(api.UpdateAlertZone))
+		r.Method(http.MethodDelete, "/alert-zones/{zoneID}", Handler(api.DeleteAlertZone))
+		r.With(api.VerifySignedRequest).Method(http.MethodPost, "/location", Handler(api.LocationHeartbeat))
+		r.Method(http.MethodGet, "/integrations/calendar", Handler(api.GetCalendarIntegration))
+		r.Method(http.MethodPut, "/integrations/calendar", Handler(api.ConnectCalendar))
+		r.Method(http.MethodDelete, "/integrations/calendar", Handler(api.DisconnectCalendar))
+		r.Method(http.MethodGet, "/integrations/calendar/drives", Handler(api.ListPlannedDrives))
+		r.Method(http.MethodPost, "/integrations/calendar/drives/{driveID}/dismiss", Handler(api.DismissPlannedDrive))
+		// Trip history from finished navigation sessions; ?page=1&pageSize=20
+		r.Method(http.MethodGet, "/trips", Handler(api.ListTrips))
+		// Driving per week or month; ?period=week|month&periods=12
+		r.Method(http.MethodGet, "/trips/stats", Handler(api.GetTripStats))
+		// Places navigated to, most visited and most recent first; ?limit=20. Off when the
+		//   settings body { "enabled": false } is sent, which also clears the history
+		r.Method(http.MethodGet, "/recent-destinations", Handler(api.ListRecentDestinations))
+		r.Method(http.MethodDelete, "/recent-destinations", Handler(api.ClearRecentDestinations))
+		r.Method(http.MethodDelete, "/recent-destinations/{destinationID}", Handler(api.DeleteRecentDestination))
+		r.Method(http.MethodPut, "/recent-destinations/settings", Handler(api.UpdateRecentDestinationSettings))
 	})
 
 	return mux
@@ -128,6 +166,34 @@ func (api *API) UpdateLanguage(_ http.ResponseWriter, r *http.Request) *ServerRe
 	}
 }
 
+func (api *API) UpdateRoutePreference(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.UpdateRoutePreferenceRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+
+	err = api.UpdateRoutePreferenceRepo(r.Context(), userID.String(), req.RoutePreference)
+	if err != nil {
+		return respondWithError(err, "failed to update route preference", values.Error, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    "Route preference updated successfully",
+		Status:     values.Success,
+		StatusCode: util.StatusCode(values.Success),
+	}
+}
+
 func (api *API) DeleteAccount(_ http.ResponseWriter, r *http.Request) *ServerResponse {
 	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
 
@@ -136,13 +202,61 @@ func (api *API) DeleteAccount(_ http.ResponseWriter, r *http.Request) *ServerRes
 		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
 	}
 
-	err = api.DeleteUserRepo(r.Context(), userID.String())
+	deactivation, status, message, err := api.DeactivateAccountHelper(r.Context(), userID.String())
+	if err != nil {
+		return respondWithError(err, message, status, &tc)
+	}
+
+	return &ServerResponse{
+		Message:    message,
+		Status:     status,
+		StatusCode: util.StatusCode(status),
+		Data:       deactivation,
+	}
+}
+
+// LocationHeartbeat records a location ping from the app.
+// POST /user/location
+func (api *API) LocationHeartbeat(_ http.ResponseWriter, r *http.Request) *ServerResponse {
+	tc := r.Context().Value(values.ContextTracingKey).(tracing.Context)
+
+	userID, err := util.GetUserIDFromContext(r.Context())
+	if err != nil {
+		return respondWithError(err, "unable to get user ID from context", values.NotAuthorised, &tc)
+	}
+
+	var req model.LocationHeartbeatRequest
+	if decodeErr := util.DecodeJSONBody(&tc, r.Body, &req); decodeErr != nil {
+		return respondWithError(decodeErr, "unable to decode request", values.BadRequestBody, &tc)
+	}
+	if err := util.ValidateStruct(req); err != nil {
+		return respondWithValidationError(err, &tc)
+	}
+	req.Latitude, req.Longitude, err = util.NormalizeCoordinate(req.Latitude, req.Longitude)
 	if err != nil {
-		return respondWithError(err, "failed to delete account", values.Error, &tc)
+		return respondWithError(err, "invalid latitude or longitude", values.BadRequestBody, &tc)
+	}
+
+	if req.RecordedAt == nil {
+		now := time.Now()
+		req.RecordedAt = &now
 	}
 
+	err = api.InsertLocationPingRepo(r.Context(), userID.String(), req)
+	if err != nil {
+		return respondWithError(err, "failed to record location", values.Error, &tc)
+	}
+
+	api.runPlausibilityCheck(userID.String(), r.Header.Get(values.HeaderDeviceID), "location", util.LocationSample{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Speed:     req.Speed,
+		Heading:   req.Heading,
+		At:        *req.RecordedAt,
+	})
+
 	return &ServerResponse{
-		Message:    "Account deleted successfully",
+		Message:    "Location recorded",
 		Status:     values.Success,
 		StatusCode: util.StatusCode(values.Success),
 	}
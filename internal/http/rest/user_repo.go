@@ -2,13 +2,20 @@ package rest
 
 import (
 	"context"
+	"time"
 
 	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+// anonymousUserID is the system user (0037_create_anonymous_user.sql) that keeps reports
+// and votes of deleted accounts.
+var anonymousUserID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
 func (api *API) GetUserProfileByID(ctx context.Context, id string) (model.User, error) {
 	var user model.User
-	stmt := `SELECT id, email, firstname, lastname, auth_provider, is_verified, preferred_language, created_at, updated_at FROM users WHERE id = $1`
+	stmt := `SELECT id, COALESCE(email, ''), firstname, lastname, auth_provider, is_verified, preferred_language, reputation_score, created_at, updated_at FROM users WHERE id = $1`
 
 	err := api.Deps.DB.Pool().QueryRow(ctx, stmt, id).Scan(
 		&user.ID,
@@ -18,6 +25,7 @@ func (api *API) GetUserProfileByID(ctx context.Context, id string) (model.User,
 		&user.AuthProvider,
 		&user.IsVerified,
 		&user.PreferredLanguage,
+		&user.ReputationScore,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -58,10 +66,156 @@ func (api *API) UpdateLanguageRepo(ctx context.Context, userID, language string)
 	return nil
 }
 
-func (api *API) DeleteUserRepo(ctx context.Context, userID string) error {
-	stmt := `DELETE FROM users WHERE id = $1`
+func (api *API) UpdateRoutePreferenceRepo(ctx context.Context, userID, preference string) error {
+	stmt := `
+        UPDATE users
+        SET route_preference = $2, updated_at = NOW()
+        WHERE id = $1
+    `
+	_, err := api.Deps.DB.Pool().Exec(ctx, stmt, userID, preference)
+	return err
+}
+
+// DeactivateUserRepo marks an account for deletion and signs it out everywhere: refresh
+// tokens are revoked and device secrets and push tokens dropped. It returns when the
+// account was deactivated, which stays the first request's time if asked again.
+func (api *API) DeactivateUserRepo(ctx context.Context, userID string) (time.Time, error) {
+	var deactivatedAt time.Time
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+            UPDATE users SET deactivated_at = COALESCE(deactivated_at, NOW()), updated_at = NOW()
+            WHERE id = $1
+            RETURNING deactivated_at
+        `, userID).Scan(&deactivatedAt)
+		if err != nil {
+			return dbError(err)
+		}
+		stmts := []string{
+			`UPDATE auth_tokens SET is_revoked = TRUE WHERE user_id = $1 AND is_revoked = FALSE`,
+			`DELETE FROM device_secrets WHERE user_id = $1`,
+			`DELETE FROM user_fcm_tokens WHERE user_id = $1`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return deactivatedAt, err
+}
+
+// ReactivateUserRepo cancels a pending deletion. It reports whether the account was
+// deactivated.
+func (api *API) ReactivateUserRepo(ctx context.Context, userID string) (bool, error) {
+	tag, err := api.DB.Exec(ctx, `
+        UPDATE users SET deactivated_at = NULL, updated_at = NOW()
+        WHERE id = $1 AND deactivated_at IS NOT NULL
+    `, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListPurgeableUsersRepo returns up to limit accounts deactivated before before, oldest first.
+func (api *API) ListPurgeableUsersRepo(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	rows, err := api.DB.Query(ctx, `
+        SELECT id::text FROM users
+        WHERE deactivated_at < $1
+        ORDER BY deactivated_at
+        LIMIT $2
+    `, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeUserRepo deletes an account deactivated before deactivatedBefore, returning
+// ErrNotFound if it isn't (it was reactivated in the meantime, or is already gone).
+// Reports that are still live or that other users have voted or commented on are handed
+// to the anonymous user with their description removed; the rest are deleted along with
+// their votes and comments. The user's votes are kept under the anonymous user so report
+// vote counts stay right, while their comments are blanked and soft-deleted under the
+// anonymous user, so replies keep their thread.
+func (api *API) PurgeUserRepo(ctx context.Context, userID string, deactivatedBefore time.Time) error {
+	return api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		var id string
+		err := tx.QueryRow(ctx, `
+            SELECT id FROM users WHERE id = $1 AND deactivated_at < $2 FOR UPDATE
+        `, userID, deactivatedBefore).Scan(&id)
+		if err != nil {
+			return dbError(err)
+		}
+
+		keepReports := `
+            UPDATE reports r
+            SET user_id = $2, description = NULL, updated_at = NOW()
+            WHERE r.user_id = $1
+              AND ((r.active AND r.expires_at > NOW())
+                OR EXISTS (SELECT 1 FROM votes v WHERE v.report_id = r.id AND v.user_id <> $1)
+                OR EXISTS (SELECT 1 FROM comments c WHERE c.report_id = r.id AND c.user_id <> $1))
+        `
+		if _, err := tx.Exec(ctx, keepReports, userID, anonymousUserID); err != nil {
+			return err
+		}
+		deleteComments := `
+            WITH removed AS (
+                UPDATE comments c
+                SET user_id = $2, content = '', deleted_at = COALESCE(c.deleted_at, NOW())
+                FROM (SELECT id, deleted_at IS NULL AS live FROM comments WHERE user_id = $1 FOR UPDATE) o
+                WHERE c.id = o.id
+                RETURNING c.report_id, o.live
+            )
+            UPDATE reports r
+            SET comments_count = GREATEST(r.comments_count - n.count, 0)
+            FROM (SELECT report_id, COUNT(*) AS count FROM removed WHERE live GROUP BY report_id) n
+            WHERE r.id = n.report_id
+        `
+		if _, err := tx.Exec(ctx, deleteComments, userID, anonymousUserID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE votes SET user_id = $2 WHERE user_id = $1`, userID, anonymousUserID); err != nil {
+			return err
+		}
+
+		// Whatever the user still owns wasn't worth keeping, nor are the user's rows in
+		// tables without ON DELETE CASCADE on users.
+		stmts := []string{
+			`DELETE FROM votes WHERE report_id IN (SELECT id FROM reports WHERE user_id = $1)`,
+			`DELETE FROM comments WHERE report_id IN (SELECT id FROM reports WHERE user_id = $1)`,
+			`DELETE FROM reports WHERE user_id = $1`,
+			`DELETE FROM email_verifications WHERE user_id = $1`,
+			`DELETE FROM auth_tokens WHERE user_id = $1`,
+			`DELETE FROM saved_locations WHERE user_id = $1`,
+			`DELETE FROM users WHERE id = $1`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(ctx, stmt, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	_, err := api.Deps.DB.Pool().Exec(ctx, stmt, userID)
+func (api *API) InsertLocationPingRepo(ctx context.Context, userID string, ping model.LocationHeartbeatRequest) error {
+	stmt := `
+        INSERT INTO user_location_pings (user_id, position, speed, heading, accuracy, recorded_at)
+        VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), $4, $5, $6, COALESCE($7, NOW()))
+    `
+	_, err := api.Deps.DB.Pool().Exec(ctx, stmt, userID, ping.Longitude, ping.Latitude, ping.Speed, ping.Heading, ping.Accuracy, ping.RecordedAt)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,155 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/model"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultValhallaTilesMaxAgeDays = 30
+	valhallaTileCheckInterval      = 6 * time.Hour
+	valhallaStatusTimeout          = 10 * time.Second
+)
+
+func (api *API) valhallaTilesMaxAgeDays() int {
+	if api.Config.ValhallaTilesMaxAgeDays > 0 {
+		return api.Config.ValhallaTilesMaxAgeDays
+	}
+	return defaultValhallaTilesMaxAgeDays
+}
+
+// valhallaTileStatus asks Valhalla when its tileset was built and compares that with
+// VALHALLA_TILES_MAX_AGE_DAYS. An unreachable Valhalla is reported, not returned as an error.
+func (api *API) valhallaTileStatus(ctx context.Context) model.ValhallaTileStatus {
+	st := model.ValhallaTileStatus{
+		MaxAgeDays:        api.valhallaTilesMaxAgeDays(),
+		WebhookConfigured: api.Config.OutboxWebhookURL != "",
+	}
+	if api.ValhallaClient == nil {
+		st.Error = "valhalla client not configured"
+		return st
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, valhallaStatusTimeout)
+	defer cancel()
+	status, err := api.ValhallaClient.GetStatus(ctx)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Reachable = true
+	st.Version = status.Version
+	st.TilesetBuiltAt = status.TilesetBuiltAt()
+	if st.TilesetBuiltAt == nil {
+		st.Error = "valhalla did not report tileset_last_modified"
+		return st
+	}
+	age := time.Since(*st.TilesetBuiltAt).Hours() / 24
+	st.AgeDays = &age
+	st.Stale = age > float64(st.MaxAgeDays)
+	return st
+}
+
+func (api *API) GetValhallaTileStatusHelper(ctx context.Context) (model.ValhallaTileStatus, string, string, error) {
+	st := api.valhallaTileStatus(ctx)
+	last, err := api.LastOutboxEventAtRepo(ctx, OutboxEventValhallaRebuild)
+	if err != nil {
+		return st, values.Error, "Failed to fetch tile status", err
+	}
+	st.LastRebuildAt = last
+	return st, values.Success, "Tile status fetched successfully", nil
+}
+
+// TriggerValhallaRebuildHelper queues a rebuild request for the tile pipeline. Requests are
+// deduplicated per hour so repeated clicks do not start several builds.
+func (api *API) TriggerValhallaRebuildHelper(ctx context.Context, req model.TriggerValhallaRebuildRequest, adminID uuid.UUID) (model.ValhallaTileStatus, string, string, error) {
+	st := api.valhallaTileStatus(ctx)
+	if st.Reachable && !st.Stale && !req.Force {
+		return st, values.Conflict, "Tiles are not stale; set force to rebuild anyway", fmt.Errorf("tiles are fresh")
+	}
+
+	event := model.ValhallaRebuildRequestedEvent{
+		Trigger:        "manual",
+		RequestedBy:    &adminID,
+		Version:        st.Version,
+		TilesetBuiltAt: st.TilesetBuiltAt,
+		AgeDays:        st.AgeDays,
+	}
+	if req.Reason != nil {
+		event.Reason = *req.Reason
+	}
+	dedupKey := OutboxEventValhallaRebuild + ":manual:" + time.Now().UTC().Format("2006-01-02T15")
+	if err := api.queueValhallaRebuild(ctx, event, dedupKey); err != nil {
+		return st, values.Error, "Failed to queue tile rebuild", err
+	}
+
+	now := time.Now()
+	st.LastRebuildAt = &now
+	message := "Tile rebuild requested"
+	if !st.WebhookConfigured {
+		message += "; OUTBOX_WEBHOOK_URL is not set, so no pipeline will receive it"
+	}
+	return st, values.Created, message, nil
+}
+
+func (api *API) queueValhallaRebuild(ctx context.Context, event model.ValhallaRebuildRequestedEvent, dedupKey string) error {
+	err := api.Deps.DB.RunInTx(ctx, func(tx pgx.Tx) error {
+		return insertOutboxEvent(ctx, tx, OutboxEventValhallaRebuild, "valhalla", dedupKey, event)
+	})
+	if err != nil {
+		return err
+	}
+	api.NotifyOutbox()
+	return nil
+}
+
+// RunValhallaTileMonitor checks tile freshness until ctx is cancelled and requests a
+// rebuild, at most once a day, while the tiles are stale.
+func (api *API) RunValhallaTileMonitor(ctx context.Context) {
+	if api.ValhallaClient == nil || api.ValhallaClient.BaseURL == "" {
+		return
+	}
+	ticker := time.NewTicker(valhallaTileCheckInterval)
+	defer ticker.Stop()
+	for {
+		api.checkValhallaTiles(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (api *API) checkValhallaTiles(ctx context.Context) {
+	st := api.valhallaTileStatus(ctx)
+	if !st.Stale {
+		if st.Error != "" {
+			api.logger().WarnContext(ctx, "Valhalla tile check failed", "err", st.Error)
+		}
+		return
+	}
+
+	api.logger().WarnContext(ctx, "Valhalla tiles are stale",
+		"built_at", st.TilesetBuiltAt.Format(time.RFC3339), "age_days", *st.AgeDays, "max_age_days", st.MaxAgeDays)
+	if !api.dbAvailable() {
+		return
+	}
+	event := model.ValhallaRebuildRequestedEvent{
+		Trigger:        "stale",
+		Reason:         fmt.Sprintf("tiles older than %d days", st.MaxAgeDays),
+		Version:        st.Version,
+		TilesetBuiltAt: st.TilesetBuiltAt,
+		AgeDays:        st.AgeDays,
+	}
+	dedupKey := OutboxEventValhallaRebuild + ":stale:" + time.Now().UTC().Format("2006-01-02")
+	if err := api.queueValhallaRebuild(ctx, event, dedupKey); err != nil {
+		api.logger().ErrorContext(ctx, "Failed to queue Valhalla tile rebuild", "err", err)
+	}
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/util/websockets"
+	"github.com/google/uuid"
+)
+
+// wsAuthorizer is the WebSocket manager's view of the auth and membership rules.
+type wsAuthorizer struct {
+	api *API
+}
+
+var _ websockets.Authorizer = wsAuthorizer{}
+
+// Authenticate accepts the access token as a Bearer header or, for clients that can't
+// set headers on the upgrade, an access_token query parameter. It applies the same
+// checks as RequireLogin.
+func (a wsAuthorizer) Authenticate(r *http.Request) (string, error) {
+	token := r.URL.Query().Get("access_token")
+	if auth := strings.Split(r.Header.Get("Authorization"), " "); len(auth) == 2 && auth[0] == "Bearer" {
+		token = auth[1]
+	}
+	if token == "" {
+		return "", errors.New("missing access token")
+	}
+
+	claims, err := a.api.verifyToken(token, false)
+	if err != nil {
+		return "", err
+	}
+	if a.api.accessTokenRevoked(r.Context(), claims) {
+		return "", ErrTokenRevoked
+	}
+	userID, _, err := a.api.resolveLoginUser(r.Context(), claims)
+	return userID, err
+}
+
+// CanJoin lets active group members into a group's topic, and the driver or anyone
+// sharing an active group with them into a navigation session's topic.
+func (a wsAuthorizer) CanJoin(ctx context.Context, userID, topic string) (bool, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return false, nil
+	}
+	switch {
+	case strings.HasPrefix(topic, websockets.TopicPrefixGroup):
+		groupID, err := uuid.Parse(strings.TrimPrefix(topic, websockets.TopicPrefixGroup))
+		if err != nil {
+			return false, nil
+		}
+		return a.api.IsUserMemberOfGroup(ctx, groupID, uid)
+	case strings.HasPrefix(topic, websockets.TopicPrefixNavigation):
+		sessionID, err := uuid.Parse(strings.TrimPrefix(topic, websockets.TopicPrefixNavigation))
+		if err != nil {
+			return false, nil
+		}
+		return a.api.CanFollowNavigationSessionRepo(ctx, sessionID, uid)
+	}
+	return false, nil
+}
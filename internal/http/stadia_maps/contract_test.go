@@ -0,0 +1,88 @@
+package stadiamaps
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestDecodeFeatureCollection(t *testing.T) {
+	tests := []struct {
+		fixture string
+		status  int
+		strict  bool
+		wantErr string
+	}{
+		{fixture: "reverse_ok.json", status: 200, strict: true},
+		{fixture: "autocomplete_missing_properties.json", status: 200},
+		{fixture: "autocomplete_missing_properties.json", status: 200, strict: true, wantErr: "missing features[0].geometry"},
+		{fixture: "unauthorized.json", status: 401, wantErr: "status 401"},
+	}
+	for _, tt := range tests {
+		var result GeoJSONFeatureCollection
+		err := decodeResponse(tt.status, readFixture(t, tt.fixture), &result, tt.strict, featureRequiredFields)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s (strict=%v): got error %v, want %q", tt.fixture, tt.strict, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (strict=%v): unexpected error: %v", tt.fixture, tt.strict, err)
+		}
+	}
+}
+
+func TestAutocompleteSuggestionsSkipsIncompleteFeatures(t *testing.T) {
+	var result GeoJSONFeatureCollection
+	if err := decodeResponse(200, readFixture(t, "autocomplete_missing_properties.json"), &result, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	suggestions := autocompleteSuggestions(result)
+	if len(suggestions) != 2 {
+		t.Fatalf("got %d suggestions, want 2: %+v", len(suggestions), suggestions)
+	}
+	if suggestions[1].Name != "Cafe Ambience" {
+		t.Errorf("got name %q, want Cafe Ambience", suggestions[1].Name)
+	}
+}
+
+func TestPlaceDetailsFromResponse(t *testing.T) {
+	tests := []struct {
+		fixture string
+		wantErr string
+	}{
+		{fixture: "reverse_ok.json"},
+		{fixture: "place_details_no_geometry.json", wantErr: "no coordinates"},
+	}
+	for _, tt := range tests {
+		var result GeocodingResponse
+		if err := decodeResponse(200, readFixture(t, tt.fixture), &result, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		details, err := placeDetailsFromResponse(result)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got error %v, want %q", tt.fixture, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.fixture, err)
+			continue
+		}
+		if details.Latitude != 35.185641 || details.Longitude != 33.382298 {
+			t.Errorf("%s: got %v,%v", tt.fixture, details.Latitude, details.Longitude)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/util"
 	"github.com/google/go-querystring/query"
 	"github.com/pkg/errors"
 )
@@ -23,6 +25,8 @@ type Client struct {
 	BaseURL    *url.URL
 	APIKey     string
 	HTTPClient *http.Client
+	// Strict makes responses missing fields we rely on fail instead of decoding to zero values.
+	Strict bool
 }
 
 // NewClient creates a new Stadia Maps API client with default timeout.
@@ -31,14 +35,11 @@ func NewClient(apiKey string) *Client {
 	return &Client{
 		BaseURL: baseURL,
 		APIKey:  apiKey,
-		HTTPClient: &http.Client{
-			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        10,
-				IdleConnTimeout:     30 * time.Second,
-				TLSHandshakeTimeout: 5 * time.Second,
-			},
-		},
+		HTTPClient: resilient.NewClient(&http.Transport{
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 5 * time.Second,
+		}, resilient.Policy{}),
 	}
 }
 
@@ -53,19 +54,26 @@ type GeocodeQuery struct {
 	Layers        []string `url:"layers,omitempty,comma"`    // e.g., "address", "venue"
 	FocusPointLat *float64 `url:"focus.point.lat,omitempty"` // For proximity-based search
 	FocusPointLon *float64 `url:"focus.point.lon,omitempty"` // For proximity-based search
+	Lang          *string  `url:"lang,omitempty"`            // Preferred language of names, e.g. "tr"
 }
 
 // GeoJSONFeatureCollection is the response structure for geocoding APIs.
 type GeoJSONFeatureCollection struct {
-	Type     string `json:"type"` // "FeatureCollection"
-	Features []struct {
-		Type     string    `json:"type"` // "Feature"
-		Geometry *struct { // Nil for v2 autocomplete
-			Type        string    `json:"type"`        // "Point"
-			Coordinates []float64 `json:"coordinates"` // [lon, lat]
-		} `json:"geometry"`
-		Properties map[string]interface{} `json:"properties"` // Address, confidence, gid, etc.
-	} `json:"features"`
+	Type     string           `json:"type"` // "FeatureCollection"
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a single geocoding result.
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`       // "Feature"
+	Geometry   *GeoJSONPoint          `json:"geometry"`   // Nil for v2 autocomplete
+	Properties map[string]interface{} `json:"properties"` // Address, confidence, gid, etc.
+}
+
+// GeoJSONPoint is the point geometry of a geocoding result.
+type GeoJSONPoint struct {
+	Type        string    `json:"type"`        // "Point"
+	Coordinates []float64 `json:"coordinates"` // [lon, lat]
 }
 
 // PlaceDetailResponse is the response for the /place_detail endpoint.
@@ -152,6 +160,8 @@ type AutocompleteSuggestion struct {
 	Name           string `json:"name"`
 	CoarseLocation string `json:"coarse_location"`
 	Layer          string `json:"layer"`
+	// Names is set by our API for places known by several names, keyed by language.
+	Names map[string]string `json:"names,omitempty"`
 }
 
 type PlaceDetails struct {
@@ -212,7 +222,7 @@ func (c *Client) Search(ctx context.Context, text string, params *GeocodeQuery)
 	}
 
 	var result GeoJSONFeatureCollection
-	if err := c.do(req, &result); err != nil {
+	if err := c.do(req, &result, featureRequiredFields...); err != nil {
 		return nil, errors.Wrap(err, "execute search request")
 	}
 	return &result, nil
@@ -262,22 +272,33 @@ func (c *Client) Autocomplete(ctx context.Context, text string, params *GeocodeQ
 	}
 
 	var result GeoJSONFeatureCollection
-	if err := c.do(req, &result); err != nil {
+	if err := c.do(req, &result, "features[].properties.gid"); err != nil {
 		return nil, errors.Wrap(err, "execute autocomplete request")
 	}
+	return autocompleteSuggestions(result), nil
+}
 
+// autocompleteSuggestions flattens autocomplete features. Properties other than gid are
+// often missing (countries have no coarse_location), so they default to empty; features
+// without a gid can't be looked up and are skipped.
+func autocompleteSuggestions(result GeoJSONFeatureCollection) []AutocompleteSuggestion {
 	var suggestions []AutocompleteSuggestion
 	for _, feature := range result.Features {
-		suggestion := AutocompleteSuggestion{
-			GID:            feature.Properties["gid"].(string),
-			Name:           feature.Properties["name"].(string),
-			CoarseLocation: feature.Properties["coarse_location"].(string),
-			Layer:          feature.Properties["layer"].(string),
+		prop := func(key string) string {
+			s, _ := feature.Properties[key].(string)
+			return s
+		}
+		if prop("gid") == "" {
+			continue
 		}
-		suggestions = append(suggestions, suggestion)
+		suggestions = append(suggestions, AutocompleteSuggestion{
+			GID:            prop("gid"),
+			Name:           prop("name"),
+			CoarseLocation: prop("coarse_location"),
+			Layer:          prop("layer"),
+		})
 	}
-
-	return suggestions, nil
+	return suggestions
 }
 
 // PlaceDetail fetches detailed place information using v2 API.
@@ -324,15 +345,21 @@ func (c *Client) PlaceDetail(ctx context.Context, gid string) (*PlaceDetails, er
 	}
 
 	var result GeocodingResponse
-	if err := c.do(req, &result); err != nil {
+	if err := c.do(req, &result, featureRequiredFields...); err != nil {
 		return nil, errors.Wrap(err, "execute place detail request")
 	}
+	return placeDetailsFromResponse(result)
+}
 
+func placeDetailsFromResponse(result GeocodingResponse) (*PlaceDetails, error) {
 	if len(result.Features) == 0 {
 		return nil, errors.New("no place details found")
 	}
 
 	feature := result.Features[0]
+	if len(feature.Geometry.Coordinates) < 2 {
+		return nil, errors.New("place details have no coordinates")
+	}
 	props := feature.Properties
 
 	details := &PlaceDetails{
@@ -377,7 +404,7 @@ func (c *Client) ReverseGeocode(ctx context.Context, lat, lon float64, params *G
 	}
 
 	var result GeoJSONFeatureCollection
-	if err := c.do(req, &result); err != nil {
+	if err := c.do(req, &result, featureRequiredFields...); err != nil {
 		return nil, errors.Wrap(err, "execute reverse geocode request")
 	}
 	return &result, nil
@@ -462,22 +489,60 @@ type RouteLocation struct {
 // 	return &result, nil
 // }
 
-// do executes HTTP requests and decodes JSON responses.
-func (c *Client) do(req *http.Request, v interface{}) error {
+// featureRequiredFields are what the app reads from every geocoding feature; strict
+// mode rejects responses without them.
+var featureRequiredFields = []string{"features[].geometry.coordinates", "features[].properties"}
+
+// do executes HTTP requests and decodes JSON responses. In strict mode the response must
+// also have the required fields (see util.RequireJSONFields).
+func (c *Client) do(req *http.Request, v interface{}, required ...string) error {
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
 		return errors.Wrap(err, "execute HTTP request")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "read response")
 	}
+	return decodeResponse(resp.StatusCode, body, v, c.Strict, required)
+}
+
+// Error is a non-2xx reply from Stadia Maps.
+type Error struct {
+	StatusCode int
+	Body       string
+}
 
-	if v != nil {
-		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
-			return errors.Wrap(err, "decode response")
+func (e *Error) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// NotFound reports whether the requested place does not exist.
+func (e *Error) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// RateLimited reports whether Stadia turned the request away for exceeding a rate limit
+// or quota.
+func (e *Error) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+func decodeResponse(statusCode int, body []byte, v interface{}, strict bool, required []string) error {
+	if statusCode < 200 || statusCode >= 300 {
+		return &Error{StatusCode: statusCode, Body: string(body)}
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrap(err, "decode response")
+	}
+	if strict && len(required) > 0 {
+		if err := util.RequireJSONFields(body, required...); err != nil {
+			return errors.Wrap(err, "stadia maps")
 		}
 	}
 	return nil
@@ -0,0 +1,52 @@
+package valhalla
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRouteResponse(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		status   int
+		strict   bool
+		wantCode int
+		wantErr  string
+	}{
+		{fixture: "route_ok.json", status: http.StatusOK, strict: true},
+		{fixture: "route_missing_shape.json", status: http.StatusOK},
+		{fixture: "route_missing_shape.json", status: http.StatusOK, strict: true, wantErr: "missing trip.legs[0].shape"},
+		{fixture: "error_no_path.json", status: http.StatusBadRequest, wantCode: 442, wantErr: "No path could be found"},
+		{fixture: "error_no_edges.json", status: http.StatusBadRequest, wantCode: 171, wantErr: "No suitable edges"},
+		{fixture: "proxy_502.html", status: http.StatusBadGateway, wantErr: "status code 502"},
+	}
+	for _, tt := range tests {
+		body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := parseRouteResponse(tt.status, body, tt.strict)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s (strict=%v): got error %v, want %q", tt.fixture, tt.strict, err, tt.wantErr)
+			}
+			var vErr *Error
+			if errors.As(err, &vErr) != (tt.status != http.StatusOK) || (vErr != nil && (vErr.Code != tt.wantCode || vErr.HTTPStatus != tt.status)) {
+				t.Errorf("%s: got valhalla error %+v, want code %d", tt.fixture, vErr, tt.wantCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (strict=%v): unexpected error: %v", tt.fixture, tt.strict, err)
+			continue
+		}
+		// Lenient parsing must still produce something the mobile formatter copes with.
+		if _, err := FormatRouteForMobile(resp, DefaultLanguage); err != nil {
+			t.Errorf("%s: formatting route: %v", tt.fixture, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// IsochroneRequest asks for the areas reachable from a location within each contour's
+// time.
+type IsochroneRequest struct {
+	Locations      []Location      `json:"locations"`
+	Costing        string          `json:"costing"`
+	CostingOptions *CostingOptions `json:"costing_options,omitempty"`
+	Contours       []Contour       `json:"contours"` // at most 4 by default
+	// Polygons returns filled areas instead of contour lines.
+	Polygons bool `json:"polygons"`
+	// Denoise (0-1) drops islands smaller than this fraction of the largest area.
+	Denoise *float64 `json:"denoise,omitempty"`
+	// Generalize is the Douglas-Peucker tolerance in meters applied to the shapes.
+	Generalize *float64 `json:"generalize,omitempty"`
+}
+
+// Contour is one reachability limit, in minutes (max 120 by default).
+type Contour struct {
+	Time  float64 `json:"time"`
+	Color string  `json:"color,omitempty"` // hex without '#', echoed back on the feature
+}
+
+// IsochroneResponse is a GeoJSON FeatureCollection with a feature per contour, largest
+// first.
+type IsochroneResponse struct {
+	Type     string             `json:"type"`
+	Features []IsochroneFeature `json:"features"`
+}
+
+// IsochroneFeature is a contour's area. Properties carry "contour" (the minutes),
+// "metric" ("time") and the fill colour and opacity.
+type IsochroneFeature struct {
+	Type       string          `json:"type"`
+	Properties map[string]any  `json:"properties"`
+	Geometry   json.RawMessage `json:"geometry"`
+}
+
+// Isochrone fetches the reachable areas around the request's location.
+func (vc *ValhallaClient) Isochrone(ctx context.Context, request IsochroneRequest) (*IsochroneResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal isochrone request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vc.BaseURL+"/isochrone", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make isochrone request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var isochrone IsochroneResponse
+	if err := json.Unmarshal(body, &isochrone); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla isochrone response: %w", err)
+	}
+	return &isochrone, nil
+}
@@ -0,0 +1,219 @@
+package valhalla
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Languages route formatting is localized into. The app serves Northern Cyprus, so
+// Turkish and Greek besides English; anything else is formatted in DefaultLanguage.
+const (
+	LanguageEnglish = "en"
+	LanguageTurkish = "tr"
+	LanguageGreek   = "el"
+
+	DefaultLanguage = LanguageEnglish
+)
+
+// narrativeLocales are the Valhalla narrative locales asked for per language.
+var narrativeLocales = map[string]string{
+	LanguageEnglish: "en-US",
+	LanguageTurkish: "tr-TR",
+	LanguageGreek:   "el-GR",
+}
+
+// NormalizeLanguage reduces a language tag ("tr-TR", "EL", "de") to a supported
+// language, falling back to DefaultLanguage.
+func NormalizeLanguage(lang string) string {
+	lang, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(lang)), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	if _, ok := narrativeLocales[lang]; ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// NarrativeLocale is the Valhalla language parameter for lang, e.g. "tr-TR".
+func NarrativeLocale(lang string) string {
+	return narrativeLocales[NormalizeLanguage(lang)]
+}
+
+// unitLabels are how durations and distances are written in one language.
+type unitLabels struct {
+	hours, minutes, seconds string
+	km, m, mi               string
+	decimalComma            bool
+}
+
+var formatLabels = map[string]unitLabels{
+	LanguageEnglish: {hours: "h", minutes: "m", seconds: "s", km: "km", m: "m", mi: "mi"},
+	LanguageTurkish: {hours: " sa", minutes: " dk", seconds: " sn", km: "km", m: "m", mi: "mil", decimalComma: true},
+	LanguageGreek:   {hours: " ώρ.", minutes: " λεπ.", seconds: " δευτ.", km: "χλμ.", m: "μ.", mi: "μίλ.", decimalComma: true},
+}
+
+func labelsFor(lang string) unitLabels {
+	return formatLabels[NormalizeLanguage(lang)]
+}
+
+// decimal formats v with one decimal in the language's notation, e.g. "12,5" in Turkish.
+func (l unitLabels) decimal(v float64) string {
+	s := fmt.Sprintf("%.1f", v)
+	if l.decimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// maneuverTemplate is a maneuver instruction without and with the street it leads onto.
+// An empty onto means the street is left out.
+type maneuverTemplate struct {
+	plain, onto string
+}
+
+// maneuverTemplates translate instructions by MapValhallaManeuverType name. They are used
+// when the provider couldn't narrate in the requested language; types missing here keep
+// the provider's instruction.
+var maneuverTemplates = map[string]map[string]maneuverTemplate{
+	LanguageEnglish: {
+		"Start":            {"Head out", "Head out on %s"},
+		"Destination":      {"You have arrived at your destination", ""},
+		"DestinationRight": {"Your destination is on the right", ""},
+		"DestinationLeft":  {"Your destination is on the left", ""},
+		"Continue":         {"Continue", "Continue on %s"},
+		"SlightRight":      {"Bear right", "Bear right onto %s"},
+		"Right":            {"Turn right", "Turn right onto %s"},
+		"SharpRight":       {"Make a sharp right", "Make a sharp right onto %s"},
+		"UturnRight":       {"Make a U-turn", "Make a U-turn onto %s"},
+		"UturnLeft":        {"Make a U-turn", "Make a U-turn onto %s"},
+		"SharpLeft":        {"Make a sharp left", "Make a sharp left onto %s"},
+		"Left":             {"Turn left", "Turn left onto %s"},
+		"SlightLeft":       {"Bear left", "Bear left onto %s"},
+		"RampRight":        {"Take the ramp on the right", "Take the ramp on the right onto %s"},
+		"RampLeft":         {"Take the ramp on the left", "Take the ramp on the left onto %s"},
+		"ExitRight":        {"Take the exit on the right", "Take the exit on the right onto %s"},
+		"ExitLeft":         {"Take the exit on the left", "Take the exit on the left onto %s"},
+		"StayRight":        {"Keep right", "Keep right onto %s"},
+		"StayLeft":         {"Keep left", "Keep left onto %s"},
+		"Merge":            {"Merge", "Merge onto %s"},
+		"RoundaboutEnter":  {"Enter the roundabout", ""},
+		"RoundaboutExit":   {"Exit the roundabout", "Exit the roundabout onto %s"},
+		"FerryEnter":       {"Take the ferry", ""},
+		"FerryExit":        {"Drive off the ferry", "Drive off the ferry onto %s"},
+	},
+	LanguageTurkish: {
+		"Start":            {"Yola çıkın", "%s üzerinden yola çıkın"},
+		"Destination":      {"Varış noktanıza ulaştınız", ""},
+		"DestinationRight": {"Varış noktanız sağda", ""},
+		"DestinationLeft":  {"Varış noktanız solda", ""},
+		"Continue":         {"Düz devam edin", "%s üzerinde devam edin"},
+		"SlightRight":      {"Hafif sağa dönün", "%s yönüne hafif sağa dönün"},
+		"Right":            {"Sağa dönün", "%s yönüne sağa dönün"},
+		"SharpRight":       {"Keskin sağa dönün", "%s yönüne keskin sağa dönün"},
+		"UturnRight":       {"U dönüşü yapın", "U dönüşü yaparak %s yönüne geçin"},
+		"UturnLeft":        {"U dönüşü yapın", "U dönüşü yaparak %s yönüne geçin"},
+		"SharpLeft":        {"Keskin sola dönün", "%s yönüne keskin sola dönün"},
+		"Left":             {"Sola dönün", "%s yönüne sola dönün"},
+		"SlightLeft":       {"Hafif sola dönün", "%s yönüne hafif sola dönün"},
+		"RampRight":        {"Sağdaki rampaya girin", "Sağdaki rampadan %s yönüne girin"},
+		"RampLeft":         {"Soldaki rampaya girin", "Soldaki rampadan %s yönüne girin"},
+		"ExitRight":        {"Sağdaki çıkışı kullanın", "Sağdaki çıkıştan %s yönüne çıkın"},
+		"ExitLeft":         {"Soldaki çıkışı kullanın", "Soldaki çıkıştan %s yönüne çıkın"},
+		"StayRight":        {"Sağdan devam edin", "%s yönünde sağdan devam edin"},
+		"StayLeft":         {"Soldan devam edin", "%s yönünde soldan devam edin"},
+		"Merge":            {"Trafiğe katılın", "%s trafiğine katılın"},
+		"RoundaboutEnter":  {"Döner kavşağa girin", ""},
+		"RoundaboutExit":   {"Döner kavşaktan çıkın", "Döner kavşaktan %s yönüne çıkın"},
+		"FerryEnter":       {"Feribota binin", ""},
+		"FerryExit":        {"Feribottan inin", "Feribottan inip %s yönüne devam edin"},
+	},
+	LanguageGreek: {
+		"Start":            {"Ξεκινήστε", "Ξεκινήστε μέσω %s"},
+		"Destination":      {"Φτάσατε στον προορισμό σας", ""},
+		"DestinationRight": {"Ο προορισμός σας είναι δεξιά", ""},
+		"DestinationLeft":  {"Ο προορισμός σας είναι αριστερά", ""},
+		"Continue":         {"Συνεχίστε ευθεία", "Συνεχίστε ευθεία προς %s"},
+		"SlightRight":      {"Στρίψτε ελαφρώς δεξιά", "Στρίψτε ελαφρώς δεξιά προς %s"},
+		"Right":            {"Στρίψτε δεξιά", "Στρίψτε δεξιά προς %s"},
+		"SharpRight":       {"Στρίψτε απότομα δεξιά", "Στρίψτε απότομα δεξιά προς %s"},
+		"UturnRight":       {"Κάντε αναστροφή", "Κάντε αναστροφή προς %s"},
+		"UturnLeft":        {"Κάντε αναστροφή", "Κάντε αναστροφή προς %s"},
+		"SharpLeft":        {"Στρίψτε απότομα αριστερά", "Στρίψτε απότομα αριστερά προς %s"},
+		"Left":             {"Στρίψτε αριστερά", "Στρίψτε αριστερά προς %s"},
+		"SlightLeft":       {"Στρίψτε ελαφρώς αριστερά", "Στρίψτε ελαφρώς αριστερά προς %s"},
+		"RampRight":        {"Πάρτε τη ράμπα δεξιά", "Πάρτε τη ράμπα δεξιά προς %s"},
+		"RampLeft":         {"Πάρτε τη ράμπα αριστερά", "Πάρτε τη ράμπα αριστερά προς %s"},
+		"ExitRight":        {"Πάρτε την έξοδο δεξιά", "Πάρτε την έξοδο δεξιά προς %s"},
+		"ExitLeft":         {"Πάρτε την έξοδο αριστερά", "Πάρτε την έξοδο αριστερά προς %s"},
+		"StayRight":        {"Κρατήστε δεξιά", "Κρατήστε δεξιά προς %s"},
+		"StayLeft":         {"Κρατήστε αριστερά", "Κρατήστε αριστερά προς %s"},
+		"Merge":            {"Ενταχθείτε στην κυκλοφορία", "Ενταχθείτε στην κυκλοφορία προς %s"},
+		"RoundaboutEnter":  {"Μπείτε στον κυκλικό κόμβο", ""},
+		"RoundaboutExit":   {"Βγείτε από τον κυκλικό κόμβο", "Βγείτε από τον κυκλικό κόμβο προς %s"},
+		"FerryEnter":       {"Επιβιβαστείτε στο πλοίο", ""},
+		"FerryExit":        {"Αποβιβαστείτε από το πλοίο", "Αποβιβαστείτε από το πλοίο προς %s"},
+	},
+}
+
+// maneuverAliases share a template with a more common maneuver type.
+var maneuverAliases = map[string]string{
+	"StartRight":   "Start",
+	"StartLeft":    "Start",
+	"Becomes":      "Continue",
+	"StayStraight": "Continue",
+	"RampStraight": "Continue",
+	"MergeRight":   "Merge",
+	"MergeLeft":    "Merge",
+}
+
+// ManeuverInstruction renders the template for a maneuver type (as named by
+// MapValhallaManeuverType) in lang, leading onto street when one is known. ok is false
+// for maneuver types without a template.
+func ManeuverInstruction(lang, maneuverType, street string) (string, bool) {
+	if alias, ok := maneuverAliases[maneuverType]; ok {
+		maneuverType = alias
+	}
+	t, ok := maneuverTemplates[NormalizeLanguage(lang)][maneuverType]
+	if !ok {
+		return "", false
+	}
+	if street == "" || t.onto == "" {
+		return t.plain, true
+	}
+	return fmt.Sprintf(t.onto, street), true
+}
+
+// narratedIn reports whether a trip narrated in locale (Valhalla's trip.language, e.g.
+// "tr-TR") is in lang. An unknown locale is assumed to be what was asked for.
+func narratedIn(locale, lang string) bool {
+	if locale == "" {
+		return true
+	}
+	prefix, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	return prefix == NormalizeLanguage(lang)
+}
+
+// announcementTemplates put a distance before a lower-cased instruction for voice
+// guidance, e.g. "In 500 m, turn right".
+var announcementTemplates = map[string]string{
+	LanguageEnglish: "In %s, %s",
+	LanguageTurkish: "%s sonra %s",
+	LanguageGreek:   "Σε %s, %s",
+}
+
+// ManeuverAnnouncement is the spoken form of instruction when it is distanceM ahead.
+// Announcements at the maneuver itself are just the instruction.
+func ManeuverAnnouncement(lang, instruction string, distanceM float64) string {
+	lang = NormalizeLanguage(lang)
+	if distanceM < 1 || instruction == "" {
+		return instruction
+	}
+	distance, _ := formatDistance(distanceM, "kilometers", lang)
+	lower := strings.ToLower
+	if lang == LanguageTurkish {
+		lower = func(s string) string { return strings.ToLowerSpecial(unicode.TurkishCase, s) }
+	}
+	first, size := utf8.DecodeRuneInString(instruction)
+	return fmt.Sprintf(announcementTemplates[lang], distance, lower(string(first))+instruction[size:])
+}
@@ -0,0 +1,76 @@
+package valhalla
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":      "en",
+		"tr-TR": "tr",
+		"EL":    "el",
+		"el_GR": "el",
+		"de":    "en",
+	}
+	for in, want := range tests {
+		if got := NormalizeLanguage(in); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatLocalized(t *testing.T) {
+	tests := []struct {
+		lang             string
+		seconds, meters  float64
+		wantDur, wantDst string
+	}{
+		{lang: "en", seconds: 3720, meters: 12500, wantDur: "1h 2m", wantDst: "12.5 km"},
+		{lang: "tr", seconds: 3720, meters: 12500, wantDur: "1 sa 2 dk", wantDst: "12,5 km"},
+		{lang: "el", seconds: 95, meters: 450, wantDur: "1 λεπ. 35 δευτ.", wantDst: "450 μ."},
+	}
+	for _, tt := range tests {
+		if got := formatDuration(tt.seconds, tt.lang); got != tt.wantDur {
+			t.Errorf("formatDuration(%v, %s) = %q, want %q", tt.seconds, tt.lang, got, tt.wantDur)
+		}
+		if got, _ := formatDistance(tt.meters, "kilometers", tt.lang); got != tt.wantDst {
+			t.Errorf("formatDistance(%v, %s) = %q, want %q", tt.meters, tt.lang, got, tt.wantDst)
+		}
+	}
+	if _, unit := formatDistance(12500, "kilometers", "el"); unit != "km" {
+		t.Errorf("formatDistance unit = %q, want km", unit)
+	}
+}
+
+func TestManeuverInstruction(t *testing.T) {
+	tests := []struct {
+		lang, maneuver, street string
+		want                   string
+		wantOK                 bool
+	}{
+		{"tr", "Right", "Atatürk Caddesi", "Atatürk Caddesi yönüne sağa dönün", true},
+		{"el", "Left", "", "Στρίψτε αριστερά", true},
+		{"en", "Destination", "Main St", "You have arrived at your destination", true},
+		{"tr", "StayStraight", "", "Düz devam edin", true},
+		{"en", "TransitConnectionStart", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ManeuverInstruction(tt.lang, tt.maneuver, tt.street)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ManeuverInstruction(%s, %s, %q) = %q, %v; want %q, %v", tt.lang, tt.maneuver, tt.street, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestManeuverAnnouncement(t *testing.T) {
+	if got, want := ManeuverAnnouncement("tr", "Işık yönüne sağa dönün", 500), "500 m sonra ışık yönüne sağa dönün"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := ManeuverAnnouncement("en", "Turn right", 0), "Turn right"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNarratedIn(t *testing.T) {
+	if !narratedIn("tr-TR", "tr") || narratedIn("en-US", "el") || !narratedIn("", "el") {
+		t.Error("narratedIn mismatch")
+	}
+}
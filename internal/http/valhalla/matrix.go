@@ -0,0 +1,67 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MatrixRequest asks for the travel time and distance from every source to every target.
+type MatrixRequest struct {
+	Sources        []Location      `json:"sources"`
+	Targets        []Location      `json:"targets"`
+	Costing        string          `json:"costing"`
+	CostingOptions *CostingOptions `json:"costing_options,omitempty"`
+}
+
+// MatrixResponse holds a row per source with a cell per target.
+type MatrixResponse struct {
+	SourcesToTargets [][]MatrixCell `json:"sources_to_targets"`
+	Units            string         `json:"units"`
+}
+
+// MatrixCell is one source-target pair. Time (seconds) and Distance (in the response's
+// units, kilometers by default) are nil when the target can't be reached.
+type MatrixCell struct {
+	FromIndex int      `json:"from_index"`
+	ToIndex   int      `json:"to_index"`
+	Time      *float64 `json:"time"`
+	Distance  *float64 `json:"distance"`
+}
+
+// SourcesToTargets fetches the many-to-many time and distance matrix.
+func (vc *ValhallaClient) SourcesToTargets(ctx context.Context, request MatrixRequest) (*MatrixResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal matrix request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vc.BaseURL+"/sources_to_targets", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make matrix request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var matrix MatrixResponse
+	if err := json.Unmarshal(body, &matrix); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla matrix response: %w", err)
+	}
+	return &matrix, nil
+}
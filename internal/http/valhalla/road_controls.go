@@ -0,0 +1,194 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/bwise1/waze_kibris/util"
+)
+
+// Road control types reported on MobileRoadControl.Type.
+const (
+	RoadControlTrafficSignal = "TrafficSignal"
+	RoadControlStopSign      = "StopSign"
+)
+
+// traceAttributesRequest asks Valhalla for the signal/stop attributes of the edges along a shape.
+type traceAttributesRequest struct {
+	EncodedPolyline string                 `json:"encoded_polyline,omitempty"`
+	Shape           []Location             `json:"shape,omitempty"`
+	Costing         string                 `json:"costing"`
+	ShapeMatch      string                 `json:"shape_match"`
+	Filters         traceAttributesFilters `json:"filters"`
+}
+
+type traceAttributesFilters struct {
+	Attributes []string `json:"attributes"`
+	Action     string   `json:"action"`
+}
+
+type traceAttributesResponse struct {
+	Edges []traceEdge `json:"edges"`
+}
+
+type traceEdge struct {
+	BeginShapeIndex int  `json:"begin_shape_index"`
+	EndShapeIndex   int  `json:"end_shape_index"`
+	StopSign        bool `json:"stop_sign"`
+	TrafficSignal   bool `json:"traffic_signal"`
+	EndNode         struct {
+		TrafficSignal bool `json:"traffic_signal"`
+	} `json:"end_node"`
+}
+
+// RoadControl is a signal or stop sign located at a shape index of the traced line.
+type RoadControl struct {
+	Type       string
+	ShapeIndex int
+}
+
+// TraceRoadControls returns the traffic signals and stop signs along a route line of
+// [lon, lat] pairs, in travel order. ShapeIndex points into coords.
+func (vc *ValhallaClient) TraceRoadControls(ctx context.Context, costing string, coords [][]float64) ([]RoadControl, error) {
+	shape := make([]Location, 0, len(coords))
+	for _, c := range coords {
+		if len(c) < 2 {
+			return nil, fmt.Errorf("invalid coordinate %v", c)
+		}
+		shape = append(shape, Location{Lat: c[1], Lon: c[0]})
+	}
+	return vc.traceRoadControls(ctx, traceAttributesRequest{Shape: shape, Costing: costing})
+}
+
+// traceRoadControls returns the traffic signals and stop signs along the request's
+// shape, in travel order. The route shape is walked edge by edge, so edge shape
+// indices line up with the shape sent.
+func (vc *ValhallaClient) traceRoadControls(ctx context.Context, request traceAttributesRequest) ([]RoadControl, error) {
+	request.ShapeMatch = "edge_walk"
+	request.Filters = traceAttributesFilters{
+		Attributes: []string{
+			"edge.begin_shape_index", "edge.end_shape_index",
+			"edge.stop_sign", "edge.traffic_signal", "node.traffic_signal",
+		},
+		Action: "include",
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trace_attributes request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vc.BaseURL+"/trace_attributes", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make trace_attributes request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var traceResp traceAttributesResponse
+	if err := json.Unmarshal(body, &traceResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla trace_attributes response: %w", err)
+	}
+
+	controls := make([]RoadControl, 0)
+	for _, e := range traceResp.Edges {
+		// Both flags describe the control at the end of the edge in the direction of travel.
+		switch {
+		case e.TrafficSignal || e.EndNode.TrafficSignal:
+			controls = append(controls, RoadControl{Type: RoadControlTrafficSignal, ShapeIndex: e.EndShapeIndex})
+		case e.StopSign:
+			controls = append(controls, RoadControl{Type: RoadControlStopSign, ShapeIndex: e.EndShapeIndex})
+		}
+	}
+	return controls, nil
+}
+
+// addRoadControls enriches every maneuver of the formatted response with the signals and
+// stop signs along it. Failures are logged and leave maneuvers without road controls;
+// they never fail the route itself.
+func (vc *ValhallaClient) addRoadControls(ctx context.Context, costing string, raw *RouteResponse, mobile *MobileRouteResponse) {
+	vc.addTripRoadControls(ctx, costing, &raw.Trip, &mobile.Trip)
+
+	// Alternatives that failed to format are dropped, so only pair them up when nothing was skipped.
+	if len(raw.Alternates) != len(mobile.Alternatives) {
+		slog.WarnContext(ctx, "Skipping road controls for alternates", "raw", len(raw.Alternates), "formatted", len(mobile.Alternatives))
+		return
+	}
+	for i := range raw.Alternates {
+		vc.addTripRoadControls(ctx, costing, &raw.Alternates[i].Trip, &mobile.Alternatives[i])
+	}
+}
+
+func (vc *ValhallaClient) addTripRoadControls(ctx context.Context, costing string, raw *Trip, mobile *MobileTrip) {
+	if len(raw.Legs) != len(mobile.Legs) {
+		return
+	}
+	for legIdx, leg := range raw.Legs {
+		controls, err := vc.traceRoadControls(ctx, traceAttributesRequest{EncodedPolyline: leg.Shape, Costing: costing})
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to fetch road controls", "leg", legIdx, "err", err)
+			continue
+		}
+		attachRoadControls(leg.Maneuvers, &mobile.Legs[legIdx], controls)
+	}
+}
+
+// attachRoadControls assigns each control to the maneuver whose shape span
+// (begin, end] contains it. A control at a maneuver's end is the one at the
+// intersection where the next maneuver happens.
+func attachRoadControls(maneuvers []Maneuver, leg *MobileLeg, controls []RoadControl) {
+	if len(maneuvers) != len(leg.Maneuvers) {
+		return
+	}
+	cumulative := cumulativeDistances(leg.Coordinates)
+
+	for _, c := range controls {
+		if c.ShapeIndex < 0 || c.ShapeIndex >= len(leg.Coordinates) {
+			continue
+		}
+		for i, m := range maneuvers {
+			if c.ShapeIndex <= m.BeginShapeIndex || c.ShapeIndex > m.EndShapeIndex {
+				continue
+			}
+			start := m.BeginShapeIndex
+			if start < 0 || start >= len(cumulative) {
+				start = 0
+			}
+			leg.Maneuvers[i].RoadControls = append(leg.Maneuvers[i].RoadControls, MobileRoadControl{
+				Type:           c.Type,
+				DistanceMeters: cumulative[c.ShapeIndex] - cumulative[start],
+				Coordinates:    leg.Coordinates[c.ShapeIndex],
+				AtManeuverEnd:  c.ShapeIndex == m.EndShapeIndex,
+			})
+			break
+		}
+	}
+}
+
+// cumulativeDistances returns the distance in meters from the first coordinate to each
+// coordinate of a [lon, lat] polyline.
+func cumulativeDistances(coords [][]float64) []float64 {
+	out := make([]float64, len(coords))
+	for i := 1; i < len(coords); i++ {
+		prev, cur := coords[i-1], coords[i]
+		out[i] = out[i-1] + util.HaversineMeters(prev[1], prev[0], cur[1], cur[0])
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package valhalla
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// routeCacheTTL is how long an identical route request is answered from memory.
+	routeCacheTTL        = 60 * time.Second
+	routeCacheMaxEntries = 1000
+	// routeCacheGridDeg snaps locations to roughly 30 m cells when building the cache key,
+	// so users setting off from the same block share one route.
+	routeCacheGridDeg = 0.0003
+)
+
+// routeCache serves repeated route requests from memory and coalesces identical
+// requests that are in flight at the same time into one Valhalla call.
+type routeCache struct {
+	mu      sync.Mutex
+	entries map[string]routeCacheEntry
+	flight  singleflight.Group
+}
+
+type routeCacheEntry struct {
+	body     []byte
+	storedAt time.Time
+}
+
+func newRouteCache() *routeCache {
+	return &routeCache{entries: make(map[string]routeCacheEntry)}
+}
+
+// snapRouteLocations returns request with its locations snapped to the cache grid.
+func snapRouteLocations(request RouteRequest) RouteRequest {
+	snapped := make([]Location, len(request.Locations))
+	for i, loc := range request.Locations {
+		loc.Lat = math.Round(loc.Lat/routeCacheGridDeg) * routeCacheGridDeg
+		loc.Lon = math.Round(loc.Lon/routeCacheGridDeg) * routeCacheGridDeg
+		snapped[i] = loc
+	}
+	request.Locations = snapped
+	return request
+}
+
+// routeCacheKey hashes a request whose locations are already snapped. Every other field
+// (costing, options, exclusions, language) is part of the key as sent.
+func routeCacheKey(request RouteRequest) (string, error) {
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// do returns the cached body for key, or runs fetch once for all concurrent callers with
+// the same key. fetch runs detached from the caller's cancellation so one client hanging
+// up does not fail the others waiting on it; the HTTP client timeout still bounds it.
+func (c *routeCache) do(ctx context.Context, key string, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	if body, ok := c.get(key); ok {
+		return body, nil
+	}
+
+	ch := c.flight.DoChan(key, func() (interface{}, error) {
+		body, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, body)
+		return body, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
+
+func (c *routeCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.storedAt) > routeCacheTTL {
+		return nil, false
+	}
+	return e.body, true
+}
+
+func (c *routeCache) put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= routeCacheMaxEntries {
+		for k, e := range c.entries {
+			if time.Since(e.storedAt) > routeCacheTTL {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= routeCacheMaxEntries {
+			c.entries = make(map[string]routeCacheEntry)
+		}
+	}
+	c.entries[key] = routeCacheEntry{body: body, storedAt: time.Now()}
+}
@@ -0,0 +1,160 @@
+package valhalla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetRouteOSRMSendsSnappedLocations(t *testing.T) {
+	var calls atomic.Int32
+	var sent []Location
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req RouteRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		sent = req.Locations
+		io.WriteString(w, `{"code":"Ok","routes":[]}`)
+	}))
+	defer srv.Close()
+	vc := NewValhallaClient(srv.URL)
+	vc.Client = srv.Client()
+
+	// Both points are in the same grid cell, ~10 m apart.
+	first := RouteRequest{Costing: "auto", Locations: []Location{{Lat: 35.17004, Lon: 33.36004}, {Lat: 35.18, Lon: 33.37}}}
+	second := RouteRequest{Costing: "auto", Locations: []Location{{Lat: 35.16996, Lon: 33.35996}, {Lat: 35.18, Lon: 33.37}}}
+	if _, err := vc.GetRouteOSRM(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vc.GetRouteOSRM(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Valhalla called %d times, want 1", calls.Load())
+	}
+	want := snapRouteLocations(second).Locations[0]
+	if len(sent) != 2 || sent[0].Lat != want.Lat || sent[0].Lon != want.Lon {
+		t.Errorf("sent %+v, want the snapped origin %+v", sent, want)
+	}
+	if first.Locations[0].Lat != 35.17004 {
+		t.Error("the caller's request was modified")
+	}
+
+	third := RouteRequest{Costing: "pedestrian", Locations: first.Locations}
+	if _, err := vc.GetRouteOSRM(context.Background(), third); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Error("a request with other costing was answered from the cache")
+	}
+}
+
+func TestRouteCacheTTL(t *testing.T) {
+	c := newRouteCache()
+	var calls int
+	fetch := func(context.Context) ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprint(calls)), nil
+	}
+
+	body, _ := c.do(context.Background(), "k", fetch)
+	again, _ := c.do(context.Background(), "k", fetch)
+	if calls != 1 || string(again) != string(body) {
+		t.Fatalf("fresh entry: %d calls, body %q then %q", calls, body, again)
+	}
+
+	c.mu.Lock()
+	e := c.entries["k"]
+	e.storedAt = time.Now().Add(-routeCacheTTL - time.Second)
+	c.entries["k"] = e
+	c.mu.Unlock()
+	if body, _ := c.do(context.Background(), "k", fetch); calls != 2 || string(body) != "2" {
+		t.Errorf("expired entry: %d calls, body %q", calls, body)
+	}
+
+	failing := func(context.Context) ([]byte, error) { return nil, errors.New("boom") }
+	if _, err := c.do(context.Background(), "err", failing); err == nil {
+		t.Error("error not returned")
+	}
+	if _, ok := c.get("err"); ok {
+		t.Error("a failed fetch was cached")
+	}
+}
+
+func TestRouteCacheEviction(t *testing.T) {
+	c := newRouteCache()
+	stale := time.Now().Add(-routeCacheTTL - time.Second)
+	for i := 0; i < routeCacheMaxEntries-1; i++ {
+		c.entries[fmt.Sprint("stale", i)] = routeCacheEntry{body: []byte("x"), storedAt: stale}
+	}
+	c.put("fresh", []byte("x"))
+	c.put("new", []byte("x"))
+	if len(c.entries) != 2 {
+		t.Errorf("%d entries after eviction, want the 2 fresh ones", len(c.entries))
+	}
+
+	for i := 0; i < routeCacheMaxEntries; i++ {
+		c.put(fmt.Sprint("fresh", i), []byte("x"))
+	}
+	if len(c.entries) > routeCacheMaxEntries {
+		t.Errorf("%d entries, want at most %d", len(c.entries), routeCacheMaxEntries)
+	}
+}
+
+func TestRouteCacheCoalesces(t *testing.T) {
+	c := newRouteCache()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) ([]byte, error) {
+		calls.Add(1)
+		<-release
+		// The fetch is detached from the first caller, who hangs up below.
+		return []byte("route"), ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hungUp := make(chan error, 1)
+	go func() {
+		_, err := c.do(ctx, "k", fetch)
+		hungUp <- err
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([][]byte, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i], _ = c.do(context.Background(), "k", fetch)
+		}()
+	}
+	cancel()
+	if err := <-hungUp; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller: err = %v", err)
+	}
+	// Give the waiters time to join the flight before it lands.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("fetch ran %d times, want 1", calls.Load())
+	}
+	for i, b := range bodies {
+		if string(b) != "route" {
+			t.Errorf("waiter %d got %q", i, b)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// SafeCostingOptions returns costing options for the "safe" routing preference: drivers
+// stick to main roads and keep off service roads, tracks and living streets even when
+// they are shorter; cyclists keep to cycleways and quiet streets. Walkers prefer ways OSM
+// tags as lit, as far as the tiles carry the tag. Vehicle costing has no lit option, so
+// the API ranks the alternatives these options produce by the imported lit_ways instead.
+func SafeCostingOptions(costing string) *CostingOptions {
+	f := func(v float64) *float64 { return &v }
+	switch costing {
+	case "auto":
+		return &CostingOptions{Auto: &AutoCostingOptions{
+			UseHighways:      f(1),
+			UseLivingStreets: f(0),
+			UseTracks:        f(0),
+			ServicePenalty:   f(120),
+		}}
+	case "motorcycle":
+		return &CostingOptions{Motorcycle: &MotorcycleCostingOptions{
+			UseHighways: f(1),
+			UseTrails:   f(0),
+		}}
+	case "bicycle":
+		return &CostingOptions{Bicycle: &BicycleCostingOptions{
+			UseRoads:         f(0.1),
+			UseLivingStreets: f(0.8),
+			AvoidBadSurfaces: f(0.5),
+		}}
+	case "pedestrian":
+		return &CostingOptions{Pedestrian: &PedestrianCostingOptions{
+			UseLit:      f(1),
+			AlleyFactor: f(5),
+		}}
+	}
+	return nil
+}
+
+// DefaultMotorcycleCostingOptions returns the default options for motorcycle routes: keep off
+// unpaved trails, where loose surfaces are the main risk for two-wheelers.
+func DefaultMotorcycleCostingOptions() *CostingOptions {
+	trails := 0.0
+	return &CostingOptions{Motorcycle: &MotorcycleCostingOptions{UseTrails: &trails}}
+}
+
+// GetRouteOSRM fetches a route with format "osrm" and GeoJSON geometry and returns the raw
+// body, which has the same shape as a Mapbox Directions response. With the cache on, the
+// locations are snapped to a ~30 m grid before they are sent, so requests from the same
+// block get the same route: it is served from a short-lived cache, and concurrent ones
+// share a single Valhalla call. The returned body may be shared and must not be modified.
+func (vc *ValhallaClient) GetRouteOSRM(ctx context.Context, request RouteRequest) ([]byte, error) {
+	format, shapeFormat := "osrm", "geojson"
+	request.Format = &format
+	request.ShapeFormat = &shapeFormat
+
+	if vc.routes == nil {
+		return vc.postRouteOSRM(ctx, request)
+	}
+	// The snapped request is what is sent, not only what is keyed on: otherwise whoever
+	// asked first would decide the route everyone else in the cell gets.
+	request = snapRouteLocations(request)
+	key, err := routeCacheKey(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build route cache key: %w", err)
+	}
+	return vc.routes.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return vc.postRouteOSRM(ctx, request)
+	})
+}
+
+func (vc *ValhallaClient) postRouteOSRM(ctx context.Context, request RouteRequest) ([]byte, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal route request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vc.BaseURL+"/route", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "Valhalla request failed", "err", err)
+		return nil, fmt.Errorf("failed to make route request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "Valhalla request rejected", "status", resp.StatusCode, "body", string(body))
+		return nil, responseError(resp.StatusCode, body)
+	}
+	return body, nil
+}
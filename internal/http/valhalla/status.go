@@ -0,0 +1,55 @@
+package valhalla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Status is Valhalla's /status response. TilesetLastModified is the unix time the
+// routing tiles were last written, i.e. when the tileset was built.
+type Status struct {
+	Version             string   `json:"version"`
+	TilesetLastModified int64    `json:"tileset_last_modified"`
+	AvailableActions    []string `json:"available_actions,omitempty"`
+}
+
+// TilesetBuiltAt returns the tileset build time, or nil if Valhalla did not report one.
+func (s *Status) TilesetBuiltAt() *time.Time {
+	if s.TilesetLastModified <= 0 {
+		return nil
+	}
+	t := time.Unix(s.TilesetLastModified, 0).UTC()
+	return &t
+}
+
+// GetStatus fetches the service version and tileset build time.
+func (vc *ValhallaClient) GetStatus(ctx context.Context) (*Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vc.BaseURL+"/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make status request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var status Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla status response: %w", err)
+	}
+	return &status, nil
+}
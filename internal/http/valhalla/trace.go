@@ -0,0 +1,100 @@
+package valhalla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Matched point types on MatchedPoint.Type.
+const (
+	MatchTypeMatched      = "matched"
+	MatchTypeInterpolated = "interpolated"
+	MatchTypeUnmatched    = "unmatched"
+)
+
+// traceMatchRequest map-matches a shape with trace_attributes and asks only for the
+// matched points.
+type traceMatchRequest struct {
+	Shape        []Location             `json:"shape"`
+	Costing      string                 `json:"costing"`
+	ShapeMatch   string                 `json:"shape_match"`
+	TraceOptions traceOptions           `json:"trace_options"`
+	Filters      traceAttributesFilters `json:"filters"`
+}
+
+type traceOptions struct {
+	SearchRadius int `json:"search_radius,omitempty"` // meters
+}
+
+type traceMatchResponse struct {
+	MatchedPoints []MatchedPoint `json:"matched_points"`
+}
+
+// MatchedPoint is where a shape point lands on the road network.
+type MatchedPoint struct {
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Type string  `json:"type"` // matched, interpolated or unmatched
+	// DistanceFromTracePoint is how far the point moved, in meters.
+	DistanceFromTracePoint float64 `json:"distance_from_trace_point"`
+}
+
+// MatchTrace snaps a GPS trace to the road network, returning a matched point per shape
+// point in order. Points further than searchRadiusM from any road come back unmatched.
+// Valhalla needs at least two shape points, so a single point is sent twice.
+func (vc *ValhallaClient) MatchTrace(ctx context.Context, shape []Location, costing string, searchRadiusM int) ([]MatchedPoint, error) {
+	if len(shape) == 0 {
+		return nil, errors.New("no shape points to match")
+	}
+	points := shape
+	if len(points) == 1 {
+		points = []Location{shape[0], shape[0]}
+	}
+	payload, err := json.Marshal(traceMatchRequest{
+		Shape:        points,
+		Costing:      costing,
+		ShapeMatch:   "map_snap",
+		TraceOptions: traceOptions{SearchRadius: searchRadiusM},
+		Filters: traceAttributesFilters{
+			Attributes: []string{"matched.point", "matched.type", "matched.distance_from_trace_point"},
+			Action:     "include",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal trace_attributes request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, vc.BaseURL+"/trace_attributes", bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make trace_attributes request to Valhalla: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var traceResp traceMatchResponse
+	if err := json.Unmarshal(body, &traceResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Valhalla trace_attributes response: %w", err)
+	}
+	if len(traceResp.MatchedPoints) < len(shape) {
+		return nil, fmt.Errorf("valhalla matched %d of %d shape points", len(traceResp.MatchedPoints), len(shape))
+	}
+	return traceResp.MatchedPoints[:len(shape)], nil
+}
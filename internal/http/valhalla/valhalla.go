@@ -6,22 +6,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/bwise1/waze_kibris/internal/http/resilient"
+	"github.com/bwise1/waze_kibris/util"
 )
 
 // ValhallaClient handles communication with the Valhalla API
 type ValhallaClient struct {
 	BaseURL string
 	Client  *http.Client
+	// Strict makes responses missing fields we rely on fail instead of decoding to zero values.
+	Strict bool
+
+	routes *routeCache // caches and coalesces GetRouteOSRM calls; nil disables
 }
 
 // NewValhallaClient creates a new client instance
 func NewValhallaClient(baseURL string) *ValhallaClient {
 	return &ValhallaClient{
 		BaseURL: baseURL,
-		Client:  &http.Client{Timeout: 30 * time.Second}, // Add a timeout
+		// Valhalla's POSTs only compute routes and matrices, so they are safe to retry.
+		Client: resilient.NewClient(nil, resilient.Policy{RetryPOST: true, AttemptTimeout: 8 * time.Second, Budget: 15 * time.Second}),
+		routes: newRouteCache(),
 	}
 }
 
@@ -42,20 +51,45 @@ type Location struct {
 
 // CostingOptions allows specifying detailed options for a costing model (e.g., "auto")
 type CostingOptions struct {
-	Auto *AutoCostingOptions `json:"auto,omitempty"`
-	// Add other costing models like pedestrian, bicycle, truck etc. as needed
-	// Pedestrian *PedestrianCostingOptions `json:"pedestrian,omitempty"`
+	Auto       *AutoCostingOptions       `json:"auto,omitempty"`
+	Pedestrian *PedestrianCostingOptions `json:"pedestrian,omitempty"`
+	Motorcycle *MotorcycleCostingOptions `json:"motorcycle,omitempty"`
+	Bicycle    *BicycleCostingOptions    `json:"bicycle,omitempty"`
+	// Add other costing models like truck etc. as needed
 }
 
 // AutoCostingOptions specific options for the "auto" costing model
 type AutoCostingOptions struct {
-	AvoidTolls    *bool    `json:"avoid_tolls,omitempty"`    // Avoid tolls where possible
-	AvoidHighways *bool    `json:"avoid_highways,omitempty"` // Avoid highways where possible
-	AvoidFerry    *bool    `json:"avoid_ferry,omitempty"`    // Avoid ferries where possible
-	AvoidUnpaved  *bool    `json:"avoid_unpaved,omitempty"`  // Avoid unpaved roads where possible
-	Height        *float64 `json:"height,omitempty"`         // Vehicle height restriction
-	Width         *float64 `json:"width,omitempty"`          // Vehicle width restriction
-	// Add more options as needed (e.g., top_speed, use_living_streets)
+	AvoidTolls       *bool    `json:"avoid_tolls,omitempty"`        // Avoid tolls where possible
+	AvoidHighways    *bool    `json:"avoid_highways,omitempty"`     // Avoid highways where possible
+	AvoidFerry       *bool    `json:"avoid_ferry,omitempty"`        // Avoid ferries where possible
+	AvoidUnpaved     *bool    `json:"avoid_unpaved,omitempty"`      // Avoid unpaved roads where possible
+	Height           *float64 `json:"height,omitempty"`             // Vehicle height restriction
+	Width            *float64 `json:"width,omitempty"`              // Vehicle width restriction
+	UseHighways      *float64 `json:"use_highways,omitempty"`       // 0-1, preference for highways
+	UseLivingStreets *float64 `json:"use_living_streets,omitempty"` // 0-1, preference for living streets
+	UseTracks        *float64 `json:"use_tracks,omitempty"`         // 0-1, preference for tracks
+	ServicePenalty   *float64 `json:"service_penalty,omitempty"`    // Seconds added when entering a service road
+	// Add more options as needed (e.g., top_speed)
+}
+
+// PedestrianCostingOptions specific options for the "pedestrian" costing model
+type PedestrianCostingOptions struct {
+	UseLit      *float64 `json:"use_lit,omitempty"`      // 0-1, preference for ways OSM tags lit=yes
+	AlleyFactor *float64 `json:"alley_factor,omitempty"` // Multiplier applied to alleys
+}
+
+// BicycleCostingOptions specific options for the "bicycle" costing model
+type BicycleCostingOptions struct {
+	UseRoads         *float64 `json:"use_roads,omitempty"`          // 0-1, willingness to ride with traffic rather than on cycleways
+	UseLivingStreets *float64 `json:"use_living_streets,omitempty"` // 0-1, preference for living streets
+	AvoidBadSurfaces *float64 `json:"avoid_bad_surfaces,omitempty"` // 0-1, how hard rough surfaces are avoided
+}
+
+// MotorcycleCostingOptions specific options for the "motorcycle" costing model
+type MotorcycleCostingOptions struct {
+	UseHighways *float64 `json:"use_highways,omitempty"` // 0-1, preference for highways
+	UseTrails   *float64 `json:"use_trails,omitempty"`   // 0-1, preference for unpaved/trail roads
 }
 
 // RouteRequest represents the enhanced request payload for the /route endpoint
@@ -68,6 +102,19 @@ type RouteRequest struct {
 	Language       *string         `json:"language,omitempty"`        // Optional: Language for narrative instructions (e.g., "en-US")
 	DateTime       *DateTime       `json:"date_time,omitempty"`       // Optional: Specify time for time-dependent routing
 	ID             *string         `json:"id,omitempty"`              // Optional: User-defined ID for the request
+	// IncludeRoadControls makes GetRoute add traffic signal / stop sign data to maneuvers. It
+	// costs an extra trace_attributes call per leg, so it is off by default. Not sent to
+	// Valhalla; OSRM-format callers use TraceRoadControls on the steps instead.
+	IncludeRoadControls bool `json:"-"`
+	// Format "osrm" makes Valhalla answer in the OSRM/Mapbox Directions shape (see GetRouteOSRM).
+	Format             *string `json:"format,omitempty"`
+	BannerInstructions *bool   `json:"banner_instructions,omitempty"` // OSRM format only
+	VoiceInstructions  *bool   `json:"voice_instructions,omitempty"`  // OSRM format only
+	ShapeFormat        *string `json:"shape_format,omitempty"`        // "polyline6" (default), "polyline5" or "geojson"
+	// ExcludeLocations are points whose nearest road edges the route must avoid (max 50 by default).
+	ExcludeLocations []Location `json:"exclude_locations,omitempty"`
+	// ExcludePolygons are outer rings of [lon, lat] pairs; roads crossing them are avoided.
+	ExcludePolygons [][][]float64 `json:"exclude_polygons,omitempty"`
 	// Add other top-level parameters like directions_type, avoid_locations etc. if needed
 }
 
@@ -175,6 +222,14 @@ type Summary struct {
 func (vc *ValhallaClient) GetRoute(ctx context.Context, request RouteRequest) (*MobileRouteResponse, error) {
 	url := fmt.Sprintf("%s/route", vc.BaseURL)
 
+	// Narrate in a supported locale; the formatter translates if Valhalla can't.
+	lang := DefaultLanguage
+	if request.Language != nil {
+		lang = NormalizeLanguage(*request.Language)
+	}
+	locale := NarrativeLocale(lang)
+	request.Language = &locale
+
 	// Marshal the request payload
 	payload, err := json.Marshal(request)
 	if err != nil {
@@ -191,7 +246,7 @@ func (vc *ValhallaClient) GetRoute(ctx context.Context, request RouteRequest) (*
 	// Make the HTTP request
 	resp, err := vc.Client.Do(req)
 	if err != nil {
-		log.Printf("Error making Valhalla request: %v\n", err)
+		slog.ErrorContext(ctx, "Valhalla request failed", "err", err)
 		return nil, fmt.Errorf("failed to make route request to Valhalla: %w", err)
 	}
 	defer resp.Body.Close()
@@ -199,33 +254,97 @@ func (vc *ValhallaClient) GetRoute(ctx context.Context, request RouteRequest) (*
 	// Read body first for better error reporting
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error reading Valhalla response body: %v\n", err)
+		slog.ErrorContext(ctx, "Reading Valhalla response failed", "err", err)
 		return nil, fmt.Errorf("failed to read Valhalla response body: %w", err)
 	}
 
-	// Check for HTTP errors
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Valhalla request failed with status %d: %s\n", resp.StatusCode, string(bodyBytes))
-		return nil, fmt.Errorf("valhalla error: status code %d, body: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	// Parse the response
-	var routeResponse RouteResponse
-	err = json.Unmarshal(bodyBytes, &routeResponse)
+	routeResponse, err := parseRouteResponse(resp.StatusCode, bodyBytes, vc.Strict)
 	if err != nil {
-		log.Printf("Error decoding Valhalla response: %v\nBody: %s\n", err, string(bodyBytes))
-		return nil, fmt.Errorf("failed to decode Valhalla route response: %w", err)
+		return nil, err
 	}
 
 	// Basic validation of response
 	if len(routeResponse.Trip.Legs) == 0 {
-		log.Printf("Valhalla response contained no route legs. Status: %d, Message: %s\n", routeResponse.Trip.Status, routeResponse.Trip.StatusMessage)
+		slog.WarnContext(ctx, "Valhalla response contained no route legs", "status", routeResponse.Trip.Status, "message", routeResponse.Trip.StatusMessage)
 		// Consider returning a more specific error or allowing empty result depending on use case
 		// return nil, fmt.Errorf("no route found or error in Valhalla response (Status: %d, Msg: %s)", routeResponse.Trip.Status, routeResponse.Trip.StatusMessage)
 	}
-	mobileResponse, err := FormatRouteForMobile(&routeResponse)
+	mobileResponse, err := FormatRouteForMobile(routeResponse, lang)
 	if err != nil {
 		return nil, fmt.Errorf("failed to format Valhalla route response: %w", err)
 	}
+	if request.IncludeRoadControls {
+		vc.addRoadControls(ctx, request.Costing, routeResponse, mobileResponse)
+	}
 	return mobileResponse, nil
 }
+
+// Error is a non-200 reply. Valhalla's own errors carry a JSON payload, e.g. error_code
+// 442 "No path could be found for input" or 171 "No suitable edges near location"; for
+// anything else (e.g. an HTML page from a proxy) Code is 0 and Message is the raw body.
+type Error struct {
+	HTTPStatus int    `json:"-"`
+	Code       int    `json:"error_code"`
+	Message    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("valhalla error: status code %d, body: %s", e.HTTPStatus, e.Message)
+	}
+	return fmt.Sprintf("valhalla error: status code %d, error %d: %s", e.HTTPStatus, e.Code, e.Message)
+}
+
+// NoPath reports whether the request was fine but no route joins the locations: they are
+// off the road network (171), in unconnected regions (170) or simply unreachable (442).
+func (e *Error) NoPath() bool {
+	switch e.Code {
+	case 170, 171, 442:
+		return true
+	}
+	return false
+}
+
+// RateLimited reports whether Valhalla, or a proxy in front of it, turned the request
+// away for sending too many.
+func (e *Error) RateLimited() bool {
+	return e.HTTPStatus == http.StatusTooManyRequests
+}
+
+// responseError describes a non-200 reply.
+func responseError(statusCode int, body []byte) error {
+	var e Error
+	if json.Unmarshal(body, &e) != nil || e.Code == 0 {
+		e = Error{Message: string(body)}
+	}
+	e.HTTPStatus = statusCode
+	return &e
+}
+
+// routeRequiredFields are what FormatRouteForMobile reads; strict mode rejects responses
+// without them.
+var routeRequiredFields = []string{
+	"trip.legs[].shape",
+	"trip.legs[].maneuvers",
+	"trip.summary.time",
+	"trip.summary.length",
+}
+
+func parseRouteResponse(statusCode int, body []byte, strict bool) (*RouteResponse, error) {
+	if statusCode != http.StatusOK {
+		slog.Error("Valhalla request rejected", "status", statusCode, "body", string(body))
+		return nil, responseError(statusCode, body)
+	}
+
+	var routeResponse RouteResponse
+	if err := json.Unmarshal(body, &routeResponse); err != nil {
+		slog.Error("Decoding Valhalla response failed", "err", err, "body", string(body))
+		return nil, fmt.Errorf("failed to decode Valhalla route response: %w", err)
+	}
+	if strict {
+		if err := util.RequireJSONFields(body, routeRequiredFields...); err != nil {
+			return nil, fmt.Errorf("valhalla route: %w", err)
+		}
+	}
+	return &routeResponse, nil
+}
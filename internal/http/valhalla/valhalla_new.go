@@ -2,7 +2,7 @@ package valhalla
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -49,6 +49,7 @@ type Maneuver struct {
 	Time            float64  `json:"time"`
 	Length          float64  `json:"length"` // In units specified by Trip.Units
 	BeginShapeIndex int      `json:"begin_shape_index"`
+	EndShapeIndex   int      `json:"end_shape_index"`
 	StreetNames     []string `json:"street_names,omitempty"`
 	// ... other fields
 }
@@ -68,6 +69,7 @@ type Trip struct {
 	Summary       TripSummary    `json:"summary"`
 	Units         string         `json:"units"`          // e.g., "kilometers" or "miles"
 	Status        int            `json:"status"`         // Optional: Valhalla status code
+	Language      string         `json:"language"`       // Narrative locale, e.g. "en-US"
 	StatusMessage string         `json:"status_message"` // Optional: Valhalla status message
 	// ... other fields
 }
@@ -93,7 +95,8 @@ type MobileRouteResponse struct {
 	ID           *string      `json:"id,omitempty"` // Optional: Echoes request ID
 	Trip         MobileTrip   `json:"trip"`
 	Alternatives []MobileTrip `json:"alternates,omitempty"`
-	ErrorMessage *string      `json:"errorMessage,omitempty"` // Used if processing fails partially/fully
+	ErrorMessage *string      `json:"error_message,omitempty"` // Used if processing fails partially/fully
+	Language     string       `json:"language"`                // Language of the instructions and formatted values
 }
 
 // MobileTrip represents a single processed route trip
@@ -104,12 +107,26 @@ type MobileTrip struct {
 
 // MobileTripSummary provides formatted overall trip details
 type MobileTripSummary struct {
-	TotalTimeSeconds    float64   `json:"totalTimeSeconds"`
-	TotalDistanceMeters float64   `json:"totalDistanceMeters"`
-	FormattedTime       string    `json:"formattedTime"`         // e.g., "1h 15m"
-	FormattedDistance   string    `json:"formattedDistance"`     // e.g., "120.5 km" or "75.0 mi" (depends on desired output unit)
-	Units               string    `json:"units"`                 // Indicate units used in FormattedDistance ("km" or "mi")
-	BoundingBox         []float64 `json:"boundingBox,omitempty"` // Optional: [minLon, minLat, maxLon, maxLat]
+	TotalTimeSeconds    float64   `json:"total_time_seconds"`
+	TotalDistanceMeters float64   `json:"total_distance_meters"`
+	FormattedTime       string    `json:"formatted_time"`         // e.g., "1h 15m"
+	FormattedDistance   string    `json:"formatted_distance"`     // e.g., "120.5 km" or "75.0 mi" (depends on desired output unit)
+	Units               string    `json:"units"`                  // Indicate units used in FormattedDistance ("km" or "mi")
+	BoundingBox         []float64 `json:"bounding_box,omitempty"` // Optional: [minLon, minLat, maxLon, maxLat]
+	// AdjustedTimeSeconds is added by our API: TotalTimeSeconds plus the delay expected from
+	// live TRAFFIC and ACCIDENT reports on the route, itemised in ReportDelays.
+	AdjustedTimeSeconds float64       `json:"adjusted_time_seconds,omitempty"`
+	ReportDelays        []ReportDelay `json:"report_delays,omitempty"`
+}
+
+// ReportDelay is the delay one community report adds to a trip.
+type ReportDelay struct {
+	ReportID       int64   `json:"report_id"`
+	Type           string  `json:"type"`
+	Subtype        *string `json:"subtype,omitempty"`
+	Severity       int     `json:"severity"`
+	DistanceMeters float64 `json:"distance_meters"` // along the trip, from the start
+	DelaySeconds   float64 `json:"delay_seconds"`
 }
 
 // MobileLeg represents a processed leg of the trip
@@ -121,31 +138,45 @@ type MobileLeg struct {
 
 // MobileLegSummary provides formatted leg details
 type MobileLegSummary struct {
-	TimeSeconds             float64 `json:"timeSeconds"`
-	DistanceMeters          float64 `json:"distanceMeters"`
-	FormattedTime           string  `json:"formattedTime"`
-	FormattedDistance       string  `json:"formattedDistance"`
+	TimeSeconds             float64 `json:"time_seconds"`
+	DistanceMeters          float64 `json:"distance_meters"`
+	FormattedTime           string  `json:"formatted_time"`
+	FormattedDistance       string  `json:"formatted_distance"`
 	Units                   string  `json:"units"`
-	DestinationWaypointType *string `json:"destinationWaypointType,omitempty"` // ADDED: e.g., "Stopover", "ViaPassThrough", "FinalDestination"
-	DestinationWaypointName *string `json:"destinationWaypointName,omitempty"` // ADDED: Name of the destination waypoint for this leg
+	DestinationWaypointType *string `json:"destination_waypoint_type,omitempty"` // ADDED: e.g., "Stopover", "ViaPassThrough", "FinalDestination"
+	DestinationWaypointName *string `json:"destination_waypoint_name,omitempty"` // ADDED: Name of the destination waypoint for this leg
 }
 
 // MobileManeuver represents a simplified turn-by-turn instruction
 type MobileManeuver struct {
 	Type             string    `json:"type"` // String representation (e.g., "TurnLeft", "RoundaboutExit")
 	Instruction      string    `json:"instruction"`
-	DistanceMeters   float64   `json:"distanceMeters"`             // Distance for this step
-	TimeSeconds      float64   `json:"timeSeconds"`                // Time for this step
-	StartCoordinates []float64 `json:"startCoordinates,omitempty"` // [lon, lat]
-	StreetName       string    `json:"streetName,omitempty"`
+	DistanceMeters   float64   `json:"distance_meters"`             // Distance for this step
+	TimeSeconds      float64   `json:"time_seconds"`                // Time for this step
+	StartCoordinates []float64 `json:"start_coordinates,omitempty"` // [lon, lat]
+	StreetName       string    `json:"street_name,omitempty"`
+	// RoadControls lists traffic signals and stop signs passed while following this maneuver,
+	// in travel order. Only filled when the route was requested with IncludeRoadControls.
+	RoadControls []MobileRoadControl `json:"road_controls,omitempty"`
+}
+
+// MobileRoadControl is a traffic signal or stop sign along a maneuver, so clients can
+// say "turn left at the second traffic light".
+type MobileRoadControl struct {
+	Type           string    `json:"type"`            // "TrafficSignal" or "StopSign"
+	DistanceMeters float64   `json:"distance_meters"` // Distance from the start of the maneuver
+	Coordinates    []float64 `json:"coordinates"`     // [lon, lat]
+	AtManeuverEnd  bool      `json:"at_maneuver_end"` // True when it sits at the next maneuver's intersection
 }
 
 // --- Formatting Helper Functions ---
 
-// formatDuration converts seconds into a "Xh Ym" or "Ym Zs" string
-func formatDuration(seconds float64) string {
+// formatDuration converts seconds into a "Xh Ym" or "Ym Zs" string, or the equivalent in
+// lang (e.g. "1 sa 15 dk")
+func formatDuration(seconds float64, lang string) string {
+	l := labelsFor(lang)
 	if seconds < 0 {
-		return "0s"
+		return "0" + l.seconds
 	}
 	dur := time.Duration(seconds * float64(time.Second))
 	h := int(dur.Hours())
@@ -153,31 +184,27 @@ func formatDuration(seconds float64) string {
 	s := int(dur.Seconds()) % 60
 
 	if h > 0 {
-		return fmt.Sprintf("%dh %dm", h, m)
+		return fmt.Sprintf("%d%s %d%s", h, l.hours, m, l.minutes)
 	}
 	if m > 0 {
-		return fmt.Sprintf("%dm %ds", m, s)
+		return fmt.Sprintf("%d%s %d%s", m, l.minutes, s, l.seconds)
 	}
-	return fmt.Sprintf("%ds", s)
+	return fmt.Sprintf("%d%s", s, l.seconds)
 }
 
-// formatDistance converts meters into a "X.Y km" or "X.Y mi" string
-func formatDistance(meters float64, targetUnit string) (string, string) {
-	unit := "km" // Default unit
-	var value float64
-
+// formatDistance converts meters into a "X.Y km" or "X.Y mi" string in lang's notation
+// (e.g. "12,5 km"). The returned unit is the language-independent code: km, m or mi.
+func formatDistance(meters float64, targetUnit, lang string) (string, string) {
+	l := labelsFor(lang)
 	if targetUnit == "miles" { // Valhalla might use "miles" or "mi" in trip.Units
-		unit = "mi"
-		value = meters / 1609.34
-		return fmt.Sprintf("%.1f %s", value, unit), unit
-	} else { // Default to kilometers, Valhalla might use "kilometers" or "km"
-		unit = "km"
-		value = meters / 1000.0
-		if value < 1.0 && value > 0 { // Show meters if less than 1 km and not zero
-			return fmt.Sprintf("%.0f m", meters), "m"
-		}
-		return fmt.Sprintf("%.1f %s", value, unit), unit
+		return l.decimal(meters/1609.34) + " " + l.mi, "mi"
+	}
+	// Default to kilometers, Valhalla might use "kilometers" or "km"
+	value := meters / 1000.0
+	if value < 1.0 && value > 0 { // Show meters if less than 1 km and not zero
+		return fmt.Sprintf("%.0f %s", meters, l.m), "m"
 	}
+	return l.decimal(value) + " " + l.km, "km"
 }
 
 // metersPerUnit returns the conversion factor from the Valhalla unit to meters.
@@ -189,8 +216,10 @@ func metersPerUnit(unit string) float64 {
 	return 1000.0 // Default to kilometers
 }
 
-// formatTripForMobile processes a single Valhalla Trip into a MobileTrip
-func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
+// formatTripForMobile processes a single Valhalla Trip into a MobileTrip with values
+// formatted in lang. Instructions are translated from templates when Valhalla narrated the
+// trip in another language.
+func formatTripForMobile(trip *Trip, lang string) (*MobileTrip, error) {
 	if trip == nil {
 		return nil, fmt.Errorf("cannot format nil trip")
 	}
@@ -198,7 +227,7 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 		// This check is important as trip.Locations is used to determine via points.
 		// If it's nil, we might not be able to correctly identify via points.
 		// Depending on requirements, you might return an error or proceed with limited info.
-		log.Println("Warning: trip.Locations is nil, cannot determine via point details accurately.")
+		slog.Warn("Trip has no locations, cannot determine via point details accurately")
 		// return nil, fmt.Errorf("trip.Locations is nil, cannot process via points")
 	}
 
@@ -208,12 +237,13 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 
 	metersFactor := metersPerUnit(trip.Units)
 	totalDistanceMeters := trip.Summary.Length * metersFactor
-	formattedDistStr, distUnit := formatDistance(totalDistanceMeters, trip.Units)
+	formattedDistStr, distUnit := formatDistance(totalDistanceMeters, trip.Units, lang)
+	translate := !narratedIn(trip.Language, lang)
 
 	mobileTrip.Summary = MobileTripSummary{
 		TotalTimeSeconds:    trip.Summary.Time,
 		TotalDistanceMeters: totalDistanceMeters,
-		FormattedTime:       formatDuration(trip.Summary.Time),
+		FormattedTime:       formatDuration(trip.Summary.Time, lang),
 		FormattedDistance:   formattedDistStr,
 		Units:               distUnit,
 	}
@@ -240,11 +270,11 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 
 		// Process Leg Summary
 		legDistMeters := leg.Summary.Length * metersFactor
-		legFormattedDist, legDistUnit := formatDistance(legDistMeters, trip.Units)
+		legFormattedDist, legDistUnit := formatDistance(legDistMeters, trip.Units, lang)
 		mobileLeg.Summary = MobileLegSummary{
 			TimeSeconds:       leg.Summary.Time,
 			DistanceMeters:    legDistMeters,
-			FormattedTime:     formatDuration(leg.Summary.Time),
+			FormattedTime:     formatDuration(leg.Summary.Time, lang),
 			FormattedDistance: legFormattedDist,
 			Units:             legDistUnit,
 		}
@@ -286,9 +316,9 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 				mobileLeg.Summary.DestinationWaypointName = &destWaypointInfo.Street
 			}
 		} else if trip.Locations == nil {
-			log.Printf("Warning: trip.Locations is nil, cannot determine destination waypoint type/name for leg %d", legIdx)
+			slog.Warn("Trip has no locations, cannot determine destination waypoint", "leg", legIdx)
 		} else {
-			log.Printf("Warning: Not enough location info to determine destination waypoint for leg %d (locations: %d, legIdx+1: %d)", legIdx, len(trip.Locations), legIdx+1)
+			slog.Warn("Not enough locations to determine destination waypoint", "leg", legIdx, "locations", len(trip.Locations))
 		}
 		// --- END ADDED LOGIC ---
 
@@ -306,6 +336,15 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 				TimeSeconds:    maneuver.Time,
 				StreetName:     streetName,
 			}
+			if translate {
+				firstStreet := ""
+				if len(maneuver.StreetNames) > 0 {
+					firstStreet = maneuver.StreetNames[0]
+				}
+				if instruction, ok := ManeuverInstruction(lang, mobileManeuver.Type, firstStreet); ok {
+					mobileManeuver.Instruction = instruction
+				}
+			}
 			if len(mobileCoords) > maneuver.BeginShapeIndex && maneuver.BeginShapeIndex >= 0 {
 				mobileManeuver.StartCoordinates = mobileCoords[maneuver.BeginShapeIndex]
 			}
@@ -318,21 +357,24 @@ func formatTripForMobile(trip *Trip) (*MobileTrip, error) {
 	return &mobileTrip, nil
 }
 
-// FormatRouteForMobile takes a raw Valhalla response and converts it to mobile-friendly format
-func FormatRouteForMobile(resp *RouteResponse) (*MobileRouteResponse, error) {
+// FormatRouteForMobile takes a raw Valhalla response and converts it to mobile-friendly
+// format in lang (en, tr or el; anything else is formatted in DefaultLanguage)
+func FormatRouteForMobile(resp *RouteResponse, lang string) (*MobileRouteResponse, error) {
 	if resp == nil {
 		return nil, fmt.Errorf("received nil RouteResponse")
 	}
 
+	lang = NormalizeLanguage(lang)
 	mobileResp := MobileRouteResponse{
 		ID:           resp.ID,
 		Alternatives: make([]MobileTrip, 0, len(resp.Alternates)),
+		Language:     lang,
 	}
 
 	// Process the main trip
 	// Ensure resp.Trip is not nil before dereferencing, though formatTripForMobile handles nil trip.
 	if resp.Trip.Legs != nil || resp.Trip.Summary.Time > 0 { // Basic check if trip has some data
-		mainTrip, err := formatTripForMobile(&resp.Trip)
+		mainTrip, err := formatTripForMobile(&resp.Trip, lang)
 		if err != nil {
 			errMsg := fmt.Sprintf("Error processing main trip: %v", err)
 			mobileResp.ErrorMessage = &errMsg
@@ -341,16 +383,16 @@ func FormatRouteForMobile(resp *RouteResponse) (*MobileRouteResponse, error) {
 		}
 	} else {
 		// Handle case where resp.Trip might be an empty struct
-		log.Println("Main trip in RouteResponse appears to be empty or uninitialized.")
+		slog.Warn("Main trip in route response is empty")
 	}
 
 	// Process alternatives
 	for i, altRoute := range resp.Alternates { // Assuming resp.Alternates is []AlternateRoute
 		// altRoute.Trip is the actual Trip object for the alternative
 		if altRoute.Trip.Legs != nil || altRoute.Trip.Summary.Time > 0 { // Basic check
-			formattedAlt, err := formatTripForMobile(&altRoute.Trip)
+			formattedAlt, err := formatTripForMobile(&altRoute.Trip, lang)
 			if err != nil {
-				log.Printf("Error processing alternative %d: %v", i, err)
+				slog.Warn("Formatting alternative failed", "alternative", i, "err", err)
 				errMsgPart := fmt.Sprintf("Error processing alternative %d: %v", i, err)
 				if mobileResp.ErrorMessage == nil {
 					mobileResp.ErrorMessage = &errMsgPart
@@ -363,7 +405,7 @@ func FormatRouteForMobile(resp *RouteResponse) (*MobileRouteResponse, error) {
 				mobileResp.Alternatives = append(mobileResp.Alternatives, *formattedAlt)
 			}
 		} else {
-			log.Printf("Alternative trip %d in RouteResponse appears to be empty or uninitialized.", i)
+			slog.Warn("Alternative trip in route response is empty", "alternative", i)
 		}
 	}
 
@@ -0,0 +1,158 @@
+package vision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+)
+
+const annotateURL = "https://vision.googleapis.com/v1/images:annotate"
+
+// Likelihood values returned by SafeSearch, least to most likely.
+const (
+	LikelihoodUnknown      = "UNKNOWN"
+	LikelihoodVeryUnlikely = "VERY_UNLIKELY"
+	LikelihoodUnlikely     = "UNLIKELY"
+	LikelihoodPossible     = "POSSIBLE"
+	LikelihoodLikely       = "LIKELY"
+	LikelihoodVeryLikely   = "VERY_LIKELY"
+)
+
+// VisionClient calls the Google Cloud Vision API.
+type VisionClient struct {
+	APIKey string
+	Client *http.Client
+}
+
+// NewVisionClient creates a client. With an empty key the client is still returned
+// but Configured reports false.
+func NewVisionClient(apiKey string) *VisionClient {
+	if apiKey == "" {
+		slog.Warn("Cloud Vision API key is empty; image moderation is disabled")
+	}
+	return &VisionClient{
+		APIKey: apiKey,
+		Client: &http.Client{Timeout: 15 * time.Second, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// Configured reports whether requests can be made.
+func (vc *VisionClient) Configured() bool {
+	return vc != nil && vc.APIKey != ""
+}
+
+// SafeSearchAnnotation is the likelihood of each kind of unsafe content in an image.
+type SafeSearchAnnotation struct {
+	Adult    string `json:"adult"`
+	Spoof    string `json:"spoof"`
+	Medical  string `json:"medical"`
+	Violence string `json:"violence"`
+	Racy     string `json:"racy"`
+}
+
+type annotateRequest struct {
+	Requests []annotateImageRequest `json:"requests"`
+}
+
+type annotateImageRequest struct {
+	Image    annotateImage     `json:"image"`
+	Features []annotateFeature `json:"features"`
+}
+
+type annotateImage struct {
+	Content string `json:"content"`
+}
+
+type annotateFeature struct {
+	Type string `json:"type"`
+}
+
+type annotateResponse struct {
+	Responses []struct {
+		SafeSearchAnnotation *SafeSearchAnnotation `json:"safeSearchAnnotation"`
+		Error                *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"responses"`
+}
+
+// SafeSearch runs SafeSearch detection on raw image bytes.
+func (vc *VisionClient) SafeSearch(ctx context.Context, image []byte) (*SafeSearchAnnotation, error) {
+	if !vc.Configured() {
+		return nil, fmt.Errorf("cloud vision API key is not set")
+	}
+
+	payload, err := json.Marshal(annotateRequest{
+		Requests: []annotateImageRequest{{
+			Image:    annotateImage{Content: base64.StdEncoding.EncodeToString(image)},
+			Features: []annotateFeature{{Type: "SAFE_SEARCH_DETECTION"}},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SafeSearch request: %w", err)
+	}
+
+	fullURL := fmt.Sprintf("%s?%s", annotateURL, url.Values{"key": {vc.APIKey}}.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SafeSearch request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := vc.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute SafeSearch request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SafeSearch response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cloud vision error: status code %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var annotated annotateResponse
+	if err := json.Unmarshal(body, &annotated); err != nil {
+		return nil, fmt.Errorf("failed to decode SafeSearch response: %w", err)
+	}
+	if len(annotated.Responses) == 0 {
+		return nil, fmt.Errorf("cloud vision returned no responses")
+	}
+	r := annotated.Responses[0]
+	if r.Error != nil {
+		return nil, fmt.Errorf("cloud vision API error %d: %s", r.Error.Code, r.Error.Message)
+	}
+	if r.SafeSearchAnnotation == nil {
+		return nil, fmt.Errorf("cloud vision returned no safe search annotation")
+	}
+	return r.SafeSearchAnnotation, nil
+}
+
+// LikelihoodRank orders likelihoods from 0 (unknown) to 5 (very likely).
+func LikelihoodRank(likelihood string) int {
+	switch likelihood {
+	case LikelihoodVeryUnlikely:
+		return 1
+	case LikelihoodUnlikely:
+		return 2
+	case LikelihoodPossible:
+		return 3
+	case LikelihoodLikely:
+		return 4
+	case LikelihoodVeryLikely:
+		return 5
+	}
+	return 0
+}
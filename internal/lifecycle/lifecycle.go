@@ -0,0 +1,143 @@
+// Package lifecycle starts the server's long-running pieces and stops them in order when
+// the process is asked to exit.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager shuts down in three stages, all under one deadline: drain steps stop taking
+// new work and finish what is in flight, then background workers are cancelled and
+// awaited, then close steps release what the earlier stages were still using (e.g. the
+// database pool).
+type Manager struct {
+	// Grace is how long to keep serving after the signal, so load balancers stop routing
+	// here before listeners close.
+	Grace time.Duration
+	// Timeout bounds the whole shutdown once draining starts.
+	Timeout time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	drains  []step
+	closers []step
+}
+
+func New(grace, timeout time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{Grace: grace, Timeout: timeout, ctx: ctx, cancel: cancel}
+}
+
+// Context is cancelled once draining has finished; background work should stop with it.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go runs a background worker until Context is cancelled. Shutdown waits for it to return.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		fn(m.ctx)
+		slog.Info("Worker stopped", "worker", name)
+	}()
+}
+
+// OnDrain adds a step that stops intake and waits for in-flight work. Steps run in the
+// order they were added.
+func (m *Manager) OnDrain(name string, fn func(ctx context.Context) error) {
+	m.drains = append(m.drains, step{name, fn})
+}
+
+// OnClose adds a step that runs after the workers have stopped, in the order added.
+func (m *Manager) OnClose(name string, fn func(ctx context.Context) error) {
+	m.closers = append(m.closers, step{name, fn})
+}
+
+// Run calls serve in the background and blocks until one of signals arrives or serve
+// fails, then shuts down. http.ErrServerClosed from serve counts as a clean stop.
+func (m *Manager) Run(serve func() error, signals ...os.Signal) error {
+	sigCtx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		err := serve()
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		served <- err
+	}()
+
+	var serveErr error
+	select {
+	case <-sigCtx.Done():
+		stop() // a second signal kills the process the default way
+		slog.Info("Shutdown requested, still serving", "grace", m.Grace)
+		time.Sleep(m.Grace)
+	case serveErr = <-served:
+		served = nil
+		if serveErr != nil {
+			slog.Error("Server failed", "err", serveErr)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.Timeout)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	if served != nil {
+		select {
+		case serveErr = <-served:
+		case <-ctx.Done():
+		}
+	}
+	return errors.Join(serveErr, err)
+}
+
+// Shutdown runs the drain steps, cancels and waits for the workers, then runs the close
+// steps. Close steps run even if an earlier stage failed or ctx expired, so resources
+// are always released; every failure is returned.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	var errs []error
+	run := func(steps []step) {
+		for _, s := range steps {
+			start := time.Now()
+			if err := s.fn(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+				continue
+			}
+			slog.Info("Shutdown step done", "step", s.name, "took", time.Since(start).Round(time.Millisecond))
+		}
+	}
+
+	run(m.drains)
+
+	m.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background workers: %w", ctx.Err()))
+	}
+
+	run(m.closers)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	m := New(0, time.Second)
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) {
+		mu.Lock()
+		order = append(order, s)
+		mu.Unlock()
+	}
+
+	m.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker")
+	})
+	m.OnDrain("http", func(context.Context) error {
+		if m.Context().Err() != nil {
+			t.Error("workers cancelled before draining")
+		}
+		record("http")
+		return nil
+	})
+	m.OnDrain("websockets", func(context.Context) error {
+		record("websockets")
+		return errors.New("boom")
+	})
+	m.OnClose("database", func(context.Context) error {
+		record("database")
+		return nil
+	})
+
+	err := m.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "websockets: boom") {
+		t.Errorf("Shutdown() = %v, want the websockets error", err)
+	}
+	if got, want := strings.Join(order, ","), "http,websockets,worker,database"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestShutdownStuckWorker(t *testing.T) {
+	m := New(0, time.Second)
+	release := make(chan struct{})
+	defer close(release)
+	m.Go("stuck", func(context.Context) { <-release })
+	closed := false
+	m.OnClose("database", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+	}
+	if !closed {
+		t.Error("close steps skipped after the deadline")
+	}
+}
+
+func TestRunServeFailure(t *testing.T) {
+	m := New(time.Hour, time.Second)
+	drained := false
+	m.OnDrain("http", func(context.Context) error {
+		drained = true
+		return nil
+	})
+	failure := errors.New("address in use")
+	if err := m.Run(func() error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("Run() = %v, want the serve error", err)
+	}
+	if !drained {
+		t.Error("failed serve did not shut down")
+	}
+
+	m = New(time.Hour, time.Second)
+	if err := m.Run(func() error { return http.ErrServerClosed }); err != nil {
+		t.Errorf("Run() = %v, want nil for a closed server", err)
+	}
+}
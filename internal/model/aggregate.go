@@ -0,0 +1,16 @@
+package model
+
+// Outcomes of one source in a fan-out response.
+const (
+	SourceStatusOK      = "ok"
+	SourceStatusError   = "error"
+	SourceStatusTimeout = "timeout"
+)
+
+// SourceStatus reports how one upstream source fared in a fan-out request.
+type SourceStatus struct {
+	Source    string  `json:"source"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertZone is an area a user watches for new reports: a polygon they drew, or a route
+// (such as home to work) widened into a corridor CorridorWidthM on each side. Polygon is
+// the ring as [lng, lat] pairs; Route is the line for corridors. Empty ReportTypes means
+// every type.
+type AlertZone struct {
+	ID             uuid.UUID   `json:"id"`
+	Name           string      `json:"name"`
+	Kind           string      `json:"kind"` // polygon or corridor
+	Polygon        [][]float64 `json:"polygon,omitempty"`
+	Route          [][]float64 `json:"route,omitempty"`
+	CorridorWidthM *int        `json:"corridor_width_m,omitempty"`
+	ReportTypes    []string    `json:"report_types"`
+	MinSeverity    int         `json:"min_severity"`
+	Active         bool        `json:"active"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+// AlertZoneRequest creates an alert zone, or replaces one on PUT. Set exactly one of
+// Polygon (at least three [lng, lat] points; the ring is closed for you) or Route (at
+// least two). CorridorWidthM defaults to 200 for routes; MinSeverity defaults to 1 and
+// Active to true.
+type AlertZoneRequest struct {
+	Name           string      `json:"name" validate:"required,max=100"`
+	Polygon        [][]float64 `json:"polygon,omitempty" validate:"omitempty,min=3,max=500,dive,len=2"`
+	Route          [][]float64 `json:"route,omitempty" validate:"omitempty,min=2,max=5000,dive,len=2"`
+	CorridorWidthM int         `json:"corridor_width_m,omitempty" validate:"omitempty,min=50,max=1000"`
+	ReportTypes    []string    `json:"report_types" validate:"omitempty,max=6,dive,oneof=TRAFFIC POLICE ACCIDENT HAZARD ROAD_CLOSED PHOTOSHARING"`
+	MinSeverity    int         `json:"min_severity,omitempty" validate:"omitempty,min=1,max=5"`
+	Active         *bool       `json:"active,omitempty"`
+}
+
+// AlertZoneMatch is a user to alert about a new report and the zone it fell in.
+type AlertZoneMatch struct {
+	UserID   uuid.UUID
+	ZoneID   uuid.UUID
+	ZoneName string
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Admin analytics aggregates, served under /admin/analytics. Days are UTC dates
+// ("2024-05-01"); every endpoint covers the latest Days days, today included.
+
+// ReportsPerDay is how many reports of one type were created on a day.
+type ReportsPerDay struct {
+	Day     string `json:"day"`
+	Type    string `json:"type"`
+	Reports int    `json:"reports"`
+}
+
+// ActiveUsersStats counts users who sent a location ping or created a report.
+type ActiveUsersStats struct {
+	Since       time.Time       `json:"since"`
+	ActiveUsers int             `json:"active_users"` // over the whole window
+	Daily       []DailyActivity `json:"daily"`
+}
+
+type DailyActivity struct {
+	Day   string `json:"day"`
+	Users int    `json:"users"`
+}
+
+// ReportHotspot is a grid cell of CellDeg degrees and the reports created in it.
+// Latitude and Longitude are the cell's center.
+type ReportHotspot struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	CellDeg   float64 `json:"cell_deg"`
+	Reports   int     `json:"reports"`
+}
+
+// ReportLifetime is how long reports of one type stayed up, from creation until they
+// expired, were resolved or were taken down.
+type ReportLifetime struct {
+	Type          string  `json:"type"`
+	Reports       int     `json:"reports"`
+	AvgMinutes    float64 `json:"avg_minutes"`
+	MedianMinutes float64 `json:"median_minutes"`
+}
+
+// ExternalAPIUsage is the requests sent to one provider host on a day.
+type ExternalAPIUsage struct {
+	Day      string `json:"day"`
+	Host     string `json:"host"`
+	Requests int64  `json:"requests"`
+	Errors   int64  `json:"errors"`
+}
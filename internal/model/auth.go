@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -16,12 +18,34 @@ type ResendCodeRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
 
+// PhoneCodeRequest asks for a login code by SMS. The number may be typed in any common
+// form; numbers without a country code are taken as Cypriot.
+type PhoneCodeRequest struct {
+	Phone string `json:"phone" validate:"required"`
+}
+
+type PhoneVerifyRequest struct {
+	Phone string `json:"phone" validate:"required"`
+	Code  string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// PhoneCodeResponse is the answer to POST /auth/phone/request.
+type PhoneCodeResponse struct {
+	Phone     string    `json:"phone"` // normalized, as the code was sent to it
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type VerifyCodeRequest struct {
 	Code  string `json:"code" validate:"required"`
 	Type  string `json:"type" validate:"required"`
 	Email string `json:"email" validate:"required,email"`
 }
 
+// VerifyLinkRequest exchanges the token from an emailed verification link for a login.
+type VerifyLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 type VerifyCodeResponse struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
@@ -33,6 +57,7 @@ type LoginUserResponse struct {
 	LastName          *string   `json:"lastname,omitempty"`
 	Username          *string   `json:"username,omitempty"`
 	Email             string    `json:"email"`
+	Phone             *string   `json:"phone,omitempty"`
 	ProfileIcon       *string   `json:"profile_icon,omitempty"`
 	IsVerified        bool      `json:"is_verified"`
 	PreferredLanguage *string   `json:"preferred_language,omitempty"`
@@ -42,6 +67,34 @@ type LoginResponse struct {
 	User         *LoginUserResponse `json:"user"`
 	Token        string             `json:"token"`
 	RefreshToken string             `json:"refresh_token"`
+	// DeviceSecret is issued when the app sends X-Device-ID at login; it is used to sign
+	// report submissions and location heartbeats.
+	DeviceSecret string `json:"device_secret,omitempty"`
+	// Reactivated is true when this login cancelled a pending account deletion.
+	Reactivated bool `json:"reactivated,omitempty"`
+}
+
+// DeviceInfo identifies the app install making the request (X-Device-* headers).
+type DeviceInfo struct {
+	DeviceID   string `json:"device_id"`
+	Model      string `json:"model,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	IsEmulator bool   `json:"is_emulator"`
+}
+
+// Session is a signed-in device: one active refresh token and where it was issued.
+type Session struct {
+	ID          int64      `json:"id"`
+	DeviceID    *string    `json:"device_id,omitempty"`
+	DeviceModel *string    `json:"device_model,omitempty"`
+	Platform    *string    `json:"platform,omitempty"`
+	IPAddress   *string    `json:"ip_address,omitempty"`
+	UserAgent   *string    `json:"user_agent,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	// Current marks the session of the device making the request (by X-Device-ID).
+	Current bool `json:"current"`
 }
 
 type UserInfo struct {
@@ -68,3 +121,20 @@ type UserAuthProvider struct {
 	AuthProviderID string    // e.g., "google_user_123"
 
 }
+
+// RefreshTokenResponse is returned by /auth/refresh. RefreshToken is the token the client
+// sent back unless Rotated is true.
+type RefreshTokenResponse struct {
+	AccessToken          string    `json:"access_token"`
+	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	RefreshToken         string    `json:"refresh_token"`
+	Rotated              bool      `json:"rotated"`
+}
+
+// TokenIntrospection describes a valid access token, checked from its signature alone.
+type TokenIntrospection struct {
+	Active    bool      `json:"active"`
+	UserID    string    `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ExpiresIn int64     `json:"expires_in"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BroadcastPolicy maps a report type and minimum severity to the radius (meters)
+// within which connected users receive the report_update event.
+type BroadcastPolicy struct {
+	ID          int64      `json:"id"`
+	ReportType  string     `json:"report_type"` // report type or "*" for the fallback
+	MinSeverity int        `json:"min_severity"`
+	RadiusM     int        `json:"radius_m"`
+	UpdatedBy   *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type UpsertBroadcastPolicyRequest struct {
+	ReportType  string `json:"report_type" validate:"required"`
+	MinSeverity int    `json:"min_severity" validate:"min=1,max=5"`
+	RadiusM     int    `json:"radius_m" validate:"required,min=100,max=50000"`
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarIntegration is a user's connected calendar feed.
+type CalendarIntegration struct {
+	UserID       uuid.UUID  `json:"-"`
+	FeedURL      string     `json:"feed_url"`
+	OriginLat    *float64   `json:"origin_latitude,omitempty"`
+	OriginLng    *float64   `json:"origin_longitude,omitempty"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	LastError    *string    `json:"last_error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// ConnectCalendarRequest connects or replaces the user's calendar feed. Without an origin,
+// departure times are computed from the user's latest known location.
+type ConnectCalendarRequest struct {
+	FeedURL         string   `json:"feed_url" validate:"required"`
+	OriginLatitude  *float64 `json:"origin_latitude,omitempty" validate:"required_with=OriginLongitude,omitempty,latitude"`
+	OriginLongitude *float64 `json:"origin_longitude,omitempty" validate:"required_with=OriginLatitude,omitempty,longitude"`
+}
+
+// PlannedDrive is a drive to an upcoming event, suggested from the user's calendar or
+// scheduled by the user. DepartAt is re-estimated from traffic as the drive nears;
+// NotifiedAt is when the user was told to leave.
+type PlannedDrive struct {
+	ID              int64      `json:"id"`
+	UserID          uuid.UUID  `json:"-"`
+	Source          string     `json:"source"`
+	Title           *string    `json:"title,omitempty"`
+	DestinationName string     `json:"destination_name"`
+	Latitude        float64    `json:"latitude"`
+	Longitude       float64    `json:"longitude"`
+	OriginLat       *float64   `json:"origin_latitude,omitempty"`
+	OriginLng       *float64   `json:"origin_longitude,omitempty"`
+	ArriveBy        time.Time  `json:"arrive_by"`
+	DepartAt        time.Time  `json:"depart_at"`
+	DurationS       float64    `json:"duration_s"`
+	Status          string     `json:"status"`
+	NotifiedAt      *time.Time `json:"notified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// PlannedDriveRequest schedules a drive, or replaces a scheduled one. Without an origin
+// the drive starts from the user's latest known location.
+type PlannedDriveRequest struct {
+	Title           *string   `json:"title,omitempty" validate:"omitempty,max=200"`
+	DestinationName string    `json:"destination_name" validate:"required,max=200"`
+	Latitude        float64   `json:"latitude" validate:"required,latitude"`
+	Longitude       float64   `json:"longitude" validate:"required,longitude"`
+	OriginLatitude  *float64  `json:"origin_latitude,omitempty" validate:"required_with=OriginLongitude,omitempty,latitude"`
+	OriginLongitude *float64  `json:"origin_longitude,omitempty" validate:"required_with=OriginLatitude,omitempty,longitude"`
+	ArriveBy        time.Time `json:"arrive_by" validate:"required"`
+}
+
+// PlannedDriveUpsert is what the calendar sync writes for one event.
+type PlannedDriveUpsert struct {
+	ExternalID      string
+	Title           *string
+	DestinationName string
+	Latitude        float64
+	Longitude       float64
+	ArriveBy        time.Time
+	DepartAt        time.Time
+	DurationS       float64
+}
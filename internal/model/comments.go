@@ -6,10 +6,26 @@ import (
 	"github.com/google/uuid"
 )
 
+// Comment is a comment on a report. Deleted comments that still have replies are kept in
+// the thread with their content blanked.
 type Comment struct {
-	ID        uuid.UUID `json:"id"`
-	ReportID  uuid.UUID `json:"report_id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              uuid.UUID  `json:"id"`
+	ReportID        int64      `json:"report_id"`
+	ParentCommentID *uuid.UUID `json:"parent_comment_id,omitempty"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Username        *string    `json:"username,omitempty"`
+	Comment         string     `json:"comment"`
+	CreatedAt       time.Time  `json:"created_at"`
+	EditedAt        *time.Time `json:"edited_at,omitempty"`
+	Deleted         bool       `json:"deleted,omitempty"`
+	Replies         []Comment  `json:"replies,omitempty"`
+}
+
+type CreateCommentRequest struct {
+	Content         string     `json:"content" validate:"required,max=1000"`
+	ParentCommentID *uuid.UUID `json:"parent_comment_id,omitempty"`
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content" validate:"required,max=1000"`
 }
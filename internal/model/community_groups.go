@@ -8,14 +8,14 @@ import (
 
 type CommunityGroup struct {
 	ID                  uuid.UUID  `json:"id"`
-	Name                string     `json:"name"`
+	Name                string     `json:"name" validate:"required"`
 	ShortCode           string     `json:"short_code"`
 	Description         *string    `json:"description"`
-	GroupType           string     `json:"group_type"`
+	GroupType           string     `json:"group_type" validate:"omitempty,oneof=destination event route general"`
 	DestinationPlaceID  *string    `json:"destination_place_id,omitempty"`
 	DestinationName     *string    `json:"destination_name,omitempty"`
 	DestinationLocation *string    `json:"destination_location,omitempty"` // WKT format for geometry
-	Visibility          string     `json:"visibility"`
+	Visibility          string     `json:"visibility" validate:"omitempty,oneof=public private"`
 	CreatorID           uuid.UUID  `json:"creator_id,omitempty"`
 	IconURL             *string    `json:"icon_url,omitempty"`
 	MemberCount         int        `json:"member_count"`
@@ -27,18 +27,22 @@ type CommunityGroup struct {
 	DeletedAt           *time.Time `json:"deleted_at,omitempty"`
 	CreatedAt           time.Time  `json:"created_at"`
 	UpdatedAt           time.Time  `json:"updated_at"`
+
+	PinnedMessages []GroupMessage `json:"pinned_messages,omitempty"` // group detail only, newest pin first
 }
 
 type GroupMembership struct {
-	ID        uuid.UUID  `json:"id"`
-	GroupID   uuid.UUID  `json:"group_id"`
-	UserID    uuid.UUID  `json:"user_id"`
-	Role      string     `json:"role"`   // "admin" or "member"
-	Status    string     `json:"status"` // "active", "pending", or "invited"
-	JoinedAt  time.Time  `json:"joined_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	IsDeleted bool       `json:"is_deleted"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID                uuid.UUID  `json:"id"`
+	GroupID           uuid.UUID  `json:"group_id"`
+	UserID            uuid.UUID  `json:"user_id"`
+	Role              string     `json:"role"`               // "admin", "moderator" or "member"
+	Status            string     `json:"status"`             // "active", or "pending" while a private group's admins decide
+	Username          *string    `json:"username,omitempty"` // from JOIN with users, for @mentions
+	NotificationLevel string     `json:"notification_level"` // "all", "mentions" or "muted"
+	JoinedAt          time.Time  `json:"joined_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	IsDeleted         bool       `json:"is_deleted"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
 }
 
 type GroupMessage struct {
@@ -46,14 +50,55 @@ type GroupMessage struct {
 	GroupID        uuid.UUID  `json:"group_id"`
 	UserID         uuid.UUID  `json:"user_id"`
 	SenderUsername *string    `json:"sender_username,omitempty"` // from JOIN with users, for display
-	MessageType    string     `json:"message_type"`             // "text", "location", "system"
+	MessageType    string     `json:"message_type" validate:"omitempty,oneof=text location_update eta_update report_share poll image location_pin report_pin announcement"`
 	Content        string     `json:"content"`
 	IsDeleted      bool       `json:"is_deleted"`
+	Attachment     *MessageAttachment `json:"attachment,omitempty"`
+	PinnedAt       *time.Time `json:"pinned_at,omitempty"`
+	PinnedBy       *uuid.UUID `json:"pinned_by,omitempty"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
 }
 
+// GroupNotificationSettings is the current user's push setting for one group.
+type GroupNotificationSettings struct {
+	GroupID           uuid.UUID `json:"group_id"`
+	NotificationLevel string    `json:"notification_level"`
+}
+
+type UpdateGroupNotificationsRequest struct {
+	NotificationLevel string `json:"notification_level" validate:"required,oneof=all mentions muted"`
+}
+
+// GroupMemberList is one page of a group's members or pending join requests.
+type GroupMemberList struct {
+	Members  []GroupMembership `json:"members"`
+	Total    int               `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+}
+
+// UpdateGroupMemberRequest changes a member's role; only group admins may send it.
+type UpdateGroupMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin moderator member"`
+}
+
+// GroupPresence lists which members are connected right now.
+type GroupPresence struct {
+	GroupID uuid.UUID        `json:"group_id"`
+	Online  int              `json:"online"`
+	Members []MemberPresence `json:"members"`
+}
+
+// MemberPresence is one member's live state; Navigating is only set for online members.
+type MemberPresence struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Username   *string   `json:"username,omitempty"`
+	Online     bool      `json:"online"`
+	Navigating bool      `json:"navigating"`
+}
+
 // GroupInvitation represents an invite to join a community group.
 type GroupInvitation struct {
 	ID             uuid.UUID  `json:"id"`
@@ -65,6 +110,7 @@ type GroupInvitation struct {
 	UpdatedAt      time.Time  `json:"updated_at"`
 	// Optional joined fields for list responses
 	GroupName      *string `json:"group_name,omitempty"`
+	GroupShortCode *string `json:"group_short_code,omitempty"` // for the join deep link
 	InvitedByName  *string `json:"invited_by_name,omitempty"`
 	InvitedUserEmail *string `json:"invited_user_email,omitempty"`
 }
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DestinationOverride routes trips to a destination to a curated arrival point.
+type DestinationOverride struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	PlaceID    *string    `json:"place_id,omitempty"`
+	Latitude   float64    `json:"latitude"`
+	Longitude  float64    `json:"longitude"`
+	RadiusM    int        `json:"radius_m"`
+	ArrivalLat float64    `json:"arrival_lat"`
+	ArrivalLng float64    `json:"arrival_lng"`
+	Kind       string     `json:"kind"` // entrance, parking or drop_off
+	Notes      *string    `json:"notes,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type CreateDestinationOverrideRequest struct {
+	Name       string  `json:"name" validate:"required,max=200"`
+	PlaceID    *string `json:"place_id,omitempty"`
+	Latitude   float64 `json:"latitude" validate:"latitude"`
+	Longitude  float64 `json:"longitude" validate:"longitude"`
+	RadiusM    int     `json:"radius_m,omitempty" validate:"omitempty,min=10,max=2000"`
+	ArrivalLat float64 `json:"arrival_lat" validate:"latitude"`
+	ArrivalLng float64 `json:"arrival_lng" validate:"longitude"`
+	Kind       string  `json:"kind,omitempty" validate:"omitempty,oneof=entrance parking drop_off"`
+	Notes      *string `json:"notes,omitempty" validate:"omitempty,max=500"`
+}
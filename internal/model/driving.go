@@ -0,0 +1,41 @@
+package model
+
+// Trimmed payloads served in driving mode (X-Response-Mode: driving or
+// ?response_mode=driving), sized for voice prompts and heads-up displays.
+
+// DrivingReport is a report reduced to what can be read or spoken at a glance.
+type DrivingReport struct {
+	ID        int64   `json:"id"`
+	Label     string  `json:"label"` // e.g. "Hidden police", "Heavy traffic"
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Severity  int     `json:"severity,omitempty"`
+	// DistanceM is set for route alerts: how far along the route the report is.
+	DistanceM *float64 `json:"distance_m,omitempty"`
+}
+
+// DrivingReports is a short list of reports, nearest or most relevant first.
+type DrivingReports struct {
+	Reports []DrivingReport `json:"reports"`
+	// More is how many reports were left out.
+	More int `json:"more,omitempty"`
+}
+
+// DrivingRoute is the primary route with its steps reduced to one short instruction each.
+type DrivingRoute struct {
+	DurationS    float64       `json:"duration_s"`
+	DistanceM    float64       `json:"distance_m"`
+	Summary      string        `json:"summary,omitempty"`
+	ETA          string        `json:"eta,omitempty"` // arrival window, e.g. "12-15 min"
+	Geometry     [][]float64   `json:"geometry"`
+	Steps        []DrivingStep `json:"steps"`
+	Alternatives int           `json:"alternatives,omitempty"`
+}
+
+type DrivingStep struct {
+	Instruction string  `json:"instruction"`     // banner text, or the maneuver instruction
+	Voice       string  `json:"voice,omitempty"` // first spoken announcement for the step
+	Type        string  `json:"type"`
+	Modifier    string  `json:"modifier,omitempty"`
+	DistanceM   float64 `json:"distance_m"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExpiryPolicy sets how long new reports of a type (and optionally subtype) stay live.
+type ExpiryPolicy struct {
+	ID         int64      `json:"id"`
+	ReportType string     `json:"report_type"` // report type or "*" for the fallback
+	Subtype    string     `json:"subtype"`     // report subtype or "*" for any
+	TTLMinutes int        `json:"ttl_minutes"`
+	UpdatedBy  *uuid.UUID `json:"updated_by,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type UpsertExpiryPolicyRequest struct {
+	ReportType string `json:"report_type" validate:"required"`
+	Subtype    string `json:"subtype,omitempty"`
+	TTLMinutes int    `json:"ttl_minutes" validate:"required,min=5,max=10080"`
+}
@@ -0,0 +1,36 @@
+package model
+
+// RFC 7946 GeoJSON, served instead of the JSON envelope when an endpoint is called with
+// ?format=geojson, so web maps can use the response as a source as is.
+
+type FeatureCollection struct {
+	Type     string    `json:"type"` // always "FeatureCollection"
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type string `json:"type"` // always "Feature"
+	// ID is the report ID or place gid, for MapLibre's feature state.
+	ID interface{} `json:"id,omitempty"`
+	// Geometry is null for features without a location, e.g. some autocomplete results.
+	Geometry   *Geometry   `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string      `json:"type"`        // "Point" or "LineString"
+	Coordinates interface{} `json:"coordinates"` // [lng, lat], or a list of them
+}
+
+// RouteFeatureProperties describe a route LineString.
+type RouteFeatureProperties struct {
+	Kind       string  `json:"kind"`        // "route", or "walking" for a park-and-walk final leg
+	RouteIndex int     `json:"route_index"` // position in the routes list; 0 is the primary route
+	DurationS  float64 `json:"duration_s"`
+	DistanceM  float64 `json:"distance_m"`
+	// AdjustedDurationS includes the delay expected from live reports on the route.
+	AdjustedDurationS float64 `json:"adjusted_duration_s,omitempty"`
+	Summary           string  `json:"summary,omitempty"`
+	ETA               string  `json:"eta,omitempty"`
+	Provider          string  `json:"provider,omitempty"`
+}
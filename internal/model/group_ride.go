@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupRide is a member's ride offer or request to the group's destination. Seats are
+// free seats on an offer, or seats needed on a request.
+type GroupRide struct {
+	ID             uuid.UUID `json:"id"`
+	GroupID        uuid.UUID `json:"group_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Username       *string   `json:"username,omitempty"`
+	Kind           string    `json:"kind"` // "offer" or "request"
+	OriginLat      float64   `json:"origin_latitude"`
+	OriginLng      float64   `json:"origin_longitude"`
+	OriginName     *string   `json:"origin_name,omitempty"`
+	DestinationLat float64   `json:"destination_latitude"`
+	DestinationLng float64   `json:"destination_longitude"`
+	DepartFrom     time.Time `json:"depart_from"`
+	DepartUntil    time.Time `json:"depart_until"`
+	Seats          int       `json:"seats"`
+	Note           *string   `json:"note,omitempty"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// GroupRideRequest offers or asks for a ride leaving between DepartFrom and DepartUntil.
+type GroupRideRequest struct {
+	Kind            string    `json:"kind" validate:"required,oneof=offer request"`
+	OriginLatitude  float64   `json:"origin_latitude" validate:"required,latitude"`
+	OriginLongitude float64   `json:"origin_longitude" validate:"required,longitude"`
+	OriginName      *string   `json:"origin_name,omitempty" validate:"omitempty,max=200"`
+	DepartFrom      time.Time `json:"depart_from" validate:"required"`
+	DepartUntil     time.Time `json:"depart_until" validate:"required"`
+	Seats           int       `json:"seats" validate:"required,min=1,max=8"`
+	Note            *string   `json:"note,omitempty" validate:"omitempty,max=500"`
+}
+
+// GroupRideMatch pairs one of the caller's rides with a ride from the other side.
+type GroupRideMatch struct {
+	Ride            GroupRide `json:"ride"` // the matching offer or request
+	PickupDistanceM float64   `json:"pickup_distance_m"`
+	MatchedAt       time.Time `json:"matched_at"`
+}
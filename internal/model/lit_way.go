@@ -0,0 +1,8 @@
+package model
+
+// LitWay is an OSM way with its lit tag, as imported into lit_ways.
+type LitWay struct {
+	OSMWayID    int64
+	Lit         string      // "yes" or "no"
+	Coordinates [][]float64 // [lng, lat] pairs
+}
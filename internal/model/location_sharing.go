@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UpdateLocationSharingRequest turns live location sharing in a group on or off.
+// SessionID links the share to the member's navigation session so its ETA follows the
+// route; DurationMinutes bounds how long sharing lasts (default 60, at most 480).
+type UpdateLocationSharingRequest struct {
+	Enabled         bool       `json:"enabled"`
+	SessionID       *uuid.UUID `json:"session_id,omitempty"`
+	DurationMinutes int        `json:"duration_minutes,omitempty" validate:"omitempty,min=1,max=480"`
+}
+
+// SharedLocationRequest is one position update from a member who is sharing.
+// ETASeconds overrides the estimate from the linked navigation session.
+type SharedLocationRequest struct {
+	Latitude   float64  `json:"latitude" validate:"latitude"`
+	Longitude  float64  `json:"longitude" validate:"longitude"`
+	Heading    *float64 `json:"heading,omitempty" validate:"omitempty,min=0,max=360"`
+	Speed      *float64 `json:"speed,omitempty" validate:"omitempty,min=0"` // m/s
+	ETASeconds *int     `json:"eta_seconds,omitempty" validate:"omitempty,min=0"`
+}
+
+// GroupLocationShare is a member's live location in a group. Latitude and Longitude are
+// nil until the first position update; Sharing is false on the final update sent when
+// a member stops.
+type GroupLocationShare struct {
+	GroupID         uuid.UUID  `json:"group_id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Username        *string    `json:"username,omitempty"`
+	SessionID       *uuid.UUID `json:"session_id,omitempty"`
+	Latitude        *float64   `json:"latitude,omitempty"`
+	Longitude       *float64   `json:"longitude,omitempty"`
+	Heading         *float64   `json:"heading,omitempty"`
+	Speed           *float64   `json:"speed,omitempty"`
+	ETA             *time.Time `json:"eta,omitempty"`
+	DestinationName *string    `json:"destination_name,omitempty"`
+	Sharing         bool       `json:"sharing"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	PositionAt      *time.Time `json:"position_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
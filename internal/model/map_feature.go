@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MapFeature is a fixed hazard on the map. Bearing is the direction of travel it applies
+// to (degrees from north); nil means both directions.
+type MapFeature struct {
+	ID            int64      `json:"id"`
+	Type          string     `json:"type"` // speed_camera, speed_bump, railway_crossing
+	Latitude      float64    `json:"latitude"`
+	Longitude     float64    `json:"longitude"`
+	Bearing       *float64   `json:"bearing,omitempty"`
+	SpeedLimitKmh *int       `json:"speed_limit_kmh,omitempty"`
+	Name          *string    `json:"name,omitempty"`
+	Notes         *string    `json:"notes,omitempty"`
+	Active        bool       `json:"active"`
+	CreatedBy     *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	// DistanceM is set on nearby lookups: how far the feature is from the query point.
+	DistanceM *float64 `json:"distance_m,omitempty"`
+}
+
+// MapFeatureRequest creates a map feature, or replaces one on PUT. Active defaults to
+// true; set it to false to keep a feature on file without warning drivers about it.
+type MapFeatureRequest struct {
+	Type          string   `json:"type" validate:"required,oneof=speed_camera speed_bump railway_crossing"`
+	Latitude      float64  `json:"latitude" validate:"latitude"`
+	Longitude     float64  `json:"longitude" validate:"longitude"`
+	Bearing       *float64 `json:"bearing,omitempty" validate:"omitempty,min=0,lt=360"`
+	SpeedLimitKmh *int     `json:"speed_limit_kmh,omitempty" validate:"omitempty,min=5,max=200"`
+	Name          *string  `json:"name,omitempty" validate:"omitempty,max=200"`
+	Notes         *string  `json:"notes,omitempty" validate:"omitempty,max=500"`
+	Active        *bool    `json:"active,omitempty"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MapIssue is a user-reported error in the base map, as opposed to a live hazard report.
+type MapIssue struct {
+	ID          int64      `json:"id"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	Category    string     `json:"category"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"` // open, accepted, rejected, fixed or exported
+	AdminNote   *string    `json:"admin_note,omitempty"`
+	ReviewedBy  *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	ExportedAt  *time.Time `json:"exported_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type CreateMapIssueRequest struct {
+	Category    string  `json:"category" validate:"required,oneof=missing_road wrong_one_way speed_limit turn_restriction road_name road_closed other"`
+	Latitude    float64 `json:"latitude" validate:"latitude"`
+	Longitude   float64 `json:"longitude" validate:"longitude"`
+	Description string  `json:"description" validate:"required,min=5,max=1000"`
+}
+
+type UpdateMapIssueStatusRequest struct {
+	Status    string  `json:"status" validate:"required,oneof=open accepted rejected fixed"`
+	AdminNote *string `json:"admin_note,omitempty" validate:"omitempty,max=500"`
+}
+
+type ExportMapIssuesRequest struct {
+	// IDs to export; empty exports every accepted issue not exported yet.
+	IDs []int64 `json:"ids,omitempty" validate:"omitempty,max=500"`
+}
+
+// OSMNote is a map issue rendered for the OpenStreetMap notes API
+// (POST /api/0.6/notes?lat=&lon=&text=).
+type OSMNote struct {
+	MapIssueID int64   `json:"map_issue_id"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+	Text       string  `json:"text"`
+}
+
+// MapIssueStatusChangedEvent is the outbox payload for map_issue.status_changed.
+type MapIssueStatusChangedEvent struct {
+	MapIssueID int64     `json:"map_issue_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Category   string    `json:"category"`
+	Status     string    `json:"status"`
+	AdminNote  *string   `json:"admin_note,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageAttachment is a structured payload on a group message. Kind says which of the
+// kind-specific fields is set. Fields marked "filled on read" are never stored; they are
+// expanded from live data each time messages are fetched.
+type MessageAttachment struct {
+	Kind     string              `json:"kind"` // location, eta or route
+	Location *LocationAttachment `json:"location,omitempty"`
+	ETA      *ETAAttachment      `json:"eta,omitempty"`
+	Route    *RouteAttachment    `json:"route,omitempty"`
+}
+
+// LocationAttachment is a point on the map: either one of the sender's saved locations
+// (by saved_location_id, resolved by the server) or a dropped pin.
+type LocationAttachment struct {
+	SavedLocationID *int64  `json:"saved_location_id,omitempty"`
+	Name            *string `json:"name,omitempty"`
+	Address         *string `json:"address,omitempty"`
+	PlaceID         *string `json:"place_id,omitempty"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+}
+
+// ETAAttachment shares the sender's arrival time for one of their active navigation sessions.
+type ETAAttachment struct {
+	SessionID       uuid.UUID `json:"session_id"`
+	ArriveAt        time.Time `json:"arrive_at"`
+	DestinationName *string   `json:"destination_name,omitempty"`
+	DestinationLat  *float64  `json:"destination_lat,omitempty"`
+	DestinationLng  *float64  `json:"destination_lng,omitempty"`
+	Live            *LiveETA  `json:"live,omitempty"` // filled on read
+}
+
+// LiveETA is the current state of a shared trip.
+type LiveETA struct {
+	Status             string     `json:"status"` // active, finished, cancelled
+	Latitude           *float64   `json:"latitude,omitempty"`
+	Longitude          *float64   `json:"longitude,omitempty"`
+	PositionAt         *time.Time `json:"position_at,omitempty"`
+	RemainingDistanceM *float64   `json:"remaining_distance_m,omitempty"` // straight line to the destination
+}
+
+// RouteAttachment shares the route of one of the sender's navigation sessions; the
+// session ID doubles as the share code.
+type RouteAttachment struct {
+	SessionID       uuid.UUID   `json:"session_id"`
+	RouteVersion    int         `json:"route_version"`
+	DestinationName *string     `json:"destination_name,omitempty"`
+	DestinationLat  *float64    `json:"destination_lat,omitempty"`
+	DestinationLng  *float64    `json:"destination_lng,omitempty"`
+	Geometry        [][]float64 `json:"geometry,omitempty"` // filled on read, [lng, lat] pairs
+	Rerouted        bool        `json:"rerouted,omitempty"` // filled on read: the sender rerouted after sharing
+}
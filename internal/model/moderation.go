@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImageModeration is one moderation verdict on an image attached to a report.
+type ImageModeration struct {
+	ID         int64             `json:"id"`
+	ReportID   *int64            `json:"report_id,omitempty"`
+	UserID     uuid.UUID         `json:"user_id"`
+	ImageURL   *string           `json:"image_url,omitempty"`
+	Provider   string            `json:"provider"`         // "cloud_vision" or "none" when moderation is unavailable
+	Decision   string            `json:"decision"`         // approved, held, rejected
+	Reason     *string           `json:"reason,omitempty"` // e.g. "adult:LIKELY"
+	Scores     map[string]string `json:"scores,omitempty"` // SafeSearch likelihood per category
+	ReviewedBy *uuid.UUID        `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+type ReviewImageModerationRequest struct {
+	Decision string `json:"decision" validate:"required,oneof=approved rejected"`
+}
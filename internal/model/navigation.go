@@ -0,0 +1,109 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NavigationSession struct {
+	ID              uuid.UUID  `json:"id"`
+	UserID          uuid.UUID  `json:"user_id"`
+	Status          string     `json:"status"` // active, finished, cancelled
+	CorridorWidthM  int        `json:"corridor_width_m"`
+	RouteVersion    int        `json:"route_version"`
+	DestinationLat  *float64   `json:"destination_lat,omitempty"`
+	DestinationLng  *float64   `json:"destination_lng,omitempty"`
+	DestinationName *string    `json:"destination_name,omitempty"`
+	Profile         string     `json:"profile"`
+	Preference      string     `json:"preference"`
+	RouteLengthM    float64    `json:"route_length_m"`
+	ProgressM       float64    `json:"progress_m"`
+	RemainingM      float64    `json:"remaining_m"`
+	OffRouteCount   int        `json:"off_route_count"`
+	RerouteCount    int        `json:"reroute_count"`
+	LastPositionAt  *time.Time `json:"last_position_at,omitempty"`
+	LastReroutedAt  *time.Time `json:"last_rerouted_at,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// StartNavigationRequest carries the route the app is about to follow.
+// Geometry is the GeoJSON LineString coordinates ([lng, lat] pairs) returned by /route.
+type StartNavigationRequest struct {
+	Geometry [][]float64 `json:"geometry" validate:"required,min=2,dive,len=2,lnglat"`
+	// CorridorWidthM is how far from the route reports are alerted on and a fix still
+	// counts as on route. It defaults to 100.
+	CorridorWidthM  int      `json:"corridor_width_m,omitempty"`
+	DestinationLat  *float64 `json:"destination_lat,omitempty"`
+	DestinationLng  *float64 `json:"destination_lng,omitempty"`
+	DestinationName *string  `json:"destination_name,omitempty"`
+	// RouteChoiceToken and RouteIndex tell which /route alternative was picked, so the
+	// user's future alternatives can be ranked by their habits.
+	RouteChoiceToken string `json:"route_choice_token,omitempty"`
+	RouteIndex       int    `json:"route_index,omitempty"`
+	// Profile and Preference are what the route was requested with; automatic reroutes
+	// reuse them. They default to driving and fastest.
+	Profile    string `json:"profile,omitempty"`
+	Preference string `json:"preference,omitempty"`
+}
+
+type RerouteRequest struct {
+	Geometry [][]float64 `json:"geometry" validate:"required,min=2,dive,len=2,lnglat"`
+}
+
+// UpdatePositionRequest is one GPS fix from the app while navigating.
+type UpdatePositionRequest struct {
+	Latitude  float64  `json:"latitude" validate:"latitude"`
+	Longitude float64  `json:"longitude" validate:"longitude"`
+	Heading   *float64 `json:"heading,omitempty"`    // degrees from north
+	AccuracyM *float64 `json:"accuracy_m,omitempty"` // horizontal accuracy reported by the device
+	// RouteVersion is the session route_version the app is following. When it is still
+	// current, an automatic reroute comes back as a RouteDiff against it.
+	RouteVersion *int `json:"route_version,omitempty"`
+}
+
+// NavigationProgress is the server's view of a session after a position update.
+// Route is only set when the update triggered an automatic reroute; the app should
+// switch to it, the session already has. RouteDiff is sent instead of Route when the
+// app's route_version was the one rerouted from.
+type NavigationProgress struct {
+	Session            NavigationSession `json:"session"`
+	DistanceFromRouteM float64           `json:"distance_from_route_m"`
+	OffRoute           bool              `json:"off_route"`
+	Rerouted           bool              `json:"rerouted"`
+	Route              *DrivingRoute     `json:"route,omitempty"`
+	RouteDiff          *RouteDiff        `json:"route_diff,omitempty"`
+}
+
+// RouteDiff is a reroute expressed against the route the app already has (BaseVersion).
+// The new geometry is Geometry followed by the old geometry from index KeepFromPoint, and
+// the new steps are Steps followed by the old steps from index KeepFromStep. A keep index
+// equal to the old length means nothing is kept.
+type RouteDiff struct {
+	BaseVersion   int           `json:"base_version"`
+	DurationS     float64       `json:"duration_s"`
+	DistanceM     float64       `json:"distance_m"`
+	Summary       string        `json:"summary,omitempty"`
+	ETA           string        `json:"eta,omitempty"`
+	Geometry      [][]float64   `json:"geometry"`
+	KeepFromPoint int           `json:"keep_from_point"`
+	Steps         []DrivingStep `json:"steps"`
+	KeepFromStep  int           `json:"keep_from_step"`
+}
+
+// RouteAlert is an active report that lies inside a session's route corridor.
+type RouteAlert struct {
+	Report
+	DistanceFromStartM float64 `json:"distance_from_start_m"`
+}
+
+// SegmentSpeedStats summarises historical device speeds (m/s) recorded along one slice of a route.
+type SegmentSpeedStats struct {
+	LengthM float64
+	Samples int
+	SlowMS  float64 // 15th percentile
+	TypMS   float64 // median
+	FastMS  float64 // 85th percentile
+}
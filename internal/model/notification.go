@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PushDevice is a registered push token, without the token itself.
+type PushDevice struct {
+	ID        uuid.UUID `json:"id"`
+	Platform  string    `json:"platform"` // android, ios or web
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" validate:"required,max=4096"`
+	Platform string `json:"platform" validate:"required,oneof=android ios web"`
+}
+
+// NotificationPreferences controls which nearby report alerts a user is pushed.
+// Empty ReportTypes means every type.
+type NotificationPreferences struct {
+	ReportAlerts bool       `json:"report_alerts"`
+	AlertRadiusM int        `json:"alert_radius_m"`
+	MinSeverity  int        `json:"min_severity"`
+	ReportTypes  []string   `json:"report_types"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	ReportAlerts bool     `json:"report_alerts"`
+	AlertRadiusM int      `json:"alert_radius_m" validate:"min=200,max=20000"`
+	MinSeverity  int      `json:"min_severity" validate:"min=3,max=5"`
+	ReportTypes  []string `json:"report_types" validate:"omitempty,max=6,dive,oneof=TRAFFIC POLICE ACCIDENT HAZARD ROAD_CLOSED PHOTOSHARING"`
+}
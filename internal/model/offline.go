@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// OfflineRegionManifest describes an offline region bundle: the area it covers, when it
+// was built and how many features each layer holds.
+type OfflineRegionManifest struct {
+	BBox           BoundingBox `json:"bbox"`
+	GeneratedAt    time.Time   `json:"generated_at"`
+	Reports        int         `json:"reports"`
+	SavedLocations int         `json:"saved_locations"`
+	POIs           int         `json:"pois"`
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OpsStatus is the runtime state exposed to admins at GET /admin/ops.
+type OpsStatus struct {
+	Database           string           `json:"database"` // "up", "read-only" or "down"
+	GeocodingPinned    string           `json:"geocoding_pinned,omitempty"`
+	GeocodingProviders []ProviderHealth `json:"geocoding_providers"`
+}
+
+// ProviderHealth is the rolling health of one upstream provider, in the order the
+// next request will try them.
+type ProviderHealth struct {
+	Provider     string     `json:"provider"`
+	Configured   bool       `json:"configured"`
+	Samples      int        `json:"samples"`
+	SuccessRate  float64    `json:"success_rate"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	Score        float64    `json:"score"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+}
+
+// ValhallaTileStatus reports how old the routing tiles served by Valhalla are.
+type ValhallaTileStatus struct {
+	Reachable         bool       `json:"reachable"`
+	Version           string     `json:"version,omitempty"`
+	TilesetBuiltAt    *time.Time `json:"tileset_built_at,omitempty"`
+	AgeDays           *float64   `json:"age_days,omitempty"`
+	MaxAgeDays        int        `json:"max_age_days"`
+	Stale             bool       `json:"stale"`
+	Error             string     `json:"error,omitempty"`
+	LastRebuildAt     *time.Time `json:"last_rebuild_requested_at,omitempty"`
+	WebhookConfigured bool       `json:"webhook_configured"` // rebuild requests are delivered via OUTBOX_WEBHOOK_URL
+}
+
+// TriggerValhallaRebuildRequest asks for a tile rebuild. Fresh tiles are only rebuilt with Force.
+type TriggerValhallaRebuildRequest struct {
+	Force  bool    `json:"force"`
+	Reason *string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+// ExplainGeoQueryRequest picks a geo query and the parameters to run it with. "nearby" is
+// GET /reports/nearby, "viewport" is GET /reports/bbox (one tile's query, run over the
+// whole box), "clusters" is GET /reports/clusters and "route_hazards" is the bounding-box
+// hazard lookup used to keep motorcycle routes off hazards.
+type ExplainGeoQueryRequest struct {
+	Query string `json:"query" validate:"required,oneof=nearby viewport clusters route_hazards"`
+
+	// nearby
+	Latitude  float64    `json:"latitude" validate:"latitude"`
+	Longitude float64    `json:"longitude" validate:"longitude"`
+	Radius    float64    `json:"radius,omitempty" validate:"omitempty,gt=0,max=100000"`
+	Types     []string   `json:"types,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	ViewerID  *uuid.UUID `json:"viewer_id,omitempty"`
+	Page      int        `json:"page,omitempty" validate:"omitempty,min=1"`
+	PageSize  int        `json:"page_size,omitempty" validate:"omitempty,min=1,max=500"`
+
+	// viewport, clusters and route_hazards; clusters also takes types and viewer_id
+	MinLat float64 `json:"min_lat" validate:"latitude"`
+	MinLng float64 `json:"min_lng" validate:"longitude"`
+	MaxLat float64 `json:"max_lat" validate:"latitude"`
+	MaxLng float64 `json:"max_lng" validate:"longitude"`
+	Zoom   int     `json:"zoom,omitempty"` // clusters
+
+	// route_hazards; limit also applies to viewport
+	Subtypes    []string `json:"subtypes,omitempty"`
+	MinSeverity int      `json:"min_severity,omitempty"`
+	Limit       int      `json:"limit,omitempty" validate:"omitempty,min=1,max=1000"`
+}
+
+// GeoQueryExplain is the EXPLAIN ANALYZE output for one run of a geo query.
+type GeoQueryExplain struct {
+	Query       string          `json:"query"`
+	SQL         string          `json:"sql"`
+	Args        []interface{}   `json:"args"`
+	PlanningMs  float64         `json:"planning_ms"`
+	ExecutionMs float64         `json:"execution_ms"`
+	TotalMs     float64         `json:"total_ms"` // wall clock, including the round trip
+	Plan        json.RawMessage `json:"plan"`     // EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON)
+}
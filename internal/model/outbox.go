@@ -0,0 +1,67 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a domain event waiting to be published to the WebSocket, push and webhook sinks.
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	EventType   string          `json:"event_type"`
+	AggregateID string          `json:"aggregate_id"`
+	DedupKey    string          `json:"dedup_key"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// ReportCreatedEvent is the outbox payload for report.created.
+type ReportCreatedEvent struct {
+	Report   CreateReportResponse `json:"report"`
+	Severity int                  `json:"severity"`
+}
+
+// ReportResolvedEvent is the outbox payload for report.resolved.
+type ReportResolvedEvent struct {
+	ReportID      int64     `json:"report_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Type          string    `json:"type"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Severity      int       `json:"severity"`
+	Reason        string    `json:"reason"` // "speeds_recovered"
+	RecentSpeedMS float64   `json:"recent_speed_ms"`
+	NormalSpeedMS float64   `json:"normal_speed_ms"`
+	ResolvedAt    time.Time `json:"resolved_at"`
+}
+
+// ReportExpiredEvent is the outbox payload for report.expired.
+type ReportExpiredEvent struct {
+	ReportID  int64     `json:"report_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Type      string    `json:"type"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Severity  int       `json:"severity"`
+	Reason    string    `json:"reason"` // "ttl", "disputed", "rejected" or "merged"
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// GroupMessageEvent is the outbox payload for group.message_created.
+type GroupMessageEvent struct {
+	Message GroupMessage `json:"message"`
+}
+
+// ValhallaRebuildRequestedEvent is the outbox payload for valhalla.rebuild_requested. It is
+// only delivered to the webhook, where the tile build pipeline picks it up.
+type ValhallaRebuildRequestedEvent struct {
+	Trigger        string     `json:"trigger"` // "manual" or "stale"
+	Reason         string     `json:"reason,omitempty"`
+	RequestedBy    *uuid.UUID `json:"requested_by,omitempty"`
+	Version        string     `json:"version,omitempty"`
+	TilesetBuiltAt *time.Time `json:"tileset_built_at,omitempty"`
+	AgeDays        *float64   `json:"age_days,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// Page is one page of a list endpoint. Offset-paged lists set Page; keyset-paged ones,
+// like group message history, leave it out. NextCursor fetches the following page when
+// sent back as ?cursor= and is empty on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	Page       int    `json:"page,omitempty"`
+	PageSize   int    `json:"page_size"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// Place is a geocoding result from /places search, reverse, autocomplete and details,
+// in the same shape whichever provider found it.
+type Place struct {
+	// GID identifies the place across providers: Pelias gids for Stadia,
+	// "google:place:<place_id>" for Google, "nominatim:<osm ref>" (e.g. N240109189) for
+	// Nominatim.
+	GID     string `json:"gid"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// Coordinates are [lng, lat]; autocomplete suggestions may have none.
+	Coordinates []float64 `json:"coordinates,omitempty"`
+	// Layer is the Pelias layer ("address", "street", "locality" or "venue").
+	Layer  string `json:"layer,omitempty"`
+	Source string `json:"source"` // "stadia", "google" or "nominatim"
+	// Names lists every name of a place known by several, keyed by language.
+	Names    map[string]string `json:"names,omitempty"`
+	Street   string            `json:"street,omitempty"`
+	Locality string            `json:"locality,omitempty"`
+	Region   string            `json:"region,omitempty"`
+
+	// Set only by /places/details, when the provider knows them.
+	Phone   string   `json:"phone,omitempty"`
+	Website string   `json:"website,omitempty"`
+	Hours   []string `json:"hours,omitempty"`
+	Rating  *float64 `json:"rating,omitempty"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlaceAlias lists the names one place goes by, per language.
+type PlaceAlias struct {
+	ID        int64             `json:"id"`
+	Key       string            `json:"key"`
+	Names     map[string]string `json:"names"` // language code -> name
+	GIDs      []string          `json:"gids"`
+	UpdatedBy *uuid.UUID        `json:"updated_by,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type UpsertPlaceAliasRequest struct {
+	Key   string            `json:"key" validate:"required,max=100"`
+	Names map[string]string `json:"names" validate:"required,min=2,dive,keys,len=2,endkeys,required,max=200"`
+	GIDs  []string          `json:"gids,omitempty" validate:"omitempty,max=20,dive,required,max=200"`
+}
@@ -0,0 +1,47 @@
+package model
+
+// POI is a place from the local points-of-interest table, trimmed to what list UIs show.
+type POI struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Category  string  `json:"category"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// RoutePOI is a POI near a route, with where along the route it is and how far off it.
+type RoutePOI struct {
+	POI
+	DistanceAlongM float64 `json:"distance_along_m"`
+	DetourM        float64 `json:"detour_m"`
+}
+
+// PrefetchPOIsRequest asks for POIs along a route. Geometry is the GeoJSON LineString
+// coordinates ([lng, lat] pairs) returned by /route.
+type PrefetchPOIsRequest struct {
+	Geometry [][]float64 `json:"geometry" validate:"required,min=2,max=10000,dive,len=2"`
+	// Categories defaults to fuel, pharmacy and restaurant.
+	Categories     []string `json:"categories,omitempty" validate:"omitempty,max=7,dive,oneof=fuel ev_charging pharmacy restaurant cafe atm parking"`
+	CorridorWidthM int      `json:"corridor_width_m,omitempty"`
+	// Limit caps the POIs returned per category.
+	Limit int `json:"limit,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+// RoutePOIs groups the POIs found along a route by category, each ordered by distance along it.
+type RoutePOIs struct {
+	CorridorWidthM int                   `json:"corridor_width_m"`
+	Categories     map[string][]RoutePOI `json:"categories"`
+}
+
+type UpsertPOIRequest struct {
+	Source    string  `json:"source" validate:"required,max=50"`
+	SourceID  string  `json:"source_id" validate:"required,max=200"`
+	Name      string  `json:"name" validate:"required,max=200"`
+	Category  string  `json:"category" validate:"required,oneof=fuel ev_charging pharmacy restaurant cafe atm parking"`
+	Latitude  float64 `json:"latitude" validate:"latitude"`
+	Longitude float64 `json:"longitude" validate:"longitude"`
+}
+
+type UpsertPOIsRequest struct {
+	POIs []UpsertPOIRequest `json:"pois" validate:"required,min=1,max=1000,dive"`
+}
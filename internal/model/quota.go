@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserQuota is a user's quota tier and today's usage of each quota class.
+type UserQuota struct {
+	UserID  uuid.UUID    `json:"user_id"`
+	Tier    string       `json:"tier"` // free, power or partner
+	ResetAt time.Time    `json:"reset_at"`
+	Usage   []QuotaUsage `json:"usage"`
+}
+
+type QuotaUsage struct {
+	Class     string `json:"class"` // directions, autocomplete or matrix
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+}
+
+type UpdateQuotaTierRequest struct {
+	Tier string `json:"tier" validate:"required,oneof=free power partner"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// RecentDestination is a place the user navigated to. Score ranks the list: visits count
+// for less the longer ago the last one was.
+type RecentDestination struct {
+	ID             int64     `json:"id"`
+	Name           *string   `json:"name,omitempty"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	VisitCount     int       `json:"visit_count"`
+	FirstVisitedAt time.Time `json:"first_visited_at"`
+	LastVisitedAt  time.Time `json:"last_visited_at"`
+	Score          float64   `json:"score"`
+}
+
+// RecentDestinationSettingsRequest turns destination history on or off. Turning it off
+// also clears the history.
+type RecentDestinationSettingsRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
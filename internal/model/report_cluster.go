@@ -0,0 +1,34 @@
+package model
+
+import "github.com/google/uuid"
+
+// ReportClusterParams filters GET /reports/clusters: active reports inside BBox, grouped
+// into geohash cells sized for the map's zoom level.
+type ReportClusterParams struct {
+	BBox      BoundingBox
+	Zoom      int
+	Precision int // geohash length of the cells, derived from Zoom
+	Types     []string
+	ViewerID  *uuid.UUID // hides reports the viewer dismissed or snoozed
+	Limit     int
+}
+
+// ReportCluster is the active reports in one geohash cell. ReportID is set when the
+// cluster holds a single report, so the client can draw it as a normal pin.
+type ReportCluster struct {
+	Geohash      string  `json:"geohash"`
+	Count        int     `json:"count"`
+	Latitude     float64 `json:"latitude"`  // centroid of the reports in the cell
+	Longitude    float64 `json:"longitude"` // centroid of the reports in the cell
+	DominantType string  `json:"dominant_type"`
+	ReportID     *int64  `json:"report_id,omitempty"`
+}
+
+// ReportClusters answers GET /reports/clusters, largest clusters first. Truncated is set
+// when the viewport had more clusters than were returned.
+type ReportClusters struct {
+	Zoom      int             `json:"zoom"`
+	Precision int             `json:"precision"`
+	Clusters  []ReportCluster `json:"clusters"`
+	Truncated bool            `json:"truncated"`
+}
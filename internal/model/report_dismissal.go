@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ReportDismissal hides a report from one user's nearby results, route alerts and
+// report pushes until it is snoozed out or the report changes materially.
+type ReportDismissal struct {
+	ReportID     int64      `json:"report_id"`
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"` // nil: dismissed until the report changes
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// DismissReportRequest dismisses a report, or snoozes it when SnoozeMinutes is set.
+type DismissReportRequest struct {
+	SnoozeMinutes int `json:"snooze_minutes,omitempty" validate:"omitempty,min=1,max=1440"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportExport is an async export of anonymized historical reports.
+type ReportExport struct {
+	ID          int64               `json:"id"`
+	RequestedBy *uuid.UUID          `json:"requested_by,omitempty"`
+	Format      string              `json:"format"` // csv or geojson
+	Filters     ReportExportFilters `json:"filters"`
+	Status      string              `json:"status"` // pending, running, done, failed
+	FileURL     *string             `json:"file_url,omitempty"`
+	RowCount    *int                `json:"row_count,omitempty"`
+	Error       *string             `json:"error,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+}
+
+// ReportExportFilters selects which reports go into an export.
+type ReportExportFilters struct {
+	Region *BoundingBox `json:"region,omitempty"`
+	Types  []string     `json:"types,omitempty"`
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+}
+
+// BoundingBox is a lat/lng rectangle.
+type BoundingBox struct {
+	MinLat float64 `json:"min_lat" validate:"min=-90,max=90"`
+	MinLng float64 `json:"min_lng" validate:"min=-180,max=180"`
+	MaxLat float64 `json:"max_lat" validate:"min=-90,max=90"`
+	MaxLng float64 `json:"max_lng" validate:"min=-180,max=180"`
+}
+
+type CreateReportExportRequest struct {
+	Format string       `json:"format" validate:"required,oneof=csv geojson"`
+	Region *BoundingBox `json:"region,omitempty"`
+	Types  []string     `json:"types,omitempty"`
+	From   time.Time    `json:"from" validate:"required"`
+	To     time.Time    `json:"to" validate:"required"`
+}
+
+// AnonymizedReport is one exported report: no user, free text or image, with the
+// position and time coarsened.
+type AnonymizedReport struct {
+	Type          string    `json:"type"`
+	Subtype       *string   `json:"subtype,omitempty"`
+	Severity      *int      `json:"severity,omitempty"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	CreatedHour   time.Time `json:"created_hour"`
+	Resolved      bool      `json:"resolved"`
+	ReportStatus  *string   `json:"report_status,omitempty"`
+	VerifiedCount int       `json:"verified_count"`
+	Upvotes       int       `json:"upvotes"`
+	Downvotes     int       `json:"downvotes"`
+}
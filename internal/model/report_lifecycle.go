@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// ConfirmReportRequest answers the app's "still there?" prompt for a report.
+type ConfirmReportRequest struct {
+	StillThere *bool `json:"still_there" validate:"required"`
+}
+
+// ReportConfirmation is a report's confirmation state after the user's answer.
+type ReportConfirmation struct {
+	ReportID           int64      `json:"report_id"`
+	StillThere         bool       `json:"still_there"`
+	ConfirmationsCount int        `json:"confirmations_count"`
+	NotThereCount      int        `json:"not_there_count"`
+	LastConfirmedAt    *time.Time `json:"last_confirmed_at,omitempty"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportModerationAction is one entry in a report's moderation audit trail.
+type ReportModerationAction struct {
+	ID          int64      `json:"id"`
+	ReportID    int64      `json:"report_id"`
+	ModeratorID *uuid.UUID `json:"moderator_id,omitempty"`
+	Action      string     `json:"action"` // approve, reject, merge
+	FromStatus  *string    `json:"from_status,omitempty"`
+	ToStatus    string     `json:"to_status"`
+	MergedInto  *int64     `json:"merged_into,omitempty"`
+	Reason      *string    `json:"reason,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type ModerateReportRequest struct {
+	Reason *string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
+
+type MergeReportRequest struct {
+	IntoReportID int64   `json:"into_report_id" validate:"required"`
+	Reason       *string `json:"reason,omitempty" validate:"omitempty,max=500"`
+}
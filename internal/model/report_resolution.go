@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportSpeedCheck compares recent ping speeds around an open report with the usual
+// speed there at this time of day.
+type ReportSpeedCheck struct {
+	ReportID         int64
+	UserID           uuid.UUID
+	Type             string
+	Latitude         float64
+	Longitude        float64
+	Severity         int
+	ReopenDetectedAt *time.Time
+	RecentSamples    int
+	RecentMedianMS   float64
+	UsualSamples     int
+	UsualMedianMS    float64
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportSearchParams filters GET /reports/search. Unlike the nearby query it includes
+// expired and inactive reports, so past incidents can be looked up.
+type ReportSearchParams struct {
+	From       time.Time
+	To         time.Time
+	Types      []string
+	Status     string
+	BBox       *BoundingBox
+	ReporterID *uuid.UUID // admin only
+	// After continues from the last report of the previous page (created_at, id).
+	After *ReportSearchCursor
+	Limit int
+}
+
+type ReportSearchCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// ReportSearchResult is one page of matches, oldest first. NextCursor is empty on the
+// last page.
+type ReportSearchResult struct {
+	Reports    []Report `json:"reports"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// ReportBBoxParams filters GET /reports/bbox: active reports inside a map viewport.
+type ReportBBoxParams struct {
+	BBox     BoundingBox
+	Types    []string
+	ViewerID *uuid.UUID // hides reports the viewer dismissed or snoozed
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportTimelineEvent is one entry in a report's activity feed. Kind is one of created,
+// confirmed, disputed, comment, severity_changed, status_changed, resolved or reopened.
+// Comments carry Comment, severity and status changes carry From/To, and Automatic marks
+// changes made by the auto-resolver.
+type ReportTimelineEvent struct {
+	Kind      string     `json:"kind"`
+	At        time.Time  `json:"at"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"`
+	Username  *string    `json:"username,omitempty"`
+	Comment   *string    `json:"comment,omitempty"`
+	From      *string    `json:"from,omitempty"`
+	To        *string    `json:"to,omitempty"`
+	Automatic bool       `json:"automatic,omitempty"`
+}
+
+// ReportTimeline is a report's activity, oldest first. Truncated is set when older events
+// were left out.
+type ReportTimeline struct {
+	ReportID  int64                 `json:"report_id"`
+	Events    []ReportTimelineEvent `json:"events"`
+	Truncated bool                  `json:"truncated"`
+}
@@ -11,7 +11,7 @@ type Report struct {
 	UserID         uuid.UUID `json:"user_id"`
 	Username       *string   `json:"username,omitempty"`
 	Type           string    `json:"type"`              // TRAFFIC, POLICE, ACCIDENT, HAZARD, ROAD_CLOSED, PHOTOSHARING
-	Subtype        *string   `json:"subtype,omitempty"` // LIGHT, HEAVY, STAND_STILL, VISIBLE, HIDDEN, OTHER_SIDE, MINOR, MAJOR
+	Subtype        *string   `json:"subtype,omitempty"` // LIGHT, HEAVY, STAND_STILL, VISIBLE, HIDDEN, OTHER_SIDE, MINOR, MAJOR, GRAVEL, OIL_SLICK
 	Latitude       float64   `json:"latitude"`
 	Longitude      float64   `json:"longitude"`
 	Description    *string   `json:"description,omitempty"`
@@ -28,20 +28,31 @@ type Report struct {
 	CommentsCount  int       `json:"comments_count,omitempty"`
 	UpvotesCount   int       `json:"upvotes_count,omitempty"`
 	DownvotesCount int       `json:"downvotes_count,omitempty"`
+	// UserVote is the caller's vote (UPVOTE or DOWNVOTE) where the endpoint looks it up;
+	// absent when they haven't voted.
+	UserVote *string `json:"user_vote,omitempty"`
+	// LocationLabel is picked from LocationLabels in the caller's language.
+	LocationLabel  *string           `json:"location_label,omitempty"`
+	LocationLabels map[string]string `json:"-"`
 }
 
 type CreateReportRequest struct {
 	UserID       uuid.UUID `json:"user_id"`
-	Type         string    `json:"type"`
+	Type         string    `json:"type" validate:"required,oneof=TRAFFIC POLICE ACCIDENT HAZARD ROAD_CLOSED PHOTOSHARING"`
 	Subtype      *string   `json:"subtype,omitempty"`
 	Longitude    float64   `json:"longitude"`
 	Latitude     float64   `json:"latitude"`
 	Description  *string   `json:"description,omitempty"`
-	Severity     *int      `json:"severity,omitempty"`
+	Severity     *int      `json:"severity,omitempty" validate:"omitempty,min=1,max=5"`
 	ExpiresAt    time.Time `json:"expires_at"`
 	ImageURL     *string   `json:"image_url,omitempty"`
 	ReportSource *string   `json:"report_source,omitempty"`
 	ReportStatus *string   `json:"report_status,omitempty"`
+	// ClosureSegment is the closed stretch of a ROAD_CLOSED report as [lng, lat] pairs,
+	// and ClosureDirection "BOTH" (default) or "ONE_WAY" when only traffic travelling in
+	// the order the segment is drawn in is blocked.
+	ClosureSegment   [][]float64 `json:"closure_segment,omitempty" validate:"omitempty,min=2,max=20,dive,len=2"`
+	ClosureDirection *string     `json:"closure_direction,omitempty" validate:"omitempty,oneof=BOTH ONE_WAY"`
 }
 
 type UpdateReportRequest struct {
@@ -80,14 +91,22 @@ type CreateReportResponse struct {
 	CommentsCount  int       `json:"comments_count,omitempty"`
 	UpvotesCount   int       `json:"upvotes_count,omitempty"`
 	DownvotesCount int       `json:"downvotes_count,omitempty"`
+	// ClosureSegment and ClosureDirection are set on ROAD_CLOSED reports that cover a
+	// stretch of road.
+	ClosureSegment   [][]float64 `json:"closure_segment,omitempty"`
+	ClosureDirection string      `json:"closure_direction,omitempty"`
+	// Duplicate is set when the report matched an existing one nearby; this is that report
+	// and no new one was created.
+	Duplicate bool `json:"duplicate,omitempty"`
 }
 
 type NearbyReportsParams struct {
 	Latitude  float64
 	Longitude float64
-	Radius    float64  // in meters
-	Types     []string // optional filter by report types
-	Status    string   // optional filter by status
+	Radius    float64    // in meters
+	Types     []string   // optional filter by report types
+	Status    string     // optional filter by status
+	ViewerID  *uuid.UUID // optional: hide reports this user has dismissed
 	Page      int
 	PageSize  int
 }
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// RouteAudit compares a served route with the same request re-run on the other provider.
+type RouteAudit struct {
+	ID                  int64      `json:"id"`
+	PrimaryProvider     string     `json:"primary_provider"` // valhalla or mapbox
+	AuditProvider       string     `json:"audit_provider"`
+	Profile             string     `json:"profile"`
+	Preference          string     `json:"preference"`
+	OriginLat           float64    `json:"origin_lat"`
+	OriginLng           float64    `json:"origin_lng"`
+	DestinationLat      float64    `json:"destination_lat"`
+	DestinationLng      float64    `json:"destination_lng"`
+	PrimaryDurationS    float64    `json:"primary_duration_s"`
+	PrimaryDistanceM    float64    `json:"primary_distance_m"`
+	AuditDurationS      *float64   `json:"audit_duration_s,omitempty"`
+	AuditDistanceM      *float64   `json:"audit_distance_m,omitempty"`
+	DurationDiffPct     *float64   `json:"duration_diff_pct,omitempty"`
+	DistanceDiffPct     *float64   `json:"distance_diff_pct,omitempty"`
+	GeometryDivergenceM *float64   `json:"geometry_divergence_m,omitempty"`
+	Flagged             bool       `json:"flagged"`
+	Error               *string    `json:"error,omitempty"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+}
+
+// RouteAuditSummary aggregates audits over a recent window.
+type RouteAuditSummary struct {
+	Since         time.Time         `json:"since"`
+	SamplePercent float64           `json:"sample_percent"`
+	Groups        []RouteAuditGroup `json:"groups"`
+	RecentFlagged []RouteAudit      `json:"recent_flagged"`
+}
+
+// RouteAuditGroup is the divergence for one provider pair and profile.
+type RouteAuditGroup struct {
+	PrimaryProvider        string  `json:"primary_provider"`
+	AuditProvider          string  `json:"audit_provider"`
+	Profile                string  `json:"profile"`
+	Preference             string  `json:"preference"`
+	Audits                 int     `json:"audits"`
+	Errors                 int     `json:"errors"`
+	Flagged                int     `json:"flagged"`
+	AvgAbsDurationDiffPct  float64 `json:"avg_abs_duration_diff_pct"`
+	AvgAbsDistanceDiffPct  float64 `json:"avg_abs_distance_diff_pct"`
+	P90GeometryDivergenceM float64 `json:"p90_geometry_divergence_m"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "github.com/google/uuid"
+
+// RouteFeatures summarises one route offered by /route, for ranking and for learning from
+// the user's pick.
+type RouteFeatures struct {
+	DurationS    float64 `json:"duration_s"`
+	DistanceM    float64 `json:"distance_m"`
+	HighwayShare float64 `json:"highway_share"` // share of distance on motorways
+	Maneuvers    int     `json:"maneuvers"`
+}
+
+// RouteChoiceStats are a user's learned route preferences; see the
+// user_route_choice_stats migration for the ranges.
+type RouteChoiceStats struct {
+	UserID               uuid.UUID
+	Choices              int
+	HighwayAvoidance     float64
+	SimplicityPreference float64
+	DetourTolerance      float64
+}
+
+// RouteChoiceSignal is what one navigation start says about the user's preferences. Nil
+// means the offered routes didn't differ enough on that axis to tell.
+type RouteChoiceSignal struct {
+	HighwayAvoidance     *float64
+	SimplicityPreference *float64
+	DetourShare          float64
+}
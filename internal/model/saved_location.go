@@ -14,15 +14,29 @@ type SavedLocation struct {
 	Address   *string      `json:"address"`
 	Location  pgtype.Point `json:"location"`
 	PlaceID   *string      `json:"place_id"`
+	Category  string       `json:"category"`
+	Icon      *string      `json:"icon,omitempty"`
+	SortOrder int          `json:"sort_order"`
 	CreatedAt time.Time    `json:"created_at"`
 }
 
+// LocationRequest creates or replaces a saved location. Category defaults to favorite;
+// saving a new home or work moves the old one back to favorites. Icon is an icon name or
+// emoji, required for custom places.
 type LocationRequest struct {
 	Name      string  `json:"name" validate:"required,min=1,max=50"`
 	Address   *string `json:"address"`
 	Latitude  float64 `json:"latitude" validate:"required,latitude"`
 	Longitude float64 `json:"longitude" validate:"required,longitude"`
 	PlaceID   *string `json:"place_id"`
+	Category  string  `json:"category,omitempty" validate:"omitempty,oneof=home work favorite custom"`
+	Icon      *string `json:"icon,omitempty" validate:"required_if=Category custom,omitempty,max=32"`
+}
+
+// ReorderSavedLocationsRequest lists saved location IDs in their new order. Locations
+// left out keep their relative order after the listed ones.
+type ReorderSavedLocationsRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1,max=500,unique,dive,gt=0"`
 }
 
 type SavedLocationResponse struct {
@@ -32,4 +46,7 @@ type SavedLocationResponse struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	PlaceID   *string `json:"place_id"`
+	Category  string  `json:"category"`
+	Icon      *string `json:"icon,omitempty"`
+	SortOrder int     `json:"sort_order"`
 }
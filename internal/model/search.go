@@ -0,0 +1,28 @@
+package model
+
+// Unified search result types on SearchResult.Type.
+const (
+	SearchResultSavedLocation     = "saved_location"
+	SearchResultRecentDestination = "recent_destination"
+	SearchResultGroup             = "group"
+)
+
+// SearchResult is one hit from /search. ID is the saved location's, recent destination's
+// or group's own ID, so the app can open it. Groups without a destination have no
+// coordinates.
+type SearchResult struct {
+	Type      string   `json:"type"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Subtitle  *string  `json:"subtitle,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Score (0-1) is how well the name matched, weighted by the result's type.
+	Score float64 `json:"score"`
+}
+
+// SearchResponse lists the results best first.
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
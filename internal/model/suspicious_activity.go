@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SuspiciousActivityFlag is a review-queue entry for an account whose location stream or
+// reports look fabricated.
+type SuspiciousActivityFlag struct {
+	ID          int64           `json:"id"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Reason      string          `json:"reason"` // teleport, impossible_heading, emulator
+	Source      string          `json:"source"` // location, report, login
+	Details     json.RawMessage `json:"details,omitempty"`
+	Occurrences int             `json:"occurrences"`
+	Status      string          `json:"status"` // pending, confirmed, dismissed
+	ReviewedBy  *uuid.UUID      `json:"reviewed_by,omitempty"`
+	ReviewedAt  *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastSeenAt  time.Time       `json:"last_seen_at"`
+}
+
+type ResolveSuspiciousActivityRequest struct {
+	Status string `json:"status" validate:"required,oneof=confirmed dismissed"`
+}
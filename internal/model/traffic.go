@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// TrafficSample is one GPS fix in a POST /traffic/samples batch.
+type TrafficSample struct {
+	Latitude   float64   `json:"latitude" validate:"latitude"`
+	Longitude  float64   `json:"longitude" validate:"longitude"`
+	Accuracy   *float64  `json:"accuracy,omitempty" validate:"omitempty,min=0"` // meters
+	RecordedAt time.Time `json:"recorded_at" validate:"required"`
+}
+
+// TrafficSamplesRequest is a batch of consecutive fixes from one drive, oldest first.
+type TrafficSamplesRequest struct {
+	Samples []TrafficSample `json:"samples" validate:"required,min=2,max=100,dive"`
+}
+
+// TrafficIngestResult says how much of a batch ended up in the traffic model.
+type TrafficIngestResult struct {
+	Samples  int `json:"samples"`
+	Matched  int `json:"matched"`
+	Segments int `json:"segments"`
+}
+
+// TrafficObservation is the speed driven between two consecutive map-matched fixes. Its
+// segment is keyed by road name, midpoint geohash and BearingBucket (0-7, 45° each).
+type TrafficObservation struct {
+	RoadName      string
+	BearingBucket int
+	From, To      []float64 // [lng, lat]
+	SpeedMS       float64
+}
+
+// TrafficSegment is a road segment's current speed for the traffic overlay.
+type TrafficSegment struct {
+	SegmentKey  string      `json:"segment_key"`
+	RoadName    string      `json:"road_name,omitempty"`
+	Coordinates [][]float64 `json:"coordinates"` // [lng, lat] pairs
+	SpeedKmh    float64     `json:"speed_kmh"`
+	FreeFlowKmh float64     `json:"free_flow_kmh"`
+	// Congestion is free, moderate, heavy or severe, from the speed against free flow.
+	Congestion string    `json:"congestion"`
+	Samples    int       `json:"samples"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
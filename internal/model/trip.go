@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trip is a finished or cancelled navigation session, as kept in the user's history.
+// Polyline is the route followed, encoded with precision 5.
+type Trip struct {
+	ID              uuid.UUID  `json:"id"`
+	SessionID       *uuid.UUID `json:"session_id,omitempty"`
+	Status          string     `json:"status"` // finished, cancelled
+	DistanceM       float64    `json:"distance_m"`
+	DurationS       int        `json:"duration_s"`
+	AvgSpeedKmh     float64    `json:"avg_speed_kmh"`
+	Polyline        string     `json:"polyline"`
+	DestinationLat  *float64   `json:"destination_lat,omitempty"`
+	DestinationLng  *float64   `json:"destination_lng,omitempty"`
+	DestinationName *string    `json:"destination_name,omitempty"`
+	Profile         string     `json:"profile"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      time.Time  `json:"finished_at"`
+}
+
+// TripList is one page of a user's trips, newest first.
+type TripList struct {
+	Trips    []Trip `json:"trips"`
+	Total    int    `json:"total"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+}
+
+// TripStatsBucket aggregates the trips started in one week or month. Start is the
+// first day of the period in local time, e.g. "2024-04-29".
+type TripStatsBucket struct {
+	Start       string  `json:"start,omitempty"`
+	Trips       int     `json:"trips"`
+	DistanceM   float64 `json:"distance_m"`
+	DurationS   int     `json:"duration_s"`
+	AvgSpeedKmh float64 `json:"avg_speed_kmh"`
+}
+
+// TripStats is a user's driving per week or month, most recent period first, with
+// totals over those periods.
+type TripStats struct {
+	Period  string            `json:"period"` // week, month
+	Buckets []TripStatsBucket `json:"buckets"`
+	Totals  TripStatsBucket   `json:"totals"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload is a report image uploaded ahead of creating the report; pass URL as the
+// report's image_url. A held image waits for review: the report only shows it once a
+// moderator approves it.
+type Upload struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int       `json:"size_bytes"`
+	Width        *int      `json:"width,omitempty"`
+	Height       *int      `json:"height,omitempty"`
+	Status       string    `json:"status"` // approved or held
+	CreatedAt    time.Time `json:"created_at"`
+}
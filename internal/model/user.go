@@ -26,13 +26,28 @@ type User struct {
 	LastName          *string   `json:"lastname,omitempty"`
 	Username          *string   `json:"username,omitempty"`
 	Email             string    `json:"email"`
+	Phone             *string   `json:"phone,omitempty"`
 	ProfileIcon       *string   `json:"profile_icon,omitempty"` // URL or asset filename (e.g. buddy_buggy.png)
 	IsDeleted         bool      `json:"is_deleted,omitempty"`
 	AuthProvider      string    `json:"auth_provider,omitempty"`
 	IsVerified        bool      `json:"is_verified"`
 	PreferredLanguage *string   `json:"preferred_language,omitempty"`
+	Role              string    `json:"role,omitempty"`             // user, moderator, admin
+	RoutePreference   string    `json:"route_preference,omitempty"` // fastest, safe
+	ReputationScore   int       `json:"reputation_score"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// DeactivatedAt is set while the account is waiting to be deleted.
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+	// RecentDestinationsEnabled is only filled in on the user's own profile.
+	RecentDestinationsEnabled *bool `json:"recent_destinations_enabled,omitempty"`
+}
+
+// AccountDeactivation is the answer to DELETE /user/account: the account is deleted at
+// PurgeAfter unless the user logs in again before then.
+type AccountDeactivation struct {
+	DeactivatedAt time.Time `json:"deactivated_at"`
+	PurgeAfter    time.Time `json:"purge_after"`
 }
 
 type ChangePasswordRequest struct {
@@ -43,3 +58,17 @@ type ChangePasswordRequest struct {
 type UpdateLanguageRequest struct {
 	Language string `json:"language" validate:"required"`
 }
+
+type UpdateRoutePreferenceRequest struct {
+	RoutePreference string `json:"route_preference" validate:"required,oneof=fastest safe"`
+}
+
+// LocationHeartbeatRequest is the periodic location ping sent by the app while driving.
+type LocationHeartbeatRequest struct {
+	Latitude   float64    `json:"latitude" validate:"latitude"`
+	Longitude  float64    `json:"longitude" validate:"longitude"`
+	Speed      *float64   `json:"speed,omitempty"`    // m/s
+	Heading    *float64   `json:"heading,omitempty"`  // degrees
+	Accuracy   *float64   `json:"accuracy,omitempty"` // meters
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+}
@@ -0,0 +1,92 @@
+// Package notifications sends push notifications through Firebase Cloud Messaging. FCM
+// also delivers to iOS devices through APNs, so one client covers every platform.
+package notifications
+
+import (
+	"context"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// fcmMaxTokens is the most tokens FCM accepts in one multicast request.
+const fcmMaxTokens = 500
+
+// Notification is a push message. CollapseKey makes a newer notification with the same
+// key replace an older one that is still on the device.
+type Notification struct {
+	Title       string
+	Body        string
+	Data        map[string]string
+	CollapseKey string
+	// HighPriority wakes the device immediately; use it only for time-sensitive alerts.
+	HighPriority bool
+}
+
+// Client sends notifications to device registration tokens. A Client built from a nil
+// messaging client is disabled and drops everything.
+type Client struct {
+	fcm *messaging.Client
+}
+
+func New(fcm *messaging.Client) *Client {
+	return &Client{fcm: fcm}
+}
+
+// Enabled reports whether notifications are actually sent.
+func (c *Client) Enabled() bool {
+	return c != nil && c.fcm != nil
+}
+
+// Send delivers n to tokens in batches. It returns the tokens FCM reported as no longer
+// registered, so callers can forget them; other per-token failures are only counted.
+func (c *Client) Send(ctx context.Context, tokens []string, n Notification) (stale []string, failed int, err error) {
+	if !c.Enabled() {
+		return nil, 0, nil
+	}
+	for start := 0; start < len(tokens); start += fcmMaxTokens {
+		end := min(start+fcmMaxTokens, len(tokens))
+		batch := tokens[start:end]
+		br, err := c.fcm.SendEachForMulticast(ctx, message(batch, n))
+		if err != nil {
+			return stale, failed, err
+		}
+		for i, resp := range br.Responses {
+			if resp.Success {
+				continue
+			}
+			if messaging.IsUnregistered(resp.Error) {
+				stale = append(stale, batch[i])
+				continue
+			}
+			failed++
+		}
+	}
+	return stale, failed, nil
+}
+
+func message(tokens []string, n Notification) *messaging.MulticastMessage {
+	msg := &messaging.MulticastMessage{
+		Tokens: tokens,
+		Notification: &messaging.Notification{
+			Title: n.Title,
+			Body:  n.Body,
+		},
+		Data: n.Data,
+	}
+	androidPriority, apnsPriority := "normal", "5"
+	if n.HighPriority {
+		androidPriority, apnsPriority = "high", "10"
+	}
+	msg.Android = &messaging.AndroidConfig{
+		Priority:    androidPriority,
+		CollapseKey: n.CollapseKey,
+	}
+	msg.APNS = &messaging.APNSConfig{
+		Headers: map[string]string{"apns-priority": apnsPriority},
+		Payload: &messaging.APNSPayload{Aps: &messaging.Aps{Sound: "default"}},
+	}
+	if n.CollapseKey != "" {
+		msg.APNS.Headers["apns-collapse-id"] = n.CollapseKey
+	}
+	return msg
+}
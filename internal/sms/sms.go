@@ -0,0 +1,77 @@
+// Package sms sends text messages, such as phone login codes, through an SMS provider.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bwise1/waze_kibris/internal/telemetry"
+)
+
+// Sender delivers a text message to a phone number in E.164 form (+35799123456).
+type Sender interface {
+	Send(ctx context.Context, to, body string) error
+}
+
+const twilioBaseURL = "https://api.twilio.com"
+
+// Twilio sends messages through the Twilio Messaging API.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string // sending number or messaging service SID
+	BaseURL    string
+	Client     *http.Client
+}
+
+// NewTwilio creates a Twilio sender.
+func NewTwilio(accountSID, authToken, from string) *Twilio {
+	return &Twilio{
+		AccountSID: accountSID,
+		AuthToken:  authToken,
+		From:       from,
+		BaseURL:    twilioBaseURL,
+		Client:     &http.Client{Timeout: 10 * time.Second, Transport: telemetry.Transport(nil)},
+	}
+}
+
+// Send creates a message. Twilio queues it, so a nil error means accepted, not delivered.
+func (t *Twilio) Send(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if strings.HasPrefix(t.From, "MG") {
+		form.Set("MessagingServiceSid", t.From)
+	} else {
+		form.Set("From", t.From)
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.BaseURL, url.PathEscape(t.AccountSID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("twilio returned %d: %s (code %d)", resp.StatusCode, apiErr.Message, apiErr.Code)
+		}
+		return fmt.Errorf("twilio returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package sms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTwilioSend(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	tw := NewTwilio("AC123", "secret", "+35722000000")
+	tw.BaseURL = srv.URL
+	if err := tw.Send(context.Background(), "+35799123456", "Your code is 123456"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+		t.Errorf("path = %s", got.URL.Path)
+	}
+	if user, pass, _ := got.BasicAuth(); user != "AC123" || pass != "secret" {
+		t.Errorf("basic auth = %s:%s", user, pass)
+	}
+	if got.PostForm.Get("To") != "+35799123456" || got.PostForm.Get("From") != "+35722000000" {
+		t.Errorf("form = %v", got.PostForm)
+	}
+}
+
+func TestTwilioSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code": 21211, "message": "The 'To' number is not a valid phone number."}`))
+	}))
+	defer srv.Close()
+
+	tw := NewTwilio("AC123", "secret", "MG456")
+	tw.BaseURL = srv.URL
+	err := tw.Send(context.Background(), "+1", "hi")
+	if err == nil || !strings.Contains(err.Error(), "21211") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package telemetry sets up OpenTelemetry tracing: spans for incoming requests, outgoing
+// provider calls and database queries, exported to an OTLP/HTTP collector.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/bwise1/waze_kibris"
+
+// Options configures tracing. An empty Endpoint leaves tracing off: spans are still
+// created against the global no-op provider, so instrumented code needs no checks.
+type Options struct {
+	// Endpoint is the collector's OTLP/HTTP base URL, e.g. http://otel-collector:4318.
+	Endpoint    string
+	ServiceName string
+	// SampleRatio is the share (0-1] of new traces recorded; traces started upstream
+	// follow the caller's decision. 0 records every trace.
+	SampleRatio float64
+}
+
+// Setup installs the global tracer provider and W3C trace-context propagation. The
+// returned function flushes buffered spans and must be called on shutdown.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(opts.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newExporter(ctx, opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	sampler := sdktrace.AlwaysSample()
+	if opts.SampleRatio > 0 && opts.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(opts.SampleRatio)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// newExporter returns an OTLP/HTTP exporter for the collector at endpoint (its base URL).
+// The exporter's own HTTP client isn't instrumented, so exporting creates no spans.
+func newExporter(ctx context.Context, endpoint string) (*otlptrace.Exporter, error) {
+	return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"))
+}
+
+// Transport wraps base (http.DefaultTransport if nil) so each outgoing request is a
+// client span under the caller's span and carries the trace context upstream. Requests
+// are also counted per host for TakeUsage.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(countingTransport{base: base})
+}
+
+// Handler starts a server span for each request to h and continues traces arriving with
+// a traceparent header. Requests to skipPaths (long-lived WebSocket upgrades) are not
+// traced.
+func Handler(h http.Handler, skipPaths ...string) http.Handler {
+	return otelhttp.NewHandler(h, "http.server", otelhttp.WithFilter(func(r *http.Request) bool {
+		return !slices.Contains(skipPaths, r.URL.Path)
+	}))
+}
+
+// NameServerSpan names the request's server span after its route pattern
+// ("GET /reports/{reportID}") once routing is done, and tags it with the request ID.
+func NameServerSpan(ctx context.Context, method, route, requestID string) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(attribute.String("request.id", requestID))
+	if route != "" {
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}
+}
@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExporterSendsOTLP(t *testing.T) {
+	var got coltracepb.ExportTraceServiceRequest
+	var path, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := proto.Unmarshal(body, &got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	exporter, err := newExporter(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := tp.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "GET /reports", trace.WithSpanKind(trace.SpanKindServer))
+	_, child := tracer.Start(ctx, "postgres SELECT", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int64("db.rows_affected", 3)))
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+
+	if path != "/v1/traces" || contentType != "application/x-protobuf" {
+		t.Fatalf("posted to %s as %s", path, contentType)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected grouping: %v", &got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want the ended child only", len(spans))
+	}
+	s := spans[0]
+	if trace.TraceID(s.TraceId) != parent.SpanContext().TraceID() || trace.SpanID(s.ParentSpanId) != parent.SpanContext().SpanID() {
+		t.Errorf("child not linked to parent: %v", s)
+	}
+	if s.Kind != tracepb.Span_SPAN_KIND_CLIENT || s.Status.Code != tracepb.Status_STATUS_CODE_ERROR || s.Status.Message != "boom" {
+		t.Errorf("kind %v status %v, want client span with error status", s.Kind, s.Status)
+	}
+}
+
+func TestQueryOperation(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT 1":                            "SELECT",
+		"\n\t  with recent AS (select 1) ...": "WITH",
+		"-- nearest report\ninsert into x":    "INSERT",
+		"   ":                                 "QUERY",
+	} {
+		if got := queryOperation(sql); got != want {
+			t.Errorf("queryOperation(%q) = %s, want %s", sql, got, want)
+		}
+	}
+}
+
+func TestTransportCountsUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	TakeUsage()
+	client := &http.Client{Transport: Transport(nil)}
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	got := TakeUsage()
+	if len(got) != 1 || got[0].Requests != 3 || got[0].Errors != 1 {
+		t.Fatalf("usage = %+v, want 3 requests with 1 error", got)
+	}
+	if again := TakeUsage(); len(again) != 0 {
+		t.Errorf("usage after take = %+v, want none", again)
+	}
+}
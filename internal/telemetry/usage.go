@@ -0,0 +1,61 @@
+package telemetry
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HostUsage counts the outgoing requests made through Transport to one upstream host.
+// Errors are requests that failed to complete or got a 4xx/5xx response.
+type HostUsage struct {
+	Host     string
+	Requests int64
+	Errors   int64
+}
+
+// usage accumulates HostUsage until TakeUsage collects it.
+var usage = struct {
+	mu    sync.Mutex
+	hosts map[string]*HostUsage
+}{hosts: make(map[string]*HostUsage)}
+
+func recordUsage(host string, failed bool) {
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+	u, ok := usage.hosts[host]
+	if !ok {
+		u = &HostUsage{Host: host}
+		usage.hosts[host] = u
+	}
+	u.Requests++
+	if failed {
+		u.Errors++
+	}
+}
+
+// TakeUsage returns the requests counted since the last call, by host, and starts over.
+func TakeUsage() []HostUsage {
+	usage.mu.Lock()
+	hosts := usage.hosts
+	usage.hosts = make(map[string]*HostUsage)
+	usage.mu.Unlock()
+
+	out := make([]HostUsage, 0, len(hosts))
+	for _, u := range hosts {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// countingTransport records every request it sends in usage.
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	recordUsage(req.URL.Host, err != nil || resp.StatusCode >= 400)
+	return resp, err
+}
@@ -39,6 +39,10 @@ func StatusCode(status string) int {
 		return http.StatusUnauthorized
 	case values.ActiveLogin:
 		return http.StatusForbidden
+	case values.Unavailable:
+		return http.StatusServiceUnavailable
+	case values.TooManyRequests:
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusOK
 	}
@@ -103,6 +107,16 @@ func GetUserIDFromContext(ctx context.Context) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// GetUserRoleFromContext returns the role RequireLogin stored in the context,
+// defaulting to the regular user role.
+func GetUserRoleFromContext(ctx context.Context) string {
+	role, ok := ctx.Value("user_role").(string)
+	if !ok || role == "" {
+		return values.RoleUser
+	}
+	return role
+}
+
 // string to UUID
 func StringToUUID(s string) (uuid.UUID, error) {
 	return uuid.Parse(s)
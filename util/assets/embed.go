@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//go:embed "emails"
+//go:embed "emails" "pages"
 var EmbeddedFiles embed.FS
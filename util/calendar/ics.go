@@ -0,0 +1,164 @@
+// Package calendar reads the parts of iCalendar (RFC 5545) feeds needed to plan drives:
+// event start, title, location and optional GEO position.
+package calendar
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one VEVENT. Recurring events are returned once, at their first DTSTART.
+type Event struct {
+	UID      string
+	Summary  string
+	Location string
+	Start    time.Time
+	AllDay   bool
+	// Lat/Lng come from the GEO property when the feed has one.
+	Lat, Lng  *float64
+	Cancelled bool
+}
+
+// Parse reads the VEVENTs from an iCalendar feed. Events without a UID or DTSTART are
+// skipped. Times without a zone are read in the feed's X-WR-TIMEZONE, else in local.
+func Parse(r io.Reader, local *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var cur *Event
+	depth := 0 // nesting inside the current VEVENT (VALARM etc.)
+	for _, line := range lines {
+		name, params, value := splitProperty(line)
+		if name == "X-WR-TIMEZONE" && cur == nil {
+			if l, err := time.LoadLocation(value); err == nil {
+				local = l
+			}
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			cur, depth = &Event{}, 0
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur.UID != "" && !cur.Start.IsZero() {
+				events = append(events, *cur)
+			}
+			cur = nil
+			continue
+		case name == "END":
+			depth--
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.UID = value
+		case "SUMMARY":
+			cur.Summary = unescape(value)
+		case "LOCATION":
+			cur.Location = unescape(value)
+		case "STATUS":
+			cur.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case "GEO":
+			lat, lng, ok := strings.Cut(value, ";")
+			if !ok {
+				continue
+			}
+			la, err1 := strconv.ParseFloat(lat, 64)
+			ln, err2 := strconv.ParseFloat(lng, 64)
+			if err1 == nil && err2 == nil {
+				cur.Lat, cur.Lng = &la, &ln
+			}
+		case "DTSTART":
+			start, allDay, err := parseTime(value, params, local)
+			if err == nil {
+				cur.Start, cur.AllDay = start, allDay
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfold joins continuation lines (those starting with a space or tab) onto the previous line.
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+// splitProperty splits "NAME;PARAM=X;PARAM2=Y:value" into its parts. Parameter names are
+// upper-cased; quoted parameter values may contain ':' and ';'.
+func splitProperty(line string) (string, map[string]string, string) {
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+	head, value := line[:colon], line[colon+1:]
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+// parseTime handles UTC ("...Z"), floating or TZID local times, and VALUE=DATE all-day
+// dates. TZIDs Go doesn't know (e.g. Windows zone names) fall back to local.
+func parseTime(value string, params map[string]string, local *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.UTC)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var unescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescape(s string) string {
+	return strings.TrimSpace(unescaper.Replace(s))
+}
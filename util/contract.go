@@ -0,0 +1,58 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RequireJSONFields checks that body has a non-null value at each path. Paths are
+// dot-separated keys, where "key[]" steps into every element of an array, e.g.
+// "routes[].legs[].duration". Provider clients use it in strict mode so a response
+// missing a field the app depends on fails loudly instead of decoding to zero values.
+func RequireJSONFields(body []byte, paths ...string) error {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := requireJSONPath(doc, strings.Split(path, "."), ""); err != nil {
+			return fmt.Errorf("response schema changed: %w", err)
+		}
+	}
+	return nil
+}
+
+func requireJSONPath(v interface{}, keys []string, at string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	key, each := strings.CutSuffix(keys[0], "[]")
+	here := key
+	if at != "" {
+		here = at + "." + key
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not an object", at)
+	}
+	child, ok := obj[key]
+	if !ok || child == nil {
+		return fmt.Errorf("missing %s", here)
+	}
+	if !each {
+		return requireJSONPath(child, keys[1:], here)
+	}
+
+	items, ok := child.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not an array", here)
+	}
+	for i, item := range items {
+		if err := requireJSONPath(item, keys[1:], fmt.Sprintf("%s[%d]", here, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
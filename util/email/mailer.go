@@ -5,7 +5,6 @@ import (
 	"crypto/tls"
 	"fmt"
 	"html/template"
-	"log"
 	"net/smtp"
 
 	"github.com/bwise1/waze_kibris/util"
@@ -31,7 +30,6 @@ func NewMailer(host string, port int, username, password, from string) *Mailer {
 }
 
 func (m *Mailer) Send(recipient string, data interface{}, patterns ...string) error {
-	log.Println("Here", m)
 	for i := range patterns {
 		patterns[i] = "emails/" + patterns[i]
 
@@ -42,9 +40,7 @@ func (m *Mailer) Send(recipient string, data interface{}, patterns ...string) er
 
 	// Establish an SMTP connection and send the email
 	auth := smtp.PlainAuth("", m.smtpUser, m.smtpPassword, m.smtpHost)
-	err := sendEmail(m.smtpHost, m.smtpPort, auth, m.smtpFrom, recipient, msg)
-	log.Println(err)
-	return err
+	return sendEmail(m.smtpHost, m.smtpPort, auth, m.smtpFrom, recipient, msg)
 }
 
 func composeEmail(recipient, sender string, patterns []string, data interface{}) []byte {
@@ -180,3 +176,20 @@ func sendEmail(smtpHost, smtpPort string, auth smtp.Auth, from, recipient string
 
 	return fmt.Errorf("failed to send email after 3 attempts")
 }
+
+// Verify connects to the SMTP server and logs in without sending anything.
+func (m *Mailer) Verify() error {
+	client, err := smtp.Dial(m.smtpHost + ":" + m.smtpPort)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		return err
+	}
+	if err := client.Auth(smtp.PlainAuth("", m.smtpUser, m.smtpPassword, m.smtpHost)); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"errors"
+	"math"
+)
+
+const earthRadiusMeters = 6371000
+
+// coordinateDecimals is the precision coordinates are stored at (~0.1 m).
+const coordinateDecimals = 6
+
+var (
+	ErrCoordinateOutOfRange = errors.New("latitude must be within ±90 and longitude within ±180")
+	// (0,0) is what apps send when they have no fix yet, never a real position for us.
+	ErrNullIslandCoordinate = errors.New("coordinate is (0,0)")
+)
+
+// HaversineMeters returns the great-circle distance in meters between two points.
+func HaversineMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	lat1Rad := lat1 * math.Pi / 180
+	lat2Rad := lat2 * math.Pi / 180
+	deltaLat := (lat2 - lat1) * math.Pi / 180
+	deltaLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return earthRadiusMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// HeadingDelta returns the smallest absolute difference between two compass headings (0-180).
+func HeadingDelta(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// NormalizeCoordinate validates a lat/lon pair from client input and returns it cleaned
+// up: a pair that only makes sense transposed (|lat| > 90, |lon| <= 90) is swapped back,
+// and both values are rounded to 6 decimals. (0,0) and anything out of range is rejected.
+func NormalizeCoordinate(lat, lon float64) (float64, float64, error) {
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.IsInf(lat, 0) || math.IsInf(lon, 0) {
+		return 0, 0, ErrCoordinateOutOfRange
+	}
+	if math.Abs(lat) > 90 && math.Abs(lon) <= 90 {
+		lat, lon = lon, lat
+	}
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return 0, 0, ErrCoordinateOutOfRange
+	}
+	if lat == 0 && lon == 0 {
+		return 0, 0, ErrNullIslandCoordinate
+	}
+	scale := math.Pow10(coordinateDecimals)
+	return math.Round(lat*scale) / scale, math.Round(lon*scale) / scale, nil
+}
@@ -0,0 +1,80 @@
+// Package logging builds the service's structured logger. Records logged with a request
+// context carry that request's ID, trace, signed-in user and route without the caller
+// adding them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New returns a logger writing to w at level ("debug", "info", "warn" or "error") in
+// format ("json" or "text"). Empty values mean info and json.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("log level %q: want debug, info, warn or error", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("log format %q: want json or text", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// contextHandler adds request_id, trace_id, user_id and route from the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(RequestAttrs(ctx)...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// RequestAttrs returns the request fields found in ctx; outside a request there are none.
+func RequestAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if tc, ok := ctx.Value(values.ContextTracingKey).(tracing.Context); ok && tc.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", tc.RequestID))
+	}
+	if userID, ok := ctx.Value("user_id").(string); ok && userID != "" {
+		attrs = append(attrs, slog.String("user_id", userID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+	if rc := chi.RouteContext(ctx); rc != nil {
+		if route := rc.RoutePattern(); route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/bwise1/waze_kibris/util/tracing"
+	"github.com/bwise1/waze_kibris/util/values"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := chi.NewRouteContext()
+	rc.RoutePatterns = []string{"/reports/*", "/{reportID}/vote"}
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rc)
+	ctx = context.WithValue(ctx, values.ContextTracingKey, tracing.Context{RequestID: "req-1"})
+	ctx = context.WithValue(ctx, "user_id", "user-1")
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2},
+	}))
+
+	logger.With("component", "test").InfoContext(ctx, "voted", "report_id", 7)
+	logger.DebugContext(ctx, "filtered out")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("want exactly one JSON record, got %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]any{
+		"msg": "voted", "request_id": "req-1", "user_id": "user-1", "trace_id": trace.TraceID{1}.String(),
+		"route": "/reports/{reportID}/vote", "component": "test", "report_id": float64(7),
+	} {
+		if rec[key] != want {
+			t.Errorf("%s = %v, want %v", key, rec[key], want)
+		}
+	}
+}
+
+func TestNoRequestFieldsOutsideRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "", "")
+	logger.Info("tick")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"request_id", "trace_id", "user_id", "route"} {
+		if _, ok := rec[key]; ok {
+			t.Errorf("unexpected %s outside a request", key)
+		}
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", ""); err == nil {
+		t.Error("unknown level accepted")
+	}
+	if _, err := New(&bytes.Buffer{}, "", "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, err := New(&bytes.Buffer{}, "WARN", "Text"); err != nil {
+		t.Errorf("case-insensitive settings rejected: %v", err)
+	}
+}
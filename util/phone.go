@@ -0,0 +1,72 @@
+package util
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// DefaultPhoneCountryCode is assumed for numbers entered without one: the app serves
+// Cyprus, where local numbers are eight digits.
+const DefaultPhoneCountryCode = "357"
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone turns a phone number as typed into E.164 (+35799123456). Spaces,
+// dashes, dots and parentheses are dropped, a leading 00 is read as +, and a number
+// without a country code gets DefaultPhoneCountryCode.
+func NormalizePhone(raw string) (string, error) {
+	s := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+
+	switch {
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	case strings.HasPrefix(s, "00"):
+		s = s[2:]
+	default:
+		s = DefaultPhoneCountryCode + s
+	}
+	if len(s) < 8 || len(s) > 15 || s[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidPhone
+		}
+	}
+	return "+" + s, nil
+}
+
+// MaskPhone hides all but the last three digits of a phone number, for logs.
+func MaskPhone(phone string) string {
+	const shown = 3
+	masked := []byte(phone)
+	hidden := len(masked) - shown
+	for i := 0; i < hidden; i++ {
+		if masked[i] >= '0' && masked[i] <= '9' {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
+
+// GenerateNumericCode returns a random code of n digits from a cryptographic source,
+// for one-time codes sent where guessing is the main attack.
+func GenerateNumericCode(n int) (string, error) {
+	code := make([]byte, n)
+	for i := range code {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code[i] = byte('0' + d.Int64())
+	}
+	return string(code), nil
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"strings"
+	"time"
+)
+
+// Reasons used when flagging an account for review.
+const (
+	SuspicionTeleport          = "teleport"
+	SuspicionImpossibleHeading = "impossible_heading"
+	SuspicionEmulator          = "emulator"
+)
+
+const (
+	// maxPlausibleSpeed is ~250 km/h; nothing legal on Cyprus roads moves faster.
+	maxPlausibleSpeed = 70.0 // m/s
+	// minTeleportDistance ignores GPS jitter and cold-start fixes.
+	minTeleportDistance = 500.0 // meters
+	// A car above headingCheckSpeed cannot swing more than maxHeadingChange within headingCheckWindow.
+	headingCheckSpeed  = 15.0 // m/s
+	maxHeadingChange   = 120.0
+	headingCheckWindow = 2 * time.Second
+)
+
+// LocationSample is a single point in a user's location stream.
+type LocationSample struct {
+	Latitude  float64
+	Longitude float64
+	Speed     *float64
+	Heading   *float64
+	At        time.Time
+}
+
+// CheckLocationPlausibility compares two consecutive samples and returns the
+// reasons (if any) the transition looks physically impossible.
+func CheckLocationPlausibility(prev, curr LocationSample) []string {
+	var reasons []string
+
+	elapsed := curr.At.Sub(prev.At).Seconds()
+	if elapsed <= 0 {
+		elapsed = 1
+	}
+
+	distance := HaversineMeters(prev.Latitude, prev.Longitude, curr.Latitude, curr.Longitude)
+	if distance >= minTeleportDistance && distance/elapsed > maxPlausibleSpeed {
+		reasons = append(reasons, SuspicionTeleport)
+	}
+
+	if prev.Heading != nil && curr.Heading != nil && curr.Speed != nil &&
+		*curr.Speed >= headingCheckSpeed &&
+		curr.At.Sub(prev.At) <= headingCheckWindow &&
+		HeadingDelta(*prev.Heading, *curr.Heading) > maxHeadingChange {
+		reasons = append(reasons, SuspicionImpossibleHeading)
+	}
+
+	return reasons
+}
+
+// emulatorModelMarkers are substrings of device model names reported by common
+// Android emulators and the iOS simulator.
+var emulatorModelMarkers = []string{
+	"sdk_gphone",
+	"android sdk built for",
+	"emulator",
+	"simulator",
+	"genymotion",
+	"goldfish",
+	"ranchu",
+	"vbox86",
+}
+
+// IsEmulatorFingerprint reports whether the device model looks like an emulator.
+func IsEmulatorFingerprint(deviceModel string) bool {
+	m := strings.ToLower(deviceModel)
+	if m == "" {
+		return false
+	}
+	for _, marker := range emulatorModelMarkers {
+		if strings.Contains(m, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+// Package presence shares WebSocket presence between API instances through Redis.
+package presence
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix   = "presence:user:"
+	dialTimeout = 3 * time.Second
+	// Used when the caller's context has no deadline.
+	defaultTimeout = 2 * time.Second
+)
+
+// deleteIfOwner removes a presence key only if this instance wrote it, so a user who has
+// already reconnected to another instance isn't marked offline.
+var deleteIfOwner = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// RedisStore implements websockets.PresenceStore. Each online user is a key holding the
+// instance they are connected to, with a TTL that the instance keeps refreshing.
+type RedisStore struct {
+	client   *redis.Client
+	instance string
+}
+
+// NewRedisStore parses a redis:// or rediss:// (TLS) URL, such as
+// redis://[:password@]host:port[/db], and checks the server answers.
+func NewRedisStore(ctx context.Context, rawURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redis URL: %w", err)
+	}
+	opts.DialTimeout = dialTimeout
+	opts.ContextTimeoutEnabled = true
+
+	host, _ := os.Hostname()
+	s := &RedisStore{
+		client:   redis.NewClient(opts),
+		instance: fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		s.client.Close()
+		return nil, fmt.Errorf("connecting to redis: %w", err)
+	}
+	return s, nil
+}
+
+// SetOnline marks users as connected to this instance for ttl.
+func (s *RedisStore) SetOnline(ctx context.Context, userIDs []string, ttl time.Duration) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range userIDs {
+			pipe.Set(ctx, keyPrefix+id, s.instance, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// SetOffline removes a user's presence if this instance owns it.
+func (s *RedisStore) SetOffline(ctx context.Context, userID string) error {
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	return deleteIfOwner.Run(ctx, s.client, []string{keyPrefix + userID}, s.instance).Err()
+}
+
+// Online reports which of userIDs are connected to any instance.
+func (s *RedisStore) Online(ctx context.Context, userIDs []string) (map[string]bool, error) {
+	online := make(map[string]bool, len(userIDs))
+	if len(userIDs) == 0 {
+		return online, nil
+	}
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = keyPrefix + id
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if v != nil && i < len(userIDs) {
+			online[userIDs[i]] = true
+		}
+	}
+	return online, nil
+}
+
+// Close releases the store's connections.
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, defaultTimeout)
+}
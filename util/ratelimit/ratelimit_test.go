@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreBurstAndRefill(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	limit := PerMinute(60, 3) // one token a second
+	now := time.Unix(1700000000, 0)
+
+	for i := 2; i >= 0; i-- {
+		res, _ := s.Take(ctx, "k", limit, now)
+		if !res.Allowed || res.Remaining != i {
+			t.Fatalf("take within burst = %+v, want allowed with %d remaining", res, i)
+		}
+	}
+	res, _ := s.Take(ctx, "k", limit, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Fatalf("take past burst = %+v, want denied with 1s retry", res)
+	}
+
+	res, _ = s.Take(ctx, "k", limit, now.Add(500*time.Millisecond))
+	if res.Allowed || res.RetryAfter != 500*time.Millisecond {
+		t.Fatalf("take after half a token = %+v, want denied with 500ms retry", res)
+	}
+	res, _ = s.Take(ctx, "k", limit, now.Add(time.Second))
+	if !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("take after refill = %+v, want allowed with 0 remaining", res)
+	}
+
+	// Long idle periods refill to the burst, not beyond.
+	later := now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if res, _ := s.Take(ctx, "k", limit, later); !res.Allowed {
+			t.Fatalf("take %d after idle hour denied", i)
+		}
+	}
+	if res, _ := s.Take(ctx, "k", limit, later); res.Allowed {
+		t.Fatal("bucket refilled past its burst")
+	}
+}
+
+func TestMemoryStoreKeysAndDisabledLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	limit := PerMinute(1, 1)
+
+	if res, _ := s.Take(ctx, "a", limit, now); !res.Allowed {
+		t.Fatal("first take for a denied")
+	}
+	if res, _ := s.Take(ctx, "b", limit, now); !res.Allowed {
+		t.Fatal("b shares a's bucket")
+	}
+	if res, _ := s.Take(ctx, "a", limit, now); res.Allowed {
+		t.Fatal("second take for a allowed")
+	}
+
+	for i := 0; i < 100; i++ {
+		if res, _ := s.Take(ctx, "c", Limit{}, now); !res.Allowed {
+			t.Fatal("disabled limit denied a request")
+		}
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	now := time.Unix(1700000000, 0)
+	s.Take(ctx, "idle", PerMinute(60, 2), now)
+	s.Take(ctx, "busy", PerMinute(0.5, 2), now) // full again after two minutes
+
+	s.Take(ctx, "other", PerMinute(60, 2), now.Add(sweepInterval))
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("refilled bucket not swept")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
@@ -0,0 +1,58 @@
+package util
+
+import "math"
+
+// SimplifyLine reduces a [lng, lat] line with Douglas-Peucker, dropping points that lie
+// within toleranceM meters of the simplified line. The first and last points are always
+// kept. A non-positive tolerance returns the line unchanged.
+func SimplifyLine(line [][]float64, toleranceM float64) [][]float64 {
+	if toleranceM <= 0 || len(line) < 3 {
+		return line
+	}
+	keep := make([]bool, len(line))
+	keep[0], keep[len(line)-1] = true, true
+
+	// Work through spans with an explicit stack; long routes have tens of thousands of
+	// points and recursion depth would follow the shape.
+	stack := [][2]int{{0, len(line) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+		farthest, farthestM := -1, toleranceM
+		for i := first + 1; i < last; i++ {
+			if d := SegmentDistanceMeters(line[i], line[first], line[last]); d > farthestM {
+				farthest, farthestM = i, d
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+		keep[farthest] = true
+		stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+	}
+
+	simplified := make([][]float64, 0, len(line))
+	for i, p := range line {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// SegmentDistanceMeters returns how far point p is from the segment a-b, all [lng, lat].
+// It projects onto a plane around a, which is accurate for segments of a few kilometers.
+func SegmentDistanceMeters(p, a, b []float64) float64 {
+	metersPerDegLat := earthRadiusMeters * math.Pi / 180
+	metersPerDegLng := metersPerDegLat * math.Cos(a[1]*math.Pi/180)
+	px, py := (p[0]-a[0])*metersPerDegLng, (p[1]-a[1])*metersPerDegLat
+	bx, by := (b[0]-a[0])*metersPerDegLng, (b[1]-a[1])*metersPerDegLat
+
+	lengthSq := bx*bx + by*by
+	if lengthSq == 0 {
+		return math.Hypot(px, py)
+	}
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/lengthSq))
+	return math.Hypot(px-t*bx, py-t*by)
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"log"
+	"strings"
 
 	"github.com/bwise1/waze_kibris/config"
 	"github.com/cloudinary/cloudinary-go/v2"
@@ -29,3 +31,55 @@ func (c *Cloudinary) UploadImage(ctx context.Context, filePath string, folder st
 	}
 	return resp.SecureURL, nil
 }
+
+// ImageAsset is a stored image and the public ID it can be deleted by.
+type ImageAsset struct {
+	PublicID string
+	URL      string
+}
+
+// UploadImageAsset is UploadImage for callers that need to delete the image later.
+func (c *Cloudinary) UploadImageAsset(ctx context.Context, filePath string, folder string) (ImageAsset, error) {
+	resp, err := c.CLD.Upload.Upload(ctx, filePath, uploader.UploadParams{Folder: folder})
+	if err != nil {
+		return ImageAsset{}, err
+	}
+	return ImageAsset{PublicID: resp.PublicID, URL: resp.SecureURL}, nil
+}
+
+// DeleteImage removes an image by public ID and purges it from the CDN. Deleting an
+// image that is already gone is not an error.
+func (c *Cloudinary) DeleteImage(ctx context.Context, publicID string) error {
+	invalidate := true
+	resp, err := c.CLD.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID, Invalidate: &invalidate})
+	if err != nil {
+		return err
+	}
+	if resp.Error.Message != "" {
+		return errors.New(resp.Error.Message)
+	}
+	return nil
+}
+
+// thumbnailTransformation crops to a square around the most interesting part of the image
+// and lets Cloudinary pick the format and quality per client.
+const thumbnailTransformation = "c_fill,g_auto,w_320,h_320,q_auto,f_auto"
+
+// ThumbnailURL is the delivery URL of a thumbnail of the image at url. Cloudinary renders
+// it on first request, so nothing extra is stored. Non-Cloudinary URLs come back as is.
+func ThumbnailURL(url string) string {
+	before, after, ok := strings.Cut(url, "/image/upload/")
+	if !ok {
+		return url
+	}
+	return before + "/image/upload/" + thumbnailTransformation + "/" + after
+}
+
+// UploadFile stores a non-image file (e.g. a CSV export) as a raw asset and returns its URL.
+func (c *Cloudinary) UploadFile(ctx context.Context, filePath string, folder string) (string, error) {
+	resp, err := c.CLD.Upload.Upload(ctx, filePath, uploader.UploadParams{Folder: folder, ResourceType: "raw"})
+	if err != nil {
+		return "", err
+	}
+	return resp.SecureURL, nil
+}
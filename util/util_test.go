@@ -1,6 +1,10 @@
 package util
 
 import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -61,3 +65,207 @@ func TestFormatTime(t *testing.T) {
 	}
 
 }
+
+func TestSignRequest(t *testing.T) {
+	secret := "device-secret"
+	body := []byte(`{"type":"POLICE","latitude":35.18,"longitude":33.38}`)
+
+	sig := SignRequest(secret, "post", "/reports/", "1700000000", "nonce-1", body)
+	if sig != SignRequest(secret, "POST", "/reports/", "1700000000", "nonce-1", body) {
+		t.Fatalf("signature should not depend on method case")
+	}
+	if !ValidSignature(secret, "POST", "/reports/", "1700000000", "nonce-1", body, sig) {
+		t.Fatalf("expected signature to validate")
+	}
+	if ValidSignature(secret, "POST", "/reports/", "1700000000", "nonce-2", body, sig) {
+		t.Errorf("signature must be bound to the nonce")
+	}
+	if ValidSignature(secret, "POST", "/reports/", "1700000000", "nonce-1", []byte(`{}`), sig) {
+		t.Errorf("signature must be bound to the body")
+	}
+	if ValidSignature("other-secret", "POST", "/reports/", "1700000000", "nonce-1", body, sig) {
+		t.Errorf("signature must be bound to the secret")
+	}
+}
+
+func TestCheckLocationPlausibility(t *testing.T) {
+	start := time.Date(2025, 4, 5, 14, 30, 0, 0, time.UTC)
+	speed := 25.0
+	north, south := 0.0, 180.0
+
+	// Nicosia -> Kyrenia (~20km) in 10 seconds
+	prev := LocationSample{Latitude: 35.1856, Longitude: 33.3823, At: start}
+	curr := LocationSample{Latitude: 35.3364, Longitude: 33.3199, At: start.Add(10 * time.Second)}
+	if got := CheckLocationPlausibility(prev, curr); len(got) != 1 || got[0] != SuspicionTeleport {
+		t.Errorf("expected teleport, got %v", got)
+	}
+
+	// Same trip in 25 minutes is fine
+	curr.At = start.Add(25 * time.Minute)
+	if got := CheckLocationPlausibility(prev, curr); len(got) != 0 {
+		t.Errorf("expected no suspicion, got %v", got)
+	}
+
+	// U-turn at speed within one second
+	prev = LocationSample{Latitude: 35.1856, Longitude: 33.3823, Heading: &north, At: start}
+	curr = LocationSample{Latitude: 35.1857, Longitude: 33.3823, Heading: &south, Speed: &speed, At: start.Add(time.Second)}
+	if got := CheckLocationPlausibility(prev, curr); len(got) != 1 || got[0] != SuspicionImpossibleHeading {
+		t.Errorf("expected impossible heading, got %v", got)
+	}
+}
+
+func TestIsEmulatorFingerprint(t *testing.T) {
+	if !IsEmulatorFingerprint("sdk_gphone64_arm64") {
+		t.Errorf("expected android emulator to be detected")
+	}
+	if !IsEmulatorFingerprint("iPhone Simulator") {
+		t.Errorf("expected iOS simulator to be detected")
+	}
+	if IsEmulatorFingerprint("SM-G991B") || IsEmulatorFingerprint("") {
+		t.Errorf("real device should not be flagged")
+	}
+}
+
+func TestNormalizeCoordinate(t *testing.T) {
+	lat, lon, err := NormalizeCoordinate(35.18563219, 33.38231187)
+	if err != nil || lat != 35.185632 || lon != 33.382312 {
+		t.Errorf("expected rounding to 6 decimals, got %v,%v (%v)", lat, lon, err)
+	}
+
+	// Longitude sent as latitude
+	lat, lon, err = NormalizeCoordinate(120.5, 35.2)
+	if err != nil || lat != 35.2 || lon != 120.5 {
+		t.Errorf("expected transposed pair to be swapped, got %v,%v (%v)", lat, lon, err)
+	}
+
+	if _, _, err := NormalizeCoordinate(0, 0); err != ErrNullIslandCoordinate {
+		t.Errorf("expected (0,0) to be rejected, got %v", err)
+	}
+	if _, _, err := NormalizeCoordinate(95, 200); err != ErrCoordinateOutOfRange {
+		t.Errorf("expected out of range error, got %v", err)
+	}
+}
+
+func TestRequireJSONFields(t *testing.T) {
+	body := []byte(`{"code":"Ok","routes":[{"duration":1.5,"legs":[{"summary":"A"}]},{"duration":2,"legs":[]}]}`)
+	if err := RequireJSONFields(body, "code", "routes[].duration", "routes[].legs[].summary"); err != nil {
+		t.Errorf("expected fields to be present, got %v", err)
+	}
+	if err := RequireJSONFields(body, "routes[].geometry"); err == nil || !strings.Contains(err.Error(), "routes[0].geometry") {
+		t.Errorf("expected missing geometry to be reported, got %v", err)
+	}
+	if err := RequireJSONFields([]byte(`{"routes":null}`), "routes[].duration"); err == nil {
+		t.Errorf("expected null array to be rejected")
+	}
+	if err := RequireJSONFields([]byte(`{"routes":{}}`), "routes[].duration"); err == nil {
+		t.Errorf("expected object in place of array to be rejected")
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	cases := map[string]string{
+		"+357 99 123456":     "+35799123456",
+		"0035799123456":      "+35799123456",
+		"99-123-456":         "+35799123456",
+		"+44 (20) 7946 0958": "+442079460958",
+	}
+	for in, want := range cases {
+		if got, err := NormalizePhone(in); err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "+123", "+357 99 12a456", "+0357991234", "+1234567890123456"} {
+		if _, err := NormalizePhone(in); err != ErrInvalidPhone {
+			t.Errorf("NormalizePhone(%q) should fail, got %v", in, err)
+		}
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	if got, want := MaskPhone("+35799123456"), "+********456"; got != want {
+		t.Errorf("MaskPhone = %q, want %q", got, want)
+	}
+	if got := MaskPhone("12"); got != "12" {
+		t.Errorf("MaskPhone(short) = %q, want it unchanged", got)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	type Point struct {
+		Latitude float64 `json:"latitude" validate:"latitude"`
+	}
+	type Base struct {
+		Type string `json:"type" validate:"required"`
+	}
+	type request struct {
+		Base
+		Kind   string      `json:"kind" validate:"oneof=a b"`
+		Tags   []string    `json:"tags" validate:"max=1,dive,min=2"`
+		Points []Point     `json:"points" validate:"dive"`
+		Line   [][]float64 `json:"line" validate:"dive,len=2,lnglat"`
+	}
+
+	errs := FieldErrors(ValidateStruct(request{Kind: "c", Tags: []string{"x"}, Points: []Point{{Latitude: 91}},
+		Line: [][]float64{{33.3, 35.1}, {1}, {35.1, 233.3}}}))
+	want := []FieldError{
+		{Field: "type", Rule: "required", Message: "type is required"},
+		{Field: "kind", Rule: "oneof", Message: "kind must be one of: a, b"},
+		{Field: "tags[0]", Rule: "min", Message: "tags[0] must be at least 2 characters"},
+		{Field: "points[0].latitude", Rule: "latitude", Message: "points[0].latitude must be a latitude between -90 and 90"},
+		{Field: "line[1]", Rule: "len", Message: "line[1] must be exactly 2 items"},
+		{Field: "line[2]", Rule: "lnglat", Message: "line[2] must be a [longitude, latitude] pair within -180..180 and -90..90"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("FieldErrors = %+v", errs)
+	}
+	for i := range want {
+		if errs[i] != want[i] {
+			t.Errorf("errs[%d] = %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+	if FieldErrors(errors.New("boom")) != nil {
+		t.Error("FieldErrors of a plain error should be nil")
+	}
+}
+
+func TestSimplifyLine(t *testing.T) {
+	coords, err := DecodeValhallaPolyline6("qlvcbAwspp~@}AxAwKfKcUhUoYbVq]|X{UtQgc@zZ_KrGoFjCwDrBsCpDw@fAuAxBcBpGuBlCgB|@qCPcCYQeDGmDR_Dh@gLBoKeAuPqCca@kEs`@kDcRkB}JkB}JqAoGa@oB}AyHyEmU}Pov@qLsj@aCwLoFoYoNku@sCwJ{A}FuIgMqIwHsFqA_FsCuEqF{CkHsAuIIaJpAeKhDuIpAyMFiMa@uJwA{JyFiXiCkLuEgQmOiq@c[wvAya@okBaDcO_Kae@o@wCaHub@aCoUiAiTa@yl@t@ol@jBce@rDua@lBqP")
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	line := make([][]float64, len(coords))
+	for i, c := range coords {
+		line[i] = []float64{c.Lon, c.Lat}
+	}
+
+	// maxDeviation is how far any original point ends up from the simplified line.
+	maxDeviation := func(simplified [][]float64) float64 {
+		var worst float64
+		for _, p := range line {
+			nearest := math.Inf(1)
+			for i := 1; i < len(simplified); i++ {
+				nearest = math.Min(nearest, SegmentDistanceMeters(p, simplified[i-1], simplified[i]))
+			}
+			worst = math.Max(worst, nearest)
+		}
+		return worst
+	}
+
+	if got := SimplifyLine(line, 0); len(got) != len(line) {
+		t.Errorf("tolerance 0 kept %d of %d points, want all", len(got), len(line))
+	}
+	previous := len(line)
+	for _, toleranceM := range []float64{1, 5, 25} {
+		got := SimplifyLine(line, toleranceM)
+		if len(got) >= previous {
+			t.Errorf("tolerance %v m kept %d points, want fewer than %d", toleranceM, len(got), previous)
+		}
+		if !reflect.DeepEqual(got[0], line[0]) || !reflect.DeepEqual(got[len(got)-1], line[len(line)-1]) {
+			t.Errorf("tolerance %v m moved the endpoints", toleranceM)
+		}
+		if d := maxDeviation(got); d > toleranceM {
+			t.Errorf("tolerance %v m: max deviation %.2f m", toleranceM, d)
+		}
+		previous = len(got)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net/url"
 	"regexp"
@@ -108,7 +108,7 @@ func PointFromLatLon(lat, lon float64) pgtype.Point {
 func DecodePolyLines(shape string) ([][]float64, error) {
 	decoded, _, err := polyline.DecodeCoords([]byte(shape))
 	if err != nil {
-		log.Println("error deocoding polyline: ", err)
+		slog.Warn("Decoding polyline failed", "err", err)
 		return nil, fmt.Errorf("failed to decode polyline %w", err)
 	}
 	return decoded, nil
@@ -1,6 +1,13 @@
 package util
 
-import "github.com/go-playground/validator/v10"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
 
 var validate *validator.Validate
 
@@ -8,6 +15,16 @@ func init() {
 	validate = validator.New()
 	validate.RegisterValidation("latitude", validateLatitude)
 	validate.RegisterValidation("longitude", validateLongitude)
+	validate.RegisterValidation("lnglat", validateLngLat)
+	// Name fields as clients send them, so errors point at "report_types[2]" rather than
+	// "ReportTypes[2]".
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 }
 
 func validateLatitude(fl validator.FieldLevel) bool {
@@ -20,6 +37,103 @@ func validateLongitude(fl validator.FieldLevel) bool {
 	return lon >= -180 && lon <= 180
 }
 
+// validateLngLat checks a GeoJSON position: a [longitude, latitude] pair.
+func validateLngLat(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.Kind() != reflect.Slice || field.Len() != 2 {
+		return false
+	}
+	lng, lat := field.Index(0).Float(), field.Index(1).Float()
+	return lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90
+}
+
 func ValidateStruct(s interface{}) error {
 	return validate.Struct(s)
 }
+
+// FieldError is one rule a request field broke, as returned in a response's "errors".
+type FieldError struct {
+	Field   string `json:"field"`   // JSON path, e.g. "pois[0].category"
+	Rule    string `json:"rule"`    // validate tag that failed, e.g. "oneof"
+	Message string `json:"message"` // readable sentence naming the field
+}
+
+// FieldErrors turns an error from ValidateStruct into one FieldError per broken rule. It
+// returns nil for any other error.
+func FieldErrors(err error) []FieldError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil
+	}
+	out := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		field := fieldPath(fe.Namespace())
+		out = append(out, FieldError{
+			Field:   field,
+			Rule:    fe.Tag(),
+			Message: field + " " + ruleMessage(fe),
+		})
+	}
+	return out
+}
+
+// fieldPath drops the struct name the namespace starts with, and any embedded structs
+// (which have no JSON name) along the way.
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")[1:]
+	kept := parts[:0]
+	for _, p := range parts {
+		if p != "" && p[0] >= 'A' && p[0] <= 'Z' {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return strings.Join(kept, ".")
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	case "lnglat":
+		return "must be a [longitude, latitude] pair within -180..180 and -90..90"
+	case "email":
+		return "must be an email address"
+	case "numeric":
+		return "must contain only digits"
+	case "uuid", "uuid4":
+		return "must be a UUID"
+	case "url", "http_url":
+		return "must be a URL"
+	case "unique":
+		return "must not contain duplicates"
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
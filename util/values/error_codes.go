@@ -0,0 +1,61 @@
+package values
+
+/* Error codes, sent as "code" on every error response. Clients branch on these;
+messages are for people and may change. Codes are only ever added, never renamed. */
+
+// One per response status, for errors without a more specific code.
+const CodeInternal = "INTERNAL_ERROR"
+const CodeRequestFailed = "REQUEST_FAILED"
+const CodeBadRequest = "BAD_REQUEST"
+const CodeUnprocessable = "UNPROCESSABLE"
+const CodeForbidden = "FORBIDDEN"
+const CodeActiveLogin = "ACTIVE_LOGIN"
+const CodeConflict = "CONFLICT"
+const CodeNotFound = "NOT_FOUND"
+const CodeUnauthorized = "UNAUTHORIZED"
+const CodeTokenExpired = "TOKEN_EXPIRED"
+const CodeUnavailable = "SERVICE_UNAVAILABLE"
+const CodeRateLimited = "RATE_LIMITED"
+
+// Requests and auth.
+const CodeValidationFailed = "VALIDATION_FAILED"
+const CodeInvalidToken = "INVALID_TOKEN"
+const CodeTokenRevoked = "TOKEN_REVOKED"
+const CodeAccountDeactivated = "ACCOUNT_DEACTIVATED"
+const CodeInsufficientRole = "INSUFFICIENT_ROLE"
+const CodeMissingPermission = "MISSING_PERMISSION"
+const CodeSignatureRequired = "SIGNATURE_REQUIRED"
+const CodeInvalidSignature = "INVALID_SIGNATURE"
+const CodeSignatureExpired = "SIGNATURE_EXPIRED"
+const CodeUnknownDevice = "UNKNOWN_DEVICE"
+const CodeReplayedRequest = "REPLAYED_REQUEST"
+const CodePhoneCodeInvalid = "PHONE_CODE_INVALID"
+const CodePhoneCodeLocked = "PHONE_CODE_LOCKED"
+const CodeQuotaExceeded = "QUOTA_EXCEEDED"
+const CodeDatabaseUnavailable = "DATABASE_UNAVAILABLE"
+const CodeTimeout = "TIMEOUT"
+
+// Resources.
+const CodeReportNotFound = "REPORT_NOT_FOUND"
+const CodeReportTransition = "REPORT_INVALID_TRANSITION"
+const CodeCommentNotFound = "COMMENT_NOT_FOUND"
+const CodeVoteNotFound = "VOTE_NOT_FOUND"
+const CodeSessionNotFound = "SESSION_NOT_FOUND"
+const CodeNavigationSessionNotFound = "NAVIGATION_SESSION_NOT_FOUND"
+const CodeNavigationSessionInactive = "NAVIGATION_SESSION_INACTIVE"
+const CodePlannedDriveNotFound = "PLANNED_DRIVE_NOT_FOUND"
+const CodeAlertZoneNotFound = "ALERT_ZONE_NOT_FOUND"
+const CodeAlertZoneLimit = "ALERT_ZONE_LIMIT"
+const CodeGroupMemberNotFound = "GROUP_MEMBER_NOT_FOUND"
+const CodeGroupRideNotFound = "GROUP_RIDE_NOT_FOUND"
+const CodeAlreadyGroupMember = "ALREADY_GROUP_MEMBER"
+const CodeLastGroupAdmin = "LAST_GROUP_ADMIN"
+const CodePinLimitReached = "PIN_LIMIT_REACHED"
+const CodeInvitationNotFound = "INVITATION_NOT_FOUND"
+const CodeUploadNotFound = "UPLOAD_NOT_FOUND"
+const CodeSavedLocationNotFound = "SAVED_LOCATION_NOT_FOUND"
+const CodeRecentDestinationNotFound = "RECENT_DESTINATION_NOT_FOUND"
+
+// Routing and map providers.
+const CodeRouteNoPath = "ROUTE_NO_PATH"
+const CodeProviderError = "PROVIDER_ERROR"
@@ -0,0 +1,35 @@
+package values
+
+/* Group notification levels (group_memberships.notification_level) */
+
+const GroupNotifyAll = "all"
+const GroupNotifyMentions = "mentions"
+const GroupNotifyMuted = "muted"
+
+/* Group roles (group_memberships.role) */
+
+const GroupRoleAdmin = "admin"
+const GroupRoleModerator = "moderator"
+const GroupRoleMember = "member"
+
+/* Group membership statuses (group_memberships.status) */
+
+const GroupMemberActive = "active"
+const GroupMemberPending = "pending" // asked to join a private group; awaiting an admin
+
+/* Group visibility (community_groups.visibility) */
+
+const GroupVisibilityPublic = "public"
+const GroupVisibilityPrivate = "private"
+
+/* Group message types with special handling (messages.message_type) */
+
+const GroupMessageAnnouncement = "announcement"
+
+/* Group rides (group_rides.kind, group_rides.status) */
+
+const GroupRideOffer = "offer"     // a driver with free seats
+const GroupRideRequest = "request" // a member looking for a lift
+
+const GroupRideOpen = "open"
+const GroupRideCancelled = "cancelled"
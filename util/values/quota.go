@@ -0,0 +1,18 @@
+package values
+
+/* API quota tiers (users.quota_tier) */
+
+const QuotaTierFree = "free"
+const QuotaTierPower = "power"
+const QuotaTierPartner = "partner"
+
+/* Quota response headers */
+
+const HeaderQuotaTier = "X-Quota-Tier"
+const HeaderQuotaLimit = "X-Quota-Limit"
+const HeaderQuotaRemaining = "X-Quota-Remaining"
+const HeaderQuotaReset = "X-Quota-Reset" // unix seconds when the daily counter resets (UTC midnight)
+
+/* Rate limit response headers */
+
+const HeaderRateLimitRemaining = "X-RateLimit-Remaining"
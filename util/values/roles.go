@@ -0,0 +1,7 @@
+package values
+
+/* User roles (users.role) */
+
+const RoleUser = "user"
+const RoleModerator = "moderator"
+const RoleAdmin = "admin"
@@ -0,0 +1,15 @@
+package values
+
+/* Route preferences (users.route_preference, POST /route "preference") */
+
+const RoutePreferenceFastest = "fastest"
+const RoutePreferenceSafe = "safe"
+
+/* Planned drives (planned_drives.source, planned_drives.status) */
+
+const PlannedDriveSourceCalendar = "calendar"
+const PlannedDriveSourceManual = "manual"
+
+const PlannedDriveSuggested = "suggested"
+const PlannedDriveDismissed = "dismissed"
+const PlannedDriveCancelled = "cancelled"
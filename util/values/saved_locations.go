@@ -0,0 +1,8 @@
+package values
+
+/* Saved location categories (saved_locations.category) */
+
+const SavedLocationHome = "home" // at most one per user, like work
+const SavedLocationWork = "work"
+const SavedLocationFavorite = "favorite"
+const SavedLocationCustom = "custom" // shown with its own icon or emoji
@@ -0,0 +1,10 @@
+package values
+
+/* Request signing headers (reports and location heartbeats) */
+
+const HeaderDeviceID = "X-Device-ID"
+const HeaderDeviceModel = "X-Device-Model"
+const HeaderDevicePlatform = "X-Device-Platform"
+const HeaderSignature = "X-Signature"
+const HeaderSignatureTimestamp = "X-Signature-Timestamp"
+const HeaderSignatureNonce = "X-Signature-Nonce"
@@ -14,5 +14,7 @@ const Conflict = "conflict"
 const NotFound = "not-found"
 const NotAuthorised = "not-authorised"
 const TokenExpired = "token-expired"
+const Unavailable = "unavailable" // retryable: a dependency (e.g. the database) is temporarily down
+const TooManyRequests = "too-many-requests"
 
 const SystemErr = "Unable to complete this request. Please try again"
@@ -0,0 +1,6 @@
+package values
+
+/* Report vote types (votes.vote_type) */
+
+const VoteUp = "UPVOTE"
+const VoteDown = "DOWNVOTE"
@@ -0,0 +1,84 @@
+package websockets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authorizer ties connections to users and guards the private topics. The REST layer
+// implements it with the access token and group memberships.
+type Authorizer interface {
+	// Authenticate returns the user whose access token came with the upgrade request.
+	Authenticate(r *http.Request) (userID string, err error)
+	// CanJoin reports whether userID may subscribe to a group or navigation topic.
+	CanJoin(ctx context.Context, userID, topic string) (bool, error)
+}
+
+// ErrNoAuthorizer is returned while no Authorizer is set: connections are refused and
+// private topics can't be joined rather than trusting what clients claim.
+var ErrNoAuthorizer = errors.New("websocket authorizer not configured")
+
+// authorizeTimeout bounds the membership lookups behind one subscription.
+const authorizeTimeout = 5 * time.Second
+
+// SetAuthorizer authenticates new connections and checks private topic subscriptions
+// through a.
+func (manager *WebSocketManager) SetAuthorizer(a Authorizer) {
+	manager.mu.Lock()
+	manager.authorizer = a
+	manager.mu.Unlock()
+}
+
+func (manager *WebSocketManager) getAuthorizer() Authorizer {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager.authorizer
+}
+
+// authenticate returns the user behind an upgrade request.
+func (manager *WebSocketManager) authenticate(r *http.Request) (string, error) {
+	a := manager.getAuthorizer()
+	if a == nil {
+		return "", ErrNoAuthorizer
+	}
+	return a.Authenticate(r)
+}
+
+// authorizeTopics checks that client may join the group and navigation topics among
+// topics it isn't subscribed to yet. Region topics are public. Must not be called with
+// manager.mu held.
+func (manager *WebSocketManager) authorizeTopics(client *Client, topics []string) error {
+	var private []string
+	for _, topic := range topics {
+		if !strings.HasPrefix(topic, TopicPrefixGroup) && !strings.HasPrefix(topic, TopicPrefixNavigation) {
+			continue
+		}
+		if !manager.subscribedTo(client, topic) {
+			private = append(private, topic)
+		}
+	}
+	if len(private) == 0 {
+		return nil
+	}
+	a := manager.getAuthorizer()
+	if a == nil {
+		return ErrNoAuthorizer
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), authorizeTimeout)
+	defer cancel()
+	for _, topic := range private {
+		ok, err := a.CanJoin(ctx, client.UserID, topic)
+		if err != nil {
+			return fmt.Errorf("checking access to %s: %w", topic, err)
+		}
+		if !ok {
+			return fmt.Errorf("not allowed to join %s", topic)
+		}
+	}
+	return nil
+}
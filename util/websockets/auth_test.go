@@ -0,0 +1,98 @@
+package websockets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// memberOf authenticates the token "alice" and lets that user into the listed topics only.
+type memberOf map[string]bool
+
+func (memberOf) Authenticate(r *http.Request) (string, error) {
+	if r.URL.Query().Get("access_token") != "alice" {
+		return "", errors.New("bad token")
+	}
+	return "alice", nil
+}
+
+func (m memberOf) CanJoin(_ context.Context, userID, topic string) (bool, error) {
+	return userID == "alice" && m[topic], nil
+}
+
+func TestSubscribeChecksAccess(t *testing.T) {
+	manager := NewWebSocketManager()
+	c := newTestClient(manager, 0, 0)
+	c.UserID = "alice"
+
+	if err := manager.subscribe(c, []string{GroupTopic("g1")}); !errors.Is(err, ErrNoAuthorizer) {
+		t.Errorf("without an authorizer: err = %v, want ErrNoAuthorizer", err)
+	}
+
+	manager.SetAuthorizer(memberOf{GroupTopic("g1"): true, NavigationTopic("s1"): true})
+	if err := manager.subscribe(c, []string{GroupTopic("g1"), NavigationTopic("s1"), ReportsRegionTopic("swg")}); err != nil {
+		t.Fatalf("allowed topics: %v", err)
+	}
+	if err := manager.subscribe(c, []string{ReportsRegionTopic("sw3"), GroupTopic("g2")}); err == nil {
+		t.Fatal("joined a group the user isn't a member of")
+	}
+	if manager.subscribedTo(c, ReportsRegionTopic("sw3")) || manager.subscribedTo(c, GroupTopic("g2")) {
+		t.Error("a rejected subscription must not subscribe to any topic")
+	}
+
+	if err := manager.syncGroupTopics(c, []string{"g1", "g2"}); err == nil {
+		t.Error("active_group_ids joined a group the user isn't a member of")
+	}
+}
+
+func TestHandleConnectionsRequiresToken(t *testing.T) {
+	manager := NewWebSocketManager()
+	manager.SetAuthorizer(memberOf{})
+	go manager.Run()
+	srv := httptest.NewServer(http.HandlerFunc(manager.HandleConnections))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"?access_token=mallory", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad token: err = %v, resp = %v", err, resp)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?access_token=alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The frame's user_id can't override the token's user.
+	if err := conn.WriteJSON(Message{Type: MsgTypeSubscribe, UserID: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteJSON(Message{Type: MsgTypeSubscribeTopics, Topics: []string{ReportsRegionTopic("swg")}}); err != nil {
+		t.Fatal(err)
+	}
+	var reply Message
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Type != MsgTypeTopics {
+		t.Fatalf("reply = %+v", reply)
+	}
+	// Run registers the user asynchronously.
+	registered := false
+	for i := 0; i < 100 && !registered; i++ {
+		registered = manager.SendToUser("alice", []byte(`{"type":"ping"}`))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !registered {
+		t.Error("connection not registered under the token's user")
+	}
+	if manager.SendToUser("bob", []byte(`{"type":"ping"}`)) {
+		t.Error("connection registered under the user_id the frame claimed")
+	}
+}
@@ -0,0 +1,132 @@
+package websockets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Inbound frames per connection: inboundRate per second on average, in bursts of up to
+	// inboundBurst. A client over the limit is disconnected.
+	inboundRate  = 5.0
+	inboundBurst = 20.0
+	// Clients are disconnected after this many malformed or invalid frames.
+	maxInvalidFrames = 5
+
+	maxUserIDLength  = 64
+	maxGroupIDLength = 64
+	maxActiveGroups  = 50
+	maxContentLength = 400 // leaves room for the envelope within readLimit
+)
+
+// inboundLimiter is a token bucket for one connection's inbound frames. It is only used
+// by the connection's read loop, so it needs no locking.
+type inboundLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+func newInboundLimiter() *inboundLimiter {
+	return &inboundLimiter{tokens: inboundBurst, last: time.Now()}
+}
+
+// allow takes a token for one frame, reporting false when the bucket is empty.
+func (l *inboundLimiter) allow() bool {
+	now := time.Now()
+	l.tokens = min(inboundBurst, l.tokens+now.Sub(l.last).Seconds()*inboundRate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// validateMessage checks an inbound frame against the schema for its type. client is the
+// sender, whose subscription state decides what it may send.
+func (manager *WebSocketManager) validateMessage(client *Client, m *Message) error {
+	switch m.Type {
+	case "ping":
+		return nil
+	case MsgTypeSubscribe:
+		// user_id is optional now that the token identifies the user, but must not differ.
+		if m.UserID != "" && m.UserID != client.UserID {
+			return errors.New("user_id does not match the access token")
+		}
+		if len(m.ActiveGroupIDs) > maxActiveGroups {
+			return fmt.Errorf("at most %d active_group_ids", maxActiveGroups)
+		}
+		for _, id := range m.ActiveGroupIDs {
+			if id == "" || len(id) > maxGroupIDLength {
+				return errors.New("invalid active_group_ids")
+			}
+		}
+		return validCoordinates(m.Latitude, m.Longitude)
+	case MsgTypeSubscribeTopics, MsgTypeUnsubscribeTopics:
+		if len(m.Topics) == 0 || len(m.Topics) > maxTopicsPerClient {
+			return fmt.Errorf("between 1 and %d topics", maxTopicsPerClient)
+		}
+		for _, topic := range m.Topics {
+			if err := validateTopic(topic); err != nil {
+				return err
+			}
+		}
+		return nil
+	case MsgTypeReportUpdate:
+		// Report updates are only published by the server (BroadcastReportUpdate); clients
+		// create and change reports through the REST API.
+		return errors.New("report_update is sent by the server only")
+	case MsgTypeDirectMessage:
+		if m.Receiver == "" || len(m.Receiver) > maxUserIDLength {
+			return errors.New("invalid receiver")
+		}
+		if m.Content == "" || len(m.Content) > maxContentLength {
+			return errors.New("invalid content")
+		}
+		return nil
+	case MsgTypeGroupChat, MsgTypeGroupLocationUpdate:
+		if m.GroupID == "" || !manager.subscribedTo(client, GroupTopic(m.GroupID)) {
+			return errors.New("not subscribed to group")
+		}
+		if len(m.Content) > maxContentLength {
+			return errors.New("content too long")
+		}
+		return validCoordinates(m.Latitude, m.Longitude)
+	}
+	return fmt.Errorf("unknown message type %q", m.Type)
+}
+
+func validCoordinates(lat, lon float64) error {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return errors.New("invalid coordinates")
+	}
+	return nil
+}
+
+// closeWithPolicyViolation tells the client why it is being disconnected. The read loop
+// exits afterwards and unregisters the connection.
+func closeWithPolicyViolation(conn *websocket.Conn, reason string) {
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+}
+
+// relayed re-encodes a client's message for other clients: only the known fields are
+// kept, the sender is the authenticated user rather than whatever the frame claimed, and
+// event IDs are left to the server.
+func relayed(client *Client, m Message) ([]byte, bool) {
+	m.UserID = client.UserID
+	m.EventID = ""
+	m.ActiveGroupIDs = nil
+	m.Topics = nil
+	out, err := json.Marshal(m)
+	if err != nil {
+		slog.Error("Failed to encode WebSocket message", "user_id", client.UserID, "err", err)
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,36 @@
+package websockets
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateMessageRejectsClientReportUpdates(t *testing.T) {
+	manager := newTestManager()
+	c := newTestClient(manager, 0, 0)
+	m := Message{Type: MsgTypeReportUpdate, Content: `{"id":1,"upvotes_count":999}`}
+	if err := manager.validateMessage(c, &m); err == nil {
+		t.Error("client report_update was accepted")
+	}
+}
+
+func TestRelayedDirectMessageCarriesSender(t *testing.T) {
+	c := &Client{UserID: "sender"}
+	out, ok := relayed(c, Message{
+		Type:     MsgTypeDirectMessage,
+		UserID:   "someone-else",
+		Receiver: "receiver",
+		Content:  `{"type":"report_update"}`,
+		EventID:  "forged",
+	})
+	if !ok {
+		t.Fatal("relayed failed")
+	}
+	var got Message
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != MsgTypeDirectMessage || got.UserID != "sender" || got.EventID != "" {
+		t.Errorf("relayed = %+v, want a direct_message from sender without an event_id", got)
+	}
+}
@@ -0,0 +1,111 @@
+package websockets
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PresenceStore shares which users are connected across API instances (util/presence
+// has the Redis implementation). Without one, presence only covers this instance.
+type PresenceStore interface {
+	SetOnline(ctx context.Context, userIDs []string, ttl time.Duration) error
+	SetOffline(ctx context.Context, userID string) error
+	Online(ctx context.Context, userIDs []string) (map[string]bool, error)
+}
+
+const (
+	// Presence entries expire unless refreshed, so a crashed instance's users drop off.
+	presenceTTL     = 60 * time.Second
+	presenceRefresh = 20 * time.Second
+	presenceTimeout = 2 * time.Second
+)
+
+// SetPresenceStore shares this instance's connected users through store.
+func (manager *WebSocketManager) SetPresenceStore(store PresenceStore) {
+	manager.mu.Lock()
+	manager.presence = store
+	manager.mu.Unlock()
+}
+
+// presenceChanged pushes a single connect or disconnect to the presence store.
+// Must not be called with manager.mu held.
+func (manager *WebSocketManager) presenceChanged(userID string, online bool) {
+	manager.mu.Lock()
+	store := manager.presence
+	manager.mu.Unlock()
+	if store == nil || userID == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+		defer cancel()
+		var err error
+		if online {
+			err = store.SetOnline(ctx, []string{userID}, presenceTTL)
+		} else {
+			err = store.SetOffline(ctx, userID)
+		}
+		if err != nil {
+			slog.Warn("Presence update failed", "user_id", userID, "err", err)
+		}
+	}()
+}
+
+// RunPresence keeps this instance's connected users alive in the presence store until
+// ctx is cancelled. It returns at once when no store is set.
+func (manager *WebSocketManager) RunPresence(ctx context.Context) {
+	ticker := time.NewTicker(presenceRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		manager.mu.Lock()
+		store := manager.presence
+		userIDs := make([]string, 0, len(manager.userIndex))
+		for id := range manager.userIndex {
+			userIDs = append(userIDs, id)
+		}
+		manager.mu.Unlock()
+		if store == nil {
+			continue
+		}
+
+		refreshCtx, cancel := context.WithTimeout(ctx, presenceTimeout)
+		if err := store.SetOnline(refreshCtx, userIDs, presenceTTL); err != nil {
+			slog.WarnContext(ctx, "Presence refresh failed", "err", err)
+		}
+		cancel()
+	}
+}
+
+// OnlineUsers reports which of userIDs are connected, to this instance or, with a
+// presence store, to any instance. Store errors fall back to local presence.
+func (manager *WebSocketManager) OnlineUsers(ctx context.Context, userIDs []string) map[string]bool {
+	manager.mu.Lock()
+	store := manager.presence
+	online := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		if manager.userIndex[id] != nil {
+			online[id] = true
+		}
+	}
+	manager.mu.Unlock()
+
+	if store != nil {
+		ctx, cancel := context.WithTimeout(ctx, presenceTimeout)
+		defer cancel()
+		shared, err := store.Online(ctx, userIDs)
+		if err != nil {
+			slog.WarnContext(ctx, "Presence lookup failed, using local connections only", "err", err)
+		}
+		for id := range shared {
+			online[id] = true
+		}
+	}
+	return online
+}
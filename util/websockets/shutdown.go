@@ -0,0 +1,47 @@
+package websockets
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown sends every client a "going away" close frame so apps reconnect to another
+// instance, then waits for their read loops to unregister them. Connections still open
+// when ctx is done are closed outright. Run must keep running until Shutdown returns.
+func (manager *WebSocketManager) Shutdown(ctx context.Context) error {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, conn := range manager.conns() {
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		conns := manager.conns()
+		if len(conns) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (manager *WebSocketManager) conns() []*websocket.Conn {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	conns := make([]*websocket.Conn, 0, len(manager.clients))
+	for conn := range manager.clients {
+		conns = append(conns, conn)
+	}
+	return conns
+}
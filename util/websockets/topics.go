@@ -0,0 +1,288 @@
+package websockets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Topics let a client receive only the events it cares about:
+//
+//	reports:region:<geohash>  report updates inside a geohash cell of 3 to 6 characters
+//	group:<id>                group chat and group location updates
+//	navigation:<session>      server updates for a navigation session
+//
+// Clients register with {"type":"subscribe_topics","topics":[...]} and leave with
+// "unsubscribe_topics"; the server answers both with a "topics" message listing what the
+// client is now subscribed to. Each topic has its own hub of subscribed clients.
+//
+// Clients that never subscribe to a region keep getting report updates by distance from
+// the position in their last "subscribe" message, and active_group_ids in that message
+// still subscribes to the matching group topics.
+const (
+	TopicPrefixReportsRegion = "reports:region:"
+	TopicPrefixGroup         = "group:"
+	TopicPrefixNavigation    = "navigation:"
+
+	minRegionPrecision = 3 // ~156 km cells
+	maxRegionPrecision = 6 // ~1.2 km cells
+	maxTopicsPerClient = 100
+	maxSessionIDLength = 64
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ReportsRegionTopic is the topic for report updates inside a geohash cell.
+func ReportsRegionTopic(geohash string) string {
+	return TopicPrefixReportsRegion + geohash
+}
+
+// GroupTopic is the topic for a group's chat and location updates.
+func GroupTopic(groupID string) string {
+	return TopicPrefixGroup + groupID
+}
+
+// NavigationTopic is the topic for server updates on a navigation session.
+func NavigationTopic(sessionID string) string {
+	return TopicPrefixNavigation + sessionID
+}
+
+// validateTopic checks a topic name against the formats above.
+func validateTopic(topic string) error {
+	switch {
+	case strings.HasPrefix(topic, TopicPrefixReportsRegion):
+		geohash := strings.TrimPrefix(topic, TopicPrefixReportsRegion)
+		if len(geohash) < minRegionPrecision || len(geohash) > maxRegionPrecision {
+			return fmt.Errorf("region geohash must be %d to %d characters", minRegionPrecision, maxRegionPrecision)
+		}
+		for _, c := range geohash {
+			if !strings.ContainsRune(geohashAlphabet, c) {
+				return errors.New("invalid region geohash")
+			}
+		}
+		return nil
+	case strings.HasPrefix(topic, TopicPrefixGroup):
+		id := strings.TrimPrefix(topic, TopicPrefixGroup)
+		if id == "" || len(id) > maxGroupIDLength {
+			return errors.New("invalid group topic")
+		}
+		return nil
+	case strings.HasPrefix(topic, TopicPrefixNavigation):
+		id := strings.TrimPrefix(topic, TopicPrefixNavigation)
+		if id == "" || len(id) > maxSessionIDLength {
+			return errors.New("invalid navigation topic")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown topic %q", topic)
+}
+
+// topicHub holds the clients subscribed to one topic.
+type topicHub struct {
+	clients map[*Client]struct{}
+}
+
+// subscribe adds client to topics, all or nothing: going over maxTopicsPerClient or
+// naming a group or navigation topic the user may not join subscribes to none of them.
+// Must not be called with manager.mu held.
+func (manager *WebSocketManager) subscribe(client *Client, topics []string) error {
+	if err := manager.authorizeTopics(client, topics); err != nil {
+		return err
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	added := 0
+	for _, topic := range topics {
+		if _, ok := client.topics[topic]; !ok {
+			added++
+		}
+	}
+	if len(client.topics)+added > maxTopicsPerClient {
+		return fmt.Errorf("at most %d topics per connection", maxTopicsPerClient)
+	}
+
+	if client.topics == nil {
+		client.topics = make(map[string]struct{})
+	}
+	for _, topic := range topics {
+		if _, ok := client.topics[topic]; ok {
+			continue
+		}
+		hub := manager.topics[topic]
+		if hub == nil {
+			hub = &topicHub{clients: make(map[*Client]struct{})}
+			manager.topics[topic] = hub
+		}
+		hub.clients[client] = struct{}{}
+		client.topics[topic] = struct{}{}
+		if strings.HasPrefix(topic, TopicPrefixReportsRegion) {
+			client.regionTopics++
+		}
+	}
+	return nil
+}
+
+// unsubscribe removes client from topics it is subscribed to. Must not be called with
+// manager.mu held.
+func (manager *WebSocketManager) unsubscribe(client *Client, topics []string) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for _, topic := range topics {
+		manager.unsubscribeLocked(client, topic)
+	}
+}
+
+func (manager *WebSocketManager) unsubscribeLocked(client *Client, topic string) {
+	if _, ok := client.topics[topic]; !ok {
+		return
+	}
+	delete(client.topics, topic)
+	if strings.HasPrefix(topic, TopicPrefixReportsRegion) {
+		client.regionTopics--
+	}
+	hub := manager.topics[topic]
+	if hub == nil {
+		return
+	}
+	delete(hub.clients, client)
+	if len(hub.clients) == 0 {
+		delete(manager.topics, topic)
+	}
+}
+
+// dropTopicsLocked removes a disconnecting client from every hub. Must be called with
+// manager.mu held.
+func (manager *WebSocketManager) dropTopicsLocked(client *Client) {
+	for topic := range client.topics {
+		manager.unsubscribeLocked(client, topic)
+	}
+}
+
+// syncGroupTopics makes the client's group topics match groupIDs, for clients that
+// list their groups in the "subscribe" message. Must not be called with manager.mu held.
+func (manager *WebSocketManager) syncGroupTopics(client *Client, groupIDs []string) error {
+	want := make(map[string]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		want[GroupTopic(id)] = true
+	}
+
+	manager.mu.Lock()
+	var stale []string
+	for topic := range client.topics {
+		if strings.HasPrefix(topic, TopicPrefixGroup) && !want[topic] {
+			stale = append(stale, topic)
+		}
+	}
+	manager.mu.Unlock()
+	manager.unsubscribe(client, stale)
+
+	topics := make([]string, 0, len(want))
+	for topic := range want {
+		topics = append(topics, topic)
+	}
+	return manager.subscribe(client, topics)
+}
+
+// subscribedTo reports whether client is subscribed to topic.
+func (manager *WebSocketManager) subscribedTo(client *Client, topic string) bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	_, ok := client.topics[topic]
+	return ok
+}
+
+// clientTopics lists the topics client is subscribed to.
+func (manager *WebSocketManager) clientTopics(client *Client) []string {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	topics := make([]string, 0, len(client.topics))
+	for topic := range client.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Publish queues message for every client subscribed to any of topics, once per client
+// however many of the topics it is subscribed to. It returns how many clients it was
+// queued for; clients whose buffer is full are skipped.
+func (manager *WebSocketManager) Publish(message []byte, topics ...string) int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	recipients := make(map[*Client]struct{})
+	for _, topic := range topics {
+		if hub := manager.topics[topic]; hub != nil {
+			for c := range hub.clients {
+				recipients[c] = struct{}{}
+			}
+		}
+	}
+	return deliver(recipients, message)
+}
+
+// deliver queues message for each client without blocking. It must be called with
+// manager.mu held so a disconnecting client's Send channel can't be closed under it.
+func deliver(clients map[*Client]struct{}, message []byte) int {
+	sent := 0
+	for client := range clients {
+		select {
+		case client.Send <- message:
+			sent++
+		default:
+			// buffer full; skip this client to avoid blocking
+		}
+	}
+	return sent
+}
+
+// regionTopics lists the region topics covering a point, one per supported precision.
+func regionTopics(lat, lon float64) []string {
+	geohash := encodeGeohash(lat, lon, maxRegionPrecision)
+	topics := make([]string, 0, maxRegionPrecision-minRegionPrecision+1)
+	for p := minRegionPrecision; p <= maxRegionPrecision; p++ {
+		topics = append(topics, ReportsRegionTopic(geohash[:p]))
+	}
+	return topics
+}
+
+// encodeGeohash returns the standard base32 geohash of a point.
+func encodeGeohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	var out strings.Builder
+	bit, ch, even := 0, 0, true
+	for out.Len() < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			out.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return out.String()
+}
+
+// topicsReply tells a client what it is subscribed to after a (un)subscribe.
+func (manager *WebSocketManager) topicsReply(client *Client) {
+	out, err := json.Marshal(Message{Type: MsgTypeTopics, Topics: manager.clientTopics(client)})
+	if err != nil {
+		slog.Error("Failed to encode topics reply", "user_id", client.UserID, "err", err)
+		return
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	deliver(map[*Client]struct{}{client: {}}, out)
+}
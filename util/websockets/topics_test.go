@@ -0,0 +1,187 @@
+package websockets
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// allowAll lets every connection and subscription through.
+type allowAll struct{}
+
+func (allowAll) Authenticate(*http.Request) (string, error)            { return "u", nil }
+func (allowAll) CanJoin(context.Context, string, string) (bool, error) { return true, nil }
+
+func newTestManager() *WebSocketManager {
+	manager := NewWebSocketManager()
+	manager.SetAuthorizer(allowAll{})
+	return manager
+}
+
+func newTestClient(manager *WebSocketManager, lat, lon float64) *Client {
+	c := &Client{Conn: &websocket.Conn{}, Send: make(chan []byte, 8), UserID: "u", Latitude: lat, Longitude: lon}
+	manager.clients[c.Conn] = c
+	return c
+}
+
+func received(c *Client) int {
+	n := 0
+	for {
+		select {
+		case <-c.Send:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+func TestValidateTopic(t *testing.T) {
+	cases := []struct {
+		topic string
+		ok    bool
+	}{
+		{"reports:region:swg", true},
+		{"reports:region:swg9x3", true},
+		{"reports:region:sw", false},      // too coarse
+		{"reports:region:swg9x3p", false}, // too fine
+		{"reports:region:swga", false},    // 'a' is not in the geohash alphabet
+		{"group:6f1c2d9e-4b8a-4f7e-9a51-0c3d2b1e8f77", true},
+		{"group:", false},
+		{"navigation:6f1c2d9e-4b8a-4f7e-9a51-0c3d2b1e8f77", true},
+		{"navigation:", false},
+		{"everything", false},
+	}
+	for _, tc := range cases {
+		err := validateTopic(tc.topic)
+		if (err == nil) != tc.ok {
+			t.Errorf("validateTopic(%q) = %v, want ok=%v", tc.topic, err, tc.ok)
+		}
+	}
+}
+
+func TestEncodeGeohash(t *testing.T) {
+	if got := encodeGeohash(57.64911, 10.40744, 6); got != "u4pruy" {
+		t.Errorf("encodeGeohash = %q, want u4pruy", got)
+	}
+	topics := regionTopics(57.64911, 10.40744)
+	want := []string{"reports:region:u4p", "reports:region:u4pr", "reports:region:u4pru", "reports:region:u4pruy"}
+	if !slices.Equal(topics, want) {
+		t.Errorf("regionTopics = %v, want %v", topics, want)
+	}
+}
+
+func TestPublishOncePerClient(t *testing.T) {
+	manager := newTestManager()
+	a := newTestClient(manager, 0, 0)
+	b := newTestClient(manager, 0, 0)
+	if err := manager.subscribe(a, []string{GroupTopic("g1"), NavigationTopic("s1")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.subscribe(b, []string{GroupTopic("g2")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := manager.Publish([]byte("x"), GroupTopic("g1"), NavigationTopic("s1")); n != 1 {
+		t.Errorf("Publish queued for %d clients, want 1", n)
+	}
+	if got := received(a); got != 1 {
+		t.Errorf("subscriber of both topics got %d messages, want 1", got)
+	}
+	if got := received(b); got != 0 {
+		t.Errorf("client of another group got %d messages, want 0", got)
+	}
+}
+
+func TestUnsubscribeRemovesEmptyHubs(t *testing.T) {
+	manager := newTestManager()
+	a := newTestClient(manager, 0, 0)
+	b := newTestClient(manager, 0, 0)
+	topic := GroupTopic("g1")
+	manager.subscribe(a, []string{topic})
+	manager.subscribe(b, []string{topic, ReportsRegionTopic("swg")})
+
+	manager.unsubscribe(a, []string{topic})
+	if manager.Publish([]byte("x"), topic) != 1 || received(a) != 0 {
+		t.Errorf("unsubscribed client still receives the topic")
+	}
+
+	manager.mu.Lock()
+	manager.dropTopicsLocked(b)
+	manager.mu.Unlock()
+	if len(manager.topics) != 0 {
+		t.Errorf("hubs left after every client left: %v", manager.topics)
+	}
+	if b.regionTopics != 0 {
+		t.Errorf("regionTopics = %d after dropping all topics", b.regionTopics)
+	}
+}
+
+func TestSubscribeTopicLimit(t *testing.T) {
+	manager := newTestManager()
+	c := newTestClient(manager, 0, 0)
+	topics := make([]string, maxTopicsPerClient+1)
+	for i := range topics {
+		topics[i] = NavigationTopic(string(rune('a'+i%26)) + string(rune('a'+i/26)))
+	}
+	if err := manager.subscribe(c, topics); err == nil {
+		t.Fatal("expected subscribing past the limit to fail")
+	}
+	if len(c.topics) != 0 || len(manager.topics) != 0 {
+		t.Errorf("a rejected subscription must not subscribe to any topic")
+	}
+	if err := manager.subscribe(c, topics[:maxTopicsPerClient]); err != nil {
+		t.Errorf("subscribing up to the limit: %v", err)
+	}
+}
+
+func TestSyncGroupTopics(t *testing.T) {
+	manager := newTestManager()
+	c := newTestClient(manager, 0, 0)
+	manager.subscribe(c, []string{NavigationTopic("s1")})
+
+	manager.syncGroupTopics(c, []string{"g1", "g2"})
+	manager.syncGroupTopics(c, []string{"g2", "g3"})
+
+	got := manager.clientTopics(c)
+	slices.Sort(got)
+	want := []string{"group:g2", "group:g3", "navigation:s1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("topics = %v, want %v", got, want)
+	}
+}
+
+func TestBroadcastReportUpdate(t *testing.T) {
+	// A report in central Nicosia.
+	const lat, lon = 35.1856, 33.3823
+	manager := newTestManager()
+
+	regional := newTestClient(manager, 34.70, 33.02) // Limassol, subscribed to Nicosia's region
+	manager.subscribe(regional, []string{ReportsRegionTopic(encodeGeohash(lat, lon, 4))})
+	nearby := newTestClient(manager, 35.19, 33.38) // no regions, within radius
+	elsewhere := newTestClient(manager, 35.19, 33.38)
+	manager.subscribe(elsewhere, []string{ReportsRegionTopic("sw3")}) // regions replace the radius
+	far := newTestClient(manager, 34.70, 33.02)
+	dismissed := newTestClient(manager, 35.19, 33.38)
+	dismissed.UserID = "dismissed"
+
+	manager.BroadcastReportUpdate([]byte("r"), lat, lon, 5000, map[string]bool{"dismissed": true})
+
+	for name, tc := range map[string]struct {
+		c    *Client
+		want int
+	}{
+		"regional":  {regional, 1},
+		"nearby":    {nearby, 1},
+		"elsewhere": {elsewhere, 0},
+		"far":       {far, 0},
+		"dismissed": {dismissed, 0},
+	} {
+		if got := received(tc.c); got != tc.want {
+			t.Errorf("%s client got %d messages, want %d", name, got, tc.want)
+		}
+	}
+}
@@ -77,7 +77,7 @@ package websockets
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"time"
@@ -107,7 +107,7 @@ func NewWebSocketManager() *WebSocketManager {
 	return &WebSocketManager{
 		clients:      make(map[*websocket.Conn]*Client),
 		userIndex:    make(map[string]*Client),
-		broadcast:    make(chan []byte),
+		topics:       make(map[string]*topicHub),
 		register:     make(chan *Client),
 		registerUser: make(chan *Client, 64),
 		unregister:   make(chan *websocket.Conn),
@@ -133,13 +133,13 @@ func (manager *WebSocketManager) writePump(client *Client) {
 			}
 			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := client.Conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-				log.Printf("writePump error for client %s: %v", client.UserID, err)
+				slog.Info("WebSocket write failed", "user_id", client.UserID, "err", err)
 				return
 			}
 		case <-ticker.C:
 			client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := client.Conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(writeWait)); err != nil {
-				log.Printf("writePump ping error for client %s: %v", client.UserID, err)
+				slog.Info("WebSocket ping failed", "user_id", client.UserID, "err", err)
 				return
 			}
 		}
@@ -158,43 +158,35 @@ func (manager *WebSocketManager) Run() {
 
 		case conn := <-manager.unregister:
 			manager.mu.Lock()
+			var offlineUserID string
 			if client, exists := manager.clients[conn]; exists {
 				delete(manager.clients, conn)
 				if client.UserID != "" && manager.userIndex[client.UserID] == client {
 					delete(manager.userIndex, client.UserID)
+					offlineUserID = client.UserID
 				}
+				manager.dropTopicsLocked(client)
 				close(client.Send)
-				log.Printf("Client %s disconnected", client.UserID)
+				slog.Debug("WebSocket client disconnected", "user_id", client.UserID)
 			}
 			manager.mu.Unlock()
 			conn.Close()
+			if offlineUserID != "" {
+				manager.presenceChanged(offlineUserID, false)
+			}
 
 		case client := <-manager.registerUser:
 			manager.mu.Lock()
 			manager.userIndex[client.UserID] = client
 			manager.mu.Unlock()
-
-		case message := <-manager.broadcast:
-			manager.mu.Lock()
-			clients := make([]*Client, 0, len(manager.clients))
-			for _, c := range manager.clients {
-				clients = append(clients, c)
-			}
-			manager.mu.Unlock()
-			for _, client := range clients {
-				select {
-				case client.Send <- message:
-				default:
-					// buffer full; skip this client to avoid blocking
-				}
-			}
+			manager.presenceChanged(client.UserID, true)
 
 		case direct := <-manager.send:
 			manager.mu.Lock()
 			client := manager.userIndex[direct.ReceiverID]
 			if client != nil {
 				select {
-				case client.Send <- []byte(direct.Message):
+				case client.Send <- direct.Message:
 				default:
 				}
 			}
@@ -205,18 +197,29 @@ func (manager *WebSocketManager) Run() {
 
 // HandleConnections upgrades HTTP requests to WebSocket connections.
 // The read loop (readPump) sets read limit, deadline, and pong handler so dead connections are detected.
+// The upgrade request must carry an access token (see Authorizer); the connection belongs
+// to its user whatever user_id later frames claim.
 func (manager *WebSocketManager) HandleConnections(w http.ResponseWriter, r *http.Request) {
+	userID, err := manager.authenticate(r)
+	if err != nil {
+		slog.InfoContext(r.Context(), "WebSocket authentication failed", "err", err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println("WebSocket Upgrade Error:", err)
+		slog.InfoContext(r.Context(), "WebSocket upgrade failed", "err", err)
 		return
 	}
 
 	client := &Client{
-		Conn: conn,
-		Send: make(chan []byte, clientSendBufferSize),
+		Conn:   conn,
+		Send:   make(chan []byte, clientSendBufferSize),
+		UserID: userID,
 	}
 	manager.register <- client
+	manager.registerUser <- client
 
 	defer conn.Close()
 	defer func() {
@@ -237,6 +240,9 @@ func (manager *WebSocketManager) HandleConnections(w http.ResponseWriter, r *htt
 		return nil
 	})
 
+	// Frames over readLimit end the read loop; gorilla answers them with close code 1009.
+	limiter := newInboundLimiter()
+	invalid := 0
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -244,9 +250,22 @@ func (manager *WebSocketManager) HandleConnections(w http.ResponseWriter, r *htt
 		}
 		conn.SetReadDeadline(time.Now().Add(pongWait))
 
+		if !limiter.allow() {
+			slog.Warn("Disconnecting WebSocket client: inbound rate limit exceeded", "user_id", client.UserID)
+			closeWithPolicyViolation(conn, "rate limit exceeded")
+			break
+		}
+
 		var message Message
-		if err := json.Unmarshal(msg, &message); err != nil {
-			log.Println("Invalid JSON:", err)
+		if err := json.Unmarshal(msg, &message); err == nil {
+			err = manager.validateMessage(client, &message)
+		}
+		if err != nil {
+			slog.Info("Invalid WebSocket message", "user_id", client.UserID, "err", err)
+			if invalid++; invalid >= maxInvalidFrames {
+				closeWithPolicyViolation(conn, "too many invalid messages")
+				break
+			}
 			continue
 		}
 
@@ -255,50 +274,65 @@ func (manager *WebSocketManager) HandleConnections(w http.ResponseWriter, r *htt
 			// Keepalive from client; no reply needed, keeps connection alive past proxy timeouts
 
 		case MsgTypeSubscribe:
-			client.UserID = message.UserID
 			client.Latitude = message.Latitude
 			client.Longitude = message.Longitude
 			if message.ActiveGroupIDs != nil {
 				client.ActiveGroupIDs = message.ActiveGroupIDs
+				if err := manager.syncGroupTopics(client, message.ActiveGroupIDs); err != nil {
+					slog.Error("Failed to subscribe WebSocket client to its groups", "user_id", client.UserID, "err", err)
+				}
 			}
-			if client.UserID != "" {
-				manager.registerUser <- client
+
+		case MsgTypeSubscribeTopics:
+			if err := manager.subscribe(client, message.Topics); err != nil {
+				slog.Info("Rejected WebSocket topic subscription", "user_id", client.UserID, "err", err)
 			}
+			manager.topicsReply(client)
 
-		case MsgTypeReportUpdate:
-			manager.broadcast <- msg
+		case MsgTypeUnsubscribeTopics:
+			manager.unsubscribe(client, message.Topics)
+			manager.topicsReply(client)
 
 		case MsgTypeDirectMessage:
-			directMsg := DirectMessage{
-				ReceiverID: message.Receiver,
-				Message:    message.Content,
+			// The receiver gets the whole envelope with the authenticated sender, never the
+			// bare content, so a client cannot forge server events for another user.
+			if out, ok := relayed(client, message); ok {
+				manager.send <- DirectMessage{ReceiverID: message.Receiver, Message: out}
 			}
-			manager.send <- directMsg
 
 		case MsgTypeGroupChat, MsgTypeGroupLocationUpdate:
-			if message.GroupID != "" {
-				manager.BroadcastToGroup(message.GroupID, msg)
+			if out, ok := relayed(client, message); ok {
+				manager.BroadcastToGroup(message.GroupID, out)
 			}
 		}
 	}
 }
 
-// BroadcastReportUpdate sends reports only to nearby users via each client's send channel
-func (manager *WebSocketManager) BroadcastReportUpdate(report []byte, reportLat, reportLon float64, radius float64) {
+// BroadcastReportUpdate sends a report update to the clients subscribed to a region
+// topic covering the report, and to clients without region topics that are within radius
+// of it. Users in excludeUserIDs (those who dismissed the report) are skipped.
+func (manager *WebSocketManager) BroadcastReportUpdate(report []byte, reportLat, reportLon float64, radius float64, excludeUserIDs map[string]bool) {
 	manager.mu.Lock()
-	clients := make([]*Client, 0, len(manager.clients))
+	clients := make(map[*Client]struct{})
+	for _, topic := range regionTopics(reportLat, reportLon) {
+		if hub := manager.topics[topic]; hub != nil {
+			for c := range hub.clients {
+				clients[c] = struct{}{}
+			}
+		}
+	}
 	for _, c := range manager.clients {
-		if isNearby(c.Latitude, c.Longitude, reportLat, reportLon, radius) {
-			clients = append(clients, c)
+		if c.regionTopics == 0 && isNearby(c.Latitude, c.Longitude, reportLat, reportLon, radius) {
+			clients[c] = struct{}{}
 		}
 	}
-	manager.mu.Unlock()
-	for _, client := range clients {
-		select {
-		case client.Send <- report:
-		default:
+	for c := range clients {
+		if c.UserID != "" && excludeUserIDs[c.UserID] {
+			delete(clients, c)
 		}
 	}
+	deliver(clients, report)
+	manager.mu.Unlock()
 }
 
 // isNearby checks if a user is within a given radius using the Haversine formula
@@ -339,23 +373,21 @@ func (manager *WebSocketManager) GetNearbyUsers(lat, lon, radiusMeters float64,
 	return out
 }
 
-// BroadcastToGroup sends a message to all connected clients who have groupID in their ActiveGroupIDs
-func (manager *WebSocketManager) BroadcastToGroup(groupID string, message []byte) {
+// SendToUser queues message for userID's connection and reports whether they are
+// connected. Like other server sends, it is dropped if the client's buffer is full.
+func (manager *WebSocketManager) SendToUser(userID string, message []byte) bool {
 	manager.mu.Lock()
-	clients := make([]*Client, 0, len(manager.clients))
-	for _, c := range manager.clients {
-		for _, activeGrpID := range c.ActiveGroupIDs {
-			if activeGrpID == groupID {
-				clients = append(clients, c)
-				break
-			}
-		}
-	}
-	manager.mu.Unlock()
-	for _, client := range clients {
-		select {
-		case client.Send <- message:
-		default:
-		}
+	defer manager.mu.Unlock()
+	client := manager.userIndex[userID]
+	if client == nil {
+		return false
 	}
+	deliver(map[*Client]struct{}{client: {}}, message)
+	return true
+}
+
+// BroadcastToGroup sends a message to all connected clients subscribed to the group's
+// topic, directly or through active_group_ids.
+func (manager *WebSocketManager) BroadcastToGroup(groupID string, message []byte) {
+	manager.Publish(message, GroupTopic(groupID))
 }
@@ -15,6 +15,12 @@ const (
 	MsgTypeCommentUpdate       = "comment_update"
 	MsgTypeGroupChat           = "group_chat"
 	MsgTypeGroupLocationUpdate = "group_location_update"
+	MsgTypeSubscribeTopics     = "subscribe_topics"
+	MsgTypeUnsubscribeTopics   = "unsubscribe_topics"
+	MsgTypeTopics              = "topics"            // server reply listing the client's topics
+	MsgTypeNavigationUpdate    = "navigation_update" // published on navigation:<session>
+	MsgTypeAlertZoneReport     = "alert_zone_report" // sent to a user when a report lands in one of their zones
+	MsgTypeRideMatch           = "ride_match"        // sent to both riders when a group ride offer and request match
 )
 
 // ReportUpdatePayload is sent in Message.Content for report_update events.
@@ -39,23 +45,30 @@ type Client struct {
 	Latitude       float64
 	Longitude      float64
 	ActiveGroupIDs []string
+	// topics and regionTopics (how many of them are report regions) are guarded by
+	// WebSocketManager.mu.
+	topics       map[string]struct{}
+	regionTopics int
 }
 
 type WebSocketManager struct {
 	clients    map[*websocket.Conn]*Client
 	userIndex  map[string]*Client // userID -> client for O(1) direct messaging
-	broadcast  chan []byte
+	topics     map[string]*topicHub // topic -> subscribed clients
 	register   chan *Client
 	registerUser chan *Client     // client that just subscribed (has UserID set); updates userIndex
 	unregister chan *websocket.Conn
 	send       chan DirectMessage
+	presence   PresenceStore // optional; shares presence with other instances
+	authorizer Authorizer    // authenticates connections and guards private topics
 	mu         sync.Mutex
 }
 
-// DirectMessage struct for 1-on-1 messages
+// DirectMessage struct for 1-on-1 messages. Message is the encoded frame delivered to
+// the receiver.
 type DirectMessage struct {
 	ReceiverID string `json:"receiver_id"`
-	Message    string `json:"message"`
+	Message    []byte `json:"message"`
 }
 
 // NearbyUser is a minimal representation of a connected user for the nearby-users API.
@@ -75,4 +88,9 @@ type Message struct {
 	Receiver       string   `json:"receiver,omitempty"`
 	GroupID        string   `json:"group_id,omitempty"`
 	ActiveGroupIDs []string `json:"active_group_ids,omitempty"`
+	// Topics is set on subscribe_topics, unsubscribe_topics and topics messages.
+	Topics []string `json:"topics,omitempty"`
+	// EventID is set on server-published events; delivery is at-least-once so clients
+	// should ignore an event_id they have already handled.
+	EventID string `json:"event_id,omitempty"`
 }